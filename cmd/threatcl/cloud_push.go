@@ -29,6 +29,10 @@ Usage: threatcl cloud push <file> [-no-create] [-no-update-local] [-ignore-linke
 	This command validates, creates (if needed), and uploads a threat model
 	to ThreatCL Cloud in a single operation.
 
+	Before anything is uploaded, the model is scanned for secrets (see
+	'threatcl scan'); any finding without a "threatcl:allow-secret" marker
+	blocks the push.
+
 	The file must contain exactly one threat model and have a valid backend
 	block configured for threatcl-cloud with an organization specified.
 
@@ -161,6 +165,12 @@ func (c *CloudPushCommand) Run(args []string) int {
 		return 1
 	}
 
+	// Secret scan preflight: nothing leaves the machine while the model holds
+	// an unmarked secret.
+	if !runSecretPreflight(filePath, wrapped.Threatmodels) {
+		return 1
+	}
+
 	// A dotted id or extends marks this file as one segment of a multi-file
 	// model; point at the local preflight when it wasn't requested.
 	if c.flagWith == "" {
@@ -29,6 +29,9 @@ Usage: threatcl cloud upload <file> -model-id=<modelId_or_slug> [-org-id=<orgId>
 
 	The -model-id flag is required and can be either a threat model ID or slug.
 
+	Before uploading, the model is scanned for secrets (see 'threatcl scan');
+	any finding without a "threatcl:allow-secret" marker blocks the upload.
+
 	If -org-id is not provided, the command will check the THREATCL_CLOUD_ORG
 	environment variable. If that is also not set, it will use the first
 	organization from your user profile.
@@ -138,6 +141,11 @@ func (c *CloudUploadCommand) Run(args []string) int {
 		return 1
 	}
 
+	// Secret scan preflight before anything leaves the machine
+	if !runSecretPreflight(filePath, tmParser.GetWrapped().Threatmodels) {
+		return 1
+	}
+
 	// Retrieve token and org ID, then build the cloud client
 	token, orgId, apiURL, err := c.getTokenAndOrgId(c.flagOrgId, keyringSvc, fsSvc)
 	if err != nil {
@@ -14,8 +14,14 @@ import (
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/posener/complete"
 	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/cadence"
+	"github.com/threatcl/threatcl/internal/dfd"
+	"github.com/threatcl/threatcl/internal/redact"
 	"github.com/threatcl/threatcl/internal/tmloader"
 	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
 )
 
 type tmListEntryType struct {
@@ -27,6 +33,14 @@ type tmListEntryType struct {
 	InternetFacing string
 	Size           string
 	HasDfd         string
+	LastReviewed   string
+	Due            string
+	Overdue        bool
+
+	// InsecureFlows and UnprotectedAssets count the findings of
+	// 'threatcl dfd classify'
+	InsecureFlows     int
+	UnprotectedAssets int
 }
 
 // DashboardCommand struct defines the "threatcl dashboard" commands
@@ -37,10 +51,14 @@ type DashboardCommand struct {
 	flagOutExt              string
 	flagOverwrite           bool
 	flagNoDfd               bool
+	flagNoClassify          bool
 	flagDashboardTemplate   string
 	flagThreatmodelTemplate string
 	flagDashboardFilename   string
 	flagDashboardHTML       bool
+	flagCadence             string
+	flagRedact              string
+	flagTheme               string
 }
 
 // Help is the help output for "threatcl dashboard"
@@ -67,14 +85,38 @@ Options:
 
  -nodfd
 
+ -noclassify
+   Do not append the insecure flows and unprotected information assets
+   tables found by 'threatcl dfd classify'. Each dashboard entry exposes
+   .InsecureFlows and .UnprotectedAssets counts to the dashboard template
+   either way
+
  -dashboard-template=<file>
 
  -dashboard-filename=<filename>
 
  -dashboard-html
+   Render as HTML. Unless -nodfd is set, each threat model's DFDs are
+   inlined as SVGs, instead of PNGs, that show an element's trust zone,
+   information asset and threats, or a flow's protocol and data, on hover,
+   and link to their headings
 
  -threatmodel-template=<file>
 
+ -cadence=<file>
+   Optional HCL review-cadence policy file. Each dashboard entry exposes
+   .LastReviewed, .Due and .Overdue to the dashboard template. See
+   'threatcl stale -h'
+
+ -redact=<file>
+   Optional HCL redaction profile applied to every threat model before it's
+   rendered. See 'threatcl export -h'
+
+ -theme=<file>
+   Optional HCL theme file styling the DFDs. Defaults to dfd-theme.hcl in
+   the same directory as the -config file, if there is one. See
+   'threatcl dfd -h'
+
 `
 	return strings.TrimSpace(helpText)
 }
@@ -90,7 +132,11 @@ func (c *DashboardCommand) Run(args []string) int {
 	flagSet.StringVar(&c.flagThreatmodelTemplate, "threatmodel-template", "", "Template file to override the default threatmodel.md file(s)")
 	flagSet.BoolVar(&c.flagOverwrite, "overwrite", false, "Overwrite existing files in the outdir. Defaults to false")
 	flagSet.BoolVar(&c.flagNoDfd, "nodfd", false, "Do not include generated DFD images. Defaults to false")
+	flagSet.BoolVar(&c.flagNoClassify, "noclassify", false, "Do not include data classification tables. Defaults to false")
 	flagSet.BoolVar(&c.flagDashboardHTML, "dashboard-html", false, "Render as HTML instead of text. Implies --out-ext=html.")
+	flagSet.StringVar(&c.flagCadence, "cadence", "", "Optional HCL review-cadence policy file for the last reviewed and due columns")
+	flagSet.StringVar(&c.flagRedact, "redact", "", "Optional HCL redaction profile to apply before rendering")
+	flagSet.StringVar(&c.flagTheme, "theme", "", "HCL theme file styling the diagrams")
 	parseFlags(flagSet, args)
 
 	if c.flagConfig != "" {
@@ -119,6 +165,27 @@ func (c *DashboardCommand) Run(args []string) int {
 		return 1
 	}
 
+	policy, err := loadCadencePolicy(c.flagCadence)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+
+	var profile *redact.Profile
+	if c.flagRedact != "" {
+		profile, err = loadRedactProfile(c.flagRedact)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			return 1
+		}
+	}
+
+	theme, err := loadDfdTheme(c.flagTheme, c.flagConfig)
+	if err != nil {
+		fmt.Printf("Error reading -theme: %s\n", err)
+		return 1
+	}
+
 	outExt := c.flagOutExt
 	if c.flagDashboardHTML {
 		outExt = "html"
@@ -186,6 +253,10 @@ func (c *DashboardCommand) Run(args []string) int {
 		return 1
 	}
 
+	if profile != nil {
+		fmt.Print(profile.Apply(loadedThreatmodels(res.Models)).String())
+	}
+
 	// Walk the parsed models - just to determine output files
 	for _, lm := range res.Models {
 		tm := lm.TM
@@ -218,6 +289,8 @@ func (c *DashboardCommand) Run(args []string) int {
 	// @TODO Fix the race condition (or TOCTOU problem)
 
 	tmList := []tmListEntryType{}
+	sources := cadence.NewSources()
+	now := time.Now()
 
 	for _, lm := range res.Models {
 		file := lm.File
@@ -225,10 +298,11 @@ func (c *DashboardCommand) Run(args []string) int {
 		{
 
 			// First we check if there are any DFDs
-			if !c.flagNoDfd && len(tm.DataFlowDiagrams) > 0 {
+			// With -dashboard-html the DFDs are inlined as SVGs instead
+			if !c.flagNoDfd && !c.flagDashboardHTML && len(tm.DataFlowDiagrams) > 0 {
 				for _, adfd := range tm.DataFlowDiagrams {
 					dfdPath := outfilePath(c.flagOutDir, fmt.Sprintf("%s_%s", tm.Name, adfd.Name), file, ".png")
-					err = adfd.GenerateDfdPng(dfdPath, tm.Name, spec.DfdRenderOptions{})
+					err = writeDashboardPng(&tm, adfd, dfdPath, theme)
 					if err != nil {
 						fmt.Printf("Error generating DFD: %s\n", err)
 						return 1
@@ -252,6 +326,11 @@ func (c *DashboardCommand) Run(args []string) int {
 				return 1
 			}
 
+			classification := classifyThreatmodel(&tm)
+			if md := classification.Markdown(); !c.flagNoClassify && md != "" {
+				rendered = append(rendered, []byte("\n"+md)...)
+			}
+
 			// When emitting HTML, convert the rendered Markdown to sanitized
 			// HTML. RenderMarkdown uses text/template, so threat-model fields
 			// (which may originate from untrusted .hcl files) are not escaped;
@@ -259,7 +338,15 @@ func (c *DashboardCommand) Run(args []string) int {
 			// embedded markup execute as stored XSS when the dashboard is
 			// served or opened in a browser.
 			if c.flagDashboardHTML {
-				rendered, err = markdownToSafeHTML(rendered, tm.Name)
+				var dfds template.HTML
+				if !c.flagNoDfd && len(tm.DataFlowDiagrams) > 0 {
+					dfds, err = interactiveDfdHTML(&tm, markdownHeadings(rendered), theme)
+					if err != nil {
+						fmt.Printf("Error generating DFD: %s\n", err)
+						return 1
+					}
+				}
+				rendered, err = markdownToSafeHTML(rendered, tm.Name, dfds)
 				if err != nil {
 					fmt.Printf("Error rendering HTML: %s\n", err)
 					return 1
@@ -330,6 +417,13 @@ func (c *DashboardCommand) Run(args []string) int {
 				tmListEntry.HasDfd = "Yes"
 			}
 
+			st := policy.Evaluate(lm.TM, reviewEvidence(sources, lm), now)
+			tmListEntry.LastReviewed = cadence.FormatDate(st.LastReviewed)
+			tmListEntry.Due = dueString(st)
+			tmListEntry.Overdue = st.Overdue
+			tmListEntry.InsecureFlows = len(classification.InsecureFlows)
+			tmListEntry.UnprotectedAssets = len(classification.UnprotectedAssets)
+
 			tmList = append(tmList, tmListEntry)
 		}
 	}
@@ -358,6 +452,20 @@ func (c *DashboardCommand) Run(args []string) int {
 	return 0
 }
 
+// writeDashboardPng writes adfd, a DFD of tm, as a PNG, styled by theme if
+// it isn't nil.
+func writeDashboardPng(tm *spec.Threatmodel, adfd *spec.DataFlowDiagram, path string, theme *dfd.Theme) error {
+	if theme == nil {
+		return adfd.GenerateDfdPng(path, tm.Name, spec.DfdRenderOptions{})
+	}
+	g := dfd.FromSpec(adfd)
+	png, err := dfd.Png(dfd.Dot(g, tm.Name, themedDfdOptions(tm, g, theme)))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, png, 0600)
+}
+
 func unixToTime(unixtime int64) string {
 	utime := time.Unix(unixtime, 0)
 	return utime.Format("2006-01-02")
@@ -365,7 +473,9 @@ func unixToTime(unixtime int64) string {
 
 // tmHTMLDocTemplate wraps sanitized threat-model HTML in a minimal document.
 // The body is pre-sanitized (bluemonday) before being marked template.HTML;
-// the title is a plain string and is context-escaped by html/template.
+// the title is a plain string and is context-escaped by html/template. The
+// diagrams are generated by interactiveDfdHTML rather than the threat model,
+// so they're added after sanitization.
 var tmHTMLDocTemplate = template.Must(template.New("tmHTMLDoc").Parse(`<!DOCTYPE html>
 <html lang="en">
 <head>
@@ -374,18 +484,65 @@ var tmHTMLDocTemplate = template.Must(template.New("tmHTMLDoc").Parse(`<!DOCTYPE
 </head>
 <body>
 {{ .Body }}
+{{- with .Diagrams }}
+{{ . }}
+{{- end }}
 </body>
 </html>
 `))
 
+// dashboardMarkdown gives headings ids, which the interactive DFDs link to.
+var dashboardMarkdown = goldmark.New(goldmark.WithParserOptions(parser.WithAutoHeadingID()))
+
+// markdownHeadings maps the text of markdown's headings to the ids
+// markdownToSafeHTML gives them. Repeated headings map to the first one.
+func markdownHeadings(markdown []byte) map[string]string {
+	headings := map[string]string{}
+	doc := dashboardMarkdown.Parser().Parse(text.NewReader(markdown))
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if id, ok := h.AttributeString("id"); ok {
+			t := strings.TrimSpace(inlineText(h, markdown))
+			if _, ok := headings[t]; !ok {
+				headings[t] = string(id.([]byte))
+			}
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	return headings
+}
+
+// inlineText is the plain text of n's inline children.
+func inlineText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c := c.(type) {
+		case *ast.Text:
+			b.Write(c.Segment.Value(source))
+			if c.SoftLineBreak() || c.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(c.Value)
+		default:
+			b.WriteString(inlineText(c, source))
+		}
+	}
+	return b.String()
+}
+
 // markdownToSafeHTML converts rendered Markdown into a self-contained HTML
 // document. goldmark is used without the "unsafe" option (so raw HTML embedded
 // in the Markdown is not passed through), and the result is additionally run
 // through bluemonday's UGC policy. Together this ensures that HTML in untrusted
 // threat-model fields cannot execute as script when the dashboard is viewed.
-func markdownToSafeHTML(markdown []byte, title string) ([]byte, error) {
+// Headings keep their ids. diagrams, the interactive DFDs, are appended as is.
+func markdownToSafeHTML(markdown []byte, title string, diagrams template.HTML) ([]byte, error) {
 	var htmlBuf bytes.Buffer
-	if err := goldmark.New().Convert(markdown, &htmlBuf); err != nil {
+	if err := dashboardMarkdown.Convert(markdown, &htmlBuf); err != nil {
 		return nil, err
 	}
 
@@ -393,11 +550,13 @@ func markdownToSafeHTML(markdown []byte, title string) ([]byte, error) {
 
 	var out bytes.Buffer
 	err := tmHTMLDocTemplate.Execute(&out, struct {
-		Title string
-		Body  template.HTML
+		Title    string
+		Body     template.HTML
+		Diagrams template.HTML
 	}{
-		Title: title,
-		Body:  template.HTML(safe),
+		Title:    title,
+		Body:     template.HTML(safe),
+		Diagrams: diagrams,
 	})
 	if err != nil {
 		return nil, err
@@ -418,5 +577,8 @@ func (c *DashboardCommand) AutocompleteFlags() complete.Flags {
 		"-outdir":               complete.PredictDirs("*"),
 		"-dashboard-template":   predictTpl,
 		"-threatmodel-template": predictTpl,
+		"-cadence":              predictHCL,
+		"-redact":               predictHCL,
+		"-theme":                predictHCL,
 	}
 }
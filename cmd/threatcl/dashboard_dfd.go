@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/dfd"
+)
+
+// dfdDetailsTemplate lays out a threat model's interactive DFDs. The
+// diagrams are trusted Graphviz output, everything else is escaped.
+var dfdDetailsTemplate = template.Must(template.New("dfdDetails").Parse(`<section class="interactive-dfds">
+<h2>Interactive data flow diagrams</h2>
+{{- range . }}
+<figure class="dfd" id="{{ .Anchor }}">
+<figcaption>{{ .Name }}</figcaption>
+{{ .Svg }}
+</figure>
+{{- end }}
+</section>
+`))
+
+// dfdHeadingSections are the headings of the threat model's rendered
+// Markdown that elements link to when it has no heading of their own.
+var dfdHeadingSections = map[string][]string{
+	dfd.ThreatSection: {"threats", "threat scenarios"},
+	dfd.AssetSection:  {"information assets"},
+}
+
+type dfdDetailsDiagram struct {
+	Anchor string
+	Name   string
+	Svg    template.HTML
+}
+
+// interactiveDfdHTML renders tm's DFDs as SVGs whose elements and flows
+// describe themselves on hover, and link to the threat and information asset
+// headings of the rendered threat model, as returned by markdownHeadings.
+// Decomposed processes link to their child diagrams. theme, if not nil,
+// styles the diagrams.
+func interactiveDfdHTML(tm *spec.Threatmodel, headings map[string]string, theme *dfd.Theme) (template.HTML, error) {
+	diagrams := []dfdDetailsDiagram{}
+
+	taken := map[string]bool{}
+	folded := map[string]string{}
+	for text, id := range headings {
+		taken[id] = true
+		folded[strings.ToLower(text)] = id
+	}
+	anchors := map[string]string{}
+	for _, d := range tm.DataFlowDiagrams {
+		a := htmlAnchor(taken, "dfd", d.Name)
+		if _, ok := anchors[d.Name]; !ok {
+			anchors[d.Name] = a
+		}
+		diagrams = append(diagrams, dfdDetailsDiagram{Anchor: a, Name: d.Name})
+	}
+
+	link := func(section, name string) string {
+		if section == dfd.DiagramSection {
+			if a, ok := anchors[name]; ok {
+				return "#" + a
+			}
+			return ""
+		}
+		if id, ok := headings[name]; ok {
+			return "#" + id
+		}
+		for _, h := range dfdHeadingSections[section] {
+			if id, ok := folded[h]; ok {
+				return "#" + id
+			}
+		}
+		return ""
+	}
+
+	for i, d := range tm.DataFlowDiagrams {
+		svg, err := interactiveDfdSvg(tm, d, link, theme)
+		if err != nil {
+			return "", fmt.Errorf("error rendering data flow diagram %q: %s", d.Name, err)
+		}
+		s := string(svg)
+		if i := strings.Index(s, "<svg"); i >= 0 {
+			s = s[i:]
+		}
+		diagrams[i].Svg = template.HTML(s)
+	}
+
+	var buf bytes.Buffer
+	if err := dfdDetailsTemplate.Execute(&buf, diagrams); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+var nonAnchor = regexp.MustCompile(`[^a-z0-9]+`)
+
+// htmlAnchor returns a page-unique anchor for name.
+func htmlAnchor(taken map[string]bool, prefix, name string) string {
+	s := strings.Trim(nonAnchor.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if s == "" {
+		s = "item"
+	}
+	base := prefix + "-" + s
+	a := base
+	for n := 2; taken[a]; n++ {
+		a = fmt.Sprintf("%s-%d", base, n)
+	}
+	taken[a] = true
+	return a
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/dfd"
+)
+
+func TestInteractiveDfdHTML(t *testing.T) {
+	tm := &spec.Threatmodel{
+		Name: "Shop",
+		InformationAssets: []*spec.InformationAsset{
+			{Name: "Card <data>", InformationClassification: "Restricted"},
+		},
+		Threats: []*spec.Threat{
+			{Name: "Web defacement", Controls: []*spec.Control{{Name: "WAF", Implemented: true}}},
+			{Name: "Card theft", InformationAssetRefs: []string{"Card <data>"}},
+		},
+		DataFlowDiagrams: []*spec.DataFlowDiagram{{
+			Name: "Level 1: Web",
+		}, {
+			Name:             "Level 0",
+			ExternalElements: []*spec.DfdExternal{{Name: "User"}},
+			TrustZones: []*spec.DfdTrustZone{{
+				Name:       "DMZ",
+				Processes:  []*spec.DfdProcess{{Name: "Web"}},
+				DataStores: []*spec.DfdData{{Name: "Cards", IaLink: "Card <data>"}},
+			}},
+			Flows: []*spec.DfdFlow{
+				{Name: "Browse", From: "User", To: "Web", Protocol: "https"},
+				{Name: "Store", From: "Web", To: "Cards"},
+			},
+		}},
+	}
+
+	md := []byte("# Shop\n\n## Information Assets\n\n| Card <data> | Restricted |\n\n## Threats\n\n### Card theft\n\n### Web *defacement*\n")
+	headings := markdownHeadings(md)
+	if headings["Card theft"] != "card-theft" || headings["Web defacement"] != "web-defacement" {
+		t.Errorf("unexpected headings: %v", headings)
+	}
+
+	out, err := interactiveDfdHTML(tm, headings, nil)
+	if err != nil {
+		t.Fatalf("error rendering: %s", err)
+	}
+	for _, want := range []string{
+		"<figcaption>Level 0</figcaption>\n<svg",
+		`<figure class="dfd" id="dfd-level-1-web">`,
+		`xlink:href="#dfd-level-1-web"`,
+		`xlink:href="#card-theft"`,
+		`xlink:href="#information-assets"`,
+		`xlink:title="Web (process)&#10;Trust zone: DMZ&#10;Threats: Web defacement&#10;Decomposed in: Level 1: Web"`,
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"<?xml", "Threats and controls", "<h2>Information assets</h2>"} {
+		if strings.Contains(string(out), unwanted) {
+			t.Errorf("unexpected %q in:\n%s", unwanted, out)
+		}
+	}
+
+	doc, err := markdownToSafeHTML(md, "Shop", out)
+	if err != nil {
+		t.Fatalf("error rendering html: %s", err)
+	}
+	for _, want := range []string{
+		`<h3 id="card-theft">Card theft</h3>`,
+		`<h2 id="information-assets">Information Assets</h2>`,
+		"</h3>\n\n<section class=\"interactive-dfds\">",
+	} {
+		if !strings.Contains(string(doc), want) {
+			t.Errorf("expected %q in:\n%s", want, doc)
+		}
+	}
+}
+
+func TestInteractiveDfdHTMLTheme(t *testing.T) {
+	theme, err := dfd.ParseThemeFile(writeDfdTheme(t, dfdTheme))
+	if err != nil {
+		t.Fatalf("error reading theme: %s", err)
+	}
+	tm := &spec.Threatmodel{
+		Name: "Shop",
+		DataFlowDiagrams: []*spec.DataFlowDiagram{{
+			Name: "Level 0",
+			TrustZones: []*spec.DfdTrustZone{{
+				Name:       "Internal",
+				Processes:  []*spec.DfdProcess{{Name: "Web"}},
+				DataStores: []*spec.DfdData{{Name: "Cards"}},
+			}},
+			Flows: []*spec.DfdFlow{{Name: "Store", From: "Web", To: "Cards"}},
+		}},
+	}
+
+	out, err := interactiveDfdHTML(tm, nil, theme)
+	if err != nil {
+		t.Fatalf("error rendering: %s", err)
+	}
+	for _, want := range []string{`fill="#eeeeee"`, "Trust zone: Internal"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
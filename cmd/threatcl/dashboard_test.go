@@ -174,6 +174,45 @@ func TestDashboardWithDfd(t *testing.T) {
 
 }
 
+func TestDashboardClassification(t *testing.T) {
+	tmFile := writeAnalyzeTm(t)
+	outDir := filepath.Join(t.TempDir(), "out")
+
+	for _, tc := range []struct {
+		args   []string
+		tables bool
+	}{
+		{[]string{"-nodfd"}, true},
+		{[]string{"-nodfd", "-noclassify", "-overwrite"}, false},
+	} {
+		cmd := testDashboardCommand(t)
+
+		var code int
+		out := capturer.CaptureStdout(func() {
+			code = cmd.Run(append(tc.args, fmt.Sprintf("-outdir=%s", outDir), tmFile))
+		})
+
+		if code != 0 {
+			t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+		}
+
+		tmfile, err := os.ReadFile(filepath.Join(outDir, "shop-shop.md"))
+		if err != nil {
+			t.Fatalf("Error opening threat model file: %s", err)
+		}
+
+		for _, exp := range []string{
+			"### Insecure Flows",
+			"| Level 0 | Browse | User (outside) | Web (Internal) | unset | Restricted | Card data |",
+			"| Card data | Restricted | Cards | no threat refers to it |",
+		} {
+			if strings.Contains(string(tmfile), exp) != tc.tables {
+				t.Errorf("With %v, expected %s to contain %s: %t", tc.args, tmfile, exp, tc.tables)
+			}
+		}
+	}
+}
+
 func TestDashboardOverwrite(t *testing.T) {
 	d, err := os.MkdirTemp("", "")
 	if err != nil {
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/posener/complete"
 	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/dfd"
 	"github.com/threatcl/threatcl/internal/tmloader"
 )
 
@@ -19,9 +23,25 @@ type DfdCommand struct {
 	flagStdout        bool
 	flagIndex         int
 	flagProtocolStyle string
+	flagDiffFrom      string
+	flagFleet         bool
+	flagCluster       string
+	flagAliases       string
+	flagTheme         string
+	flagFocus         string
+	flagDepth         int
+	flagZone          string
+	flagPathFrom      string
+	flagPathTo        string
 	renderOpts        spec.DfdRenderOptions
+	theme             *dfd.Theme
+	focus             *dfd.FocusOptions
 }
 
+// dfdThemeFile is the theme used when -theme isn't set, if there's one in
+// the same directory as the -config file.
+const dfdThemeFile = "dfd-theme.hcl"
+
 func (c *DfdCommand) Help() string {
 	helpText := `
 Usage: threatcl dfd [options] -outdir=<directory> <files>
@@ -38,12 +58,15 @@ Usage: threatcl dfd [options] -outdir=<directory> <files>
    converted.
    Either this, or -outdir, must be set
 
- -format=<png|dot|svg|mermaid|d2>
-   Output format. If not set, defaults to png.
+ -format=<png|dot|svg|mermaid|d2|plantuml|drawio>
+   Output format. If not set, defaults to png. drawio writes diagrams.net
+   XML, with trust zones as containers. With -outdir, svg diagrams link
+   each decomposed process to its child diagram's file (see
+   'threatcl dfd validate -h')
 
  -stdout
-   If the format is a text format (dot, mermaid, d2), you can output
-   directly to STDOUT
+   If the format is a text format (dot, mermaid, d2, plantuml, drawio), you
+   can output directly to STDOUT
 
  -protocol-style=<label|color|both|none>
    How to render the optional 'protocol' attribute on DFD flows.
@@ -52,6 +75,55 @@ Usage: threatcl dfd [options] -outdir=<directory> <files>
    - both:  combine label and color
    - none:  ignore the protocol attribute entirely
 
+ -diff-from=<file|git ref>
+   Render a single DFD with the changes since an older version of it,
+   either in a threat model file or in the same files at a git ref. Added
+   elements and flows are drawn in green, removed ones ghosted in red, and
+   elements that changed trust zone in orange. Supports the png, svg,
+   mermaid and dot formats. Use -index to choose the DFD. With -outdir, the
+   file name ends in -diff
+
+ -fleet
+   Merge every DFD of every threat model found in <files> into one
+   organisation-wide map. External elements and data stores with the same
+   name, or an alias set with -aliases, and the same kind become one
+   element. Supports the svg, dot, d2 and mermaid formats. With -outdir, the file is named fleet
+
+ -cluster=<model|zone>
+   How -fleet groups elements: by threat model (default) or by trust zone
+
+ -aliases=<file>
+   Optional HCL file of the other names -fleet should merge elements
+   under, as element blocks:
+     element "Payments DB" {
+       aliases = ["payments-db"]
+     }
+
+ -theme=<file>
+   Optional HCL theme file styling the dot, svg, png, mermaid and d2
+   formats: trust zone colours, element shapes and fonts, flow styles by
+   protocol or data classification, a legend, the layout direction and
+   the spacing of trust zones. Defaults to dfd-theme.hcl in the same
+   directory as the -config file, if there is one. See the README for the
+   format
+
+ -focus=<element>
+   Only render the elements within -depth flows of an element, in either
+   direction. Flows to and from the elements left out are collapsed into
+   "…" stubs. Works with every format. With -outdir, DFDs without the
+   element are skipped
+
+ -depth=<n>
+   How many flows away from -focus to render. Defaults to 1
+
+ -zone=<trust zone>
+   Only render the elements of a trust zone, with stubs for the elements
+   outside it
+
+ -path-from=<element> -path-to=<element>
+   Only render the elements on the paths from one element to another, with
+   stubs for their other neighbours. Both must be set
+
 Options:
 
  -config=<file>
@@ -63,29 +135,33 @@ Options:
 	return strings.TrimSpace(helpText)
 }
 
-func (c *DfdCommand) extractDfd(models []tmloader.LoadedModel, index int) (*spec.DataFlowDiagram, string, error) {
+func (c *DfdCommand) extractDfd(models []tmloader.LoadedModel, index int) (*spec.DataFlowDiagram, *spec.Threatmodel, error) {
 	for _, lm := range models {
 		tm := lm.TM
 
 		if len(tm.DataFlowDiagrams) > 0 {
 			for idx, adfd := range tm.DataFlowDiagrams {
 				if idx+1 == index {
-					return adfd, tm.Name, nil
+					return adfd, tm, nil
 				}
 			}
 		}
 	}
-	return nil, "", fmt.Errorf("no DFD found with that index")
+	return nil, nil, fmt.Errorf("no DFD found with that index")
 }
 
 func (c *DfdCommand) genDfdPng(models []tmloader.LoadedModel, index int, filepath string) error {
 
-	adfd, tmName, err := c.extractDfd(models, index)
+	adfd, tm, err := c.extractDfd(models, index)
 	if err != nil {
 		return err
 	}
 
-	err = adfd.GenerateDfdPng(filepath, tmName, c.renderOpts)
+	if c.rendersGraph(c.flagFormat) {
+		return c.writeGraphDfd(tm, adfd, filepath)
+	}
+
+	err = adfd.GenerateDfdPng(filepath, tm.Name, c.renderOpts)
 	if err != nil {
 		return err
 	}
@@ -95,12 +171,16 @@ func (c *DfdCommand) genDfdPng(models []tmloader.LoadedModel, index int, filepat
 
 func (c *DfdCommand) genDfdSvg(models []tmloader.LoadedModel, index int, filepath string) error {
 
-	adfd, tmName, err := c.extractDfd(models, index)
+	adfd, tm, err := c.extractDfd(models, index)
 	if err != nil {
 		return err
 	}
 
-	err = adfd.GenerateDfdSvg(filepath, tmName, c.renderOpts)
+	if c.rendersGraph(c.flagFormat) {
+		return c.writeGraphDfd(tm, adfd, filepath)
+	}
+
+	err = adfd.GenerateDfdSvg(filepath, tm.Name, c.renderOpts)
 	if err != nil {
 		return err
 	}
@@ -108,6 +188,116 @@ func (c *DfdCommand) genDfdSvg(models []tmloader.LoadedModel, index int, filepat
 
 }
 
+// decomposesProcess reports whether another of tm's DFDs decomposes one of
+// adfd's processes (see dfd.Decomposes).
+func decomposesProcess(tm *spec.Threatmodel, adfd *spec.DataFlowDiagram) bool {
+	graphs := []*dfd.Graph{}
+	for _, d := range tm.DataFlowDiagrams {
+		graphs = append(graphs, dfd.FromSpec(d))
+	}
+	for _, d := range dfd.Decompositions(graphs) {
+		if d.Parent == adfd.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// dfdOptions are the options rendering g, a DFD of tm, with -theme
+// styling flows by the classification of the data they carry.
+func (c *DfdCommand) dfdOptions(tm *spec.Threatmodel, g *dfd.Graph) dfd.RenderOptions {
+	opts := themedDfdOptions(tm, g, c.theme)
+	opts.ProtocolStyle = c.renderOpts.ProtocolStyle
+	return opts
+}
+
+// themedDfdOptions are the options rendering g, a DFD of tm, with theme,
+// which may be nil.
+func themedDfdOptions(tm *spec.Threatmodel, g *dfd.Graph, theme *dfd.Theme) dfd.RenderOptions {
+	opts := dfd.RenderOptions{Theme: theme}
+	if theme != nil {
+		opts.Classifications = dfd.FlowClassifications(g, dfd.AssetsFromSpec(tm))
+	}
+	return opts
+}
+
+// loadDfdTheme parses the -theme file, or without one the dfdThemeFile
+// beside the -config file. It returns nil if there's neither.
+func loadDfdTheme(theme, config string) (*dfd.Theme, error) {
+	if theme == "" && config != "" {
+		path := filepath.Join(filepath.Dir(config), dfdThemeFile)
+		if _, err := os.Stat(path); err == nil {
+			theme = path
+		}
+	}
+	if theme == "" {
+		return nil, nil
+	}
+	return dfd.ParseThemeFile(theme)
+}
+
+// isThemedFormat reports whether -theme styles the format.
+func isThemedFormat(format string) bool {
+	switch format {
+	case "dot", "svg", "png", "mermaid", "d2":
+		return true
+	}
+	return false
+}
+
+// rendersGraph reports whether DFDs in format are rendered from their
+// graphs, rather than by the spec module, to apply -theme or a focused view.
+func (c *DfdCommand) rendersGraph(format string) bool {
+	return c.focus != nil || (c.theme != nil && isThemedFormat(format))
+}
+
+// dfdGraph is adfd's graph, cut down to the focused view if there is one.
+func (c *DfdCommand) dfdGraph(adfd *spec.DataFlowDiagram) (*dfd.Graph, error) {
+	g := dfd.FromSpec(adfd)
+	if c.focus == nil {
+		return g, nil
+	}
+	return dfd.Focus(g, *c.focus)
+}
+
+// renderDfd renders adfd, a DFD of tm, in format from its graph (see
+// rendersGraph).
+func (c *DfdCommand) renderDfd(tm *spec.Threatmodel, adfd *spec.DataFlowDiagram, format string) ([]byte, error) {
+	g, err := c.dfdGraph(adfd)
+	if err != nil {
+		return nil, err
+	}
+	opts := c.dfdOptions(tm, g)
+	switch format {
+	case "mermaid":
+		return []byte(dfd.Mermaid(g, tm.Name, opts)), nil
+	case "d2":
+		return []byte(dfd.D2(g, tm.Name, opts)), nil
+	case "dot":
+		return []byte(dfd.Dot(g, tm.Name, opts)), nil
+	case "svg":
+		return dfd.Svg(dfd.Dot(g, tm.Name, opts))
+	case "png":
+		return dfd.Png(dfd.Dot(g, tm.Name, opts))
+	case "plantuml":
+		return []byte(dfd.PlantUML(g, tm.Name, c.renderOpts)), nil
+	case "drawio":
+		out, err := dfd.Drawio(g, tm.Name, c.renderOpts)
+		return []byte(out), err
+	}
+	return nil, fmt.Errorf("unsupported format: %s", format)
+}
+
+// writeGraphDfd saves adfd, a DFD of tm, to path in the -format, rendered
+// from its graph.
+func (c *DfdCommand) writeGraphDfd(tm *spec.Threatmodel, adfd *spec.DataFlowDiagram, path string) error {
+	out, err := c.renderDfd(tm, adfd, c.flagFormat)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0600)
+}
+
 // writeSingle saves the DFD at the given index to c.flagOutFile using the
 // configured output format.
 func (c *DfdCommand) writeSingle(models []tmloader.LoadedModel, index int) int {
@@ -145,12 +335,52 @@ func (c *DfdCommand) writeSingle(models []tmloader.LoadedModel, index int) int {
 // written verbatim to a file or stdout (as opposed to a binary image format).
 func isTextFormat(format string) bool {
 	switch format {
-	case "dot", "mermaid", "d2":
+	case "dot", "mermaid", "d2", "plantuml", "drawio":
 		return true
 	}
 	return false
 }
 
+// dfdExt is the file extension of a DFD output format.
+func dfdExt(format string) string {
+	if format == "plantuml" {
+		return ".puml"
+	}
+	return fmt.Sprintf(".%s", format)
+}
+
+// parseFocus reads the focused view set by -focus, -zone or -path-from and
+// -path-to, if any.
+func (c *DfdCommand) parseFocus() (*dfd.FocusOptions, error) {
+	set := 0
+	for _, f := range []string{c.flagFocus, c.flagZone, c.flagPathFrom + c.flagPathTo} {
+		if f != "" {
+			set++
+		}
+	}
+	switch {
+	case set == 0:
+		return nil, nil
+	case set > 1:
+		return nil, fmt.Errorf("Only one of -focus, -zone or -path-from and -path-to can be set")
+	case c.flagDiffFrom != "":
+		return nil, fmt.Errorf("-focus, -zone and -path-from can't be used with -diff-from")
+	case c.flagDepth < 0:
+		return nil, fmt.Errorf("-depth can't be negative")
+	}
+
+	if (c.flagPathFrom == "") != (c.flagPathTo == "") {
+		return nil, fmt.Errorf("-path-from and -path-to must be set together")
+	}
+	return &dfd.FocusOptions{
+		Element: c.flagFocus,
+		Depth:   c.flagDepth,
+		Zone:    c.flagZone,
+		From:    c.flagPathFrom,
+		To:      c.flagPathTo,
+	}, nil
+}
+
 func parseProtocolStyle(s string) (spec.ProtocolStyle, error) {
 	switch s {
 	case "", "label":
@@ -166,16 +396,21 @@ func parseProtocolStyle(s string) (spec.ProtocolStyle, error) {
 }
 
 func (c *DfdCommand) fetchDfd(models []tmloader.LoadedModel, index int, format string) (string, error) {
-	adfd, tmName, err := c.extractDfd(models, index)
+	adfd, tm, err := c.extractDfd(models, index)
 	if err != nil {
 		return "", err
 	}
 
-	return generateText(adfd, tmName, format, c.renderOpts)
+	if c.rendersGraph(format) {
+		out, err := c.renderDfd(tm, adfd, format)
+		return string(out), err
+	}
+
+	return generateText(adfd, tm.Name, format, c.renderOpts)
 }
 
 // generateText dispatches to the appropriate spec generator for textual
-// formats (dot, mermaid, d2).
+// formats (dot, mermaid, d2), or renders plantuml and drawio itself.
 func generateText(adfd *spec.DataFlowDiagram, tmName, format string, opts spec.DfdRenderOptions) (string, error) {
 	switch format {
 	case "dot":
@@ -184,6 +419,10 @@ func generateText(adfd *spec.DataFlowDiagram, tmName, format string, opts spec.D
 		return adfd.GenerateMermaid(tmName, opts)
 	case "d2":
 		return adfd.GenerateD2(tmName, opts)
+	case "plantuml":
+		return dfd.PlantUML(dfd.FromSpec(adfd), tmName, opts), nil
+	case "drawio":
+		return dfd.Drawio(dfd.FromSpec(adfd), tmName, opts)
 	}
 	return "", fmt.Errorf("unsupported text format: %s", format)
 }
@@ -195,9 +434,19 @@ func (c *DfdCommand) Run(args []string) int {
 	flagSet.StringVar(&c.flagOutFile, "out", "", "Name of output file. Either this, or -outdir, must be set")
 	flagSet.BoolVar(&c.flagOverwrite, "overwrite", false, "Overwrite existing files in the outdir. Defaults to false")
 	flagSet.BoolVar(&c.flagStdout, "stdout", false, "If format is dot, you can send to stdout")
-	flagSet.StringVar(&c.flagFormat, "format", "png", "Format of output files. png, dot, svg, mermaid, or d2")
+	flagSet.StringVar(&c.flagFormat, "format", "png", "Format of output files. png, dot, svg, mermaid, d2, plantuml or drawio")
 	flagSet.IntVar(&c.flagIndex, "index", 0, "index")
 	flagSet.StringVar(&c.flagProtocolStyle, "protocol-style", "label", "Protocol rendering style for DFD flows: label, color, both, or none. Defaults to label.")
+	flagSet.StringVar(&c.flagDiffFrom, "diff-from", "", "Threat model file or git ref to render the changes since")
+	flagSet.BoolVar(&c.flagFleet, "fleet", false, "Merge every DFD into one fleet-wide map")
+	flagSet.StringVar(&c.flagCluster, "cluster", "model", "How -fleet groups elements: model or zone")
+	flagSet.StringVar(&c.flagAliases, "aliases", "", "HCL file of element aliases for -fleet")
+	flagSet.StringVar(&c.flagTheme, "theme", "", "HCL theme file styling the diagrams")
+	flagSet.StringVar(&c.flagFocus, "focus", "", "Element to render the neighbourhood of")
+	flagSet.IntVar(&c.flagDepth, "depth", 1, "How many flows away from -focus to render")
+	flagSet.StringVar(&c.flagZone, "zone", "", "Trust zone to render")
+	flagSet.StringVar(&c.flagPathFrom, "path-from", "", "Element to render the paths from")
+	flagSet.StringVar(&c.flagPathTo, "path-to", "", "Element to render the paths to")
 	parseFlags(flagSet, args)
 
 	if c.flagConfig != "" {
@@ -210,7 +459,7 @@ func (c *DfdCommand) Run(args []string) int {
 	}
 
 	if c.flagOutDir == "" && c.flagOutFile == "" && !c.flagStdout && !isTextFormat(c.flagFormat) {
-		fmt.Printf("You must set an -outdir or -out. Or set the format to a text format (dot, mermaid, d2) and enable -stdout\n\n")
+		fmt.Printf("You must set an -outdir or -out. Or set the format to a text format (dot, mermaid, d2, plantuml, drawio) and enable -stdout\n\n")
 		fmt.Println(c.Help())
 		return 1
 	}
@@ -223,13 +472,51 @@ func (c *DfdCommand) Run(args []string) int {
 
 	// Check that flagFormat is one of the supported formats
 	switch c.flagFormat {
-	case "png", "svg", "dot", "mermaid", "d2":
+	case "png", "svg", "dot", "mermaid", "d2", "plantuml", "drawio":
 	default:
-		fmt.Printf("-format must be png, dot, svg, mermaid or d2\n\n")
+		fmt.Printf("-format must be png, dot, svg, mermaid, d2, plantuml or drawio\n\n")
 		fmt.Println(c.Help())
 		return 1
 	}
 
+	if c.flagDiffFrom != "" {
+		switch c.flagFormat {
+		case "png", "svg", "mermaid", "dot":
+		default:
+			fmt.Printf("-diff-from supports the png, svg, mermaid and dot formats\n\n")
+			fmt.Println(c.Help())
+			return 1
+		}
+	}
+
+	focus, err := c.parseFocus()
+	if err != nil {
+		fmt.Printf("%s\n\n", err)
+		fmt.Println(c.Help())
+		return 1
+	}
+	c.focus = focus
+
+	if c.flagFleet {
+		switch {
+		case c.flagDiffFrom != "":
+			fmt.Printf("-fleet can't be used with -diff-from\n\n")
+			fmt.Println(c.Help())
+			return 1
+		case c.flagCluster != string(dfd.ClusterModel) && c.flagCluster != string(dfd.ClusterZone):
+			fmt.Printf("-cluster must be model or zone\n\n")
+			fmt.Println(c.Help())
+			return 1
+		}
+		switch c.flagFormat {
+		case "svg", "mermaid", "dot", "d2":
+		default:
+			fmt.Printf("-fleet supports the svg, dot, d2 and mermaid formats\n\n")
+			fmt.Println(c.Help())
+			return 1
+		}
+	}
+
 	ps, err := parseProtocolStyle(c.flagProtocolStyle)
 	if err != nil {
 		fmt.Printf("%s\n\n", err)
@@ -238,6 +525,12 @@ func (c *DfdCommand) Run(args []string) int {
 	}
 	c.renderOpts = spec.DfdRenderOptions{ProtocolStyle: ps}
 
+	c.theme, err = loadDfdTheme(c.flagTheme, c.flagConfig)
+	if err != nil {
+		fmt.Printf("Error reading -theme: %s\n", err)
+		return 1
+	}
+
 	if len(flagSet.Args()) == 0 {
 		fmt.Printf("Please provide file(s)\n\n")
 		fmt.Println(c.Help())
@@ -266,7 +559,7 @@ func (c *DfdCommand) Run(args []string) int {
 			// we do need to check if we're outputing to a directory or not.
 			// If a directory, we can output multiple DFDs, otherwise, we need to prompt them for another parameter - i.e.
 			// which dfd to draw
-			fileExt := fmt.Sprintf(".%s", c.flagFormat)
+			fileExt := dfdExt(c.flagFormat)
 			for _, adfd := range tm.DataFlowDiagrams {
 				outfile := outfilePath(c.flagOutDir, fmt.Sprintf("%s_%s", tm.Name, adfd.Name), lm.File, fileExt)
 
@@ -280,6 +573,14 @@ func (c *DfdCommand) Run(args []string) int {
 		return 1
 	}
 
+	if c.flagDiffFrom != "" {
+		return c.writeDiff(models, outfiles)
+	}
+
+	if c.flagFleet {
+		return c.writeFleet(models)
+	}
+
 	// Now we have a set of DFDs and some options to parse, namely
 	// Is this a stdout + dot file?
 	// - Then we can only handle 1 file
@@ -379,10 +680,23 @@ func (c *DfdCommand) Run(args []string) int {
 			{
 				for _, adfd := range tm.DataFlowDiagrams {
 
-					currentOutpath := outfilePath(c.flagOutDir, fmt.Sprintf("%s_%s", tm.Name, adfd.Name), lm.File, fmt.Sprintf(".%s", c.flagFormat))
+					currentOutpath := outfilePath(c.flagOutDir, fmt.Sprintf("%s_%s", tm.Name, adfd.Name), lm.File, dfdExt(c.flagFormat))
 
 					// Now we switch on the output format
 					switch {
+					case c.rendersGraph(c.flagFormat) && !(c.flagFormat == "svg" && decomposesProcess(tm, adfd)):
+						err := c.writeGraphDfd(tm, adfd, currentOutpath)
+						if errors.Is(err, dfd.ErrNotFocused) {
+							fmt.Printf("Skipped '%s': %s\n", currentOutpath, err)
+							continue
+						}
+						if err != nil {
+							fmt.Printf("Error writing %s file to %s: %s\n", strings.ToUpper(c.flagFormat), currentOutpath, err)
+							return 1
+						}
+
+						fmt.Printf("Successfully created '%s'\n", currentOutpath)
+
 					case isTextFormat(c.flagFormat):
 						text, err := generateText(adfd, tm.Name, c.flagFormat, c.renderOpts)
 						if err != nil {
@@ -407,6 +721,35 @@ func (c *DfdCommand) Run(args []string) int {
 
 						fmt.Printf("Successfully created '%s'\n", currentOutpath)
 
+					case c.flagFormat == "svg" && decomposesProcess(tm, adfd):
+
+						link := func(section, name string) string {
+							if section != dfd.DiagramSection {
+								return ""
+							}
+							return filepath.Base(outfilePath(c.flagOutDir, fmt.Sprintf("%s_%s", tm.Name, name), lm.File, ".svg"))
+						}
+						g, err := c.dfdGraph(adfd)
+						if errors.Is(err, dfd.ErrNotFocused) {
+							fmt.Printf("Skipped '%s': %s\n", currentOutpath, err)
+							continue
+						}
+						var svg []byte
+						if err == nil {
+							opts := c.dfdOptions(tm, g)
+							opts.Details = dfd.DetailsFromSpec(g, tm, link)
+							svg, err = dfd.Svg(dfd.Dot(g, tm.Name, opts))
+						}
+						if err == nil {
+							err = os.WriteFile(currentOutpath, svg, 0600)
+						}
+						if err != nil {
+							fmt.Printf("Error writing SVG file to %s: %s\n", currentOutpath, err)
+							return 1
+						}
+
+						fmt.Printf("Successfully created '%s'\n", currentOutpath)
+
 					case c.flagFormat == "svg":
 
 						err := adfd.GenerateDfdSvg(currentOutpath, tm.Name, c.renderOpts)
@@ -442,7 +785,17 @@ func (c *DfdCommand) AutocompleteFlags() complete.Flags {
 	return complete.Flags{
 		"-config":         predictHCL,
 		"-outdir":         complete.PredictDirs("*"),
-		"-format":         complete.PredictSet("png", "dot", "svg", "mermaid", "d2"),
+		"-format":         complete.PredictSet("png", "dot", "svg", "mermaid", "d2", "plantuml", "drawio"),
 		"-protocol-style": complete.PredictSet("label", "color", "both", "none"),
+		"-diff-from":      predictHCLOrJSON,
+		"-fleet":          complete.PredictNothing,
+		"-cluster":        complete.PredictSet("model", "zone"),
+		"-aliases":        predictHCL,
+		"-theme":          predictHCL,
+		"-focus":          complete.PredictAnything,
+		"-depth":          complete.PredictAnything,
+		"-zone":           complete.PredictAnything,
+		"-path-from":      complete.PredictAnything,
+		"-path-to":        complete.PredictAnything,
 	}
 }
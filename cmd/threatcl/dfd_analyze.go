@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/posener/complete"
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/dfd"
+	"github.com/threatcl/threatcl/internal/tmloader"
+)
+
+type DfdAnalyzeCommand struct {
+	*GlobalCmdOptions
+	specCfg         *spec.ThreatmodelSpecConfig
+	flagFormat      string
+	flagCompromised string
+	flagOut         string
+	flagOutDir      string
+	flagOverwrite   bool
+	flagIndex       int
+}
+
+func (c *DfdAnalyzeCommand) Help() string {
+	helpText := `
+Usage: threatcl dfd analyze [options] <files>
+
+  Analyse the Data Flow Diagrams in existing Threat model HCL files (as
+  specified by <files>) for attack paths and blast radius
+
+  Every path along flows from each external_element to each data_store is
+  listed, with the trust-zone boundaries it crosses. With -compromised, every
+  element, data store and information asset reachable downstream of that
+  element is reported too, and only diagrams containing it are analysed.
+
+Options:
+
+ -config=<file>
+   Optional config file
+
+ -format=<text|json|svg>
+   Output format. Defaults to text. svg draws each diagram with the attack
+   paths, or the blast radius of -compromised, highlighted
+
+ -compromised=<name>
+   Name of an element to treat as compromised
+
+ -out=<filename>
+   Name of output file. If not set, text and json are written to STDOUT.
+   For svg, only one diagram can be written; use -index to choose it
+
+ -outdir=<directory>
+   Directory to write an svg per diagram to. Will create directory if it
+   doesn't exist
+
+ -index=<n>
+   Only analyse the nth DFD
+
+ -overwrite
+   Overwrite existing files
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// dfdAnalysis is one diagram's analysis, with where it came from.
+type dfdAnalysis struct {
+	Threatmodel string `json:"threatmodel"`
+	File        string `json:"file"`
+	*dfd.Analysis
+
+	graph *dfd.Graph
+}
+
+func (c *DfdAnalyzeCommand) Run(args []string) int {
+	flagSet := c.GetFlagset("dfd analyze")
+	flagSet.StringVar(&c.flagFormat, "format", "text", "Output format. text, json or svg")
+	flagSet.StringVar(&c.flagCompromised, "compromised", "", "Name of an element to treat as compromised")
+	flagSet.StringVar(&c.flagOut, "out", "", "Name of output file")
+	flagSet.StringVar(&c.flagOutDir, "outdir", "", "Directory to write svg files to")
+	flagSet.BoolVar(&c.flagOverwrite, "overwrite", false, "Overwrite existing files. Defaults to false")
+	flagSet.IntVar(&c.flagIndex, "index", 0, "Only analyse the nth DFD")
+	parseFlags(flagSet, args)
+
+	if c.flagConfig != "" {
+		err := c.specCfg.LoadSpecConfigFile(c.flagConfig)
+
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			return 1
+		}
+	}
+
+	switch c.flagFormat {
+	case "text", "json", "svg":
+	default:
+		fmt.Printf("-format must be text, json or svg\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	if c.flagOut != "" && c.flagOutDir != "" {
+		fmt.Printf("You must set an -outdir or -out, but not both\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	if c.flagOutDir != "" && c.flagFormat != "svg" {
+		fmt.Printf("-outdir is only supported with -format=svg\n")
+		return 1
+	}
+
+	if c.flagFormat == "svg" && c.flagOut == "" && c.flagOutDir == "" {
+		fmt.Printf("You must set an -outdir or -out for svg output\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	if len(flagSet.Args()) == 0 {
+		fmt.Printf("Please provide file(s)\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	res, err := tmloader.LoadSet(c.specCfg, flagSet.Args())
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+
+	analyses, err := c.analyze(res.Models)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+
+	if c.flagFormat == "svg" {
+		return c.writeSvgs(analyses)
+	}
+
+	var out string
+	switch c.flagFormat {
+	case "json":
+		j, err := json.MarshalIndent(analyses, "", "  ")
+		if err != nil {
+			fmt.Printf("Error marshalling JSON: %s\n", err)
+			return 1
+		}
+		out = string(j) + "\n"
+	default:
+		parts := []string{}
+		for _, a := range analyses {
+			parts = append(parts, fmt.Sprintf("%s: %s\n\n%s", a.Threatmodel, a.Diagram, a.Analysis))
+		}
+		out = strings.Join(parts, "\n")
+	}
+
+	if c.flagOut == "" {
+		fmt.Print(out)
+		return 0
+	}
+
+	if err := fileExistenceCheck([]string{c.flagOut}, c.flagOverwrite); err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+	if err := writeStringToFile(c.flagOut, out); err != nil {
+		fmt.Printf("Error writing output to %s: %s\n", c.flagOut, err)
+		return 1
+	}
+	fmt.Printf("Successfully wrote %d analysis(es) to '%s'\n", len(analyses), c.flagOut)
+	return 0
+}
+
+// analyze analyses every DFD, or the one chosen with -index. With
+// -compromised, diagrams without that element are left out.
+func (c *DfdAnalyzeCommand) analyze(models []tmloader.LoadedModel) ([]dfdAnalysis, error) {
+	analyses := []dfdAnalysis{}
+	index := 0
+	for _, lm := range models {
+		tm := lm.TM
+
+		classifications := map[string]string{}
+		for _, ia := range tm.InformationAssets {
+			classifications[ia.Name] = ia.InformationClassification
+		}
+
+		for _, adfd := range tm.DataFlowDiagrams {
+			index++
+			if c.flagIndex != 0 && c.flagIndex != index {
+				continue
+			}
+
+			g := dfd.FromSpec(adfd)
+			if _, ok := g.Element(c.flagCompromised); c.flagCompromised != "" && !ok {
+				continue
+			}
+
+			a, err := dfd.Analyze(g, dfd.AnalyzeOptions{
+				Compromised:     c.flagCompromised,
+				Classifications: classifications,
+			})
+			if err != nil {
+				return nil, err
+			}
+			analyses = append(analyses, dfdAnalysis{Threatmodel: tm.Name, File: lm.File, Analysis: a, graph: g})
+		}
+	}
+
+	switch {
+	case index == 0:
+		return nil, fmt.Errorf("No DFDs found")
+	case c.flagIndex > index || c.flagIndex < 0:
+		return nil, fmt.Errorf("Index provided is inaccurate")
+	case len(analyses) == 0:
+		return nil, fmt.Errorf("No DFD has an element called %q", c.flagCompromised)
+	}
+	return analyses, nil
+}
+
+// writeSvgs draws each analysis with its paths highlighted, to -out if
+// there's just one, otherwise into -outdir.
+func (c *DfdAnalyzeCommand) writeSvgs(analyses []dfdAnalysis) int {
+	outfiles := []string{}
+	for _, a := range analyses {
+		if c.flagOut != "" {
+			outfiles = append(outfiles, c.flagOut)
+			continue
+		}
+		outfiles = append(outfiles, outfilePath(c.flagOutDir, fmt.Sprintf("%s_%s_analysis", a.Threatmodel, a.Diagram), a.File, ".svg"))
+	}
+
+	if c.flagOut != "" && len(analyses) != 1 {
+		fmt.Printf("You're trying to save a single file, but there's too many DFDs\n\n")
+		fmt.Printf("Run the command again and provide an -index=n flag, or use -outdir\n")
+		return 1
+	}
+
+	if err := fileExistenceCheck(outfiles, c.flagOverwrite); err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+	if c.flagOutDir != "" {
+		if err := createOrValidateFolder(c.flagOutDir, c.flagOverwrite); err != nil {
+			fmt.Printf("%s\n", err)
+			return 1
+		}
+	}
+
+	for i, a := range analyses {
+		h := a.Highlight()
+		svg, err := dfd.Svg(dfd.Dot(a.graph, a.Threatmodel, dfd.RenderOptions{Highlight: &h}))
+		if err != nil {
+			fmt.Printf("Error creating file: %s: %s\n", outfiles[i], err)
+			return 1
+		}
+		if err := writeStringToFile(outfiles[i], string(svg)); err != nil {
+			fmt.Printf("Error writing SVG file to %s: %s\n", outfiles[i], err)
+			return 1
+		}
+		fmt.Printf("Successfully created '%s'\n", outfiles[i])
+	}
+	return 0
+}
+
+func (c *DfdAnalyzeCommand) Synopsis() string {
+	return "Analyse Data Flow Diagrams for attack paths and blast radius"
+}
+
+func (c *DfdAnalyzeCommand) AutocompleteArgs() complete.Predictor { return predictHCLOrJSON }
+func (c *DfdAnalyzeCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-config": predictHCL,
+		"-outdir": complete.PredictDirs("*"),
+		"-format": complete.PredictSet("text", "json", "svg"),
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+
+	"github.com/zenizh/go-capturer"
+)
+
+const analyzeTm = `spec_version = "0.1.0"
+
+threatmodel "Shop" {
+  author = "@alice"
+
+  information_asset "Card data" {
+    information_classification = "Restricted"
+  }
+
+  data_flow_diagram_v2 "Level 0" {
+    external_element "User" {}
+
+    trust_zone "Internal" {
+      process "Web" {}
+
+      data_store "Cards" {
+        information_asset = "Card data"
+      }
+    }
+
+    flow "Browse" {
+      from = "User"
+      to   = "Web"
+    }
+
+    flow "Store" {
+      from = "Web"
+      to   = "Cards"
+    }
+  }
+}
+`
+
+func testDfdAnalyzeCommand(tb testing.TB) *DfdAnalyzeCommand {
+	tb.Helper()
+
+	d, err := os.MkdirTemp("", "")
+	if err != nil {
+		tb.Fatalf("Error creating tmp dir: %s", err)
+	}
+
+	_ = os.Setenv("HOME", d)
+	_ = os.Setenv("USERPROFILE", d)
+
+	cfg, _ := spec.LoadSpecConfig()
+
+	defer os.RemoveAll(d)
+
+	global := &GlobalCmdOptions{}
+
+	return &DfdAnalyzeCommand{
+		GlobalCmdOptions: global,
+		specCfg:          cfg,
+	}
+}
+
+func writeAnalyzeTm(tb testing.TB) string {
+	tb.Helper()
+
+	tmFile := filepath.Join(tb.TempDir(), "shop.hcl")
+	if err := os.WriteFile(tmFile, []byte(analyzeTm), 0600); err != nil {
+		tb.Fatalf("Error writing threat model: %s", err)
+	}
+	return tmFile
+}
+
+func TestDfdAnalyzeText(t *testing.T) {
+	tmFile := writeAnalyzeTm(t)
+	cmd := testDfdAnalyzeCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-compromised=Web", tmFile})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	for _, exp := range []string{
+		"Shop: Level 0",
+		"User -> Web -> Cards",
+		"crosses Browse (outside -> Internal)",
+		"If Web is compromised:",
+		"Information assets: Card data [Restricted] in Cards",
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Expected %s to contain %s", out, exp)
+		}
+	}
+}
+
+func TestDfdAnalyzeJson(t *testing.T) {
+	tmFile := writeAnalyzeTm(t)
+	cmd := testDfdAnalyzeCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-format=json", tmFile})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	analyses := []struct {
+		Threatmodel string
+		Diagram     string
+		Paths       []struct {
+			Elements []string
+		}
+	}{}
+	if err := json.Unmarshal([]byte(out), &analyses); err != nil {
+		t.Fatalf("Error parsing JSON: %s\n%s", err, out)
+	}
+	if len(analyses) != 1 || analyses[0].Threatmodel != "Shop" || len(analyses[0].Paths) != 1 {
+		t.Errorf("Unexpected analyses: %+v", analyses)
+	}
+}
+
+func TestDfdAnalyzeSvg(t *testing.T) {
+	tmFile := writeAnalyzeTm(t)
+	d := t.TempDir()
+	cmd := testDfdAnalyzeCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-format=svg", fmt.Sprintf("-outdir=%s", filepath.Join(d, "out")), tmFile})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	svg, err := os.ReadFile(filepath.Join(d, "out", "shop-shoplevel0analysis.svg"))
+	if err != nil {
+		t.Fatalf("Error opening svg: %s", err)
+	}
+	if !strings.Contains(string(svg), "<svg") || !strings.Contains(string(svg), "#d62728") {
+		t.Errorf("Expected a highlighted svg, got %.200s", svg)
+	}
+}
+
+func TestDfdAnalyzeUnknownElement(t *testing.T) {
+	tmFile := writeAnalyzeTm(t)
+	cmd := testDfdAnalyzeCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-compromised=Nope", tmFile})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+
+	if !strings.Contains(out, `No DFD has an element called "Nope"`) {
+		t.Errorf("Expected %s to contain %s", out, `No DFD has an element called "Nope"`)
+	}
+}
+
+func TestDfdAnalyzeInvalidFormat(t *testing.T) {
+	cmd := testDfdAnalyzeCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-format=png", "shop.hcl"})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+
+	if !strings.Contains(out, "-format must be text, json or svg") {
+		t.Errorf("Expected %s to contain %s", out, "-format must be text, json or svg")
+	}
+}
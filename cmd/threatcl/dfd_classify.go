@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/posener/complete"
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/dfd"
+	"github.com/threatcl/threatcl/internal/tmloader"
+)
+
+type DfdClassifyCommand struct {
+	*GlobalCmdOptions
+	specCfg       *spec.ThreatmodelSpecConfig
+	flagFormat    string
+	flagOut       string
+	flagOverwrite bool
+}
+
+func (c *DfdClassifyCommand) Help() string {
+	helpText := `
+Usage: threatcl dfd classify [options] <files>
+
+  Propagate information classifications along the flows of the Data Flow
+  Diagrams in existing Threat model HCL files (as specified by <files>)
+
+  Every flow to or from a data_store carries the store's information_asset,
+  which spreads upstream and downstream through processes. Flows carrying
+  Restricted or Confidential data across a trust-zone boundary over a
+  plaintext protocol (http, ftp, telnet or unset) are reported as insecure.
+  Information assets held by a data_store are reported as unprotected if no
+  threat referring to them has an implemented control.
+
+  The same results are available to invariants as insecure_flows(tm) and
+  unprotected_assets(tm), and in 'threatcl dashboard'.
+
+Options:
+
+ -config=<file>
+   Optional config file
+
+ -format=<text|json>
+   Output format. Defaults to text
+
+ -out=<filename>
+   Name of output file. If not set, writes to STDOUT
+
+ -overwrite
+   Overwrite existing files
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// dfdClassification is one threat model's data classification report.
+type dfdClassification struct {
+	Threatmodel string `json:"threatmodel"`
+	File        string `json:"file"`
+	*dfd.DataReport
+}
+
+func (c *DfdClassifyCommand) Run(args []string) int {
+	flagSet := c.GetFlagset("dfd classify")
+	flagSet.StringVar(&c.flagFormat, "format", "text", "Output format. text or json")
+	flagSet.StringVar(&c.flagOut, "out", "", "Name of output file")
+	flagSet.BoolVar(&c.flagOverwrite, "overwrite", false, "Overwrite existing files. Defaults to false")
+	parseFlags(flagSet, args)
+
+	if c.flagConfig != "" {
+		err := c.specCfg.LoadSpecConfigFile(c.flagConfig)
+
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			return 1
+		}
+	}
+
+	if c.flagFormat != "text" && c.flagFormat != "json" {
+		fmt.Printf("-format must be text or json\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	if len(flagSet.Args()) == 0 {
+		fmt.Printf("Please provide file(s)\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	res, err := tmloader.LoadSet(c.specCfg, flagSet.Args())
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+
+	reports := []dfdClassification{}
+	for _, lm := range res.Models {
+		if len(lm.TM.DataFlowDiagrams) == 0 {
+			continue
+		}
+		reports = append(reports, dfdClassification{
+			Threatmodel: lm.TM.Name,
+			File:        lm.File,
+			DataReport:  classifyThreatmodel(lm.TM),
+		})
+	}
+	if len(reports) == 0 {
+		fmt.Printf("No DFDs found\n")
+		return 1
+	}
+
+	var out string
+	switch c.flagFormat {
+	case "json":
+		j, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			fmt.Printf("Error marshalling JSON: %s\n", err)
+			return 1
+		}
+		out = string(j) + "\n"
+	default:
+		parts := []string{}
+		for _, r := range reports {
+			parts = append(parts, fmt.Sprintf("%s\n\n%s", r.Threatmodel, r.DataReport))
+		}
+		out = strings.Join(parts, "\n")
+	}
+
+	if c.flagOut == "" {
+		fmt.Print(out)
+		return 0
+	}
+
+	if err := fileExistenceCheck([]string{c.flagOut}, c.flagOverwrite); err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+	if err := writeStringToFile(c.flagOut, out); err != nil {
+		fmt.Printf("Error writing output to %s: %s\n", c.flagOut, err)
+		return 1
+	}
+	fmt.Printf("Successfully wrote %d report(s) to '%s'\n", len(reports), c.flagOut)
+	return 0
+}
+
+// classifyThreatmodel classifies the flows of every DFD in tm.
+func classifyThreatmodel(tm *spec.Threatmodel) *dfd.DataReport {
+	graphs := []*dfd.Graph{}
+	for _, adfd := range tm.DataFlowDiagrams {
+		graphs = append(graphs, dfd.FromSpec(adfd))
+	}
+	return dfd.Classify(graphs, dfd.AssetsFromSpec(tm))
+}
+
+func (c *DfdClassifyCommand) Synopsis() string {
+	return "Propagate information classifications along Data Flow Diagram flows"
+}
+
+func (c *DfdClassifyCommand) AutocompleteArgs() complete.Predictor { return predictHCLOrJSON }
+func (c *DfdClassifyCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-config": predictHCL,
+		"-format": complete.PredictSet("text", "json"),
+	}
+}
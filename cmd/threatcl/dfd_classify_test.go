@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+
+	"github.com/zenizh/go-capturer"
+)
+
+func testDfdClassifyCommand(tb testing.TB) *DfdClassifyCommand {
+	tb.Helper()
+
+	d, err := os.MkdirTemp("", "")
+	if err != nil {
+		tb.Fatalf("Error creating tmp dir: %s", err)
+	}
+
+	_ = os.Setenv("HOME", d)
+	_ = os.Setenv("USERPROFILE", d)
+
+	cfg, _ := spec.LoadSpecConfig()
+
+	defer os.RemoveAll(d)
+
+	global := &GlobalCmdOptions{}
+
+	return &DfdClassifyCommand{
+		GlobalCmdOptions: global,
+		specCfg:          cfg,
+	}
+}
+
+func TestDfdClassifyText(t *testing.T) {
+	tmFile := writeAnalyzeTm(t)
+	cmd := testDfdClassifyCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{tmFile})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	for _, exp := range []string{
+		"Classified flows (2):",
+		"Insecure flows (1):",
+		"Level 0: Browse (outside -> Internal) carries Card data [Restricted] over an unset protocol",
+		"Card data [Restricted] in Cards: no threat refers to it",
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Expected %s to contain %s", out, exp)
+		}
+	}
+}
+
+func TestDfdClassifyJson(t *testing.T) {
+	tmFile := writeAnalyzeTm(t)
+	cmd := testDfdClassifyCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-format=json", tmFile})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	reports := []struct {
+		Threatmodel   string
+		InsecureFlows []struct {
+			Flow string
+		} `json:"insecure_flows"`
+	}{}
+	if err := json.Unmarshal([]byte(out), &reports); err != nil {
+		t.Fatalf("Error parsing JSON: %s\n%s", err, out)
+	}
+	if len(reports) != 1 || reports[0].Threatmodel != "Shop" || len(reports[0].InsecureFlows) != 1 || reports[0].InsecureFlows[0].Flow != "Browse" {
+		t.Errorf("Unexpected reports: %+v", reports)
+	}
+}
+
+func TestDfdClassifyInvalidFormat(t *testing.T) {
+	cmd := testDfdClassifyCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-format=svg", "shop.hcl"})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+
+	if !strings.Contains(out, "-format must be text or json") {
+		t.Errorf("Expected %s to contain %s", out, "-format must be text or json")
+	}
+}
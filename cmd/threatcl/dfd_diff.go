@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/dfd"
+	"github.com/threatcl/threatcl/internal/tmloader"
+)
+
+// writeDiff renders a single DFD with the changes since -diff-from. outfiles
+// are the DFDs found, to choose one with -index.
+func (c *DfdCommand) writeDiff(models []tmloader.LoadedModel, outfiles []string) int {
+	index := c.flagIndex
+	switch {
+	case len(outfiles) != 1 && index == 0:
+		fmt.Printf("You're trying to diff a single DFD, but there's too many DFDs\n\n")
+		fmt.Printf("Run the command again and provide an -index=n flag\n\n")
+		for idx, outfile := range outfiles {
+			fmt.Printf("%d: %s\n", idx+1, outfile)
+		}
+		return 1
+	case len(outfiles) != 1 && (index > len(outfiles) || index < 1):
+		fmt.Printf("Index provided is inaccurate\n")
+		return 1
+	case len(outfiles) == 1:
+		index = 1
+	}
+
+	adfd, tm, err := c.extractDfd(models, index)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+	tmName := tm.Name
+
+	oldModels, err := loadDiffFrom(c.specCfg, c.flagDiffFrom, models)
+	if err != nil {
+		fmt.Printf("Error reading -diff-from: %s\n", err)
+		return 1
+	}
+
+	old := &dfd.Graph{Name: adfd.Name}
+	for _, lm := range oldModels {
+		if lm.TM.Name != tmName {
+			continue
+		}
+		for _, od := range lm.TM.DataFlowDiagrams {
+			if od.Name == adfd.Name {
+				old = dfd.FromSpec(od)
+			}
+		}
+	}
+
+	d := dfd.Diff(old, dfd.FromSpec(adfd))
+	opts := dfd.RenderOptions{ProtocolStyle: c.renderOpts.ProtocolStyle, Theme: c.theme, Diff: d}
+
+	var out []byte
+	switch c.flagFormat {
+	case "mermaid":
+		out = []byte(dfd.Mermaid(d.Graph, tmName, opts))
+	case "dot":
+		out = []byte(dfd.Dot(d.Graph, tmName, opts))
+	case "svg":
+		out, err = dfd.Svg(dfd.Dot(d.Graph, tmName, opts))
+	case "png":
+		out, err = dfd.Png(dfd.Dot(d.Graph, tmName, opts))
+	}
+	if err != nil {
+		fmt.Printf("Error rendering DFD: %s\n", err)
+		return 1
+	}
+
+	if c.flagOutFile == "" && c.flagOutDir == "" {
+		if !isTextFormat(c.flagFormat) {
+			fmt.Printf("You must set an -outdir or -out for %s output\n", c.flagFormat)
+			return 1
+		}
+		fmt.Printf("%s\n", out)
+		return 0
+	}
+
+	outfile := c.flagOutFile
+	if c.flagOutDir != "" {
+		outfile = strings.TrimSuffix(outfiles[index-1], dfdExt(c.flagFormat)) + "-diff" + dfdExt(c.flagFormat)
+		if err := createOrValidateFolder(c.flagOutDir, c.flagOverwrite); err != nil {
+			fmt.Printf("%s\n", err)
+			return 1
+		}
+	}
+	if err := fileExistenceCheck([]string{outfile}, c.flagOverwrite); err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+	if err := os.WriteFile(outfile, out, 0600); err != nil {
+		fmt.Printf("Error writing %s file to %s: %s\n", strings.ToUpper(c.flagFormat), outfile, err)
+		return 1
+	}
+
+	fmt.Printf("Successfully created '%s'\n\n%s", outfile, d)
+	return 0
+}
+
+// loadDiffFrom loads the threat models to diff against. from is either a
+// threat model file, or a git ref to read the files of models from.
+func loadDiffFrom(cfg *spec.ThreatmodelSpecConfig, from string, models []tmloader.LoadedModel) ([]tmloader.LoadedModel, error) {
+	if info, err := os.Stat(from); err == nil && !info.IsDir() {
+		res, err := tmloader.LoadSet(cfg, []string{from})
+		if err != nil {
+			return nil, err
+		}
+		return res.Models, nil
+	}
+
+	files := []string{}
+	seen := map[string]bool{}
+	for _, lm := range models {
+		if !seen[lm.File] {
+			seen[lm.File] = true
+			files = append(files, lm.File)
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "threatcl-diff-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	// Each directory's files are copied into a directory of their own, so
+	// cross-file references between them still resolve
+	dirs := map[string]string{}
+	oldFiles := []string{}
+	for _, file := range files {
+		content, found, err := gitShow(from, file)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		src := filepath.Dir(file)
+		if _, ok := dirs[src]; !ok {
+			dirs[src] = filepath.Join(dir, fmt.Sprintf("%d", len(dirs)+1))
+		}
+		oldFile := filepath.Join(dirs[src], filepath.Base(file))
+		if err := os.MkdirAll(filepath.Dir(oldFile), 0700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(oldFile, content, 0600); err != nil {
+			return nil, err
+		}
+		oldFiles = append(oldFiles, oldFile)
+	}
+	if len(oldFiles) == 0 {
+		return nil, nil
+	}
+
+	res, err := tmloader.LoadSet(cfg, oldFiles)
+	if err != nil {
+		return nil, err
+	}
+	return res.Models, nil
+}
+
+// gitShow returns file's content at ref, and false if the file didn't
+// exist there. It's an error if ref isn't a commit in file's repository.
+func gitShow(ref, file string) ([]byte, bool, error) {
+	// git would take it as an option
+	if strings.HasPrefix(ref, "-") {
+		return nil, false, fmt.Errorf("%q is neither a file nor a git ref", ref)
+	}
+
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, false, err
+	}
+	dir := filepath.Dir(abs)
+	name := filepath.Base(abs)
+
+	if err := exec.Command("git", "-C", dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}").Run(); err != nil {
+		return nil, false, fmt.Errorf("%q is neither a file nor a git ref", ref)
+	}
+
+	listed, err := gitOutput(dir, "ls-tree", "--name-only", ref, "--", name)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(bytes.TrimSpace(listed)) == 0 {
+		return nil, false, nil
+	}
+
+	out, err := gitOutput(dir, "show", fmt.Sprintf("%s:./%s", ref, name))
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// gitOutput runs git in dir, and returns its output or an error with what
+// git printed.
+func gitOutput(dir string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %s", args[0], err)
+	}
+	return out, nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zenizh/go-capturer"
+)
+
+// diffTm is analyzeTm after a change: Web moves into the DMZ, and a cache
+// is added.
+const diffTm = `spec_version = "0.1.0"
+
+threatmodel "Shop" {
+  author = "@alice"
+
+  information_asset "Card data" {
+    information_classification = "Restricted"
+  }
+
+  data_flow_diagram_v2 "Level 0" {
+    external_element "User" {}
+
+    trust_zone "DMZ" {
+      process "Web" {}
+    }
+
+    trust_zone "Internal" {
+      data_store "Cards" {
+        information_asset = "Card data"
+      }
+
+      data_store "Cache" {}
+    }
+
+    flow "Browse" {
+      from = "User"
+      to   = "Web"
+    }
+
+    flow "Store" {
+      from = "Web"
+      to   = "Cards"
+    }
+
+    flow "Cache" {
+      from = "Web"
+      to   = "Cache"
+    }
+  }
+}
+`
+
+func TestDfdDiffFromFile(t *testing.T) {
+	oldFile := writeAnalyzeTm(t)
+	newFile := filepath.Join(t.TempDir(), "shop.hcl")
+	if err := os.WriteFile(newFile, []byte(diffTm), 0600); err != nil {
+		t.Fatalf("Error writing threat model: %s", err)
+	}
+	cmd := testDfdCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-format=mermaid", "-stdout", fmt.Sprintf("-diff-from=%s", oldFile), newFile})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	for _, exp := range []string{
+		"flowchart LR",
+		`(("Web<br/>(moved from Internal)"))`,
+		"stroke:#2ca02c",
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Expected %s to contain %s", out, exp)
+		}
+	}
+}
+
+func TestDfdDiffFromGitRef(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't available")
+	}
+
+	d := t.TempDir()
+	tmFile := filepath.Join(d, "shop.hcl")
+	git := func(args ...string) {
+		t.Helper()
+		c := exec.Command("git", append([]string{"-C", d, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := c.CombinedOutput(); err != nil {
+			t.Fatalf("Error running git %v: %s\n%s", args, err, out)
+		}
+	}
+
+	git("init", "-q")
+	if err := os.WriteFile(tmFile, []byte(analyzeTm), 0600); err != nil {
+		t.Fatalf("Error writing threat model: %s", err)
+	}
+	git("add", "shop.hcl")
+	git("commit", "-q", "-m", "Add shop")
+	if err := os.WriteFile(tmFile, []byte(diffTm), 0600); err != nil {
+		t.Fatalf("Error writing threat model: %s", err)
+	}
+
+	cmd := testDfdCommand(t)
+
+	var code int
+	outDir := filepath.Join(d, "out")
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-format=svg", "-diff-from=HEAD", fmt.Sprintf("-outdir=%s", outDir), tmFile})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	for _, exp := range []string{
+		"Added elements: Cache",
+		"Added flows: Cache (Web -> Cache)",
+		"Moved elements: Web (Internal -> DMZ)",
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Expected %s to contain %s", out, exp)
+		}
+	}
+
+	svg, err := os.ReadFile(filepath.Join(outDir, "shop-shoplevel0-diff.svg"))
+	if err != nil {
+		t.Fatalf("Error opening svg: %s", err)
+	}
+	if !strings.Contains(string(svg), "<svg") || !strings.Contains(string(svg), "#2ca02c") {
+		t.Errorf("Expected a diff svg, got %.200s", svg)
+	}
+}
+
+func TestDfdDiffInvalidFormat(t *testing.T) {
+	cmd := testDfdCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-format=d2", "-stdout", "-diff-from=HEAD", "shop.hcl"})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+
+	if !strings.Contains(out, "-diff-from supports the png, svg, mermaid and dot formats") {
+		t.Errorf("Expected %s to contain %s", out, "-diff-from supports the png, svg, mermaid and dot formats")
+	}
+}
+
+func TestGitShowInvalidRef(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't available")
+	}
+
+	_, _, err := gitShow("no-such-ref", filepath.Join(t.TempDir(), "shop.hcl"))
+	if err == nil || !strings.Contains(err.Error(), `"no-such-ref" is neither a file nor a git ref`) {
+		t.Errorf("Expected an invalid ref error, got %v", err)
+	}
+}
+
+func TestGitShow(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't available")
+	}
+
+	d := t.TempDir()
+	tmFile := filepath.Join(d, "shop.hcl")
+	git := func(args ...string) {
+		t.Helper()
+		c := exec.Command("git", append([]string{"-C", d, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if out, err := c.CombinedOutput(); err != nil {
+			t.Fatalf("Error running git %v: %s\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	if err := os.WriteFile(tmFile, []byte("old"), 0600); err != nil {
+		t.Fatalf("Error writing threat model: %s", err)
+	}
+	git("add", "shop.hcl")
+	git("commit", "-q", "-m", "shop")
+
+	content, found, err := gitShow("HEAD", tmFile)
+	if err != nil || !found || string(content) != "old" {
+		t.Errorf("Expected the committed file, got %q %t %v", content, found, err)
+	}
+
+	_, found, err = gitShow("HEAD", filepath.Join(d, "other.hcl"))
+	if err != nil || found {
+		t.Errorf("Expected a file that isn't in the tree to be absent, got %t %v", found, err)
+	}
+
+	out := filepath.Join(d, "out")
+	_, _, err = gitShow("--output="+out, tmFile)
+	if err == nil || !strings.Contains(err.Error(), "is neither a file nor a git ref") {
+		t.Errorf("Expected an option-like ref to be rejected, got %v", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("Expected git not to write %s", out)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/threatcl/threatcl/internal/dfd"
+	"github.com/threatcl/threatcl/internal/tmloader"
+)
+
+// writeFleet merges every model's DFDs into one map and renders it.
+func (c *DfdCommand) writeFleet(models []tmloader.LoadedModel) int {
+	aliases := map[string]string{}
+	if c.flagAliases != "" {
+		var err error
+		aliases, err = dfd.ParseAliasFile(c.flagAliases)
+		if err != nil {
+			fmt.Printf("Error reading -aliases: %s\n", err)
+			return 1
+		}
+	}
+
+	fleet := []dfd.FleetModel{}
+	for _, lm := range models {
+		fm := dfd.FleetModel{Name: lm.TM.Name}
+		for _, adfd := range lm.TM.DataFlowDiagrams {
+			fm.Graphs = append(fm.Graphs, dfd.FromSpec(adfd))
+		}
+		if len(fm.Graphs) > 0 {
+			fleet = append(fleet, fm)
+		}
+	}
+
+	fm := dfd.Fleet(fleet, dfd.FleetOptions{Aliases: aliases, Cluster: dfd.Cluster(c.flagCluster)})
+	if c.focus != nil {
+		g, err := dfd.Focus(fm.Graph, *c.focus)
+		if err != nil {
+			fmt.Printf("Error focusing the fleet map: %s\n", err)
+			return 1
+		}
+		fm.Graph = g
+	}
+	opts := dfd.RenderOptions{ProtocolStyle: c.renderOpts.ProtocolStyle, Theme: c.theme}
+
+	var out []byte
+	var err error
+	switch c.flagFormat {
+	case "mermaid":
+		out = []byte(dfd.Mermaid(fm.Graph, "Fleet", opts))
+	case "d2":
+		out = []byte(dfd.D2(fm.Graph, "Fleet", opts))
+	case "dot":
+		out = []byte(dfd.Dot(fm.Graph, "Fleet", opts))
+	case "svg":
+		out, err = dfd.Svg(dfd.Dot(fm.Graph, "Fleet", opts))
+	}
+	if err != nil {
+		fmt.Printf("Error rendering DFD: %s\n", err)
+		return 1
+	}
+
+	if c.flagOutFile == "" && c.flagOutDir == "" {
+		if !isTextFormat(c.flagFormat) {
+			fmt.Printf("You must set an -outdir or -out for %s output\n", c.flagFormat)
+			return 1
+		}
+		fmt.Printf("%s\n", out)
+		return 0
+	}
+
+	outfile := c.flagOutFile
+	if c.flagOutDir != "" {
+		outfile = filepath.Join(c.flagOutDir, "fleet"+dfdExt(c.flagFormat))
+		if err := createOrValidateFolder(c.flagOutDir, c.flagOverwrite); err != nil {
+			fmt.Printf("%s\n", err)
+			return 1
+		}
+	}
+	if err := fileExistenceCheck([]string{outfile}, c.flagOverwrite); err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+	if err := os.WriteFile(outfile, out, 0600); err != nil {
+		fmt.Printf("Error writing %s file to %s: %s\n", strings.ToUpper(c.flagFormat), outfile, err)
+		return 1
+	}
+
+	fmt.Printf("Successfully created '%s'\n\n%s", outfile, fm)
+	return 0
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zenizh/go-capturer"
+)
+
+// fleetTm is a second model that writes to the same card store as
+// analyzeTm, under another name.
+const fleetTm = `spec_version = "0.1.0"
+
+threatmodel "Billing" {
+  author = "@bob"
+
+  data_flow_diagram_v2 "Level 0" {
+    trust_zone "Internal" {
+      process "Web" {}
+
+      data_store "card-store" {}
+    }
+
+    flow "Charge" {
+      from = "Web"
+      to   = "card-store"
+    }
+  }
+}
+`
+
+// writeFleetTms writes analyzeTm and fleetTm to a directory, with an alias
+// file merging their card stores, and returns the directory and the alias
+// file.
+func writeFleetTms(tb testing.TB) (string, string) {
+	tb.Helper()
+
+	dir := tb.TempDir()
+	for name, content := range map[string]string{"shop.hcl": analyzeTm, "billing.hcl": fleetTm} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			tb.Fatalf("Error writing threat model: %s", err)
+		}
+	}
+
+	aliases := filepath.Join(tb.TempDir(), "aliases.hcl")
+	if err := os.WriteFile(aliases, []byte("element \"Cards\" {\n  aliases = [\"card-store\"]\n}\n"), 0600); err != nil {
+		tb.Fatalf("Error writing aliases: %s", err)
+	}
+	return dir, aliases
+}
+
+func TestDfdFleet(t *testing.T) {
+	dir, aliases := writeFleetTms(t)
+	cmd := testDfdCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-fleet", "-format=d2", "-stdout", fmt.Sprintf("-aliases=%s", aliases), dir})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	for _, exp := range []string{
+		`title: "Fleet"`,
+		`"Cards" {shape: cylinder}`,
+		`"Shop: Web" {shape: oval}`,
+		`"Billing: Web" {shape: oval}`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Expected %q in:\n%s", exp, out)
+		}
+	}
+	if strings.Contains(out, "card-store") {
+		t.Errorf("Expected card-store to be merged into Cards:\n%s", out)
+	}
+}
+
+func TestDfdFleetPath(t *testing.T) {
+	dir, aliases := writeFleetTms(t)
+	cmd := testDfdCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-fleet", "-format=d2", "-stdout", fmt.Sprintf("-aliases=%s", aliases), "-path-from=Billing: Web", "-path-to=Cards", dir})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+	for _, exp := range []string{`"Billing: Web" {shape: oval}`, `"Cards" {shape: cylinder}`} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Expected %q in:\n%s", exp, out)
+		}
+	}
+	if strings.Contains(out, `"Shop: Web"`) {
+		t.Errorf("Expected Shop: Web to be left out:\n%s", out)
+	}
+}
+
+func TestDfdFleetOutDir(t *testing.T) {
+	dir, _ := writeFleetTms(t)
+	outDir := filepath.Join(t.TempDir(), "out")
+	cmd := testDfdCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-fleet", "-format=svg", "-cluster=zone", fmt.Sprintf("-outdir=%s", outDir), dir})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+	if !strings.Contains(out, "Merged 2 DFD(s) into 5 elements and 3 flows") {
+		t.Errorf("Expected a summary in:\n%s", out)
+	}
+
+	svg, err := os.ReadFile(filepath.Join(outDir, "fleet.svg"))
+	if err != nil {
+		t.Fatalf("Error reading svg: %s", err)
+	}
+	if !strings.Contains(string(svg), "<svg") {
+		t.Errorf("Expected an svg, got %.100s", svg)
+	}
+}
+
+func TestDfdFleetInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		exp  string
+	}{
+		{"format", []string{"-fleet", "-format=png", "-out=x.png"}, "-fleet supports the svg, dot, d2 and mermaid formats"},
+		{"cluster", []string{"-fleet", "-format=dot", "-stdout", "-cluster=team"}, "-cluster must be model or zone"},
+		{"diff", []string{"-fleet", "-format=dot", "-stdout", "-diff-from=HEAD"}, "-fleet can't be used with -diff-from"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := testDfdCommand(t)
+
+			var code int
+			out := capturer.CaptureStdout(func() {
+				code = cmd.Run(append(tc.args, "./testdata/tm3.hcl"))
+			})
+
+			if code != 1 {
+				t.Errorf("Code did not equal 1: %d", code)
+			}
+			if !strings.Contains(out, tc.exp) {
+				t.Errorf("Expected %q in:\n%s", tc.exp, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zenizh/go-capturer"
+)
+
+func TestDfdFocus(t *testing.T) {
+	tm := filepath.Join(t.TempDir(), "shop.hcl")
+	if err := os.WriteFile(tm, []byte(analyzeTm), 0600); err != nil {
+		t.Fatalf("Error writing threat model: %s", err)
+	}
+
+	cases := []struct {
+		name  string
+		args  []string
+		exp   []string
+		unexp string
+	}{
+		{"focus", []string{"-format=mermaid", "-focus=User", "-depth=0"}, []string{`el1["User"]`, `el2["…"]`, `el1 -->|"Browse"| el2`}, "Cards"},
+		{"zone", []string{"-format=plantuml", "-zone=Internal"}, []string{`usecase "Web" as el1`, `label "…" as el3`, `el3 --> el1 : Browse`}, `rectangle "User"`},
+		{"path", []string{"-format=d2", "-path-from=User", "-path-to=Cards"}, []string{`"User" {shape: rectangle}`, `"Cards" {shape: cylinder}`}, "…"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := testDfdCommand(t)
+
+			var code int
+			out := capturer.CaptureStdout(func() {
+				code = cmd.Run(append(tc.args, "-stdout", tm))
+			})
+
+			if code != 0 {
+				t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+			}
+			for _, exp := range tc.exp {
+				if !strings.Contains(out, exp) {
+					t.Errorf("Expected %q in:\n%s", exp, out)
+				}
+			}
+			if strings.Contains(out, tc.unexp) {
+				t.Errorf("Expected no %q in:\n%s", tc.unexp, out)
+			}
+		})
+	}
+}
+
+func TestDfdFocusInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		exp  string
+	}{
+		{"several", []string{"-focus=Web", "-zone=Internal"}, "Only one of -focus, -zone or -path-from and -path-to can be set"},
+		{"path", []string{"-path-from=Web"}, "-path-from and -path-to must be set together"},
+		{"depth", []string{"-focus=Web", "-depth=-1"}, "-depth can't be negative"},
+		{"diff", []string{"-zone=Internal", "-diff-from=HEAD"}, "-focus, -zone and -path-from can't be used with -diff-from"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := testDfdCommand(t)
+
+			var code int
+			out := capturer.CaptureStdout(func() {
+				code = cmd.Run(append(tc.args, "-format=dot", "-stdout", "./testdata/tm3.hcl"))
+			})
+
+			if code != 1 {
+				t.Errorf("Code did not equal 1: %d", code)
+			}
+			if !strings.Contains(out, tc.exp) {
+				t.Errorf("Expected %q in:\n%s", tc.exp, out)
+			}
+		})
+	}
+}
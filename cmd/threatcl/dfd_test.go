@@ -419,8 +419,8 @@ func TestDfdInvalidFormat(t *testing.T) {
 		t.Errorf("Code did not equal 1: %d", code)
 	}
 
-	if !strings.Contains(out, "format must be png, dot, svg, mermaid or d2") {
-		t.Errorf("%s did not contain %s", out, "format must be png, dot, svg, mermaid or d2")
+	if !strings.Contains(out, "format must be png, dot, svg, mermaid, d2, plantuml or drawio") {
+		t.Errorf("%s did not contain %s", out, "format must be png, dot, svg, mermaid, d2, plantuml or drawio")
 	}
 
 }
@@ -966,6 +966,71 @@ func TestDfdSuccessfulOutD2(t *testing.T) {
 	}
 }
 
+func TestDfdPlantUML(t *testing.T) {
+	d, err := os.MkdirTemp("", "")
+	if err != nil {
+		t.Fatalf("Error creatig tmp dir: %s", err)
+	}
+
+	defer os.RemoveAll(d)
+
+	cmd := testDfdCommand(t)
+
+	var code int
+
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{
+			fmt.Sprintf("-outdir=%s", filepath.Join(d, "out")),
+			"-format=plantuml",
+			"./testdata/tm3.hcl",
+		})
+	})
+
+	if code != 0 {
+		t.Errorf("Code did not equal 0: %d", code)
+	}
+
+	if !strings.Contains(out, "Successfully created") {
+		t.Errorf("%s did not contain %s", out, "Successfully created")
+	}
+
+	contents, err := os.ReadFile(filepath.Join(d, "out", "tm3-tm3onelegacydfd.puml"))
+	if err != nil {
+		t.Fatalf("Error opening plantuml: %s", err)
+	}
+
+	for _, exp := range []string{"@startuml", `rectangle "AWS" as zone`, "@enduml"} {
+		if !strings.Contains(string(contents), exp) {
+			t.Errorf("plantuml output missing %q: %s", exp, contents)
+		}
+	}
+}
+
+func TestDfdDrawioStdout(t *testing.T) {
+	cmd := testDfdCommand(t)
+
+	var code int
+
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{
+			"-format=drawio",
+			"-protocol-style=color",
+			"-stdout",
+			"./testdata/tm3.hcl",
+		})
+	})
+
+	if code != 0 {
+		t.Errorf("Code did not equal 0: %d", code)
+	}
+
+	for _, exp := range []string{`<mxfile host="threatcl">`, `value="AWS"`, "container=1", "strokeColor=#"} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("%s did not contain %s", out, exp)
+		}
+	}
+}
+
 func TestDfdProtocolStyleValid(t *testing.T) {
 	styles := []string{"label", "color", "both", "none"}
 	for _, style := range styles {
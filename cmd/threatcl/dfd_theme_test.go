@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zenizh/go-capturer"
+)
+
+const dfdTheme = `direction = "TB"
+legend    = true
+
+zone "Internal" {
+  fill = "#eeeeee"
+}
+
+element "data_store" {
+  shape = "hexagon"
+}
+
+classification "Restricted" {
+  color = "#d62728"
+  width = 3
+}
+`
+
+// writeDfdTheme writes content to a theme file and returns its path.
+func writeDfdTheme(tb testing.TB, content string) string {
+	tb.Helper()
+
+	path := filepath.Join(tb.TempDir(), "theme.hcl")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		tb.Fatalf("Error writing theme: %s", err)
+	}
+	return path
+}
+
+// writeDfdThemeConfig writes an empty -config file with a dfd-theme.hcl of
+// content beside it, and returns the config file's path.
+func writeDfdThemeConfig(tb testing.TB, content string) string {
+	tb.Helper()
+
+	dir := tb.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, dfdThemeFile), []byte(content), 0600); err != nil {
+		tb.Fatalf("Error writing theme: %s", err)
+	}
+	path := filepath.Join(dir, "config.hcl")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		tb.Fatalf("Error writing config: %s", err)
+	}
+	return path
+}
+
+func TestLoadDfdTheme(t *testing.T) {
+	theme := writeDfdTheme(t, "direction = \"BT\"\n")
+	config := writeDfdThemeConfig(t, "direction = \"TB\"\n")
+
+	cases := []struct {
+		name   string
+		theme  string
+		config string
+		exp    string
+	}{
+		{"none", "", "", ""},
+		{"theme", theme, "", "BT"},
+		{"config", "", config, "TB"},
+		{"theme over config", theme, config, "BT"},
+		{"config without a theme", "", filepath.Join(t.TempDir(), "config.hcl"), ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := loadDfdTheme(tc.theme, tc.config)
+			if err != nil {
+				t.Fatalf("Error loading theme: %s", err)
+			}
+			if tc.exp == "" {
+				if got != nil {
+					t.Errorf("Expected no theme, got %+v", got)
+				}
+				return
+			}
+			if got == nil || got.Direction != tc.exp {
+				t.Errorf("Expected direction %s, got %+v", tc.exp, got)
+			}
+		})
+	}
+}
+
+func TestDfdTheme(t *testing.T) {
+	tm := filepath.Join(t.TempDir(), "shop.hcl")
+	if err := os.WriteFile(tm, []byte(analyzeTm), 0600); err != nil {
+		t.Fatalf("Error writing threat model: %s", err)
+	}
+	theme := writeDfdTheme(t, dfdTheme)
+	config := writeDfdThemeConfig(t, dfdTheme)
+
+	cases := []struct {
+		name string
+		args []string
+		exp  []string
+	}{
+		{
+			"mermaid",
+			[]string{"-format=mermaid", "-stdout", fmt.Sprintf("-theme=%s", theme)},
+			[]string{"flowchart TB", `el3{{"Cards"}}`, "style zone1 fill:#eeeeee", "linkStyle 1 stroke:#d62728", `legend1["Trust zone: Internal"]`},
+		},
+		{
+			"config",
+			[]string{"-format=dot", "-stdout", fmt.Sprintf("-config=%s", config)},
+			[]string{"rankdir=TB;", "shape=hexagon", `penwidth=3`},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := testDfdCommand(t)
+
+			var code int
+			out := capturer.CaptureStdout(func() {
+				code = cmd.Run(append(tc.args, tm))
+			})
+
+			if code != 0 {
+				t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+			}
+			for _, exp := range tc.exp {
+				if !strings.Contains(out, exp) {
+					t.Errorf("Expected %q in:\n%s", exp, out)
+				}
+			}
+		})
+	}
+}
+
+func TestDfdThemeInvalid(t *testing.T) {
+	theme := writeDfdTheme(t, "direction = \"sideways\"\n")
+	cmd := testDfdCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-format=dot", "-stdout", fmt.Sprintf("-theme=%s", theme), "./testdata/tm3.hcl"})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+	if !strings.Contains(out, "Error reading -theme: direction must be one of LR, RL, TB, BT") {
+		t.Errorf("Expected a theme error in:\n%s", out)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/posener/complete"
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/dfd"
+	"github.com/threatcl/threatcl/internal/tmloader"
+)
+
+type DfdValidateCommand struct {
+	*GlobalCmdOptions
+	specCfg    *spec.ThreatmodelSpecConfig
+	flagFormat string
+}
+
+func (c *DfdValidateCommand) Help() string {
+	helpText := `
+Usage: threatcl dfd validate [options] <files>
+
+  Check that the levels of the Data Flow Diagrams in existing Threat model
+  HCL files (as specified by <files>) balance
+
+  A data_flow_diagram_v2 named after a process of another diagram in the
+  same threat model, either on its own or after a colon (as in "Web" or
+  "Level 1: Web"), decomposes that process. Every flow between the process
+  and another element of its diagram must be preserved in the child
+  diagram, as a flow in the same direction between that element and the
+  child's own elements. Every such flow in the child diagram must likewise
+  have a matching flow in the parent. Exits with 1 if any are unbalanced.
+
+  'threatcl dfd -format=svg -outdir', 'threatcl dashboard -dashboard-html'
+  and 'threatcl site' link decomposed processes to their child diagrams.
+
+Options:
+
+ -config=<file>
+   Optional config file
+
+ -format=<text|json>
+   Output format. Defaults to text
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// dfdHierarchy is one threat model's hierarchy report.
+type dfdHierarchy struct {
+	Threatmodel string `json:"threatmodel"`
+	File        string `json:"file"`
+	*dfd.HierarchyReport
+}
+
+func (c *DfdValidateCommand) Run(args []string) int {
+	flagSet := c.GetFlagset("dfd validate")
+	flagSet.StringVar(&c.flagFormat, "format", "text", "Output format. text or json")
+	parseFlags(flagSet, args)
+
+	if c.flagConfig != "" {
+		err := c.specCfg.LoadSpecConfigFile(c.flagConfig)
+
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			return 1
+		}
+	}
+
+	if c.flagFormat != "text" && c.flagFormat != "json" {
+		fmt.Printf("-format must be text or json\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	if len(flagSet.Args()) == 0 {
+		fmt.Printf("Please provide file(s)\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	res, err := tmloader.LoadSet(c.specCfg, flagSet.Args())
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+
+	reports := []dfdHierarchy{}
+	unbalanced := 0
+	for _, lm := range res.Models {
+		if len(lm.TM.DataFlowDiagrams) == 0 {
+			continue
+		}
+		r := hierarchyThreatmodel(lm.TM)
+		unbalanced += len(r.Imbalances)
+		reports = append(reports, dfdHierarchy{Threatmodel: lm.TM.Name, File: lm.File, HierarchyReport: r})
+	}
+	if len(reports) == 0 {
+		fmt.Printf("No DFDs found\n")
+		return 1
+	}
+
+	switch c.flagFormat {
+	case "json":
+		j, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			fmt.Printf("Error marshalling JSON: %s\n", err)
+			return 1
+		}
+		fmt.Printf("%s\n", j)
+	default:
+		parts := []string{}
+		for _, r := range reports {
+			parts = append(parts, fmt.Sprintf("%s\n\n%s", r.Threatmodel, r.HierarchyReport))
+		}
+		fmt.Print(strings.Join(parts, "\n"))
+	}
+
+	if unbalanced > 0 {
+		return 1
+	}
+	return 0
+}
+
+// hierarchyThreatmodel checks the decomposed processes of every DFD in tm.
+func hierarchyThreatmodel(tm *spec.Threatmodel) *dfd.HierarchyReport {
+	graphs := []*dfd.Graph{}
+	for _, adfd := range tm.DataFlowDiagrams {
+		graphs = append(graphs, dfd.FromSpec(adfd))
+	}
+	return dfd.CheckHierarchy(graphs)
+}
+
+func (c *DfdValidateCommand) Synopsis() string {
+	return "Check that the levels of Data Flow Diagrams balance"
+}
+
+func (c *DfdValidateCommand) AutocompleteArgs() complete.Predictor { return predictHCLOrJSON }
+func (c *DfdValidateCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-config": predictHCL,
+		"-format": complete.PredictSet("text", "json"),
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+
+	"github.com/zenizh/go-capturer"
+)
+
+// hierarchyTm decomposes analyzeTm's Web process in a level 1 diagram,
+// which drops the flow to the card store.
+const hierarchyTm = `spec_version = "0.1.0"
+
+threatmodel "Shop" {
+  author = "@alice"
+
+  data_flow_diagram_v2 "Level 0" {
+    external_element "User" {}
+
+    trust_zone "Internal" {
+      process "Web" {}
+
+      data_store "Cards" {}
+    }
+
+    flow "Browse" {
+      from = "User"
+      to   = "Web"
+    }
+
+    flow "Store" {
+      from = "Web"
+      to   = "Cards"
+    }
+  }
+
+  data_flow_diagram_v2 "Level 1: Web" {
+    external_element "User" {}
+
+    trust_zone "Internal" {
+      process "Router" {}
+    }
+
+    flow "Request" {
+      from = "User"
+      to   = "Router"
+    }
+  }
+}
+`
+
+func testDfdValidateCommand(tb testing.TB) *DfdValidateCommand {
+	tb.Helper()
+
+	d, err := os.MkdirTemp("", "")
+	if err != nil {
+		tb.Fatalf("Error creating tmp dir: %s", err)
+	}
+
+	_ = os.Setenv("HOME", d)
+	_ = os.Setenv("USERPROFILE", d)
+
+	cfg, _ := spec.LoadSpecConfig()
+
+	defer os.RemoveAll(d)
+
+	global := &GlobalCmdOptions{}
+
+	return &DfdValidateCommand{
+		GlobalCmdOptions: global,
+		specCfg:          cfg,
+	}
+}
+
+func writeHierarchyTm(tb testing.TB) string {
+	tb.Helper()
+
+	tmFile := filepath.Join(tb.TempDir(), "shop.hcl")
+	if err := os.WriteFile(tmFile, []byte(hierarchyTm), 0600); err != nil {
+		tb.Fatalf("Error writing threat model: %s", err)
+	}
+	return tmFile
+}
+
+func TestDfdValidateUnbalanced(t *testing.T) {
+	tmFile := writeHierarchyTm(t)
+	cmd := testDfdValidateCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{tmFile})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+
+	for _, exp := range []string{
+		"Decomposed processes (1):\n  Level 0: Web -> Level 1: Web\n",
+		`Level 0: flow "Store" (Web -> Cards) isn't preserved in "Level 1: Web"`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Expected %q in:\n%s", exp, out)
+		}
+	}
+}
+
+func TestDfdValidateBalancedJSON(t *testing.T) {
+	tmFile := writeAnalyzeTm(t)
+	cmd := testDfdValidateCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-format=json", tmFile})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	reports := []dfdHierarchy{}
+	if err := json.Unmarshal([]byte(out), &reports); err != nil {
+		t.Fatalf("Error parsing JSON: %s\n%s", err, out)
+	}
+	if len(reports) != 1 || reports[0].Threatmodel != "Shop" || len(reports[0].Decompositions) != 0 {
+		t.Errorf("Unexpected reports: %+v", reports)
+	}
+}
+
+func TestDfdSvgLinksChildDiagram(t *testing.T) {
+	tmFile := writeHierarchyTm(t)
+	outDir := filepath.Join(t.TempDir(), "out")
+	cmd := testDfdCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-format=svg", fmt.Sprintf("-outdir=%s", outDir), tmFile})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	svg, err := os.ReadFile(filepath.Join(outDir, "shop-shoplevel0.svg"))
+	if err != nil {
+		t.Fatalf("Error reading svg: %s\n%s", err, out)
+	}
+	if !strings.Contains(string(svg), `xlink:href="shop-shoplevel1web.svg"`) {
+		t.Errorf("Expected Web to link to its child diagram:\n%s", svg)
+	}
+}
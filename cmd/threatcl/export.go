@@ -2,10 +2,12 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/posener/complete"
 	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/redact"
 	"github.com/threatcl/threatcl/internal/tmloader"
 )
 
@@ -17,6 +19,9 @@ type ExportCommand struct {
 	flagOutput    string
 	flagTemplate  string
 	flagOverwrite bool
+	flagRedact    string
+	flagMapping   string
+	flagCatalogs  string
 }
 
 // Help is the help output for the "threatcl export" command
@@ -31,16 +36,44 @@ Options:
  -config=<file>
    Optional config file
 
- -format=<json|otm|hcl>
+ -format=<json|otm|threatdragon|csv|docx|attack-navigator|oscal|stix|hcl>
+   csv writes a threat register with one row per threat/control pair, which
+   'threatcl import -format=csv' can merge back. docx writes a Word report,
+   for one threat model or the whole set, and requires -output.
+   attack-navigator writes an ATT&CK Navigator layer scoring each technique
+   the controls map onto by the percentage of those controls implemented.
+   oscal writes an OSCAL component-definition, with a component per threat
+   model, from the framework control ids in control attributes such as
+   nist_800_53. Controls that don't name any are skipped with a warning.
+   stix writes a STIX 2.1 bundle of threats, controls, information assets
+   and third party dependencies, with ids that stay the same from one
+   export to the next
 
  -template=<file>
-   Optional overridden template file to use for md output
+   Optional overridden template file to use for md output, or a reference
+   .docx whose styles, headers, footers and page setup docx output uses
 
  -output=<file>
    Optional filename to output to. 
 
  -overwrite
 
+ -redact=<file>
+   Optional HCL redaction profile. Matching fields, threats and information
+   assets are dropped or masked before export, and a report of what was
+   removed is printed (to STDERR when exporting to STDOUT)
+
+ -attack-mapping=<file>
+   Optional HCL file mapping ATT&CK mitigations, and controls by name, onto
+   techniques for attack-navigator output. Controls also name techniques
+   and mitigations in attack_technique and attack_mitigation attributes, or
+   by linking to them on attack.mitre.org in their description
+
+ -oscal-catalog=<framework>=<uri>,...
+   OSCAL catalogs of the asvs and proactive_control frameworks, which
+   OWASP doesn't publish, for oscal output. Without one, their control ids
+   are skipped with a warning. Also overrides the nist_800_53 catalog
+
 `
 	return strings.TrimSpace(helpText)
 }
@@ -48,10 +81,13 @@ Options:
 // Run executes the "threatcl export" logic
 func (e *ExportCommand) Run(args []string) int {
 	flagSet := e.GetFlagset("export")
-	flagSet.StringVar(&e.flagFormat, "format", "json", "Format of output. json, hcl, or otm. Defaults to json")
+	flagSet.StringVar(&e.flagFormat, "format", "json", "Format of output. json, hcl, otm, threatdragon, csv, docx, attack-navigator, oscal or stix. Defaults to json")
 	flagSet.StringVar(&e.flagOutput, "output", "", "Name of output file. If not set, will output to STDOUT")
-	flagSet.StringVar(&e.flagTemplate, "template", "", "Optional overridden template file to use for md output")
+	flagSet.StringVar(&e.flagTemplate, "template", "", "Optional overridden template file to use for md output, or reference .docx for docx output")
 	flagSet.BoolVar(&e.flagOverwrite, "overwrite", false, "Overwrite existing file. Defaults to false")
+	flagSet.StringVar(&e.flagRedact, "redact", "", "Optional HCL redaction profile to apply before export")
+	flagSet.StringVar(&e.flagMapping, "attack-mapping", "", "Optional HCL mapping of ATT&CK mitigations and controls onto techniques for attack-navigator output")
+	flagSet.StringVar(&e.flagCatalogs, "oscal-catalog", "", "Optional framework=uri OSCAL catalogs for oscal output, separated by commas")
 	parseFlags(flagSet, args)
 
 	if e.flagConfig != "" {
@@ -63,6 +99,36 @@ func (e *ExportCommand) Run(args []string) int {
 		}
 	}
 
+	if e.flagFormat == "docx" && e.flagOutput == "" {
+		fmt.Printf("-format=docx requires -output\n")
+		return 1
+	}
+
+	if e.flagMapping != "" && e.flagFormat != "attack-navigator" {
+		fmt.Printf("-attack-mapping is only supported with -format=attack-navigator\n")
+		return 1
+	}
+
+	if e.flagCatalogs != "" && e.flagFormat != "oscal" {
+		fmt.Printf("-oscal-catalog is only supported with -format=oscal\n")
+		return 1
+	}
+	catalogs, err := parseOscalCatalogs(e.flagCatalogs)
+	if err != nil {
+		fmt.Printf("Error with -oscal-catalog: %s\n", err)
+		return 1
+	}
+
+	var profile *redact.Profile
+	if e.flagRedact != "" {
+		var err error
+		profile, err = loadRedactProfile(e.flagRedact)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			return 1
+		}
+	}
+
 	if len(flagSet.Args()) == 0 {
 		fmt.Printf("Please provide a filename\n")
 		return 1
@@ -74,6 +140,13 @@ func (e *ExportCommand) Run(args []string) int {
 			return 1
 		}
 
+		// Redaction edits the parsed models in place, which also covers the
+		// "hcl" format as it encodes from the same parser state.
+		var report *redact.Report
+		if profile != nil {
+			report = profile.Apply(loadedThreatmodels(res.Models))
+		}
+
 		var AllTms []spec.Threatmodel
 		for _, lm := range res.Models {
 			AllTms = append(AllTms, *lm.TM)
@@ -81,7 +154,18 @@ func (e *ExportCommand) Run(args []string) int {
 
 		// The "hcl" format re-encodes from parser state; res.HCLParser holds
 		// the merged HCL set (all .hcl inputs).
-		outputString, err := renderThreatmodels(AllTms, res.HCLParser, e.flagFormat, e.flagTemplate)
+		var (
+			outputString string
+			warnings     []string
+		)
+		switch e.flagFormat {
+		case "attack-navigator":
+			outputString, warnings, err = renderAttackNavigator(AllTms, e.flagMapping)
+		case "oscal":
+			outputString, warnings, err = renderOscal(AllTms, catalogs)
+		default:
+			outputString, err = renderThreatmodels(AllTms, res.HCLParser, e.flagFormat, e.flagTemplate)
+		}
 		if err != nil {
 			fmt.Printf("%s\n", err)
 			return 1
@@ -89,6 +173,12 @@ func (e *ExportCommand) Run(args []string) int {
 
 		if e.flagOutput == "" {
 			fmt.Printf("%s\n", outputString)
+			if report != nil {
+				fmt.Fprint(os.Stderr, report.String())
+			}
+			for _, w := range warnings {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+			}
 		} else {
 			err := fileExistenceCheck([]string{e.flagOutput}, e.flagOverwrite)
 			if err != nil {
@@ -101,6 +191,12 @@ func (e *ExportCommand) Run(args []string) int {
 				return 1
 			}
 			fmt.Printf("Successfully wrote '%s'\n", e.flagOutput)
+			if report != nil {
+				fmt.Print(report.String())
+			}
+			for _, w := range warnings {
+				fmt.Printf("Warning: %s\n", w)
+			}
 		}
 	}
 	return 0
@@ -114,9 +210,30 @@ func (e *ExportCommand) Synopsis() string {
 func (c *ExportCommand) AutocompleteArgs() complete.Predictor { return predictHCLOrJSON }
 func (c *ExportCommand) AutocompleteFlags() complete.Flags {
 	return complete.Flags{
-		"-config":   predictHCL,
-		"-format":   complete.PredictSet("json", "otm", "hcl"),
-		"-output":   complete.PredictFiles("*"),
-		"-template": predictTpl,
+		"-config":         predictHCL,
+		"-format":         complete.PredictSet("json", "otm", "threatdragon", "csv", "docx", "attack-navigator", "oscal", "stix", "hcl"),
+		"-output":         complete.PredictFiles("*"),
+		"-template":       complete.PredictOr(predictTpl, complete.PredictFiles("*.docx")),
+		"-redact":         predictHCL,
+		"-attack-mapping": predictHCL,
+		"-oscal-catalog":  complete.PredictAnything,
+	}
+}
+
+// loadRedactProfile reads the redaction profile at path.
+func loadRedactProfile(path string) (*redact.Profile, error) {
+	profile, err := redact.ParseFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing redaction profile %s: %s", path, err)
+	}
+	return profile, nil
+}
+
+// loadedThreatmodels returns the threat models of a loaded set, in order.
+func loadedThreatmodels(models []tmloader.LoadedModel) []*spec.Threatmodel {
+	tms := make([]*spec.Threatmodel, 0, len(models))
+	for _, lm := range models {
+		tms = append(tms, lm.TM)
 	}
+	return tms
 }
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"archive/zip"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/attack"
+	"github.com/threatcl/threatcl/internal/oscal"
+	"github.com/threatcl/threatcl/internal/stix"
 
 	"github.com/zenizh/go-capturer"
 )
@@ -177,6 +183,347 @@ func TestExportOtm(t *testing.T) {
 
 }
 
+func TestExportThreatDragon(t *testing.T) {
+	cmd := testExportCommand(t)
+
+	var code int
+
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{
+			"-format=threatdragon",
+			"./testdata/tm1.hcl",
+		})
+	})
+
+	if code != 0 {
+		t.Errorf("Code did not equal 0: %d", code)
+	}
+
+	for _, want := range []string{
+		`"version":"2.2.0"`,
+		`"owner":"@xntrik"`,
+		`"title":"multi line threat"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("threatdragon output did not contain %q\n%s", want, out)
+		}
+	}
+}
+
+func TestExportCsv(t *testing.T) {
+	cmd := testExportCommand(t)
+
+	var code int
+
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{
+			"-format=csv",
+			"./testdata/tm1.hcl",
+		})
+	})
+
+	if code != 0 {
+		t.Errorf("Code did not equal 0: %d", code)
+	}
+
+	for _, want := range []string{
+		"model,threat,stride,impacts,likelihood,impact,severity,residual_score,control,implemented,risk_reduction,asset_refs",
+		"tm1 one,multi line threat,",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("csv output did not contain %q\n%s", want, out)
+		}
+	}
+}
+
+func TestExportDocx(t *testing.T) {
+	cmd := testExportCommand(t)
+
+	var code int
+
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{
+			"-format=docx",
+			"./testdata/tm1.hcl",
+		})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+	if !strings.Contains(out, "-format=docx requires -output") {
+		t.Errorf("Expected -output error, got %s", out)
+	}
+
+	outFile := filepath.Join(t.TempDir(), "report.docx")
+	cmd = testExportCommand(t)
+	out = capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{
+			"-format=docx",
+			fmt.Sprintf("-output=%s", outFile),
+			"./testdata/tm1.hcl",
+		})
+	})
+
+	if code != 0 {
+		t.Errorf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	zr, err := zip.OpenReader(outFile)
+	if err != nil {
+		t.Fatalf("Error opening docx: %s", err)
+	}
+	defer zr.Close()
+
+	var doc string
+	for _, f := range zr.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Error reading document: %s", err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		doc = string(b)
+	}
+	for _, want := range []string{"tm1 one", "multi line threat", "Control coverage"} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("docx did not contain %q", want)
+		}
+	}
+}
+
+const attackTm = `spec_version = "0.7.0"
+
+threatmodel "attack" {
+  author = "@xntrik"
+
+  threat "Credential stuffing" {
+    description = "Attackers replay leaked credentials against the login page"
+
+    control "Account lockout" {
+      implemented = true
+      description = "[M1036](https://attack.mitre.org/mitigations/M1036/) - lock accounts after failed logins"
+    }
+
+    control "MFA" {
+      implemented = false
+
+      attribute "attack_technique" {
+        value = "T1110.004"
+      }
+    }
+  }
+}
+`
+
+func TestExportAttackNavigator(t *testing.T) {
+	d := t.TempDir()
+	tmFile := filepath.Join(d, "tm.hcl")
+	mappingFile := filepath.Join(d, "mapping.hcl")
+	if err := os.WriteFile(tmFile, []byte(attackTm), 0644); err != nil {
+		t.Fatalf("Error writing threat model: %s", err)
+	}
+	if err := os.WriteFile(mappingFile, []byte("mitigation \"M1036\" {\n  techniques = [\"T1110.004\"]\n}\n"), 0644); err != nil {
+		t.Fatalf("Error writing mapping: %s", err)
+	}
+
+	cmd := testExportCommand(t)
+
+	var code int
+
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{
+			"-format=json",
+			fmt.Sprintf("-attack-mapping=%s", mappingFile),
+			tmFile,
+		})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+	if !strings.Contains(out, "-attack-mapping is only supported with -format=attack-navigator") {
+		t.Errorf("Expected -attack-mapping error, got %s", out)
+	}
+
+	tests := []struct {
+		name    string
+		mapping string
+		score   int
+		warning string
+	}{
+		{"unmapped", "", 0, `Warning: mitigation M1036 (control "Account lockout") isn't mapped to any techniques`},
+		{"mapped", mappingFile, 50, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			outFile := filepath.Join(t.TempDir(), "layer.json")
+			args := []string{"-format=attack-navigator", fmt.Sprintf("-output=%s", outFile)}
+			if tc.mapping != "" {
+				args = append(args, fmt.Sprintf("-attack-mapping=%s", tc.mapping))
+			}
+			cmd := testExportCommand(t)
+			out := capturer.CaptureStdout(func() {
+				code = cmd.Run(append(args, tmFile))
+			})
+
+			if code != 0 {
+				t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+			}
+			if tc.warning != "" && !strings.Contains(out, tc.warning) {
+				t.Errorf("Expected %q, got %s", tc.warning, out)
+			}
+			if tc.warning == "" && strings.Contains(out, "Warning:") {
+				t.Errorf("Unexpected warning: %s", out)
+			}
+
+			b, err := os.ReadFile(outFile)
+			if err != nil {
+				t.Fatalf("Error reading layer: %s", err)
+			}
+			layer := attack.Layer{}
+			if err := json.Unmarshal(b, &layer); err != nil {
+				t.Fatalf("Error parsing layer: %s", err)
+			}
+			if layer.Name != "attack" || len(layer.Techniques) != 1 {
+				t.Fatalf("Unexpected layer: %s", b)
+			}
+			if tech := layer.Techniques[0]; tech.TechniqueID != "T1110.004" || tech.Score != tc.score {
+				t.Errorf("Expected T1110.004 scored %d, got %+v", tc.score, tech)
+			}
+		})
+	}
+
+	cmd = testExportCommand(t)
+	out = capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{
+			"-format=attack-navigator",
+			fmt.Sprintf("-attack-mapping=%s", tmFile),
+			tmFile,
+		})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+	if !strings.Contains(out, "Error parsing ATT&CK mapping") {
+		t.Errorf("Expected mapping error, got %s", out)
+	}
+}
+
+const oscalTm = `spec_version = "0.7.0"
+
+threatmodel "oscal" {
+  author = "@xntrik"
+
+  threat "Credential stuffing" {
+    description = "Attackers replay leaked credentials against the login page"
+
+    control "MFA" {
+      implemented = true
+      implementation_notes = "TOTP for all accounts"
+
+      attribute "nist_800_53" {
+        value = "IA-2(1)"
+      }
+    }
+
+    control "Monitoring" {
+      implemented = false
+    }
+  }
+}
+`
+
+func TestExportOscal(t *testing.T) {
+	tmFile := filepath.Join(t.TempDir(), "tm.hcl")
+	if err := os.WriteFile(tmFile, []byte(oscalTm), 0644); err != nil {
+		t.Fatalf("Error writing threat model: %s", err)
+	}
+
+	cmd := testExportCommand(t)
+
+	var code int
+
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{
+			"-format=oscal",
+			tmFile,
+		})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	doc := oscal.Document{}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("Error parsing component-definition: %s\n%s", err, out)
+	}
+	cd := doc.ComponentDefinition
+	if cd.Metadata.Title != "oscal" || len(cd.Components) != 1 || len(cd.Components[0].ControlImplementations) != 1 {
+		t.Fatalf("Unexpected component-definition: %s", out)
+	}
+	ir := cd.Components[0].ControlImplementations[0].ImplementedRequirements
+	if len(ir) != 1 || ir[0].ControlID != "ia-2.1" || ir[0].Description != "MFA: TOTP for all accounts" {
+		t.Errorf("Unexpected implemented requirements: %+v", ir)
+	}
+
+	outFile := filepath.Join(t.TempDir(), "oscal.json")
+	cmd = testExportCommand(t)
+	out = capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{
+			"-format=oscal",
+			fmt.Sprintf("-output=%s", outFile),
+			tmFile,
+		})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+	if !strings.Contains(out, `Warning: oscal: control "Monitoring" doesn't name any framework control ids, skipped`) {
+		t.Errorf("Expected a warning for the unmapped control, got %s", out)
+	}
+}
+
+func TestExportStix(t *testing.T) {
+	cmd := testExportCommand(t)
+
+	var code int
+
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{
+			"-format=stix",
+			"./testdata/tm1.hcl",
+		})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	bundle := stix.Bundle{}
+	if err := json.Unmarshal([]byte(out), &bundle); err != nil {
+		t.Fatalf("Error parsing bundle: %s\n%s", err, out)
+	}
+
+	reports := map[string]bool{}
+	for _, o := range bundle.Objects {
+		if o.Type == "report" {
+			reports[o.Name] = true
+		}
+	}
+	for _, name := range []string{"tm1 one", "tm tm1 two"} {
+		if !reports[name] {
+			t.Errorf("Expected a report for %q", name)
+		}
+	}
+}
+
 func TestExportOtmSingle(t *testing.T) {
 	d, err := os.MkdirTemp("", "")
 	if err != nil {
@@ -391,3 +738,193 @@ func TestExportGoodOverwrite(t *testing.T) {
 		t.Errorf("%s did not contain %s", string(fileIn), "This is some arbitrary text")
 	}
 }
+
+func TestExportRedact(t *testing.T) {
+	d := t.TempDir()
+
+	tmFile := filepath.Join(d, "redact.hcl")
+	err := os.WriteFile(tmFile, []byte(`spec_version = "0.7.0"
+
+threatmodel "redacted" {
+  author = "@xntrik"
+
+  information_asset "card data" {
+    information_classification = "Restricted"
+  }
+
+  threat "data leak" {
+    description = "Data could leak from db01.corp.example.com"
+    information_asset_refs = ["card data"]
+
+    control "encryption" {
+      implemented = false
+      description = "Encrypt at rest"
+      implementation_notes = "Not started, keys live in the shared vault"
+      risk_reduction = 50
+    }
+  }
+}
+`), 0600)
+	if err != nil {
+		t.Fatalf("Error writing tm file: %s", err)
+	}
+
+	profileFile := filepath.Join(d, "profile.hcl")
+	err = os.WriteFile(profileFile, []byte(`
+field {
+  match  = "^implementation_notes$"
+  action = "drop"
+}
+
+text {
+  match = "[a-z0-9.-]+\\.corp\\.example\\.com"
+}
+
+information_assets {
+  classifications = ["Restricted"]
+}
+`), 0600)
+	if err != nil {
+		t.Fatalf("Error writing profile: %s", err)
+	}
+
+	for _, format := range []string{"json", "otm", "hcl", "md"} {
+		t.Run(format, func(t *testing.T) {
+			outFile := filepath.Join(d, "out."+format)
+			cmd := testExportCommand(t)
+
+			var code int
+			out := capturer.CaptureStdout(func() {
+				code = cmd.Run([]string{"-format=" + format, "-redact=" + profileFile, "-output=" + outFile, "-overwrite", tmFile})
+			})
+
+			if code != 0 {
+				t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+			}
+
+			if !strings.Contains(out, `information_asset "card data" dropped (classified Restricted)`) {
+				t.Errorf("Expected report in %s", out)
+			}
+
+			exported, err := os.ReadFile(outFile)
+			if err != nil {
+				t.Fatalf("Error reading output: %s", err)
+			}
+			for _, leak := range []string{"shared vault", "db01.corp.example.com", "card data"} {
+				if strings.Contains(string(exported), leak) {
+					t.Errorf("Expected %q to be redacted from %s output:\n%s", leak, format, exported)
+				}
+			}
+		})
+	}
+}
+
+func TestExportRedactNames(t *testing.T) {
+	d := t.TempDir()
+
+	tmFile := filepath.Join(d, "redact.hcl")
+	err := os.WriteFile(tmFile, []byte(`spec_version = "0.7.0"
+
+threatmodel "redacted" {
+  author = "@xntrik"
+
+  information_asset "db01.corp.example.com backups" {
+    information_classification = "Internal"
+  }
+
+  threat "data leak from db01.corp.example.com" {
+    description = "Backups could leak"
+    information_asset_refs = ["db01.corp.example.com backups"]
+
+    control "encrypt db01.corp.example.com" {
+      implemented = false
+      description = "Encrypt at rest"
+      risk_reduction = 50
+    }
+  }
+
+  data_flow_diagram_v2 "dfd" {
+    data_store "backups" {
+      information_asset = "db01.corp.example.com backups"
+    }
+  }
+}
+`), 0600)
+	if err != nil {
+		t.Fatalf("Error writing tm file: %s", err)
+	}
+
+	profileFile := filepath.Join(d, "profile.hcl")
+	err = os.WriteFile(profileFile, []byte(`
+text {
+  match = "[a-z0-9.-]+\\.corp\\.example\\.com"
+}
+`), 0600)
+	if err != nil {
+		t.Fatalf("Error writing profile: %s", err)
+	}
+
+	for _, format := range []string{"json", "otm", "hcl", "md"} {
+		t.Run(format, func(t *testing.T) {
+			outFile := filepath.Join(d, "out."+format)
+			cmd := testExportCommand(t)
+
+			var code int
+			out := capturer.CaptureStdout(func() {
+				code = cmd.Run([]string{"-format=" + format, "-redact=" + profileFile, "-output=" + outFile, "-overwrite", tmFile})
+			})
+
+			if code != 0 {
+				t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+			}
+
+			exported, err := os.ReadFile(outFile)
+			if err != nil {
+				t.Fatalf("Error reading output: %s", err)
+			}
+			if strings.Contains(string(exported), "db01.corp.example.com") {
+				t.Errorf("Expected the hostname to be redacted from %s output:\n%s", format, exported)
+			}
+			if !strings.Contains(string(exported), "[REDACTED] backups") {
+				t.Errorf("Expected the masked asset name in %s output:\n%s", format, exported)
+			}
+		})
+	}
+}
+
+func TestExportRedactBadProfile(t *testing.T) {
+	profileFile := filepath.Join(t.TempDir(), "profile.hcl")
+	if err := os.WriteFile(profileFile, []byte(`mask = 1 +`), 0600); err != nil {
+		t.Fatalf("Error writing profile: %s", err)
+	}
+
+	cmd := testExportCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-redact=" + profileFile, "./testdata/tm1.hcl"})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+	if !strings.Contains(out, "Error parsing redaction profile") {
+		t.Errorf("Expected %s to contain %s", out, "Error parsing redaction profile")
+	}
+}
+
+func TestParseOscalCatalogs(t *testing.T) {
+	got, err := parseOscalCatalogs("asvs=https://example.com/asvs.json, proactive_control = ./pc.json")
+	if err != nil {
+		t.Fatalf("Error parsing catalogs: %s", err)
+	}
+	if len(got) != 2 || got["asvs"] != "https://example.com/asvs.json" || got["proactive_control"] != "./pc.json" {
+		t.Errorf("Unexpected catalogs: %v", got)
+	}
+
+	for _, in := range []string{"asvs", "asvs=", "cis=https://example.com/cis.json"} {
+		if _, err := parseOscalCatalogs(in); err == nil {
+			t.Errorf("Expected an error parsing %q", in)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/posener/complete"
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/otmconv"
+	"github.com/threatcl/threatcl/internal/register"
+	"github.com/threatcl/threatcl/internal/threatdragon"
+	"github.com/threatcl/threatcl/internal/tm7"
+)
+
+// ImportCommand struct defines the "threatcl import" command
+type ImportCommand struct {
+	*GlobalCmdOptions
+	specCfg       *spec.ThreatmodelSpecConfig
+	flagFormat    string
+	flagOutput    string
+	flagOverwrite bool
+	flagMerge     string
+}
+
+// Help is the help output for "threatcl import"
+func (c *ImportCommand) Help() string {
+	helpText := `
+Usage: threatcl import -format=<format> [options] <file>
+
+  Convert a threat model from another tool's format into threatcl HCL
+
+  otm: an Open Threat Model JSON file (a single project, or an array of them
+  as written by 'threatcl export -format=otm'). trustZones, components and
+  dataflows become a data_flow_diagram_v2, threats become threat blocks and
+  the mitigations each threat instance references become its controls.
+
+  tm7: a Microsoft Threat Modeling Tool file. Each diagram page becomes a
+  data_flow_diagram_v2: stencils become processes, external elements and
+  data stores, border trust boundaries become trust zones and connectors
+  become flows. The generated threat list becomes threat blocks, with each
+  threat's state and justification recorded in its description and controls.
+
+  threatdragon: an OWASP Threat Dragon v2 JSON model. Each diagram becomes a
+  data_flow_diagram_v2 (trust boundary boxes as trust zones), the threats on
+  its cells become threat blocks and their mitigations become controls.
+
+  csv: a threat register in the layout written by 'threatcl export
+  -format=csv', one row per threat/control pair. Columns are matched by
+  header name; severity and residual_score are ignored. With -merge, the rows
+  are merged into an existing HCL file: threats and controls are updated or
+  added (never removed), the rest of the file is left as it is, and every
+  change is reported.
+
+  Anything that doesn't map cleanly is reported as a warning on STDERR.
+
+Options:
+
+ -config=<file>
+   Optional config file
+
+ -format=<otm|tm7|threatdragon|csv>
+   Format of the input file. Defaults to otm
+
+ -merge=<file>
+   Optional HCL file to merge a csv register into. The updated file is
+   written to -output, or STDOUT
+
+ -output=<file>
+   Optional filename to write the HCL to. If not set, will output to STDOUT
+
+ -overwrite
+   Overwrite the output file if it exists
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run executes "threatcl import" logic
+func (c *ImportCommand) Run(args []string) int {
+	flagSet := c.GetFlagset("import")
+	flagSet.StringVar(&c.flagFormat, "format", "otm", "Format of the input file. Defaults to otm")
+	flagSet.StringVar(&c.flagOutput, "output", "", "Name of output file. If not set, will output to STDOUT")
+	flagSet.BoolVar(&c.flagOverwrite, "overwrite", false, "Overwrite existing file. Defaults to false")
+	flagSet.StringVar(&c.flagMerge, "merge", "", "Optional HCL file to merge a csv register into")
+	parseFlags(flagSet, args)
+
+	if c.flagConfig != "" {
+		err := c.specCfg.LoadSpecConfigFile(c.flagConfig)
+
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			return 1
+		}
+	}
+
+	if len(flagSet.Args()) != 1 {
+		fmt.Printf("Please provide a single file to import\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	if c.flagMerge != "" && c.flagFormat != "csv" {
+		fmt.Printf("-merge is only supported with -format=csv\n")
+		return 1
+	}
+
+	if c.flagOutput != "" {
+		if err := fileExistenceCheck([]string{c.flagOutput}, c.flagOverwrite); err != nil {
+			fmt.Printf("%s\n", err)
+			return 1
+		}
+	}
+
+	in, err := os.ReadFile(flagSet.Args()[0])
+	if err != nil {
+		fmt.Printf("Error reading %s: %s\n", flagSet.Args()[0], err)
+		return 1
+	}
+
+	var tms []*spec.Threatmodel
+	var warnings []string
+	var changes []register.Change
+	var out string
+
+	switch c.flagFormat {
+	case "otm":
+		tms, warnings, err = importOtm(in, c.specCfg)
+	case "tm7":
+		tms, warnings, err = importTm7(in, c.specCfg, flagSet.Args()[0])
+	case "threatdragon":
+		tms, warnings, err = importThreatDragon(in, c.specCfg)
+	case "csv":
+		var res *register.Result
+		res, out, err = importCsv(in, c.specCfg, c.flagMerge)
+		if res != nil {
+			tms, warnings, changes = res.Threatmodels, res.Warnings, res.Changes
+		}
+	default:
+		err = fmt.Errorf("Incorrect -format option")
+	}
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+
+	if out == "" {
+		out, err = importedHCL(c.specCfg, tms)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			return 1
+		}
+	}
+
+	if c.flagOutput == "" {
+		fmt.Print(out)
+		if c.flagFormat == "csv" {
+			fmt.Fprint(os.Stderr, changeReport(changes))
+		}
+		return 0
+	}
+
+	if err := writeStringToFile(c.flagOutput, out); err != nil {
+		fmt.Printf("Error writing output to %s: %s\n", c.flagOutput, err)
+		return 1
+	}
+	fmt.Printf("Successfully wrote %d threatmodel(s) to '%s'\n", len(tms), c.flagOutput)
+	if c.flagFormat == "csv" {
+		fmt.Print(changeReport(changes))
+	}
+	return 0
+}
+
+// importOtm converts every OTM project in data into a threat model. Warnings
+// are prefixed with the project they came from.
+func importOtm(data []byte, cfg *spec.ThreatmodelSpecConfig) ([]*spec.Threatmodel, []string, error) {
+	docs, err := otmconv.Parse(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := otmconv.Options{Stride: cfg.STRIDE, ImpactTypes: cfg.ImpactTypes}
+
+	tms := []*spec.Threatmodel{}
+	warnings := []string{}
+	for _, doc := range docs {
+		res := otmconv.Import(doc, opts)
+		tms = append(tms, res.Threatmodel)
+		for _, w := range res.Warnings {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", doc.Project.Name, w))
+		}
+	}
+	return tms, warnings, nil
+}
+
+// importTm7 converts a Threat Modeling Tool file into a threat model, named
+// after the file if the model has no name of its own.
+func importTm7(data []byte, cfg *spec.ThreatmodelSpecConfig, file string) ([]*spec.Threatmodel, []string, error) {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+
+	res, err := tm7.Import(data, tm7.Options{Name: name, Stride: cfg.STRIDE})
+	if err != nil {
+		return nil, nil, err
+	}
+	return []*spec.Threatmodel{res.Threatmodel}, res.Warnings, nil
+}
+
+// importThreatDragon converts every Threat Dragon model in data into a threat
+// model. Warnings are prefixed with the model they came from.
+func importThreatDragon(data []byte, cfg *spec.ThreatmodelSpecConfig) ([]*spec.Threatmodel, []string, error) {
+	models, err := threatdragon.Parse(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := threatdragon.Options{Stride: cfg.STRIDE, ImpactTypes: cfg.ImpactTypes}
+
+	tms := []*spec.Threatmodel{}
+	warnings := []string{}
+	for _, m := range models {
+		res := threatdragon.Import(m, opts)
+		tms = append(tms, res.Threatmodel)
+		for _, w := range res.Warnings {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", m.Summary.Title, w))
+		}
+	}
+	return tms, warnings, nil
+}
+
+// importCsv reads a threat register. Without a merge file it builds new
+// threat models from the rows, for importedHCL to render. With one, the rows
+// are merged into the models parsed from it and the updated file is returned
+// as HCL.
+func importCsv(data []byte, cfg *spec.ThreatmodelSpecConfig, merge string) (*register.Result, string, error) {
+	rows, err := register.Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	opts := register.Options{Stride: cfg.STRIDE, ImpactTypes: cfg.ImpactTypes}
+
+	if merge == "" {
+		return register.Merge(nil, rows, opts), "", nil
+	}
+
+	src, err := os.ReadFile(merge)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error reading %s: %s", merge, err)
+	}
+
+	parser := spec.NewThreatmodelParser(cfg)
+	if err := parser.ParseFile(merge, false); err != nil {
+		return nil, "", fmt.Errorf("Error parsing %s: %s", merge, err)
+	}
+	wrapped := parser.GetWrapped()
+	tms := []*spec.Threatmodel{}
+	for i := range wrapped.Threatmodels {
+		tms = append(tms, &wrapped.Threatmodels[i])
+	}
+
+	res := register.Merge(tms, rows, opts)
+	out, warnings, err := register.Apply(src, merge, res)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error updating %s: %s", merge, err)
+	}
+	res.Warnings = append(res.Warnings, warnings...)
+	return res, string(out), nil
+}
+
+// changeReport lists the changes a register import made.
+func changeReport(changes []register.Change) string {
+	if len(changes) == 0 {
+		return "No changes\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d change(s):\n", len(changes))
+	for _, c := range changes {
+		fmt.Fprintf(&b, "  %s\n", c)
+	}
+	return b.String()
+}
+
+// importedHCL renders imported threat models as a single HCL file.
+// AddTMAndWrite writes everything added to the parser so far, so only the
+// last write holds every model.
+func importedHCL(cfg *spec.ThreatmodelSpecConfig, tms []*spec.Threatmodel) (string, error) {
+	if len(tms) == 0 {
+		return "", fmt.Errorf("No threatmodels found to import")
+	}
+
+	parser := spec.NewThreatmodelParser(cfg)
+	var buf bytes.Buffer
+	for i, tm := range tms {
+		var w io.Writer = io.Discard
+		if i == len(tms)-1 {
+			w = &buf
+		}
+		if err := parser.AddTMAndWrite(*tm, w, false); err != nil {
+			return "", fmt.Errorf("Error writing HCL: %s", err)
+		}
+	}
+	return buf.String(), nil
+}
+
+// Synopsis returns the synopsis for the "threatcl import" command
+func (c *ImportCommand) Synopsis() string {
+	return "Import threat models from other formats into HCL"
+}
+
+func (c *ImportCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictOr(predictJSON, complete.PredictFiles("*.tm7"), complete.PredictFiles("*.csv"))
+}
+func (c *ImportCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-config":    predictHCL,
+		"-format":    complete.PredictSet("otm", "tm7", "threatdragon", "csv"),
+		"-merge":     predictHCL,
+		"-output":    complete.PredictFiles("*.hcl"),
+		"-overwrite": complete.PredictNothing,
+	}
+}
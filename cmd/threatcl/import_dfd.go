@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/posener/complete"
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/dfd"
+	"github.com/threatcl/threatcl/internal/tmloader"
+)
+
+// ImportDfdCommand struct defines the "threatcl import-dfd" command
+type ImportDfdCommand struct {
+	*GlobalCmdOptions
+	specCfg       *spec.ThreatmodelSpecConfig
+	flagFormat    string
+	flagOutput    string
+	flagOverwrite bool
+}
+
+// Help is the help output for "threatcl import-dfd"
+func (c *ImportDfdCommand) Help() string {
+	helpText := `
+Usage: threatcl import-dfd [options] <file>
+
+  Convert a draw.io diagram or a mermaid flowchart into data_flow_diagram_v2
+  blocks
+
+  drawio: a diagrams.net file (.drawio or .xml). Each page becomes a
+  diagram. Ellipses become processes, cylinders and open-ended boxes data
+  stores, and actors external elements. Plain and rounded rectangles are
+  only imported if 'threatcl dfd -format=drawio' wrote them. Set a
+  threatcl=process|data_store|external_element style key (or a
+  threatcl_type property) on a shape to choose its kind. Containers become
+  trust zones.
+
+  mermaid: a flowchart in a .mmd file, the mermaid fences of a markdown
+  file, or the mermaid blocks of threat model HCL files. Circles become
+  processes and cylinders data stores, unless the node has a process,
+  data_store or external_element class. Other nodes, including plain and
+  rounded rectangles, need a class. Subgraphs become trust zones.
+
+  Edges become flows named after their label; a trailing "(protocol)" in a
+  label sets the flow's protocol. Shapes that don't clearly map onto an
+  element are skipped rather than guessed, and listed in a mapping report
+  on STDERR.
+
+Options:
+
+ -config=<file>
+   Optional config file
+
+ -format=<drawio|mermaid>
+   Format of the input file. Defaults to drawio for .drawio and .xml files,
+   and mermaid otherwise
+
+ -output=<file>
+   Optional filename to write the HCL to. If not set, will output to STDOUT
+
+ -overwrite
+   Overwrite the output file if it exists
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run executes "threatcl import-dfd" logic
+func (c *ImportDfdCommand) Run(args []string) int {
+	flagSet := c.GetFlagset("import-dfd")
+	flagSet.StringVar(&c.flagFormat, "format", "", "Format of the input file, drawio or mermaid")
+	flagSet.StringVar(&c.flagOutput, "output", "", "Name of output file. If not set, will output to STDOUT")
+	flagSet.BoolVar(&c.flagOverwrite, "overwrite", false, "Overwrite existing file. Defaults to false")
+	parseFlags(flagSet, args)
+
+	if c.flagConfig != "" {
+		err := c.specCfg.LoadSpecConfigFile(c.flagConfig)
+
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			return 1
+		}
+	}
+
+	if len(flagSet.Args()) != 1 {
+		fmt.Printf("Please provide a single file to import\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+	file := flagSet.Args()[0]
+
+	format := c.flagFormat
+	if format == "" {
+		format = dfdImportFormat(file)
+	}
+
+	if c.flagOutput != "" {
+		if err := fileExistenceCheck([]string{c.flagOutput}, c.flagOverwrite); err != nil {
+			fmt.Printf("%s\n", err)
+			return 1
+		}
+	}
+
+	var graphs []*dfd.Graph
+	var report dfd.Report
+	var err error
+
+	switch format {
+	case "drawio":
+		graphs, report, err = importDrawio(file)
+	case "mermaid":
+		graphs, report, err = c.importMermaid(file)
+	default:
+		err = fmt.Errorf("-format must be drawio or mermaid")
+	}
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+	if len(graphs) == 0 {
+		fmt.Printf("No diagrams found in %s\n", file)
+		return 1
+	}
+
+	out := string(dfd.HCL(graphs))
+
+	if c.flagOutput == "" {
+		fmt.Print(out)
+		fmt.Fprint(os.Stderr, report)
+		return 0
+	}
+
+	if err := writeStringToFile(c.flagOutput, out); err != nil {
+		fmt.Printf("Error writing output to %s: %s\n", c.flagOutput, err)
+		return 1
+	}
+	fmt.Printf("Successfully wrote %d diagram(s) to '%s'\n", len(graphs), c.flagOutput)
+	fmt.Print(report)
+	return 0
+}
+
+// dfdImportFormat guesses the format of file from its extension.
+func dfdImportFormat(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".drawio", ".xml":
+		return "drawio"
+	}
+	return "mermaid"
+}
+
+func importDrawio(file string) ([]*dfd.Graph, dfd.Report, error) {
+	in, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error reading %s: %s", file, err)
+	}
+	return dfd.ParseDrawio(in)
+}
+
+// importMermaid reads the flowcharts in file. Threat model files contribute
+// their mermaid blocks that are flowcharts, named after the block; other
+// files are read as mermaid or markdown, named after the file.
+func (c *ImportDfdCommand) importMermaid(file string) ([]*dfd.Graph, dfd.Report, error) {
+	type source struct{ name, content string }
+	sources := []source{}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".hcl", ".json":
+		res, err := tmloader.LoadSet(c.specCfg, []string{file})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, lm := range res.Models {
+			for _, m := range lm.TM.MermaidDiagrams {
+				if dfd.IsMermaidFlowchart(m.Content) {
+					sources = append(sources, source{m.Name, m.Content})
+				}
+			}
+		}
+	default:
+		in, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error reading %s: %s", file, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		blocks := dfd.MermaidBlocks(string(in))
+		for i, b := range blocks {
+			if len(blocks) > 1 {
+				if !dfd.IsMermaidFlowchart(b) {
+					continue
+				}
+				sources = append(sources, source{fmt.Sprintf("%s %d", name, i+1), b})
+				continue
+			}
+			sources = append(sources, source{name, b})
+		}
+	}
+
+	graphs := []*dfd.Graph{}
+	report := dfd.Report{}
+	for _, s := range sources {
+		g, r, err := dfd.ParseMermaid(s.name, s.content)
+		if err != nil {
+			return nil, nil, err
+		}
+		graphs = append(graphs, g)
+		report = append(report, r...)
+	}
+	return graphs, report, nil
+}
+
+func (c *ImportDfdCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictOr(
+		complete.PredictFiles("*.drawio"),
+		complete.PredictFiles("*.xml"),
+		complete.PredictFiles("*.mmd"),
+		complete.PredictFiles("*.md"),
+		predictHCL,
+	)
+}
+func (c *ImportDfdCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-config":    predictHCL,
+		"-format":    complete.PredictSet("drawio", "mermaid"),
+		"-output":    complete.PredictFiles("*.hcl"),
+		"-overwrite": complete.PredictNothing,
+	}
+}
+
+// Synopsis returns the synopsis for the "threatcl import-dfd" command
+func (c *ImportDfdCommand) Synopsis() string {
+	return "Import data flow diagrams from draw.io or mermaid flowcharts"
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+
+	"github.com/zenizh/go-capturer"
+)
+
+func testImportDfdCommand(tb testing.TB) *ImportDfdCommand {
+	tb.Helper()
+
+	d, err := os.MkdirTemp("", "")
+	if err != nil {
+		tb.Fatalf("Error creating tmp dir: %s", err)
+	}
+
+	_ = os.Setenv("HOME", d)
+	_ = os.Setenv("USERPROFILE", d)
+
+	cfg, _ := spec.LoadSpecConfig()
+
+	defer os.RemoveAll(d)
+
+	global := &GlobalCmdOptions{}
+
+	return &ImportDfdCommand{
+		GlobalCmdOptions: global,
+		specCfg:          cfg,
+	}
+}
+
+func TestImportDfdNoArgs(t *testing.T) {
+	cmd := testImportDfdCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+
+	if !strings.Contains(out, "Please provide a single file to import") {
+		t.Errorf("Expected %s to contain %s", out, "Please provide a single file to import")
+	}
+}
+
+func TestImportDfdRun(t *testing.T) {
+	dir := t.TempDir()
+
+	drawioFile := filepath.Join(dir, "shop.drawio")
+	err := os.WriteFile(drawioFile, []byte(`<mxfile>
+  <diagram name="Level 0">
+    <mxGraphModel><root>
+      <mxCell id="0" />
+      <mxCell id="1" parent="0" />
+      <mxCell id="u" value="User" style="shape=umlActor;" vertex="1" parent="1" />
+      <mxCell id="z" value="Internal" style="swimlane;container=1;" vertex="1" parent="1" />
+      <mxCell id="w" value="Web" style="ellipse;" vertex="1" parent="z" />
+      <mxCell id="d" value="Decide" style="rhombus;" vertex="1" parent="z" />
+      <mxCell id="e" value="Browse (https)" edge="1" parent="1" source="u" target="w" />
+    </root></mxGraphModel>
+  </diagram>
+</mxfile>`), 0600)
+	if err != nil {
+		t.Fatalf("Error writing drawio: %s", err)
+	}
+
+	mdFile := filepath.Join(dir, "design.md")
+	err = os.WriteFile(mdFile, []byte("# Design\n\n```mermaid\nflowchart LR\n  u[User]:::external_element -->|Browse| w((Web))\n  w --> db[(DB)]\n  w --> box[Box]\n```\n"), 0600)
+	if err != nil {
+		t.Fatalf("Error writing markdown: %s", err)
+	}
+
+	cases := []struct {
+		name   string
+		args   []string
+		exp    []string
+		stdout []string
+	}{
+		{
+			"drawio",
+			[]string{drawioFile},
+			[]string{
+				`data_flow_diagram_v2 "Level 0" {`,
+				`external_element "User" {`,
+				`trust_zone "Internal" {`,
+				`process "Web" {`,
+				`protocol = "https"`,
+			},
+			[]string{"Mapping report:", `"Decide" (rhombus)`},
+		},
+		{
+			"mermaid",
+			[]string{"-format=mermaid", mdFile},
+			[]string{
+				`data_flow_diagram_v2 "design" {`,
+				`data_store "DB" {`,
+				`flow "Browse" {`,
+			},
+			[]string{`design: "Web" (circle) -> process`, `design: "Box" (rectangle): could be any kind of element`},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			outFile := filepath.Join(dir, tc.name+".hcl")
+
+			cmd := testImportDfdCommand(t)
+			var code int
+			out := capturer.CaptureStdout(func() {
+				code = cmd.Run(append([]string{"-output=" + outFile}, tc.args...))
+			})
+			if code != 0 {
+				t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+			}
+			if !strings.Contains(out, "Successfully wrote 1 diagram(s)") {
+				t.Errorf("Expected success, got %s", out)
+			}
+			for _, e := range tc.stdout {
+				if !strings.Contains(out, e) {
+					t.Errorf("Expected %s to contain %s", out, e)
+				}
+			}
+
+			hcl, err := os.ReadFile(outFile)
+			if err != nil {
+				t.Fatalf("Error reading output: %s", err)
+			}
+			for _, e := range tc.exp {
+				if !strings.Contains(string(hcl), e) {
+					t.Errorf("Expected %s to contain %s", hcl, e)
+				}
+			}
+		})
+	}
+}
+
+func TestImportDfdInvalidFormat(t *testing.T) {
+	cmd := testImportDfdCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-format=visio", "in.vsdx"})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+
+	if !strings.Contains(out, "-format must be drawio or mermaid") {
+		t.Errorf("Expected %s to contain %s", out, "-format must be drawio or mermaid")
+	}
+}
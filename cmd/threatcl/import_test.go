@@ -0,0 +1,474 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+
+	"github.com/zenizh/go-capturer"
+)
+
+func testImportCommand(tb testing.TB) *ImportCommand {
+	tb.Helper()
+
+	d, err := os.MkdirTemp("", "")
+	if err != nil {
+		tb.Fatalf("Error creating tmp dir: %s", err)
+	}
+
+	_ = os.Setenv("HOME", d)
+	_ = os.Setenv("USERPROFILE", d)
+
+	cfg, _ := spec.LoadSpecConfig()
+
+	defer os.RemoveAll(d)
+
+	global := &GlobalCmdOptions{}
+
+	return &ImportCommand{
+		GlobalCmdOptions: global,
+		specCfg:          cfg,
+	}
+}
+
+func TestImportNoArgs(t *testing.T) {
+	cmd := testImportCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+
+	if !strings.Contains(out, "Please provide a single file to import") {
+		t.Errorf("Expected %s to contain %s", out, "Please provide a single file to import")
+	}
+}
+
+func TestImportRun(t *testing.T) {
+	dir := t.TempDir()
+
+	otmFile := filepath.Join(dir, "in.otm.json")
+	err := os.WriteFile(otmFile, []byte(`{
+  "otmVersion": "0.2.0",
+  "project": {"id": "shop", "name": "Shop", "owner": "@alice", "description": "An online shop"},
+  "trustZones": [{"id": "dc", "name": "Data Centre", "risk": {"trustRating": 90}}],
+  "components": [
+    {"id": "web", "name": "Web", "type": "web-service", "parent": {"trustZone": "dc"},
+     "threats": [{"threat": "sqli", "state": "exposed", "mitigations": [{"mitigation": "params", "state": "implemented"}]}]},
+    {"id": "db", "name": "Orders DB", "type": "postgresql", "parent": {"trustZone": "dc"}}
+  ],
+  "dataflows": [{"id": "f1", "name": "query", "source": "web", "destination": "db", "attributes": {"protocol": "TLS"}}],
+  "threats": [{"id": "sqli", "name": "SQL injection", "description": "Attacker injects SQL", "categories": ["Tampering", "CWE-89"], "risk": {"impact": 0, "likelihood": null}}],
+  "mitigations": [{"id": "params", "name": "Parameterised queries", "description": "Use bind params", "riskReduction": 80}]
+}`), 0600)
+	if err != nil {
+		t.Fatalf("Error writing otm: %s", err)
+	}
+
+	tm7File := filepath.Join(dir, "shop.tm7")
+	err = os.WriteFile(tm7File, []byte(`<ThreatModel xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.Model">
+  <DrawingSurfaceList>
+    <DrawingSurfaceModel>
+      <Header>Level 0</Header>
+    </DrawingSurfaceModel>
+  </DrawingSurfaceList>
+  <MetaInformation><Owner>@alice</Owner></MetaInformation>
+</ThreatModel>`), 0600)
+	if err != nil {
+		t.Fatalf("Error writing tm7: %s", err)
+	}
+
+	tdFile := filepath.Join(dir, "shop.json")
+	err = os.WriteFile(tdFile, []byte(`{
+  "version": "2.2.0",
+  "summary": {"title": "Dragon Shop", "owner": "@alice"},
+  "detail": {"diagrams": [{"id": 0, "title": "Level 0", "cells": [
+    {"id": "p", "shape": "process", "data": {"type": "tm.Process", "name": "Web",
+     "threats": [{"id": "t1", "number": 1, "title": "Spoofed session", "status": "Mitigated", "type": "Spoofing", "mitigation": "- [x] Short lived tokens"}]}}
+  ]}]}
+}`), 0600)
+	if err != nil {
+		t.Fatalf("Error writing threat dragon: %s", err)
+	}
+
+	csvFile := filepath.Join(dir, "register.csv")
+	err = os.WriteFile(csvFile, []byte("model,threat,control,implemented\nShop,SQL injection,Parameterised queries,true\n"), 0600)
+	if err != nil {
+		t.Fatalf("Error writing csv: %s", err)
+	}
+
+	cases := []struct {
+		name      string
+		args      []string
+		exp       string
+		invertexp bool
+		code      int
+	}{
+		{
+			"threatmodel",
+			[]string{"-format=otm", otmFile},
+			`threatmodel "Shop"`,
+			false,
+			0,
+		},
+		{
+			"dfd",
+			[]string{"-format=otm", otmFile},
+			`data_flow_diagram_v2 "Shop"`,
+			false,
+			0,
+		},
+		{
+			"control",
+			[]string{otmFile},
+			`control "Parameterised queries"`,
+			false,
+			0,
+		},
+		{
+			"tm7",
+			[]string{"-format=tm7", tm7File},
+			`threatmodel "shop"`,
+			false,
+			0,
+		},
+		{
+			"tm7_dfd",
+			[]string{"-format=tm7", tm7File},
+			`data_flow_diagram_v2 "Level 0"`,
+			false,
+			0,
+		},
+		{
+			"tm7_invalid",
+			[]string{"-format=tm7", otmFile},
+			"error parsing tm7",
+			false,
+			1,
+		},
+		{
+			"threatdragon",
+			[]string{"-format=threatdragon", tdFile},
+			`threatmodel "Dragon Shop"`,
+			false,
+			0,
+		},
+		{
+			"threatdragon_control",
+			[]string{"-format=threatdragon", tdFile},
+			`control "Short lived tokens"`,
+			false,
+			0,
+		},
+		{
+			"csv",
+			[]string{"-format=csv", csvFile},
+			`control "Parameterised queries"`,
+			false,
+			0,
+		},
+		{
+			"bad_format",
+			[]string{"-format=tm9", otmFile},
+			"Incorrect -format option",
+			false,
+			1,
+		},
+		{
+			"missing_file",
+			[]string{filepath.Join(dir, "nope.json")},
+			"Error reading",
+			false,
+			1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := testImportCommand(t)
+
+			var code int
+			out := capturer.CaptureStdout(func() {
+				code = cmd.Run(tc.args)
+			})
+
+			if code != tc.code {
+				t.Errorf("Code did not equal %d: %d\n%s", tc.code, code, out)
+			}
+
+			if !tc.invertexp {
+				if !strings.Contains(out, tc.exp) {
+					t.Errorf("Expected %s to contain %s", out, tc.exp)
+				}
+			} else {
+				if strings.Contains(out, tc.exp) {
+					t.Errorf("Was not expecting %s to contain %s", out, tc.exp)
+				}
+			}
+		})
+	}
+}
+
+func TestImportWarnings(t *testing.T) {
+	dir := t.TempDir()
+	otmFile := filepath.Join(dir, "in.otm.json")
+	err := os.WriteFile(otmFile, []byte(`{
+  "otmVersion": "0.2.0",
+  "project": {"id": "shop", "name": "Shop"},
+  "threats": [{"id": "t", "name": "T", "categories": ["CWE-89"], "risk": {"impact": 0, "likelihood": null}}]
+}`), 0600)
+	if err != nil {
+		t.Fatalf("Error writing otm: %s", err)
+	}
+
+	cmd := testImportCommand(t)
+
+	var code int
+	out := capturer.CaptureStderr(func() {
+		code = cmd.Run([]string{"-output=" + filepath.Join(dir, "out.hcl"), otmFile})
+	})
+
+	if code != 0 {
+		t.Errorf("Code did not equal 0: %d", code)
+	}
+
+	for _, exp := range []string{
+		`Warning: Shop: project "Shop" has no owner`,
+		`Warning: Shop: threat "T": category "CWE-89"`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Expected %s to contain %s", out, exp)
+		}
+	}
+}
+
+func TestImportCsvMerge(t *testing.T) {
+	dir := t.TempDir()
+
+	hclFile := filepath.Join(dir, "shop.hcl")
+	err := os.WriteFile(hclFile, []byte(`spec_version = "0.7.0"
+
+// Our shop
+threatmodel "Shop" {
+  author = "@alice"
+
+  threat "SQL injection" {
+    description = "Attacker injects SQL"
+
+    control "WAF" {
+      implemented = false
+      description = "Filter requests"
+    }
+  }
+}
+`), 0600)
+	if err != nil {
+		t.Fatalf("Error writing hcl: %s", err)
+	}
+
+	csvFile := filepath.Join(dir, "register.csv")
+	err = os.WriteFile(csvFile, []byte(`model,threat,control,implemented,risk_reduction
+Shop,SQL injection,WAF,true,30
+Shop,Flooding,Rate limit,false,50
+`), 0600)
+	if err != nil {
+		t.Fatalf("Error writing csv: %s", err)
+	}
+
+	outFile := filepath.Join(dir, "merged.hcl")
+	cmd := testImportCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-format=csv", "-merge=" + hclFile, "-output=" + outFile, csvFile})
+	})
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	for _, exp := range []string{
+		"3 change(s):",
+		`threatmodel "Shop": threat "SQL injection" > control "WAF" > implemented: "false" -> "true"`,
+		`threatmodel "Shop": threat "Flooding" added`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Expected %s to contain %s", out, exp)
+		}
+	}
+
+	merged, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("Error reading merged file: %s", err)
+	}
+	for _, exp := range []string{"// Our shop", `threat "Flooding"`, "risk_reduction = 30"} {
+		if !strings.Contains(string(merged), exp) {
+			t.Errorf("Expected merged file to contain %s:\n%s", exp, merged)
+		}
+	}
+
+	// The merged file still parses.
+	parseRoundTripFile(t, cmd.specCfg, outFile)
+
+	cmd = testImportCommand(t)
+	out = capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-format=otm", "-merge=" + hclFile, csvFile})
+	})
+	if code != 1 || !strings.Contains(out, "-merge is only supported with -format=csv") {
+		t.Errorf("Expected -merge to be refused for otm: %d %s", code, out)
+	}
+}
+
+// TestImportOtmRoundTrip exports our own fixtures to OTM, imports them again
+// and checks the models survive.
+func TestImportOtmRoundTrip(t *testing.T) {
+	for _, fixture := range []string{"./testdata/tm1.hcl", "./testdata/tm5.hcl", "./testdata/tm_mermaid.hcl"} {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			dir := t.TempDir()
+			otmFile := filepath.Join(dir, "out.otm.json")
+			hclFile := filepath.Join(dir, "imported.hcl")
+
+			ecmd := testExportCommand(t)
+			var code int
+			out := capturer.CaptureStdout(func() {
+				code = ecmd.Run([]string{"-format=otm", "-output=" + otmFile, fixture})
+			})
+			if code != 0 {
+				t.Fatalf("export failed: %s", out)
+			}
+
+			icmd := testImportCommand(t)
+			out = capturer.CaptureStdout(func() {
+				code = icmd.Run([]string{"-format=otm", "-output=" + hclFile, otmFile})
+			})
+			if code != 0 {
+				t.Fatalf("import failed: %s", out)
+			}
+
+			orig := parseRoundTripFile(t, icmd.specCfg, fixture)
+			imported := parseRoundTripFile(t, icmd.specCfg, hclFile)
+
+			if len(orig) != len(imported) {
+				t.Fatalf("expected %d threatmodels, got %d", len(orig), len(imported))
+			}
+
+			for i := range orig {
+				compareRoundTrip(t, orig[i], imported[i])
+			}
+		})
+	}
+}
+
+func parseRoundTripFile(tb testing.TB, cfg *spec.ThreatmodelSpecConfig, path string) []spec.Threatmodel {
+	tb.Helper()
+
+	p := spec.NewThreatmodelParser(cfg)
+	if err := p.ParseFile(path, false); err != nil {
+		tb.Fatalf("Error parsing %s: %s", path, err)
+	}
+	return p.GetWrapped().Threatmodels
+}
+
+func compareRoundTrip(tb testing.TB, orig, imported spec.Threatmodel) {
+	tb.Helper()
+
+	if orig.Name != imported.Name || orig.Author != imported.Author {
+		tb.Errorf("name/author: expected %q/%q, got %q/%q", orig.Name, orig.Author, imported.Name, imported.Author)
+	}
+	if strings.TrimSpace(orig.Description) != strings.TrimSpace(imported.Description) {
+		tb.Errorf("%s: description changed:\n%q\n%q", orig.Name, orig.Description, imported.Description)
+	}
+
+	if orig.Attributes != nil {
+		if imported.Attributes == nil || *orig.Attributes != *imported.Attributes {
+			tb.Errorf("%s: attributes changed: %+v, %+v", orig.Name, orig.Attributes, imported.Attributes)
+		}
+	}
+
+	for _, aa := range orig.AdditionalAttributes {
+		found := false
+		for _, iaa := range imported.AdditionalAttributes {
+			found = found || (iaa.Name == aa.Name && iaa.Value == aa.Value)
+		}
+		if !found {
+			tb.Errorf("%s: additional_attribute %q was lost", orig.Name, aa.Name)
+		}
+	}
+
+	if len(orig.InformationAssets) != len(imported.InformationAssets) {
+		tb.Errorf("%s: expected %d information assets, got %d", orig.Name, len(orig.InformationAssets), len(imported.InformationAssets))
+	} else {
+		for i, ia := range orig.InformationAssets {
+			if ia.Name != imported.InformationAssets[i].Name || ia.Description != imported.InformationAssets[i].Description {
+				tb.Errorf("%s: information asset %q changed", orig.Name, ia.Name)
+			}
+		}
+	}
+
+	if len(orig.Threats) != len(imported.Threats) {
+		tb.Fatalf("%s: expected %d threats, got %d", orig.Name, len(orig.Threats), len(imported.Threats))
+	}
+	for i, t := range orig.Threats {
+		it := imported.Threats[i]
+		if t.Name != it.Name || strings.TrimSpace(t.Description) != strings.TrimSpace(it.Description) || t.Control != it.Control {
+			tb.Errorf("%s: threat %q changed", orig.Name, t.Name)
+		}
+		if len(t.Stride) != len(it.Stride) || len(t.ImpactType) != len(it.ImpactType) {
+			tb.Errorf("%s: threat %q stride/impacts changed: %v/%v, %v/%v", orig.Name, t.Name, t.Stride, t.ImpactType, it.Stride, it.ImpactType)
+		}
+		if (t.Risk == nil) != (it.Risk == nil) || (t.Risk != nil && (t.Risk.Likelihood != it.Risk.Likelihood || t.Risk.Impact != it.Risk.Impact)) {
+			tb.Errorf("%s: threat %q risk changed", orig.Name, t.Name)
+		}
+		if len(t.Controls) != len(it.Controls) {
+			tb.Errorf("%s: threat %q expected %d controls, got %d", orig.Name, t.Name, len(t.Controls), len(it.Controls))
+			continue
+		}
+		for j, c := range t.Controls {
+			ic := it.Controls[j]
+			if c.Name != ic.Name || c.Implemented != ic.Implemented || c.RiskReduction != ic.RiskReduction || c.ImplementationNotes != ic.ImplementationNotes {
+				tb.Errorf("%s: control %q changed: %+v, %+v", orig.Name, c.Name, c, ic)
+			}
+		}
+	}
+
+	if len(orig.DataFlowDiagrams) != len(imported.DataFlowDiagrams) {
+		tb.Errorf("%s: expected %d data flow diagrams, got %d", orig.Name, len(orig.DataFlowDiagrams), len(imported.DataFlowDiagrams))
+	} else {
+		for i, d := range orig.DataFlowDiagrams {
+			id := imported.DataFlowDiagrams[i]
+			if dfdElementCount(d) != dfdElementCount(id) || len(d.Flows) != len(id.Flows) {
+				tb.Errorf("%s: data flow diagram %q changed", orig.Name, d.Name)
+				continue
+			}
+			for j, f := range d.Flows {
+				if f.From != id.Flows[j].From || f.To != id.Flows[j].To || f.Protocol != id.Flows[j].Protocol {
+					tb.Errorf("%s: flow %q changed", orig.Name, f.Name)
+				}
+			}
+		}
+	}
+
+	if len(orig.MermaidDiagrams) != len(imported.MermaidDiagrams) {
+		tb.Errorf("%s: expected %d mermaid diagrams, got %d", orig.Name, len(orig.MermaidDiagrams), len(imported.MermaidDiagrams))
+	} else {
+		for i, m := range orig.MermaidDiagrams {
+			if m.Name != imported.MermaidDiagrams[i].Name || strings.TrimSpace(m.Content) != strings.TrimSpace(imported.MermaidDiagrams[i].Content) {
+				tb.Errorf("%s: mermaid %q changed", orig.Name, m.Name)
+			}
+		}
+	}
+}
+
+func dfdElementCount(d *spec.DataFlowDiagram) int {
+	n := len(d.Processes) + len(d.DataStores) + len(d.ExternalElements)
+	for _, tz := range d.TrustZones {
+		n += len(tz.Processes) + len(tz.DataStores) + len(tz.ExternalElements)
+	}
+	return n
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/posener/complete"
 	"github.com/ryanuber/columnize"
 	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/cadence"
 	"github.com/threatcl/threatcl/internal/tmloader"
 )
 
@@ -15,6 +17,7 @@ type ListCommand struct {
 	specCfg      *spec.ThreatmodelSpecConfig
 	flagFields   string
 	flagNoHeader bool
+	flagCadence  string
 }
 
 func (c *ListCommand) Help() string {
@@ -31,9 +34,14 @@ Options:
  -fields=<fields>
    Comma-separated list of fields to list. Fields include 'number', 'file',
    'threatmodel', 'assetcount', 'threatcount', 'usecasecount', 'tpdcount', 'exclusioncount', 'size', 'internetfacing',
-   'newinitiative', 'dfd', 'repository', 'riskcount', 'highestseverity' and 'author'.
+   'newinitiative', 'dfd', 'repository', 'riskcount', 'highestseverity',
+   'lastreviewed', 'due' and 'author'.
    If not set, defaults to 'number,file,threatmodel,author'
 
+ -cadence=<file>
+   Optional HCL review-cadence policy file used by the 'lastreviewed' and
+   'due' fields. See 'threatcl stale -h'
+
  -noheader
    If set, will not print the header
 
@@ -61,6 +69,8 @@ func (c *ListCommand) Execute(args []string) ([]string, error) {
 		"Repository",
 		"RiskCount",
 		"HighestSeverity",
+		"LastReviewed",
+		"Due",
 	}
 
 	flagFields := []string{}
@@ -113,6 +123,8 @@ func (c *ListCommand) Execute(args []string) ([]string, error) {
 				headerString = headerString + "Risk Count"
 			case "HighestSeverity":
 				headerString = headerString + "Highest Severity"
+			case "LastReviewed":
+				headerString = headerString + "Last Reviewed"
 			default:
 				headerString = headerString + flagField
 			}
@@ -129,6 +141,13 @@ func (c *ListCommand) Execute(args []string) ([]string, error) {
 		return nil, err
 	}
 
+	policy, err := loadCadencePolicy(c.flagCadence)
+	if err != nil {
+		return nil, err
+	}
+	sources := cadence.NewSources()
+	now := time.Now()
+
 	for _, lm := range res.Models {
 		file := lm.File
 		tm := lm.TM
@@ -188,6 +207,12 @@ func (c *ListCommand) Execute(args []string) ([]string, error) {
 					bodyString = bodyString + fmt.Sprintf("%d", countThreatsWithRisk(*tm))
 				case "HighestSeverity":
 					bodyString = bodyString + highestInherentSeverity(*tm)
+				case "LastReviewed":
+					st := policy.Evaluate(tm, reviewEvidence(sources, lm), now)
+					bodyString = bodyString + cadence.FormatDate(st.LastReviewed)
+				case "Due":
+					st := policy.Evaluate(tm, reviewEvidence(sources, lm), now)
+					bodyString = bodyString + dueString(st)
 				}
 			}
 
@@ -235,8 +260,9 @@ func highestInherentSeverity(tm spec.Threatmodel) string {
 func (c *ListCommand) Run(args []string) int {
 
 	flagSet := c.GetFlagset("list")
-	flagSet.StringVar(&c.flagFields, "fields", "", "Comma-separated list of fields for list. Fields include 'number', 'file', 'threatmodel', 'assetcount', 'threatcount', 'usecasecount','tpdcount', 'exclusioncount', 'size', 'internetfacing', 'newinitiative', 'dfd', 'repository', 'riskcount', 'highestseverity' and 'author'.")
+	flagSet.StringVar(&c.flagFields, "fields", "", "Comma-separated list of fields for list. Fields include 'number', 'file', 'threatmodel', 'assetcount', 'threatcount', 'usecasecount','tpdcount', 'exclusioncount', 'size', 'internetfacing', 'newinitiative', 'dfd', 'repository', 'riskcount', 'highestseverity', 'lastreviewed', 'due' and 'author'.")
 	flagSet.BoolVar(&c.flagNoHeader, "noheader", false, "If set, will not print the header")
+	flagSet.StringVar(&c.flagCadence, "cadence", "", "Optional HCL review-cadence policy file for the lastreviewed and due fields")
 	parseFlags(flagSet, args)
 
 	if c.flagConfig != "" {
@@ -272,6 +298,7 @@ func (c *ListCommand) Synopsis() string {
 func (c *ListCommand) AutocompleteArgs() complete.Predictor { return predictHCLOrJSON }
 func (c *ListCommand) AutocompleteFlags() complete.Flags {
 	return complete.Flags{
-		"-config": predictHCL,
+		"-config":  predictHCL,
+		"-cadence": predictHCL,
 	}
 }
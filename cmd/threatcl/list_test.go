@@ -174,6 +174,14 @@ func TestListRun(t *testing.T) {
 			0,
 			"-fields=dfd",
 		},
+		{
+			"last_reviewed_header",
+			"./testdata/tm1.hcl",
+			"Last Reviewed",
+			false,
+			0,
+			"-fields=threatmodel,lastreviewed,due",
+		},
 	}
 
 	for _, tc := range cases {
@@ -1,19 +1,31 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/threatcl/go-otm/pkg/otm"
 	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/attack"
+	"github.com/threatcl/threatcl/internal/docx"
+	"github.com/threatcl/threatcl/internal/oscal"
+	"github.com/threatcl/threatcl/internal/otmconv"
+	"github.com/threatcl/threatcl/internal/register"
+	"github.com/threatcl/threatcl/internal/stix"
+	"github.com/threatcl/threatcl/internal/threatdragon"
 )
 
 // renderThreatmodels renders the supplied threat models into the requested
 // output format. parser is required for the "hcl" format because HclString
 // encodes from parser state (including spec_version, components, variables,
 // and any backend blocks left in wrapped). templatePath, if non-empty, is read
-// as a markdown template for the "md" format.
+// as a markdown template for the "md" format, or as a reference .docx for the
+// "docx" format.
 func renderThreatmodels(
 	tms []spec.Threatmodel,
 	parser *spec.ThreatmodelParser,
@@ -29,13 +41,8 @@ func renderThreatmodels(
 
 	case "otm":
 		allOtms := []otm.OtmSchemaJson{}
-		for _, tm := range tms {
-			tmOtm, err := tm.RenderOtm()
-			if err != nil {
-				return "", fmt.Errorf("error parsing into otm: %s", err)
-			}
-			appendMermaidRepresentations(&tmOtm, tm)
-			allOtms = append(allOtms, tmOtm)
+		for i := range tms {
+			allOtms = append(allOtms, otmconv.Export(&tms[i]))
 		}
 
 		var (
@@ -53,6 +60,62 @@ func renderThreatmodels(
 		}
 		return string(otmJSON), nil
 
+	case "threatdragon":
+		models := []threatdragon.Model{}
+		for i := range tms {
+			models = append(models, threatdragon.Export(&tms[i]))
+		}
+
+		var (
+			tdJSON []byte
+			err    error
+		)
+		switch {
+		case len(tms) > 1:
+			tdJSON, err = json.Marshal(models)
+		case len(tms) == 1:
+			tdJSON, err = json.Marshal(models[0])
+		}
+		if err != nil {
+			return "", fmt.Errorf("error parsing into threatdragon: %s", err)
+		}
+		return string(tdJSON), nil
+
+	case "csv":
+		var buf bytes.Buffer
+		if err := register.Write(&buf, tms); err != nil {
+			return "", fmt.Errorf("error parsing into csv: %s", err)
+		}
+		return strings.TrimSuffix(buf.String(), "\n"), nil
+
+	case "docx":
+		opts := docx.Options{
+			Date: time.Now().Format("2 January 2006"),
+			DfdImage: func(tmName string, d *spec.DataFlowDiagram) ([]byte, error) {
+				return d.GenerateDfdPngBytes(tmName, spec.DfdRenderOptions{})
+			},
+		}
+		if templatePath != "" {
+			var err error
+			opts.Reference, err = os.ReadFile(templatePath)
+			if err != nil {
+				return "", fmt.Errorf("error reading template file: %s", err)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := docx.Write(&buf, tms, opts); err != nil {
+			return "", fmt.Errorf("error parsing into docx: %s", err)
+		}
+		return buf.String(), nil
+
+	case "stix":
+		stixJSON, err := json.Marshal(stix.NewBundle(tms, stix.Options{Timestamp: time.Now()}))
+		if err != nil {
+			return "", fmt.Errorf("error parsing into stix: %s", err)
+		}
+		return string(stixJSON), nil
+
 	case "hcl":
 		if parser == nil {
 			return "", fmt.Errorf("hcl format requires a parser")
@@ -90,28 +153,58 @@ func renderThreatmodels(
 	return "", fmt.Errorf("Incorrect -format option")
 }
 
-// appendMermaidRepresentations adds an OTM representation for each embedded
-// mermaid block so they survive otm export (spec.RenderOtm only maps
-// diagram_link). OTM has no first-class field for inline diagram source, so the
-// raw mermaid is carried in the representation attributes (format=mermaid,
-// content=<source>) and the block's optional description maps to the
-// representation description. IDs are namespaced "mermaid-<n>" to avoid
-// colliding with the "diagram-1" id RenderOtm emits for diagram_link.
-func appendMermaidRepresentations(o *otm.OtmSchemaJson, tm spec.Threatmodel) {
-	for i, m := range tm.MermaidDiagrams {
-		repr := otm.OtmSchemaJsonRepresentationsElem{
-			Id:   fmt.Sprintf("mermaid-%d", i+1),
-			Name: m.Name,
-			Type: "diagram",
-			Attributes: otm.OtmSchemaJsonRepresentationsElemAttributes{
-				"format":  "mermaid",
-				"content": m.Content,
-			},
+// renderAttackNavigator renders the supplied threat models into an ATT&CK
+// Navigator layer. mappingPath, if non-empty, is read as a mapping of
+// mitigations and controls onto techniques. The returned warnings list
+// anything that couldn't be scored.
+func renderAttackNavigator(tms []spec.Threatmodel, mappingPath string) (string, []string, error) {
+	var mapping *attack.Mapping
+	if mappingPath != "" {
+		var err error
+		mapping, err = attack.ParseFile(mappingPath)
+		if err != nil {
+			return "", nil, fmt.Errorf("Error parsing ATT&CK mapping %s: %s", mappingPath, err)
 		}
-		if m.Description != "" {
-			desc := m.Description
-			repr.Description = &desc
+	}
+
+	res := attack.NewLayer(tms, mapping)
+	layerJSON, err := json.Marshal(res.Layer)
+	if err != nil {
+		return "", nil, fmt.Errorf("error parsing into attack-navigator: %s", err)
+	}
+	return string(layerJSON), res.Warnings, nil
+}
+
+// parseOscalCatalogs reads -oscal-catalog, framework=uri pairs separated by
+// commas.
+func parseOscalCatalogs(s string) (map[string]string, error) {
+	catalogs := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
 		}
-		o.Representations = append(o.Representations, repr)
+		name, uri, ok := strings.Cut(pair, "=")
+		name, uri = strings.TrimSpace(name), strings.TrimSpace(uri)
+		if !ok || uri == "" {
+			return nil, fmt.Errorf("%q isn't framework=uri", pair)
+		}
+		if _, ok := oscal.Catalogs[name]; !ok {
+			return nil, fmt.Errorf("unknown framework %q, expected nist_800_53, asvs or proactive_control", name)
+		}
+		catalogs[name] = uri
+	}
+	return catalogs, nil
+}
+
+// renderOscal renders the supplied threat models into an OSCAL
+// component-definition, with catalogs as the sources of the frameworks'
+// catalogs. The returned warnings list any controls that couldn't be
+// exported.
+func renderOscal(tms []spec.Threatmodel, catalogs map[string]string) (string, []string, error) {
+	res := oscal.NewComponentDefinition(tms, oscal.Options{LastModified: time.Now(), Sources: catalogs})
+	oscalJSON, err := json.Marshal(res.Document)
+	if err != nil {
+		return "", nil, fmt.Errorf("error parsing into oscal: %s", err)
 	}
+	return string(oscalJSON), res.Warnings, nil
 }
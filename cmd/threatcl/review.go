@@ -0,0 +1,560 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/terminal"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/posener/complete"
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/cadence"
+	"github.com/threatcl/threatcl/internal/signing"
+	"github.com/threatcl/threatcl/internal/tmloader"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Review actions recorded against each threat and control.
+const (
+	reviewActionConfirmed      = "confirmed"
+	reviewActionImplemented    = "marked_implemented"
+	reviewActionNotImplemented = "marked_not_implemented"
+	reviewActionSkipped        = "skipped"
+)
+
+// reviewSidecarSuffix is appended to a threat model file's path to name the
+// file holding its review records.
+const reviewSidecarSuffix = ".review.json"
+
+// ReviewCommand struct defines the "threatcl review" command
+type ReviewCommand struct {
+	*GlobalCmdOptions
+	specCfg      *spec.ThreatmodelSpecConfig
+	flagReviewer string
+	prompter     reviewPrompter
+	now          func() time.Time
+}
+
+// reviewDecision is the reviewer's verdict on a single threat or control.
+type reviewDecision struct {
+	Threat  string `json:"threat"`
+	Control string `json:"control,omitempty"`
+	Action  string `json:"action"`
+	Note    string `json:"note,omitempty"`
+}
+
+// reviewRecord is the sign-off for one threat model. ContentHash pins the
+// record to the reviewed content, so later edits make the review stale.
+type reviewRecord struct {
+	Threatmodel string           `json:"threatmodel"`
+	Reviewer    string           `json:"reviewer"`
+	Date        time.Time        `json:"date"`
+	ContentHash string           `json:"content_hash"`
+	Decisions   []reviewDecision `json:"decisions"`
+}
+
+// reviewSidecar is the on-disk format of a <file>.review.json file. Records
+// are appended, so the file doubles as the model's review history.
+type reviewSidecar struct {
+	Reviews []reviewRecord `json:"reviews"`
+}
+
+// reviewPrompter abstracts the interactive questions so the review flow can
+// be driven without a terminal.
+type reviewPrompter interface {
+	Select(message string, options []string) (string, error)
+	Input(message, def string) (string, error)
+	Confirm(message string) (bool, error)
+}
+
+type surveyPrompter struct{}
+
+func (surveyPrompter) Select(message string, options []string) (string, error) {
+	answer := ""
+	err := survey.AskOne(&survey.Select{Message: message, Options: options}, &answer)
+	return answer, err
+}
+
+func (surveyPrompter) Input(message, def string) (string, error) {
+	answer := ""
+	err := survey.AskOne(&survey.Input{Message: message, Default: def}, &answer)
+	return answer, err
+}
+
+func (surveyPrompter) Confirm(message string) (bool, error) {
+	answer := false
+	err := survey.AskOne(&survey.Confirm{Message: message}, &answer)
+	return answer, err
+}
+
+// Help is the help output for "threatcl review"
+func (c *ReviewCommand) Help() string {
+	helpText := `
+Usage: threatcl review [options] <file>
+
+  Interactively walk through every threat and control in the threat models of
+  a HCL file. For each one you can confirm it, change a control's implemented
+  status, or add a note.
+
+  Confirmed status changes are written back to the HCL file, and a review
+  record (reviewer, date, model content hash and every decision) is appended
+  to <file>.review.json. 'threatcl validate -require-review' checks that
+  record is still fresh, and 'threatcl stale' counts it as a review.
+
+  Skipping any threat or control leaves the review unrecorded, though status
+  changes are still applied.
+
+Options:
+
+ -config=<file>
+   Optional config file
+
+ -reviewer=<name>
+   Name of the reviewer to record. If not set, you will be prompted
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run executes "threatcl review" logic
+func (c *ReviewCommand) Run(args []string) int {
+	flagSet := c.GetFlagset("review")
+	flagSet.StringVar(&c.flagReviewer, "reviewer", "", "Name of the reviewer to record")
+	parseFlags(flagSet, args)
+
+	if c.flagConfig != "" {
+		err := c.specCfg.LoadSpecConfigFile(c.flagConfig)
+
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			return 1
+		}
+	}
+
+	if len(flagSet.Args()) != 1 {
+		fmt.Printf("Please provide a single HCL file\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	file := flagSet.Args()[0]
+	if filepath.Ext(file) != ".hcl" {
+		fmt.Printf("Only HCL files can be reviewed, '%s' isn't one\n", file)
+		return 1
+	}
+
+	if c.prompter == nil {
+		c.prompter = surveyPrompter{}
+	}
+	now := time.Now()
+	if c.now != nil {
+		now = c.now()
+	}
+
+	res, err := tmloader.LoadSet(c.specCfg, []string{file})
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+
+	if len(res.Models) == 0 {
+		fmt.Printf("No threatmodels found in '%s'\n", file)
+		return 1
+	}
+
+	reviewer := c.flagReviewer
+	if reviewer == "" {
+		reviewer, err = c.prompter.Input("Reviewer name:", os.Getenv("USER"))
+		if err != nil {
+			return c.promptError(err)
+		}
+		if strings.TrimSpace(reviewer) == "" {
+			fmt.Printf("A reviewer name is required\n")
+			return 1
+		}
+	}
+
+	decisions := map[string][]reviewDecision{}
+	for _, lm := range res.Models {
+		fmt.Printf("\nReviewing threatmodel '%s'\n", lm.TM.Name)
+		d, err := c.reviewModel(lm.TM)
+		if err != nil {
+			return c.promptError(err)
+		}
+		decisions[lm.TM.Name] = d
+	}
+
+	changes, skipped := 0, 0
+	for _, ds := range decisions {
+		for _, d := range ds {
+			switch d.Action {
+			case reviewActionImplemented, reviewActionNotImplemented:
+				changes++
+			case reviewActionSkipped:
+				skipped++
+			}
+		}
+	}
+
+	// A review with skipped items isn't a sign-off, so only its changes are
+	// kept.
+	prompt := fmt.Sprintf("Apply %d change(s) to '%s' and record the review?", changes, file)
+	if skipped > 0 {
+		if changes == 0 {
+			fmt.Printf("Review not recorded: %d item(s) were skipped\n", skipped)
+			return 1
+		}
+		prompt = fmt.Sprintf("Apply %d change(s) to '%s'? %d item(s) were skipped, so the review won't be recorded", changes, file, skipped)
+	}
+
+	ok, err := c.prompter.Confirm(prompt)
+	if err != nil {
+		return c.promptError(err)
+	}
+	if !ok {
+		fmt.Printf("Review discarded, nothing was written\n")
+		return 1
+	}
+
+	if changes > 0 {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Printf("Error reading '%s': %s\n", file, err)
+			return 1
+		}
+
+		for tmName, ds := range decisions {
+			for _, d := range ds {
+				if d.Action != reviewActionImplemented && d.Action != reviewActionNotImplemented {
+					continue
+				}
+				src, err = setControlImplemented(src, file, tmName, d.Threat, d.Control, d.Action == reviewActionImplemented)
+				if err != nil {
+					fmt.Printf("Error applying review changes: %s\n", err)
+					return 1
+				}
+			}
+		}
+
+		if err := writeStringToFile(file, string(src)); err != nil {
+			fmt.Printf("Error writing '%s': %s\n", file, err)
+			return 1
+		}
+
+		// Hash what's now on disk, so the record matches the applied changes.
+		res, err = tmloader.LoadSet(c.specCfg, []string{file})
+		if err != nil {
+			fmt.Printf("Error re-parsing '%s' after applying changes: %s\n", file, err)
+			return 1
+		}
+		fmt.Printf("Applied %d change(s) to '%s'\n", changes, file)
+	}
+
+	if skipped > 0 {
+		fmt.Printf("Review not recorded: %d item(s) were skipped\n", skipped)
+		return 1
+	}
+
+	records := []reviewRecord{}
+	for _, lm := range res.Models {
+		hash, err := signing.Digest(lm.TM)
+		if err != nil {
+			fmt.Printf("Error hashing threatmodel '%s': %s\n", lm.TM.Name, err)
+			return 1
+		}
+		records = append(records, reviewRecord{
+			Threatmodel: lm.TM.Name,
+			Reviewer:    reviewer,
+			Date:        now.UTC(),
+			ContentHash: hash,
+			Decisions:   decisions[lm.TM.Name],
+		})
+	}
+
+	if err := appendReviewRecords(file, records); err != nil {
+		fmt.Printf("Error writing review record: %s\n", err)
+		return 1
+	}
+
+	fmt.Printf("Recorded review of %d threatmodel(s) in '%s'\n", len(records), reviewSidecarPath(file))
+	return 0
+}
+
+// reviewModel prompts for a decision on every threat and control in tm.
+// Controls expanded from control_imports live in another file, so they can be
+// confirmed or annotated but not changed.
+func (c *ReviewCommand) reviewModel(tm *spec.Threatmodel) ([]reviewDecision, error) {
+	decisions := []reviewDecision{}
+
+	for _, threat := range tm.Threats {
+		fmt.Printf("\nThreat: %s\n", threat.Name)
+		if threat.Description != "" {
+			fmt.Printf("  %s\n", strings.ReplaceAll(strings.TrimSpace(threat.Description), "\n", "\n  "))
+		}
+		if len(threat.Stride) > 0 {
+			fmt.Printf("  STRIDE: %s\n", strings.Join(threat.Stride, ", "))
+		}
+		if threat.Risk != nil {
+			fmt.Printf("  Risk: %s (likelihood %s, impact %s)\n", threat.Risk.Severity(), threat.Risk.Likelihood, threat.Risk.Impact)
+		}
+
+		d, err := c.decide(fmt.Sprintf("Threat '%s':", threat.Name), nil)
+		if err != nil {
+			return nil, err
+		}
+		d.Threat = threat.Name
+		decisions = append(decisions, d)
+
+		for _, ctrl := range threat.Controls {
+			implemented := ctrl.Implemented
+			fmt.Printf("\n  Control: %s (implemented: %t)\n", ctrl.Name, ctrl.Implemented)
+			if ctrl.Description != "" {
+				fmt.Printf("    %s\n", strings.TrimSpace(ctrl.Description))
+			}
+			d, err := c.decide(fmt.Sprintf("Control '%s':", ctrl.Name), &implemented)
+			if err != nil {
+				return nil, err
+			}
+			d.Threat = threat.Name
+			d.Control = ctrl.Name
+			decisions = append(decisions, d)
+		}
+
+		for _, ctrl := range threat.ExpandedControls {
+			fmt.Printf("\n  Imported control: %s (implemented: %t)\n", ctrl.Name, ctrl.Implemented)
+			d, err := c.decide(fmt.Sprintf("Imported control '%s':", ctrl.Name), nil)
+			if err != nil {
+				return nil, err
+			}
+			d.Threat = threat.Name
+			d.Control = ctrl.Name
+			decisions = append(decisions, d)
+		}
+	}
+
+	return decisions, nil
+}
+
+// decide asks for a single decision. implemented is nil for items whose status
+// can't be changed from here.
+func (c *ReviewCommand) decide(message string, implemented *bool) (reviewDecision, error) {
+	const (
+		optConfirm = "Confirm"
+		optNote    = "Confirm and add a note"
+		optSkip    = "Skip"
+	)
+	optToggle := ""
+	options := []string{optConfirm, optNote}
+	if implemented != nil {
+		optToggle = "Mark as implemented"
+		if *implemented {
+			optToggle = "Mark as not implemented"
+		}
+		options = append(options, optToggle)
+	}
+	options = append(options, optSkip)
+
+	answer, err := c.prompter.Select(message, options)
+	if err != nil {
+		return reviewDecision{}, err
+	}
+
+	d := reviewDecision{Action: reviewActionConfirmed}
+	switch answer {
+	case optSkip:
+		d.Action = reviewActionSkipped
+		return d, nil
+	case optToggle:
+		d.Action = reviewActionImplemented
+		if *implemented {
+			d.Action = reviewActionNotImplemented
+		}
+	}
+
+	if answer == optNote || answer == optToggle {
+		note, err := c.prompter.Input("Note (optional):", "")
+		if err != nil {
+			return reviewDecision{}, err
+		}
+		d.Note = strings.TrimSpace(note)
+	}
+
+	return d, nil
+}
+
+func (c *ReviewCommand) promptError(err error) int {
+	if errors.Is(err, terminal.InterruptErr) {
+		fmt.Printf("Interrupted - nothing was written\n")
+		return 1
+	}
+	fmt.Printf("Error: %s\n", err)
+	return 1
+}
+
+// setControlImplemented rewrites the implemented attribute of one control in
+// HCL source, leaving the rest of the file (comments, layout) untouched.
+func setControlImplemented(src []byte, filename, tmName, threatName, controlName string, implemented bool) ([]byte, error) {
+	f, diags := hclwrite.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	tmBlock := findLabelledBlock(f.Body(), "threatmodel", tmName)
+	if tmBlock == nil {
+		return nil, fmt.Errorf("threatmodel '%s' isn't declared in %s", tmName, filename)
+	}
+	threatBlock := findLabelledBlock(tmBlock.Body(), "threat", threatName)
+	if threatBlock == nil {
+		return nil, fmt.Errorf("threat '%s' isn't declared in threatmodel '%s' in %s", threatName, tmName, filename)
+	}
+	controlBlock := findLabelledBlock(threatBlock.Body(), "control", controlName)
+	if controlBlock == nil {
+		return nil, fmt.Errorf("control '%s' isn't declared in threat '%s' in %s", controlName, threatName, filename)
+	}
+
+	controlBlock.Body().SetAttributeValue("implemented", cty.BoolVal(implemented))
+	return f.Bytes(), nil
+}
+
+func findLabelledBlock(body *hclwrite.Body, blockType, label string) *hclwrite.Block {
+	for _, b := range body.Blocks() {
+		if b.Type() != blockType {
+			continue
+		}
+		labels := b.Labels()
+		if len(labels) > 0 && labels[0] == label {
+			return b
+		}
+	}
+	return nil
+}
+
+func reviewSidecarPath(file string) string {
+	return file + reviewSidecarSuffix
+}
+
+// readReviewSidecar loads the review records kept alongside file. A missing
+// sidecar is not an error; it simply holds no reviews.
+func readReviewSidecar(file string) (*reviewSidecar, error) {
+	sc := &reviewSidecar{}
+	b, err := os.ReadFile(reviewSidecarPath(file))
+	if os.IsNotExist(err) {
+		return sc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, sc); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %s", reviewSidecarPath(file), err)
+	}
+	return sc, nil
+}
+
+func appendReviewRecords(file string, records []reviewRecord) error {
+	sc, err := readReviewSidecar(file)
+	if err != nil {
+		return err
+	}
+	sc.Reviews = append(sc.Reviews, records...)
+
+	b, err := json.MarshalIndent(sc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeStringToFile(reviewSidecarPath(file), string(b)+"\n")
+}
+
+// latestReview returns the most recent review record for the named threat
+// model, or nil when it has never been reviewed.
+func (sc *reviewSidecar) latestReview(tmName string) *reviewRecord {
+	var latest *reviewRecord
+	for i := range sc.Reviews {
+		r := &sc.Reviews[i]
+		if r.Threatmodel != tmName {
+			continue
+		}
+		if latest == nil || r.Date.After(latest.Date) {
+			latest = r
+		}
+	}
+	return latest
+}
+
+// skipped counts the threats and controls the review skipped.
+func (r *reviewRecord) skipped() int {
+	n := 0
+	for _, d := range r.Decisions {
+		if d.Action == reviewActionSkipped {
+			n++
+		}
+	}
+	return n
+}
+
+// reviewEvidence adds the date of a model's last recorded review to the other
+// "last reviewed" evidence used by stale, list and dashboard.
+func reviewEvidence(sources *cadence.Sources, lm tmloader.LoadedModel) []cadence.Reviewed {
+	out := sources.For(lm.File)
+	if lm.File == "" {
+		return out
+	}
+	sc, err := readReviewSidecar(lm.File)
+	if err != nil {
+		return out
+	}
+	if r := sc.latestReview(lm.TM.Name); r != nil && r.skipped() == 0 {
+		out = append(out, cadence.Reviewed{At: r.Date, Source: "review"})
+	}
+	return out
+}
+
+// checkReviewFreshness reports a problem when lm has no review record, its
+// last review skipped any items, it has changed since its last review, or
+// (with maxAgeDays > 0) it was last reviewed too long ago. It returns "" when the review is fresh.
+func checkReviewFreshness(lm tmloader.LoadedModel, maxAgeDays int, now time.Time) (string, error) {
+	sc, err := readReviewSidecar(lm.File)
+	if err != nil {
+		return "", err
+	}
+	r := sc.latestReview(lm.TM.Name)
+	if r == nil {
+		return "has never been reviewed (run 'threatcl review')", nil
+	}
+
+	hash, err := signing.Digest(lm.TM)
+	if err != nil {
+		return "", err
+	}
+	if n := r.skipped(); n > 0 {
+		return fmt.Sprintf("was only partly reviewed by %s on %s: %d item(s) were skipped", r.Reviewer, r.Date.Format("2006-01-02"), n), nil
+	}
+	if hash != r.ContentHash {
+		return fmt.Sprintf("has changed since it was reviewed by %s on %s", r.Reviewer, r.Date.Format("2006-01-02")), nil
+	}
+
+	if maxAgeDays > 0 && now.After(r.Date.AddDate(0, 0, maxAgeDays)) {
+		return fmt.Sprintf("was last reviewed by %s on %s, more than %d days ago", r.Reviewer, r.Date.Format("2006-01-02"), maxAgeDays), nil
+	}
+
+	return "", nil
+}
+
+// Synopsis returns the synopsis for the "threatcl review" command
+func (c *ReviewCommand) Synopsis() string {
+	return "Interactively review a threat model and record a sign-off"
+}
+
+func (c *ReviewCommand) AutocompleteArgs() complete.Predictor { return predictHCL }
+func (c *ReviewCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-config":   predictHCL,
+		"-reviewer": complete.PredictAnything,
+	}
+}
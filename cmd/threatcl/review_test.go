@@ -0,0 +1,395 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/cadence"
+	"github.com/threatcl/threatcl/internal/signing"
+	"github.com/threatcl/threatcl/internal/tmloader"
+
+	"github.com/zenizh/go-capturer"
+)
+
+const reviewTestHCL = `spec_version = "0.7.0"
+
+threatmodel "reviewed" {
+  author = "@xntrik"
+
+  # this comment must survive the review
+  threat "data leak" {
+    description = "Data could leak"
+
+    control "encryption" {
+      implemented = false
+      description = "Encrypt at rest"
+      risk_reduction = 50
+    }
+  }
+}
+`
+
+// scriptedPrompter answers prompts from a fixed script, in order.
+type scriptedPrompter struct {
+	selects  []string
+	inputs   []string
+	confirms []bool
+}
+
+func (p *scriptedPrompter) Select(message string, options []string) (string, error) {
+	if len(p.selects) == 0 {
+		return "", fmt.Errorf("unexpected select: %s", message)
+	}
+	answer := p.selects[0]
+	p.selects = p.selects[1:]
+	return answer, nil
+}
+
+func (p *scriptedPrompter) Input(message, def string) (string, error) {
+	if len(p.inputs) == 0 {
+		return "", fmt.Errorf("unexpected input: %s", message)
+	}
+	answer := p.inputs[0]
+	p.inputs = p.inputs[1:]
+	return answer, nil
+}
+
+func (p *scriptedPrompter) Confirm(message string) (bool, error) {
+	if len(p.confirms) == 0 {
+		return false, fmt.Errorf("unexpected confirm: %s", message)
+	}
+	answer := p.confirms[0]
+	p.confirms = p.confirms[1:]
+	return answer, nil
+}
+
+func testReviewCommand(tb testing.TB, p reviewPrompter) *ReviewCommand {
+	tb.Helper()
+
+	d, err := os.MkdirTemp("", "")
+	if err != nil {
+		tb.Fatalf("Error creating tmp dir: %s", err)
+	}
+
+	_ = os.Setenv("HOME", d)
+	_ = os.Setenv("USERPROFILE", d)
+
+	cfg, _ := spec.LoadSpecConfig()
+
+	defer os.RemoveAll(d)
+
+	global := &GlobalCmdOptions{}
+
+	return &ReviewCommand{
+		GlobalCmdOptions: global,
+		specCfg:          cfg,
+		prompter:         p,
+		now: func() time.Time {
+			return time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		},
+	}
+}
+
+func writeReviewFixture(tb testing.TB) string {
+	tb.Helper()
+	tmFile := filepath.Join(tb.TempDir(), "reviewed.hcl")
+	if err := os.WriteFile(tmFile, []byte(reviewTestHCL), 0600); err != nil {
+		tb.Fatalf("Error writing tm file: %s", err)
+	}
+	return tmFile
+}
+
+func TestReviewNoArgs(t *testing.T) {
+	cmd := testReviewCommand(t, &scriptedPrompter{})
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+
+	if !strings.Contains(out, "Please provide a single HCL file") {
+		t.Errorf("Expected %s to contain %s", out, "Please provide a single HCL file")
+	}
+}
+
+func TestReviewApplyAndValidate(t *testing.T) {
+	tmFile := writeReviewFixture(t)
+
+	p := &scriptedPrompter{
+		selects:  []string{"Confirm", "Mark as implemented"},
+		inputs:   []string{"verified KMS config"},
+		confirms: []bool{true},
+	}
+	cmd := testReviewCommand(t, p)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-reviewer=alice", tmFile})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	if !strings.Contains(out, "Applied 1 change(s)") {
+		t.Errorf("Expected %s to contain %s", out, "Applied 1 change(s)")
+	}
+
+	src, err := os.ReadFile(tmFile)
+	if err != nil {
+		t.Fatalf("Error reading tm file: %s", err)
+	}
+	if !strings.Contains(string(src), "implemented    = true") && !strings.Contains(string(src), "implemented = true") {
+		t.Errorf("Expected the control to be marked implemented:\n%s", src)
+	}
+	if !strings.Contains(string(src), "# this comment must survive the review") {
+		t.Errorf("Expected comments to be preserved:\n%s", src)
+	}
+
+	sc, err := readReviewSidecar(tmFile)
+	if err != nil {
+		t.Fatalf("Error reading sidecar: %s", err)
+	}
+	r := sc.latestReview("reviewed")
+	if r == nil {
+		t.Fatalf("Expected a review record")
+	}
+	if r.Reviewer != "alice" || len(r.Decisions) != 2 {
+		t.Errorf("Unexpected review record: %+v", r)
+	}
+	if r.Decisions[1].Action != reviewActionImplemented || r.Decisions[1].Note != "verified KMS config" {
+		t.Errorf("Unexpected control decision: %+v", r.Decisions[1])
+	}
+
+	validate := testValidateCommand(t)
+	validate.now = cmd.now
+	out = capturer.CaptureStdout(func() {
+		code = validate.Run([]string{"-require-review", tmFile})
+	})
+	if code != 0 {
+		t.Errorf("Expected a fresh review to validate, got %d: %s", code, out)
+	}
+
+	// Editing the model afterwards makes the review stale
+	edited := strings.Replace(string(src), "Data could leak", "Data could leak badly", 1)
+	if err := os.WriteFile(tmFile, []byte(edited), 0600); err != nil {
+		t.Fatalf("Error writing tm file: %s", err)
+	}
+
+	validate = testValidateCommand(t)
+	out = capturer.CaptureStdout(func() {
+		code = validate.Run([]string{"-require-review", tmFile})
+	})
+	if code != 1 {
+		t.Errorf("Expected a stale review to fail validation, got %d", code)
+	}
+	if !strings.Contains(out, "has changed since it was reviewed by alice") {
+		t.Errorf("Expected %s to contain %s", out, "has changed since it was reviewed by alice")
+	}
+}
+
+func TestReviewDiscarded(t *testing.T) {
+	tmFile := writeReviewFixture(t)
+
+	p := &scriptedPrompter{
+		selects:  []string{"Skip", "Mark as implemented"},
+		inputs:   []string{""},
+		confirms: []bool{false},
+	}
+	cmd := testReviewCommand(t, p)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-reviewer=alice", tmFile})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+	if !strings.Contains(out, "Review discarded") {
+		t.Errorf("Expected %s to contain %s", out, "Review discarded")
+	}
+
+	src, _ := os.ReadFile(tmFile)
+	if string(src) != reviewTestHCL {
+		t.Errorf("Expected the file to be untouched:\n%s", src)
+	}
+	if _, err := os.Stat(reviewSidecarPath(tmFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected no sidecar to be written")
+	}
+}
+
+func TestReviewSkippedNotRecorded(t *testing.T) {
+	tmFile := writeReviewFixture(t)
+
+	p := &scriptedPrompter{
+		selects:  []string{"Skip", "Skip"},
+		confirms: []bool{},
+	}
+	cmd := testReviewCommand(t, p)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-reviewer=alice", tmFile})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+	if !strings.Contains(out, "Review not recorded: 2 item(s) were skipped") {
+		t.Errorf("Expected %s to contain %s", out, "Review not recorded: 2 item(s) were skipped")
+	}
+	if _, err := os.Stat(reviewSidecarPath(tmFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected no sidecar to be written")
+	}
+
+	validate := testValidateCommand(t)
+	out = capturer.CaptureStdout(func() {
+		code = validate.Run([]string{"-require-review", tmFile})
+	})
+	if code != 1 {
+		t.Errorf("Expected a skipped review to fail validation, got %d", code)
+	}
+}
+
+func TestReviewSkippedAppliesChanges(t *testing.T) {
+	tmFile := writeReviewFixture(t)
+
+	p := &scriptedPrompter{
+		selects:  []string{"Skip", "Mark as implemented"},
+		inputs:   []string{""},
+		confirms: []bool{true},
+	}
+	cmd := testReviewCommand(t, p)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-reviewer=alice", tmFile})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+	if !strings.Contains(out, "Applied 1 change(s)") || !strings.Contains(out, "Review not recorded: 1 item(s) were skipped") {
+		t.Errorf("Expected the change to be applied and the review not recorded:\n%s", out)
+	}
+	if _, err := os.Stat(reviewSidecarPath(tmFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected no sidecar to be written")
+	}
+}
+
+func TestValidateRequireReviewMissing(t *testing.T) {
+	tmFile := writeReviewFixture(t)
+
+	cmd := testValidateCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-review-max-age=30", tmFile})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+	if !strings.Contains(out, "has never been reviewed") {
+		t.Errorf("Expected %s to contain %s", out, "has never been reviewed")
+	}
+}
+
+func TestSetControlImplemented(t *testing.T) {
+	out, err := setControlImplemented([]byte(reviewTestHCL), "reviewed.hcl", "reviewed", "data leak", "encryption", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Contains(string(out), "implemented = false") {
+		t.Errorf("Expected implemented to be rewritten:\n%s", out)
+	}
+	if !strings.Contains(string(out), "# this comment must survive the review") {
+		t.Errorf("Expected comments to be preserved:\n%s", out)
+	}
+
+	_, err = setControlImplemented([]byte(reviewTestHCL), "reviewed.hcl", "reviewed", "data leak", "missing", true)
+	if err == nil || !strings.Contains(err.Error(), "control 'missing' isn't declared") {
+		t.Errorf("Expected a missing control error, got %v", err)
+	}
+}
+
+func TestCheckReviewFreshnessMaxAge(t *testing.T) {
+	tmFile := filepath.Join(t.TempDir(), "aged.hcl")
+	tm := &spec.Threatmodel{Name: "aged", Author: "@xntrik"}
+	hash, err := signing.Digest(tm)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	reviewed := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = appendReviewRecords(tmFile, []reviewRecord{{
+		Threatmodel: "aged",
+		Reviewer:    "bob",
+		Date:        reviewed,
+		ContentHash: hash,
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	lm := tmloader.LoadedModel{TM: tm, File: tmFile}
+
+	problem, err := checkReviewFreshness(lm, 30, reviewed.AddDate(0, 0, 10))
+	if err != nil || problem != "" {
+		t.Errorf("Expected a fresh review, got %q (%v)", problem, err)
+	}
+
+	problem, _ = checkReviewFreshness(lm, 30, reviewed.AddDate(0, 0, 31))
+	if !strings.Contains(problem, "more than 30 days ago") {
+		t.Errorf("Expected an aged review, got %q", problem)
+	}
+}
+
+func TestCheckReviewFreshnessSkipped(t *testing.T) {
+	tmFile := filepath.Join(t.TempDir(), "skipped.hcl")
+	tm := &spec.Threatmodel{Name: "skipped", Author: "@xntrik"}
+	hash, err := signing.Digest(tm)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// a record from before skipped reviews stopped being recorded
+	reviewed := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = appendReviewRecords(tmFile, []reviewRecord{{
+		Threatmodel: "skipped",
+		Reviewer:    "bob",
+		Date:        reviewed,
+		ContentHash: hash,
+		Decisions: []reviewDecision{
+			{Threat: "data leak", Action: reviewActionConfirmed},
+			{Threat: "data leak", Control: "encryption", Action: reviewActionSkipped},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	lm := tmloader.LoadedModel{TM: tm, File: tmFile}
+	problem, err := checkReviewFreshness(lm, 0, reviewed)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if problem != "was only partly reviewed by bob on 2030-01-01: 1 item(s) were skipped" {
+		t.Errorf("Expected a partial review, got %q", problem)
+	}
+	for _, ev := range reviewEvidence(cadence.NewSources(), lm) {
+		if ev.Source == "review" {
+			t.Errorf("Expected a partial review not to count as evidence, got %+v", ev)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/posener/complete"
+	"github.com/ryanuber/columnize"
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/secretscan"
+	"github.com/threatcl/threatcl/internal/tmloader"
+)
+
+// ScanCommand struct defines the "threatcl scan" command
+type ScanCommand struct {
+	*GlobalCmdOptions
+	specCfg         *spec.ThreatmodelSpecConfig
+	flagShowAllowed bool
+	flagNoEntropy   bool
+	flagNoHeader    bool
+}
+
+// Help is the help output for "threatcl scan"
+func (c *ScanCommand) Help() string {
+	helpText := `
+Usage: threatcl scan [options] <files>
+
+  Scan threat models for secrets - connection strings, API tokens, private
+  keys, passwords and other high-entropy strings - in every string field.
+
+  Exits with a non-zero status if anything is found. A finding is allowed by
+  putting the marker "threatcl:allow-secret" on the line holding the value,
+  for example as a trailing comment, or in a comment on the line above. A
+  value repeated elsewhere in the file must be marked everywhere it appears.
+  JSON models, which have no comments, take the marker in a "//" property:
+  "//": "threatcl:allow-secret"
+
+  The same scan runs before 'threatcl cloud push' and 'threatcl cloud upload'
+  and blocks them while there are findings.
+
+Options:
+
+ -config=<file>
+   Optional config file
+
+ -show-allowed
+   Also list findings allowed by an ignore marker
+
+ -no-entropy
+   Only use the built-in secret rules, skipping the entropy check
+
+ -noheader
+   If set, will not print the header
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run executes "threatcl scan" logic
+func (c *ScanCommand) Run(args []string) int {
+	flagSet := c.GetFlagset("scan")
+	flagSet.BoolVar(&c.flagShowAllowed, "show-allowed", false, "Also list findings allowed by an ignore marker")
+	flagSet.BoolVar(&c.flagNoEntropy, "no-entropy", false, "Skip the entropy check")
+	flagSet.BoolVar(&c.flagNoHeader, "noheader", false, "If set, will not print the header")
+	parseFlags(flagSet, args)
+
+	if c.flagConfig != "" {
+		err := c.specCfg.LoadSpecConfigFile(c.flagConfig)
+
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			return 1
+		}
+	}
+
+	if len(flagSet.Args()) == 0 {
+		fmt.Printf("Please provide file(s)\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	res, err := tmloader.LoadSet(c.specCfg, flagSet.Args())
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+
+	scanner := secretscan.New()
+	if c.flagNoEntropy {
+		scanner.EntropyThreshold = 0
+	}
+
+	findings := []secretscan.Finding{}
+	files := map[string]string{}
+	for _, lm := range res.Models {
+		fs, err := scanModelSource(scanner, lm.TM, lm.File)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			return 1
+		}
+		for _, f := range fs {
+			files[f.Threatmodel] = lm.File
+		}
+		findings = append(findings, fs...)
+	}
+
+	blocking := printSecretFindings(os.Stdout, findings, files, c.flagShowAllowed, c.flagNoHeader)
+
+	fmt.Printf("Scanned %d threatmodels: %d finding(s), %d allowed\n", len(res.Models), len(findings), len(findings)-blocking)
+
+	if blocking > 0 {
+		return 1
+	}
+	return 0
+}
+
+// scanModelSource scans tm, using its source file to locate findings and
+// honour ignore markers.
+func scanModelSource(scanner *secretscan.Scanner, tm *spec.Threatmodel, file string) ([]secretscan.Finding, error) {
+	var src []byte
+	if file != "" {
+		var err error
+		src, err = os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s: %s", file, err)
+		}
+	}
+	return scanner.ScanModel(tm, src), nil
+}
+
+// printSecretFindings prints a table of findings to w and returns how many
+// aren't allowed. files maps threat model names to their source file.
+func printSecretFindings(w io.Writer, findings []secretscan.Finding, files map[string]string, showAllowed, noHeader bool) int {
+	output := []string{}
+	if !noHeader {
+		output = append(output, "Location | Threatmodel | Field | Rule | Match")
+	}
+
+	blocking := 0
+	for _, f := range findings {
+		if !f.Allowed {
+			blocking++
+		} else if !showAllowed {
+			continue
+		}
+
+		location := files[f.Threatmodel]
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", location, f.Line)
+		}
+		rule := f.RuleID
+		if f.Allowed {
+			rule = rule + " (allowed)"
+		}
+
+		output = append(output, fmt.Sprintf("%s | %s | %s | %s | %s",
+			location, f.Threatmodel, f.Path, rule, f.Redacted()))
+	}
+
+	if len(output) > 1 || (noHeader && len(output) > 0) {
+		fmt.Fprintln(w, columnize.SimpleFormat(output))
+		fmt.Fprintln(w)
+	}
+
+	return blocking
+}
+
+// runSecretPreflight scans the models about to be pushed from file and prints
+// any findings to STDERR. It returns false when the push must stop.
+func runSecretPreflight(file string, tms []spec.Threatmodel) bool {
+	scanner := secretscan.New()
+
+	// The models were just parsed from file; without its source nothing can
+	// be allowed, so the scan is stricter rather than skipped.
+	src, _ := os.ReadFile(file)
+	findings := []secretscan.Finding{}
+	files := map[string]string{}
+
+	for i := range tms {
+		files[tms[i].Name] = file
+		findings = append(findings, scanner.ScanModel(&tms[i], src)...)
+	}
+
+	blocking := 0
+	for _, f := range findings {
+		if !f.Allowed {
+			blocking++
+		}
+	}
+	if blocking == 0 {
+		return true
+	}
+
+	fmt.Fprintf(os.Stderr, "❌ Secret scan found %d possible secret(s) in %s, not pushing:\n\n", blocking, file)
+	printSecretFindings(os.Stderr, findings, files, false, false)
+	fmt.Fprintf(os.Stderr, "   Remove them, or mark a line with '%s' if the value is safe to share.\n", secretscan.IgnoreMarker)
+	return false
+}
+
+// Synopsis returns the synopsis for the "threatcl scan" command
+func (c *ScanCommand) Synopsis() string {
+	return "Scan threat models for secrets and sensitive data"
+}
+
+func (c *ScanCommand) AutocompleteArgs() complete.Predictor { return predictHCLOrJSON }
+func (c *ScanCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-config": predictHCL,
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/posener/complete"
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/signing"
+	"github.com/threatcl/threatcl/internal/tmloader"
+	"golang.org/x/crypto/ssh"
+)
+
+// signingPassphraseEnv names the environment variable holding the passphrase
+// for an encrypted signing key, for non-interactive use.
+const signingPassphraseEnv = "THREATCL_SIGNING_PASSPHRASE"
+
+// SignCommand struct defines the "threatcl sign" command
+type SignCommand struct {
+	*GlobalCmdOptions
+	specCfg    *spec.ThreatmodelSpecConfig
+	flagKey    string
+	flagSigner string
+	now        func() time.Time
+}
+
+// Help is the help output for "threatcl sign"
+func (c *SignCommand) Help() string {
+	helpText := `
+Usage: threatcl sign [options] <files>
+
+  Sign every threat model in <files> with a local SSH key.
+
+  The signature covers a canonical form of the parsed threat model, so it
+  survives formatting-only edits but not content changes. Signatures are
+  written to a detached <file>.sig file next to each threat model file; check
+  them with 'threatcl verify' or 'threatcl validate -require-signature'.
+
+  If the key is encrypted, its passphrase is read from the
+  THREATCL_SIGNING_PASSPHRASE environment variable, or prompted for.
+
+Options:
+
+ -config=<file>
+   Optional config file
+
+ -key=<file>
+   SSH private key to sign with, ed25519 or RSA. Defaults to
+   ~/.ssh/id_ed25519
+
+ -signer=<name>
+   Optional name of the signer to record alongside the signature
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run executes "threatcl sign" logic
+func (c *SignCommand) Run(args []string) int {
+	flagSet := c.GetFlagset("sign")
+	flagSet.StringVar(&c.flagKey, "key", "", "SSH private key to sign with")
+	flagSet.StringVar(&c.flagSigner, "signer", "", "Optional name of the signer to record")
+	parseFlags(flagSet, args)
+
+	if c.flagConfig != "" {
+		err := c.specCfg.LoadSpecConfigFile(c.flagConfig)
+
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			return 1
+		}
+	}
+
+	if len(flagSet.Args()) == 0 {
+		fmt.Printf("Please provide file(s)\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	keyFile := c.flagKey
+	if keyFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			fmt.Printf("Error finding home directory, use -key: %s\n", err)
+			return 1
+		}
+		keyFile = filepath.Join(home, ".ssh", "id_ed25519")
+	}
+
+	signer, err := loadSigningKey(keyFile)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+
+	res, err := tmloader.LoadSet(c.specCfg, flagSet.Args())
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+
+	now := time.Now()
+	if c.now != nil {
+		now = c.now()
+	}
+
+	// Group signatures by sidecar so each file is read and written once.
+	sidecars := map[string]*signing.File{}
+	order := []string{}
+
+	for _, lm := range res.Models {
+		sig, err := signing.Sign(signer, lm.TM, c.flagSigner, now)
+		if err != nil {
+			fmt.Printf("Error signing threatmodel '%s': %s\n", lm.TM.Name, err)
+			return 1
+		}
+
+		path := signing.SidecarPath(lm.File)
+		sf, ok := sidecars[path]
+		if !ok {
+			sf, err = signing.ReadFile(path)
+			if err != nil {
+				fmt.Printf("Error reading signatures: %s\n", err)
+				return 1
+			}
+			sidecars[path] = sf
+			order = append(order, path)
+		}
+		sf.Add(sig)
+
+		fmt.Printf("Signed threatmodel '%s' (%s) with %s\n", lm.TM.Name, lm.File, sig.Fingerprint)
+	}
+
+	for _, path := range order {
+		if err := sidecars[path].WriteFile(path); err != nil {
+			fmt.Printf("Error writing signatures: %s\n", err)
+			return 1
+		}
+	}
+
+	fmt.Printf("Wrote %d signature(s) to %d file(s)\n", len(res.Models), len(order))
+	return 0
+}
+
+// loadSigningKey reads an SSH private key, asking for its passphrase if it's
+// encrypted and the passphrase isn't in the environment.
+func loadSigningKey(path string) (ssh.Signer, error) {
+	var passphrase []byte
+	if p, ok := os.LookupEnv(signingPassphraseEnv); ok {
+		passphrase = []byte(p)
+	}
+
+	signer, err := signing.LoadSigner(path, passphrase)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		answer := ""
+		prompt := &survey.Password{Message: fmt.Sprintf("Passphrase for %s:", path)}
+		if perr := survey.AskOne(prompt, &answer); perr != nil {
+			return nil, fmt.Errorf("Error reading passphrase: %s", perr)
+		}
+		signer, err = signing.LoadSigner(path, []byte(answer))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error loading signing key: %s", err)
+	}
+	return signer, nil
+}
+
+// Synopsis returns the synopsis for the "threatcl sign" command
+func (c *SignCommand) Synopsis() string {
+	return "Sign threat models with a local SSH key"
+}
+
+func (c *SignCommand) AutocompleteArgs() complete.Predictor { return predictHCLOrJSON }
+func (c *SignCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-config": predictHCL,
+		"-key":    complete.PredictFiles("*"),
+		"-signer": complete.PredictAnything,
+	}
+}
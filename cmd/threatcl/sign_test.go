@@ -0,0 +1,241 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmloader"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/zenizh/go-capturer"
+)
+
+func testSignCommand(tb testing.TB) *SignCommand {
+	tb.Helper()
+
+	d, err := os.MkdirTemp("", "")
+	if err != nil {
+		tb.Fatalf("Error creating tmp dir: %s", err)
+	}
+
+	_ = os.Setenv("HOME", d)
+	_ = os.Setenv("USERPROFILE", d)
+
+	cfg, _ := spec.LoadSpecConfig()
+
+	defer os.RemoveAll(d)
+
+	global := &GlobalCmdOptions{}
+
+	return &SignCommand{
+		GlobalCmdOptions: global,
+		specCfg:          cfg,
+		now: func() time.Time {
+			return time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		},
+	}
+}
+
+func testVerifyCommand(tb testing.TB) *VerifyCommand {
+	tb.Helper()
+
+	d, err := os.MkdirTemp("", "")
+	if err != nil {
+		tb.Fatalf("Error creating tmp dir: %s", err)
+	}
+
+	_ = os.Setenv("HOME", d)
+	_ = os.Setenv("USERPROFILE", d)
+
+	cfg, _ := spec.LoadSpecConfig()
+
+	defer os.RemoveAll(d)
+
+	global := &GlobalCmdOptions{}
+
+	return &VerifyCommand{
+		GlobalCmdOptions: global,
+		specCfg:          cfg,
+	}
+}
+
+// writeSigningKeys writes an unencrypted ed25519 private key and a trusted
+// keys file holding its public half, returning both paths.
+func writeSigningKeys(tb testing.TB) (string, string) {
+	tb.Helper()
+	d := tb.TempDir()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		tb.Fatalf("Error generating key: %s", err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, "test")
+	if err != nil {
+		tb.Fatalf("Error marshalling key: %s", err)
+	}
+	keyFile := filepath.Join(d, "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		tb.Fatalf("Error writing key: %s", err)
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		tb.Fatalf("Error converting key: %s", err)
+	}
+	keysFile := filepath.Join(d, "trusted_keys")
+	if err := os.WriteFile(keysFile, ssh.MarshalAuthorizedKey(sshPub), 0600); err != nil {
+		tb.Fatalf("Error writing keys: %s", err)
+	}
+
+	return keyFile, keysFile
+}
+
+func TestSignNoArgs(t *testing.T) {
+	cmd := testSignCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+
+	if !strings.Contains(out, "Please provide file(s)") {
+		t.Errorf("Expected %s to contain %s", out, "Please provide file(s)")
+	}
+}
+
+func TestVerifyNoKeys(t *testing.T) {
+	cmd := testVerifyCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"./testdata/tm1.hcl"})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+
+	if !strings.Contains(out, "Please provide trusted keys with -keys") {
+		t.Errorf("Expected %s to contain %s", out, "Please provide trusted keys with -keys")
+	}
+}
+
+func TestSignVerifyRun(t *testing.T) {
+	tmFile := writeReviewFixture(t)
+	keyFile, keysFile := writeSigningKeys(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = testSignCommand(t).Run([]string{"-key=" + keyFile, "-signer=alice", tmFile})
+	})
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+	if !strings.Contains(out, "Signed threatmodel 'reviewed'") {
+		t.Errorf("Expected %s to contain %s", out, "Signed threatmodel 'reviewed'")
+	}
+
+	out = capturer.CaptureStdout(func() {
+		code = testVerifyCommand(t).Run([]string{"-keys=" + keysFile, tmFile})
+	})
+	if code != 0 {
+		t.Errorf("Expected verification to pass, got %d: %s", code, out)
+	}
+	if !strings.Contains(out, "signed by alice") {
+		t.Errorf("Expected %s to contain %s", out, "signed by alice")
+	}
+
+	// A formatting-only edit keeps the signature valid
+	src, _ := os.ReadFile(tmFile)
+	reformatted := strings.ReplaceAll(string(src), "  ", "    ")
+	if err := os.WriteFile(tmFile, []byte(reformatted), 0600); err != nil {
+		t.Fatalf("Error writing tm file: %s", err)
+	}
+
+	out = capturer.CaptureStdout(func() {
+		code = testValidateCommand(t).Run([]string{"-require-signature=" + keysFile, tmFile})
+	})
+	if code != 0 {
+		t.Errorf("Expected a reformatted model to still validate, got %d: %s", code, out)
+	}
+
+	// A content change breaks it
+	edited := strings.Replace(reformatted, "Data could leak", "Data could leak badly", 1)
+	if err := os.WriteFile(tmFile, []byte(edited), 0600); err != nil {
+		t.Fatalf("Error writing tm file: %s", err)
+	}
+
+	out = capturer.CaptureStdout(func() {
+		code = testValidateCommand(t).Run([]string{"-require-signature=" + keysFile, tmFile})
+	})
+	if code != 1 {
+		t.Errorf("Expected an edited model to fail validation, got %d", code)
+	}
+	if !strings.Contains(out, "threatmodel has changed since it was signed") {
+		t.Errorf("Expected %s to contain %s", out, "threatmodel has changed since it was signed")
+	}
+}
+
+func TestValidateRequireSignatureUnsigned(t *testing.T) {
+	tmFile := writeReviewFixture(t)
+	_, keysFile := writeSigningKeys(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = testValidateCommand(t).Run([]string{"-require-signature=" + keysFile, tmFile})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+	if !strings.Contains(out, "threatmodel isn't signed") {
+		t.Errorf("Expected %s to contain %s", out, "threatmodel isn't signed")
+	}
+}
+
+func TestProtectedModels(t *testing.T) {
+	models := []tmloader.LoadedModel{
+		{TM: &spec.Threatmodel{Name: "payments"}, File: filepath.Join("models", "prod", "payments.hcl")},
+		{TM: &spec.Threatmodel{Name: "sandbox"}, File: filepath.Join("models", "dev", "sandbox.hcl")},
+		{TM: &spec.Threatmodel{Name: "ledger"}, File: "ledger.hcl"},
+	}
+
+	cases := []struct {
+		globs string
+		exp   string
+	}{
+		{"", "payments,sandbox,ledger"},
+		{filepath.Join("models", "prod", "*.hcl"), "payments"},
+		{"ledger.hcl, sandbox.hcl", "sandbox,ledger"},
+		{"nothing*.hcl", ""},
+	}
+	for _, tc := range cases {
+		got, err := protectedModels(models, tc.globs)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", tc.globs, err)
+		}
+		names := []string{}
+		for _, lm := range got {
+			names = append(names, lm.TM.Name)
+		}
+		if strings.Join(names, ",") != tc.exp {
+			t.Errorf("%q: expected %s, got %v", tc.globs, tc.exp, names)
+		}
+	}
+
+	if _, err := protectedModels(models, "[models"); err == nil {
+		t.Error("expected an invalid glob to be an error")
+	}
+}
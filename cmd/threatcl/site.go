@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/posener/complete"
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/dfd"
+	"github.com/threatcl/threatcl/internal/redact"
+	"github.com/threatcl/threatcl/internal/site"
+	"github.com/threatcl/threatcl/internal/tmloader"
+)
+
+// SiteCommand struct defines the "threatcl site" commands
+type SiteCommand struct {
+	*GlobalCmdOptions
+	specCfg       *spec.ThreatmodelSpecConfig
+	flagOutDir    string
+	flagOverwrite bool
+	flagNoDfd     bool
+	flagMermaidJS string
+	flagRedact    string
+	flagTheme     string
+}
+
+// Help is the help output for "threatcl site"
+func (c *SiteCommand) Help() string {
+	helpText := `
+Usage: threatcl site [options] -outdir=<directory> <files>
+
+  Generate a static HTML documentation site from existing Threat model HCL
+  files (as specified by <files>).
+
+  The site has a fleet index, a page per threat model with its data flow
+  diagrams inlined as SVG, and cross-linked indexes of information assets,
+  controls and third party dependencies. Search and severity filters run
+  in the browser, so the site can be opened straight from a file share
+  without a web server.
+
+ -outdir=<directory>
+   Directory to output the site. Will create directory if it doesn't
+   exist. Must be set
+
+Options:
+
+ -config=<file>
+   Optional config file
+
+ -overwrite
+
+ -nodfd
+   Do not include generated DFD images
+
+ -mermaid-js=<file>
+   Optional copy of mermaid.min.js to bundle into the site, so mermaid
+   blocks are rendered as diagrams. Without it their source is shown
+
+ -redact=<file>
+   Optional HCL redaction profile applied to every threat model before it's
+   rendered. See 'threatcl export -h'
+
+ -theme=<file>
+   Optional HCL theme file styling the DFDs. Defaults to dfd-theme.hcl in
+   the same directory as the -config file, if there is one. See
+   'threatcl dfd -h'
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run executes "threatcl site" logic
+func (c *SiteCommand) Run(args []string) int {
+	flagSet := c.GetFlagset("site")
+	flagSet.StringVar(&c.flagOutDir, "outdir", "", "Directory to output the site. Will create directory if it doesn't exist. Must be set")
+	flagSet.BoolVar(&c.flagOverwrite, "overwrite", false, "Overwrite existing files in the outdir. Defaults to false")
+	flagSet.BoolVar(&c.flagNoDfd, "nodfd", false, "Do not include generated DFD images. Defaults to false")
+	flagSet.StringVar(&c.flagMermaidJS, "mermaid-js", "", "Optional mermaid.min.js to bundle so mermaid blocks are rendered")
+	flagSet.StringVar(&c.flagRedact, "redact", "", "Optional HCL redaction profile to apply before rendering")
+	flagSet.StringVar(&c.flagTheme, "theme", "", "HCL theme file styling the diagrams")
+	parseFlags(flagSet, args)
+
+	if c.flagConfig != "" {
+		err := c.specCfg.LoadSpecConfigFile(c.flagConfig)
+
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			return 1
+		}
+	}
+
+	if c.flagOutDir == "" {
+		fmt.Println("You must set an -outdir")
+		return 1
+	}
+
+	if len(flagSet.Args()) == 0 {
+		fmt.Printf("Please provide file(s)\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	opts := site.Options{
+		Generated: time.Now().Format("2006-01-02"),
+	}
+
+	if c.flagMermaidJS != "" {
+		js, err := os.ReadFile(c.flagMermaidJS)
+		if err != nil {
+			fmt.Printf("Error reading -mermaid-js file: %s\n", err)
+			return 1
+		}
+		opts.MermaidJS = js
+	}
+
+	var profile *redact.Profile
+	if c.flagRedact != "" {
+		var err error
+		profile, err = loadRedactProfile(c.flagRedact)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			return 1
+		}
+	}
+
+	theme, err := loadDfdTheme(c.flagTheme, c.flagConfig)
+	if err != nil {
+		fmt.Printf("Error reading -theme: %s\n", err)
+		return 1
+	}
+
+	// Parse all discovered files as one set (cross-file `extends` resolves).
+	res, err := tmloader.LoadSet(c.specCfg, flagSet.Args())
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+
+	tms := loadedThreatmodels(res.Models)
+	if profile != nil {
+		fmt.Print(profile.Apply(tms).String())
+	}
+
+	if !c.flagNoDfd {
+		opts.DfdSvg = func(tm *spec.Threatmodel, d *spec.DataFlowDiagram, link func(section, name string) string) ([]byte, error) {
+			return interactiveDfdSvg(tm, d, link, theme)
+		}
+	}
+
+	files, err := site.Build(tms, opts)
+	if err != nil {
+		fmt.Printf("Error generating site: %s\n", err)
+		return 1
+	}
+
+	err = createOrValidateFolder(c.flagOutDir, c.flagOverwrite)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+
+	for _, f := range files {
+		path := filepath.Join(c.flagOutDir, f.Name)
+		if err := os.WriteFile(path, f.Data, 0644); err != nil {
+			fmt.Printf("Error writing to file: %s\n", err)
+			return 1
+		}
+	}
+
+	fmt.Printf("Successfully wrote %d threat model(s) to '%s', open '%s' to browse\n", len(tms), c.flagOutDir, filepath.Join(c.flagOutDir, "index.html"))
+	return 0
+}
+
+// Synopsis returns the synopsis for the "threatcl site" command
+func (c *SiteCommand) Synopsis() string {
+	return "Generate a static HTML site from existing HCL threatmodel file(s)"
+}
+
+func (c *SiteCommand) AutocompleteArgs() complete.Predictor { return predictHCLOrJSON }
+func (c *SiteCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-config":     predictHCL,
+		"-outdir":     complete.PredictDirs("*"),
+		"-mermaid-js": complete.PredictFiles("*.js"),
+		"-redact":     predictHCL,
+		"-theme":      predictHCL,
+	}
+}
+
+// interactiveDfdSvg renders d as an SVG, styled by theme if it isn't nil,
+// whose elements and flows describe themselves on hover and link to their
+// threats and information assets
+func interactiveDfdSvg(tm *spec.Threatmodel, d *spec.DataFlowDiagram, link func(section, name string) string, theme *dfd.Theme) ([]byte, error) {
+	g := dfd.FromSpec(d)
+	opts := themedDfdOptions(tm, g, theme)
+	opts.Details = dfd.DetailsFromSpec(g, tm, link)
+	return dfd.Svg(dfd.Dot(g, tm.Name, opts))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+	"github.com/zenizh/go-capturer"
+)
+
+func testSiteCommand(tb testing.TB) *SiteCommand {
+	tb.Helper()
+
+	d, err := os.MkdirTemp("", "")
+	if err != nil {
+		tb.Fatalf("Error creating tmp dir: %s", err)
+	}
+
+	_ = os.Setenv("HOME", d)
+	_ = os.Setenv("USERPROFILE", d)
+
+	cfg, _ := spec.LoadSpecConfig()
+
+	defer os.RemoveAll(d)
+
+	global := &GlobalCmdOptions{}
+
+	return &SiteCommand{
+		GlobalCmdOptions: global,
+		specCfg:          cfg,
+	}
+}
+
+func TestSiteRun(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+		exp  string
+		code int
+	}{
+		{
+			"missing_outdir",
+			[]string{"./testdata/tm1.hcl"},
+			"You must set an -outdir",
+			1,
+		},
+		{
+			"missing_files",
+			[]string{"-outdir=OUTDIR"},
+			"Usage: threatcl site",
+			1,
+		},
+		{
+			"missing_mermaid_js",
+			[]string{"-outdir=OUTDIR", "-mermaid-js=./testdata/nope.js", "./testdata/tm1.hcl"},
+			"Error reading -mermaid-js file",
+			1,
+		},
+		{
+			"site",
+			[]string{"-outdir=OUTDIR", "./testdata/tm1.hcl"},
+			"Successfully wrote",
+			0,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			outDir := filepath.Join(t.TempDir(), "site")
+			args := []string{}
+			for _, a := range tc.args {
+				args = append(args, strings.ReplaceAll(a, "OUTDIR", outDir))
+			}
+
+			cmd := testSiteCommand(t)
+
+			var code int
+
+			out := capturer.CaptureStdout(func() {
+				code = cmd.Run(args)
+			})
+
+			if code != tc.code {
+				t.Errorf("Code did not equal %d: %d", tc.code, code)
+			}
+
+			if !strings.Contains(out, tc.exp) {
+				t.Errorf("Expected %s to contain %s", out, tc.exp)
+			}
+		})
+	}
+}
+
+func TestSiteIndex(t *testing.T) {
+	outDir := filepath.Join(t.TempDir(), "site")
+
+	cmd := testSiteCommand(t)
+
+	var code int
+
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{
+			fmt.Sprintf("-outdir=%s", outDir),
+			"-nodfd",
+			"./testdata/tm1.hcl",
+		})
+	})
+
+	if code != 0 {
+		t.Fatalf("Code did not equal 0: %d\n%s", code, out)
+	}
+
+	for _, f := range []string{"assets.html", "controls.html", "dependencies.html", "search-index.js", "site.js", "site.css"} {
+		if _, err := os.Stat(filepath.Join(outDir, f)); err != nil {
+			t.Errorf("Expected %s to exist: %s", f, err)
+		}
+	}
+
+	index, err := os.ReadFile(filepath.Join(outDir, "index.html"))
+	if err != nil {
+		t.Fatalf("Error reading index: %s", err)
+	}
+	if !strings.Contains(string(index), `<a href="tm-tm1-one.html">tm1 one</a>`) {
+		t.Errorf("Expected the index to link to tm1 one\n%s", index)
+	}
+}
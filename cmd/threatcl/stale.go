@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/posener/complete"
+	"github.com/ryanuber/columnize"
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/cadence"
+	"github.com/threatcl/threatcl/internal/tmloader"
+)
+
+// StaleCommand struct defines the "threatcl stale" command
+type StaleCommand struct {
+	*GlobalCmdOptions
+	specCfg      *spec.ThreatmodelSpecConfig
+	flagCadence  string
+	flagAll      bool
+	flagFailOn   string
+	flagNoHeader bool
+	now          func() time.Time
+}
+
+// Help is the help output for "threatcl stale"
+func (c *StaleCommand) Help() string {
+	helpText := `
+Usage: threatcl stale [options] <files>
+
+  Report threat models that are overdue for review.
+
+  A model's "last reviewed" time is the most recent of its updated_at (or
+  created_at) attribute, the last git commit that touched its file and its
+  last 'threatcl review' record. Its review cadence comes from the -cadence
+  policy file; without one, models are due yearly, every 180 days if they
+  hold Confidential information assets and every 90 days if they hold
+  Restricted ones.
+
+Options:
+
+ -config=<file>
+   Optional config file
+
+ -cadence=<file>
+   Optional HCL review-cadence policy file, for example:
+
+     default_days = 365
+     initiative_size = { Large = 180 }
+     information_classification = { Restricted = 90, Confidential = 180 }
+     fail_on_classification = ["Restricted"]
+
+ -all
+   List every threat model, not only the overdue ones
+
+ -fail-on=<classifications>
+   Comma-separated information classifications. If any overdue threat model
+   holds an information asset with one of these classifications, exit with a
+   non-zero status. Overrides fail_on_classification in the cadence file.
+
+ -noheader
+   If set, will not print the header
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run executes "threatcl stale" logic
+func (c *StaleCommand) Run(args []string) int {
+	flagSet := c.GetFlagset("stale")
+	flagSet.StringVar(&c.flagCadence, "cadence", "", "Optional HCL review-cadence policy file")
+	flagSet.BoolVar(&c.flagAll, "all", false, "List every threat model, not only the overdue ones")
+	flagSet.StringVar(&c.flagFailOn, "fail-on", "", "Comma-separated information classifications that fail the run when overdue")
+	flagSet.BoolVar(&c.flagNoHeader, "noheader", false, "If set, will not print the header")
+	parseFlags(flagSet, args)
+
+	if c.flagConfig != "" {
+		err := c.specCfg.LoadSpecConfigFile(c.flagConfig)
+
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			return 1
+		}
+	}
+
+	if len(flagSet.Args()) == 0 {
+		fmt.Printf("Please provide file(s)\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	policy, err := loadCadencePolicy(c.flagCadence)
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+
+	failOn := policy.FailClassification
+	if c.flagFailOn != "" {
+		failOn = splitCommaList(c.flagFailOn)
+	}
+
+	res, err := tmloader.LoadSet(c.specCfg, flagSet.Args())
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+
+	now := time.Now()
+	if c.now != nil {
+		now = c.now()
+	}
+
+	output := []string{}
+	if !c.flagNoHeader {
+		output = append(output, "File | Threatmodel | Cadence | Last Reviewed | Source | Due | Status")
+	}
+
+	sources := cadence.NewSources()
+	rows := 0
+	overdue := 0
+	failing := []string{}
+
+	for _, lm := range res.Models {
+		st := policy.Evaluate(lm.TM, reviewEvidence(sources, lm), now)
+
+		if st.Overdue {
+			overdue++
+			if len(failOn) > 0 && cadence.HoldsClassification(lm.TM, failOn) {
+				failing = append(failing, lm.TM.Name)
+			}
+		} else if !c.flagAll {
+			continue
+		}
+
+		source := st.Source
+		if source == "" {
+			source = "-"
+		}
+
+		rows++
+		output = append(output, fmt.Sprintf("%s | %s | %dd (%s) | %s | %s | %s | %s",
+			lm.File,
+			lm.TM.Name,
+			st.CadenceDays,
+			st.Reason,
+			cadence.FormatDate(st.LastReviewed),
+			source,
+			dueString(st),
+			staleStatus(st),
+		))
+	}
+
+	if rows > 0 {
+		fmt.Println(columnize.SimpleFormat(output))
+		fmt.Println()
+	}
+
+	fmt.Printf("%d of %d threatmodels are overdue for review\n", overdue, len(res.Models))
+
+	if len(failing) > 0 {
+		fmt.Printf("Overdue threatmodels holding %s information: %s\n", strings.Join(failOn, ", "), strings.Join(failing, ", "))
+		return 1
+	}
+
+	return 0
+}
+
+// loadCadencePolicy reads the review-cadence file at path, or returns the
+// built-in policy when path is empty.
+func loadCadencePolicy(path string) (*cadence.Policy, error) {
+	if path == "" {
+		return cadence.DefaultPolicy(), nil
+	}
+	policy, err := cadence.ParseFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing cadence file %s: %s", path, err)
+	}
+	return policy, nil
+}
+
+// splitCommaList splits a comma-separated flag value, dropping empty entries
+// and surrounding whitespace.
+func splitCommaList(in string) []string {
+	out := []string{}
+	for _, s := range strings.Split(in, ",") {
+		s = strings.TrimSpace(s)
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func dueString(st cadence.Status) string {
+	if st.Due.IsZero() {
+		return "now"
+	}
+	return cadence.FormatDate(st.Due)
+}
+
+func staleStatus(st cadence.Status) string {
+	switch {
+	case st.LastReviewed.IsZero():
+		return "never reviewed"
+	case st.Overdue:
+		return fmt.Sprintf("overdue by %dd", st.DaysOverdue)
+	}
+	return "ok"
+}
+
+// Synopsis returns the synopsis for the "threatcl stale" command
+func (c *StaleCommand) Synopsis() string {
+	return "Report threat models that are overdue for review"
+}
+
+func (c *StaleCommand) AutocompleteArgs() complete.Predictor { return predictHCLOrJSON }
+func (c *StaleCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-config":  predictHCL,
+		"-cadence": predictHCL,
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/threatcl/spec"
+
+	"github.com/zenizh/go-capturer"
+)
+
+func testStaleCommand(tb testing.TB) *StaleCommand {
+	tb.Helper()
+
+	d, err := os.MkdirTemp("", "")
+	if err != nil {
+		tb.Fatalf("Error creating tmp dir: %s", err)
+	}
+
+	_ = os.Setenv("HOME", d)
+	_ = os.Setenv("USERPROFILE", d)
+
+	cfg, _ := spec.LoadSpecConfig()
+
+	defer os.RemoveAll(d)
+
+	global := &GlobalCmdOptions{}
+
+	return &StaleCommand{
+		GlobalCmdOptions: global,
+		specCfg:          cfg,
+		now: func() time.Time {
+			return time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		},
+	}
+}
+
+func TestStaleNoArgs(t *testing.T) {
+	cmd := testStaleCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+
+	if !strings.Contains(out, "Please provide file(s)") {
+		t.Errorf("Expected %s to contain %s", out, "Please provide file(s)")
+	}
+}
+
+func TestStaleRun(t *testing.T) {
+	cases := []struct {
+		name      string
+		flags     []string
+		exp       string
+		invertexp bool
+		code      int
+	}{
+		{
+			"overdue",
+			nil,
+			"tm tm1 two",
+			false,
+			0,
+		},
+		{
+			"summary",
+			nil,
+			"2 of 2 threatmodels are overdue for review",
+			false,
+			0,
+		},
+		{
+			"never_reviewed",
+			nil,
+			"never reviewed",
+			false,
+			0,
+		},
+		{
+			"fail_on_restricted",
+			[]string{"-fail-on=Restricted"},
+			"Overdue threatmodels holding Restricted information: tm tm1 two",
+			false,
+			1,
+		},
+		{
+			"fail_on_public",
+			[]string{"-fail-on=Public"},
+			"Overdue threatmodels holding",
+			true,
+			0,
+		},
+		{
+			"noheader",
+			[]string{"-noheader"},
+			"Last Reviewed",
+			true,
+			0,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := testStaleCommand(t)
+
+			var code int
+			out := capturer.CaptureStdout(func() {
+				code = cmd.Run(append(tc.flags, "./testdata/tm1.hcl"))
+			})
+
+			if code != tc.code {
+				t.Errorf("Code did not equal %d: %d", tc.code, code)
+			}
+
+			if !tc.invertexp && !strings.Contains(out, tc.exp) {
+				t.Errorf("Expected %s to contain %s", out, tc.exp)
+			}
+
+			if tc.invertexp && strings.Contains(out, tc.exp) {
+				t.Errorf("Was not expecting %s to contain %s", out, tc.exp)
+			}
+		})
+	}
+}
+
+func TestStaleCurrentModelHidden(t *testing.T) {
+	d := t.TempDir()
+	tmFile := filepath.Join(d, "current.hcl")
+
+	err := os.WriteFile(tmFile, []byte(`spec_version = "0.7.0"
+threatmodel "fresh" {
+  author = "@xntrik"
+  updated_at = 1893369600
+}
+`), 0600)
+	if err != nil {
+		t.Fatalf("Error writing tm file: %s", err)
+	}
+
+	cmd := testStaleCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{tmFile})
+	})
+
+	if code != 0 {
+		t.Errorf("Code did not equal 0: %d", code)
+	}
+
+	if strings.Contains(out, "fresh") {
+		t.Errorf("Expected a current model to be hidden without -all: %s", out)
+	}
+
+	out = capturer.CaptureStdout(func() {
+		code = testStaleCommand(t).Run([]string{"-all", tmFile})
+	})
+
+	if !strings.Contains(out, "fresh") || !strings.Contains(out, "ok") {
+		t.Errorf("Expected -all to list the current model as ok: %s", out)
+	}
+}
+
+func TestStaleBadCadenceFile(t *testing.T) {
+	d := t.TempDir()
+	cadenceFile := filepath.Join(d, "cadence.hcl")
+	if err := os.WriteFile(cadenceFile, []byte(`default_days = 0`), 0600); err != nil {
+		t.Fatalf("Error writing cadence file: %s", err)
+	}
+
+	cmd := testStaleCommand(t)
+
+	var code int
+	out := capturer.CaptureStdout(func() {
+		code = cmd.Run([]string{"-cadence=" + cadenceFile, "./testdata/tm1.hcl"})
+	})
+
+	if code != 1 {
+		t.Errorf("Code did not equal 1: %d", code)
+	}
+
+	if !strings.Contains(out, "Error parsing cadence file") {
+		t.Errorf("Expected %s to contain %s", out, "Error parsing cadence file")
+	}
+}
@@ -40,6 +40,12 @@ func Run(args []string) int {
 				specCfg:          cfg,
 			}, nil
 		},
+		"site": func() (cli.Command, error) {
+			return &SiteCommand{
+				GlobalCmdOptions: globalCmdOptions,
+				specCfg:          cfg,
+			}, nil
+		},
 		"validate": func() (cli.Command, error) {
 			return &ValidateCommand{
 				GlobalCmdOptions: globalCmdOptions,
@@ -85,6 +91,24 @@ func Run(args []string) int {
 				specCfg:          cfg,
 			}, nil
 		},
+		"dfd analyze": func() (cli.Command, error) {
+			return &DfdAnalyzeCommand{
+				GlobalCmdOptions: globalCmdOptions,
+				specCfg:          cfg,
+			}, nil
+		},
+		"dfd classify": func() (cli.Command, error) {
+			return &DfdClassifyCommand{
+				GlobalCmdOptions: globalCmdOptions,
+				specCfg:          cfg,
+			}, nil
+		},
+		"dfd validate": func() (cli.Command, error) {
+			return &DfdValidateCommand{
+				GlobalCmdOptions: globalCmdOptions,
+				specCfg:          cfg,
+			}, nil
+		},
 		"mermaid": func() (cli.Command, error) {
 			return &MermaidCommand{
 				GlobalCmdOptions: globalCmdOptions,
@@ -115,12 +139,54 @@ func Run(args []string) int {
 				specCfg:          cfg,
 			}, nil
 		},
+		"import": func() (cli.Command, error) {
+			return &ImportCommand{
+				GlobalCmdOptions: globalCmdOptions,
+				specCfg:          cfg,
+			}, nil
+		},
+		"import-dfd": func() (cli.Command, error) {
+			return &ImportDfdCommand{
+				GlobalCmdOptions: globalCmdOptions,
+				specCfg:          cfg,
+			}, nil
+		},
+		"scan": func() (cli.Command, error) {
+			return &ScanCommand{
+				GlobalCmdOptions: globalCmdOptions,
+				specCfg:          cfg,
+			}, nil
+		},
 		"server": func() (cli.Command, error) {
 			return &ServerCommand{
 				GlobalCmdOptions: globalCmdOptions,
 				specCfg:          cfg,
 			}, nil
 		},
+		"review": func() (cli.Command, error) {
+			return &ReviewCommand{
+				GlobalCmdOptions: globalCmdOptions,
+				specCfg:          cfg,
+			}, nil
+		},
+		"sign": func() (cli.Command, error) {
+			return &SignCommand{
+				GlobalCmdOptions: globalCmdOptions,
+				specCfg:          cfg,
+			}, nil
+		},
+		"stale": func() (cli.Command, error) {
+			return &StaleCommand{
+				GlobalCmdOptions: globalCmdOptions,
+				specCfg:          cfg,
+			}, nil
+		},
+		"verify": func() (cli.Command, error) {
+			return &VerifyCommand{
+				GlobalCmdOptions: globalCmdOptions,
+				specCfg:          cfg,
+			}, nil
+		},
 		"query": func() (cli.Command, error) {
 			return &QueryCommand{
 				GlobalCmdOptions: globalCmdOptions,
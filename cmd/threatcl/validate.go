@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/posener/complete"
 	"github.com/threatcl/spec"
@@ -19,6 +21,11 @@ type ValidateCommand struct {
 	flagStdin      bool
 	flagStdinJson  bool
 	flagInvariants string
+	flagReview     bool
+	flagReviewAge  int
+	flagSignature  string
+	flagProtected  string
+	now            func() time.Time
 }
 
 func (c *ValidateCommand) Help() string {
@@ -36,6 +43,24 @@ Options:
    Optional HCL file of invariant blocks to evaluate against the validated
    threat models. Invariant violations of severity "error" fail validation.
 
+ -require-review
+   If set, every threat model must have a review record (written by
+   'threatcl review') that matches its current content and skipped nothing
+
+ -review-max-age=<days>
+   If set, review records older than this many days fail validation.
+   Implies -require-review
+
+ -require-signature=<file>
+   If set, every protected threat model must carry a valid signature
+   (written by 'threatcl sign') from one of the trusted SSH public keys in
+   <file>. Without -protected, every threat model is protected
+
+ -protected=<globs>
+   Comma separated globs of the files whose threat models -require-signature
+   checks, such as models/prod/*.hcl. Protection is set here, where CI runs
+   validate, rather than in the models, so editing a model can't unprotect it
+
  -stdin
    If set, will expect a HCL file to be piped in
 
@@ -51,6 +76,10 @@ func (c *ValidateCommand) Run(args []string) int {
 	flagSet.BoolVar(&c.flagStdin, "stdin", false, "If set, will expect a HCL file to be piped in")
 	flagSet.BoolVar(&c.flagStdinJson, "stdinjson", false, "If set, will expect a JSON file to be piped in")
 	flagSet.StringVar(&c.flagInvariants, "invariants", "", "Optional HCL file of invariants to evaluate against the threat models")
+	flagSet.BoolVar(&c.flagReview, "require-review", false, "If set, every threat model must have a fresh review record")
+	flagSet.IntVar(&c.flagReviewAge, "review-max-age", 0, "Review records older than this many days fail validation")
+	flagSet.StringVar(&c.flagSignature, "require-signature", "", "Trusted SSH public keys file; every protected threat model must be signed by one of them")
+	flagSet.StringVar(&c.flagProtected, "protected", "", "Comma separated globs of the files -require-signature checks")
 	parseFlags(flagSet, args)
 
 	if c.flagConfig != "" {
@@ -67,6 +96,22 @@ func (c *ValidateCommand) Run(args []string) int {
 		return 1
 	}
 
+	requireReview := c.flagReview || c.flagReviewAge > 0
+	if requireReview && (c.flagStdin || c.flagStdinJson) {
+		fmt.Printf("You can't -require-review with -stdin or -stdinjson\n")
+		return 1
+	}
+
+	if c.flagSignature != "" && (c.flagStdin || c.flagStdinJson) {
+		fmt.Printf("You can't -require-signature with -stdin or -stdinjson\n")
+		return 1
+	}
+
+	if c.flagProtected != "" && c.flagSignature == "" {
+		fmt.Printf("-protected needs -require-signature\n")
+		return 1
+	}
+
 	var invs []*invariants.Invariant
 	if c.flagInvariants != "" {
 		var err error
@@ -170,6 +215,23 @@ func (c *ValidateCommand) Run(args []string) int {
 
 		fmt.Printf("Validated %d threatmodels in %d files\n", len(res.Models), len(res.Files))
 
+		if requireReview {
+			if code := c.checkReviews(res.Models); code != 0 {
+				return code
+			}
+		}
+
+		if c.flagSignature != "" {
+			protected, err := protectedModels(res.Models, c.flagProtected)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err)
+				return 1
+			}
+			if code := checkSignatures(protected, c.flagSignature, false); code != 0 {
+				return code
+			}
+		}
+
 		if invs != nil {
 			return c.runInvariants(invs, models)
 		}
@@ -179,6 +241,36 @@ func (c *ValidateCommand) Run(args []string) int {
 	return 0
 }
 
+// protectedModels returns the models declared in files matching one of the
+// comma separated globs, or every model if there are none. A glob matches a
+// file's path as given or its base name.
+func protectedModels(models []tmloader.LoadedModel, globs string) ([]tmloader.LoadedModel, error) {
+	if globs == "" {
+		return models, nil
+	}
+
+	patterns := strings.Split(globs, ",")
+	for _, p := range patterns {
+		if _, err := filepath.Match(strings.TrimSpace(p), ""); err != nil {
+			return nil, fmt.Errorf("invalid -protected glob %q: %s", p, err)
+		}
+	}
+
+	out := []tmloader.LoadedModel{}
+	for _, lm := range models {
+		for _, p := range patterns {
+			p = strings.TrimSpace(p)
+			full, _ := filepath.Match(p, lm.File)
+			base, _ := filepath.Match(p, filepath.Base(lm.File))
+			if full || base {
+				out = append(out, lm)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
 // wrappedModels pairs each threat model in a parsed file with its source, for
 // invariant violation reporting.
 func wrappedModels(wrapped *spec.ThreatmodelWrapped, source string) []*invariants.Model {
@@ -192,6 +284,33 @@ func wrappedModels(wrapped *spec.ThreatmodelWrapped, source string) []*invariant
 	return models
 }
 
+// checkReviews fails validation when any model lacks a fresh review record.
+func (c *ValidateCommand) checkReviews(models []tmloader.LoadedModel) int {
+	now := time.Now()
+	if c.now != nil {
+		now = c.now()
+	}
+
+	stale := 0
+	for _, lm := range models {
+		problem, err := checkReviewFreshness(lm, c.flagReviewAge, now)
+		if err != nil {
+			fmt.Printf("Error checking review of threatmodel '%s': %s\n", lm.TM.Name, err)
+			return 1
+		}
+		if problem != "" {
+			fmt.Printf("Review check failed: threatmodel '%s' (%s) %s\n", lm.TM.Name, lm.File, problem)
+			stale++
+		}
+	}
+
+	if stale > 0 {
+		fmt.Printf("%d of %d threatmodels don't have a fresh review\n", stale, len(models))
+		return 1
+	}
+	return 0
+}
+
 // runInvariants evaluates invariants against the validated models and prints
 // the outcome. Only error-severity violations make validation fail.
 func (c *ValidateCommand) runInvariants(invs []*invariants.Invariant, models []*invariants.Model) int {
@@ -232,7 +351,11 @@ func (c *ValidateCommand) Synopsis() string {
 func (c *ValidateCommand) AutocompleteArgs() complete.Predictor { return predictHCLOrJSON }
 func (c *ValidateCommand) AutocompleteFlags() complete.Flags {
 	return complete.Flags{
-		"-config":     predictHCL,
-		"-invariants": predictHCL,
+		"-config":            predictHCL,
+		"-invariants":        predictHCL,
+		"-require-review":    complete.PredictNothing,
+		"-review-max-age":    complete.PredictAnything,
+		"-require-signature": complete.PredictFiles("*"),
+		"-protected":         complete.PredictAnything,
 	}
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/posener/complete"
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/signing"
+	"github.com/threatcl/threatcl/internal/tmloader"
+)
+
+// VerifyCommand struct defines the "threatcl verify" command
+type VerifyCommand struct {
+	*GlobalCmdOptions
+	specCfg  *spec.ThreatmodelSpecConfig
+	flagKeys string
+}
+
+// Help is the help output for "threatcl verify"
+func (c *VerifyCommand) Help() string {
+	helpText := `
+Usage: threatcl verify -keys=<file> <files>
+
+  Verify that every threat model in <files> carries a valid signature (made
+  with 'threatcl sign') from one of the trusted keys.
+
+  Verification fails if a model is unsigned, is only signed by untrusted
+  keys, or has changed since it was signed.
+
+Options:
+
+ -config=<file>
+   Optional config file
+
+ -keys=<file>
+   Required. Trusted SSH public keys, one per line in authorized_keys format.
+   A single .pub file also works
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run executes "threatcl verify" logic
+func (c *VerifyCommand) Run(args []string) int {
+	flagSet := c.GetFlagset("verify")
+	flagSet.StringVar(&c.flagKeys, "keys", "", "Trusted SSH public keys file")
+	parseFlags(flagSet, args)
+
+	if c.flagConfig != "" {
+		err := c.specCfg.LoadSpecConfigFile(c.flagConfig)
+
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			return 1
+		}
+	}
+
+	if len(flagSet.Args()) == 0 {
+		fmt.Printf("Please provide file(s)\n\n")
+		fmt.Println(c.Help())
+		return 1
+	}
+
+	if c.flagKeys == "" {
+		fmt.Printf("Please provide trusted keys with -keys\n")
+		return 1
+	}
+
+	res, err := tmloader.LoadSet(c.specCfg, flagSet.Args())
+	if err != nil {
+		fmt.Printf("%s\n", err)
+		return 1
+	}
+
+	return checkSignatures(res.Models, c.flagKeys, true)
+}
+
+// checkSignatures verifies every model's signature against the trusted keys
+// in keysFile, printing failures (and, with verbose, successes too).
+func checkSignatures(models []tmloader.LoadedModel, keysFile string, verbose bool) int {
+	trusted, err := signing.LoadTrustedKeys(keysFile)
+	if err != nil {
+		fmt.Printf("Error loading trusted keys: %s\n", err)
+		return 1
+	}
+
+	sidecars := map[string]*signing.File{}
+	failed := 0
+
+	for _, lm := range models {
+		path := signing.SidecarPath(lm.File)
+		sf, ok := sidecars[path]
+		if !ok {
+			sf, err = signing.ReadFile(path)
+			if err != nil {
+				fmt.Printf("Error reading signatures: %s\n", err)
+				return 1
+			}
+			sidecars[path] = sf
+		}
+
+		sig, err := sf.Verify(lm.TM, trusted)
+		if err != nil {
+			fmt.Printf("Signature check failed: threatmodel '%s' (%s): %s\n", lm.TM.Name, lm.File, err)
+			failed++
+			continue
+		}
+
+		if verbose {
+			by := sig.Fingerprint
+			if sig.Signer != "" {
+				by = fmt.Sprintf("%s (%s)", sig.Signer, sig.Fingerprint)
+			}
+			fmt.Printf("Verified threatmodel '%s' (%s), signed by %s on %s\n",
+				lm.TM.Name, lm.File, by, sig.SignedAt.Format("2006-01-02"))
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("%d of %d threatmodels failed signature verification\n", failed, len(models))
+		return 1
+	}
+
+	if verbose {
+		fmt.Printf("Verified %d threatmodels\n", len(models))
+	}
+	return 0
+}
+
+// Synopsis returns the synopsis for the "threatcl verify" command
+func (c *VerifyCommand) Synopsis() string {
+	return "Verify threat model signatures"
+}
+
+func (c *VerifyCommand) AutocompleteArgs() complete.Predictor { return predictHCLOrJSON }
+func (c *VerifyCommand) AutocompleteFlags() complete.Flags {
+	return complete.Flags{
+		"-config": predictHCL,
+		"-keys":   complete.PredictFiles("*"),
+	}
+}
@@ -0,0 +1,180 @@
+// ATT&CK techniques for the mitigations in MITRE_ATTACK_controls.hcl, for
+// threatcl export -format=attack-navigator -attack-mapping=<file>.
+//
+// Each mitigation lists the main Enterprise techniques it addresses on
+// attack.mitre.org, not every one. Add the others your controls cover.
+
+mitigation "M1036" { // Account Use Policies
+  techniques = ["T1110", "T1110.001", "T1110.003"]
+}
+
+mitigation "M1015" { // Active Directory Configuration
+  techniques = ["T1134.005", "T1558"]
+}
+
+mitigation "M1049" { // Antivirus/Antimalware
+  techniques = ["T1027", "T1059.001", "T1566.001"]
+}
+
+mitigation "M1048" { // Application Isolation and Sandboxing
+  techniques = ["T1189", "T1190", "T1203"]
+}
+
+mitigation "M1047" { // Audit
+  techniques = ["T1053", "T1574"]
+}
+
+mitigation "M1040" { // Behavior Prevention on Endpoint
+  techniques = ["T1003.001", "T1055"]
+}
+
+mitigation "M1046" { // Boot Integrity
+  techniques = ["T1542", "T1542.003"]
+}
+
+mitigation "M1045" { // Code Signing
+  techniques = ["T1059.001", "T1554"]
+}
+
+mitigation "M1043" { // Credential Access Protection
+  techniques = ["T1003", "T1003.001"]
+}
+
+mitigation "M1053" { // Data Backup
+  techniques = ["T1485", "T1486", "T1490", "T1561"]
+}
+
+mitigation "M1057" { // Data Loss Prevention
+  techniques = ["T1020", "T1048", "T1052", "T1567"]
+}
+
+mitigation "M1042" { // Disable or Remove Feature or Program
+  techniques = ["T1021.001", "T1557.001"]
+}
+
+mitigation "M1041" { // Encrypt Sensitive Information
+  techniques = ["T1040", "T1530", "T1557"]
+}
+
+mitigation "M1039" { // Environment Variable Permissions
+  techniques = ["T1574.007"]
+}
+
+mitigation "M1038" { // Execution Prevention
+  techniques = ["T1059", "T1204.002", "T1218"]
+}
+
+mitigation "M1050" { // Exploit Protection
+  techniques = ["T1068", "T1190", "T1203", "T1211"]
+}
+
+mitigation "M1037" { // Filter Network Traffic
+  techniques = ["T1048", "T1498", "T1557"]
+}
+
+mitigation "M1035" { // Limit Access to Resource Over Network
+  techniques = ["T1021.001", "T1133"]
+}
+
+mitigation "M1034" { // Limit Hardware Installation
+  techniques = ["T1052.001", "T1091", "T1200"]
+}
+
+mitigation "M1033" { // Limit Software Installation
+  techniques = ["T1176"]
+}
+
+mitigation "M1032" { // Multi-factor Authentication
+  techniques = ["T1021", "T1078", "T1110", "T1133"]
+}
+
+mitigation "M1031" { // Network Intrusion Prevention
+  techniques = ["T1071", "T1095", "T1566"]
+}
+
+mitigation "M1030" { // Network Segmentation
+  techniques = ["T1046", "T1190", "T1210"]
+}
+
+mitigation "M1028" { // Operating System Configuration
+  techniques = ["T1003"]
+}
+
+mitigation "M1027" { // Password Policies
+  techniques = ["T1110", "T1110.002"]
+}
+
+mitigation "M1056" { // Pre-compromise
+  techniques = ["T1583", "T1588", "T1589", "T1595"]
+}
+
+mitigation "M1026" { // Privileged Account Management
+  techniques = ["T1003", "T1053", "T1098"]
+}
+
+mitigation "M1025" { // Privileged Process Integrity
+  techniques = ["T1003.001", "T1547.008"]
+}
+
+mitigation "M1029" { // Remote Data Storage
+  techniques = ["T1070"]
+}
+
+mitigation "M1022" { // Restrict File and Directory Permissions
+  techniques = ["T1574"]
+}
+
+mitigation "M1044" { // Restrict Library Loading
+  techniques = ["T1574.001"]
+}
+
+mitigation "M1024" { // Restrict Registry Permissions
+  techniques = ["T1112", "T1574.011"]
+}
+
+mitigation "M1021" { // Restrict Web-Based Content
+  techniques = ["T1189", "T1566", "T1567"]
+}
+
+mitigation "M1054" { // Software Configuration
+  techniques = ["T1566"]
+}
+
+mitigation "M1020" { // SSL/TLS Inspection
+  techniques = ["T1071", "T1573"]
+}
+
+mitigation "M1051" { // Update Software
+  techniques = ["T1068", "T1190", "T1203", "T1210"]
+}
+
+mitigation "M1052" { // User Account Control
+  techniques = ["T1548.002"]
+}
+
+mitigation "M1018" { // User Account Management
+  techniques = ["T1078", "T1098"]
+}
+
+mitigation "M1017" { // User Training
+  techniques = ["T1204", "T1566"]
+}
+
+mitigation "M1016" { // Vulnerability Scanning
+  techniques = ["T1190", "T1210"]
+}
+
+// Broad programmes rather than defences against particular techniques, so
+// they score nothing.
+
+mitigation "M1013" { // Application Developer Guidance
+  techniques = []
+}
+
+mitigation "M1019" { // Threat Intelligence Program
+  techniques = []
+}
+
+mitigation "M1055" { // Do Not Mitigate
+  techniques = []
+}
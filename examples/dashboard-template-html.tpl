@@ -8,9 +8,9 @@ A dashboard of threat models.
 <h2>Threat Models</h2>
 
 <table>
-  <tr><th>name</th><th>author</th><th>new initiative?</th><th>internet facing?</th><th>size</th></tr>
+  <tr><th>name</th><th>author</th><th>new initiative?</th><th>internet facing?</th><th>size</th><th>last reviewed</th><th>due</th></tr>
 {{- range . }}
-  <tr><td><a href="{{ .File }}" title="{{ .Hover }}">{{ .Name }}</a></td><td>{{ .Author }}</td><td>{{ .NewInitiative }}</td><td>{{ .InternetFacing }}</td><td>{{ .Size }}</td></tr>
+  <tr><td><a href="{{ .File }}" title="{{ .Hover }}">{{ .Name }}</a></td><td>{{ .Author }}</td><td>{{ .NewInitiative }}</td><td>{{ .InternetFacing }}</td><td>{{ .Size }}</td><td>{{ .LastReviewed }}</td><td>{{ if .Overdue }}<strong>{{ .Due }}</strong>{{ else }}{{ .Due }}{{ end }}</td></tr>
 {{- end }}
 </table>
 </body>
@@ -4,8 +4,8 @@ A dashboard of threat models.
 
 ## Threat Models
 
-| name | author | new initiative? | internet facing? | size |
-| -- | -- | -- | -- | -- |
+| name | author | new initiative? | internet facing? | size | last reviewed | due |
+| -- | -- | -- | -- | -- | -- | -- |
 {{- range . }}
-| [{{ .Name }}]({{ .File }} "{{ .Hover }}") | {{ .Author }} | {{ .NewInitiative }} | {{ .InternetFacing }} | {{ .Size }} |
+| [{{ .Name }}]({{ .File }} "{{ .Hover }}") | {{ .Author }} | {{ .NewInitiative }} | {{ .InternetFacing }} | {{ .Size }} | {{ .LastReviewed }} | {{ if .Overdue }}**{{ .Due }}**{{ else }}{{ .Due }}{{ end }} |
 {{- end }}
@@ -0,0 +1,204 @@
+// Package attack maps controls onto MITRE ATT&CK and builds ATT&CK Navigator
+// layers that score techniques by how many of their controls are
+// implemented.
+//
+// A control names ATT&CK techniques and mitigations in attack_technique and
+// attack_mitigation attributes, or links to them on attack.mitre.org in its
+// description (as the controls in examples/MITRE_ATTACK_controls.hcl do). A
+// mapping file adds techniques for mitigations and for controls by name, as
+// the Navigator only scores techniques.
+package attack
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/threatcl/spec"
+)
+
+// Control attributes holding ATT&CK ids. Values are comma or space separated
+// lists.
+const (
+	TechniqueAttribute  = "attack_technique"
+	MitigationAttribute = "attack_mitigation"
+)
+
+var (
+	techniqueID  = regexp.MustCompile(`^T\d{4}(\.\d{3})?$`)
+	mitigationID = regexp.MustCompile(`^M\d{4}$`)
+
+	// attackLink is a link to a technique or mitigation on attack.mitre.org.
+	attackLink = regexp.MustCompile(`attack\.mitre\.org/(?:mitigations/(M\d{4})|techniques/(T\d{4})(?:/(\d{3}))?)`)
+)
+
+// Mapping maps mitigations and controls (by name) onto techniques.
+type Mapping struct {
+	Mitigations map[string][]string
+	Controls    map[string]ControlMapping
+}
+
+// ControlMapping is the techniques and mitigations a mapping file gives a
+// control.
+type ControlMapping struct {
+	Techniques  []string
+	Mitigations []string
+}
+
+type mappingHCL struct {
+	Mitigations []struct {
+		ID         string   `hcl:"id,label"`
+		Techniques []string `hcl:"techniques"`
+	} `hcl:"mitigation,block"`
+	Controls []struct {
+		Name        string   `hcl:"name,label"`
+		Techniques  []string `hcl:"techniques,optional"`
+		Mitigations []string `hcl:"mitigations,optional"`
+	} `hcl:"control,block"`
+}
+
+// NewMapping returns an empty mapping.
+func NewMapping() *Mapping {
+	return &Mapping{Mitigations: map[string][]string{}, Controls: map[string]ControlMapping{}}
+}
+
+// ParseFile parses a mapping file:
+//
+//	mitigation "M1036" {
+//	  techniques = ["T1110"]
+//	}
+//
+//	control "Web application firewall" {
+//	  techniques  = ["T1190"]
+//	  mitigations = ["M1050"]
+//	}
+func ParseFile(path string) (*Mapping, error) {
+	parser := hclparse.NewParser()
+	f, diags := parser.ParseHCLFile(path)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	var raw mappingHCL
+	diags = gohcl.DecodeBody(f.Body, nil, &raw)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	m := NewMapping()
+	for _, mit := range raw.Mitigations {
+		id := strings.ToUpper(mit.ID)
+		if !mitigationID.MatchString(id) {
+			return nil, fmt.Errorf("mitigation %q isn't an ATT&CK mitigation id", mit.ID)
+		}
+		techniques, err := validIDs(mit.Techniques, techniqueID, "technique")
+		if err != nil {
+			return nil, fmt.Errorf("mitigation %q: %s", mit.ID, err)
+		}
+		m.Mitigations[id] = append(m.Mitigations[id], techniques...)
+	}
+	for _, c := range raw.Controls {
+		techniques, err := validIDs(c.Techniques, techniqueID, "technique")
+		if err != nil {
+			return nil, fmt.Errorf("control %q: %s", c.Name, err)
+		}
+		mitigations, err := validIDs(c.Mitigations, mitigationID, "mitigation")
+		if err != nil {
+			return nil, fmt.Errorf("control %q: %s", c.Name, err)
+		}
+		cm := m.Controls[c.Name]
+		cm.Techniques = append(cm.Techniques, techniques...)
+		cm.Mitigations = append(cm.Mitigations, mitigations...)
+		m.Controls[c.Name] = cm
+	}
+	return m, nil
+}
+
+func validIDs(ids []string, re *regexp.Regexp, what string) ([]string, error) {
+	out := []string{}
+	for _, id := range ids {
+		id = strings.ToUpper(strings.TrimSpace(id))
+		if !re.MatchString(id) {
+			return nil, fmt.Errorf("%q isn't an ATT&CK %s id", id, what)
+		}
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+// IDs returns the techniques and mitigations c names in its attributes and
+// description, and those the mapping adds for it by name. Invalid attribute
+// values are returned as warnings.
+func (m *Mapping) IDs(c *spec.Control) (techniques, mitigations, warnings []string) {
+	t, mit := map[string]bool{}, map[string]bool{}
+
+	for _, a := range c.Attributes {
+		var (
+			re   *regexp.Regexp
+			into map[string]bool
+		)
+		switch strings.ToLower(a.Name) {
+		case TechniqueAttribute, TechniqueAttribute + "s":
+			re, into = techniqueID, t
+		case MitigationAttribute, MitigationAttribute + "s":
+			re, into = mitigationID, mit
+		default:
+			continue
+		}
+		for _, id := range strings.FieldsFunc(a.Value, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\t' }) {
+			id = strings.ToUpper(id)
+			if !re.MatchString(id) {
+				warnings = append(warnings, fmt.Sprintf("control %q: %s %q isn't an ATT&CK id, skipped", c.Name, a.Name, id))
+				continue
+			}
+			into[id] = true
+		}
+	}
+
+	linkedT, linkedMit := Links(c.Description)
+	for _, id := range linkedT {
+		t[id] = true
+	}
+	for _, id := range linkedMit {
+		mit[id] = true
+	}
+
+	if cm, ok := m.Controls[c.Name]; ok {
+		for _, id := range cm.Techniques {
+			t[id] = true
+		}
+		for _, id := range cm.Mitigations {
+			mit[id] = true
+		}
+	}
+
+	return sortedKeys(t), sortedKeys(mit), warnings
+}
+
+// Links returns the techniques and mitigations text links to on
+// attack.mitre.org, in the order they're linked.
+func Links(text string) (techniques, mitigations []string) {
+	for _, l := range attackLink.FindAllStringSubmatch(text, -1) {
+		switch {
+		case l[1] != "":
+			mitigations = append(mitigations, l[1])
+		case l[3] != "":
+			techniques = append(techniques, l[2]+"."+l[3])
+		default:
+			techniques = append(techniques, l[2])
+		}
+	}
+	return techniques, mitigations
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,187 @@
+package attack
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+func writeMapping(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mapping.hcl")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("error writing mapping: %s", err)
+	}
+	return path
+}
+
+func TestParseFile(t *testing.T) {
+	path := writeMapping(t, `
+mitigation "m1036" {
+  techniques = ["T1110", "t1110.001"]
+}
+
+control "WAF" {
+  techniques  = ["T1190"]
+  mitigations = ["M1050"]
+}
+`)
+	m, err := ParseFile(path)
+	if err != nil {
+		t.Fatalf("error parsing mapping: %s", err)
+	}
+	if !reflect.DeepEqual(m.Mitigations["M1036"], []string{"T1110", "T1110.001"}) {
+		t.Errorf("unexpected mitigation mapping: %v", m.Mitigations)
+	}
+	if !reflect.DeepEqual(m.Controls["WAF"], ControlMapping{Techniques: []string{"T1190"}, Mitigations: []string{"M1050"}}) {
+		t.Errorf("unexpected control mapping: %v", m.Controls)
+	}
+}
+
+// TestExampleMapping checks that the shipped mapping covers every
+// mitigation the example ATT&CK controls link to.
+func TestExampleMapping(t *testing.T) {
+	m, err := ParseFile(filepath.Join("..", "..", "examples", "attack-mapping.hcl"))
+	if err != nil {
+		t.Fatalf("error parsing the example mapping: %s", err)
+	}
+	controls, err := os.ReadFile(filepath.Join("..", "..", "examples", "MITRE_ATTACK_controls.hcl"))
+	if err != nil {
+		t.Fatalf("error reading the example controls: %s", err)
+	}
+
+	techniques := 0
+	for _, link := range attackLink.FindAllStringSubmatch(string(controls), -1) {
+		if link[1] == "" {
+			continue
+		}
+		mapped, ok := m.Mitigations[link[1]]
+		if !ok {
+			t.Errorf("mitigation %s isn't mapped", link[1])
+		}
+		techniques += len(mapped)
+	}
+	if techniques == 0 {
+		t.Error("expected the example mitigations to map onto techniques")
+	}
+}
+
+func TestParseFileInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		exp     string
+	}{
+		{"mitigation id", `mitigation "T1110" { techniques = [] }`, `mitigation "T1110" isn't an ATT&CK mitigation id`},
+		{"technique id", `mitigation "M1036" { techniques = ["M1110"] }`, `"M1110" isn't an ATT&CK technique id`},
+		{"control mitigation", `control "WAF" { mitigations = ["T1190"] }`, `control "WAF": "T1190" isn't an ATT&CK mitigation id`},
+		{"unknown block", `technique "T1110" {}`, "Unsupported block type"},
+		{"syntax", `mitigation "M1036" {`, "Unclosed configuration block"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseFile(writeMapping(t, tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.exp) {
+				t.Errorf("expected error containing %q, got %v", tc.exp, err)
+			}
+		})
+	}
+}
+
+func TestIDs(t *testing.T) {
+	m := NewMapping()
+	m.Controls["MFA"] = ControlMapping{Techniques: []string{"T1078"}}
+
+	c := &spec.Control{
+		Name:        "MFA",
+		Description: "See https://attack.mitre.org/mitigations/M1032/ and https://attack.mitre.org/techniques/T1110/003/",
+		Attributes: []*spec.ControlAttribute{
+			{Name: "attack_technique", Value: "t1110, T1556"},
+			{Name: "attack_mitigations", Value: "M1036 nope"},
+			{Name: "owner", Value: "T9999"},
+		},
+	}
+	techniques, mitigations, warnings := m.IDs(c)
+
+	if exp := []string{"T1078", "T1110", "T1110.003", "T1556"}; !reflect.DeepEqual(techniques, exp) {
+		t.Errorf("expected techniques %v, got %v", exp, techniques)
+	}
+	if exp := []string{"M1032", "M1036"}; !reflect.DeepEqual(mitigations, exp) {
+		t.Errorf("expected mitigations %v, got %v", exp, mitigations)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], `attack_mitigations "NOPE" isn't an ATT&CK id`) {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+}
+
+func TestNewLayer(t *testing.T) {
+	m := NewMapping()
+	m.Mitigations["M1036"] = []string{"T1110"}
+
+	tms := []spec.Threatmodel{
+		{
+			Name: "Shop",
+			Threats: []*spec.Threat{
+				{
+					Name: "Credential stuffing",
+					Controls: []*spec.Control{
+						{Name: "Lockout", Implemented: true, Attributes: []*spec.ControlAttribute{{Name: "attack_mitigation", Value: "M1036"}}},
+						{Name: "MFA", Attributes: []*spec.ControlAttribute{{Name: "attack_technique", Value: "T1110"}}},
+						{Name: "Training", Attributes: []*spec.ControlAttribute{{Name: "attack_mitigation", Value: "M1017"}}},
+					},
+				},
+			},
+		},
+		{
+			Name: "Warehouse",
+			Threats: []*spec.Threat{
+				{
+					Name: "Exploit",
+					Controls: []*spec.Control{
+						{Name: "WAF", Implemented: true, Description: "https://attack.mitre.org/techniques/T1190/"},
+					},
+				},
+			},
+		},
+	}
+
+	res := NewLayer(tms, m)
+	l := res.Layer
+	if l.Name != "threatcl" || l.Domain != Domain || l.Versions.Layer != LayerVersion {
+		t.Errorf("unexpected layer header: %+v", l)
+	}
+	if len(l.Metadata) != 2 {
+		t.Errorf("expected both threat models in the layer metadata, got %v", l.Metadata)
+	}
+	if len(l.Techniques) != 2 {
+		t.Fatalf("expected 2 techniques, got %+v", l.Techniques)
+	}
+
+	brute := l.Techniques[0]
+	if brute.TechniqueID != "T1110" || brute.Score != 50 || brute.Comment != "1 of 2 controls implemented" {
+		t.Errorf("unexpected T1110 scoring: %+v", brute)
+	}
+	if len(brute.Metadata) != 2 || brute.Metadata[0] != (Metadata{Name: "implemented", Value: "Shop / Credential stuffing / Lockout"}) {
+		t.Errorf("unexpected T1110 metadata: %v", brute.Metadata)
+	}
+	if waf := l.Techniques[1]; waf.TechniqueID != "T1190" || waf.Score != 100 {
+		t.Errorf("unexpected T1190 scoring: %+v", waf)
+	}
+
+	if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], `mitigation M1017 (control "Training") isn't mapped`) {
+		t.Errorf("unexpected warnings: %v", res.Warnings)
+	}
+
+	single := NewLayer(tms[:1], nil)
+	if single.Layer.Name != "Shop" {
+		t.Errorf("expected a single model layer to be named after it, got %q", single.Layer.Name)
+	}
+	// without a mapping only the direct technique is scored
+	if len(single.Layer.Techniques) != 1 || single.Layer.Techniques[0].Score != 0 {
+		t.Errorf("unexpected techniques without a mapping: %+v", single.Layer.Techniques)
+	}
+}
@@ -0,0 +1,189 @@
+package attack
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// Navigator layer format versions written by NewLayer.
+const (
+	LayerVersion     = "4.5"
+	NavigatorVersion = "5.1.0"
+	Domain           = "enterprise-attack"
+)
+
+// Layer is an ATT&CK Navigator layer.
+type Layer struct {
+	Name        string       `json:"name"`
+	Versions    Versions     `json:"versions"`
+	Domain      string       `json:"domain"`
+	Description string       `json:"description"`
+	Techniques  []Technique  `json:"techniques"`
+	Gradient    Gradient     `json:"gradient"`
+	LegendItems []LegendItem `json:"legendItems"`
+	Metadata    []Metadata   `json:"metadata,omitempty"`
+}
+
+type Versions struct {
+	Layer     string `json:"layer"`
+	Navigator string `json:"navigator"`
+}
+
+type Technique struct {
+	TechniqueID       string     `json:"techniqueID"`
+	Score             int        `json:"score"`
+	Comment           string     `json:"comment,omitempty"`
+	Enabled           bool       `json:"enabled"`
+	Metadata          []Metadata `json:"metadata,omitempty"`
+	ShowSubtechniques bool       `json:"showSubtechniques"`
+}
+
+type Gradient struct {
+	Colors   []string `json:"colors"`
+	MinValue int      `json:"minValue"`
+	MaxValue int      `json:"maxValue"`
+}
+
+type LegendItem struct {
+	Label string `json:"label"`
+	Color string `json:"color"`
+}
+
+type Metadata struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Result is a layer and anything that couldn't be scored.
+type Result struct {
+	Layer    Layer
+	Warnings []string
+}
+
+// use is one control mapped onto a technique.
+type use struct {
+	model, threat, control string
+	implemented            bool
+}
+
+func (u use) String() string {
+	return fmt.Sprintf("%s / %s / %s", u.model, u.threat, u.control)
+}
+
+// NewLayer scores every technique the controls of tms map onto, directly or
+// through a mitigation, by the share of those controls that are implemented:
+// 0 when they're all planned, 100 when they're all implemented.
+func NewLayer(tms []spec.Threatmodel, m *Mapping) *Result {
+	if m == nil {
+		m = NewMapping()
+	}
+	res := &Result{}
+	uses := map[string][]use{}
+	unmapped := map[string][]string{}
+
+	for _, tm := range tms {
+		for _, t := range tm.Threats {
+			for _, c := range tmutil.AllControls(t) {
+				techniques, mitigations, warnings := m.IDs(c)
+				res.Warnings = append(res.Warnings, warnings...)
+
+				ids := map[string]bool{}
+				for _, id := range techniques {
+					ids[id] = true
+				}
+				for _, mit := range mitigations {
+					mapped, ok := m.Mitigations[mit]
+					if !ok {
+						unmapped[mit] = append(unmapped[mit], c.Name)
+					}
+					for _, id := range mapped {
+						ids[id] = true
+					}
+				}
+
+				u := use{model: tm.Name, threat: t.Name, control: c.Name, implemented: c.Implemented}
+				for id := range ids {
+					uses[id] = append(uses[id], u)
+				}
+			}
+		}
+	}
+
+	mits := make([]string, 0, len(unmapped))
+	for mit := range unmapped {
+		mits = append(mits, mit)
+	}
+	sort.Strings(mits)
+	for _, mit := range mits {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("mitigation %s (control %s) isn't mapped to any techniques, add it to the mapping file to score it", mit, quoteList(unmapped[mit])))
+	}
+
+	name := "threatcl"
+	desc := fmt.Sprintf("ATT&CK techniques mapped from the controls of %d threat models, scored by the percentage of their controls that are implemented.", len(tms))
+	if len(tms) == 1 {
+		name = tms[0].Name
+		desc = fmt.Sprintf("ATT&CK techniques mapped from the controls of %s, scored by the percentage of their controls that are implemented.", tms[0].Name)
+	}
+
+	res.Layer = Layer{
+		Name:        name,
+		Versions:    Versions{Layer: LayerVersion, Navigator: NavigatorVersion},
+		Domain:      Domain,
+		Description: desc,
+		Techniques:  []Technique{},
+		Gradient: Gradient{
+			Colors:   []string{"#ff6666ff", "#ffe766ff", "#8ec843ff"},
+			MinValue: 0,
+			MaxValue: 100,
+		},
+		LegendItems: []LegendItem{
+			{Label: "Controls planned", Color: "#ff6666ff"},
+			{Label: "Controls partly implemented", Color: "#ffe766ff"},
+			{Label: "Controls implemented", Color: "#8ec843ff"},
+		},
+	}
+	for _, tm := range tms {
+		res.Layer.Metadata = append(res.Layer.Metadata, Metadata{Name: "threat model", Value: tm.Name})
+	}
+
+	ids := make([]string, 0, len(uses))
+	for id := range uses {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		implemented := 0
+		tech := Technique{TechniqueID: id, Enabled: true}
+		for _, u := range uses[id] {
+			if u.implemented {
+				implemented++
+				tech.Metadata = append(tech.Metadata, Metadata{Name: "implemented", Value: u.String()})
+			} else {
+				tech.Metadata = append(tech.Metadata, Metadata{Name: "planned", Value: u.String()})
+			}
+		}
+		total := len(uses[id])
+		tech.Score = implemented * 100 / total
+		tech.Comment = fmt.Sprintf("%d of %d controls implemented", implemented, total)
+		res.Layer.Techniques = append(res.Layer.Techniques, tech)
+	}
+
+	return res
+}
+
+func quoteList(names []string) string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, n := range names {
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, fmt.Sprintf("%q", n))
+		}
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,257 @@
+// Package cadence tracks how recently each threat model was reviewed and when
+// its next review is due. The optional created_at/updated_at attributes are
+// rarely kept current, so the "last reviewed" time is the most recent of those
+// attributes and the model file's last git commit. The review cadence (how
+// often a model must be looked at) is configurable per initiative_size and per
+// information_classification in a small HCL policy file; the strictest
+// applicable cadence wins.
+package cadence
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/threatcl/spec"
+)
+
+// Policy is a parsed review-cadence file. All durations are in days.
+type Policy struct {
+	DefaultDays        int
+	InitiativeSizes    map[string]int
+	Classifications    map[string]int
+	FailClassification []string
+}
+
+type policyHCL struct {
+	DefaultDays     *int           `hcl:"default_days,optional"`
+	InitiativeSizes map[string]int `hcl:"initiative_size,optional"`
+	Classifications map[string]int `hcl:"information_classification,optional"`
+	FailOn          []string       `hcl:"fail_on_classification,optional"`
+}
+
+// DefaultPolicy is used when no cadence file is provided: models are reviewed
+// yearly, and more often when they hold sensitive information assets.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		DefaultDays:     365,
+		InitiativeSizes: map[string]int{},
+		Classifications: map[string]int{
+			"Restricted":   90,
+			"Confidential": 180,
+		},
+	}
+}
+
+// ParseFile parses a review-cadence HCL file.
+func ParseFile(path string) (*Policy, error) {
+	parser := hclparse.NewParser()
+	f, diags := parser.ParseHCLFile(path)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	var raw policyHCL
+	diags = gohcl.DecodeBody(f.Body, nil, &raw)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	p := DefaultPolicy()
+	if raw.DefaultDays != nil {
+		p.DefaultDays = *raw.DefaultDays
+	}
+	// A file that sets classifications replaces the built-in ones rather than
+	// merging, so a team can relax the defaults.
+	if raw.Classifications != nil {
+		p.Classifications = raw.Classifications
+	}
+	if raw.InitiativeSizes != nil {
+		p.InitiativeSizes = raw.InitiativeSizes
+	}
+	p.FailClassification = raw.FailOn
+
+	if p.DefaultDays <= 0 {
+		return nil, fmt.Errorf("default_days must be greater than 0")
+	}
+	for k, v := range p.InitiativeSizes {
+		if v <= 0 {
+			return nil, fmt.Errorf("initiative_size %q: days must be greater than 0", k)
+		}
+	}
+	for k, v := range p.Classifications {
+		if v <= 0 {
+			return nil, fmt.Errorf("information_classification %q: days must be greater than 0", k)
+		}
+	}
+
+	return p, nil
+}
+
+// CadenceFor returns the review cadence, in days, that applies to tm and a
+// short human-readable reason naming the rule that set it.
+func (p *Policy) CadenceFor(tm *spec.Threatmodel) (int, string) {
+	days := p.DefaultDays
+	reason := "default"
+
+	if tm.Attributes != nil {
+		if d, ok := lookupFold(p.InitiativeSizes, tm.Attributes.InitiativeSize); ok && d < days {
+			days = d
+			reason = fmt.Sprintf("initiative_size %s", tm.Attributes.InitiativeSize)
+		}
+	}
+
+	for _, ia := range tm.InformationAssets {
+		if d, ok := lookupFold(p.Classifications, ia.InformationClassification); ok && d < days {
+			days = d
+			reason = fmt.Sprintf("classification %s", ia.InformationClassification)
+		}
+	}
+
+	return days, reason
+}
+
+// HoldsClassification reports whether any of tm's information assets carry one
+// of the given classifications (case-insensitive).
+func HoldsClassification(tm *spec.Threatmodel, classifications []string) bool {
+	for _, ia := range tm.InformationAssets {
+		for _, c := range classifications {
+			if strings.EqualFold(ia.InformationClassification, c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func lookupFold(m map[string]int, key string) (int, bool) {
+	if key == "" {
+		return 0, false
+	}
+	if v, ok := m[key]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// Status is the review state of a single threat model at a point in time.
+type Status struct {
+	CadenceDays  int
+	Reason       string
+	LastReviewed time.Time
+	Source       string
+	Due          time.Time
+	Overdue      bool
+	DaysOverdue  int
+}
+
+// Reviewed is one candidate "last reviewed" timestamp and where it came from.
+type Reviewed struct {
+	At     time.Time
+	Source string
+}
+
+// Evaluate computes tm's review status. extra carries additional evidence of
+// review beyond the model's own attributes, such as the file's last git commit.
+// A model with no evidence of review at all is reported as overdue.
+func (p *Policy) Evaluate(tm *spec.Threatmodel, extra []Reviewed, now time.Time) Status {
+	days, reason := p.CadenceFor(tm)
+	st := Status{CadenceDays: days, Reason: reason}
+
+	candidates := []Reviewed{}
+	if tm.UpdatedAt != 0 {
+		candidates = append(candidates, Reviewed{At: time.Unix(tm.UpdatedAt, 0), Source: "updated_at"})
+	} else if tm.CreatedAt != 0 {
+		candidates = append(candidates, Reviewed{At: time.Unix(tm.CreatedAt, 0), Source: "created_at"})
+	}
+	candidates = append(candidates, extra...)
+
+	for _, c := range candidates {
+		if !c.At.IsZero() && c.At.After(st.LastReviewed) {
+			st.LastReviewed = c.At
+			st.Source = c.Source
+		}
+	}
+
+	if st.LastReviewed.IsZero() {
+		st.Overdue = true
+		return st
+	}
+
+	st.Due = st.LastReviewed.AddDate(0, 0, days)
+	if now.After(st.Due) {
+		st.Overdue = true
+		st.DaysOverdue = int(now.Sub(st.Due).Hours() / 24)
+	}
+	return st
+}
+
+// FormatDate renders t as a date, or "never" for the zero time.
+func FormatDate(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Format("2006-01-02")
+}
+
+// GitLastModified returns the time of the last git commit that touched path.
+// It reports false when git isn't available, the file isn't tracked, or it has
+// no commits yet.
+func GitLastModified(path string) (time.Time, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	out, err := exec.Command("git", "-C", filepath.Dir(abs), "log", "-1", "--format=%ct", "--", filepath.Base(abs)).Output()
+	if err != nil {
+		return time.Time{}, false
+	}
+	ts := strings.TrimSpace(string(out))
+	if ts == "" {
+		return time.Time{}, false
+	}
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(secs, 0), true
+}
+
+// Sources collects the review evidence for a model declared in file, caching
+// git lookups so that a file holding several models is only queried once.
+type Sources struct {
+	gitTimes map[string]*time.Time
+}
+
+// NewSources returns an empty evidence collector.
+func NewSources() *Sources {
+	return &Sources{gitTimes: map[string]*time.Time{}}
+}
+
+// For returns the extra review evidence available for file.
+func (s *Sources) For(file string) []Reviewed {
+	if file == "" {
+		return nil
+	}
+	cached, ok := s.gitTimes[file]
+	if !ok {
+		if t, found := GitLastModified(file); found {
+			cached = &t
+		}
+		s.gitTimes[file] = cached
+	}
+	if cached == nil {
+		return nil
+	}
+	return []Reviewed{{At: *cached, Source: "git"}}
+}
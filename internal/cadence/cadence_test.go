@@ -0,0 +1,146 @@
+package cadence
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/threatcl/spec"
+)
+
+func writePolicy(tb testing.TB, src string) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "cadence.hcl")
+	if err := os.WriteFile(path, []byte(src), 0600); err != nil {
+		tb.Fatalf("Error writing policy: %s", err)
+	}
+	return path
+}
+
+func TestParseFile(t *testing.T) {
+	p, err := ParseFile(writePolicy(t, `
+default_days = 200
+initiative_size = { Large = 120 }
+information_classification = { Restricted = 30 }
+fail_on_classification = ["Restricted"]
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if p.DefaultDays != 200 {
+		t.Errorf("expected default_days 200, got %d", p.DefaultDays)
+	}
+	if p.InitiativeSizes["Large"] != 120 {
+		t.Errorf("expected Large 120, got %d", p.InitiativeSizes["Large"])
+	}
+	if _, ok := p.Classifications["Confidential"]; ok {
+		t.Errorf("expected file classifications to replace the defaults")
+	}
+	if len(p.FailClassification) != 1 || p.FailClassification[0] != "Restricted" {
+		t.Errorf("unexpected fail_on_classification: %v", p.FailClassification)
+	}
+}
+
+func TestParseFileInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		exp  string
+	}{
+		{"zero_default", `default_days = 0`, "default_days must be greater than 0"},
+		{"negative_size", `initiative_size = { Large = -1 }`, `initiative_size "Large"`},
+		{"unknown_attr", `cadence = 1`, "Unsupported argument"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseFile(writePolicy(t, tc.src))
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if !strings.Contains(err.Error(), tc.exp) {
+				t.Errorf("expected %q to contain %q", err, tc.exp)
+			}
+		})
+	}
+}
+
+func TestCadenceForStrictestWins(t *testing.T) {
+	p := &Policy{
+		DefaultDays:     365,
+		InitiativeSizes: map[string]int{"Large": 180},
+		Classifications: map[string]int{"Restricted": 90, "Confidential": 120},
+	}
+
+	tm := &spec.Threatmodel{
+		Name:       "tm",
+		Attributes: &spec.Attribute{InitiativeSize: "large"},
+	}
+	days, reason := p.CadenceFor(tm)
+	if days != 180 || reason != "initiative_size large" {
+		t.Errorf("expected 180 from initiative_size, got %d (%s)", days, reason)
+	}
+
+	tm.InformationAssets = []*spec.InformationAsset{
+		{Name: "a", InformationClassification: "Confidential"},
+		{Name: "b", InformationClassification: "Restricted"},
+	}
+	days, reason = p.CadenceFor(tm)
+	if days != 90 || reason != "classification Restricted" {
+		t.Errorf("expected 90 from Restricted, got %d (%s)", days, reason)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	p := DefaultPolicy()
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("never_reviewed", func(t *testing.T) {
+		st := p.Evaluate(&spec.Threatmodel{Name: "tm"}, nil, now)
+		if !st.Overdue || !st.LastReviewed.IsZero() || !st.Due.IsZero() {
+			t.Errorf("expected an unreviewed model to be overdue, got %+v", st)
+		}
+	})
+
+	t.Run("updated_at_current", func(t *testing.T) {
+		tm := &spec.Threatmodel{Name: "tm", UpdatedAt: now.AddDate(0, -1, 0).Unix()}
+		st := p.Evaluate(tm, nil, now)
+		if st.Overdue {
+			t.Errorf("expected model to be current, got %+v", st)
+		}
+		if st.Source != "updated_at" {
+			t.Errorf("expected source updated_at, got %s", st.Source)
+		}
+	})
+
+	t.Run("git_newer_than_attributes", func(t *testing.T) {
+		tm := &spec.Threatmodel{
+			Name:              "tm",
+			CreatedAt:         now.AddDate(-3, 0, 0).Unix(),
+			InformationAssets: []*spec.InformationAsset{{Name: "a", InformationClassification: "Restricted"}},
+		}
+		git := []Reviewed{{At: now.AddDate(0, 0, -100), Source: "git"}}
+		st := p.Evaluate(tm, git, now)
+		if st.Source != "git" {
+			t.Errorf("expected source git, got %s", st.Source)
+		}
+		if !st.Overdue || st.DaysOverdue != 10 {
+			t.Errorf("expected 10 days overdue on a 90 day cadence, got %+v", st)
+		}
+	})
+}
+
+func TestHoldsClassification(t *testing.T) {
+	tm := &spec.Threatmodel{
+		InformationAssets: []*spec.InformationAsset{{Name: "a", InformationClassification: "Restricted"}},
+	}
+	if !HoldsClassification(tm, []string{"restricted"}) {
+		t.Errorf("expected a case-insensitive match")
+	}
+	if HoldsClassification(tm, []string{"Public"}) {
+		t.Errorf("expected no match")
+	}
+}
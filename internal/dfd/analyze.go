@@ -0,0 +1,366 @@
+package dfd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxPaths caps the paths Analyze enumerates, as densely connected
+// diagrams have a great many.
+const DefaultMaxPaths = 1000
+
+// DefaultMaxSteps caps the flows Analyze follows while looking for paths,
+// so a dense mesh of processes can't keep it walking indefinitely.
+const DefaultMaxSteps = 100000
+
+// highlight colours used by Analysis.Highlight. A compromised element is
+// drawn like an attack path, and what it reaches in a warning colour.
+const (
+	pathColor  = "#d62728"
+	reachColor = "#ff7f0e"
+)
+
+// AnalyzeOptions configures Analyze.
+type AnalyzeOptions struct {
+	// Compromised names an element to work out the blast radius of.
+	Compromised string
+
+	// Classifications maps information asset names onto their
+	// information_classification.
+	Classifications map[string]string
+
+	// MaxPaths caps the paths enumerated, defaulting to DefaultMaxPaths.
+	MaxPaths int
+
+	// MaxSteps caps the flows followed, defaulting to DefaultMaxSteps.
+	MaxSteps int
+}
+
+// Boundary is a trust-zone boundary crossed by a flow. An empty zone is
+// outside every trust zone.
+type Boundary struct {
+	Flow     string `json:"flow"`
+	FromZone string `json:"from_zone"`
+	ToZone   string `json:"to_zone"`
+}
+
+// String renders the boundary as "flow (outside -> Internal)".
+func (b Boundary) String() string {
+	return fmt.Sprintf("%s (%s -> %s)", b.Flow, zoneName(b.FromZone), zoneName(b.ToZone))
+}
+
+// Path is a route along flows from an external element to a data store.
+type Path struct {
+	Source     string     `json:"source"`
+	Target     string     `json:"target"`
+	Elements   []string   `json:"elements"`
+	Flows      []string   `json:"flows"`
+	Boundaries []Boundary `json:"boundaries"`
+
+	// flows are the indices of the path's flows in the graph
+	flows []int
+}
+
+// Asset is an information asset held by a data store.
+type Asset struct {
+	Name           string   `json:"name"`
+	Classification string   `json:"classification,omitempty"`
+	DataStores     []string `json:"data_stores"`
+}
+
+// BlastRadius is everything downstream of a compromised element.
+type BlastRadius struct {
+	Element    string   `json:"element"`
+	Reachable  []string `json:"reachable"`
+	DataStores []string `json:"data_stores"`
+	Assets     []Asset  `json:"information_assets"`
+
+	flows []int
+}
+
+// Analysis is the attack paths through a diagram, and the blast radius of
+// a compromised element.
+type Analysis struct {
+	Diagram     string       `json:"diagram"`
+	Paths       []Path       `json:"paths"`
+	Truncated   bool         `json:"truncated,omitempty"`
+	Compromised *BlastRadius `json:"compromised,omitempty"`
+}
+
+// Analyze finds every simple path from each external element to each data
+// store, in element order, with the trust-zone boundaries it crosses. A
+// path may pass through other data stores on its way. If opts names a
+// compromised element, it also finds every element, data store and
+// information asset reachable downstream of it; an unknown element is an
+// error.
+//
+// Only elements that can reach a data store are walked through, and the
+// walk stops, marking the analysis Truncated, once it has found MaxPaths
+// paths or followed MaxSteps flows.
+func Analyze(g *Graph, opts AnalyzeOptions) (*Analysis, error) {
+	maxPaths := opts.MaxPaths
+	if maxPaths <= 0 {
+		maxPaths = DefaultMaxPaths
+	}
+	maxSteps := opts.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = DefaultMaxSteps
+	}
+
+	a := &Analysis{Diagram: g.Name, Paths: []Path{}}
+	out := g.outgoing()
+	reaches := g.reachesDataStore()
+	steps := 0
+
+	for _, start := range g.Elements {
+		if start.Kind != ExternalElement || !reaches[start.Name] {
+			continue
+		}
+
+		visited := map[string]bool{start.Name: true}
+		elements := []string{start.Name}
+		flows := []int{}
+
+		var walk func(name string)
+		walk = func(name string) {
+			for _, fi := range out[name] {
+				if a.Truncated {
+					return
+				}
+				next := g.Flows[fi].To
+				if visited[next] || !reaches[next] {
+					continue
+				}
+				if steps == maxSteps {
+					a.Truncated = true
+					return
+				}
+				steps++
+				visited[next] = true
+				elements = append(elements, next)
+				flows = append(flows, fi)
+
+				if el, _ := g.Element(next); el.Kind == DataStore {
+					if len(a.Paths) == maxPaths {
+						a.Truncated = true
+					} else {
+						a.Paths = append(a.Paths, g.path(elements, flows))
+					}
+				}
+				walk(next)
+
+				visited[next] = false
+				elements = elements[:len(elements)-1]
+				flows = flows[:len(flows)-1]
+			}
+		}
+		walk(start.Name)
+	}
+
+	if opts.Compromised != "" {
+		br, err := g.blastRadius(opts.Compromised, opts.Classifications)
+		if err != nil {
+			return nil, err
+		}
+		a.Compromised = br
+	}
+	return a, nil
+}
+
+// outgoing maps element names onto the indices of the flows leaving them,
+// ignoring flows with an end that isn't an element.
+func (g *Graph) outgoing() map[string][]int {
+	out := map[string][]int{}
+	for i, f := range g.Flows {
+		_, okFrom := g.Element(f.From)
+		_, okTo := g.Element(f.To)
+		if okFrom && okTo {
+			out[f.From] = append(out[f.From], i)
+		}
+	}
+	return out
+}
+
+// reachesDataStore is the set of elements with a route along flows to a
+// data store, data stores included, found by walking flows backwards from
+// every data store.
+func (g *Graph) reachesDataStore() map[string]bool {
+	in := map[string][]string{}
+	for _, fis := range g.outgoing() {
+		for _, fi := range fis {
+			f := g.Flows[fi]
+			in[f.To] = append(in[f.To], f.From)
+		}
+	}
+
+	reaches := map[string]bool{}
+	queue := []string{}
+	for _, el := range g.Elements {
+		if el.Kind == DataStore && !reaches[el.Name] {
+			reaches[el.Name] = true
+			queue = append(queue, el.Name)
+		}
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, prev := range in[cur] {
+			if !reaches[prev] {
+				reaches[prev] = true
+				queue = append(queue, prev)
+			}
+		}
+	}
+	return reaches
+}
+
+func (g *Graph) path(elements []string, flows []int) Path {
+	p := Path{
+		Source:     elements[0],
+		Target:     elements[len(elements)-1],
+		Elements:   append([]string{}, elements...),
+		Flows:      []string{},
+		Boundaries: []Boundary{},
+		flows:      append([]int{}, flows...),
+	}
+	for _, fi := range flows {
+		f := g.Flows[fi]
+		p.Flows = append(p.Flows, f.Name)
+		if b, ok := g.boundary(f); ok {
+			p.Boundaries = append(p.Boundaries, b)
+		}
+	}
+	return p
+}
+
+// boundary returns the trust-zone boundary f crosses, if any.
+func (g *Graph) boundary(f Flow) (Boundary, bool) {
+	from, _ := g.Element(f.From)
+	to, _ := g.Element(f.To)
+	if from.Zone == to.Zone {
+		return Boundary{}, false
+	}
+	return Boundary{Flow: f.Name, FromZone: from.Zone, ToZone: to.Zone}, true
+}
+
+func (g *Graph) blastRadius(name string, classifications map[string]string) (*BlastRadius, error) {
+	if _, ok := g.Element(name); !ok {
+		return nil, fmt.Errorf("no element called %q in %s", name, g.Name)
+	}
+
+	br := &BlastRadius{Element: name, Reachable: []string{}, DataStores: []string{}, Assets: []Asset{}}
+	out := g.outgoing()
+	seen := map[string]bool{name: true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, fi := range out[cur] {
+			br.flows = append(br.flows, fi)
+			next := g.Flows[fi].To
+			if seen[next] {
+				continue
+			}
+			seen[next] = true
+			queue = append(queue, next)
+			br.Reachable = append(br.Reachable, next)
+		}
+	}
+
+	assets := map[string]int{}
+	for _, n := range br.Reachable {
+		el, _ := g.Element(n)
+		if el.Kind != DataStore {
+			continue
+		}
+		br.DataStores = append(br.DataStores, n)
+		if el.IaLink == "" {
+			continue
+		}
+		if i, ok := assets[el.IaLink]; ok {
+			br.Assets[i].DataStores = append(br.Assets[i].DataStores, n)
+			continue
+		}
+		assets[el.IaLink] = len(br.Assets)
+		br.Assets = append(br.Assets, Asset{Name: el.IaLink, Classification: classifications[el.IaLink], DataStores: []string{n}})
+	}
+	return br, nil
+}
+
+// Highlight is what to emphasise when drawing the analysis: the blast
+// radius if there's a compromised element, otherwise every attack path.
+func (a *Analysis) Highlight() Highlight {
+	h := Highlight{Elements: map[string]string{}, Flows: map[int]string{}}
+	if br := a.Compromised; br != nil {
+		h.Elements[br.Element] = pathColor
+		for _, n := range br.Reachable {
+			h.Elements[n] = reachColor
+		}
+		for _, fi := range br.flows {
+			h.Flows[fi] = reachColor
+		}
+		return h
+	}
+	for _, p := range a.Paths {
+		for _, n := range p.Elements {
+			h.Elements[n] = pathColor
+		}
+		for _, fi := range p.flows {
+			h.Flows[fi] = pathColor
+		}
+	}
+	return h
+}
+
+// String renders the analysis as text.
+func (a *Analysis) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Attack paths (%d):\n", len(a.Paths))
+	if len(a.Paths) == 0 {
+		b.WriteString("  No external element reaches a data store\n")
+	}
+	for _, p := range a.Paths {
+		fmt.Fprintf(&b, "  %s\n", strings.Join(p.Elements, " -> "))
+		if len(p.Boundaries) == 0 {
+			b.WriteString("    crosses no trust boundaries\n")
+			continue
+		}
+		for _, bd := range p.Boundaries {
+			fmt.Fprintf(&b, "    crosses %s\n", bd)
+		}
+	}
+	if a.Truncated {
+		fmt.Fprintf(&b, "  ... stopped after %d paths\n", len(a.Paths))
+	}
+
+	if br := a.Compromised; br != nil {
+		fmt.Fprintf(&b, "\nIf %s is compromised:\n", br.Element)
+		fmt.Fprintf(&b, "  Reachable: %s\n", listOrNone(br.Reachable))
+		fmt.Fprintf(&b, "  Data stores: %s\n", listOrNone(br.DataStores))
+		assets := []string{}
+		for _, as := range br.Assets {
+			s := as.Name
+			if as.Classification != "" {
+				s += fmt.Sprintf(" [%s]", as.Classification)
+			}
+			assets = append(assets, fmt.Sprintf("%s in %s", s, strings.Join(as.DataStores, ", ")))
+		}
+		fmt.Fprintf(&b, "  Information assets: %s\n", listOrNone(assets))
+	}
+	return b.String()
+}
+
+func listOrNone(list []string) string {
+	if len(list) == 0 {
+		return "none"
+	}
+	return strings.Join(list, ", ")
+}
+
+func zoneName(zone string) string {
+	if zone == "" {
+		return "outside"
+	}
+	return zone
+}
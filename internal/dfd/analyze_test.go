@@ -0,0 +1,212 @@
+package dfd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+// testAnalyzeGraph has two routes from the user to the card store, one
+// through the audit log.
+func testAnalyzeGraph() *Graph {
+	return &Graph{
+		Name:  "Level 0",
+		Zones: []string{"DMZ", "Internal"},
+		Elements: []Element{
+			{Name: "User", Kind: ExternalElement},
+			{Name: "Web", Kind: Process, Zone: "DMZ"},
+			{Name: "API", Kind: Process, Zone: "Internal"},
+			{Name: "Cards", Kind: DataStore, Zone: "Internal", IaLink: "Card data"},
+			{Name: "Audit", Kind: DataStore, Zone: "Internal", IaLink: "Logs"},
+			{Name: "Admin", Kind: ExternalElement},
+		},
+		Flows: []Flow{
+			{Name: "Browse", From: "User", To: "Web", Protocol: "https"},
+			{Name: "Call", From: "Web", To: "API", Protocol: "grpc"},
+			{Name: "Store", From: "API", To: "Cards"},
+			{Name: "Log", From: "API", To: "Audit"},
+			{Name: "Replay", From: "Audit", To: "Cards"},
+			{Name: "Reply", From: "API", To: "Web"},
+			{Name: "Dangling", From: "API", To: "Nowhere"},
+		},
+	}
+}
+
+func TestAnalyzePaths(t *testing.T) {
+	a, err := Analyze(testAnalyzeGraph(), AnalyzeOptions{})
+	if err != nil {
+		t.Fatalf("error analysing: %s", err)
+	}
+
+	exp := []string{
+		"User -> Web -> API -> Cards",
+		"User -> Web -> API -> Audit",
+		"User -> Web -> API -> Audit -> Cards",
+	}
+	if len(a.Paths) != len(exp) {
+		t.Fatalf("expected %d paths, got %+v", len(exp), a.Paths)
+	}
+	for i := range exp {
+		if got := strings.Join(a.Paths[i].Elements, " -> "); got != exp[i] {
+			t.Errorf("path %d: expected %s, got %s", i, exp[i], got)
+		}
+	}
+
+	p := a.Paths[0]
+	if p.Source != "User" || p.Target != "Cards" || strings.Join(p.Flows, ",") != "Browse,Call,Store" {
+		t.Errorf("unexpected path: %+v", p)
+	}
+	if len(p.Boundaries) != 2 || p.Boundaries[0].String() != "Browse (outside -> DMZ)" || p.Boundaries[1].String() != "Call (DMZ -> Internal)" {
+		t.Errorf("unexpected boundaries: %+v", p.Boundaries)
+	}
+	if a.Compromised != nil || a.Truncated {
+		t.Errorf("unexpected analysis: %+v", a)
+	}
+
+	h := a.Highlight()
+	if h.Elements["Admin"] != "" || h.Elements["Cards"] != pathColor || h.Flows[5] != "" || h.Flows[4] != pathColor {
+		t.Errorf("unexpected highlight: %+v", h)
+	}
+}
+
+func TestAnalyzeMaxPaths(t *testing.T) {
+	a, err := Analyze(testAnalyzeGraph(), AnalyzeOptions{MaxPaths: 2})
+	if err != nil {
+		t.Fatalf("error analysing: %s", err)
+	}
+	if len(a.Paths) != 2 || !a.Truncated {
+		t.Errorf("expected 2 paths and truncation, got %d, %t", len(a.Paths), a.Truncated)
+	}
+	if !strings.Contains(a.String(), "stopped after 2 paths") {
+		t.Errorf("expected truncation in:\n%s", a)
+	}
+}
+
+func TestAnalyzeMeshWithoutDataStore(t *testing.T) {
+	// every process talks to every other, and nothing is stored, so there
+	// are no paths however many routes there are through the mesh
+	g := &Graph{Name: "Mesh", Elements: []Element{{Name: "User", Kind: ExternalElement}}}
+	for i := 0; i < 12; i++ {
+		g.Elements = append(g.Elements, Element{Name: fmt.Sprintf("P%d", i), Kind: Process})
+	}
+	for _, from := range g.Elements {
+		for _, to := range g.Elements[1:] {
+			if from.Name != to.Name {
+				g.Flows = append(g.Flows, Flow{Name: from.Name + "-" + to.Name, From: from.Name, To: to.Name})
+			}
+		}
+	}
+
+	a, err := Analyze(g, AnalyzeOptions{})
+	if err != nil {
+		t.Fatalf("error analysing: %s", err)
+	}
+	if len(a.Paths) != 0 || a.Truncated {
+		t.Errorf("expected no paths and no truncation, got %d, %t", len(a.Paths), a.Truncated)
+	}
+
+	// with a store at the far side of the mesh, the walk gives up after
+	// MaxSteps flows rather than trying every route through it
+	g.Elements = append(g.Elements, Element{Name: "Store", Kind: DataStore})
+	g.Flows = append(g.Flows, Flow{Name: "Save", From: "P11", To: "Store"})
+	a, err = Analyze(g, AnalyzeOptions{MaxSteps: 500})
+	if err != nil {
+		t.Fatalf("error analysing: %s", err)
+	}
+	if !a.Truncated || len(a.Paths) == 0 {
+		t.Errorf("expected some paths and truncation, got %d, %t", len(a.Paths), a.Truncated)
+	}
+}
+
+func TestAnalyzeCompromised(t *testing.T) {
+	a, err := Analyze(testAnalyzeGraph(), AnalyzeOptions{
+		Compromised:     "Web",
+		Classifications: map[string]string{"Card data": "Restricted"},
+	})
+	if err != nil {
+		t.Fatalf("error analysing: %s", err)
+	}
+
+	br := a.Compromised
+	if br == nil {
+		t.Fatal("expected a blast radius")
+	}
+	if strings.Join(br.Reachable, ",") != "API,Cards,Audit" {
+		t.Errorf("unexpected reachable elements: %v", br.Reachable)
+	}
+	if strings.Join(br.DataStores, ",") != "Cards,Audit" {
+		t.Errorf("unexpected data stores: %v", br.DataStores)
+	}
+	if len(br.Assets) != 2 || br.Assets[0].Classification != "Restricted" || br.Assets[1].Name != "Logs" {
+		t.Errorf("unexpected assets: %+v", br.Assets)
+	}
+
+	out := a.String()
+	for _, want := range []string{
+		"If Web is compromised:",
+		"Reachable: API, Cards, Audit",
+		"Information assets: Card data [Restricted] in Cards, Logs in Audit",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+
+	h := a.Highlight()
+	if h.Elements["Web"] != pathColor || h.Elements["Cards"] != reachColor || h.Elements["User"] != "" {
+		t.Errorf("unexpected highlight: %+v", h)
+	}
+
+	j, err := json.Marshal(a)
+	if err != nil {
+		t.Fatalf("error marshalling: %s", err)
+	}
+	if !strings.Contains(string(j), `"compromised":{"element":"Web"`) || !strings.Contains(string(j), `"from_zone":"DMZ"`) {
+		t.Errorf("unexpected json: %s", j)
+	}
+
+	if _, err := Analyze(testAnalyzeGraph(), AnalyzeOptions{Compromised: "Nope"}); err == nil {
+		t.Error("expected an error for an unknown element")
+	}
+}
+
+func TestDot(t *testing.T) {
+	g := testAnalyzeGraph()
+
+	out := Dot(g, "Shop", RenderOptions{ProtocolStyle: spec.ProtocolStyleBoth})
+	for _, want := range []string{
+		`label="Shop: Level 0";`,
+		`el1 [label="User", shape=box];`,
+		`subgraph cluster_zone1 {`,
+		`el4 [label="Cards", shape=cylinder];`,
+		`el1 -> el2 [label="Browse (https)", color="#ff7f0e", fontcolor="#ff7f0e"];`,
+		`subgraph cluster_legend {`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Nowhere") {
+		t.Errorf("expected the dangling flow to be dropped:\n%s", out)
+	}
+
+	a, _ := Analyze(g, AnalyzeOptions{})
+	h := a.Highlight()
+	out = Dot(g, "Shop", RenderOptions{Highlight: &h})
+	if !strings.Contains(out, `el1 -> el2 [label="Browse (https)", color="#d62728", penwidth=2.5`) ||
+		!strings.Contains(out, `el6 [label="Admin", shape=box, color="#bbbbbb"`) ||
+		strings.Contains(out, "cluster_legend") {
+		t.Errorf("unexpected highlighted dot:\n%s", out)
+	}
+
+	svg, err := Svg(out)
+	if err != nil {
+		t.Fatalf("error rendering svg: %s", err)
+	}
+	if !strings.Contains(string(svg), "<svg") || !strings.Contains(string(svg), "#d62728") {
+		t.Errorf("unexpected svg: %.200s", svg)
+	}
+}
@@ -0,0 +1,313 @@
+package dfd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// SensitiveClassifications are the information classifications that must
+// not cross a trust boundary in plaintext, most sensitive first. They're
+// compared case-insensitively.
+var SensitiveClassifications = []string{"Restricted", "Confidential"}
+
+// plaintextProtocols are the flow protocols that don't protect data in
+// transit. A flow without a protocol is assumed to be plaintext.
+var plaintextProtocols = map[string]bool{
+	"":       true,
+	"http":   true,
+	"ftp":    true,
+	"telnet": true,
+}
+
+// Assets is what Classify needs to know about a threat model's information
+// assets.
+type Assets struct {
+	// Classifications maps asset names onto their
+	// information_classification.
+	Classifications map[string]string
+
+	// Threats counts the threats referring to each asset, and Controls the
+	// implemented controls of those threats.
+	Threats  map[string]int
+	Controls map[string]int
+}
+
+// AssetsFromSpec collects the assets of tm. A threat protects the assets in
+// its information_asset_refs with each of its implemented controls.
+func AssetsFromSpec(tm *spec.Threatmodel) Assets {
+	a := Assets{Classifications: map[string]string{}, Threats: map[string]int{}, Controls: map[string]int{}}
+	for _, ia := range tm.InformationAssets {
+		a.Classifications[ia.Name] = ia.InformationClassification
+	}
+	for _, t := range tm.Threats {
+		implemented := 0
+		for _, c := range tmutil.AllControls(t) {
+			if c.Implemented {
+				implemented++
+			}
+		}
+		for _, ref := range t.InformationAssetRefs {
+			a.Threats[ref]++
+			a.Controls[ref] += implemented
+		}
+	}
+	return a
+}
+
+// ClassifiedFlow is a flow carrying information assets. Classification is
+// that of the most sensitive of them.
+type ClassifiedFlow struct {
+	Diagram        string   `json:"diagram"`
+	Flow           string   `json:"flow"`
+	From           string   `json:"from"`
+	To             string   `json:"to"`
+	FromZone       string   `json:"from_zone"`
+	ToZone         string   `json:"to_zone"`
+	Protocol       string   `json:"protocol"`
+	Assets         []string `json:"information_assets"`
+	Classification string   `json:"classification"`
+}
+
+// Plaintext reports whether the flow's protocol leaves data unprotected.
+func (f ClassifiedFlow) Plaintext() bool {
+	return plaintextProtocols[strings.ToLower(f.Protocol)]
+}
+
+// String renders the flow as "Level 0: Browse (outside -> Internal)
+// carries Card data [Restricted] over https".
+func (f ClassifiedFlow) String() string {
+	s := fmt.Sprintf("%s: %s", f.Diagram, f.Flow)
+	if f.FromZone != f.ToZone {
+		s += fmt.Sprintf(" (%s -> %s)", zoneName(f.FromZone), zoneName(f.ToZone))
+	}
+	assets := strings.Join(f.Assets, ", ")
+	if f.Classification != "" {
+		assets += fmt.Sprintf(" [%s]", f.Classification)
+	}
+	return fmt.Sprintf("%s carries %s over %s", s, assets, protocolName(f.Protocol))
+}
+
+// UnprotectedAsset is an information asset held by a data store that no
+// implemented control protects.
+type UnprotectedAsset struct {
+	Name           string   `json:"name"`
+	Classification string   `json:"classification,omitempty"`
+	DataStores     []string `json:"data_stores"`
+	Reason         string   `json:"reason"`
+}
+
+// DataReport is how a threat model's information assets move through its
+// diagrams.
+type DataReport struct {
+	Flows             []ClassifiedFlow   `json:"flows"`
+	InsecureFlows     []ClassifiedFlow   `json:"insecure_flows"`
+	UnprotectedAssets []UnprotectedAsset `json:"unprotected_assets"`
+}
+
+// Classify propagates the information assets held by data stores along the
+// flows of graphs. Every flow to or from a data store carries its asset,
+// which then spreads upstream and downstream through processes, stopping at
+// other data stores and external elements.
+//
+// A flow is insecure if it carries Restricted or Confidential data across a
+// trust-zone boundary over a plaintext protocol. An asset held by a data
+// store is unprotected if no threat referring to it has an implemented
+// control.
+func Classify(graphs []*Graph, assets Assets) *DataReport {
+	r := &DataReport{Flows: []ClassifiedFlow{}, InsecureFlows: []ClassifiedFlow{}, UnprotectedAssets: []UnprotectedAsset{}}
+	held := map[string]int{}
+
+	for _, g := range graphs {
+		carried := g.carried()
+		for i, f := range g.Flows {
+			if len(carried[i]) == 0 {
+				continue
+			}
+			from, _ := g.Element(f.From)
+			to, _ := g.Element(f.To)
+			cf := ClassifiedFlow{
+				Diagram:        g.Name,
+				Flow:           f.Name,
+				From:           f.From,
+				To:             f.To,
+				FromZone:       from.Zone,
+				ToZone:         to.Zone,
+				Protocol:       f.Protocol,
+				Assets:         carried[i],
+				Classification: mostSensitive(carried[i], assets.Classifications),
+			}
+			r.Flows = append(r.Flows, cf)
+			if Sensitive(cf.Classification) && cf.FromZone != cf.ToZone && cf.Plaintext() {
+				r.InsecureFlows = append(r.InsecureFlows, cf)
+			}
+		}
+
+		for _, el := range g.Elements {
+			if el.Kind != DataStore || el.IaLink == "" || assets.Controls[el.IaLink] > 0 {
+				continue
+			}
+			if i, ok := held[el.IaLink]; ok {
+				r.UnprotectedAssets[i].DataStores = appendUnique(r.UnprotectedAssets[i].DataStores, el.Name)
+				continue
+			}
+			reason := "no threat refers to it"
+			if assets.Threats[el.IaLink] > 0 {
+				reason = "no threat that refers to it has an implemented control"
+			}
+			held[el.IaLink] = len(r.UnprotectedAssets)
+			r.UnprotectedAssets = append(r.UnprotectedAssets, UnprotectedAsset{
+				Name:           el.IaLink,
+				Classification: assets.Classifications[el.IaLink],
+				DataStores:     []string{el.Name},
+				Reason:         reason,
+			})
+		}
+	}
+	return r
+}
+
+// carried maps flow indices onto the information assets they carry, in
+// the order their data stores are declared.
+func (g *Graph) carried() map[int][]string {
+	out := g.outgoing()
+	in := map[string][]int{}
+	for _, fis := range out {
+		for _, fi := range fis {
+			in[g.Flows[fi].To] = append(in[g.Flows[fi].To], fi)
+		}
+	}
+
+	carried := map[int][]string{}
+	spread := func(store Element, edges map[string][]int, end func(Flow) string) {
+		seen := map[string]bool{store.Name: true}
+		queue := []string{store.Name}
+		for len(queue) > 0 {
+			cur := queue[0]
+			queue = queue[1:]
+			for _, fi := range edges[cur] {
+				carried[fi] = appendUnique(carried[fi], store.IaLink)
+				next := end(g.Flows[fi])
+				if el, _ := g.Element(next); el.Kind == Process && !seen[next] {
+					seen[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+	for _, el := range g.Elements {
+		if el.Kind != DataStore || el.IaLink == "" {
+			continue
+		}
+		spread(el, out, func(f Flow) string { return f.To })
+		spread(el, in, func(f Flow) string { return f.From })
+	}
+	return carried
+}
+
+// Sensitive reports whether classification is one of the
+// SensitiveClassifications.
+func Sensitive(classification string) bool {
+	return sensitivity(classification) > 0
+}
+
+// sensitivity ranks a classification: higher is more sensitive, and 0 is
+// not sensitive.
+func sensitivity(classification string) int {
+	for i, c := range SensitiveClassifications {
+		if strings.EqualFold(c, classification) {
+			return len(SensitiveClassifications) - i
+		}
+	}
+	return 0
+}
+
+// mostSensitive is the classification of the most sensitive asset, or of
+// the first classified one if none are sensitive.
+func mostSensitive(assets []string, classifications map[string]string) string {
+	out := ""
+	for _, a := range assets {
+		c := classifications[a]
+		if out == "" || sensitivity(c) > sensitivity(out) {
+			out = c
+		}
+	}
+	return out
+}
+
+func protocolName(protocol string) string {
+	if protocol == "" {
+		return "an unset protocol"
+	}
+	return protocol
+}
+
+// String renders the report as text.
+func (r *DataReport) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Classified flows (%d):\n", len(r.Flows))
+	if len(r.Flows) == 0 {
+		b.WriteString("  No flow touches a data store holding an information asset\n")
+	}
+	for _, f := range r.Flows {
+		fmt.Fprintf(&b, "  %s\n", f)
+	}
+
+	fmt.Fprintf(&b, "\nInsecure flows (%d):\n", len(r.InsecureFlows))
+	for _, f := range r.InsecureFlows {
+		fmt.Fprintf(&b, "  %s\n", f)
+	}
+
+	fmt.Fprintf(&b, "\nUnprotected assets (%d):\n", len(r.UnprotectedAssets))
+	for _, a := range r.UnprotectedAssets {
+		s := a.Name
+		if a.Classification != "" {
+			s += fmt.Sprintf(" [%s]", a.Classification)
+		}
+		fmt.Fprintf(&b, "  %s in %s: %s\n", s, strings.Join(a.DataStores, ", "), a.Reason)
+	}
+	return b.String()
+}
+
+// Markdown renders the insecure flows and unprotected assets as Markdown
+// tables, or nothing if there are neither.
+func (r *DataReport) Markdown() string {
+	if len(r.InsecureFlows) == 0 && len(r.UnprotectedAssets) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("## Data Classification\n")
+	if len(r.InsecureFlows) > 0 {
+		b.WriteString("\n### Insecure Flows\n\n")
+		b.WriteString("| Diagram | Flow | From | To | Protocol | Classification | Information Assets |\n")
+		b.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+		for _, f := range r.InsecureFlows {
+			fmt.Fprintf(&b, "| %s | %s | %s (%s) | %s (%s) | %s | %s | %s |\n",
+				mdCell(f.Diagram), mdCell(f.Flow),
+				mdCell(f.From), mdCell(zoneName(f.FromZone)),
+				mdCell(f.To), mdCell(zoneName(f.ToZone)),
+				mdCell(tmutil.FirstNonEmpty(f.Protocol, "unset")), mdCell(f.Classification),
+				mdCell(strings.Join(f.Assets, ", ")))
+		}
+	}
+	if len(r.UnprotectedAssets) > 0 {
+		b.WriteString("\n### Unprotected Information Assets\n\n")
+		b.WriteString("| Information Asset | Classification | Data Stores | Reason |\n")
+		b.WriteString("| --- | --- | --- | --- |\n")
+		for _, a := range r.UnprotectedAssets {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n",
+				mdCell(a.Name), mdCell(a.Classification), mdCell(strings.Join(a.DataStores, ", ")), mdCell(a.Reason))
+		}
+	}
+	return b.String()
+}
+
+// mdCell escapes s for a Markdown table cell.
+func mdCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,87 @@
+package dfd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+func TestClassify(t *testing.T) {
+	g := testAnalyzeGraph()
+	g.Flows[0].Protocol = "http"
+
+	r := Classify([]*Graph{g}, Assets{
+		Classifications: map[string]string{"Card data": "restricted", "Logs": "Internal"},
+		Threats:         map[string]int{"Logs": 1},
+		Controls:        map[string]int{},
+	})
+
+	flows := []string{}
+	for _, f := range r.Flows {
+		flows = append(flows, f.Flow+"="+strings.Join(f.Assets, "+"))
+	}
+	if got := strings.Join(flows, ","); got != "Browse=Card data+Logs,Call=Card data+Logs,Store=Card data,Log=Logs,Replay=Card data+Logs,Reply=Card data+Logs" {
+		t.Errorf("unexpected classified flows: %s", got)
+	}
+
+	if len(r.InsecureFlows) != 2 || r.InsecureFlows[0].Flow != "Browse" || r.InsecureFlows[1].Flow != "Reply" || r.InsecureFlows[0].Classification != "restricted" {
+		t.Fatalf("unexpected insecure flows: %+v", r.InsecureFlows)
+	}
+	if got := r.InsecureFlows[0].String(); got != "Level 0: Browse (outside -> DMZ) carries Card data, Logs [restricted] over http" {
+		t.Errorf("unexpected insecure flow: %s", got)
+	}
+
+	if len(r.UnprotectedAssets) != 2 ||
+		r.UnprotectedAssets[0].Reason != "no threat refers to it" ||
+		r.UnprotectedAssets[1].Reason != "no threat that refers to it has an implemented control" {
+		t.Errorf("unexpected unprotected assets: %+v", r.UnprotectedAssets)
+	}
+
+	md := r.Markdown()
+	for _, want := range []string{
+		"### Insecure Flows",
+		"| Level 0 | Browse | User (outside) | Web (DMZ) | http | restricted | Card data, Logs |",
+		"| Logs | Internal | Audit | no threat that refers to it has an implemented control |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected %q in:\n%s", want, md)
+		}
+	}
+
+	j, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("error marshalling: %s", err)
+	}
+	if !strings.Contains(string(j), `"insecure_flows":[{"diagram":"Level 0","flow":"Browse"`) {
+		t.Errorf("unexpected json: %s", j)
+	}
+}
+
+func TestClassifyProtected(t *testing.T) {
+	g := testAnalyzeGraph()
+	tm := &spec.Threatmodel{
+		InformationAssets: []*spec.InformationAsset{
+			{Name: "Card data", InformationClassification: "Confidential"},
+		},
+		Threats: []*spec.Threat{
+			{InformationAssetRefs: []string{"Card data"}, Controls: []*spec.Control{{Name: "WAF", Implemented: true}}},
+			{InformationAssetRefs: []string{"Logs"}, Controls: []*spec.Control{{Name: "SIEM"}}},
+		},
+	}
+
+	r := Classify([]*Graph{g}, AssetsFromSpec(tm))
+	if len(r.InsecureFlows) != 1 || r.InsecureFlows[0].Flow != "Reply" || r.InsecureFlows[0].Classification != "Confidential" {
+		t.Errorf("expected only the unset protocol Reply flow to be insecure, got %+v", r.InsecureFlows)
+	}
+	if len(r.UnprotectedAssets) != 1 || r.UnprotectedAssets[0].Name != "Logs" || r.UnprotectedAssets[0].Classification != "" {
+		t.Errorf("unexpected unprotected assets: %+v", r.UnprotectedAssets)
+	}
+	if !strings.Contains(r.String(), "Logs in Audit: no threat that refers to it has an implemented control") {
+		t.Errorf("unexpected report:\n%s", r)
+	}
+	if (&DataReport{}).Markdown() != "" {
+		t.Error("expected no markdown for an empty report")
+	}
+}
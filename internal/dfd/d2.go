@@ -0,0 +1,208 @@
+package dfd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// d2Shapes are the D2 shapes drawn for each of Shapes.
+var d2Shapes = map[string]string{
+	"ellipse":       "oval",
+	"circle":        "circle",
+	"rectangle":     "rectangle",
+	"rounded":       "rectangle",
+	"cylinder":      "cylinder",
+	"hexagon":       "hexagon",
+	"diamond":       "diamond",
+	"parallelogram": "parallelogram",
+	"stub":          "text",
+}
+
+// d2Directions are the D2 direction of each of Directions.
+var d2Directions = map[string]string{
+	"LR": "right",
+	"RL": "left",
+	"TB": "down",
+	"BT": "up",
+}
+
+// d2Lines are the stroke-dash of each of Lines.
+var d2Lines = map[string]int{
+	"dashed": 3,
+	"dotted": 1,
+}
+
+// D2 renders g as a D2 diagram, with trust zones as dashed containers.
+// Highlights, changes and protocol colours are drawn as styles, as in
+// Mermaid. D2 files can't set a font family or the spacing between
+// containers, so those are left to the d2 command line.
+func D2(g *Graph, tmName string, opts RenderOptions) string {
+	var b strings.Builder
+
+	theme := opts.Theme
+
+	fmt.Fprintf(&b, "direction: %s\n", d2Directions[theme.direction()])
+	fmt.Fprintf(&b, "title: %s {shape: text; near: top-center; style.font-size: 24}\n\n", d2Quote(title(tmName, g)))
+
+	// paths are the keys flows refer to elements by, qualified by the
+	// container of a zoned element
+	paths := map[string]string{}
+	for i, el := range g.Elements {
+		paths[el.Name] = fmt.Sprintf("el%d", i+1)
+	}
+	zoneIDs := map[string]string{}
+	for i, zone := range g.Zones {
+		zoneIDs[zone] = fmt.Sprintf("zone%d", i+1)
+	}
+
+	writeElements := func(els []Element, indent string) {
+		for _, el := range els {
+			label := el.label()
+			if opts.Diff != nil {
+				label = opts.Diff.label(el)
+			}
+			es := theme.element(el.Kind)
+			attrs := []string{"shape: " + d2Shapes[es.Shape]}
+			attrs = append(attrs, d2ElementStyle(es)...)
+			switch {
+			case opts.Highlight != nil:
+				attrs = append(attrs, d2Style(tmutil.FirstNonEmpty(opts.Highlight.Elements[el.Name], fadedColor), opts.Highlight.Elements[el.Name] != "", false)...)
+			case opts.Diff != nil && opts.Diff.Elements[el.Name] != "":
+				c := opts.Diff.Elements[el.Name]
+				attrs = append(attrs, d2Style(changeColors[c], c != Removed, c == Removed)...)
+			}
+			fmt.Fprintf(&b, "%s%s: %s {%s}\n", indent, paths[el.Name], d2Quote(label), strings.Join(attrs, "; "))
+		}
+	}
+	writeElements(g.InZone(""), "")
+	for _, zone := range g.Zones {
+		zs := theme.zone(zone)
+		fmt.Fprintf(&b, "%s: %s {\n  style.stroke: %s\n", zoneIDs[zone], d2Quote(zone), d2Color(zs.Color))
+		if dash := d2Lines[zs.Line]; dash > 0 {
+			fmt.Fprintf(&b, "  style.stroke-dash: %d\n", dash)
+		}
+		fmt.Fprintf(&b, "  style.font-color: %s\n  style.fill: %s\n", d2Color(zs.FontColor), d2Color(tmutil.FirstNonEmpty(zs.Fill, "transparent")))
+		writeElements(g.InZone(zone), "  ")
+		b.WriteString("}\n")
+	}
+	for _, el := range g.Elements {
+		if el.Zone != "" {
+			paths[el.Name] = zoneIDs[el.Zone] + "." + paths[el.Name]
+		}
+	}
+	b.WriteString("\n")
+
+	colors := protocolColors(g)
+	for i, f := range g.Flows {
+		from, ok := paths[f.From]
+		if !ok {
+			continue
+		}
+		to, ok := paths[f.To]
+		if !ok {
+			continue
+		}
+
+		var attrs []string
+		switch {
+		case opts.Highlight != nil:
+			attrs = d2Style(tmutil.FirstNonEmpty(opts.Highlight.Flows[i], fadedColor), opts.Highlight.Flows[i] != "", false)
+		case opts.Diff != nil:
+			if c := opts.Diff.Flows[i]; c != "" {
+				attrs = d2Style(changeColors[c], c != Removed, c == Removed)
+			}
+		case themed(theme, f, opts.Classifications[i]):
+			es, _ := theme.edge(f.Protocol, opts.Classifications[i])
+			attrs = d2EdgeStyle(es)
+		case colored(opts.ProtocolStyle):
+			attrs = d2Style(tmutil.FirstNonEmpty(colors[f.Protocol], unsetProtocolColor), false, false)
+		}
+
+		fmt.Fprintf(&b, "%s -> %s", from, to)
+		if label := flowLabel(f, opts.ProtocolStyle); label != "" {
+			fmt.Fprintf(&b, ": %s", d2Quote(label))
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " {%s}", strings.Join(attrs, "; "))
+		}
+		b.WriteString("\n")
+	}
+
+	if opts.Highlight == nil && opts.Diff == nil {
+		if entries := theme.legend(g, opts.Classifications); len(entries) > 0 {
+			b.WriteString("\nlegend: \"Legend\" {\n  near: bottom-right\n  style.stroke: \"#999999\"\n  style.fill: transparent\n")
+			for i, e := range entries {
+				fmt.Fprintf(&b, "  legend%d: %s {shape: text; style.font-color: %s}\n", i+1, d2Quote(e.Label), d2Color(e.Color))
+			}
+			b.WriteString("}\n")
+		}
+	}
+	return b.String()
+}
+
+// d2ElementStyle is the style of an element's themed style.
+func d2ElementStyle(s ElementStyle) []string {
+	style := []string{}
+	if s.Shape == "rounded" {
+		style = append(style, "style.border-radius: 8")
+	}
+	if s.Fill != "" {
+		style = append(style, "style.fill: "+d2Color(s.Fill))
+	}
+	if s.Color != "" {
+		style = append(style, "style.stroke: "+d2Color(s.Color))
+	}
+	if s.FontSize > 0 {
+		style = append(style, fmt.Sprintf("style.font-size: %d", s.FontSize))
+	}
+	if s.FontColor != "" {
+		style = append(style, "style.font-color: "+d2Color(s.FontColor))
+	}
+	return style
+}
+
+// d2EdgeStyle is the style of a flow's themed style.
+func d2EdgeStyle(s EdgeStyle) []string {
+	style := []string{}
+	if s.Color != "" {
+		style = append(style, "style.stroke: "+d2Color(s.Color), "style.font-color: "+d2Color(s.Color))
+	}
+	if s.Width > 0 {
+		style = append(style, fmt.Sprintf("style.stroke-width: %d", s.Width))
+	}
+	if dash := d2Lines[s.Line]; dash > 0 {
+		style = append(style, fmt.Sprintf("style.stroke-dash: %d", dash))
+	}
+	return style
+}
+
+// d2Color writes a colour, quoted unless it's a plain colour name, as D2
+// reads # as a comment.
+func d2Color(color string) string {
+	if strings.HasPrefix(color, "#") {
+		return d2Quote(color)
+	}
+	return color
+}
+
+// d2Style is the style drawing a shape or connection in color, thicker if
+// emphasised and dashed if ghosted.
+func d2Style(color string, emphasised, ghosted bool) []string {
+	style := []string{fmt.Sprintf("style.stroke: %q", color), fmt.Sprintf("style.font-color: %q", color)}
+	if emphasised {
+		style = append(style, "style.stroke-width: 3")
+	}
+	if ghosted {
+		style = append(style, "style.stroke-dash: 3")
+	}
+	return style
+}
+
+// d2Quote quotes s as a D2 string.
+func d2Quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + strings.ReplaceAll(s, "\n", `\n`) + `"`
+}
@@ -0,0 +1,171 @@
+package dfd
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// Sections of a rendered threat model that an interactive diagram links to.
+const (
+	ThreatSection  = "threat"
+	AssetSection   = "information_asset"
+	DiagramSection = "data_flow_diagram"
+)
+
+// Detail is what an interactive diagram shows when hovering over an element
+// or flow, and where clicking it goes.
+type Detail struct {
+	Tooltip string
+	URL     string
+}
+
+// Details describe the elements, by name, and flows, by index, of an
+// interactive diagram.
+type Details struct {
+	Elements map[string]Detail
+	Flows    map[int]Detail
+}
+
+// DetailsFromSpec describes g's elements and flows from tm. Elements show
+// their trust zone, their information asset and the threats that mention
+// them, or refer to their asset, and link to the first of those threats, or
+// failing that to their asset. A process decomposed by another of tm's
+// diagrams (see Decomposes) links to that diagram instead. Flows show their
+// protocol and the data they carry (see Classify), and link to the most
+// sensitive of it.
+//
+// link maps a section and the name of a threat, information asset or
+// diagram onto a URL. Nothing links where it returns "".
+func DetailsFromSpec(g *Graph, tm *spec.Threatmodel, link func(section, name string) string) *Details {
+	d := &Details{Elements: map[string]Detail{}, Flows: map[int]Detail{}}
+	assets := AssetsFromSpec(tm)
+
+	for _, el := range g.Elements {
+		lines := []string{
+			fmt.Sprintf("%s (%s)", el.Name, strings.ReplaceAll(string(el.Kind), "_", " ")),
+			"Trust zone: " + zoneName(el.Zone),
+		}
+		if el.IaLink != "" {
+			lines = append(lines, "Information asset: "+assetName(el.IaLink, assets.Classifications[el.IaLink]))
+		}
+
+		threats := []string{}
+		for _, t := range tm.Threats {
+			if mentions(t.Name+"\n"+t.Description, el.Name) || (el.IaLink != "" && contains(t.InformationAssetRefs, el.IaLink)) {
+				threats = append(threats, t.Name)
+			}
+		}
+		lines = append(lines, "Threats: "+listOrNone(threats))
+
+		children := []string{}
+		if el.Kind == Process {
+			for _, child := range tm.DataFlowDiagrams {
+				if child.Name != g.Name && Decomposes(child.Name, el.Name) {
+					children = append(children, child.Name)
+				}
+			}
+		}
+
+		if len(children) > 0 {
+			lines = append(lines, "Decomposed in: "+strings.Join(children, ", "))
+		}
+
+		url := ""
+		switch {
+		case len(children) > 0 && link(DiagramSection, children[0]) != "":
+			url = link(DiagramSection, children[0])
+		case len(threats) > 0:
+			url = link(ThreatSection, threats[0])
+		case el.IaLink != "":
+			url = link(AssetSection, el.IaLink)
+		}
+		d.Elements[el.Name] = Detail{Tooltip: strings.Join(lines, "\n"), URL: url}
+	}
+
+	r := Classify([]*Graph{g}, assets)
+	classified := map[string]ClassifiedFlow{}
+	for _, cf := range r.Flows {
+		classified[cf.Flow+"\x00"+cf.From+"\x00"+cf.To] = cf
+	}
+	insecure := map[string]bool{}
+	for _, cf := range r.InsecureFlows {
+		insecure[cf.Flow+"\x00"+cf.From+"\x00"+cf.To] = true
+	}
+
+	for i, f := range g.Flows {
+		key := f.Name + "\x00" + f.From + "\x00" + f.To
+		lines := []string{
+			fmt.Sprintf("%s: %s -> %s", f.Name, f.From, f.To),
+			"Protocol: " + tmutil.FirstNonEmpty(f.Protocol, "unset"),
+		}
+		url := ""
+		if cf, ok := classified[key]; ok {
+			lines = append(lines, "Data: "+assetName(strings.Join(cf.Assets, ", "), cf.Classification))
+			for _, a := range cf.Assets {
+				if assets.Classifications[a] == cf.Classification {
+					url = link(AssetSection, a)
+					break
+				}
+			}
+		}
+		if insecure[key] {
+			lines = append(lines, "Insecure: sensitive data crosses a trust boundary in plaintext")
+		}
+		d.Flows[i] = Detail{Tooltip: strings.Join(lines, "\n"), URL: url}
+	}
+	return d
+}
+
+func assetName(name, classification string) string {
+	if classification == "" {
+		return name
+	}
+	return fmt.Sprintf("%s [%s]", name, classification)
+}
+
+// mentions reports whether text mentions name as a whole word, ignoring
+// case.
+func mentions(text, name string) bool {
+	text, name = strings.ToLower(text), strings.ToLower(name)
+	if name == "" {
+		return false
+	}
+	for i := 0; ; {
+		j := strings.Index(text[i:], name)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(name)
+		if !wordRune(text[:start], true) && !wordRune(text[end:], false) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+// wordRune reports whether the rune at the end (or start) of s is part of
+// a word.
+func wordRune(s string, last bool) bool {
+	if s == "" {
+		return false
+	}
+	r := []rune(s)
+	c := r[0]
+	if last {
+		c = r[len(r)-1]
+	}
+	return unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
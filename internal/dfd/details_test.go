@@ -0,0 +1,64 @@
+package dfd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+func TestDetailsFromSpec(t *testing.T) {
+	g := testAnalyzeGraph()
+	tm := &spec.Threatmodel{
+		InformationAssets: []*spec.InformationAsset{
+			{Name: "Card data", InformationClassification: "Restricted"},
+			{Name: "Logs", InformationClassification: "Internal"},
+		},
+		Threats: []*spec.Threat{
+			{Name: "Web defacement", Description: "Someone changes the web pages"},
+			{Name: "Card theft", Description: "Cards are stolen through the API", InformationAssetRefs: []string{"Card data"}},
+			{Name: "Rapid scraping", Description: "APIs get scraped"},
+		},
+	}
+
+	d := DetailsFromSpec(g, tm, func(section, name string) string {
+		return "#" + section + "-" + strings.ToLower(strings.ReplaceAll(name, " ", "-"))
+	})
+
+	if got := d.Elements["Web"]; got.Tooltip != "Web (process)\nTrust zone: DMZ\nThreats: Web defacement" || got.URL != "#threat-web-defacement" {
+		t.Errorf("unexpected Web detail: %+v", got)
+	}
+	if got := d.Elements["API"]; got.Tooltip != "API (process)\nTrust zone: Internal\nThreats: Card theft" {
+		t.Errorf("expected APIs not to mention API, got %+v", got)
+	}
+	if got := d.Elements["Cards"]; got.Tooltip != "Cards (data store)\nTrust zone: Internal\nInformation asset: Card data [Restricted]\nThreats: Card theft" {
+		t.Errorf("unexpected Cards detail: %+v", got)
+	}
+	if got := d.Elements["Audit"]; got.URL != "#information_asset-logs" || !strings.HasSuffix(got.Tooltip, "Threats: none") {
+		t.Errorf("unexpected Audit detail: %+v", got)
+	}
+	if got := d.Elements["User"]; got.URL != "" || !strings.Contains(got.Tooltip, "Trust zone: outside") {
+		t.Errorf("unexpected User detail: %+v", got)
+	}
+
+	if got := d.Flows[0]; got.Tooltip != "Browse: User -> Web\nProtocol: https\nData: Card data, Logs [Restricted]" || got.URL != "#information_asset-card-data" {
+		t.Errorf("unexpected Browse detail: %+v", got)
+	}
+	if got := d.Flows[5]; !strings.HasSuffix(got.Tooltip, "\nInsecure: sensitive data crosses a trust boundary in plaintext") {
+		t.Errorf("unexpected Reply detail: %+v", got)
+	}
+
+	svg, err := Svg(Dot(g, "Shop", RenderOptions{Details: d}))
+	if err != nil {
+		t.Fatalf("error rendering svg: %s", err)
+	}
+	for _, want := range []string{
+		`xlink:href="#threat-web-defacement"`,
+		`target="_top"`,
+		`xlink:title="Web (process)&#10;Trust zone: DMZ&#10;Threats: Web defacement"`,
+	} {
+		if !strings.Contains(string(svg), want) {
+			t.Errorf("expected %q in the svg:\n%s", want, svg)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package dfd
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+func testDiagram() *spec.DataFlowDiagram {
+	return &spec.DataFlowDiagram{
+		Name:             "Level 0",
+		ExternalElements: []*spec.DfdExternal{{Name: "User"}},
+		TrustZones: []*spec.DfdTrustZone{
+			{
+				Name:       "Internal",
+				Processes:  []*spec.DfdProcess{{Name: "Web"}},
+				DataStores: []*spec.DfdData{{Name: "DB", IaLink: "Card data"}},
+			},
+		},
+		Processes: []*spec.DfdProcess{{Name: "Worker", TrustZone: "Batch"}},
+		Flows: []*spec.DfdFlow{
+			{Name: "Browse", From: "User", To: "Web", Protocol: "https"},
+			{Name: "Query", From: "Web", To: "DB", Protocol: "postgres"},
+			{Name: "Jobs", From: "Web", To: "Worker"},
+			{Name: "Dangling", From: "Web", To: "Nowhere"},
+		},
+	}
+}
+
+func TestFromSpec(t *testing.T) {
+	g := FromSpec(testDiagram())
+
+	if strings.Join(g.Zones, ",") != "Internal,Batch" {
+		t.Errorf("unexpected zones: %v", g.Zones)
+	}
+	exp := []Element{
+		{Name: "Worker", Kind: Process, Zone: "Batch"},
+		{Name: "User", Kind: ExternalElement},
+		{Name: "Web", Kind: Process, Zone: "Internal"},
+		{Name: "DB", Kind: DataStore, Zone: "Internal", IaLink: "Card data"},
+	}
+	if len(g.Elements) != len(exp) {
+		t.Fatalf("expected %d elements, got %+v", len(exp), g.Elements)
+	}
+	for i := range exp {
+		if g.Elements[i] != exp[i] {
+			t.Errorf("element %d: expected %+v, got %+v", i, exp[i], g.Elements[i])
+		}
+	}
+	if el, ok := g.Element("DB"); !ok || el.Kind != DataStore {
+		t.Errorf("expected to find DB, got %+v", el)
+	}
+	if len(g.Flows) != 4 || g.Flows[0].Protocol != "https" {
+		t.Errorf("unexpected flows: %+v", g.Flows)
+	}
+}
+
+func TestPlantUML(t *testing.T) {
+	tests := []struct {
+		name  string
+		style spec.ProtocolStyle
+		exp   []string
+		not   []string
+	}{
+		{
+			"label",
+			spec.ProtocolStyleLabel,
+			[]string{
+				"@startuml\ntitle tm: Level 0\n",
+				`rectangle "User" as el2`,
+				"rectangle \"Internal\" as zone1 #line:red;line.dashed {\n  usecase \"Web\" as el3\n  database \"DB\" as el4\n}",
+				"rectangle \"Batch\" as zone2 #line:red;line.dashed {\n  usecase \"Worker\" as el1\n}",
+				"el2 --> el3 : Browse (https)",
+				"el3 --> el1 : Jobs\n",
+				"@enduml",
+			},
+			[]string{"Dangling", "legend"},
+		},
+		{
+			"color",
+			spec.ProtocolStyleColor,
+			[]string{
+				"el2 -[#1f77b4]-> el3 : Browse\n",
+				"el3 -[#ff7f0e]-> el4 : Query\n",
+				"el3 -[#000000]-> el1 : Jobs",
+				"legend right",
+				"<color:#1f77b4>——</color> https",
+			},
+			[]string{"(https)"},
+		},
+		{
+			"both",
+			spec.ProtocolStyleBoth,
+			[]string{"el2 -[#1f77b4]-> el3 : Browse (https)", "legend right"},
+			nil,
+		},
+		{
+			"none",
+			spec.ProtocolStyleNone,
+			[]string{"el2 --> el3 : Browse\n"},
+			[]string{"https", "legend"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out := PlantUML(FromSpec(testDiagram()), "tm", spec.DfdRenderOptions{ProtocolStyle: tc.style})
+			for _, exp := range tc.exp {
+				if !strings.Contains(out, exp) {
+					t.Errorf("expected %q in:\n%s", exp, out)
+				}
+			}
+			for _, not := range tc.not {
+				if strings.Contains(out, not) {
+					t.Errorf("didn't expect %q in:\n%s", not, out)
+				}
+			}
+		})
+	}
+}
+
+func TestDrawio(t *testing.T) {
+	d := testDiagram()
+	d.Processes = append(d.Processes, &spec.DfdProcess{Name: "<b>Admin</b>"})
+
+	out, err := Drawio(FromSpec(d), "tm", spec.DfdRenderOptions{ProtocolStyle: spec.ProtocolStyleBoth})
+	if err != nil {
+		t.Fatalf("error rendering drawio: %s", err)
+	}
+
+	f := mxFile{}
+	if err := xml.Unmarshal([]byte(out), &f); err != nil {
+		t.Fatalf("error parsing drawio: %s\n%s", err, out)
+	}
+	if f.Diagram.Name != "tm: Level 0" {
+		t.Errorf("unexpected diagram name %q", f.Diagram.Name)
+	}
+
+	cells := map[string]mxCell{}
+	for _, c := range f.Diagram.Model.Cells {
+		if c.Value != "" {
+			cells[c.Value] = c
+		}
+	}
+
+	zone := cells["Internal"]
+	if !strings.Contains(zone.Style, "container=1") || zone.Parent != "1" {
+		t.Errorf("expected the trust zone to be a container: %+v", zone)
+	}
+	if web := cells["Web"]; web.Parent != zone.ID || !strings.HasPrefix(web.Style, "ellipse;") {
+		t.Errorf("expected Web to be a process in its zone: %+v", web)
+	}
+	if db := cells["DB"]; db.Parent != zone.ID || !strings.Contains(db.Style, "shape=partialRectangle") {
+		t.Errorf("expected DB to be a data store in its zone: %+v", db)
+	}
+	if user := cells["User"]; user.Parent != "1" || !strings.HasPrefix(user.Style, "rounded=0;") {
+		t.Errorf("expected User to be an unzoned external element: %+v", user)
+	}
+	if _, ok := cells["&lt;b&gt;Admin&lt;/b&gt;"]; !ok {
+		t.Errorf("expected names to be escaped for html labels")
+	}
+
+	flow := cells["Browse (https)"]
+	if flow.Edge != "1" || flow.Source != cells["User"].ID || flow.Target != cells["Web"].ID || !strings.Contains(flow.Style, "strokeColor=#1f77b4;") {
+		t.Errorf("unexpected flow: %+v", flow)
+	}
+	if _, ok := cells["Dangling"]; ok {
+		t.Errorf("flows to unknown elements shouldn't be drawn")
+	}
+	if legend, ok := cells["postgres"]; !ok || !strings.Contains(legend.Style, "fontColor=#ff7f0e;") {
+		t.Errorf("expected a protocol legend: %+v", legend)
+	}
+}
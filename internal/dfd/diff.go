@@ -0,0 +1,165 @@
+package dfd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Change is how an element or flow differs between two versions of a
+// diagram.
+type Change string
+
+const (
+	Added   Change = "added"
+	Removed Change = "removed"
+
+	// Moved is an element whose trust zone changed.
+	Moved Change = "moved"
+)
+
+// changeColors are the colours changes are drawn in.
+var changeColors = map[Change]string{
+	Added:   "#2ca02c",
+	Removed: pathColor,
+	Moved:   reachColor,
+}
+
+// GraphDiff is two versions of a diagram merged into one graph, with what
+// changed between them. Removed elements sit in their old trust zone, and
+// removed flows join their old ends.
+type GraphDiff struct {
+	Graph    *Graph
+	Elements map[string]Change
+	Flows    map[int]Change
+
+	// From maps moved elements onto their old zone.
+	From map[string]string
+}
+
+// Diff compares the old version of a diagram with the new one. Elements
+// are matched by name, and flows by name and ends.
+func Diff(old, new *Graph) *GraphDiff {
+	d := &GraphDiff{
+		Graph:    &Graph{Name: new.Name, Zones: append([]string{}, new.Zones...)},
+		Elements: map[string]Change{},
+		Flows:    map[int]Change{},
+		From:     map[string]string{},
+	}
+
+	for _, el := range new.Elements {
+		d.Graph.Elements = append(d.Graph.Elements, el)
+		was, ok := old.Element(el.Name)
+		switch {
+		case !ok:
+			d.Elements[el.Name] = Added
+		case was.Zone != el.Zone:
+			d.Elements[el.Name] = Moved
+			d.From[el.Name] = was.Zone
+		}
+	}
+	for _, el := range old.Elements {
+		if _, ok := new.Element(el.Name); ok {
+			continue
+		}
+		d.Graph.Elements = append(d.Graph.Elements, el)
+		d.Elements[el.Name] = Removed
+		if el.Zone != "" {
+			d.Graph.Zones = appendUnique(d.Graph.Zones, el.Zone)
+		}
+	}
+
+	for _, f := range new.Flows {
+		if !hasFlow(old, f) {
+			d.Flows[len(d.Graph.Flows)] = Added
+		}
+		d.Graph.Flows = append(d.Graph.Flows, f)
+	}
+	for _, f := range old.Flows {
+		if !hasFlow(new, f) {
+			d.Flows[len(d.Graph.Flows)] = Removed
+			d.Graph.Flows = append(d.Graph.Flows, f)
+		}
+	}
+	return d
+}
+
+func hasFlow(g *Graph, f Flow) bool {
+	for _, o := range g.Flows {
+		if o.Name == f.Name && o.From == f.From && o.To == f.To {
+			return true
+		}
+	}
+	return false
+}
+
+// Empty reports whether nothing changed.
+func (d *GraphDiff) Empty() bool {
+	return len(d.Elements) == 0 && len(d.Flows) == 0
+}
+
+// label is how a changed element is labelled: moved elements say where
+// they came from.
+func (d *GraphDiff) label(el Element) string {
+	if d.Elements[el.Name] == Moved {
+		return fmt.Sprintf("%s\n(moved from %s)", el.Name, zoneName(d.From[el.Name]))
+	}
+	return el.Name
+}
+
+// changes lists the kinds of change in d, in legend order.
+func (d *GraphDiff) changes() []Change {
+	out := []Change{}
+	for _, c := range []Change{Added, Removed, Moved} {
+		found := false
+		for _, ec := range d.Elements {
+			found = found || ec == c
+		}
+		for _, fc := range d.Flows {
+			found = found || fc == c
+		}
+		if found {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// String summarises the changes as text.
+func (d *GraphDiff) String() string {
+	if d.Empty() {
+		return "No changes\n"
+	}
+
+	var b strings.Builder
+	for _, c := range []Change{Added, Removed} {
+		els := []string{}
+		for _, el := range d.Graph.Elements {
+			if d.Elements[el.Name] == c {
+				els = append(els, el.Name)
+			}
+		}
+		flows := []string{}
+		for i, f := range d.Graph.Flows {
+			if d.Flows[i] == c {
+				flows = append(flows, fmt.Sprintf("%s (%s -> %s)", f.Name, f.From, f.To))
+			}
+		}
+		label := strings.ToUpper(string(c[:1])) + string(c[1:])
+		if len(els) > 0 {
+			fmt.Fprintf(&b, "%s elements: %s\n", label, strings.Join(els, ", "))
+		}
+		if len(flows) > 0 {
+			fmt.Fprintf(&b, "%s flows: %s\n", label, strings.Join(flows, ", "))
+		}
+	}
+	moved := []string{}
+	for _, el := range d.Graph.Elements {
+		if d.Elements[el.Name] == Moved {
+			moved = append(moved, fmt.Sprintf("%s (%s -> %s)", el.Name, zoneName(d.From[el.Name]), zoneName(el.Zone)))
+		}
+	}
+	if len(moved) > 0 {
+		fmt.Fprintf(&b, "Moved elements: %s\n", strings.Join(moved, ", "))
+	}
+	return b.String()
+}
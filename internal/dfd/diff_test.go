@@ -0,0 +1,131 @@
+package dfd
+
+import (
+	"strings"
+	"testing"
+)
+
+// testDiffGraphs returns testAnalyzeGraph before and after a change: the
+// audit log is gone, a cache is added and the API moves into the DMZ.
+func testDiffGraphs() (*Graph, *Graph) {
+	old := testAnalyzeGraph()
+	new := testAnalyzeGraph()
+	new.Elements = []Element{
+		{Name: "User", Kind: ExternalElement},
+		{Name: "Web", Kind: Process, Zone: "DMZ"},
+		{Name: "API", Kind: Process, Zone: "DMZ"},
+		{Name: "Cards", Kind: DataStore, Zone: "Internal", IaLink: "Card data"},
+		{Name: "Cache", Kind: DataStore, Zone: "DMZ"},
+		{Name: "Admin", Kind: ExternalElement},
+	}
+	new.Flows = []Flow{
+		{Name: "Browse", From: "User", To: "Web", Protocol: "https"},
+		{Name: "Call", From: "Web", To: "API", Protocol: "grpc"},
+		{Name: "Store", From: "API", To: "Cards"},
+		{Name: "Cache", From: "API", To: "Cache"},
+	}
+	return old, new
+}
+
+func TestDiff(t *testing.T) {
+	d := Diff(testDiffGraphs())
+
+	if len(d.Graph.Elements) != 7 || d.Graph.Elements[6].Name != "Audit" || d.Graph.Elements[6].Zone != "Internal" {
+		t.Errorf("expected the removed store at the end, got %+v", d.Graph.Elements)
+	}
+	if d.Elements["Cache"] != Added || d.Elements["Audit"] != Removed || d.Elements["API"] != Moved || d.Elements["Web"] != "" {
+		t.Errorf("unexpected element changes: %+v", d.Elements)
+	}
+	if d.From["API"] != "Internal" {
+		t.Errorf("expected API to have moved from Internal, got %+v", d.From)
+	}
+	if d.Flows[3] != Added || d.Flows[4] != Removed || d.Flows[0] != "" || len(d.Graph.Flows) != 8 {
+		t.Errorf("unexpected flow changes: %+v", d.Flows)
+	}
+
+	for _, want := range []string{
+		"Added elements: Cache\n",
+		"Added flows: Cache (API -> Cache)\n",
+		"Removed elements: Audit\n",
+		"Removed flows: Log (API -> Audit), Replay (Audit -> Cards), Reply (API -> Web), Dangling (API -> Nowhere)\n",
+		"Moved elements: API (Internal -> DMZ)\n",
+	} {
+		if !strings.Contains(d.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, d)
+		}
+	}
+
+	old, _ := testDiffGraphs()
+	if same := Diff(old, old); !same.Empty() || same.String() != "No changes\n" {
+		t.Errorf("expected no changes, got %s", same)
+	}
+}
+
+func TestDotDiff(t *testing.T) {
+	d := Diff(testDiffGraphs())
+
+	out := Dot(d.Graph, "Shop", RenderOptions{Diff: d})
+	for _, want := range []string{
+		`el3 [label="API\n(moved from Internal)", shape=ellipse, color="#ff7f0e", penwidth=2.5`,
+		`el5 [label="Cache", shape=cylinder, color="#2ca02c"`,
+		`el7 [label="Audit", shape=cylinder, color="#d62728", fontcolor="#d62728", style=dashed];`,
+		`el3 -> el7 [label="Log", color="#d62728", fontcolor="#d62728", style=dashed];`,
+		`el1 -> el2 [label="Browse (https)"];`,
+		`label="Changes";`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+
+	png, err := Png(out)
+	if err != nil {
+		t.Fatalf("error rendering png: %s", err)
+	}
+	if !strings.HasPrefix(string(png), "\x89PNG") {
+		t.Errorf("unexpected png: %.20q", png)
+	}
+}
+
+func TestMermaid(t *testing.T) {
+	g := testAnalyzeGraph()
+
+	out := Mermaid(g, "Shop", RenderOptions{})
+	for _, want := range []string{
+		"---\ntitle: \"Shop: Level 0\"\n---\nflowchart LR\n",
+		`  el1["User"]`,
+		`  subgraph zone2["Internal"]`,
+		`    el4[("Cards")]`,
+		`  el1 -->|"Browse (https)"| el2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Nowhere") || strings.Contains(out, "linkStyle") {
+		t.Errorf("unexpected mermaid:\n%s", out)
+	}
+
+	parsed, _, err := ParseMermaid("Level 0", out)
+	if err != nil {
+		t.Fatalf("error parsing the mermaid back: %s", err)
+	}
+	if cards, _ := parsed.Element("Cards"); len(parsed.Elements) != 6 || len(parsed.Flows) != 6 || cards.Kind != DataStore || cards.Zone != "Internal" {
+		t.Errorf("unexpected round trip: %+v", parsed)
+	}
+
+	d := Diff(testDiffGraphs())
+	out = Mermaid(d.Graph, "Shop", RenderOptions{Diff: d})
+	for _, want := range []string{
+		`  el3(("API<br/>(moved from Internal)"))`,
+		"  el3 -.->|\"Log\"| el7\n",
+		"  style el7 stroke:#d62728,color:#d62728,stroke-dasharray:5 5\n",
+		"  style el5 stroke:#2ca02c,color:#2ca02c,stroke-width:3px\n",
+		"  linkStyle 3 stroke:#2ca02c,color:#2ca02c,stroke-width:3px\n",
+		"  linkStyle 4 stroke:#d62728,color:#d62728\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package dfd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/goccy/go-graphviz"
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// dotShapes are the Graphviz shapes drawn for each of Shapes.
+var dotShapes = map[string]string{
+	"ellipse":       "ellipse",
+	"circle":        "circle",
+	"rectangle":     "box",
+	"rounded":       "box",
+	"cylinder":      "cylinder",
+	"hexagon":       "hexagon",
+	"diamond":       "diamond",
+	"parallelogram": "parallelogram",
+	"stub":          "plaintext",
+}
+
+// Highlight maps elements, by name, and flows, by index, onto the colour
+// to emphasise them in. Everything else is drawn faded.
+type Highlight struct {
+	Elements map[string]string
+	Flows    map[int]string
+}
+
+// RenderOptions configures Dot, Mermaid and D2.
+type RenderOptions struct {
+	ProtocolStyle spec.ProtocolStyle
+
+	// Highlight, if set, emphasises part of the diagram.
+	Highlight *Highlight
+
+	// Diff, if set, draws the changes between two versions of the diagram.
+	// The diagram drawn must be Diff.Graph.
+	Diff *GraphDiff
+
+	// Details, if set, make an SVG interactive: hovering over an element
+	// or flow shows its tooltip, and clicking it follows its URL.
+	Details *Details
+
+	// Theme, if set, styles the diagram.
+	Theme *Theme
+
+	// Classifications map flows, by index, onto the classification of the
+	// data they carry, for the theme to style them by (see
+	// FlowClassifications).
+	Classifications map[int]string
+}
+
+// fadedColor is the colour of everything a highlight leaves out.
+const fadedColor = "#bbbbbb"
+
+// Dot renders g as a Graphviz digraph, with trust zones as dashed
+// clusters.
+func Dot(g *Graph, tmName string, opts RenderOptions) string {
+	var b strings.Builder
+
+	theme := opts.Theme
+	font := dotQuote(theme.font())
+
+	b.WriteString("digraph dfd {\n")
+	fmt.Fprintf(&b, "  label=%s;\n  labelloc=t;\n  rankdir=%s;\n  fontname=%s;\n", dotQuote(title(tmName, g)), theme.direction(), font)
+	fmt.Fprintf(&b, "  node [fontname=%s];\n  edge [fontname=%s, fontsize=10];\n\n", font, font)
+
+	ids := map[string]string{}
+	for i, el := range g.Elements {
+		ids[el.Name] = fmt.Sprintf("el%d", i+1)
+	}
+
+	writeElements := func(els []Element, indent string) {
+		for _, el := range els {
+			label := el.label()
+			if opts.Diff != nil {
+				label = opts.Diff.label(el)
+			}
+			style := theme.element(el.Kind)
+			attrs := []string{
+				"label=" + dotQuote(label),
+				"shape=" + dotShapes[style.Shape],
+			}
+			attrs = append(attrs, dotElementStyle(style)...)
+			switch {
+			case opts.Highlight != nil:
+				if color, ok := opts.Highlight.Elements[el.Name]; ok {
+					attrs = append(attrs, emphasised(color)...)
+				} else {
+					attrs = append(attrs, faded()...)
+				}
+			case opts.Diff != nil:
+				attrs = append(attrs, changed(opts.Diff.Elements[el.Name])...)
+			}
+			if opts.Details != nil {
+				attrs = append(attrs, detailAttrs(opts.Details.Elements[el.Name])...)
+			}
+			fmt.Fprintf(&b, "%s%s [%s];\n", indent, ids[el.Name], strings.Join(attrs, ", "))
+		}
+	}
+	writeElements(g.InZone(""), "  ")
+	for i, zone := range g.Zones {
+		fmt.Fprintf(&b, "\n  subgraph cluster_zone%d {\n", i+1)
+		fmt.Fprintf(&b, "    label=%s;\n", dotQuote(zone))
+		style := theme.zone(zone)
+		if style.Fill != "" {
+			fmt.Fprintf(&b, "    style=\"%s,filled\";\n    fillcolor=%s;\n", style.Line, dotQuote(style.Fill))
+		} else {
+			fmt.Fprintf(&b, "    style=%s;\n", style.Line)
+		}
+		fmt.Fprintf(&b, "    color=%s;\n    fontcolor=%s;\n", dotColor(style.Color), dotColor(style.FontColor))
+		if theme != nil && theme.ClusterSpacing > 0 {
+			fmt.Fprintf(&b, "    margin=%d;\n", theme.ClusterSpacing)
+		}
+		writeElements(g.InZone(zone), "    ")
+		b.WriteString("  }\n")
+	}
+	b.WriteString("\n")
+
+	colors := protocolColors(g)
+	for i, f := range g.Flows {
+		from, ok := ids[f.From]
+		if !ok {
+			continue
+		}
+		to, ok := ids[f.To]
+		if !ok {
+			continue
+		}
+
+		attrs := []string{}
+		if label := flowLabel(f, opts.ProtocolStyle); label != "" {
+			attrs = append(attrs, "label="+dotQuote(label))
+		}
+		switch {
+		case opts.Highlight != nil:
+			if color, ok := opts.Highlight.Flows[i]; ok {
+				attrs = append(attrs, emphasised(color)...)
+			} else {
+				attrs = append(attrs, faded()...)
+			}
+		case opts.Diff != nil:
+			attrs = append(attrs, changed(opts.Diff.Flows[i])...)
+		case themed(theme, f, opts.Classifications[i]):
+			style, _ := theme.edge(f.Protocol, opts.Classifications[i])
+			attrs = append(attrs, dotEdgeStyle(style)...)
+		case colored(opts.ProtocolStyle):
+			color := tmutil.FirstNonEmpty(colors[f.Protocol], unsetProtocolColor)
+			attrs = append(attrs, fmt.Sprintf("color=%q", color), fmt.Sprintf("fontcolor=%q", color))
+		}
+		if opts.Details != nil {
+			attrs = append(attrs, detailAttrs(opts.Details.Flows[i])...)
+		}
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n", from, to, strings.Join(attrs, ", "))
+	}
+
+	switch {
+	case opts.Diff != nil && len(opts.Diff.changes()) > 0:
+		b.WriteString("\n  subgraph cluster_legend {\n    label=\"Changes\";\n    style=solid;\n    color=\"#999999\";\n")
+		for i, c := range opts.Diff.changes() {
+			fmt.Fprintf(&b, "    legend%d [shape=plaintext, label=%s, fontcolor=%q];\n", i+1, dotQuote(string(c)), changeColors[c])
+		}
+		b.WriteString("  }\n")
+	case opts.Highlight == nil && opts.Diff == nil && len(theme.legend(g, opts.Classifications)) > 0:
+		b.WriteString("\n  subgraph cluster_legend {\n    label=\"Legend\";\n    style=solid;\n    color=\"#999999\";\n")
+		for i, e := range theme.legend(g, opts.Classifications) {
+			fmt.Fprintf(&b, "    legend%d [shape=plaintext, label=%s, fontcolor=%s];\n", i+1, dotQuote(e.Label), dotColor(e.Color))
+		}
+		b.WriteString("  }\n")
+	case colored(opts.ProtocolStyle) && opts.Highlight == nil && opts.Diff == nil && len(colors) > 0:
+		b.WriteString("\n  subgraph cluster_legend {\n    label=\"Protocols\";\n    style=solid;\n    color=\"#999999\";\n")
+		for i, p := range sortedKeys(colors) {
+			fmt.Fprintf(&b, "    legend%d [shape=plaintext, label=%s, fontcolor=%q];\n", i+1, dotQuote(p), colors[p])
+		}
+		b.WriteString("  }\n")
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// themed reports whether the theme styles flow f, which carries data of
+// classification.
+func themed(theme *Theme, f Flow, classification string) bool {
+	_, ok := theme.edge(f.Protocol, classification)
+	return ok
+}
+
+// dotElementStyle are the attributes of an element's themed style.
+func dotElementStyle(s ElementStyle) []string {
+	attrs := []string{}
+	styles := []string{}
+	if s.Shape == "rounded" {
+		styles = append(styles, "rounded")
+	}
+	if s.Fill != "" {
+		styles = append(styles, "filled")
+		attrs = append(attrs, "fillcolor="+dotQuote(s.Fill))
+	}
+	if len(styles) > 0 {
+		attrs = append(attrs, "style="+dotQuote(strings.Join(styles, ",")))
+	}
+	if s.Color != "" {
+		attrs = append(attrs, "color="+dotQuote(s.Color))
+	}
+	if s.Font != "" {
+		attrs = append(attrs, "fontname="+dotQuote(s.Font))
+	}
+	if s.FontSize > 0 {
+		attrs = append(attrs, fmt.Sprintf("fontsize=%d", s.FontSize))
+	}
+	if s.FontColor != "" {
+		attrs = append(attrs, "fontcolor="+dotQuote(s.FontColor))
+	}
+	return attrs
+}
+
+// dotEdgeStyle are the attributes of a flow's themed style.
+func dotEdgeStyle(s EdgeStyle) []string {
+	attrs := []string{}
+	if s.Color != "" {
+		attrs = append(attrs, fmt.Sprintf("color=%q", s.Color), fmt.Sprintf("fontcolor=%q", s.Color))
+	}
+	if s.Width > 0 {
+		attrs = append(attrs, fmt.Sprintf("penwidth=%d", s.Width))
+	}
+	if s.Line != "" {
+		attrs = append(attrs, "style="+s.Line)
+	}
+	return attrs
+}
+
+// dotColor writes a colour, bare if it's a plain colour name.
+func dotColor(color string) string {
+	for _, r := range color {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return dotQuote(color)
+		}
+	}
+	return color
+}
+
+func emphasised(color string) []string {
+	return []string{fmt.Sprintf("color=%q", color), "penwidth=2.5", fmt.Sprintf("fontcolor=%q", color)}
+}
+
+func faded() []string {
+	return []string{fmt.Sprintf("color=%q", fadedColor), fmt.Sprintf("fontcolor=%q", fadedColor)}
+}
+
+// changed styles a change: removed elements and flows are ghosted, and
+// unchanged ones drawn as normal.
+func changed(c Change) []string {
+	switch c {
+	case "":
+		return nil
+	case Removed:
+		return []string{fmt.Sprintf("color=%q", changeColors[c]), fmt.Sprintf("fontcolor=%q", changeColors[c]), "style=dashed"}
+	}
+	return emphasised(changeColors[c])
+}
+
+// detailAttrs are the attributes of an interactive element or flow. Links
+// open in the top window, so they work from a diagram inlined in a page or
+// embedded with an object element.
+func detailAttrs(d Detail) []string {
+	attrs := []string{}
+	if d.Tooltip != "" {
+		attrs = append(attrs, "tooltip="+dotQuote(d.Tooltip))
+	}
+	if d.URL != "" {
+		attrs = append(attrs, "URL="+dotQuote(d.URL), `target="_top"`)
+	}
+	return attrs
+}
+
+// dotQuote quotes s as a DOT string.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + strings.ReplaceAll(s, "\n", `\n`) + `"`
+}
+
+// Svg lays out and renders a DOT graph as SVG.
+func Svg(dot string) ([]byte, error) {
+	return render(dot, graphviz.SVG)
+}
+
+// Png lays out and renders a DOT graph as PNG.
+func Png(dot string) ([]byte, error) {
+	return render(dot, graphviz.PNG)
+}
+
+func render(dot string, format graphviz.Format) ([]byte, error) {
+	ctx := context.Background()
+	gv, err := graphviz.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer gv.Close()
+
+	graph, err := graphviz.ParseBytes([]byte(dot))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing DOT: %s", err)
+	}
+	defer graph.Close()
+
+	var buf bytes.Buffer
+	if err := gv.Render(ctx, graph, format, &buf); err != nil {
+		return nil, fmt.Errorf("error rendering %s: %s", format, err)
+	}
+	return buf.Bytes(), nil
+}
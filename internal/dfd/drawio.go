@@ -0,0 +1,206 @@
+package dfd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// drawio layout, in pixels. Elements are stacked in a column per trust zone,
+// unzoned elements first.
+const (
+	drawioColumnWidth = 240
+	drawioRowHeight   = 120
+	drawioZoneHeader  = 40
+	drawioMargin      = 20
+)
+
+// drawioStyles are the diagrams.net styles of each kind: DFD notation, with
+// processes as ellipses, data stores as open-ended boxes and external
+// elements as rectangles. Elided stubs are bare text.
+var drawioStyles = map[Kind]string{
+	Process:         "ellipse;whiteSpace=wrap;html=1;fillColor=#dae8fc;strokeColor=#6c8ebf;",
+	DataStore:       "shape=partialRectangle;whiteSpace=wrap;html=1;left=0;right=0;fillColor=#fff2cc;strokeColor=#d6b656;",
+	ExternalElement: "rounded=0;whiteSpace=wrap;html=1;fillColor=#f5f5f5;strokeColor=#666666;",
+	Elided:          "text;html=1;align=center;verticalAlign=middle;",
+}
+
+// drawioSizes are the width and height of each kind.
+var drawioSizes = map[Kind][2]int{
+	Process:         {100, 100},
+	DataStore:       {160, 60},
+	ExternalElement: {160, 80},
+	Elided:          {40, 40},
+}
+
+const (
+	drawioZoneStyle   = "swimlane;container=1;collapsible=0;dashed=1;html=1;startSize=30;fillColor=none;strokeColor=#b85450;fontColor=#b85450;"
+	drawioFlowStyle   = "edgeStyle=orthogonalEdgeStyle;rounded=1;html=1;endArrow=classic;"
+	drawioLegendStyle = "text;html=1;align=left;verticalAlign=middle;"
+)
+
+type mxFile struct {
+	XMLName xml.Name  `xml:"mxfile"`
+	Host    string    `xml:"host,attr"`
+	Diagram mxDiagram `xml:"diagram"`
+}
+
+type mxDiagram struct {
+	ID    string       `xml:"id,attr"`
+	Name  string       `xml:"name,attr"`
+	Model mxGraphModel `xml:"mxGraphModel"`
+}
+
+type mxGraphModel struct {
+	Grid   int      `xml:"grid,attr"`
+	Arrows int      `xml:"arrows,attr"`
+	Cells  []mxCell `xml:"root>mxCell"`
+}
+
+type mxCell struct {
+	ID       string      `xml:"id,attr"`
+	Value    string      `xml:"value,attr,omitempty"`
+	Style    string      `xml:"style,attr,omitempty"`
+	Vertex   string      `xml:"vertex,attr,omitempty"`
+	Edge     string      `xml:"edge,attr,omitempty"`
+	Parent   string      `xml:"parent,attr,omitempty"`
+	Source   string      `xml:"source,attr,omitempty"`
+	Target   string      `xml:"target,attr,omitempty"`
+	Geometry *mxGeometry `xml:"mxGeometry"`
+}
+
+type mxGeometry struct {
+	X        int    `xml:"x,attr,omitempty"`
+	Y        int    `xml:"y,attr,omitempty"`
+	Width    int    `xml:"width,attr,omitempty"`
+	Height   int    `xml:"height,attr,omitempty"`
+	Relative string `xml:"relative,attr,omitempty"`
+	As       string `xml:"as,attr"`
+}
+
+// Drawio renders g as diagrams.net XML, with trust zones as containers
+// holding their elements so they move together when the diagram is edited.
+// Labels are HTML, so names are escaped.
+func Drawio(g *Graph, tmName string, opts spec.DfdRenderOptions) (string, error) {
+	cells := []mxCell{{ID: "0"}, {ID: "1", Parent: "0"}}
+
+	ids := map[string]string{}
+	place := func(els []Element, parent string, x, y int) {
+		for i, el := range els {
+			size := drawioSizes[el.Kind]
+			id := fmt.Sprintf("el-%d", len(ids)+1)
+			ids[el.Name] = id
+			cells = append(cells, mxCell{
+				ID:     id,
+				Value:  html.EscapeString(el.label()),
+				Style:  drawioStyles[el.Kind],
+				Vertex: "1",
+				Parent: parent,
+				Geometry: &mxGeometry{
+					X:      x + (drawioColumnWidth-2*drawioMargin-size[0])/2,
+					Y:      y + i*drawioRowHeight + (drawioRowHeight-size[1])/2,
+					Width:  size[0],
+					Height: size[1],
+					As:     "geometry",
+				},
+			})
+		}
+	}
+
+	column := 0
+	if unzoned := g.InZone(""); len(unzoned) > 0 {
+		place(unzoned, "1", drawioMargin, drawioZoneHeader)
+		column++
+	}
+	for i, zone := range g.Zones {
+		els := g.InZone(zone)
+		rows := len(els)
+		if rows == 0 {
+			rows = 1
+		}
+		id := fmt.Sprintf("zone-%d", i+1)
+		cells = append(cells, mxCell{
+			ID:     id,
+			Value:  html.EscapeString(zone),
+			Style:  drawioZoneStyle,
+			Vertex: "1",
+			Parent: "1",
+			Geometry: &mxGeometry{
+				X:      column*drawioColumnWidth + drawioMargin,
+				Y:      drawioMargin,
+				Width:  drawioColumnWidth - 2*drawioMargin,
+				Height: rows*drawioRowHeight + drawioZoneHeader,
+				As:     "geometry",
+			},
+		})
+		// children are positioned relative to their container
+		place(els, id, 0, drawioZoneHeader)
+		column++
+	}
+
+	colors := protocolColors(g)
+	for i, f := range g.Flows {
+		from, ok := ids[f.From]
+		if !ok {
+			continue
+		}
+		to, ok := ids[f.To]
+		if !ok {
+			continue
+		}
+
+		style := drawioFlowStyle
+		if colored(opts.ProtocolStyle) {
+			color := tmutil.FirstNonEmpty(colors[f.Protocol], unsetProtocolColor)
+			style += fmt.Sprintf("strokeColor=%s;fontColor=%s;", color, color)
+		}
+		cells = append(cells, mxCell{
+			ID:       fmt.Sprintf("flow-%d", i+1),
+			Value:    html.EscapeString(flowLabel(f, opts.ProtocolStyle)),
+			Style:    style,
+			Edge:     "1",
+			Parent:   "1",
+			Source:   from,
+			Target:   to,
+			Geometry: &mxGeometry{Relative: "1", As: "geometry"},
+		})
+	}
+
+	if colored(opts.ProtocolStyle) && len(colors) > 0 {
+		x := column*drawioColumnWidth + drawioMargin
+		cells = append(cells, mxCell{
+			ID:       "legend",
+			Value:    "<b>Protocols</b>",
+			Style:    drawioLegendStyle,
+			Vertex:   "1",
+			Parent:   "1",
+			Geometry: &mxGeometry{X: x, Y: drawioMargin, Width: 160, Height: 30, As: "geometry"},
+		})
+		for i, p := range sortedKeys(colors) {
+			cells = append(cells, mxCell{
+				ID:       fmt.Sprintf("legend-%d", i+1),
+				Value:    html.EscapeString(p),
+				Style:    drawioLegendStyle + fmt.Sprintf("fontColor=%s;", colors[p]),
+				Vertex:   "1",
+				Parent:   "1",
+				Geometry: &mxGeometry{X: x, Y: drawioMargin + (i+1)*30, Width: 160, Height: 30, As: "geometry"},
+			})
+		}
+	}
+
+	out, err := xml.MarshalIndent(mxFile{
+		Host: "threatcl",
+		Diagram: mxDiagram{
+			ID:    "dfd",
+			Name:  title(tmName, g),
+			Model: mxGraphModel{Grid: 1, Arrows: 1, Cells: cells},
+		},
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,308 @@
+package dfd
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// drawioRead is a diagrams.net file, either an mxfile of pages or a bare
+// mxGraphModel.
+type drawioRead struct {
+	XMLName  xml.Name
+	Diagrams []struct {
+		Name    string           `xml:"name,attr"`
+		Model   *drawioModelRead `xml:"mxGraphModel"`
+		Content string           `xml:",chardata"`
+	} `xml:"diagram"`
+	Root drawioRootRead `xml:"root"`
+}
+
+type drawioModelRead struct {
+	Root drawioRootRead `xml:"root"`
+}
+
+type drawioRootRead struct {
+	Items []drawioItem `xml:",any"`
+}
+
+// drawioItem is an mxCell, or an object or UserObject wrapping one with
+// custom properties.
+type drawioItem struct {
+	XMLName      xml.Name
+	ID           string      `xml:"id,attr"`
+	Label        string      `xml:"label,attr"`
+	ThreatclType string      `xml:"threatcl_type,attr"`
+	Value        string      `xml:"value,attr"`
+	Style        string      `xml:"style,attr"`
+	Vertex       string      `xml:"vertex,attr"`
+	Edge         string      `xml:"edge,attr"`
+	Parent       string      `xml:"parent,attr"`
+	Source       string      `xml:"source,attr"`
+	Target       string      `xml:"target,attr"`
+	Cell         *drawioItem `xml:"mxCell"`
+}
+
+// drawioCell is a cell with any wrapping object folded in.
+type drawioCell struct {
+	id, label, kind        string
+	rawStyle               string
+	style                  map[string]string
+	base                   string
+	vertex, edge           bool
+	parent, source, target string
+}
+
+// ParseDrawio reads every page of a diagrams.net file as a graph. Shapes
+// are mapped by their style, or by a threatcl style key or threatcl_type
+// property set to process, data_store or external_element. Containers become
+// trust zones. Shapes that don't clearly map onto a DFD element, including
+// plain and rounded rectangles other than the external elements written by
+// Drawio, are skipped and listed in the report.
+func ParseDrawio(data []byte) ([]*Graph, Report, error) {
+	f := drawioRead{}
+	if err := xml.Unmarshal(data, &f); err != nil {
+		return nil, nil, fmt.Errorf("error parsing drawio file: %s", err)
+	}
+
+	type page struct {
+		name  string
+		items []drawioItem
+	}
+	pages := []page{}
+	switch f.XMLName.Local {
+	case "mxGraphModel":
+		pages = append(pages, page{"Diagram 1", f.Root.Items})
+	case "mxfile":
+		for n, d := range f.Diagrams {
+			name := strings.TrimSpace(d.Name)
+			if name == "" {
+				name = fmt.Sprintf("Diagram %d", n+1)
+			}
+			model := d.Model
+			if model == nil {
+				var err error
+				model, err = inflateDrawio(d.Content)
+				if err != nil {
+					return nil, nil, fmt.Errorf("error reading drawio page %q: %s", name, err)
+				}
+			}
+			pages = append(pages, page{name, model.Root.Items})
+		}
+	default:
+		return nil, nil, fmt.Errorf("error parsing drawio file: unexpected <%s> element", f.XMLName.Local)
+	}
+
+	graphs := []*Graph{}
+	report := Report{}
+	for _, p := range pages {
+		graphs = append(graphs, drawioGraph(p.name, p.items, &report))
+	}
+	return graphs, report, nil
+}
+
+// inflateDrawio decodes a compressed page: base64 of raw deflate of the
+// URI encoded model.
+func inflateDrawio(content string) (*drawioModelRead, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(content))
+	if err != nil {
+		return nil, err
+	}
+	inflated, err := io.ReadAll(flate.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		return nil, err
+	}
+	unescaped, err := url.PathUnescape(string(inflated))
+	if err != nil {
+		return nil, err
+	}
+	m := &drawioModelRead{}
+	if err := xml.Unmarshal([]byte(unescaped), m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func drawioGraph(name string, items []drawioItem, report *Report) *Graph {
+	i := newImporter(name, report)
+
+	cells := []*drawioCell{}
+	byID := map[string]*drawioCell{}
+	children := map[string]int{}
+	for _, it := range items {
+		c := foldDrawioItem(it)
+		cells = append(cells, c)
+		byID[c.id] = c
+		if c.vertex {
+			children[c.parent]++
+		}
+	}
+
+	isZone := func(c *drawioCell) bool {
+		return c.vertex && (c.style["container"] == "1" || c.base == "swimlane" || (children[c.id] > 0 && c.base != "group"))
+	}
+
+	// zoneOf is the label of the nearest container holding c
+	zoneOf := func(c *drawioCell) string {
+		for p := byID[c.parent]; p != nil; p = byID[p.parent] {
+			if isZone(p) {
+				return p.label
+			}
+		}
+		return ""
+	}
+
+	edgeLabels := map[string][]string{}
+	zones := 0
+	for _, c := range cells {
+		switch {
+		case !c.vertex:
+			continue
+		case byID[c.parent] != nil && byID[c.parent].edge:
+			edgeLabels[c.parent] = append(edgeLabels[c.parent], c.label)
+		case isZone(c):
+			zones++
+			if c.label == "" {
+				c.label = fmt.Sprintf("Zone %d", zones)
+			}
+		}
+	}
+
+	for _, c := range cells {
+		if !c.vertex || isZone(c) || c.base == "group" {
+			continue
+		}
+		if p := byID[c.parent]; p != nil && p.edge {
+			continue
+		}
+
+		kind, source, note := drawioKind(c)
+		if kind == "" && source == "text" {
+			// free text annotates the diagram, it isn't a shape
+			continue
+		}
+		if kind != "" && c.label == "" {
+			kind, note = "", "has no label"
+		}
+		i.element(c.id, c.label, kind, zoneOf(c), source, note)
+	}
+
+	for _, c := range cells {
+		if !c.edge {
+			continue
+		}
+		label := strings.TrimSpace(strings.Join(append([]string{c.label}, edgeLabels[c.id]...), " "))
+		i.flow(c.source, c.target, label, "edge")
+	}
+
+	// keep empty trust zones too
+	for _, c := range cells {
+		if isZone(c) {
+			i.g.Zones = appendUnique(i.g.Zones, c.label)
+		}
+	}
+	return i.g
+}
+
+func foldDrawioItem(it drawioItem) *drawioCell {
+	c := &drawioCell{id: it.ID, label: plainText(it.Value)}
+	inner := it
+	if it.Cell != nil {
+		// object and UserObject keep the label and properties outside
+		// their mxCell
+		inner = *it.Cell
+		c.label = plainText(it.Label)
+		c.kind = it.ThreatclType
+	}
+	c.rawStyle = inner.Style
+	c.style, c.base = parseStyle(inner.Style)
+	c.vertex = inner.Vertex == "1"
+	c.edge = inner.Edge == "1"
+	c.parent, c.source, c.target = inner.Parent, inner.Source, inner.Target
+	return c
+}
+
+// drawioKind maps a shape onto an element kind, describing the shape for
+// the report. An empty kind means the shape is ambiguous.
+func drawioKind(c *drawioCell) (Kind, string, string) {
+	shape := c.style["shape"]
+	if shape == "" {
+		shape = c.base
+	}
+	source := shape
+	if source == "" {
+		source = "rectangle"
+	}
+
+	explicit := tmutil.FirstNonEmpty(c.kind, c.style["threatcl"])
+	if explicit != "" {
+		if kind, ok := parseKind(explicit); ok {
+			return kind, source, ""
+		}
+		return "", source, fmt.Sprintf("threatcl type %q isn't process, data_store or external_element", explicit)
+	}
+
+	switch shape {
+	case "ellipse", "doubleEllipse", "mxgraph.flowchart.start_2", "mxgraph.flowchart.on-page_reference":
+		return Process, source, ""
+	case "cylinder", "cylinder2", "cylinder3", "datastore", "partialRectangle", "mxgraph.flowchart.database", "mxgraph.flowchart.stored_data":
+		return DataStore, source, ""
+	case "umlActor", "actor", "mxgraph.basic.person":
+		return ExternalElement, source, ""
+	case "", "rect", "rectangle":
+		// rectangles are drawn for anything, so only the external elements
+		// written by Drawio are taken as one
+		if c.rawStyle == drawioStyles[ExternalElement] {
+			return ExternalElement, source, ""
+		}
+		if c.style["rounded"] == "1" {
+			source = "rounded rectangle"
+		}
+		if c.style["dashed"] == "1" {
+			return "", "dashed rectangle", "looks like a trust boundary but holds no shapes, set a threatcl style to import it as an element"
+		}
+		return "", source, "could be any kind of element, set a threatcl=process|data_store|external_element style to import it"
+	case "text":
+		return "", source, ""
+	}
+	return "", source, "doesn't map onto a DFD element, set a threatcl=process|data_store|external_element style to import it"
+}
+
+// parseStyle splits a style into its keys and its leading base name, as in
+// "ellipse;whiteSpace=wrap;".
+func parseStyle(style string) (map[string]string, string) {
+	out := map[string]string{}
+	base := ""
+	for n, part := range strings.Split(style, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			if n == 0 {
+				base = strings.TrimSpace(part)
+			}
+			continue
+		}
+		out[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return out, base
+}
+
+var (
+	htmlBreak = regexp.MustCompile(`(?i)<br\s*/?>|</div>|</p>`)
+	htmlTag   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// plainText is an HTML label as plain text on one line.
+func plainText(s string) string {
+	s = htmlBreak.ReplaceAllString(s, " ")
+	s = htmlTag.ReplaceAllString(s, "")
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
@@ -0,0 +1,234 @@
+package dfd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// Cluster is how a fleet map groups its elements.
+type Cluster string
+
+const (
+	ClusterModel Cluster = "model"
+	ClusterZone  Cluster = "zone"
+)
+
+// FleetModel is a threat model's diagrams, to merge into a fleet map.
+type FleetModel struct {
+	Name   string
+	Graphs []*Graph
+}
+
+// FleetOptions configures Fleet.
+type FleetOptions struct {
+	// Aliases map other names of external elements and data stores onto
+	// the name they're merged under.
+	Aliases map[string]string
+
+	// Cluster groups the elements by threat model, the default, or by
+	// trust zone.
+	Cluster Cluster
+}
+
+// FleetMap is every diagram of a fleet of threat models merged into one
+// graph.
+type FleetMap struct {
+	Graph *Graph
+
+	// Models maps each element onto the threat models it appears in.
+	Models map[string][]string
+
+	// Diagrams counts the diagrams merged.
+	Diagrams int
+
+	// Mismatched names the external elements and data stores that go by
+	// the same name but are drawn as different kinds, in element order.
+	Mismatched []string
+}
+
+// fleetNode is an element of the fleet map while it's being merged.
+type fleetNode struct {
+	el     Element
+	model  string
+	zones  []string
+	models []string
+}
+
+// Fleet merges the diagrams of models into one graph. External elements
+// and data stores that share a name and kind, once aliases are applied,
+// become one element, wherever they appear. A name drawn as both kinds
+// stays two elements, each suffixed with its kind, and is listed in
+// Mismatched. Processes belong to their threat model, and are unified
+// across its diagrams only; one whose name is used elsewhere in the fleet
+// is prefixed with its model's name.
+//
+// Clustering by model puts processes, and the elements only one model
+// uses, in a zone named after the model. Clustering by trust zone keeps
+// the zone an element is given in every diagram it appears in. Elements
+// that don't fit either way are left unzoned. Flows drawn in more than one
+// diagram are merged.
+func Fleet(models []FleetModel, opts FleetOptions) *FleetMap {
+	keys := []string{}
+	nodes := map[string]*fleetNode{}
+	// local maps a model's diagram and element name onto its node key
+	local := map[string]string{}
+
+	diagrams := 0
+	for _, m := range models {
+		for gi, g := range m.Graphs {
+			diagrams++
+			for _, el := range g.Elements {
+				key := fmt.Sprintf("shared\x00%s\x00%s", el.Kind, tmutil.FirstNonEmpty(opts.Aliases[el.Name], el.Name))
+				if el.Kind == Process {
+					key = "process\x00" + m.Name + "\x00" + el.Name
+				}
+				n, ok := nodes[key]
+				if !ok {
+					n = &fleetNode{el: el, model: m.Name}
+					if el.Kind != Process {
+						n.el.Name = tmutil.FirstNonEmpty(opts.Aliases[el.Name], el.Name)
+					}
+					nodes[key] = n
+					keys = append(keys, key)
+				}
+				n.zones = appendUnique(n.zones, el.Zone)
+				n.models = appendUnique(n.models, m.Name)
+				if n.el.IaLink == "" {
+					n.el.IaLink = el.IaLink
+				}
+				local[fmt.Sprintf("%s\x00%d\x00%s", m.Name, gi, el.Name)] = key
+			}
+		}
+	}
+
+	used := map[string]int{}
+	kinds := map[string][]Kind{}
+	for _, key := range keys {
+		el := nodes[key].el
+		used[el.Name]++
+		if el.Kind != Process {
+			kinds[el.Name] = append(kinds[el.Name], el.Kind)
+		}
+	}
+
+	fm := &FleetMap{Graph: &Graph{}, Models: map[string][]string{}, Diagrams: diagrams, Mismatched: []string{}}
+	names := map[string]string{}
+	for _, key := range keys {
+		n := nodes[key]
+		el := n.el
+		switch {
+		case el.Kind == Process && used[el.Name] > 1:
+			el.Name = fmt.Sprintf("%s: %s", n.model, el.Name)
+		case el.Kind != Process && len(kinds[el.Name]) > 1:
+			if kinds[el.Name][0] == el.Kind {
+				fm.Mismatched = append(fm.Mismatched, el.Name)
+			}
+			el.Name = fmt.Sprintf("%s (%s)", el.Name, strings.ReplaceAll(string(el.Kind), "_", " "))
+		}
+
+		el.Zone = ""
+		switch {
+		case opts.Cluster == ClusterZone:
+			if len(n.zones) == 1 {
+				el.Zone = n.zones[0]
+			}
+		case len(n.models) == 1:
+			el.Zone = n.models[0]
+		}
+
+		names[key] = el.Name
+		fm.Models[el.Name] = n.models
+		fm.Graph.Elements = append(fm.Graph.Elements, el)
+		if el.Zone != "" {
+			fm.Graph.Zones = appendUnique(fm.Graph.Zones, el.Zone)
+		}
+	}
+
+	seen := map[Flow]bool{}
+	for _, m := range models {
+		for gi, g := range m.Graphs {
+			for _, f := range g.Flows {
+				from, okFrom := local[fmt.Sprintf("%s\x00%d\x00%s", m.Name, gi, f.From)]
+				to, okTo := local[fmt.Sprintf("%s\x00%d\x00%s", m.Name, gi, f.To)]
+				if !okFrom || !okTo {
+					continue
+				}
+				merged := Flow{Name: f.Name, From: names[from], To: names[to], Protocol: f.Protocol}
+				if !seen[merged] {
+					seen[merged] = true
+					fm.Graph.Flows = append(fm.Graph.Flows, merged)
+				}
+			}
+		}
+	}
+	return fm
+}
+
+// Shared returns the elements that appear in more than one threat model,
+// in element order.
+func (fm *FleetMap) Shared() []string {
+	out := []string{}
+	for _, el := range fm.Graph.Elements {
+		if len(fm.Models[el.Name]) > 1 {
+			out = append(out, el.Name)
+		}
+	}
+	return out
+}
+
+// String summarises the merge, naming the elements threat models share.
+func (fm *FleetMap) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Merged %d DFD(s) into %d elements and %d flows\n", fm.Diagrams, len(fm.Graph.Elements), len(fm.Graph.Flows))
+	shared := []string{}
+	for _, name := range fm.Shared() {
+		shared = append(shared, fmt.Sprintf("%s (%s)", name, strings.Join(fm.Models[name], ", ")))
+	}
+	fmt.Fprintf(&b, "Shared elements: %s\n", listOrNone(shared))
+	if len(fm.Mismatched) > 0 {
+		fmt.Fprintf(&b, "Drawn as different kinds, so not merged: %s\n", strings.Join(fm.Mismatched, ", "))
+	}
+	return b.String()
+}
+
+type aliasesHCL struct {
+	Elements []struct {
+		Name    string   `hcl:"name,label"`
+		Aliases []string `hcl:"aliases"`
+	} `hcl:"element,block"`
+}
+
+// ParseAliasFile parses an HCL file of element blocks, each listing the
+// other names an external element or data store goes by:
+//
+//	element "Payments DB" {
+//	  aliases = ["payments-db", "Payments database"]
+//	}
+//
+// It returns a map of each alias onto its element's name.
+func ParseAliasFile(path string) (map[string]string, error) {
+	f, diags := hclparse.NewParser().ParseHCLFile(path)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	var raw aliasesHCL
+	if diags := gohcl.DecodeBody(f.Body, nil, &raw); diags.HasErrors() {
+		return nil, diags
+	}
+
+	aliases := map[string]string{}
+	for _, el := range raw.Elements {
+		for _, a := range el.Aliases {
+			if prev, ok := aliases[a]; ok && prev != el.Name {
+				return nil, fmt.Errorf("alias %q is used by both %q and %q", a, prev, el.Name)
+			}
+			aliases[a] = el.Name
+		}
+	}
+	return aliases, nil
+}
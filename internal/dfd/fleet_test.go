@@ -0,0 +1,174 @@
+package dfd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testFleet() []FleetModel {
+	shop := &Graph{
+		Name: "Level 0",
+		Elements: []Element{
+			{Name: "User", Kind: ExternalElement},
+			{Name: "Web", Kind: Process, Zone: "DMZ"},
+			{Name: "payments-db", Kind: DataStore, Zone: "Internal"},
+		},
+		Zones: []string{"DMZ", "Internal"},
+		Flows: []Flow{
+			{Name: "Browse", From: "User", To: "Web", Protocol: "https"},
+			{Name: "Pay", From: "Web", To: "payments-db"},
+		},
+	}
+	shopAdmin := &Graph{
+		Name: "Admin",
+		Elements: []Element{
+			{Name: "User", Kind: ExternalElement},
+			{Name: "Web", Kind: Process, Zone: "DMZ"},
+		},
+		Flows: []Flow{
+			{Name: "Browse", From: "User", To: "Web", Protocol: "https"},
+		},
+	}
+	billing := &Graph{
+		Name: "Level 0",
+		Elements: []Element{
+			{Name: "Web", Kind: Process, Zone: "DMZ"},
+			{Name: "Payments DB", Kind: DataStore, Zone: "Internal", IaLink: "Card data"},
+			{Name: "Ledger", Kind: DataStore, Zone: "Internal"},
+		},
+		Flows: []Flow{
+			{Name: "Charge", From: "Web", To: "Payments DB"},
+			{Name: "Record", From: "Web", To: "Ledger"},
+		},
+	}
+	return []FleetModel{
+		{Name: "Shop", Graphs: []*Graph{shop, shopAdmin}},
+		{Name: "Billing", Graphs: []*Graph{billing}},
+	}
+}
+
+func TestFleet(t *testing.T) {
+	fm := Fleet(testFleet(), FleetOptions{Aliases: map[string]string{"payments-db": "Payments DB"}})
+
+	names := []string{}
+	for _, el := range fm.Graph.Elements {
+		names = append(names, el.Name+"@"+el.Zone)
+	}
+	if got := strings.Join(names, ", "); got != "User@Shop, Shop: Web@Shop, Payments DB@, Billing: Web@Billing, Ledger@Billing" {
+		t.Errorf("unexpected elements: %s", got)
+	}
+	if db, _ := fm.Graph.Element("Payments DB"); db.IaLink != "Card data" {
+		t.Errorf("expected the merged store to keep its information asset, got %+v", db)
+	}
+	if len(fm.Graph.Flows) != 4 || fm.Graph.Flows[1].To != "Payments DB" || fm.Graph.Flows[2].From != "Billing: Web" {
+		t.Errorf("unexpected flows: %+v", fm.Graph.Flows)
+	}
+	if got := fm.String(); got != "Merged 3 DFD(s) into 5 elements and 4 flows\nShared elements: Payments DB (Shop, Billing)\n" {
+		t.Errorf("unexpected summary: %q", got)
+	}
+
+	fm = Fleet(testFleet(), FleetOptions{Cluster: ClusterZone})
+	zones := map[string]string{}
+	for _, el := range fm.Graph.Elements {
+		zones[el.Name] = el.Zone
+	}
+	if zones["Shop: Web"] != "DMZ" || zones["payments-db"] != "Internal" || zones["User"] != "" {
+		t.Errorf("unexpected zones: %+v", zones)
+	}
+	if strings.Join(fm.Graph.Zones, ",") != "DMZ,Internal" {
+		t.Errorf("unexpected zones: %v", fm.Graph.Zones)
+	}
+	if len(fm.Shared()) != 0 {
+		t.Errorf("expected nothing shared without the alias, got %v", fm.Shared())
+	}
+}
+
+func TestFleetKindMismatch(t *testing.T) {
+	models := testFleet()
+	// Billing draws the ledger as somebody else's system
+	models[1].Graphs[0].Elements[2].Kind = ExternalElement
+	models[0].Graphs[0].Elements = append(models[0].Graphs[0].Elements, Element{Name: "Ledger", Kind: DataStore})
+
+	fm := Fleet(models, FleetOptions{})
+	for _, name := range []string{"Ledger (data store)", "Ledger (external element)"} {
+		if _, ok := fm.Graph.Element(name); !ok {
+			t.Errorf("expected an element called %q, got %+v", name, fm.Graph.Elements)
+		}
+	}
+	if strings.Join(fm.Mismatched, ",") != "Ledger" || len(fm.Shared()) != 0 {
+		t.Errorf("unexpected mismatches %v or shared elements %v", fm.Mismatched, fm.Shared())
+	}
+	if !strings.Contains(fm.String(), "Drawn as different kinds, so not merged: Ledger\n") {
+		t.Errorf("expected the mismatch in the summary, got %q", fm.String())
+	}
+}
+
+func TestParseAliasFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aliases.hcl")
+	if err := os.WriteFile(path, []byte(`
+element "Payments DB" {
+  aliases = ["payments-db", "Payments database"]
+}
+
+element "User" {
+  aliases = ["Customer"]
+}
+`), 0600); err != nil {
+		t.Fatalf("error writing aliases: %s", err)
+	}
+
+	aliases, err := ParseAliasFile(path)
+	if err != nil {
+		t.Fatalf("error parsing aliases: %s", err)
+	}
+	if len(aliases) != 3 || aliases["payments-db"] != "Payments DB" || aliases["Customer"] != "User" {
+		t.Errorf("unexpected aliases: %+v", aliases)
+	}
+
+	if err := os.WriteFile(path, []byte(`
+element "A" {
+  aliases = ["x"]
+}
+
+element "B" {
+  aliases = ["x"]
+}
+`), 0600); err != nil {
+		t.Fatalf("error writing aliases: %s", err)
+	}
+	if _, err := ParseAliasFile(path); err == nil || !strings.Contains(err.Error(), `alias "x" is used by both "A" and "B"`) {
+		t.Errorf("expected a duplicate alias error, got %v", err)
+	}
+}
+
+func TestD2(t *testing.T) {
+	fm := Fleet(testFleet(), FleetOptions{Aliases: map[string]string{"payments-db": "Payments DB"}})
+
+	out := D2(fm.Graph, "Fleet", RenderOptions{})
+	for _, want := range []string{
+		"direction: right\n",
+		`title: "Fleet" {shape: text; near: top-center; style.font-size: 24}`,
+		`el3: "Payments DB" {shape: cylinder}`,
+		"zone1: \"Shop\" {\n  style.stroke: red\n",
+		`  el2: "Shop: Web" {shape: oval}`,
+		`el1 -> zone1.el2: "Browse (https)"`,
+		`zone1.el2 -> el3: "Pay"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+
+	d := Diff(testDiffGraphs())
+	out = D2(d.Graph, "Shop", RenderOptions{Diff: d})
+	for _, want := range []string{
+		`el7: "Audit" {shape: cylinder; style.stroke: "#d62728"; style.font-color: "#d62728"; style.stroke-dash: 3}`,
+		`zone1.el3 -> zone2.el7: "Log" {style.stroke: "#d62728"; style.font-color: "#d62728"; style.stroke-dash: 3}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package dfd
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFocused is returned by Focus for a graph without the element or
+// trust zone to focus on.
+var ErrNotFocused = errors.New("not in the diagram")
+
+// FocusOptions configures Focus. Set one of Element, Zone, or From and To.
+type FocusOptions struct {
+	// Element keeps the elements within Depth flows of it, following flows
+	// either way.
+	Element string
+	Depth   int
+
+	// Zone keeps the elements of a trust zone.
+	Zone string
+
+	// From and To keep the elements on the paths from one element to
+	// another.
+	From string
+	To   string
+}
+
+// Focus returns the part of g that opts selects. Each kept element's flows
+// to and from the elements left out are collapsed into a flow to or from an
+// Elided stub, one stub per direction, so the view still shows where data
+// enters and leaves it.
+func Focus(g *Graph, opts FocusOptions) (*Graph, error) {
+	keep, err := g.focused(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Graph{Name: g.Name}
+	for _, el := range g.Elements {
+		if keep[el.Name] {
+			out.Elements = append(out.Elements, el)
+		}
+	}
+
+	// stubs collects the flows each stub stands for, keyed by its name
+	type stub struct {
+		el    Element
+		flows []Flow
+		zones []string
+	}
+	stubs := map[string]*stub{}
+	order := []string{}
+	for _, f := range g.Flows {
+		from, okFrom := g.Element(f.From)
+		to, okTo := g.Element(f.To)
+		if !okFrom || !okTo {
+			continue
+		}
+
+		var name string
+		var elided Element
+		switch {
+		case keep[f.From] && keep[f.To]:
+			out.Flows = append(out.Flows, f)
+			continue
+		case keep[f.To]:
+			name, elided = "… to "+f.To, from
+		case keep[f.From]:
+			name, elided = "… from "+f.From, to
+		default:
+			continue
+		}
+
+		s, ok := stubs[name]
+		if !ok {
+			s = &stub{el: Element{Name: name, Kind: Elided}}
+			stubs[name] = s
+			order = append(order, name)
+		}
+		s.flows = append(s.flows, f)
+		s.zones = appendUnique(s.zones, elided.Zone)
+	}
+
+	for _, name := range order {
+		s := stubs[name]
+		if len(s.zones) == 1 {
+			s.el.Zone = s.zones[0]
+		}
+		out.Elements = append(out.Elements, s.el)
+
+		f := Flow{Name: s.flows[0].Name, From: s.flows[0].From, To: s.flows[0].To, Protocol: s.flows[0].Protocol}
+		if keep[f.From] {
+			f.To = name
+		} else {
+			f.From = name
+		}
+		if len(s.flows) > 1 {
+			f.Name = fmt.Sprintf("%d flows", len(s.flows))
+			for _, other := range s.flows[1:] {
+				if other.Protocol != f.Protocol {
+					f.Protocol = ""
+				}
+			}
+		}
+		out.Flows = append(out.Flows, f)
+	}
+
+	for _, zone := range g.Zones {
+		if len(out.InZone(zone)) > 0 {
+			out.Zones = append(out.Zones, zone)
+		}
+	}
+	return out, nil
+}
+
+// focused returns the names of the elements opts keeps.
+func (g *Graph) focused(opts FocusOptions) (map[string]bool, error) {
+	keep := map[string]bool{}
+	switch {
+	case opts.Element != "":
+		if _, ok := g.Element(opts.Element); !ok {
+			return nil, g.notFocused(fmt.Sprintf("element %q", opts.Element))
+		}
+		if opts.Depth < 0 {
+			return nil, fmt.Errorf("depth can't be negative")
+		}
+
+		neighbours := map[string][]string{}
+		for _, fis := range g.outgoing() {
+			for _, fi := range fis {
+				f := g.Flows[fi]
+				neighbours[f.From] = append(neighbours[f.From], f.To)
+				neighbours[f.To] = append(neighbours[f.To], f.From)
+			}
+		}
+		keep[opts.Element] = true
+		frontier := []string{opts.Element}
+		for depth := 0; depth < opts.Depth && len(frontier) > 0; depth++ {
+			next := []string{}
+			for _, name := range frontier {
+				for _, n := range neighbours[name] {
+					if !keep[n] {
+						keep[n] = true
+						next = append(next, n)
+					}
+				}
+			}
+			frontier = next
+		}
+
+	case opts.Zone != "":
+		els := g.InZone(opts.Zone)
+		if len(els) == 0 {
+			return nil, g.notFocused(fmt.Sprintf("trust zone %q", opts.Zone))
+		}
+		for _, el := range els {
+			keep[el.Name] = true
+		}
+
+	case opts.From != "" || opts.To != "":
+		for _, name := range []string{opts.From, opts.To} {
+			if _, ok := g.Element(name); !ok {
+				return nil, g.notFocused(fmt.Sprintf("element %q", name))
+			}
+		}
+
+		out := g.outgoing()
+		in := map[string][]int{}
+		for _, fis := range out {
+			for _, fi := range fis {
+				in[g.Flows[fi].To] = append(in[g.Flows[fi].To], fi)
+			}
+		}
+		reached := g.reach(opts.From, out, func(f Flow) string { return f.To })
+		reaches := g.reach(opts.To, in, func(f Flow) string { return f.From })
+		for name := range reached {
+			if reaches[name] {
+				keep[name] = true
+			}
+		}
+		if !keep[opts.From] || !keep[opts.To] {
+			return nil, fmt.Errorf("no path from %q to %q in %s", opts.From, opts.To, g.Name)
+		}
+
+	default:
+		return nil, fmt.Errorf("nothing to focus on")
+	}
+	return keep, nil
+}
+
+// notFocused is the ErrNotFocused error for what, naming g if it has a
+// name.
+func (g *Graph) notFocused(what string) error {
+	if g.Name == "" {
+		return fmt.Errorf("%s is %w", what, ErrNotFocused)
+	}
+	return fmt.Errorf("%s is %w %s", what, ErrNotFocused, g.Name)
+}
+
+// reach returns the elements reachable from name along flows, by index,
+// with next the element at the other end of a flow.
+func (g *Graph) reach(name string, flows map[string][]int, next func(Flow) string) map[string]bool {
+	seen := map[string]bool{name: true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, fi := range flows[cur] {
+			n := next(g.Flows[fi])
+			if !seen[n] {
+				seen[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+	return seen
+}
@@ -0,0 +1,130 @@
+package dfd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+// focusSummary lists a focused graph's elements, with their zones, and its
+// flows.
+func focusSummary(g *Graph) string {
+	parts := []string{}
+	for _, el := range g.Elements {
+		parts = append(parts, fmt.Sprintf("%s[%s]", el.Name, el.Zone))
+	}
+	for _, f := range g.Flows {
+		parts = append(parts, fmt.Sprintf("%s: %s -> %s (%s)", f.Name, f.From, f.To, f.Protocol))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func TestFocus(t *testing.T) {
+	cases := []struct {
+		name  string
+		opts  FocusOptions
+		exp   string
+		zones []string
+	}{
+		{
+			"element",
+			FocusOptions{Element: "Web", Depth: 1},
+			"User[]; Web[DMZ]; API[Internal]; … from API[Internal]; Browse: User -> Web (https); Call: Web -> API (grpc); Reply: API -> Web (); 2 flows: API -> … from API ()",
+			[]string{"DMZ", "Internal"},
+		},
+		{
+			"element alone",
+			FocusOptions{Element: "Audit"},
+			"Audit[Internal]; … to Audit[Internal]; … from Audit[Internal]; Log: … to Audit -> Audit (); Replay: Audit -> … from Audit ()",
+			[]string{"Internal"},
+		},
+		{
+			"zone",
+			FocusOptions{Zone: "DMZ"},
+			"Web[DMZ]; … to Web[]; … from Web[Internal]; 2 flows: … to Web -> Web (); Call: Web -> … from Web (grpc)",
+			[]string{"DMZ", "Internal"},
+		},
+		{
+			"path",
+			FocusOptions{From: "Web", To: "Audit"},
+			"Web[DMZ]; API[Internal]; Audit[Internal]; … to Web[]; … from API[Internal]; … from Audit[Internal]; Call: Web -> API (grpc); Log: API -> Audit (); Reply: API -> Web (); Browse: … to Web -> Web (https); Store: API -> … from API (); Replay: Audit -> … from Audit ()",
+			[]string{"DMZ", "Internal"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := Focus(testAnalyzeGraph(), tc.opts)
+			if err != nil {
+				t.Fatalf("error focusing: %s", err)
+			}
+			if got := focusSummary(g); got != tc.exp {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.exp, got)
+			}
+			if strings.Join(g.Zones, ",") != strings.Join(tc.zones, ",") {
+				t.Errorf("expected zones %v, got %v", tc.zones, g.Zones)
+			}
+		})
+	}
+}
+
+func TestFocusErrors(t *testing.T) {
+	cases := []struct {
+		opts       FocusOptions
+		exp        string
+		notFocused bool
+	}{
+		{FocusOptions{Element: "Nobody"}, `element "Nobody" is not in the diagram Level 0`, true},
+		{FocusOptions{Zone: "Cloud"}, `trust zone "Cloud" is not in the diagram Level 0`, true},
+		{FocusOptions{From: "User", To: "Nobody"}, `element "Nobody" is not in the diagram Level 0`, true},
+		{FocusOptions{From: "Cards", To: "User"}, `no path from "Cards" to "User" in Level 0`, false},
+		{FocusOptions{Element: "Web", Depth: -1}, "depth can't be negative", false},
+		{FocusOptions{}, "nothing to focus on", false},
+	}
+
+	for _, tc := range cases {
+		_, err := Focus(testAnalyzeGraph(), tc.opts)
+		if err == nil || err.Error() != tc.exp {
+			t.Errorf("%+v: expected %q, got %v", tc.opts, tc.exp, err)
+			continue
+		}
+		if errors.Is(err, ErrNotFocused) != tc.notFocused {
+			t.Errorf("%+v: expected errors.Is(ErrNotFocused) to be %t", tc.opts, tc.notFocused)
+		}
+	}
+}
+
+func TestFocusRenders(t *testing.T) {
+	g, err := Focus(testAnalyzeGraph(), FocusOptions{Zone: "DMZ"})
+	if err != nil {
+		t.Fatalf("error focusing: %s", err)
+	}
+
+	drawio, err := Drawio(g, "Shop", spec.DfdRenderOptions{})
+	if err != nil {
+		t.Fatalf("error rendering drawio: %s", err)
+	}
+
+	for name, tc := range map[string]struct {
+		out  string
+		want []string
+	}{
+		"dot":      {Dot(g, "Shop", RenderOptions{}), []string{`el2 [label="…", shape=plaintext];`, `el2 -> el1 [label="2 flows"];`}},
+		"mermaid":  {Mermaid(g, "Shop", RenderOptions{}), []string{`el2["…"]`, "style el2 fill:none,stroke:none"}},
+		"d2":       {D2(g, "Shop", RenderOptions{}), []string{`el2: "…" {shape: text}`}},
+		"plantuml": {PlantUML(g, "Shop", spec.DfdRenderOptions{}), []string{`label "…" as el2`}},
+		"drawio":   {drawio, []string{`value="…" style="text;html=1;align=center;verticalAlign=middle;"`}},
+	} {
+		for _, want := range tc.want {
+			if !strings.Contains(tc.out, want) {
+				t.Errorf("%s: expected %q in:\n%s", name, want, tc.out)
+			}
+		}
+		if strings.Contains(tc.out, "… to Web") {
+			t.Errorf("%s: expected stubs to be labelled …:\n%s", name, tc.out)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Package dfd works with data flow diagrams as graphs: elements placed in
+// trust zones, joined by flows. It renders the formats the spec module
+// doesn't generate itself.
+package dfd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// Kind is the type of a DFD element.
+type Kind string
+
+const (
+	Process         Kind = "process"
+	DataStore       Kind = "data_store"
+	ExternalElement Kind = "external_element"
+
+	// Elided is a stub standing in for the elements a focused view leaves
+	// out (see Focus).
+	Elided Kind = "elided"
+)
+
+// elidedLabel is the label of an Elided stub.
+const elidedLabel = "…"
+
+// Element is a process, data store or external element.
+type Element struct {
+	Name string
+	Kind Kind
+
+	// Zone is the trust zone holding the element, if any.
+	Zone string
+
+	// IaLink is the information asset a data store holds.
+	IaLink string
+}
+
+// Flow is a flow between two elements.
+type Flow struct {
+	Name     string
+	From     string
+	To       string
+	Protocol string
+}
+
+// Graph is a data flow diagram with its elements flattened out of their
+// trust zones.
+type Graph struct {
+	Name     string
+	Elements []Element
+	Zones    []string
+	Flows    []Flow
+}
+
+// FromSpec flattens d into a graph. Elements keep their diagram order,
+// unzoned first, and zones their order of declaration.
+func FromSpec(d *spec.DataFlowDiagram) *Graph {
+	g := &Graph{Name: d.Name}
+
+	collect := func(zone string, ps []*spec.DfdProcess, ds []*spec.DfdData, ees []*spec.DfdExternal) {
+		for _, p := range ps {
+			g.Elements = append(g.Elements, Element{Name: p.Name, Kind: Process, Zone: tmutil.FirstNonEmpty(p.TrustZone, zone)})
+		}
+		for _, s := range ds {
+			g.Elements = append(g.Elements, Element{Name: s.Name, Kind: DataStore, Zone: tmutil.FirstNonEmpty(s.TrustZone, zone), IaLink: s.IaLink})
+		}
+		for _, ee := range ees {
+			g.Elements = append(g.Elements, Element{Name: ee.Name, Kind: ExternalElement, Zone: tmutil.FirstNonEmpty(ee.TrustZone, zone)})
+		}
+	}
+	collect("", d.Processes, d.DataStores, d.ExternalElements)
+	for _, tz := range d.TrustZones {
+		g.Zones = appendUnique(g.Zones, tz.Name)
+		collect(tz.Name, tz.Processes, tz.DataStores, tz.ExternalElements)
+	}
+	for _, el := range g.Elements {
+		if el.Zone != "" {
+			g.Zones = appendUnique(g.Zones, el.Zone)
+		}
+	}
+
+	for _, f := range d.Flows {
+		g.Flows = append(g.Flows, Flow{Name: f.Name, From: f.From, To: f.To, Protocol: f.Protocol})
+	}
+	return g
+}
+
+// Element returns the element called name.
+func (g *Graph) Element(name string) (Element, bool) {
+	for _, el := range g.Elements {
+		if el.Name == name {
+			return el, true
+		}
+	}
+	return Element{}, false
+}
+
+// label is the label drawn for el: its name, or … for a stub.
+func (el Element) label() string {
+	if el.Kind == Elided {
+		return elidedLabel
+	}
+	return el.Name
+}
+
+// InZone returns the elements in zone, or the unzoned elements if zone is
+// empty.
+func (g *Graph) InZone(zone string) []Element {
+	out := []Element{}
+	for _, el := range g.Elements {
+		if el.Zone == zone {
+			out = append(out, el)
+		}
+	}
+	return out
+}
+
+// protocolPalette colours flows by protocol. Protocols are given colours
+// in alphabetical order, so a protocol keeps its colour between renders of
+// the same diagram.
+var protocolPalette = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf",
+}
+
+// unsetProtocolColor is the colour of flows without a protocol.
+const unsetProtocolColor = "#000000"
+
+// protocolColors returns the colour of each protocol used by g's flows.
+func protocolColors(g *Graph) map[string]string {
+	protocols := []string{}
+	for _, f := range g.Flows {
+		if f.Protocol != "" {
+			protocols = appendUnique(protocols, f.Protocol)
+		}
+	}
+	sort.Strings(protocols)
+
+	out := map[string]string{}
+	for i, p := range protocols {
+		out[p] = protocolPalette[i%len(protocolPalette)]
+	}
+	return out
+}
+
+// flowLabel is a flow's label under the protocol style.
+func flowLabel(f Flow, style spec.ProtocolStyle) string {
+	if f.Protocol != "" && (style == spec.ProtocolStyleLabel || style == spec.ProtocolStyleBoth) {
+		return fmt.Sprintf("%s (%s)", f.Name, f.Protocol)
+	}
+	return f.Name
+}
+
+// colored reports whether the protocol style colours flows.
+func colored(style spec.ProtocolStyle) bool {
+	return style == spec.ProtocolStyleColor || style == spec.ProtocolStyleBoth
+}
+
+// title is the diagram title used by the generators.
+func title(tmName string, g *Graph) string {
+	if g.Name == "" {
+		return tmName
+	}
+	return fmt.Sprintf("%s: %s", tmName, g.Name)
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,51 @@
+package dfd
+
+import (
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// HCL writes graphs as data_flow_diagram_v2 blocks, ready to paste into a
+// threatmodel. Zoned elements are nested in their trust_zone block.
+func HCL(graphs []*Graph) []byte {
+	f := hclwrite.NewEmptyFile()
+	for i, g := range graphs {
+		if i > 0 {
+			f.Body().AppendNewline()
+		}
+		f.Body().AppendBlock(diagramBlock(g))
+	}
+	return f.Bytes()
+}
+
+func diagramBlock(g *Graph) *hclwrite.Block {
+	block := hclwrite.NewBlock("data_flow_diagram_v2", []string{g.Name})
+	body := block.Body()
+
+	for _, el := range g.InZone("") {
+		body.AppendNewBlock(string(el.Kind), []string{el.Name})
+	}
+	for _, zone := range g.Zones {
+		if len(body.Blocks()) > 0 {
+			body.AppendNewline()
+		}
+		zoneBody := body.AppendNewBlock("trust_zone", []string{zone}).Body()
+		for _, el := range g.InZone(zone) {
+			elBody := zoneBody.AppendNewBlock(string(el.Kind), []string{el.Name}).Body()
+			if el.IaLink != "" {
+				elBody.SetAttributeValue("information_asset", cty.StringVal(el.IaLink))
+			}
+		}
+	}
+
+	for _, fl := range g.Flows {
+		body.AppendNewline()
+		flowBody := body.AppendNewBlock("flow", []string{fl.Name}).Body()
+		flowBody.SetAttributeValue("from", cty.StringVal(fl.From))
+		flowBody.SetAttributeValue("to", cty.StringVal(fl.To))
+		if fl.Protocol != "" {
+			flowBody.SetAttributeValue("protocol", cty.StringVal(fl.Protocol))
+		}
+	}
+	return block
+}
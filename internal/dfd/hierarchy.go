@@ -0,0 +1,167 @@
+package dfd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Decomposition links a process to the diagram that decomposes it.
+type Decomposition struct {
+	Parent  string `json:"parent"`
+	Process string `json:"process"`
+	Child   string `json:"child"`
+}
+
+// String renders the decomposition as "Level 0: Web -> Level 1: Web".
+func (d Decomposition) String() string {
+	return fmt.Sprintf("%s: %s -> %s", d.Parent, d.Process, d.Child)
+}
+
+// Imbalance is a flow across a decomposed process's boundary that isn't
+// on both sides of the decomposition: a parent flow the child diagram
+// doesn't preserve, or a child flow the parent diagram doesn't have.
+type Imbalance struct {
+	Decomposition
+	Flow string `json:"flow"`
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	// Missing is set for a parent flow missing from the child diagram, and
+	// unset for a child flow missing from the parent.
+	Missing bool `json:"missing"`
+}
+
+// String explains which side of the decomposition lacks the flow.
+func (i Imbalance) String() string {
+	if i.Missing {
+		return fmt.Sprintf("%s: flow %q (%s -> %s) isn't preserved in %q", i.Parent, i.Flow, i.From, i.To, i.Child)
+	}
+	return fmt.Sprintf("%s: flow %q (%s -> %s) has no matching flow to or from %s in %q", i.Child, i.Flow, i.From, i.To, i.Process, i.Parent)
+}
+
+// HierarchyReport is the decomposed processes of a threat model's
+// diagrams, and the flows that leave them unbalanced.
+type HierarchyReport struct {
+	Decompositions []Decomposition `json:"decompositions"`
+	Imbalances     []Imbalance     `json:"imbalances"`
+}
+
+// Decomposes reports whether the diagram called child decomposes process.
+// By convention a child diagram is named after the process, on its own or
+// after a colon, as in "Web" or "Level 1: Web", ignoring case.
+func Decomposes(child, process string) bool {
+	child, process = strings.ToLower(strings.TrimSpace(child)), strings.ToLower(strings.TrimSpace(process))
+	if process == "" {
+		return false
+	}
+	if child == process {
+		return true
+	}
+	i := strings.LastIndex(child, ":")
+	return i >= 0 && strings.TrimSpace(child[i+1:]) == process
+}
+
+// Decompositions finds the processes of each graph that another graph
+// decomposes (see Decomposes), in graph and element order.
+func Decompositions(graphs []*Graph) []Decomposition {
+	out := []Decomposition{}
+	for _, parent := range graphs {
+		for _, el := range parent.Elements {
+			if el.Kind != Process {
+				continue
+			}
+			for _, child := range graphs {
+				if child != parent && Decomposes(child.Name, el.Name) {
+					out = append(out, Decomposition{Parent: parent.Name, Process: el.Name, Child: child.Name})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// CheckHierarchy finds the decomposed processes of graphs and checks that
+// each is balanced. Every flow between a decomposed process and another
+// element of its parent diagram must be preserved in the child diagram: a
+// flow in the same direction between that element and the child's own
+// elements. Likewise, every such flow in the child diagram must have a
+// matching flow in the parent.
+func CheckHierarchy(graphs []*Graph) *HierarchyReport {
+	r := &HierarchyReport{Decompositions: Decompositions(graphs), Imbalances: []Imbalance{}}
+
+	byName := map[string]*Graph{}
+	for _, g := range graphs {
+		if _, ok := byName[g.Name]; !ok {
+			byName[g.Name] = g
+		}
+	}
+
+	for _, d := range r.Decompositions {
+		parent, child := byName[d.Parent], byName[d.Child]
+
+		// outside are the elements of the parent the process exchanges
+		// data with
+		outside := map[string]bool{}
+		for _, el := range parent.Elements {
+			if el.Name != d.Process {
+				outside[el.Name] = true
+			}
+		}
+
+		// in and out are the parent's flows into and out of the process,
+		// keyed by the element at the other end
+		in, out := map[string]bool{}, map[string]bool{}
+		for _, f := range parent.Flows {
+			switch {
+			case f.To == d.Process && outside[f.From]:
+				in[f.From] = true
+			case f.From == d.Process && outside[f.To]:
+				out[f.To] = true
+			}
+		}
+
+		childIn, childOut := map[string]bool{}, map[string]bool{}
+		for _, f := range child.Flows {
+			switch {
+			case outside[f.From] && !outside[f.To]:
+				childIn[f.From] = true
+				if !in[f.From] {
+					r.Imbalances = append(r.Imbalances, Imbalance{Decomposition: d, Flow: f.Name, From: f.From, To: f.To})
+				}
+			case outside[f.To] && !outside[f.From]:
+				childOut[f.To] = true
+				if !out[f.To] {
+					r.Imbalances = append(r.Imbalances, Imbalance{Decomposition: d, Flow: f.Name, From: f.From, To: f.To})
+				}
+			}
+		}
+
+		for _, f := range parent.Flows {
+			switch {
+			case f.To == d.Process && outside[f.From] && !childIn[f.From],
+				f.From == d.Process && outside[f.To] && !childOut[f.To]:
+				r.Imbalances = append(r.Imbalances, Imbalance{Decomposition: d, Flow: f.Name, From: f.From, To: f.To, Missing: true})
+			}
+		}
+	}
+	return r
+}
+
+// String lists the decompositions and imbalances.
+func (r *HierarchyReport) String() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Decomposed processes (%d):\n", len(r.Decompositions))
+	if len(r.Decompositions) == 0 {
+		b.WriteString("  No diagram is named after a process of another\n")
+	}
+	for _, d := range r.Decompositions {
+		fmt.Fprintf(&b, "  %s\n", d)
+	}
+
+	fmt.Fprintf(&b, "\nUnbalanced flows (%d):\n", len(r.Imbalances))
+	for _, i := range r.Imbalances {
+		fmt.Fprintf(&b, "  %s\n", i)
+	}
+	return b.String()
+}
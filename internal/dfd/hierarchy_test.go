@@ -0,0 +1,120 @@
+package dfd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+// testHierarchy returns a level 0 diagram, and a level 1 diagram that
+// decomposes its Web process but drops the reply to the user and adds an
+// admin login the parent doesn't have.
+func testHierarchy() []*Graph {
+	level0 := &Graph{
+		Name: "Level 0",
+		Elements: []Element{
+			{Name: "User", Kind: ExternalElement},
+			{Name: "Admin", Kind: ExternalElement},
+			{Name: "Web", Kind: Process},
+			{Name: "Cards", Kind: DataStore},
+		},
+		Flows: []Flow{
+			{Name: "Browse", From: "User", To: "Web"},
+			{Name: "Reply", From: "Web", To: "User"},
+			{Name: "Store", From: "Web", To: "Cards"},
+		},
+	}
+	level1 := &Graph{
+		Name: "Level 1: web",
+		Elements: []Element{
+			{Name: "User", Kind: ExternalElement},
+			{Name: "Admin", Kind: ExternalElement},
+			{Name: "Router", Kind: Process},
+			{Name: "Checkout", Kind: Process},
+			{Name: "Cards", Kind: DataStore},
+		},
+		Flows: []Flow{
+			{Name: "Request", From: "User", To: "Router"},
+			{Name: "Route", From: "Router", To: "Checkout"},
+			{Name: "Save", From: "Checkout", To: "Cards"},
+			{Name: "Login", From: "Admin", To: "Router"},
+		},
+	}
+	return []*Graph{level0, level1}
+}
+
+func TestDecomposes(t *testing.T) {
+	for child, want := range map[string]bool{
+		"Web":            true,
+		"web":            true,
+		"Level 1: Web":   true,
+		"Level 1:Web ":   true,
+		"Web server":     false,
+		"Level 1 - Web":  false,
+		"Level 1: Webby": false,
+	} {
+		if got := Decomposes(child, "Web"); got != want {
+			t.Errorf("Decomposes(%q, Web) = %v, expected %v", child, got, want)
+		}
+	}
+}
+
+func TestCheckHierarchy(t *testing.T) {
+	r := CheckHierarchy(testHierarchy())
+
+	if len(r.Decompositions) != 1 || r.Decompositions[0].String() != "Level 0: Web -> Level 1: web" {
+		t.Fatalf("unexpected decompositions: %+v", r.Decompositions)
+	}
+
+	got := []string{}
+	for _, i := range r.Imbalances {
+		got = append(got, i.String())
+	}
+	want := []string{
+		`Level 1: web: flow "Login" (Admin -> Router) has no matching flow to or from Web in "Level 0"`,
+		`Level 0: flow "Reply" (Web -> User) isn't preserved in "Level 1: web"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected imbalances:\n%s", strings.Join(got, "\n"))
+	}
+
+	if !strings.Contains(r.String(), "Decomposed processes (1):\n  Level 0: Web -> Level 1: web\n\nUnbalanced flows (2):\n") {
+		t.Errorf("unexpected report:\n%s", r)
+	}
+
+	balanced := CheckHierarchy(testHierarchy()[:1])
+	if len(balanced.Imbalances) != 0 || !strings.Contains(balanced.String(), "No diagram is named after a process of another") {
+		t.Errorf("unexpected report:\n%s", balanced)
+	}
+}
+
+func TestDetailsFromSpecDecomposed(t *testing.T) {
+	graphs := testHierarchy()
+	tm := &spec.Threatmodel{
+		Threats: []*spec.Threat{{Name: "Web defacement"}},
+		DataFlowDiagrams: []*spec.DataFlowDiagram{
+			{Name: "Level 0"},
+			{Name: "Level 1: web"},
+		},
+	}
+
+	link := func(section, name string) string {
+		return section + ":" + name
+	}
+	d := DetailsFromSpec(graphs[0], tm, link)
+	if got := d.Elements["Web"]; got.URL != "data_flow_diagram:Level 1: web" || !strings.HasSuffix(got.Tooltip, "\nDecomposed in: Level 1: web") {
+		t.Errorf("unexpected Web detail: %+v", got)
+	}
+
+	// without a link to the child, the process links to its threats
+	d = DetailsFromSpec(graphs[0], tm, func(section, name string) string {
+		if section == DiagramSection {
+			return ""
+		}
+		return link(section, name)
+	})
+	if got := d.Elements["Web"]; got.URL != "threat:Web defacement" {
+		t.Errorf("unexpected Web detail: %+v", got)
+	}
+}
@@ -0,0 +1,299 @@
+package dfd
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+const testDrawio = `<mxfile host="app.diagrams.net">
+  <diagram name="Checkout">
+    <mxGraphModel>
+      <root>
+        <mxCell id="0" />
+        <mxCell id="1" parent="0" />
+        <mxCell id="user" value="Shopper" style="shape=umlActor;" vertex="1" parent="1" />
+        <mxCell id="dmz" value="DMZ" style="swimlane;container=1;" vertex="1" parent="1" />
+        <mxCell id="web" value="Web &lt;b&gt;app&lt;/b&gt;" style="ellipse;whiteSpace=wrap;" vertex="1" parent="dmz" />
+        <mxCell id="db" value="Orders" style="shape=cylinder3;" vertex="1" parent="dmz" />
+        <object id="queue" label="Queue" threatcl_type="data_store">
+          <mxCell style="shape=hexagon;" vertex="1" parent="1" />
+        </object>
+        <mxCell id="what" value="Decide" style="rhombus;" vertex="1" parent="1" />
+        <mxCell id="note" value="Draft" style="text;html=1;" vertex="1" parent="1" />
+        <mxCell id="e1" value="Browse (https)" edge="1" parent="1" source="user" target="web" />
+        <mxCell id="e2" edge="1" parent="1" source="web" target="db" />
+        <mxCell id="e2l" value="Save (postgres)" style="edgeLabel;" vertex="1" parent="e2" />
+        <mxCell id="e3" value="Route" edge="1" parent="1" source="web" target="what" />
+      </root>
+    </mxGraphModel>
+  </diagram>
+</mxfile>`
+
+func TestParseDrawio(t *testing.T) {
+	graphs, report, err := ParseDrawio([]byte(testDrawio))
+	if err != nil {
+		t.Fatalf("error parsing: %s", err)
+	}
+	if len(graphs) != 1 || graphs[0].Name != "Checkout" {
+		t.Fatalf("unexpected graphs: %+v", graphs)
+	}
+	g := graphs[0]
+
+	exp := []Element{
+		{Name: "Shopper", Kind: ExternalElement},
+		{Name: "Web app", Kind: Process, Zone: "DMZ"},
+		{Name: "Orders", Kind: DataStore, Zone: "DMZ"},
+		{Name: "Queue", Kind: DataStore},
+	}
+	if len(g.Elements) != len(exp) {
+		t.Fatalf("expected %d elements, got %+v", len(exp), g.Elements)
+	}
+	for i := range exp {
+		if g.Elements[i] != exp[i] {
+			t.Errorf("element %d: expected %+v, got %+v", i, exp[i], g.Elements[i])
+		}
+	}
+
+	expFlows := []Flow{
+		{Name: "Browse", From: "Shopper", To: "Web app", Protocol: "https"},
+		{Name: "Save", From: "Web app", To: "Orders", Protocol: "postgres"},
+	}
+	if len(g.Flows) != len(expFlows) {
+		t.Fatalf("expected %d flows, got %+v", len(expFlows), g.Flows)
+	}
+	for i := range expFlows {
+		if g.Flows[i] != expFlows[i] {
+			t.Errorf("flow %d: expected %+v, got %+v", i, expFlows[i], g.Flows[i])
+		}
+	}
+
+	skipped := report.Skipped()
+	if len(skipped) != 2 || skipped[0].Name != "Decide" || skipped[1].Name != "Route" {
+		t.Errorf("unexpected skipped mappings: %+v", skipped)
+	}
+	if !strings.Contains(report.String(), `Checkout: "Decide" (rhombus): doesn't map onto a DFD element`) {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
+
+func TestParseDrawioCompressed(t *testing.T) {
+	model := `<mxGraphModel><root><mxCell id="0"/><mxCell id="1" parent="0"/>` +
+		`<mxCell id="a" value="API" style="ellipse" vertex="1" parent="1"/></root></mxGraphModel>`
+
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	w.Write([]byte(url.PathEscape(model)))
+	w.Close()
+
+	in := `<mxfile><diagram name="Packed">` + base64.StdEncoding.EncodeToString(buf.Bytes()) + `</diagram></mxfile>`
+	graphs, _, err := ParseDrawio([]byte(in))
+	if err != nil {
+		t.Fatalf("error parsing: %s", err)
+	}
+	if len(graphs) != 1 || len(graphs[0].Elements) != 1 || graphs[0].Elements[0].Name != "API" {
+		t.Errorf("unexpected graphs: %+v", graphs)
+	}
+}
+
+func TestParseDrawioRoundTrip(t *testing.T) {
+	g := FromSpec(testDiagram())
+	out, err := Drawio(g, "Shop", spec.DfdRenderOptions{ProtocolStyle: spec.ProtocolStyleBoth})
+	if err != nil {
+		t.Fatalf("error rendering: %s", err)
+	}
+
+	graphs, report, err := ParseDrawio([]byte(out))
+	if err != nil {
+		t.Fatalf("error parsing: %s", err)
+	}
+	back := graphs[0]
+	if len(back.Elements) != len(g.Elements) {
+		t.Fatalf("expected %d elements, got %+v\n%s", len(g.Elements), back.Elements, report)
+	}
+	for _, el := range g.Elements {
+		got, ok := back.Element(el.Name)
+		if !ok || got.Kind != el.Kind || got.Zone != el.Zone {
+			t.Errorf("expected %+v, got %+v", el, got)
+		}
+	}
+	// the dangling flow isn't drawn
+	if len(back.Flows) != 3 || back.Flows[1] != (Flow{Name: "Query", From: "Web", To: "DB", Protocol: "postgres"}) {
+		t.Errorf("unexpected flows: %+v", back.Flows)
+	}
+}
+
+func TestParseDrawioRectangles(t *testing.T) {
+	model := `<mxGraphModel><root><mxCell id="0"/><mxCell id="1" parent="0"/>` +
+		`<mxCell id="a" value="API" style="rounded=1;whiteSpace=wrap;" vertex="1" parent="1"/>` +
+		`<mxCell id="b" value="Box" style="whiteSpace=wrap;html=1;" vertex="1" parent="1"/>` +
+		`<mxCell id="c" value="Partner" style="` + drawioStyles[ExternalElement] + `" vertex="1" parent="1"/>` +
+		`<mxCell id="d" value="Queue" style="rounded=1;threatcl=data_store;" vertex="1" parent="1"/>` +
+		`</root></mxGraphModel>`
+
+	graphs, report, err := ParseDrawio([]byte(model))
+	if err != nil {
+		t.Fatalf("error parsing: %s", err)
+	}
+	exp := []Element{{Name: "Partner", Kind: ExternalElement}, {Name: "Queue", Kind: DataStore}}
+	if g := graphs[0]; len(g.Elements) != 2 || g.Elements[0] != exp[0] || g.Elements[1] != exp[1] {
+		t.Errorf("unexpected elements: %+v", g.Elements)
+	}
+	for _, want := range []string{
+		`"API" (rounded rectangle): could be any kind of element`,
+		`"Box" (rectangle): could be any kind of element`,
+	} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("expected report to contain %q, got:\n%s", want, report)
+		}
+	}
+}
+
+func TestParseDrawioInvalid(t *testing.T) {
+	if _, _, err := ParseDrawio([]byte("<svg></svg>")); err == nil {
+		t.Error("expected an error parsing an svg")
+	}
+}
+
+const testMermaid = `%% checkout
+flowchart LR
+  user[Shopper]:::external_element -->|"Browse (https)"| web(Web app):::process
+  subgraph dmz [DMZ]
+    web
+    db[(Orders)]
+  end
+  web -- Save (postgres) --> db & cache{{Cache}}
+  web <-.-> worker((Worker)); worker ~~~ db
+  queue>Queue]:::data_store
+  worker --> queue
+  class cache store
+  lone --> db
+  classDef data_store fill:#fff
+`
+
+func TestParseMermaid(t *testing.T) {
+	g, report, err := ParseMermaid("Checkout", testMermaid)
+	if err != nil {
+		t.Fatalf("error parsing: %s", err)
+	}
+
+	exp := []Element{
+		{Name: "Shopper", Kind: ExternalElement},
+		{Name: "Web app", Kind: Process, Zone: "DMZ"},
+		{Name: "Orders", Kind: DataStore, Zone: "DMZ"},
+		{Name: "Cache", Kind: DataStore},
+		{Name: "Worker", Kind: Process},
+		{Name: "Queue", Kind: DataStore},
+	}
+	if len(g.Elements) != len(exp) {
+		t.Fatalf("expected %d elements, got %+v", len(exp), g.Elements)
+	}
+	for i := range exp {
+		if g.Elements[i] != exp[i] {
+			t.Errorf("element %d: expected %+v, got %+v", i, exp[i], g.Elements[i])
+		}
+	}
+
+	expFlows := []Flow{
+		{Name: "Browse", From: "Shopper", To: "Web app", Protocol: "https"},
+		{Name: "Save", From: "Web app", To: "Orders", Protocol: "postgres"},
+		{Name: "Save", From: "Web app", To: "Cache", Protocol: "postgres"},
+		{Name: "flow", From: "Web app", To: "Worker"},
+		{Name: "flow", From: "Worker", To: "Web app"},
+		{Name: "flow", From: "Worker", To: "Queue"},
+	}
+	if len(g.Flows) != len(expFlows) {
+		t.Fatalf("expected %d flows, got %+v", len(expFlows), g.Flows)
+	}
+	for i := range expFlows {
+		if g.Flows[i] != expFlows[i] {
+			t.Errorf("flow %d: expected %+v, got %+v", i, expFlows[i], g.Flows[i])
+		}
+	}
+
+	skipped := report.Skipped()
+	if len(skipped) != 2 || skipped[0].Name != "lone" || skipped[0].Source != "no shape" || skipped[1].Source != "link" {
+		t.Errorf("unexpected skipped mappings: %+v", skipped)
+	}
+}
+
+func TestParseMermaidAmbiguous(t *testing.T) {
+	g, report, err := ParseMermaid("d", "graph TD\n  a{Choose} --> b[API]\n  b --> b2[API] & c(Cache)\n  class b,b2 external_element")
+	if err != nil {
+		t.Fatalf("error parsing: %s", err)
+	}
+	if len(g.Elements) != 2 || g.Elements[1].Name != "API (2)" {
+		t.Errorf("unexpected elements: %+v", g.Elements)
+	}
+	out := report.String()
+	for _, want := range []string{
+		`d: "API" (class external_element) -> external_element`,
+		`d: "API" (class external_element) -> external_element, renamed to "API (2)"`,
+		`Skipped:`,
+		`d: "Choose" (rhombus): doesn't map onto a DFD element`,
+		`d: "Cache" (rounded rectangle): could be any kind of element`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected report to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestParseMermaidInvalid(t *testing.T) {
+	if _, _, err := ParseMermaid("d", "sequenceDiagram\n  A->>B: hi"); err == nil {
+		t.Error("expected an error parsing a sequence diagram")
+	}
+	if _, _, err := ParseMermaid("d", "flowchart LR\n  a --> "); err == nil {
+		t.Error("expected an error parsing a link without a target")
+	}
+}
+
+func TestMermaidBlocks(t *testing.T) {
+	md := "# Design\n\n```mermaid\nflowchart LR\n  a --> b\n```\n\ntext\n\n```mermaid\nsequenceDiagram\n```\n"
+	blocks := MermaidBlocks(md)
+	if len(blocks) != 2 || !IsMermaidFlowchart(blocks[0]) || IsMermaidFlowchart(blocks[1]) {
+		t.Errorf("unexpected blocks: %q", blocks)
+	}
+	if blocks := MermaidBlocks("graph TD\n a --> b"); len(blocks) != 1 {
+		t.Errorf("expected the source as the only block, got %q", blocks)
+	}
+}
+
+func TestHCL(t *testing.T) {
+	out := string(HCL([]*Graph{FromSpec(testDiagram())}))
+
+	exp := `data_flow_diagram_v2 "Level 0" {
+  external_element "User" {
+  }
+
+  trust_zone "Internal" {
+    process "Web" {
+    }
+    data_store "DB" {
+      information_asset = "Card data"
+    }
+  }
+
+  trust_zone "Batch" {
+    process "Worker" {
+    }
+  }
+
+  flow "Browse" {
+    from     = "User"
+    to       = "Web"
+    protocol = "https"
+  }
+`
+	if !strings.HasPrefix(out, exp) {
+		t.Errorf("unexpected HCL:\n%s", out)
+	}
+	if !strings.Contains(out, "flow \"Jobs\" {\n    from = \"Web\"\n    to   = \"Worker\"\n  }") {
+		t.Errorf("expected Jobs flow without a protocol, got:\n%s", out)
+	}
+}
@@ -0,0 +1,230 @@
+package dfd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// mermaidNodeShapes wrap a node's label in the flowchart shape of each of
+// Shapes. Without a theme, processes are drawn as circles, data stores as
+// cylinders and external elements as rectangles.
+var mermaidNodeShapes = map[string][2]string{
+	"ellipse":       {"((", "))"},
+	"circle":        {"((", "))"},
+	"rectangle":     {"[", "]"},
+	"rounded":       {"(", ")"},
+	"cylinder":      {"[(", ")]"},
+	"hexagon":       {"{{", "}}"},
+	"diamond":       {"{", "}"},
+	"parallelogram": {"[/", "/]"},
+	"stub":          {"[", "]"},
+}
+
+// mermaidLines are the stroke-dasharray of each of Lines.
+var mermaidLines = map[string]string{
+	"dashed": "5 5",
+	"dotted": "2 2",
+}
+
+// Mermaid renders g as a mermaid flowchart, with trust zones as dashed
+// subgraphs. Highlights and changes are drawn with style and linkStyle
+// statements.
+func Mermaid(g *Graph, tmName string, opts RenderOptions) string {
+	var b strings.Builder
+
+	theme := opts.Theme
+
+	fmt.Fprintf(&b, "---\ntitle: %s\n", mermaidQuote(title(tmName, g)))
+	if theme != nil && (theme.Font != "" || theme.ClusterSpacing > 0) {
+		b.WriteString("config:\n")
+		if theme.Font != "" {
+			fmt.Fprintf(&b, "  fontFamily: %s\n", mermaidQuote(theme.Font))
+		}
+		if theme.ClusterSpacing > 0 {
+			fmt.Fprintf(&b, "  flowchart:\n    nodeSpacing: %d\n    rankSpacing: %d\n", theme.ClusterSpacing, theme.ClusterSpacing)
+		}
+	}
+	fmt.Fprintf(&b, "---\nflowchart %s\n", theme.direction())
+
+	ids := map[string]string{}
+	for i, el := range g.Elements {
+		ids[el.Name] = fmt.Sprintf("el%d", i+1)
+	}
+
+	writeElements := func(els []Element, indent string) {
+		for _, el := range els {
+			label := el.label()
+			if opts.Diff != nil {
+				label = opts.Diff.label(el)
+			}
+			shape := mermaidNodeShapes[theme.element(el.Kind).Shape]
+			fmt.Fprintf(&b, "%s%s%s%s%s\n", indent, ids[el.Name], shape[0], mermaidQuote(label), shape[1])
+		}
+	}
+	writeElements(g.InZone(""), "  ")
+	for i, zone := range g.Zones {
+		fmt.Fprintf(&b, "  subgraph zone%d[%s]\n", i+1, mermaidQuote(zone))
+		writeElements(g.InZone(zone), "    ")
+		b.WriteString("  end\n")
+		zs := theme.zone(zone)
+		style := fmt.Sprintf("fill:%s,stroke:%s", tmutil.FirstNonEmpty(zs.Fill, "none"), zs.Color)
+		if dash := mermaidLines[zs.Line]; dash != "" {
+			style += ",stroke-dasharray:" + dash
+		}
+		fmt.Fprintf(&b, "  style zone%d %s,color:%s\n", i+1, style, zs.FontColor)
+	}
+
+	// the classes name each node's kind for ParseMermaid, as rectangles
+	// could be any kind
+	for _, kind := range []Kind{Process, DataStore, ExternalElement} {
+		members := []string{}
+		for _, el := range g.Elements {
+			if el.Kind == kind {
+				members = append(members, ids[el.Name])
+			}
+		}
+		if len(members) == 0 {
+			continue
+		}
+		if theme != nil {
+			if class := mermaidElementStyle(theme.Elements[kind]); class != "" {
+				fmt.Fprintf(&b, "  classDef %s %s\n", kind, class)
+			}
+		}
+		fmt.Fprintf(&b, "  class %s %s\n", strings.Join(members, ","), kind)
+	}
+
+	styles := []string{}
+	for _, el := range g.Elements {
+		var style string
+		switch {
+		case opts.Highlight != nil:
+			style = mermaidStyle(tmutil.FirstNonEmpty(opts.Highlight.Elements[el.Name], fadedColor), opts.Highlight.Elements[el.Name] != "", false)
+		case opts.Diff != nil && opts.Diff.Elements[el.Name] != "":
+			c := opts.Diff.Elements[el.Name]
+			style = mermaidStyle(changeColors[c], c != Removed, c == Removed)
+		case el.Kind == Elided:
+			style = "fill:none,stroke:none"
+		}
+		if style != "" {
+			styles = append(styles, fmt.Sprintf("  style %s %s\n", ids[el.Name], style))
+		}
+	}
+
+	colors := protocolColors(g)
+	link := 0
+	for i, f := range g.Flows {
+		from, ok := ids[f.From]
+		if !ok {
+			continue
+		}
+		to, ok := ids[f.To]
+		if !ok {
+			continue
+		}
+
+		arrow := "-->"
+		if opts.Diff != nil && opts.Diff.Flows[i] == Removed {
+			arrow = "-.->"
+		}
+		if label := flowLabel(f, opts.ProtocolStyle); label != "" {
+			arrow += "|" + mermaidQuote(label) + "|"
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", from, arrow, to)
+
+		var style string
+		switch {
+		case opts.Highlight != nil:
+			style = mermaidStyle(tmutil.FirstNonEmpty(opts.Highlight.Flows[i], fadedColor), opts.Highlight.Flows[i] != "", false)
+		case opts.Diff != nil:
+			if c := opts.Diff.Flows[i]; c != "" {
+				style = mermaidStyle(changeColors[c], c != Removed, false)
+			}
+		case themed(theme, f, opts.Classifications[i]):
+			es, _ := theme.edge(f.Protocol, opts.Classifications[i])
+			style = mermaidEdgeStyle(es)
+		case colored(opts.ProtocolStyle):
+			style = mermaidStyle(tmutil.FirstNonEmpty(colors[f.Protocol], unsetProtocolColor), false, false)
+		}
+		if style != "" {
+			styles = append(styles, fmt.Sprintf("  linkStyle %d %s\n", link, style))
+		}
+		link++
+	}
+
+	if opts.Highlight == nil && opts.Diff == nil {
+		if entries := theme.legend(g, opts.Classifications); len(entries) > 0 {
+			b.WriteString("  subgraph legend[\"Legend\"]\n")
+			for i, e := range entries {
+				fmt.Fprintf(&b, "    legend%d[%s]\n", i+1, mermaidQuote(e.Label))
+				styles = append(styles, fmt.Sprintf("  style legend%d fill:none,stroke:none,color:%s\n", i+1, e.Color))
+			}
+			b.WriteString("  end\n")
+			styles = append(styles, "  style legend fill:none,stroke:#999999\n")
+		}
+	}
+
+	for _, s := range styles {
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+// mermaidElementStyle is a classDef's style for the elements of a kind, or
+// "" if the theme leaves them as they are.
+func mermaidElementStyle(s ElementStyle) string {
+	style := []string{}
+	if s.Fill != "" {
+		style = append(style, "fill:"+s.Fill)
+	}
+	if s.Color != "" {
+		style = append(style, "stroke:"+s.Color)
+	}
+	if s.FontColor != "" {
+		style = append(style, "color:"+s.FontColor)
+	}
+	if s.Font != "" {
+		style = append(style, "font-family:"+s.Font)
+	}
+	if s.FontSize > 0 {
+		style = append(style, fmt.Sprintf("font-size:%dpx", s.FontSize))
+	}
+	return strings.Join(style, ",")
+}
+
+// mermaidEdgeStyle is a linkStyle's style for a themed flow.
+func mermaidEdgeStyle(s EdgeStyle) string {
+	style := []string{}
+	if s.Color != "" {
+		style = append(style, "stroke:"+s.Color, "color:"+s.Color)
+	}
+	if s.Width > 0 {
+		style = append(style, fmt.Sprintf("stroke-width:%dpx", s.Width))
+	}
+	if dash := mermaidLines[s.Line]; dash != "" {
+		style = append(style, "stroke-dasharray:"+dash)
+	}
+	return strings.Join(style, ",")
+}
+
+// mermaidStyle is a style or linkStyle drawing in color, thicker if
+// emphasised and dashed if ghosted.
+func mermaidStyle(color string, emphasised, ghosted bool) string {
+	style := fmt.Sprintf("stroke:%s,color:%s", color, color)
+	if emphasised {
+		style += ",stroke-width:3px"
+	}
+	if ghosted {
+		style += ",stroke-dasharray:5 5"
+	}
+	return style
+}
+
+// mermaidQuote quotes s as a mermaid label, using entity codes for quotes
+// and line breaks for newlines.
+func mermaidQuote(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	return `"` + strings.ReplaceAll(s, "\n", "<br/>") + `"`
+}
@@ -0,0 +1,407 @@
+package dfd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+var (
+	mermaidHeader   = regexp.MustCompile(`^(flowchart|graph)\b`)
+	mermaidFence    = regexp.MustCompile("(?ms)^\\s*```\\s*mermaid\\s*$(.*?)^\\s*```")
+	mermaidID       = regexp.MustCompile(`^[\p{L}\p{N}_]+`)
+	mermaidClass    = regexp.MustCompile(`^:::([\w-]+)`)
+	mermaidPipe     = regexp.MustCompile(`^\|([^|]*)\|`)
+	mermaidSubgraph = regexp.MustCompile(`^subgraph\s+(.+)$`)
+
+	// mermaidLink is a link, longest forms first
+	mermaidLink = regexp.MustCompile(`^(<?)(-{2,}>|={2,}>|-\.+->|-{2,}[ox]|={2,}[ox]|-{3,}|={3,}|-\.+-|~~~)`)
+
+	// mermaidTextLink is a link with its text in the middle, A -- text --> B
+	mermaidTextLink = regexp.MustCompile(`^(<?)(--|==|-\.)\s+(.+?)\s+(-{2,}>|={2,}>|\.->|-{3,}|={3,}|\.-)`)
+)
+
+// mermaidShapes are the node shapes, longest openings first, and what they
+// import as. Shapes without a kind are ambiguous.
+var mermaidShapes = []struct {
+	open, close, name string
+	kind              Kind
+}{
+	{"(((", ")))", "double circle", Process},
+	{"((", "))", "circle", Process},
+	{"([", "])", "stadium", ""},
+	{"[(", ")]", "cylinder", DataStore},
+	{"[[", "]]", "subroutine", ""},
+	{"[/", "/]", "parallelogram", ""},
+	{"[/", `\]`, "trapezoid", ""},
+	{`[\`, `\]`, "parallelogram", ""},
+	{`[\`, "/]", "trapezoid", ""},
+	{"{{", "}}", "hexagon", ""},
+	{"{", "}", "rhombus", ""},
+	{"(", ")", "rounded rectangle", ""},
+	{"[", "]", "rectangle", ""},
+	{">", "]", "asymmetric", ""},
+}
+
+// mermaidNamedShapes are the shapes of the node@{ shape: name } syntax.
+var mermaidNamedShapes = map[string]Kind{
+	"circle": Process, "circ": Process, "dbl-circ": Process, "double-circle": Process,
+	"cyl": DataStore, "cylinder": DataStore, "database": DataStore, "db": DataStore,
+	"h-cyl": DataStore, "das": DataStore, "lin-cyl": DataStore, "disk": DataStore,
+}
+
+// mermaidRectangles are the shapes drawn for any kind of element.
+var mermaidRectangles = map[string]bool{
+	"rectangle": true, "rounded rectangle": true,
+	"rect": true, "rounded": true, "event": true,
+}
+
+// MermaidBlocks returns the mermaid fenced code blocks in markdown, or src
+// itself if it has none.
+func MermaidBlocks(src string) []string {
+	blocks := []string{}
+	for _, m := range mermaidFence.FindAllStringSubmatch(src, -1) {
+		blocks = append(blocks, m[1])
+	}
+	if len(blocks) == 0 {
+		blocks = append(blocks, src)
+	}
+	return blocks
+}
+
+// IsMermaidFlowchart reports whether src is a mermaid flowchart.
+func IsMermaidFlowchart(src string) bool {
+	for _, stmt := range mermaidStatements(src) {
+		return mermaidHeader.MatchString(stmt)
+	}
+	return false
+}
+
+type mermaidNode struct {
+	id, label, shape string
+	kind             Kind
+	shaped           bool
+	classes          []string
+	zone             string
+}
+
+type mermaidEdge struct {
+	from, to, label string
+}
+
+// ParseMermaid reads a mermaid flowchart as a graph called name. Circles
+// become processes and cylinders data stores, unless a node has a process,
+// data_store or external_element class. Subgraphs become trust zones. Nodes
+// of any other shape, including plain and rounded rectangles, need a class
+// and are otherwise skipped and listed in the report.
+func ParseMermaid(name, src string) (*Graph, Report, error) {
+	stmts := mermaidStatements(src)
+	if len(stmts) == 0 || !mermaidHeader.MatchString(stmts[0]) {
+		return nil, nil, fmt.Errorf("%s isn't a mermaid flowchart", name)
+	}
+
+	p := &mermaidParser{nodes: map[string]*mermaidNode{}, subgraphs: map[string]bool{}}
+	for _, stmt := range stmts[1:] {
+		if err := p.statement(stmt); err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", name, err)
+		}
+	}
+
+	report := Report{}
+	i := newImporter(name, &report)
+	for _, id := range p.order {
+		n := p.nodes[id]
+		if p.subgraphs[id] {
+			continue
+		}
+		label := tmutil.FirstNonEmpty(n.label, n.id)
+
+		kind, source, note := n.kind, n.shape, ""
+		for _, c := range n.classes {
+			if k, ok := parseKind(c); ok {
+				kind, source = k, "class "+c
+			}
+		}
+		switch {
+		case kind != "":
+		case !n.shaped:
+			source, note = "no shape", "has no shape, give it one or a process, data_store or external_element class"
+		case mermaidRectangles[n.shape]:
+			note = "could be any kind of element, give it a process, data_store or external_element class"
+		default:
+			note = "doesn't map onto a DFD element, give it a process, data_store or external_element class"
+		}
+		i.element(id, label, kind, n.zone, source, note)
+	}
+	for _, e := range p.edges {
+		i.flow(e.from, e.to, e.label, "link")
+	}
+	for _, z := range p.zones {
+		i.g.Zones = appendUnique(i.g.Zones, z)
+	}
+	return i.g, report, nil
+}
+
+type mermaidParser struct {
+	nodes     map[string]*mermaidNode
+	order     []string
+	edges     []mermaidEdge
+	stack     []string
+	zones     []string
+	subgraphs map[string]bool
+}
+
+func (p *mermaidParser) statement(stmt string) error {
+	first := strings.Fields(stmt)[0]
+	switch first {
+	case "end":
+		if len(p.stack) > 0 {
+			p.stack = p.stack[:len(p.stack)-1]
+		}
+		return nil
+	case "classDef", "style", "linkStyle", "click", "direction", "accTitle", "accDescr":
+		return nil
+	case "class":
+		fields := strings.Fields(stmt)
+		if len(fields) < 3 {
+			return fmt.Errorf("incomplete class statement %q", stmt)
+		}
+		for _, id := range strings.Split(fields[1], ",") {
+			n := p.node(strings.TrimSpace(id))
+			n.classes = append(n.classes, fields[2])
+		}
+		return nil
+	}
+
+	if m := mermaidSubgraph.FindStringSubmatch(stmt); m != nil {
+		id, title := m[1], m[1]
+		if open := strings.IndexAny(m[1], "["); open > 0 && strings.HasSuffix(m[1], "]") {
+			id = strings.TrimSpace(m[1][:open])
+			title = m[1][open+1 : len(m[1])-1]
+		}
+		title = unquote(title)
+		p.subgraphs[strings.TrimSpace(id)] = true
+		p.stack = append(p.stack, title)
+		p.zones = appendUnique(p.zones, title)
+		return nil
+	}
+
+	return p.chain(stmt)
+}
+
+// node returns the node id, adding it to the innermost subgraph it's first
+// mentioned in.
+func (p *mermaidParser) node(id string) *mermaidNode {
+	n, ok := p.nodes[id]
+	if !ok {
+		n = &mermaidNode{id: id}
+		p.nodes[id] = n
+		p.order = append(p.order, id)
+	}
+	if n.zone == "" && len(p.stack) > 0 {
+		n.zone = p.stack[len(p.stack)-1]
+	}
+	return n
+}
+
+// chain parses groups of nodes joined by links, A & B --> C -->|label| D.
+func (p *mermaidParser) chain(s string) error {
+	prev, s, err := p.nodeGroup(s)
+	if err != nil {
+		return err
+	}
+	for {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return nil
+		}
+		link, rest, ok := mermaidLinkAt(s)
+		if !ok {
+			return fmt.Errorf("can't parse %q", s)
+		}
+		next, rest, err := p.nodeGroup(strings.TrimSpace(rest))
+		if err != nil {
+			return err
+		}
+		// invisible links only position nodes
+		if !link.invisible {
+			for _, from := range prev {
+				for _, to := range next {
+					p.edges = append(p.edges, mermaidEdge{from, to, link.label})
+					if link.both {
+						p.edges = append(p.edges, mermaidEdge{to, from, link.label})
+					}
+				}
+			}
+		}
+		prev, s = next, rest
+	}
+}
+
+// nodeGroup parses nodes joined by &.
+func (p *mermaidParser) nodeGroup(s string) ([]string, string, error) {
+	ids := []string{}
+	for {
+		id, rest, err := p.nodeRef(s)
+		if err != nil {
+			return nil, "", err
+		}
+		ids = append(ids, id)
+		rest = strings.TrimSpace(rest)
+		if !strings.HasPrefix(rest, "&") {
+			return ids, rest, nil
+		}
+		s = strings.TrimSpace(rest[1:])
+	}
+}
+
+// nodeRef parses a node id with its optional shape and class. Only the
+// first shape given to a node counts, as in mermaid.
+func (p *mermaidParser) nodeRef(s string) (string, string, error) {
+	id := mermaidID.FindString(s)
+	if id == "" {
+		return "", "", fmt.Errorf("expected a node at %q", s)
+	}
+	n := p.node(id)
+	rest := s[len(id):]
+
+	if strings.HasPrefix(rest, "@{") {
+		end := strings.Index(rest, "}")
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated shape at %q", s)
+		}
+		shape, label := "", ""
+		for _, prop := range strings.Split(rest[2:end], ",") {
+			k, v, _ := strings.Cut(prop, ":")
+			switch strings.TrimSpace(k) {
+			case "shape":
+				shape = strings.TrimSpace(v)
+			case "label":
+				label = unquote(v)
+			}
+		}
+		if !n.shaped {
+			n.shaped, n.shape, n.kind, n.label = true, shape, mermaidNamedShapes[shape], label
+		}
+		rest = rest[end+1:]
+	} else {
+		for _, sh := range mermaidShapes {
+			if !strings.HasPrefix(rest, sh.open) {
+				continue
+			}
+			label, after, ok := shapeLabel(rest[len(sh.open):], sh.close)
+			if !ok {
+				continue
+			}
+			if !n.shaped {
+				n.shaped, n.shape, n.kind, n.label = true, sh.name, sh.kind, label
+			}
+			rest = after
+			break
+		}
+	}
+
+	if m := mermaidClass.FindStringSubmatch(rest); m != nil {
+		n.classes = append(n.classes, m[1])
+		rest = rest[len(m[0]):]
+	}
+	return id, rest, nil
+}
+
+// shapeLabel reads a node label up to the shape's closing characters. A
+// quoted label may hold them.
+func shapeLabel(s, closing string) (string, string, bool) {
+	if strings.HasPrefix(s, `"`) {
+		end := strings.Index(s[1:], `"`)
+		if end < 0 || !strings.HasPrefix(s[end+2:], closing) {
+			return "", "", false
+		}
+		return unquote(s[:end+2]), s[end+2+len(closing):], true
+	}
+	end := strings.Index(s, closing)
+	if end < 0 {
+		return "", "", false
+	}
+	return unquote(s[:end]), s[end+len(closing):], true
+}
+
+type mermaidLinkInfo struct {
+	label           string
+	both, invisible bool
+}
+
+// mermaidLinkAt parses the link at the start of s, -->, -->|label|,
+// -- label --> and their dotted, thick and two-way forms.
+func mermaidLinkAt(s string) (mermaidLinkInfo, string, bool) {
+	if m := mermaidLink.FindStringSubmatch(s); m != nil {
+		l := mermaidLinkInfo{both: m[1] == "<", invisible: m[2] == "~~~"}
+		rest := strings.TrimLeft(s[len(m[0]):], " \t")
+		if pm := mermaidPipe.FindStringSubmatch(rest); pm != nil {
+			l.label = unquote(pm[1])
+			rest = rest[len(pm[0]):]
+		}
+		return l, rest, true
+	}
+	if m := mermaidTextLink.FindStringSubmatch(s); m != nil {
+		return mermaidLinkInfo{label: unquote(m[3]), both: m[1] == "<"}, s[len(m[0]):], true
+	}
+	return mermaidLinkInfo{}, "", false
+}
+
+// mermaidStatements splits src into statements, by line and by semicolons
+// outside labels, dropping front matter and %% comments.
+func mermaidStatements(src string) []string {
+	out := []string{}
+	lines := strings.Split(strings.TrimLeft(src, "\r\n\t "), "\n")
+	if strings.TrimSpace(lines[0]) == "---" {
+		// Skip the YAML front matter that holds the diagram's title and
+		// config
+		for i := 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "---" {
+				lines = lines[i+1:]
+				break
+			}
+		}
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "%%") {
+			continue
+		}
+		depth, quoted, start := 0, false, 0
+		for i, r := range line {
+			switch {
+			case r == '"':
+				quoted = !quoted
+			case quoted:
+			case strings.ContainsRune("[({", r):
+				depth++
+			case strings.ContainsRune("])}", r) && depth > 0:
+				depth--
+			case r == ';' && depth == 0:
+				out = appendStatement(out, line[start:i])
+				start = i + 1
+			}
+		}
+		out = appendStatement(out, line[start:])
+	}
+	return out
+}
+
+func appendStatement(stmts []string, s string) []string {
+	if s = strings.TrimSpace(s); s != "" {
+		stmts = append(stmts, s)
+	}
+	return stmts
+}
+
+// unquote is a label without its quotes, markdown backticks or HTML.
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	s = strings.Trim(s, "`")
+	return plainText(s)
+}
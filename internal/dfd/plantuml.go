@@ -0,0 +1,97 @@
+package dfd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// plantumlShapes are the PlantUML elements drawn for each kind: processes
+// as ellipses, data stores as databases, external elements as boxes and
+// elided stubs as bare labels.
+var plantumlShapes = map[Kind]string{
+	Process:         "usecase",
+	DataStore:       "database",
+	ExternalElement: "rectangle",
+	Elided:          "label",
+}
+
+// PlantUML renders g as a PlantUML diagram, with trust zones drawn as
+// dashed containers around their elements.
+func PlantUML(g *Graph, tmName string, opts spec.DfdRenderOptions) string {
+	var b strings.Builder
+
+	b.WriteString("@startuml\n")
+	fmt.Fprintf(&b, "title %s\n", plantumlText(title(tmName, g)))
+	b.WriteString("left to right direction\n")
+	b.WriteString("skinparam shadowing false\n\n")
+
+	aliases := map[string]string{}
+	for i, el := range g.Elements {
+		aliases[el.Name] = fmt.Sprintf("el%d", i+1)
+	}
+
+	writeElements := func(els []Element, indent string) {
+		for _, el := range els {
+			fmt.Fprintf(&b, "%s%s \"%s\" as %s\n", indent, plantumlShapes[el.Kind], plantumlText(el.label()), aliases[el.Name])
+		}
+	}
+	writeElements(g.InZone(""), "")
+	for i, zone := range g.Zones {
+		fmt.Fprintf(&b, "rectangle \"%s\" as zone%d #line:red;line.dashed {\n", plantumlText(zone), i+1)
+		writeElements(g.InZone(zone), "  ")
+		b.WriteString("}\n")
+	}
+	b.WriteString("\n")
+
+	colors := protocolColors(g)
+	for _, f := range g.Flows {
+		from, ok := aliases[f.From]
+		if !ok {
+			continue
+		}
+		to, ok := aliases[f.To]
+		if !ok {
+			continue
+		}
+
+		arrow := "-->"
+		if colored(opts.ProtocolStyle) {
+			arrow = fmt.Sprintf("-[%s]->", tmutil.FirstNonEmpty(colors[f.Protocol], unsetProtocolColor))
+		}
+		fmt.Fprintf(&b, "%s %s %s", from, arrow, to)
+		if label := flowLabel(f, opts.ProtocolStyle); label != "" {
+			fmt.Fprintf(&b, " : %s", plantumlText(label))
+		}
+		b.WriteString("\n")
+	}
+
+	if colored(opts.ProtocolStyle) && len(colors) > 0 {
+		b.WriteString("\nlegend right\n  Protocols\n")
+		for _, p := range sortedKeys(colors) {
+			fmt.Fprintf(&b, "  <color:%s>——</color> %s\n", colors[p], plantumlText(p))
+		}
+		b.WriteString("endlegend\n")
+	}
+
+	b.WriteString("@enduml\n")
+	return b.String()
+}
+
+// plantumlText makes s safe inside a quoted PlantUML string or label.
+func plantumlText(s string) string {
+	s = strings.ReplaceAll(s, `"`, "'")
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
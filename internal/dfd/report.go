@@ -0,0 +1,156 @@
+package dfd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// protocolSuffix is a flow label ending in a protocol, "Query (postgres)".
+var protocolSuffix = regexp.MustCompile(`^(.*\S)\s+\(([A-Za-z0-9+._/-]+)\)$`)
+
+// Mapping records what an imported shape or edge became.
+type Mapping struct {
+	Diagram string
+
+	// Source describes the shape in the source diagram, such as "ellipse".
+	Source string
+	Name   string
+
+	// Kind is what the shape was imported as, or empty if it was skipped.
+	Kind Kind
+
+	// Note explains a skipped or renamed shape.
+	Note string
+}
+
+// Report is every mapping made by an import.
+type Report []Mapping
+
+// Skipped returns the mappings of shapes that weren't imported.
+func (r Report) Skipped() Report {
+	out := Report{}
+	for _, m := range r {
+		if m.Kind == "" {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// String renders the report, imported shapes first.
+func (r Report) String() string {
+	if len(r) == 0 {
+		return "Nothing was imported\n"
+	}
+
+	var b strings.Builder
+	b.WriteString("Mapping report:\n")
+	for _, m := range r {
+		if m.Kind == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "  %s: %q (%s) -> %s", m.Diagram, m.Name, m.Source, m.Kind)
+		if m.Note != "" {
+			fmt.Fprintf(&b, ", %s", m.Note)
+		}
+		b.WriteString("\n")
+	}
+	if skipped := r.Skipped(); len(skipped) > 0 {
+		b.WriteString("Skipped:\n")
+		for _, m := range skipped {
+			fmt.Fprintf(&b, "  %s: %q (%s): %s\n", m.Diagram, m.Name, m.Source, m.Note)
+		}
+	}
+	return b.String()
+}
+
+// importer builds a graph, keeping element names unique and recording every
+// mapping.
+type importer struct {
+	g      *Graph
+	report *Report
+	taken  map[string]bool
+
+	// ids maps source ids onto element names
+	ids map[string]string
+}
+
+func newImporter(name string, report *Report) *importer {
+	return &importer{
+		g:      &Graph{Name: name},
+		report: report,
+		taken:  map[string]bool{},
+		ids:    map[string]string{},
+	}
+}
+
+// element adds an element for the source shape id. An empty kind skips the
+// shape, with note as the reason.
+func (i *importer) element(id, name string, kind Kind, zone, source, note string) {
+	m := Mapping{Diagram: i.g.Name, Source: source, Name: name, Kind: kind, Note: note}
+	if kind == "" {
+		*i.report = append(*i.report, m)
+		return
+	}
+
+	unique := name
+	for n := 2; i.taken[unique]; n++ {
+		unique = fmt.Sprintf("%s (%d)", name, n)
+	}
+	if unique != name {
+		m.Note = fmt.Sprintf("renamed to %q as the name is already used", unique)
+	}
+	i.taken[unique] = true
+	i.ids[id] = unique
+
+	i.g.Elements = append(i.g.Elements, Element{Name: unique, Kind: kind, Zone: zone})
+	if zone != "" {
+		i.g.Zones = appendUnique(i.g.Zones, zone)
+	}
+	*i.report = append(*i.report, m)
+}
+
+// flow adds a flow between the source shapes from and to. A trailing
+// "(protocol)" in the label, as 'threatcl dfd' writes, becomes the flow's
+// protocol.
+func (i *importer) flow(from, to, label, source string) {
+	name, protocol := splitProtocol(label)
+	if name == "" {
+		name = "flow"
+	}
+
+	f, okFrom := i.ids[from]
+	t, okTo := i.ids[to]
+	if !okFrom || !okTo {
+		*i.report = append(*i.report, Mapping{
+			Diagram: i.g.Name,
+			Source:  source,
+			Name:    name,
+			Note:    "isn't connected to an imported element at both ends",
+		})
+		return
+	}
+	i.g.Flows = append(i.g.Flows, Flow{Name: name, From: f, To: t, Protocol: protocol})
+}
+
+func splitProtocol(label string) (string, string) {
+	label = strings.TrimSpace(label)
+	if m := protocolSuffix.FindStringSubmatch(label); m != nil {
+		return strings.TrimSpace(m[1]), m[2]
+	}
+	return label, ""
+}
+
+// parseKind reads an explicit element kind.
+func parseKind(s string) (Kind, bool) {
+	switch strings.ToLower(strings.NewReplacer("-", "_", " ", "_").Replace(strings.TrimSpace(s))) {
+	case "process":
+		return Process, true
+	case "data_store", "datastore", "store":
+		return DataStore, true
+	case "external_element", "external", "external_entity":
+		return ExternalElement, true
+	}
+	return "", false
+}
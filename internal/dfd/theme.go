@@ -0,0 +1,329 @@
+package dfd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// Directions a theme can lay diagrams out in: left to right, right to
+// left, top to bottom and bottom to top.
+var Directions = []string{"LR", "RL", "TB", "BT"}
+
+// Shapes an element can be drawn as. Each generator draws them as its
+// nearest shape.
+var Shapes = []string{"ellipse", "circle", "rectangle", "rounded", "cylinder", "hexagon", "diamond", "parallelogram"}
+
+// Lines a zone or flow can be drawn with.
+var Lines = []string{"solid", "dashed", "dotted"}
+
+// kindShapes are the shapes drawn for each kind without a theme. Elided
+// stubs are drawn as bare text, whatever the theme.
+var kindShapes = map[Kind]string{
+	Process:         "ellipse",
+	DataStore:       "cylinder",
+	ExternalElement: "rectangle",
+	Elided:          "stub",
+}
+
+// ZoneStyle styles a trust zone's cluster.
+type ZoneStyle struct {
+	Color     string
+	Fill      string
+	FontColor string
+	Line      string
+}
+
+// ElementStyle styles the elements of a kind.
+type ElementStyle struct {
+	Shape     string
+	Color     string
+	Fill      string
+	Font      string
+	FontSize  int
+	FontColor string
+}
+
+// EdgeStyle styles the flows of a protocol or data classification.
+type EdgeStyle struct {
+	Color string
+	Width int
+	Line  string
+}
+
+// Theme styles rendered diagrams. The zero value draws them as they are
+// without a theme.
+type Theme struct {
+	// Direction is one of Directions, defaulting to LR.
+	Direction string
+
+	// ClusterSpacing is the space, in points, kept around and between
+	// trust zones.
+	ClusterSpacing int
+
+	// Font is the font of all text, defaulting to Helvetica.
+	Font string
+
+	// Legend adds a legend of the themed zones, protocols and
+	// classifications a diagram uses.
+	Legend bool
+
+	// Zones style trust zones by name, "*" styling every zone.
+	Zones map[string]ZoneStyle
+
+	Elements map[Kind]ElementStyle
+
+	// Protocols and Classifications style flows by their protocol and
+	// the classification of the data they carry. A classification's
+	// style wins over a protocol's.
+	Protocols       map[string]EdgeStyle
+	Classifications map[string]EdgeStyle
+}
+
+type themeHCL struct {
+	Direction      string `hcl:"direction,optional"`
+	ClusterSpacing int    `hcl:"cluster_spacing,optional"`
+	Font           string `hcl:"font,optional"`
+	Legend         bool   `hcl:"legend,optional"`
+
+	Zones           []zoneHCL    `hcl:"zone,block"`
+	Elements        []elementHCL `hcl:"element,block"`
+	Protocols       []edgeHCL    `hcl:"protocol,block"`
+	Classifications []edgeHCL    `hcl:"classification,block"`
+}
+
+type zoneHCL struct {
+	Name      string `hcl:"name,label"`
+	Color     string `hcl:"color,optional"`
+	Fill      string `hcl:"fill,optional"`
+	FontColor string `hcl:"font_color,optional"`
+	Line      string `hcl:"line,optional"`
+}
+
+type elementHCL struct {
+	Kind      string `hcl:"kind,label"`
+	Shape     string `hcl:"shape,optional"`
+	Color     string `hcl:"color,optional"`
+	Fill      string `hcl:"fill,optional"`
+	Font      string `hcl:"font,optional"`
+	FontSize  int    `hcl:"font_size,optional"`
+	FontColor string `hcl:"font_color,optional"`
+}
+
+type edgeHCL struct {
+	Name  string `hcl:"name,label"`
+	Color string `hcl:"color,optional"`
+	Width int    `hcl:"width,optional"`
+	Line  string `hcl:"line,optional"`
+}
+
+// ParseThemeFile parses an HCL theme file:
+//
+//	direction       = "TB"
+//	cluster_spacing = 24
+//	font            = "Arial"
+//	legend          = true
+//
+//	zone "DMZ" {
+//	  color = "#ff7f0e"
+//	  fill  = "#fff5eb"
+//	  line  = "solid"
+//	}
+//
+//	element "data_store" {
+//	  shape     = "cylinder"
+//	  fill      = "#e8f0fe"
+//	  font_size = 12
+//	}
+//
+//	protocol "https" {
+//	  color = "#2ca02c"
+//	}
+//
+//	classification "Restricted" {
+//	  color = "#d62728"
+//	  width = 3
+//	}
+func ParseThemeFile(path string) (*Theme, error) {
+	f, diags := hclparse.NewParser().ParseHCLFile(path)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	var raw themeHCL
+	if diags := gohcl.DecodeBody(f.Body, nil, &raw); diags.HasErrors() {
+		return nil, diags
+	}
+
+	t := &Theme{
+		Direction:       strings.ToUpper(raw.Direction),
+		ClusterSpacing:  raw.ClusterSpacing,
+		Font:            raw.Font,
+		Legend:          raw.Legend,
+		Zones:           map[string]ZoneStyle{},
+		Elements:        map[Kind]ElementStyle{},
+		Protocols:       map[string]EdgeStyle{},
+		Classifications: map[string]EdgeStyle{},
+	}
+	if t.Direction != "" && !contains(Directions, t.Direction) {
+		return nil, fmt.Errorf("direction must be one of %s", strings.Join(Directions, ", "))
+	}
+	if t.ClusterSpacing < 0 {
+		return nil, fmt.Errorf("cluster_spacing can't be negative")
+	}
+
+	for _, z := range raw.Zones {
+		if err := checkLine(z.Line); err != nil {
+			return nil, fmt.Errorf("zone %q: %s", z.Name, err)
+		}
+		t.Zones[z.Name] = ZoneStyle{Color: z.Color, Fill: z.Fill, FontColor: z.FontColor, Line: z.Line}
+	}
+	for _, el := range raw.Elements {
+		kind := Kind(el.Kind)
+		if _, ok := kindShapes[kind]; !ok || kind == Elided {
+			return nil, fmt.Errorf("element %q: must be process, data_store or external_element", el.Kind)
+		}
+		if el.Shape != "" && !contains(Shapes, el.Shape) {
+			return nil, fmt.Errorf("element %q: shape must be one of %s", el.Kind, strings.Join(Shapes, ", "))
+		}
+		t.Elements[kind] = ElementStyle{Shape: el.Shape, Color: el.Color, Fill: el.Fill, Font: el.Font, FontSize: el.FontSize, FontColor: el.FontColor}
+	}
+	for _, p := range raw.Protocols {
+		if err := checkLine(p.Line); err != nil {
+			return nil, fmt.Errorf("protocol %q: %s", p.Name, err)
+		}
+		t.Protocols[p.Name] = EdgeStyle{Color: p.Color, Width: p.Width, Line: p.Line}
+	}
+	for _, c := range raw.Classifications {
+		if err := checkLine(c.Line); err != nil {
+			return nil, fmt.Errorf("classification %q: %s", c.Name, err)
+		}
+		t.Classifications[c.Name] = EdgeStyle{Color: c.Color, Width: c.Width, Line: c.Line}
+	}
+	return t, nil
+}
+
+func checkLine(line string) error {
+	if line != "" && !contains(Lines, line) {
+		return fmt.Errorf("line must be one of %s", strings.Join(Lines, ", "))
+	}
+	return nil
+}
+
+// direction is the layout direction, LR unless themed.
+func (t *Theme) direction() string {
+	if t == nil || t.Direction == "" {
+		return "LR"
+	}
+	return t.Direction
+}
+
+// font is the font of all text, Helvetica unless themed.
+func (t *Theme) font() string {
+	if t == nil || t.Font == "" {
+		return "Helvetica"
+	}
+	return t.Font
+}
+
+// zone is zone's style: the "*" style overridden by the zone's own, over
+// red dashed lines.
+func (t *Theme) zone(name string) ZoneStyle {
+	s := ZoneStyle{Color: "red", Line: "dashed"}
+	if t == nil {
+		s.FontColor = s.Color
+		return s
+	}
+	for _, z := range []ZoneStyle{t.Zones["*"], t.Zones[name]} {
+		s.Color = tmutil.FirstNonEmpty(z.Color, s.Color)
+		s.Fill = tmutil.FirstNonEmpty(z.Fill, s.Fill)
+		s.FontColor = tmutil.FirstNonEmpty(z.FontColor, s.FontColor)
+		s.Line = tmutil.FirstNonEmpty(z.Line, s.Line)
+	}
+	s.FontColor = tmutil.FirstNonEmpty(s.FontColor, s.Color)
+	return s
+}
+
+// element is the style of kind, with its shape filled in.
+func (t *Theme) element(kind Kind) ElementStyle {
+	var s ElementStyle
+	if t != nil {
+		s = t.Elements[kind]
+	}
+	s.Shape = tmutil.FirstNonEmpty(s.Shape, kindShapes[kind])
+	return s
+}
+
+// edge is the themed style of a flow with protocol, carrying data of
+// classification, if any.
+func (t *Theme) edge(protocol, classification string) (EdgeStyle, bool) {
+	if t == nil {
+		return EdgeStyle{}, false
+	}
+	if s, ok := t.Classifications[classification]; ok && classification != "" {
+		return s, true
+	}
+	if s, ok := t.Protocols[protocol]; ok && protocol != "" {
+		return s, true
+	}
+	return EdgeStyle{}, false
+}
+
+// legendEntry is a line of a theme's legend, drawn in its colour.
+type legendEntry struct {
+	Label string
+	Color string
+}
+
+// legend lists the themed zones, protocols and classifications g uses,
+// or nothing if the theme has no legend.
+func (t *Theme) legend(g *Graph, classifications map[int]string) []legendEntry {
+	if t == nil || !t.Legend {
+		return nil
+	}
+
+	out := []legendEntry{}
+	for _, zone := range g.Zones {
+		if _, ok := t.Zones[zone]; ok {
+			out = append(out, legendEntry{"Trust zone: " + zone, t.zone(zone).Color})
+		}
+	}
+	protocols, classes := map[string]bool{}, map[string]bool{}
+	for i, f := range g.Flows {
+		protocols[f.Protocol] = true
+		classes[classifications[i]] = true
+	}
+	for _, p := range sortedKeys(t.Protocols) {
+		if protocols[p] {
+			out = append(out, legendEntry{"Protocol: " + p, tmutil.FirstNonEmpty(t.Protocols[p].Color, unsetProtocolColor)})
+		}
+	}
+	names := sortedKeys(t.Classifications)
+	sort.SliceStable(names, func(i, j int) bool { return sensitivity(names[i]) > sensitivity(names[j]) })
+	for _, c := range names {
+		if classes[c] {
+			out = append(out, legendEntry{"Classification: " + c, tmutil.FirstNonEmpty(t.Classifications[c].Color, unsetProtocolColor)})
+		}
+	}
+	return out
+}
+
+// FlowClassifications maps the indices of g's flows onto the most
+// sensitive classification of the data they carry (see Classify).
+func FlowClassifications(g *Graph, assets Assets) map[int]string {
+	classified := map[string]string{}
+	for _, cf := range Classify([]*Graph{g}, assets).Flows {
+		classified[cf.Flow+"\x00"+cf.From+"\x00"+cf.To] = cf.Classification
+	}
+	out := map[int]string{}
+	for i, f := range g.Flows {
+		if c := classified[f.Name+"\x00"+f.From+"\x00"+f.To]; c != "" {
+			out[i] = c
+		}
+	}
+	return out
+}
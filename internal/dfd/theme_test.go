@@ -0,0 +1,146 @@
+package dfd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testTheme = `
+direction       = "tb"
+cluster_spacing = 24
+font            = "Arial"
+legend          = true
+
+zone "*" {
+  line = "solid"
+}
+
+zone "DMZ" {
+  color = "#ff7f0e"
+  fill  = "#fff5eb"
+}
+
+element "data_store" {
+  shape     = "hexagon"
+  fill      = "#e8f0fe"
+  font_size = 12
+}
+
+element "process" {
+  shape = "rounded"
+}
+
+protocol "https" {
+  color = "#2ca02c"
+}
+
+classification "Restricted" {
+  color = "#d62728"
+  width = 3
+  line  = "dotted"
+}
+`
+
+func parseTestTheme(t *testing.T) *Theme {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "theme.hcl")
+	if err := os.WriteFile(path, []byte(testTheme), 0600); err != nil {
+		t.Fatalf("error writing theme: %s", err)
+	}
+	theme, err := ParseThemeFile(path)
+	if err != nil {
+		t.Fatalf("error parsing theme: %s", err)
+	}
+	return theme
+}
+
+func TestParseThemeFile(t *testing.T) {
+	theme := parseTestTheme(t)
+
+	if theme.Direction != "TB" || theme.ClusterSpacing != 24 || theme.Font != "Arial" || !theme.Legend {
+		t.Errorf("unexpected theme: %+v", theme)
+	}
+	if got := theme.zone("DMZ"); got != (ZoneStyle{Color: "#ff7f0e", Fill: "#fff5eb", FontColor: "#ff7f0e", Line: "solid"}) {
+		t.Errorf("unexpected DMZ style: %+v", got)
+	}
+	if got := theme.zone("Internal"); got != (ZoneStyle{Color: "red", FontColor: "red", Line: "solid"}) {
+		t.Errorf("unexpected Internal style: %+v", got)
+	}
+	if got := theme.element(ExternalElement); got.Shape != "rectangle" {
+		t.Errorf("expected unthemed kinds to keep their shape, got %+v", got)
+	}
+	if got, _ := theme.edge("https", "Restricted"); got.Color != "#d62728" {
+		t.Errorf("expected the classification to win, got %+v", got)
+	}
+
+	for _, tc := range []struct {
+		theme string
+		err   string
+	}{
+		{`direction = "up"`, "direction must be one of LR, RL, TB, BT"},
+		{`element "actor" {}`, `element "actor": must be process, data_store or external_element`},
+		{"element \"process\" {\n  shape = \"star\"\n}", `element "process": shape must be one of`},
+		{"protocol \"http\" {\n  line = \"wavy\"\n}", `protocol "http": line must be one of solid, dashed, dotted`},
+	} {
+		path := filepath.Join(t.TempDir(), "theme.hcl")
+		if err := os.WriteFile(path, []byte(tc.theme), 0600); err != nil {
+			t.Fatalf("error writing theme: %s", err)
+		}
+		if _, err := ParseThemeFile(path); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: expected %q, got %v", tc.theme, tc.err, err)
+		}
+	}
+}
+
+func TestThemedRenders(t *testing.T) {
+	g := testAnalyzeGraph()
+	opts := RenderOptions{
+		Theme:           parseTestTheme(t),
+		Classifications: FlowClassifications(g, Assets{Classifications: map[string]string{"Card data": "Restricted"}}),
+	}
+
+	for name, tc := range map[string]struct {
+		out  string
+		want []string
+	}{
+		"dot": {Dot(g, "Shop", opts), []string{
+			"  rankdir=TB;\n  fontname=\"Arial\";\n",
+			`el4 [label="Cards", shape=hexagon, fillcolor="#e8f0fe", style="filled", fontsize=12];`,
+			`el2 [label="Web", shape=box, style="rounded"];`,
+			"    style=\"solid,filled\";\n    fillcolor=\"#fff5eb\";\n    color=\"#ff7f0e\";\n",
+			"    margin=24;\n",
+			`el3 -> el4 [label="Store", color="#d62728", fontcolor="#d62728", penwidth=3, style=dotted];`,
+			`legend1 [shape=plaintext, label="Trust zone: DMZ", fontcolor="#ff7f0e"];`,
+			`label="Classification: Restricted", fontcolor="#d62728"`,
+		}},
+		"mermaid": {Mermaid(g, "Shop", opts), []string{
+			"config:\n  fontFamily: \"Arial\"\n  flowchart:\n    nodeSpacing: 24\n    rankSpacing: 24\n---\nflowchart TB\n",
+			`el4{{"Cards"}}`,
+			`el2("Web")`,
+			"  style zone1 fill:#fff5eb,stroke:#ff7f0e,color:#ff7f0e\n",
+			"  classDef data_store fill:#e8f0fe,font-size:12px\n  class el4,el5 data_store\n",
+			"stroke:#d62728,color:#d62728,stroke-width:3px,stroke-dasharray:2 2\n",
+			"    legend1[\"Trust zone: DMZ\"]\n",
+		}},
+		"d2": {D2(g, "Shop", opts), []string{
+			"direction: down\n",
+			`el4: "Cards" {shape: hexagon; style.fill: "#e8f0fe"; style.font-size: 12}`,
+			`el2: "Web" {shape: rectangle; style.border-radius: 8}`,
+			"  style.stroke: \"#ff7f0e\"\n  style.font-color: \"#ff7f0e\"\n  style.fill: \"#fff5eb\"\n",
+			`{style.stroke: "#d62728"; style.font-color: "#d62728"; style.stroke-width: 3; style.stroke-dash: 1}`,
+			`legend1: "Trust zone: DMZ" {shape: text; style.font-color: "#ff7f0e"}`,
+		}},
+	} {
+		for _, want := range tc.want {
+			if !strings.Contains(tc.out, want) {
+				t.Errorf("%s: expected %q in:\n%s", name, want, tc.out)
+			}
+		}
+	}
+
+	if _, err := Svg(Dot(g, "Shop", opts)); err != nil {
+		t.Errorf("error rendering themed svg: %s", err)
+	}
+}
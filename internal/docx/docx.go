@@ -0,0 +1,233 @@
+// Package docx writes threat models as Word (.docx) reports.
+//
+// A report has a title page, summary tables (threat counts, severity
+// distribution and control coverage), and a section per threat model with
+// its data flow diagrams, information assets and threats. Layout comes from
+// a reference document: its styles, theme, headers, footers and page setup
+// are kept and only its body is replaced, so a report can be restyled by
+// editing one in Word and passing it back as the reference.
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/threatcl/spec"
+)
+
+// Options tune Write.
+type Options struct {
+	// Reference is an optional .docx whose styles and page setup the report
+	// uses. The report refers to the Title, Subtitle, Heading1, Heading2,
+	// Heading3, Caption and TableGrid styles.
+	Reference []byte
+
+	// DfdImage renders a data flow diagram as a PNG. Diagrams are left out
+	// when it's nil.
+	DfdImage func(tmName string, d *spec.DataFlowDiagram) ([]byte, error)
+
+	// Date is shown on the title page when set.
+	Date string
+}
+
+const header = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`
+
+const (
+	documentPart = "word/document.xml"
+	relsPart     = "word/_rels/document.xml.rels"
+	typesPart    = "[Content_Types].xml"
+	corePart     = "docProps/core.xml"
+)
+
+var pngType = regexp.MustCompile(`(?i)Extension="png"`)
+
+// Write writes a report for tms to w.
+func Write(w io.Writer, tms []spec.Threatmodel, opts Options) error {
+	pkg := defaultPackage()
+	if opts.Reference != nil {
+		var err error
+		if pkg, err = readPackage(opts.Reference); err != nil {
+			return err
+		}
+	}
+
+	r := newReport(opts)
+	if err := r.build(tms); err != nil {
+		return err
+	}
+
+	doc := string(pkg.files[documentPart])
+	start := strings.Index(doc, "<w:body>")
+	end := strings.LastIndex(doc, "</w:body>")
+	if start < 0 || end < start {
+		return fmt.Errorf("error reading reference docx: %s has no body", documentPart)
+	}
+	section := lastSection(doc[start:end])
+	pkg.files[documentPart] = []byte(doc[:start] + "<w:body>" + r.body.String() + section + doc[end:])
+
+	rels := string(pkg.files[relsPart])
+	i := strings.LastIndex(rels, "</Relationships>")
+	if i < 0 {
+		return fmt.Errorf("error reading reference docx: %s isn't a relationships part", relsPart)
+	}
+	var added strings.Builder
+	for _, img := range r.images {
+		fmt.Fprintf(&added, `<Relationship Id="%s" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/%s"/>`, img.id, img.name)
+		pkg.add("word/media/"+img.name, img.data)
+	}
+	pkg.files[relsPart] = []byte(rels[:i] + added.String() + rels[i:])
+
+	types := string(pkg.files[typesPart])
+	if !pngType.MatchString(types) {
+		i := strings.LastIndex(types, "</Types>")
+		if i < 0 {
+			return fmt.Errorf("error reading reference docx: %s is invalid", typesPart)
+		}
+		types = types[:i] + `<Default Extension="png" ContentType="image/png"/>` + types[i:]
+		pkg.files[typesPart] = []byte(types)
+	}
+
+	// The reference's document properties describe the reference, not the
+	// report.
+	if _, ok := pkg.files[corePart]; ok {
+		pkg.files[corePart] = coreProperties(r.title)
+	}
+
+	return pkg.write(w)
+}
+
+// pkg is the parts of a .docx, in the order they're written.
+type pkg struct {
+	names []string
+	files map[string][]byte
+}
+
+func (p *pkg) add(name string, data []byte) {
+	if _, ok := p.files[name]; !ok {
+		p.names = append(p.names, name)
+	}
+	p.files[name] = data
+}
+
+func (p *pkg) write(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	for _, name := range p.names {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(p.files[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// lastSection returns the page setup (sectPr) at the end of a document body.
+// A sectPr inside the body's last paragraph belongs to that paragraph's
+// section break, and isn't the document's.
+func lastSection(body string) string {
+	i := strings.LastIndex(body, "<w:sectPr")
+	if i < 0 || strings.Contains(body[i:], "</w:p>") {
+		return ""
+	}
+	return strings.TrimSpace(body[i:])
+}
+
+// readPackage reads every part of a reference .docx.
+func readPackage(data []byte) (*pkg, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("error reading reference docx: %s", err)
+	}
+
+	p := &pkg{files: map[string][]byte{}}
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("error reading reference docx: %s", err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading reference docx: %s", err)
+		}
+		p.add(f.Name, b)
+	}
+
+	for _, name := range []string{typesPart, documentPart, relsPart} {
+		if _, ok := p.files[name]; !ok {
+			return nil, fmt.Errorf("error reading reference docx: missing %s", name)
+		}
+	}
+	return p, nil
+}
+
+// defaultPackage is the reference used when none is given.
+func defaultPackage() *pkg {
+	p := &pkg{files: map[string][]byte{}}
+	p.add(typesPart, []byte(header+`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`+
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`+
+		`<Default Extension="xml" ContentType="application/xml"/>`+
+		`<Default Extension="png" ContentType="image/png"/>`+
+		`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>`+
+		`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>`+
+		`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>`+
+		`</Types>`))
+	p.add("_rels/.rels", []byte(header+`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`+
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>`+
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>`+
+		`</Relationships>`))
+	p.add(documentPart, []byte(header+`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`+
+		`<w:body><w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440" w:header="708" w:footer="708" w:gutter="0"/></w:sectPr></w:body>`+
+		`</w:document>`))
+	p.add(relsPart, []byte(header+`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`+
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`+
+		`</Relationships>`))
+	p.add("word/styles.xml", []byte(defaultStyles))
+	p.add(corePart, coreProperties(""))
+	return p
+}
+
+func coreProperties(title string) []byte {
+	return []byte(header + `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/">` +
+		`<dc:title>` + escape(title) + `</dc:title><dc:creator>threatcl</dc:creator>` +
+		`</cp:coreProperties>`)
+}
+
+const defaultStyles = header +
+	`<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+	`<w:docDefaults>` +
+	`<w:rPrDefault><w:rPr><w:rFonts w:ascii="Calibri" w:hAnsi="Calibri" w:eastAsia="Calibri" w:cs="Calibri"/><w:sz w:val="22"/><w:szCs w:val="22"/><w:lang w:val="en-US"/></w:rPr></w:rPrDefault>` +
+	`<w:pPrDefault><w:pPr><w:spacing w:after="120" w:line="264" w:lineRule="auto"/></w:pPr></w:pPrDefault>` +
+	`</w:docDefaults>` +
+	`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:spacing w:before="2400" w:after="240"/></w:pPr><w:rPr><w:b/><w:color w:val="1F3864"/><w:sz w:val="56"/><w:szCs w:val="56"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Subtitle"><w:name w:val="Subtitle"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:spacing w:after="480"/></w:pPr><w:rPr><w:color w:val="595959"/><w:sz w:val="32"/><w:szCs w:val="32"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:keepNext/><w:spacing w:before="360" w:after="160"/><w:outlineLvl w:val="0"/></w:pPr><w:rPr><w:b/><w:color w:val="1F3864"/><w:sz w:val="36"/><w:szCs w:val="36"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Heading2"><w:name w:val="heading 2"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:keepNext/><w:spacing w:before="280" w:after="120"/><w:outlineLvl w:val="1"/></w:pPr><w:rPr><w:b/><w:color w:val="2F5496"/><w:sz w:val="28"/><w:szCs w:val="28"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Heading3"><w:name w:val="heading 3"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:keepNext/><w:spacing w:before="240" w:after="80"/><w:outlineLvl w:val="2"/></w:pPr><w:rPr><w:b/><w:color w:val="2F5496"/><w:sz w:val="24"/><w:szCs w:val="24"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Caption"><w:name w:val="caption"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>` +
+	`<w:pPr><w:jc w:val="center"/></w:pPr><w:rPr><w:i/><w:color w:val="595959"/><w:sz w:val="18"/><w:szCs w:val="18"/></w:rPr></w:style>` +
+	`<w:style w:type="table" w:default="1" w:styleId="TableNormal"><w:name w:val="Normal Table"/>` +
+	`<w:tblPr><w:tblInd w:w="0" w:type="dxa"/><w:tblCellMar><w:top w:w="0" w:type="dxa"/><w:left w:w="108" w:type="dxa"/><w:bottom w:w="0" w:type="dxa"/><w:right w:w="108" w:type="dxa"/></w:tblCellMar></w:tblPr></w:style>` +
+	`<w:style w:type="table" w:styleId="TableGrid"><w:name w:val="Table Grid"/><w:basedOn w:val="TableNormal"/>` +
+	`<w:pPr><w:spacing w:after="0" w:line="240" w:lineRule="auto"/></w:pPr>` +
+	`<w:tblPr><w:tblBorders><w:top w:val="single" w:sz="4" w:space="0" w:color="A6A6A6"/><w:left w:val="single" w:sz="4" w:space="0" w:color="A6A6A6"/>` +
+	`<w:bottom w:val="single" w:sz="4" w:space="0" w:color="A6A6A6"/><w:right w:val="single" w:sz="4" w:space="0" w:color="A6A6A6"/>` +
+	`<w:insideH w:val="single" w:sz="4" w:space="0" w:color="A6A6A6"/><w:insideV w:val="single" w:sz="4" w:space="0" w:color="A6A6A6"/></w:tblBorders></w:tblPr>` +
+	`<w:tblStylePr w:type="firstRow"><w:rPr><w:b/></w:rPr><w:tcPr><w:shd w:val="clear" w:color="auto" w:fill="D9E2F3"/></w:tcPr></w:tblStylePr>` +
+	`</w:style>` +
+	`</w:styles>`
@@ -0,0 +1,261 @@
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+func reportModel() spec.Threatmodel {
+	return spec.Threatmodel{
+		Name:        "Shop",
+		Description: "An online shop.\n\nTakes <card> payments & ships orders.",
+		Author:      "@alice",
+		Attributes:  &spec.Attribute{InternetFacing: true, InitiativeSize: "Small"},
+		InformationAssets: []*spec.InformationAsset{
+			{Name: "Card data", InformationClassification: "Confidential", Source: "customer", Description: "PANs"},
+		},
+		Threats: []*spec.Threat{
+			{
+				Name:        "SQL injection",
+				Description: "Attacker injects SQL",
+				Stride:      []string{"Tampering"},
+				Risk:        &spec.Risk{Likelihood: "medium", Impact: "very_high"},
+				Controls: []*spec.Control{
+					{Name: "Parameterised queries", Description: "Use bind params", Implemented: true, RiskReduction: 60},
+					{Name: "WAF", Description: "Filter requests"},
+				},
+			},
+			{
+				Name:             "Sniffing",
+				ProposedControls: []*spec.ProposedControl{{Description: "TLS everywhere"}},
+			},
+		},
+		DataFlowDiagrams: []*spec.DataFlowDiagram{{Name: "Level 0"}},
+	}
+}
+
+func testPng(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// readDocx returns the parts of a .docx, checking each XML part is well
+// formed.
+func readDocx(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("error opening docx: %s", err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(b)
+
+		if strings.HasSuffix(f.Name, ".xml") || strings.HasSuffix(f.Name, ".rels") {
+			d := xml.NewDecoder(bytes.NewReader(b))
+			for {
+				_, err := d.Token()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("%s isn't well formed: %s", f.Name, err)
+				}
+			}
+		}
+	}
+	return parts
+}
+
+func TestWrite(t *testing.T) {
+	img := testPng(t, 1200, 300)
+	var rendered []string
+	opts := Options{
+		Date: "1 January 2026",
+		DfdImage: func(tmName string, d *spec.DataFlowDiagram) ([]byte, error) {
+			rendered = append(rendered, tmName+"/"+d.Name)
+			return img, nil
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, []spec.Threatmodel{reportModel()}, opts); err != nil {
+		t.Fatalf("error writing docx: %s", err)
+	}
+	parts := readDocx(t, buf.Bytes())
+
+	if len(rendered) != 1 || rendered[0] != "Shop/Level 0" {
+		t.Errorf("rendered %v, expected Shop/Level 0", rendered)
+	}
+	if parts["word/media/threatcl-dfd1.png"] != string(img) {
+		t.Errorf("the DFD image isn't embedded")
+	}
+	if !strings.Contains(parts[relsPart], `Id="rIdThreatclDfd1"`) {
+		t.Errorf("missing image relationship: %s", parts[relsPart])
+	}
+	if !strings.Contains(parts[corePart], "<dc:title>Shop</dc:title>") {
+		t.Errorf("unexpected core properties: %s", parts[corePart])
+	}
+
+	doc := parts[documentPart]
+	for _, exp := range []string{
+		`<w:pStyle w:val="Title"/></w:pPr><w:r><w:t xml:space="preserve">Shop</w:t>`,
+		"1 January 2026",
+		"Takes &lt;card&gt; payments &amp; ships orders.",
+		"Control coverage",
+		// the image is scaled to 6 inches wide, keeping its aspect
+		`<wp:extent cx="5486400" cy="1371600"/>`,
+		`r:embed="rIdThreatclDfd1"`,
+		"Figure 1: Shop, Level 0",
+		"Confidential",
+		"SQL injection",
+		"Parameterised queries",
+		"60%",
+		"TLS everywhere",
+		"<w:sectPr>",
+	} {
+		if !strings.Contains(doc, exp) {
+			t.Errorf("expected document to contain %q", exp)
+		}
+	}
+	if strings.Contains(doc, "Threat counts") {
+		t.Errorf("a single model report shouldn't have a fleet summary")
+	}
+}
+
+func TestWriteFleet(t *testing.T) {
+	other := reportModel()
+	other.Name = "Warehouse"
+	other.Threats = nil
+
+	var buf bytes.Buffer
+	if err := Write(&buf, []spec.Threatmodel{reportModel(), other}, Options{}); err != nil {
+		t.Fatalf("error writing docx: %s", err)
+	}
+	parts := readDocx(t, buf.Bytes())
+	doc := parts[documentPart]
+
+	for _, exp := range []string{
+		"2 threat models",
+		"Threat counts",
+		"Severity distribution",
+		// Shop: 2 controls, 1 implemented, 1 threat without one
+		row("Shop", "2", "1", "50%", "1"),
+		row("Warehouse", "0", "0", "n/a", "0"),
+		row("Total", "2", "1", "50%", "1"),
+		"No threats are recorded for this threat model.",
+	} {
+		if !strings.Contains(doc, exp) {
+			t.Errorf("expected document to contain %q", exp)
+		}
+	}
+	if strings.Contains(doc, "<w:drawing>") {
+		t.Errorf("diagrams shouldn't be embedded without a renderer")
+	}
+}
+
+func TestWriteReference(t *testing.T) {
+	const styles = `<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><!-- corporate --></w:styles>`
+	const section = `<w:sectPr><w:headerReference w:type="default" r:id="rId9"/><w:pgSz w:w="12240" w:h="15840"/></w:sectPr>`
+
+	ref := map[string]string{
+		typesPart: `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="xml" ContentType="application/xml"/></Types>`,
+		documentPart: `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<w:body><w:p><w:pPr><w:sectPr><w:pgSz w:w="1"/></w:sectPr></w:pPr><w:r><w:t>Sample text</w:t></w:r></w:p>` + section + `</w:body></w:document>`,
+		relsPart: `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId9" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/header" Target="header1.xml"/></Relationships>`,
+		"word/styles.xml":  styles,
+		"word/header1.xml": `<w:hdr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"/>`,
+	}
+	var rbuf bytes.Buffer
+	zw := zip.NewWriter(&rbuf)
+	for name, content := range ref {
+		f, _ := zw.Create(name)
+		f.Write([]byte(content))
+	}
+	zw.Close()
+
+	img := testPng(t, 10, 10)
+	opts := Options{
+		Reference: rbuf.Bytes(),
+		DfdImage: func(string, *spec.DataFlowDiagram) ([]byte, error) {
+			return img, nil
+		},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, []spec.Threatmodel{reportModel()}, opts); err != nil {
+		t.Fatalf("error writing docx: %s", err)
+	}
+	parts := readDocx(t, buf.Bytes())
+
+	if parts["word/styles.xml"] != styles {
+		t.Errorf("the reference's styles weren't kept")
+	}
+	if _, ok := parts["word/header1.xml"]; !ok {
+		t.Errorf("the reference's header wasn't kept")
+	}
+	doc := parts[documentPart]
+	if strings.Contains(doc, "Sample text") {
+		t.Errorf("the reference's body wasn't replaced")
+	}
+	if !strings.HasSuffix(doc, section+"</w:body></w:document>") {
+		t.Errorf("the reference's page setup wasn't kept: %s", doc)
+	}
+	if !strings.Contains(parts[relsPart], `Id="rId9"`) || !strings.Contains(parts[relsPart], `Id="rIdThreatclDfd1"`) {
+		t.Errorf("unexpected relationships: %s", parts[relsPart])
+	}
+	if !strings.Contains(parts[typesPart], `<Default Extension="png" ContentType="image/png"/>`) {
+		t.Errorf("png content type wasn't added: %s", parts[typesPart])
+	}
+}
+
+func TestWriteInvalidReference(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, []spec.Threatmodel{reportModel()}, Options{Reference: []byte("not a docx")})
+	if err == nil || !strings.Contains(err.Error(), "error reading reference docx") {
+		t.Errorf("expected a reference error, got %v", err)
+	}
+}
+
+func TestWriteDfdError(t *testing.T) {
+	opts := Options{
+		DfdImage: func(string, *spec.DataFlowDiagram) ([]byte, error) {
+			return nil, fmt.Errorf("graphviz failed")
+		},
+	}
+	var buf bytes.Buffer
+	err := Write(&buf, []spec.Threatmodel{reportModel()}, opts)
+	if err == nil || !strings.Contains(err.Error(), `error rendering data flow diagram "Level 0": graphviz failed`) {
+		t.Errorf("expected a render error, got %v", err)
+	}
+}
+
+// row is a table row's cells as written by report.row.
+func row(cells ...string) string {
+	var b strings.Builder
+	b.WriteString("<w:tr>")
+	for _, c := range cells {
+		fmt.Fprintf(&b, `<w:tc><w:tcPr><w:tcW w:w="0" w:type="auto"/></w:tcPr><w:p>%s</w:p></w:tc>`, run(c, false))
+	}
+	b.WriteString("</w:tr>")
+	return b.String()
+}
@@ -0,0 +1,455 @@
+package docx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	_ "image/png"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+const (
+	// unrated is the severity of threats without a risk.
+	unrated = "unrated"
+
+	// Images are scaled down to fit 6 by 8 inches, in EMUs (914400 an
+	// inch, 9525 a pixel at 96 DPI).
+	maxImageWidth  = 6 * 914400
+	maxImageHeight = 8 * 914400
+	emuPerPixel    = 9525
+
+	// textWidth is the width tables are laid out in, in twentieths of a
+	// point: an A4 page less one inch margins. Word widens or narrows them
+	// to fit the reference's page.
+	textWidth = 9026
+)
+
+var paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+
+type embedded struct {
+	id   string
+	name string
+	data []byte
+}
+
+// report builds a document body.
+type report struct {
+	opts   Options
+	title  string
+	body   strings.Builder
+	images []embedded
+}
+
+func newReport(opts Options) *report {
+	return &report{opts: opts}
+}
+
+func (r *report) build(tms []spec.Threatmodel) error {
+	fleet := len(tms) != 1
+
+	subtitle := fmt.Sprintf("%d threat models", len(tms))
+	r.title = "Threat model report"
+	if !fleet {
+		r.title, subtitle = tms[0].Name, "Threat model report"
+	}
+	r.para("Title", run(r.title, false))
+	r.para("Subtitle", run(subtitle, false))
+	if !fleet && tms[0].Author != "" {
+		r.para("", run("Author: ", true)+run(tms[0].Author, false))
+	}
+	if r.opts.Date != "" {
+		r.para("", run("Date: ", true)+run(r.opts.Date, false))
+	}
+
+	if fleet {
+		r.pageBreak()
+		r.fleetSummary(tms)
+	}
+
+	for i := range tms {
+		r.pageBreak()
+		if err := r.threatmodel(&tms[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stats are a threat model's summary figures.
+type stats struct {
+	threats     int
+	severities  map[string]int
+	controls    int
+	implemented int
+	// uncovered is the number of threats without an implemented control
+	uncovered int
+}
+
+func statsFor(tm *spec.Threatmodel) stats {
+	s := stats{threats: len(tm.Threats), severities: map[string]int{}}
+	for _, t := range tm.Threats {
+		s.severities[severity(t)]++
+
+		covered := false
+		for _, c := range tmutil.AllControls(t) {
+			s.controls++
+			if c.Implemented {
+				s.implemented++
+				covered = true
+			}
+		}
+		for _, pc := range t.ProposedControls {
+			covered = covered || pc.Implemented
+		}
+		if !covered {
+			s.uncovered++
+		}
+	}
+	return s
+}
+
+func (s stats) coverage() string {
+	if s.controls == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%d%%", s.implemented*100/s.controls)
+}
+
+// severities is the severity distribution's rows, most severe first.
+func severities() []string {
+	out := []string{}
+	for i := len(spec.SeverityLevels) - 1; i >= 0; i-- {
+		out = append(out, spec.SeverityLevels[i])
+	}
+	return append(out, unrated)
+}
+
+func severity(t *spec.Threat) string {
+	if t.Risk == nil || t.Risk.Severity() == "" {
+		return unrated
+	}
+	return t.Risk.Severity()
+}
+
+func (r *report) fleetSummary(tms []spec.Threatmodel) {
+	r.para("Heading1", run("Summary", false))
+
+	counts := [][]string{}
+	dist := [][]string{}
+	coverage := [][]string{}
+	var total stats
+	total.severities = map[string]int{}
+	var assets, dfds int
+
+	for i := range tms {
+		tm := &tms[i]
+		s := statsFor(tm)
+		counts = append(counts, []string{tm.Name, tm.Author, strconv.Itoa(s.threats), strconv.Itoa(len(tm.InformationAssets)), strconv.Itoa(len(tm.DataFlowDiagrams))})
+		row := []string{tm.Name}
+		for _, sev := range severities() {
+			row = append(row, strconv.Itoa(s.severities[sev]))
+			total.severities[sev] += s.severities[sev]
+		}
+		dist = append(dist, row)
+		coverage = append(coverage, []string{tm.Name, strconv.Itoa(s.controls), strconv.Itoa(s.implemented), s.coverage(), strconv.Itoa(s.uncovered)})
+
+		total.threats += s.threats
+		total.controls += s.controls
+		total.implemented += s.implemented
+		total.uncovered += s.uncovered
+		assets += len(tm.InformationAssets)
+		dfds += len(tm.DataFlowDiagrams)
+	}
+
+	counts = append(counts, []string{"Total", "", strconv.Itoa(total.threats), strconv.Itoa(assets), strconv.Itoa(dfds)})
+	row := []string{"Total"}
+	for _, sev := range severities() {
+		row = append(row, strconv.Itoa(total.severities[sev]))
+	}
+	dist = append(dist, row)
+	coverage = append(coverage, []string{"Total", strconv.Itoa(total.controls), strconv.Itoa(total.implemented), total.coverage(), strconv.Itoa(total.uncovered)})
+
+	r.para("Heading2", run("Threat counts", false))
+	r.table([]string{"Threat model", "Author", "Threats", "Information assets", "Data flow diagrams"}, counts)
+	r.para("Heading2", run("Severity distribution", false))
+	r.table(append([]string{"Threat model"}, severities()...), dist)
+	r.para("Heading2", run("Control coverage", false))
+	r.table([]string{"Threat model", "Controls", "Implemented", "Coverage", "Threats without an implemented control"}, coverage)
+}
+
+func (r *report) threatmodel(tm *spec.Threatmodel) error {
+	r.para("Heading1", run(tm.Name, false))
+	r.text(tm.Description)
+
+	details := [][2]string{{"Author", tm.Author}}
+	if tm.Link != "" {
+		details = append(details, [2]string{"Link", tm.Link})
+	}
+	if tm.Attributes != nil {
+		details = append(details,
+			[2]string{"New initiative", yesNo(tm.Attributes.NewInitiative)},
+			[2]string{"Internet facing", yesNo(tm.Attributes.InternetFacing)},
+			[2]string{"Initiative size", tm.Attributes.InitiativeSize},
+		)
+	}
+	for _, a := range tm.AdditionalAttributes {
+		details = append(details, [2]string{a.Name, a.Value})
+	}
+	r.keyValues(details)
+
+	s := statsFor(tm)
+	r.para("Heading2", run("Summary", false))
+	r.keyValues([][2]string{
+		{"Threats", strconv.Itoa(s.threats)},
+		{"Information assets", strconv.Itoa(len(tm.InformationAssets))},
+		{"Data flow diagrams", strconv.Itoa(len(tm.DataFlowDiagrams))},
+		{"Controls", strconv.Itoa(s.controls)},
+		{"Implemented controls", strconv.Itoa(s.implemented)},
+		{"Control coverage", s.coverage()},
+		{"Threats without an implemented control", strconv.Itoa(s.uncovered)},
+	})
+	r.para("Heading3", run("Severity distribution", false))
+	dist := [][]string{}
+	for _, sev := range severities() {
+		dist = append(dist, []string{sev, strconv.Itoa(s.severities[sev])})
+	}
+	r.table([]string{"Severity", "Threats"}, dist)
+
+	if len(tm.DataFlowDiagrams) > 0 && r.opts.DfdImage != nil {
+		r.para("Heading2", run("Data flow diagrams", false))
+		for _, d := range tm.DataFlowDiagrams {
+			if err := r.dfd(tm.Name, d); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(tm.InformationAssets) > 0 {
+		r.para("Heading2", run("Information assets", false))
+		rows := [][]string{}
+		for _, ia := range tm.InformationAssets {
+			rows = append(rows, []string{ia.Name, ia.InformationClassification, ia.Source, strings.TrimSpace(ia.Description)})
+		}
+		r.table([]string{"Name", "Classification", "Source", "Description"}, rows)
+	}
+
+	r.para("Heading2", run("Threats", false))
+	if len(tm.Threats) == 0 {
+		r.para("", run("No threats are recorded for this threat model.", false))
+	}
+	for _, t := range tm.Threats {
+		r.threat(t)
+	}
+	return nil
+}
+
+func (r *report) threat(t *spec.Threat) {
+	r.para("Heading3", run(t.Name, false))
+	r.text(t.Description)
+
+	details := [][2]string{}
+	add := func(k, v string) {
+		if v != "" {
+			details = append(details, [2]string{k, v})
+		}
+	}
+	add("STRIDE", strings.Join(t.Stride, ", "))
+	add("Impacts", strings.Join(t.ImpactType, ", "))
+	add("Information assets", strings.Join(t.InformationAssetRefs, ", "))
+	if t.Risk != nil {
+		add("Likelihood", t.Risk.Likelihood)
+		add("Impact", t.Risk.Impact)
+		add("Severity", severity(t))
+		add("Residual score", strconv.FormatFloat(t.ResidualScore(), 'f', -1, 64))
+		add("Rationale", strings.TrimSpace(t.Risk.Rationale))
+	} else {
+		add("Severity", unrated)
+	}
+	add("Control", strings.TrimSpace(t.Control))
+	r.keyValues(details)
+
+	rows := [][]string{}
+	for _, c := range tmutil.AllControls(t) {
+		rr := ""
+		if c.RiskReduction != 0 {
+			rr = fmt.Sprintf("%d%%", c.RiskReduction)
+		}
+		rows = append(rows, []string{c.Name, yesNo(c.Implemented), rr, strings.TrimSpace(c.Description)})
+	}
+	for _, pc := range t.ProposedControls {
+		rows = append(rows, []string{"Proposed", yesNo(pc.Implemented), "", strings.TrimSpace(pc.Description)})
+	}
+	if len(rows) == 0 {
+		r.para("", run("No controls are recorded for this threat.", false))
+		return
+	}
+	r.table([]string{"Control", "Implemented", "Risk reduction", "Description"}, rows)
+}
+
+// dfd embeds a rendered data flow diagram with a caption.
+func (r *report) dfd(tmName string, d *spec.DataFlowDiagram) error {
+	name := d.Name
+	if name == "" {
+		name = "Data flow diagram"
+	}
+	png, err := r.opts.DfdImage(tmName, d)
+	if err != nil {
+		return fmt.Errorf("error rendering data flow diagram %q: %s", name, err)
+	}
+	if len(png) == 0 {
+		return nil
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(png))
+	if err != nil {
+		return fmt.Errorf("error reading data flow diagram %q: %s", name, err)
+	}
+
+	cx, cy := int64(cfg.Width)*emuPerPixel, int64(cfg.Height)*emuPerPixel
+	if cx > maxImageWidth {
+		cx, cy = maxImageWidth, cy*maxImageWidth/cx
+	}
+	if cy > maxImageHeight {
+		cx, cy = cx*maxImageHeight/cy, maxImageHeight
+	}
+
+	n := len(r.images) + 1
+	img := embedded{
+		id:   fmt.Sprintf("rIdThreatclDfd%d", n),
+		name: fmt.Sprintf("threatcl-dfd%d.png", n),
+		data: png,
+	}
+	r.images = append(r.images, img)
+
+	r.para("Heading3", run(name, false))
+	fmt.Fprintf(&r.body, `<w:p><w:pPr><w:keepNext/><w:jc w:val="center"/></w:pPr><w:r><w:drawing>`+
+		`<wp:inline xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" distT="0" distB="0" distL="0" distR="0">`+
+		`<wp:extent cx="%[1]d" cy="%[2]d"/><wp:docPr id="%[3]d" name="%[4]s"/>`+
+		`<a:graphic xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture">`+
+		`<pic:pic xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture">`+
+		`<pic:nvPicPr><pic:cNvPr id="%[3]d" name="%[5]s"/><pic:cNvPicPr/></pic:nvPicPr>`+
+		`<pic:blipFill><a:blip xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" r:embed="%[6]s"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>`+
+		`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%[1]d" cy="%[2]d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>`+
+		`</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r></w:p>`,
+		cx, cy, n, escape(name), img.name, img.id)
+	r.para("Caption", run(fmt.Sprintf("Figure %d: %s, %s", n, tmName, name), false))
+	return nil
+}
+
+func (r *report) para(style, runs string) {
+	r.body.WriteString("<w:p>")
+	if style != "" {
+		fmt.Fprintf(&r.body, `<w:pPr><w:pStyle w:val="%s"/></w:pPr>`, style)
+	}
+	r.body.WriteString(runs)
+	r.body.WriteString("</w:p>")
+}
+
+// text writes free text, such as a description, as paragraphs split on
+// blank lines.
+func (r *report) text(s string) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return
+	}
+	for _, p := range paragraphBreak.Split(s, -1) {
+		r.para("", run(p, false))
+	}
+}
+
+func (r *report) pageBreak() {
+	r.body.WriteString(`<w:p><w:r><w:br w:type="page"/></w:r></w:p>`)
+}
+
+// table writes a table with a header row, repeated on each page.
+func (r *report) table(header []string, rows [][]string) {
+	r.startTable(len(header), true)
+	r.row(header, true)
+	for _, row := range rows {
+		r.row(row, false)
+	}
+	r.body.WriteString("</w:tbl>")
+}
+
+// keyValues writes a two column table of labelled values.
+func (r *report) keyValues(rows [][2]string) {
+	if len(rows) == 0 {
+		return
+	}
+	r.startTable(2, false)
+	for _, kv := range rows {
+		r.body.WriteString("<w:tr>")
+		r.cell(kv[0], true)
+		r.cell(kv[1], false)
+		r.body.WriteString("</w:tr>")
+	}
+	r.body.WriteString("</w:tbl>")
+}
+
+func (r *report) startTable(cols int, header bool) {
+	firstRow := 0
+	if header {
+		firstRow = 1
+	}
+	fmt.Fprintf(&r.body, `<w:tbl><w:tblPr><w:tblStyle w:val="TableGrid"/><w:tblW w:w="5000" w:type="pct"/>`+
+		`<w:tblLook w:firstRow="%d" w:lastRow="0" w:firstColumn="0" w:lastColumn="0" w:noHBand="1" w:noVBand="1"/></w:tblPr><w:tblGrid>`, firstRow)
+	for i := 0; i < cols; i++ {
+		fmt.Fprintf(&r.body, `<w:gridCol w:w="%d"/>`, textWidth/cols)
+	}
+	r.body.WriteString("</w:tblGrid>")
+}
+
+func (r *report) row(cells []string, header bool) {
+	r.body.WriteString("<w:tr>")
+	if header {
+		r.body.WriteString("<w:trPr><w:tblHeader/></w:trPr>")
+	}
+	for _, c := range cells {
+		r.cell(c, header)
+	}
+	r.body.WriteString("</w:tr>")
+}
+
+func (r *report) cell(s string, bold bool) {
+	r.body.WriteString(`<w:tc><w:tcPr><w:tcW w:w="0" w:type="auto"/></w:tcPr><w:p>`)
+	r.body.WriteString(run(s, bold))
+	r.body.WriteString("</w:p></w:tc>")
+}
+
+// run is a run of text, with line breaks kept.
+func run(s string, bold bool) string {
+	if s == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("<w:r>")
+	if bold {
+		b.WriteString("<w:rPr><w:b/></w:rPr>")
+	}
+	for i, line := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		if i > 0 {
+			b.WriteString("<w:br/>")
+		}
+		fmt.Fprintf(&b, `<w:t xml:space="preserve">%s</w:t>`, escape(line))
+	}
+	b.WriteString("</w:r>")
+	return b.String()
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
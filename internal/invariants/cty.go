@@ -4,6 +4,7 @@ import (
 	"sort"
 
 	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmutil"
 	"github.com/zclconf/go-cty/cty"
 )
 
@@ -100,6 +101,23 @@ var (
 		"flows":             cty.List(flowCty),
 		"trust_zones":       cty.List(trustZoneCty),
 	})
+	classifiedFlowCty = cty.Object(map[string]cty.Type{
+		"diagram":            cty.String,
+		"name":               cty.String,
+		"from":               cty.String,
+		"to":                 cty.String,
+		"from_zone":          cty.String,
+		"to_zone":            cty.String,
+		"protocol":           cty.String,
+		"classification":     cty.String,
+		"information_assets": cty.List(cty.String),
+	})
+	unprotectedAssetCty = cty.Object(map[string]cty.Type{
+		"name":           cty.String,
+		"classification": cty.String,
+		"data_stores":    cty.List(cty.String),
+		"reason":         cty.String,
+	})
 	attributesCty = cty.Object(map[string]cty.Type{
 		"new_initiative":  cty.Bool,
 		"internet_facing": cty.Bool,
@@ -161,18 +179,9 @@ func riskVal(r *spec.Risk) cty.Value {
 	})
 }
 
-// threatControls flattens a threat's inline controls and any controls
-// expanded from control_imports into one slice.
-func threatControls(t *spec.Threat) []*spec.Control {
-	out := make([]*spec.Control, 0, len(t.Controls)+len(t.ExpandedControls))
-	out = append(out, t.Controls...)
-	out = append(out, t.ExpandedControls...)
-	return out
-}
-
 func threatVal(t *spec.Threat) cty.Value {
 	controls := make([]cty.Value, 0)
-	for _, c := range threatControls(t) {
+	for _, c := range tmutil.AllControls(t) {
 		controls = append(controls, controlVal(c))
 	}
 	proposed := make([]cty.Value, 0, len(t.ProposedControls))
@@ -349,7 +358,7 @@ func threatmodelVal(tm *spec.Threatmodel) cty.Value {
 	allControls := make([]cty.Value, 0)
 	for _, t := range tm.Threats {
 		threats = append(threats, threatVal(t))
-		for _, c := range threatControls(t) {
+		for _, c := range tmutil.AllControls(t) {
 			allControls = append(allControls, controlVal(c))
 		}
 	}
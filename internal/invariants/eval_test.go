@@ -197,6 +197,22 @@ func TestEvaluateViolations(t *testing.T) {
 			`invariant "dmz_only" {
   target    = "threatmodel"
   condition = lookup(item.additional_attributes, "network_segment", "") == "dmz"
+}`,
+			nil,
+		},
+		{
+			"unprotected_assets_function",
+			`invariant "assets_protected" {
+  target    = "threatmodel"
+  condition = length(unprotected_assets(item)) == 0
+}`,
+			[]string{"Test Model"},
+		},
+		{
+			"insecure_flows_function",
+			`invariant "no_plaintext_sensitive_flows" {
+  target    = "flow"
+  condition = !contains([for f in insecure_flows(tm) : f.name if f.diagram == dfd.name], item.name)
 }`,
 			nil,
 		},
@@ -517,6 +533,14 @@ func TestEvaluateExpressionErrors(t *testing.T) {
 }`,
 			"produced null",
 		},
+		{
+			"classification_function_needs_tm",
+			`invariant "x" {
+  target    = "threat"
+  condition = length(insecure_flows(item)) == 0
+}`,
+			"expected a threat model",
+		},
 		{
 			"bad_when",
 			`invariant "x" {
@@ -541,6 +565,26 @@ func TestEvaluateExpressionErrors(t *testing.T) {
 	}
 }
 
+func TestEvaluateInsecureFlows(t *testing.T) {
+	models := testModels()
+	models[0].TM.DataFlowDiagrams[0].Flows[0].Protocol = "HTTP"
+
+	report := mustEvalRaw(t, `
+invariant "no_plaintext_sensitive_flows" {
+  target        = "flow"
+  condition     = !contains([for f in insecure_flows(tm) : f.name if f.diagram == dfd.name], item.name)
+  error_message = join(", ", [for f in insecure_flows(tm) : "${f.name} carries ${f.classification} ${join("/", f.information_assets)} from ${f.from_zone == "" ? "outside" : f.from_zone} to ${f.to_zone}" if f.name == item.name])
+}
+`, models)
+
+	if len(report.Violations) != 1 || report.Violations[0].ItemName != "login" {
+		t.Fatalf("expected a violation for login, got %+v", report.Violations)
+	}
+	if exp := "login carries Confidential creds from outside to AWS"; report.Violations[0].Message != exp {
+		t.Errorf("expected message %q, got %q", exp, report.Violations[0].Message)
+	}
+}
+
 func TestEvaluateEmptyModel(t *testing.T) {
 	models := []*Model{{TM: &spec.Threatmodel{Name: "Empty", Author: "@x"}, File: "empty.hcl"}}
 
@@ -1,7 +1,10 @@
 package invariants
 
 import (
+	"fmt"
+
 	"github.com/hashicorp/hcl/v2/ext/tryfunc"
+	"github.com/threatcl/threatcl/internal/dfd"
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/function"
 	"github.com/zclconf/go-cty/cty/function/stdlib"
@@ -59,43 +62,166 @@ var lengthFunc = function.New(&function.Spec{
 	},
 })
 
+// insecureFlowsFunc and unprotectedAssetsFunc run the DFD data
+// classification analysis (see 'threatcl dfd classify') over a `tm` value:
+// insecure_flows(tm) lists the flows carrying Restricted or Confidential
+// data across a trust boundary in plaintext, and unprotected_assets(tm) the
+// data-store assets no implemented control protects.
+var insecureFlowsFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "tm", Type: cty.DynamicPseudoType},
+	},
+	Type: function.StaticReturnType(cty.List(classifiedFlowCty)),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		r, err := classifyVal(args[0])
+		if err != nil {
+			return cty.NilVal, err
+		}
+		flows := make([]cty.Value, 0, len(r.InsecureFlows))
+		for _, f := range r.InsecureFlows {
+			flows = append(flows, cty.ObjectVal(map[string]cty.Value{
+				"diagram":            cty.StringVal(f.Diagram),
+				"name":               cty.StringVal(f.Flow),
+				"from":               cty.StringVal(f.From),
+				"to":                 cty.StringVal(f.To),
+				"from_zone":          cty.StringVal(f.FromZone),
+				"to_zone":            cty.StringVal(f.ToZone),
+				"protocol":           cty.StringVal(f.Protocol),
+				"classification":     cty.StringVal(f.Classification),
+				"information_assets": stringListVal(f.Assets),
+			}))
+		}
+		return listVal(flows, classifiedFlowCty), nil
+	},
+})
+
+var unprotectedAssetsFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "tm", Type: cty.DynamicPseudoType},
+	},
+	Type: function.StaticReturnType(cty.List(unprotectedAssetCty)),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		r, err := classifyVal(args[0])
+		if err != nil {
+			return cty.NilVal, err
+		}
+		assets := make([]cty.Value, 0, len(r.UnprotectedAssets))
+		for _, a := range r.UnprotectedAssets {
+			assets = append(assets, cty.ObjectVal(map[string]cty.Value{
+				"name":           cty.StringVal(a.Name),
+				"classification": cty.StringVal(a.Classification),
+				"data_stores":    stringListVal(a.DataStores),
+				"reason":         cty.StringVal(a.Reason),
+			}))
+		}
+		return listVal(assets, unprotectedAssetCty), nil
+	},
+})
+
+// classifyVal rebuilds the diagrams and assets of a `tm` value and
+// classifies them.
+func classifyVal(tm cty.Value) (*dfd.DataReport, error) {
+	ty := tm.Type()
+	for _, attr := range []string{"data_flow_diagrams", "information_assets", "threats"} {
+		if !ty.IsObjectType() || !ty.HasAttribute(attr) {
+			return nil, fmt.Errorf("expected a threat model, such as tm")
+		}
+	}
+	if !tm.IsWhollyKnown() || tm.IsNull() {
+		return nil, fmt.Errorf("expected a known threat model")
+	}
+
+	assets := dfd.Assets{Classifications: map[string]string{}, Threats: map[string]int{}, Controls: map[string]int{}}
+	for _, ia := range tm.GetAttr("information_assets").AsValueSlice() {
+		assets.Classifications[ia.GetAttr("name").AsString()] = ia.GetAttr("information_classification").AsString()
+	}
+	for _, t := range tm.GetAttr("threats").AsValueSlice() {
+		implemented := 0
+		for _, c := range t.GetAttr("controls").AsValueSlice() {
+			if c.GetAttr("implemented").True() {
+				implemented++
+			}
+		}
+		for _, ref := range t.GetAttr("information_asset_refs").AsValueSlice() {
+			assets.Threats[ref.AsString()]++
+			assets.Controls[ref.AsString()] += implemented
+		}
+	}
+
+	graphs := []*dfd.Graph{}
+	for _, d := range tm.GetAttr("data_flow_diagrams").AsValueSlice() {
+		g := &dfd.Graph{Name: d.GetAttr("name").AsString()}
+		for _, z := range d.GetAttr("trust_zones").AsValueSlice() {
+			g.Zones = append(g.Zones, z.GetAttr("name").AsString())
+		}
+		for _, kind := range []struct {
+			attr string
+			kind dfd.Kind
+		}{
+			{"processes", dfd.Process},
+			{"data_stores", dfd.DataStore},
+			{"external_elements", dfd.ExternalElement},
+		} {
+			for _, el := range d.GetAttr(kind.attr).AsValueSlice() {
+				e := dfd.Element{Name: el.GetAttr("name").AsString(), Kind: kind.kind, Zone: el.GetAttr("trust_zone").AsString()}
+				if kind.kind == dfd.DataStore {
+					e.IaLink = el.GetAttr("information_asset").AsString()
+				}
+				g.Elements = append(g.Elements, e)
+			}
+		}
+		for _, f := range d.GetAttr("flows").AsValueSlice() {
+			g.Flows = append(g.Flows, dfd.Flow{
+				Name:     f.GetAttr("name").AsString(),
+				From:     f.GetAttr("from").AsString(),
+				To:       f.GetAttr("to").AsString(),
+				Protocol: f.GetAttr("protocol").AsString(),
+			})
+		}
+		graphs = append(graphs, g)
+	}
+	return dfd.Classify(graphs, assets), nil
+}
+
 // invariantFunctions is the function set available to when/condition/
 // error_message expressions.
 func invariantFunctions() map[string]function.Function {
 	return map[string]function.Function{
-		"alltrue":    allTrueFunc,
-		"anytrue":    anyTrueFunc,
-		"can":        tryfunc.CanFunc,
-		"try":        tryfunc.TryFunc,
-		"coalesce":   stdlib.CoalesceFunc,
-		"compact":    stdlib.CompactFunc,
-		"concat":     stdlib.ConcatFunc,
-		"contains":   stdlib.ContainsFunc,
-		"distinct":   stdlib.DistinctFunc,
-		"element":    stdlib.ElementFunc,
-		"flatten":    stdlib.FlattenFunc,
-		"format":     stdlib.FormatFunc,
-		"join":       stdlib.JoinFunc,
-		"keys":       stdlib.KeysFunc,
-		"length":     lengthFunc,
-		"lookup":     stdlib.LookupFunc,
-		"lower":      stdlib.LowerFunc,
-		"max":        stdlib.MaxFunc,
-		"merge":      stdlib.MergeFunc,
-		"min":        stdlib.MinFunc,
-		"regex":      stdlib.RegexFunc,
-		"regexall":   stdlib.RegexAllFunc,
-		"replace":    stdlib.ReplaceFunc,
-		"reverse":    stdlib.ReverseListFunc,
-		"sort":       stdlib.SortFunc,
-		"split":      stdlib.SplitFunc,
-		"substr":     stdlib.SubstrFunc,
-		"trim":       stdlib.TrimFunc,
-		"trimprefix": stdlib.TrimPrefixFunc,
-		"trimspace":  stdlib.TrimSpaceFunc,
-		"trimsuffix": stdlib.TrimSuffixFunc,
-		"upper":      stdlib.UpperFunc,
-		"values":     stdlib.ValuesFunc,
-		"zipmap":     stdlib.ZipmapFunc,
+		"alltrue":            allTrueFunc,
+		"anytrue":            anyTrueFunc,
+		"can":                tryfunc.CanFunc,
+		"try":                tryfunc.TryFunc,
+		"coalesce":           stdlib.CoalesceFunc,
+		"compact":            stdlib.CompactFunc,
+		"concat":             stdlib.ConcatFunc,
+		"contains":           stdlib.ContainsFunc,
+		"distinct":           stdlib.DistinctFunc,
+		"element":            stdlib.ElementFunc,
+		"flatten":            stdlib.FlattenFunc,
+		"format":             stdlib.FormatFunc,
+		"insecure_flows":     insecureFlowsFunc,
+		"join":               stdlib.JoinFunc,
+		"keys":               stdlib.KeysFunc,
+		"length":             lengthFunc,
+		"lookup":             stdlib.LookupFunc,
+		"lower":              stdlib.LowerFunc,
+		"max":                stdlib.MaxFunc,
+		"merge":              stdlib.MergeFunc,
+		"min":                stdlib.MinFunc,
+		"regex":              stdlib.RegexFunc,
+		"regexall":           stdlib.RegexAllFunc,
+		"replace":            stdlib.ReplaceFunc,
+		"reverse":            stdlib.ReverseListFunc,
+		"sort":               stdlib.SortFunc,
+		"split":              stdlib.SplitFunc,
+		"substr":             stdlib.SubstrFunc,
+		"trim":               stdlib.TrimFunc,
+		"trimprefix":         stdlib.TrimPrefixFunc,
+		"trimspace":          stdlib.TrimSpaceFunc,
+		"trimsuffix":         stdlib.TrimSuffixFunc,
+		"unprotected_assets": unprotectedAssetsFunc,
+		"upper":              stdlib.UpperFunc,
+		"values":             stdlib.ValuesFunc,
+		"zipmap":             stdlib.ZipmapFunc,
 	}
 }
@@ -0,0 +1,328 @@
+// Package oscal exports threat model controls as a NIST OSCAL
+// component-definition, so control evidence can flow into SSP tooling.
+//
+// Each threat model becomes a component. A control names the framework
+// controls it implements in attributes named after the framework, such as
+// nist_800_53, and each of those becomes an implemented-requirement of the
+// framework's catalog. An implemented-requirement has to be a control of a
+// catalog, so controls that don't name any are left out, with a warning.
+package oscal
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// OscalVersion is the OSCAL version written by NewComponentDefinition.
+const OscalVersion = "1.1.2"
+
+// Catalogs maps the control attributes that hold framework control ids onto
+// the catalog the ids come from. OWASP doesn't publish OSCAL catalogs, so
+// asvs and proactive_control have no Source unless Options.Sources sets one.
+var Catalogs = map[string]Catalog{
+	"nist_800_53": {
+		Title:  "NIST SP 800-53 Rev 5",
+		Source: "https://raw.githubusercontent.com/usnistgov/oscal-content/main/nist.gov/SP800-53/rev5/json/NIST_SP-800-53_rev5_catalog.json",
+		// the catalog writes AC-2(1) as ac-2.1
+		normalise: func(id string) string {
+			id = strings.ToLower(id)
+			id = strings.ReplaceAll(id, "(", ".")
+			return strings.ReplaceAll(id, ")", "")
+		},
+	},
+	"asvs": {
+		Title: "OWASP Application Security Verification Standard",
+		normalise: func(id string) string {
+			id = strings.ToUpper(id)
+			if !strings.HasPrefix(id, "V") {
+				id = "V" + id
+			}
+			return id
+		},
+	},
+	"proactive_control": {
+		Title:     "OWASP Top 10 Proactive Controls",
+		normalise: strings.ToUpper,
+	},
+}
+
+// Catalog is a framework whose controls can be implemented.
+type Catalog struct {
+	Title string
+
+	// Source is the URI of the framework's OSCAL catalog.
+	Source    string
+	normalise func(string) string
+}
+
+// Namespace qualifies the props threatcl adds that OSCAL doesn't define.
+const Namespace = "https://github.com/threatcl/threatcl"
+
+// controlID is an OSCAL token.
+var controlID = regexp.MustCompile(`^[\p{L}_][\p{L}\p{N}._-]*$`)
+
+// Document is an OSCAL component-definition document.
+type Document struct {
+	ComponentDefinition ComponentDefinition `json:"component-definition"`
+}
+
+type ComponentDefinition struct {
+	UUID       string      `json:"uuid"`
+	Metadata   Metadata    `json:"metadata"`
+	Components []Component `json:"components,omitempty"`
+}
+
+type Metadata struct {
+	Title        string `json:"title"`
+	LastModified string `json:"last-modified"`
+	Version      string `json:"version"`
+	OscalVersion string `json:"oscal-version"`
+}
+
+type Component struct {
+	UUID                   string                  `json:"uuid"`
+	Type                   string                  `json:"type"`
+	Title                  string                  `json:"title"`
+	Description            string                  `json:"description"`
+	Props                  []Prop                  `json:"props,omitempty"`
+	Links                  []Link                  `json:"links,omitempty"`
+	ControlImplementations []ControlImplementation `json:"control-implementations,omitempty"`
+}
+
+type ControlImplementation struct {
+	UUID                    string                   `json:"uuid"`
+	Source                  string                   `json:"source"`
+	Description             string                   `json:"description"`
+	ImplementedRequirements []ImplementedRequirement `json:"implemented-requirements"`
+}
+
+type ImplementedRequirement struct {
+	UUID        string `json:"uuid"`
+	ControlID   string `json:"control-id"`
+	Description string `json:"description"`
+	Props       []Prop `json:"props,omitempty"`
+	Remarks     string `json:"remarks,omitempty"`
+}
+
+type Prop struct {
+	Name  string `json:"name"`
+	NS    string `json:"ns,omitempty"`
+	Value string `json:"value"`
+}
+
+type Link struct {
+	Href string `json:"href"`
+	Rel  string `json:"rel,omitempty"`
+}
+
+// Options configures NewComponentDefinition.
+type Options struct {
+	// LastModified is written to the document metadata.
+	LastModified time.Time
+
+	// Version is the document version. Defaults to "1.0".
+	Version string
+
+	// Sources maps Catalogs keys onto the URIs of their OSCAL catalogs,
+	// overriding Catalog.Source. Requirements of catalogs without a source
+	// are skipped with a warning.
+	Sources map[string]string
+}
+
+// Result is a document and anything that couldn't be exported.
+type Result struct {
+	Document Document
+	Warnings []string
+}
+
+// requirement collects the threatcl controls implementing one framework
+// control.
+type requirement struct {
+	id                 string
+	narratives         []string
+	threats            []string
+	implemented, total int
+}
+
+// NewComponentDefinition builds a component-definition with a component per
+// threat model. UUIDs are derived from names, so they stay the same from one
+// export to the next.
+func NewComponentDefinition(tms []spec.Threatmodel, opts Options) *Result {
+	if opts.Version == "" {
+		opts.Version = "1.0"
+	}
+	res := &Result{}
+
+	title := "threatcl"
+	if len(tms) == 1 {
+		title = tms[0].Name
+	}
+	doc := ComponentDefinition{
+		UUID: tmutil.UUID("component-definition", title),
+		Metadata: Metadata{
+			Title:        title,
+			LastModified: opts.LastModified.UTC().Format(time.RFC3339),
+			Version:      opts.Version,
+			OscalVersion: OscalVersion,
+		},
+	}
+
+	seen := map[string]bool{}
+	for _, tm := range tms {
+		key := tm.Name
+		for n := 2; seen[key]; n++ {
+			key = fmt.Sprintf("%s\x00%d", tm.Name, n)
+		}
+		seen[key] = true
+
+		c, warnings := component(key, tm, opts.Sources)
+		doc.Components = append(doc.Components, c)
+		res.Warnings = append(res.Warnings, warnings...)
+	}
+
+	res.Document = Document{ComponentDefinition: doc}
+	return res
+}
+
+func component(key string, tm spec.Threatmodel, sources map[string]string) (Component, []string) {
+	var warnings []string
+
+	desc := tm.Description
+	if desc == "" {
+		desc = fmt.Sprintf("Threat model %s", tm.Name)
+	}
+	c := Component{
+		UUID:        tmutil.UUID("component", key),
+		Type:        "software",
+		Title:       tm.Name,
+		Description: desc,
+	}
+	if tm.Author != "" {
+		c.Props = append(c.Props, Prop{Name: "author", NS: Namespace, Value: tm.Author})
+	}
+	if tm.Link != "" {
+		c.Links = append(c.Links, Link{Href: tm.Link, Rel: "reference"})
+	}
+
+	bySource := map[string]map[string]*requirement{}
+	for _, t := range tm.Threats {
+		for _, ctrl := range tmutil.AllControls(t) {
+			found := false
+			for _, a := range ctrl.Attributes {
+				name := strings.ToLower(a.Name)
+				cat, ok := Catalogs[name]
+				if !ok {
+					continue
+				}
+				for _, id := range strings.FieldsFunc(a.Value, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\t' }) {
+					id = cat.normalise(id)
+					if !controlID.MatchString(id) {
+						warnings = append(warnings, fmt.Sprintf("%s: control %q: %s %q isn't an OSCAL control-id, skipped", tm.Name, ctrl.Name, a.Name, id))
+						continue
+					}
+					found = true
+
+					if bySource[name] == nil {
+						bySource[name] = map[string]*requirement{}
+					}
+					r := bySource[name][id]
+					if r == nil {
+						r = &requirement{id: id}
+						bySource[name][id] = r
+					}
+					r.narratives = append(r.narratives, narrative(ctrl))
+					r.threats = append(r.threats, t.Name)
+					r.total++
+					if ctrl.Implemented {
+						r.implemented++
+					}
+				}
+			}
+			if !found {
+				warnings = append(warnings, fmt.Sprintf("%s: control %q doesn't name any framework control ids, skipped", tm.Name, ctrl.Name))
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(bySource) {
+		cat := Catalogs[name]
+		source := cat.Source
+		if s, ok := sources[name]; ok {
+			source = s
+		}
+		if source == "" {
+			warnings = append(warnings, fmt.Sprintf("%s: %s has no OSCAL catalog source, %d %s control id(s) skipped", tm.Name, cat.Title, len(bySource[name]), name))
+			continue
+		}
+		ci := ControlImplementation{
+			UUID:        tmutil.UUID("control-implementation", key, name),
+			Source:      source,
+			Description: fmt.Sprintf("%s controls implemented by %s.", cat.Title, tm.Name),
+		}
+		for _, id := range sortedKeys(bySource[name]) {
+			r := bySource[name][id]
+			ci.ImplementedRequirements = append(ci.ImplementedRequirements, ImplementedRequirement{
+				UUID:        tmutil.UUID("implemented-requirement", key, name, id),
+				ControlID:   id,
+				Description: strings.Join(dedupe(r.narratives), "\n\n"),
+				Props:       []Prop{{Name: "implementation-status", Value: r.status()}},
+				Remarks:     "Mitigates: " + strings.Join(dedupe(r.threats), ", "),
+			})
+		}
+		c.ControlImplementations = append(c.ControlImplementations, ci)
+	}
+
+	return c, warnings
+}
+
+// status is the OSCAL implementation state of the requirement.
+func (r *requirement) status() string {
+	switch r.implemented {
+	case r.total:
+		return "implemented"
+	case 0:
+		return "planned"
+	default:
+		return "partial"
+	}
+}
+
+// narrative describes how a threatcl control implements a requirement: its
+// implementation notes, or failing those its description.
+func narrative(c *spec.Control) string {
+	text := strings.TrimSpace(c.ImplementationNotes)
+	if text == "" {
+		text = strings.TrimSpace(c.Description)
+	}
+	if text == "" {
+		return c.Name
+	}
+	return fmt.Sprintf("%s: %s", c.Name, text)
+}
+
+func dedupe(in []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, s := range in {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,188 @@
+package oscal
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/threatcl/spec"
+)
+
+// proactiveControls stands in for an OSCAL catalog of the OWASP Proactive
+// Controls, which OWASP doesn't publish.
+const proactiveControls = "https://example.com/owasp-proactive-controls.json"
+
+func oscalModels() []spec.Threatmodel {
+	return []spec.Threatmodel{
+		{
+			Name:   "Shop",
+			Author: "@alice",
+			Link:   "https://example.com/shop",
+			Threats: []*spec.Threat{
+				{
+					Name: "Credential stuffing",
+					Controls: []*spec.Control{
+						{
+							Name:                "MFA",
+							Implemented:         true,
+							Description:         "Require a second factor",
+							ImplementationNotes: "TOTP for all accounts",
+							Attributes: []*spec.ControlAttribute{
+								{Name: "nist_800_53", Value: "IA-2(1), AC-7"},
+								{Name: "category", Value: "Authentication"},
+							},
+						},
+						{
+							Name:        "Lockout",
+							Description: "Lock accounts after failed logins",
+							Attributes:  []*spec.ControlAttribute{{Name: "NIST_800_53", Value: "ac-7"}},
+						},
+					},
+				},
+				{
+					Name: "SQL injection",
+					Controls: []*spec.Control{
+						{Name: "Parameterised queries", Attributes: []*spec.ControlAttribute{{Name: "proactive_control", Value: "c3 4.1"}}},
+						{Name: "WAF"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestNewComponentDefinition(t *testing.T) {
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600))
+	res := NewComponentDefinition(oscalModels(), Options{LastModified: modified, Sources: map[string]string{"proactive_control": proactiveControls}})
+	cd := res.Document.ComponentDefinition
+
+	if cd.Metadata.Title != "Shop" || cd.Metadata.LastModified != "2026-01-02T02:04:05Z" || cd.Metadata.Version != "1.0" || cd.Metadata.OscalVersion != OscalVersion {
+		t.Errorf("unexpected metadata: %+v", cd.Metadata)
+	}
+	if len(cd.Components) != 1 {
+		t.Fatalf("expected 1 component, got %d", len(cd.Components))
+	}
+	c := cd.Components[0]
+	if c.Title != "Shop" || c.Type != "software" || c.Description != "Threat model Shop" {
+		t.Errorf("unexpected component: %+v", c)
+	}
+	if len(c.Links) != 1 || c.Links[0].Href != "https://example.com/shop" {
+		t.Errorf("unexpected links: %v", c.Links)
+	}
+	if len(c.ControlImplementations) != 2 {
+		t.Fatalf("expected 2 control implementations, got %+v", c.ControlImplementations)
+	}
+
+	nist := c.ControlImplementations[0]
+	if nist.Source != Catalogs["nist_800_53"].Source || len(nist.ImplementedRequirements) != 2 {
+		t.Fatalf("unexpected nist implementation: %+v", nist)
+	}
+	ac7, ia2 := nist.ImplementedRequirements[0], nist.ImplementedRequirements[1]
+	if ac7.ControlID != "ac-7" || ac7.Props[0].Value != "partial" {
+		t.Errorf("unexpected ac-7: %+v", ac7)
+	}
+	if ac7.Description != "MFA: TOTP for all accounts\n\nLockout: Lock accounts after failed logins" {
+		t.Errorf("unexpected ac-7 narrative: %q", ac7.Description)
+	}
+	if ac7.Remarks != "Mitigates: Credential stuffing" {
+		t.Errorf("unexpected ac-7 remarks: %q", ac7.Remarks)
+	}
+	if ia2.ControlID != "ia-2.1" || ia2.Props[0] != (Prop{Name: "implementation-status", Value: "implemented"}) {
+		t.Errorf("unexpected ia-2.1: %+v", ia2)
+	}
+
+	pc := c.ControlImplementations[1]
+	if pc.Source != proactiveControls || len(pc.ImplementedRequirements) != 1 || pc.ImplementedRequirements[0].ControlID != "C3" || pc.ImplementedRequirements[0].Props[0].Value != "planned" {
+		t.Errorf("unexpected proactive controls implementation: %+v", pc)
+	}
+
+	expWarnings := []string{
+		`Shop: control "Parameterised queries": proactive_control "4.1" isn't an OSCAL control-id, skipped`,
+		`Shop: control "WAF" doesn't name any framework control ids, skipped`,
+	}
+	if strings.Join(res.Warnings, "\n") != strings.Join(expWarnings, "\n") {
+		t.Errorf("unexpected warnings: %v", res.Warnings)
+	}
+}
+
+func TestNewComponentDefinitionNoSource(t *testing.T) {
+	res := NewComponentDefinition(oscalModels(), Options{})
+	c := res.Document.ComponentDefinition.Components[0]
+	if len(c.ControlImplementations) != 1 || c.ControlImplementations[0].Source != Catalogs["nist_800_53"].Source {
+		t.Errorf("expected only the nist implementation, got %+v", c.ControlImplementations)
+	}
+
+	exp := "Shop: OWASP Top 10 Proactive Controls has no OSCAL catalog source, 1 proactive_control control id(s) skipped"
+	if len(res.Warnings) != 3 || res.Warnings[2] != exp {
+		t.Errorf("unexpected warnings: %v", res.Warnings)
+	}
+}
+
+// TestComponentDefinitionSchema validates an export against the parts of
+// the OSCAL component-definition model that it uses, as transcribed in
+// testdata/component_definition_schema.json.
+func TestComponentDefinitionSchema(t *testing.T) {
+	c := jsonschema.NewCompiler()
+	c.AssertFormat()
+	schema, err := c.Compile(filepath.Join("testdata", "component_definition_schema.json"))
+	if err != nil {
+		t.Fatalf("error compiling the schema: %s", err)
+	}
+
+	res := NewComponentDefinition(append(oscalModels(), spec.Threatmodel{Name: "Empty"}), Options{
+		LastModified: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Sources:      map[string]string{"proactive_control": proactiveControls},
+	})
+	out, err := json.Marshal(res.Document)
+	if err != nil {
+		t.Fatalf("error marshalling: %s", err)
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("error reading the export back: %s", err)
+	}
+	if err := schema.Validate(doc); err != nil {
+		t.Errorf("export doesn't match OSCAL %s: %s", OscalVersion, err)
+	}
+
+	// and the schema does catch a malformed document
+	bad, _ := jsonschema.UnmarshalJSON(strings.NewReader(`{"component-definition": {"uuid": "not-a-uuid", "metadata": {"title": "x"}}}`))
+	if err := schema.Validate(bad); err == nil {
+		t.Error("expected a malformed document to fail validation")
+	}
+}
+
+func TestNewComponentDefinitionIDs(t *testing.T) {
+	tms := append(oscalModels(), oscalModels()...)
+	a := NewComponentDefinition(tms, Options{})
+	b := NewComponentDefinition(tms, Options{})
+
+	aj, _ := json.Marshal(a.Document)
+	bj, _ := json.Marshal(b.Document)
+	if string(aj) != string(bj) {
+		t.Errorf("expected re-exports to be identical")
+	}
+
+	if a.Document.ComponentDefinition.Metadata.Title != "threatcl" {
+		t.Errorf("expected a fleet document to be titled threatcl")
+	}
+
+	// OSCAL requires version 4 or 5 uuids, unique within the document
+	uuidRe := regexp.MustCompile(`"uuid":"([^"]+)"`)
+	valid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[45][0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := map[string]bool{}
+	for _, m := range uuidRe.FindAllStringSubmatch(string(aj), -1) {
+		if !valid.MatchString(m[1]) {
+			t.Errorf("invalid uuid %s", m[1])
+		}
+		if seen[m[1]] {
+			t.Errorf("duplicate uuid %s", m[1])
+		}
+		seen[m[1]] = true
+	}
+}
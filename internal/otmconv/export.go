@@ -0,0 +1,432 @@
+package otmconv
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/threatcl/go-otm/pkg/otm"
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// OtmVersion is the OTM version Export writes.
+const OtmVersion = "0.2.0"
+
+// Attribute keys for values OTM has no native field for. Import reads them
+// back, so an export followed by an import keeps them.
+const (
+	// AttrDiagram names the data_flow_diagram_v2 a component or dataflow
+	// belongs to.
+	AttrDiagram = "data_flow_diagram"
+	// AttrImplicit marks the trust zone holding components that have none.
+	AttrImplicit = "threatcl_implicit"
+	// AttrThreat is the id of the threat a mitigation belongs to.
+	AttrThreat = "threat"
+	// AttrProposed marks a mitigation exported from a proposed_control.
+	AttrProposed = "proposed"
+)
+
+// Component types written for DFD elements.
+const (
+	TypeProcess         = "process"
+	TypeDataStore       = "data-store"
+	TypeExternalElement = "external-element"
+)
+
+// riskValues are the 0-100 values each likelihood/impact level is written
+// as: the middle of the range level() maps back onto it.
+var riskValues = map[string]float64{
+	"very_low":  10,
+	"low":       30,
+	"medium":    50,
+	"high":      70,
+	"very_high": 90,
+}
+
+// Export converts a threat model into a complete OTM document: assets,
+// threats and mitigations, and every data_flow_diagram_v2 as trustZones,
+// components and dataflows. IDs are derived from names, so exporting the same
+// model twice gives the same IDs.
+func Export(tm *spec.Threatmodel) otm.OtmSchemaJson {
+	e := &exporter{tm: tm, ids: map[string]bool{}, assets: map[string]string{}, zones: map[string]string{}}
+
+	e.o.OtmVersion = OtmVersion
+	e.project()
+	e.representations()
+	e.exportAssets()
+	e.exportThreats()
+	e.exportDiagrams()
+
+	return e.o
+}
+
+type exporter struct {
+	tm *spec.Threatmodel
+	o  otm.OtmSchemaJson
+
+	// ids already handed out, and the ids of assets and trust zones by name
+	ids    map[string]bool
+	assets map[string]string
+	zones  map[string]string
+
+	// threatsByAsset lists the threats referencing each asset, used to
+	// attach threat instances to the data stores holding it
+	threatsByAsset map[string][]otm.Threat
+}
+
+// id returns a unique id built from a kind and slugged name parts, e.g.
+// "component.level-0.web".
+func (e *exporter) id(kind string, parts ...string) string {
+	slugs := []string{kind}
+	for _, p := range parts {
+		slugs = append(slugs, slug(p))
+	}
+	base := strings.Join(slugs, ".")
+
+	id := base
+	for n := 2; e.ids[id]; n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	e.ids[id] = true
+	return id
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slug(s string) string {
+	out := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if out == "" {
+		return "unnamed"
+	}
+	return out
+}
+
+func (e *exporter) project() {
+	tm := e.tm
+	attrs := otm.OtmSchemaJsonProjectAttributes{}
+
+	if tm.Attributes != nil {
+		attrs["initiative_size"] = tm.Attributes.InitiativeSize
+		attrs["internet_facing"] = tm.Attributes.InternetFacing
+		attrs["new_initiative"] = tm.Attributes.NewInitiative
+	}
+	for _, aa := range tm.AdditionalAttributes {
+		attrs[aa.Name] = aa.Value
+	}
+
+	if tm.Link != "" {
+		attrs["link"] = tm.Link
+	}
+	if len(tm.Repository) > 0 {
+		attrs["repository"] = tm.Repository
+	}
+	if tm.CreatedAt != 0 {
+		attrs["created_at"] = tm.CreatedAt
+	}
+	if tm.UpdatedAt != 0 {
+		attrs["updated_at"] = tm.UpdatedAt
+	}
+
+	if len(tm.UseCases) > 0 {
+		ucs := []string{}
+		for _, uc := range tm.UseCases {
+			ucs = append(ucs, uc.Description)
+		}
+		attrs["usecases"] = ucs
+	}
+	if len(tm.Exclusions) > 0 {
+		exs := []string{}
+		for _, ex := range tm.Exclusions {
+			exs = append(exs, ex.Description)
+		}
+		attrs["exclusions"] = exs
+	}
+	if len(tm.ThirdPartyDependencies) > 0 {
+		tpds := []map[string]interface{}{}
+		for _, tpd := range tm.ThirdPartyDependencies {
+			tpds = append(tpds, map[string]interface{}{
+				"name":              tpd.Name,
+				"description":       tpd.Description,
+				"saas":              tpd.Saas,
+				"paying_customer":   tpd.PayingCustomer,
+				"open_source":       tpd.OpenSource,
+				"infrastructure":    tpd.Infrastructure,
+				"uptime_dependency": string(tpd.UptimeDependency),
+				"uptime_notes":      tpd.UptimeNotes,
+			})
+		}
+		attrs["third_party_dependencies"] = tpds
+	}
+
+	e.o.Project = otm.OtmSchemaJsonProject{
+		Id:          slug(tm.Name),
+		Name:        tm.Name,
+		Description: strPtr(tm.Description),
+		Owner:       strPtr(tm.Author),
+	}
+	if len(attrs) > 0 {
+		e.o.Project.Attributes = attrs
+	}
+}
+
+// representations carries the diagram_link, and each mermaid block with its
+// raw source in the attributes (format=mermaid, content=<source>). OTM has no
+// first-class field for inline diagram source.
+func (e *exporter) representations() {
+	if e.tm.DiagramLink != "" {
+		e.o.Representations = append(e.o.Representations, otm.OtmSchemaJsonRepresentationsElem{
+			Id:         "diagram-1",
+			Name:       e.tm.Name,
+			Type:       "diagram",
+			Attributes: otm.OtmSchemaJsonRepresentationsElemAttributes{"url": e.tm.DiagramLink},
+		})
+	}
+
+	for i, m := range e.tm.MermaidDiagrams {
+		e.o.Representations = append(e.o.Representations, otm.OtmSchemaJsonRepresentationsElem{
+			Id:          fmt.Sprintf("mermaid-%d", i+1),
+			Name:        m.Name,
+			Description: optStrPtr(m.Description),
+			Type:        "diagram",
+			Attributes: otm.OtmSchemaJsonRepresentationsElemAttributes{
+				"format":  "mermaid",
+				"content": m.Content,
+			},
+		})
+	}
+}
+
+func (e *exporter) exportAssets() {
+	for _, ia := range e.tm.InformationAssets {
+		id := e.id("asset", ia.Name)
+		e.assets[ia.Name] = id
+
+		attrs := otm.OtmSchemaJsonAssetsElemAttributes{}
+		if ia.InformationClassification != "" {
+			attrs["information_classification"] = ia.InformationClassification
+		}
+		if ia.Source != "" {
+			attrs["source"] = ia.Source
+		}
+
+		a := otm.OtmSchemaJsonAssetsElem{
+			Id:          id,
+			Name:        ia.Name,
+			Description: optStrPtr(ia.Description),
+		}
+		if len(attrs) > 0 {
+			a.Attributes = attrs
+		}
+		e.o.Assets = append(e.o.Assets, a)
+	}
+}
+
+func (e *exporter) exportThreats() {
+	e.threatsByAsset = map[string][]otm.Threat{}
+
+	for _, t := range e.tm.Threats {
+		tid := e.id("threat", t.Name)
+
+		categories := []*string{}
+		for _, c := range append(append([]string{}, t.ImpactType...), t.Stride...) {
+			categories = append(categories, strPtr(c))
+		}
+
+		threat := otm.OtmSchemaJsonThreatsElem{
+			Id:          tid,
+			Name:        t.Name,
+			Description: optStrPtr(t.Description),
+			Categories:  categories,
+		}
+
+		attrs := otm.OtmSchemaJsonThreatsElemAttributes{}
+		if len(t.InformationAssetRefs) > 0 {
+			attrs["information_asset_refs"] = t.InformationAssetRefs
+		}
+		if t.Control != "" {
+			attrs["control"] = t.Control
+		}
+		if t.Risk != nil {
+			if l, ok := riskValues[t.Risk.Likelihood]; ok {
+				threat.Risk.Likelihood = &l
+			}
+			threat.Risk.Impact = riskValues[t.Risk.Impact]
+			if t.Risk.Rationale != "" {
+				attrs["risk_rationale"] = t.Risk.Rationale
+			}
+			if sev := t.Risk.Severity(); sev != "" {
+				attrs["severity"] = sev
+			}
+		}
+		if len(attrs) > 0 {
+			threat.Attributes = attrs
+		}
+		e.o.Threats = append(e.o.Threats, threat)
+
+		instance := otm.Threat{Threat: tid, State: "exposed"}
+		for _, c := range tmutil.AllControls(t) {
+			mid := e.id("mitigation", t.Name, c.Name)
+			e.o.Mitigations = append(e.o.Mitigations, e.mitigation(mid, tid, c))
+
+			state := "required"
+			if c.Implemented {
+				state = "implemented"
+			}
+			instance.Mitigations = append(instance.Mitigations, otm.ThreatMitigationsElem{
+				Mitigation: strPtr(mid),
+				State:      strPtr(state),
+			})
+		}
+
+		for i, pc := range t.ProposedControls {
+			mid := e.id("mitigation", t.Name, fmt.Sprintf("proposed %d", i+1))
+			e.o.Mitigations = append(e.o.Mitigations, otm.OtmSchemaJsonMitigationsElem{
+				Id:          mid,
+				Name:        fmt.Sprintf("Proposed control %d", i+1),
+				Description: optStrPtr(pc.Description),
+				Attributes: otm.OtmSchemaJsonMitigationsElemAttributes{
+					AttrThreat:    tid,
+					AttrProposed:  true,
+					"implemented": pc.Implemented,
+				},
+			})
+		}
+
+		for _, ref := range t.InformationAssetRefs {
+			e.threatsByAsset[ref] = append(e.threatsByAsset[ref], instance)
+		}
+	}
+}
+
+func (e *exporter) mitigation(id, threatID string, c *spec.Control) otm.OtmSchemaJsonMitigationsElem {
+	attrs := otm.OtmSchemaJsonMitigationsElemAttributes{
+		AttrThreat:    threatID,
+		"implemented": c.Implemented,
+	}
+	if c.ImplementationNotes != "" {
+		attrs["implementation_notes"] = c.ImplementationNotes
+	}
+	for _, a := range c.Attributes {
+		attrs[a.Name] = a.Value
+	}
+
+	return otm.OtmSchemaJsonMitigationsElem{
+		Id:            id,
+		Name:          c.Name,
+		Description:   optStrPtr(c.Description),
+		RiskReduction: float64(c.RiskReduction),
+		Attributes:    attrs,
+	}
+}
+
+// exportDiagrams writes each data_flow_diagram_v2. Trust zones are shared
+// between diagrams by name; elements and flows are namespaced by diagram.
+func (e *exporter) exportDiagrams() {
+	implicit := ""
+
+	zone := func(name string) string {
+		if name == "" {
+			if implicit == "" {
+				implicit = e.id("trustzone", "unzoned")
+				e.o.TrustZones = append(e.o.TrustZones, otm.OtmSchemaJsonTrustZonesElem{
+					Id:         implicit,
+					Name:       "Unzoned",
+					Attributes: otm.OtmSchemaJsonTrustZonesElemAttributes{AttrImplicit: true},
+				})
+			}
+			return implicit
+		}
+		if id, ok := e.zones[name]; ok {
+			return id
+		}
+		id := e.id("trustzone", name)
+		e.zones[name] = id
+		e.o.TrustZones = append(e.o.TrustZones, otm.OtmSchemaJsonTrustZonesElem{Id: id, Name: name})
+		return id
+	}
+
+	for _, d := range e.tm.DataFlowDiagrams {
+		elems := map[string]string{}
+
+		add := func(name, kind, zoneName, iaLink string) {
+			id := e.id("component", d.Name, name)
+			elems[name] = id
+
+			c := otm.OtmSchemaJsonComponentsElem{
+				Id:         id,
+				Name:       name,
+				Type:       kind,
+				Parent:     otm.Parent{TrustZone: strPtr(zone(zoneName))},
+				Attributes: otm.OtmSchemaJsonComponentsElemAttributes{AttrDiagram: d.Name},
+			}
+			if aid, ok := e.assets[iaLink]; ok {
+				c.Assets = &otm.AssetInstance{Stored: []*string{strPtr(aid)}}
+				c.Threats = e.threatsByAsset[iaLink]
+			}
+			e.o.Components = append(e.o.Components, c)
+		}
+
+		for _, el := range dfdElements(d) {
+			add(el.name, el.kind, el.zone, el.iaLink)
+		}
+
+		for _, f := range d.Flows {
+			from, okFrom := elems[f.From]
+			to, okTo := elems[f.To]
+			if !okFrom || !okTo {
+				continue
+			}
+
+			attrs := otm.OtmSchemaJsonDataflowsElemAttributes{AttrDiagram: d.Name}
+			if f.Protocol != "" {
+				attrs["protocol"] = f.Protocol
+			}
+			e.o.Dataflows = append(e.o.Dataflows, otm.OtmSchemaJsonDataflowsElem{
+				Id:          e.id("dataflow", d.Name, f.Name),
+				Name:        f.Name,
+				Source:      from,
+				Destination: to,
+				Attributes:  attrs,
+			})
+		}
+	}
+}
+
+type dfdElement struct {
+	name, kind, zone, iaLink string
+}
+
+// dfdElements flattens a diagram's elements, including those declared inside
+// trust_zone blocks, in declaration order.
+func dfdElements(d *spec.DataFlowDiagram) []dfdElement {
+	out := []dfdElement{}
+	collect := func(zone string, ps []*spec.DfdProcess, ds []*spec.DfdData, ees []*spec.DfdExternal) {
+		for _, p := range ps {
+			out = append(out, dfdElement{p.Name, TypeProcess, tmutil.FirstNonEmpty(p.TrustZone, zone), ""})
+		}
+		for _, s := range ds {
+			out = append(out, dfdElement{s.Name, TypeDataStore, tmutil.FirstNonEmpty(s.TrustZone, zone), s.IaLink})
+		}
+		for _, ee := range ees {
+			out = append(out, dfdElement{ee.Name, TypeExternalElement, tmutil.FirstNonEmpty(ee.TrustZone, zone), ""})
+		}
+	}
+
+	collect("", d.Processes, d.DataStores, d.ExternalElements)
+	for _, tz := range d.TrustZones {
+		collect(tz.Name, tz.Processes, tz.DataStores, tz.ExternalElements)
+	}
+	return out
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func optStrPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,211 @@
+package otmconv
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+func exportModel() *spec.Threatmodel {
+	return &spec.Threatmodel{
+		Name:        "Shop",
+		Description: "An online shop",
+		Author:      "@alice",
+		Link:        "https://example.com/shop",
+		DiagramLink: "https://example.com/shop.png",
+		Repository:  []string{"https://github.com/example/shop"},
+		CreatedAt:   1594033151,
+		Attributes:  &spec.Attribute{InitiativeSize: "Small", InternetFacing: true},
+		AdditionalAttributes: []*spec.AdditionalAttribute{
+			{Name: "network_segment", Value: "dmz"},
+		},
+		InformationAssets: []*spec.InformationAsset{
+			{Name: "Card data", Description: "PANs", InformationClassification: "Restricted"},
+		},
+		UseCases:   []*spec.UseCase{{Description: "Customers buy things"}},
+		Exclusions: []*spec.Exclusion{{Description: "The warehouse"}},
+		ThirdPartyDependencies: []*spec.ThirdPartyDependency{
+			{Name: "Stripe", Description: "Payments", Saas: true, UptimeDependency: spec.HardUptime},
+		},
+		Threats: []*spec.Threat{
+			{
+				Name:                 "SQL injection",
+				Description:          "Attacker injects SQL",
+				ImpactType:           []string{"Confidentiality"},
+				Stride:               []string{"Tampering"},
+				InformationAssetRefs: []string{"Card data"},
+				Risk:                 &spec.Risk{Likelihood: "medium", Impact: "very_high", Rationale: "Public endpoint"},
+				Controls: []*spec.Control{
+					{
+						Name:                "Parameterised queries",
+						Description:         "Use bind params",
+						Implemented:         true,
+						ImplementationNotes: "All queries via the ORM",
+						RiskReduction:       80,
+						Attributes:          []*spec.ControlAttribute{{Name: "owasp", Value: "C3"}},
+					},
+					{Name: "WAF", Description: "Filter requests", RiskReduction: 30},
+				},
+			},
+			{
+				Name:             "Flooding",
+				Description:      "Too many requests",
+				ProposedControls: []*spec.ProposedControl{{Description: "Rate limit"}},
+			},
+		},
+		DataFlowDiagrams: []*spec.DataFlowDiagram{
+			{
+				Name:             "Level 0",
+				ExternalElements: []*spec.DfdExternal{{Name: "Browser"}},
+				TrustZones: []*spec.DfdTrustZone{
+					{
+						Name:       "Data Centre",
+						Processes:  []*spec.DfdProcess{{Name: "Web"}},
+						DataStores: []*spec.DfdData{{Name: "Orders DB", IaLink: "Card data"}},
+					},
+				},
+				Flows: []*spec.DfdFlow{
+					{Name: "browse", From: "Browser", To: "Web", Protocol: "HTTPS"},
+					{Name: "query", From: "Web", To: "Orders DB", Protocol: "TLS"},
+				},
+			},
+			{
+				Name:      "Level 1",
+				Processes: []*spec.DfdProcess{{Name: "Web", TrustZone: "Data Centre"}},
+			},
+		},
+		MermaidDiagrams: []*spec.MermaidDiagram{
+			{Name: "Checkout", Content: "sequenceDiagram\n  A->>B: pay"},
+		},
+	}
+}
+
+func TestExport(t *testing.T) {
+	o := Export(exportModel())
+
+	if o.OtmVersion != OtmVersion || o.Project.Id != "shop" || deref(o.Project.Owner) != "@alice" {
+		t.Errorf("unexpected project: %+v", o.Project)
+	}
+	if o.Project.Attributes["network_segment"] != "dmz" || o.Project.Attributes["internet_facing"] != true {
+		t.Errorf("unexpected project attributes: %+v", o.Project.Attributes)
+	}
+
+	if len(o.TrustZones) != 2 || o.TrustZones[0].Id != "trustzone.unzoned" || o.TrustZones[1].Id != "trustzone.data-centre" {
+		t.Fatalf("unexpected trust zones: %+v", o.TrustZones)
+	}
+
+	ids := []string{}
+	for _, c := range o.Components {
+		ids = append(ids, c.Id+"/"+c.Type+"/"+deref(c.Parent.TrustZone))
+	}
+	expIds := []string{
+		"component.level-0.browser/external-element/trustzone.unzoned",
+		"component.level-0.web/process/trustzone.data-centre",
+		"component.level-0.orders-db/data-store/trustzone.data-centre",
+		"component.level-1.web/process/trustzone.data-centre",
+	}
+	if !reflect.DeepEqual(ids, expIds) {
+		t.Errorf("unexpected components:\n%v\n%v", ids, expIds)
+	}
+
+	db := o.Components[2]
+	if db.Assets == nil || len(db.Assets.Stored) != 1 || *db.Assets.Stored[0] != "asset.card-data" {
+		t.Errorf("expected the data store to hold the card data asset: %+v", db.Assets)
+	}
+	if len(db.Threats) != 1 || db.Threats[0].Threat != "threat.sql-injection" || len(db.Threats[0].Mitigations) != 2 {
+		t.Errorf("expected a threat instance on the data store: %+v", db.Threats)
+	}
+
+	if len(o.Dataflows) != 2 || o.Dataflows[0].Id != "dataflow.level-0.browse" ||
+		o.Dataflows[0].Source != "component.level-0.browser" || o.Dataflows[1].Attributes["protocol"] != "TLS" {
+		t.Errorf("unexpected dataflows: %+v", o.Dataflows)
+	}
+
+	sqli := o.Threats[0]
+	if sqli.Risk.Likelihood == nil || *sqli.Risk.Likelihood != 50 || sqli.Risk.Impact != 90 {
+		t.Errorf("unexpected risk: %+v", sqli.Risk)
+	}
+	if len(sqli.Categories) != 2 || sqli.Attributes["risk_rationale"] != "Public endpoint" {
+		t.Errorf("unexpected threat: %+v", sqli)
+	}
+	if o.Threats[1].Risk.Likelihood != nil {
+		t.Errorf("expected an unrated threat to have a null likelihood")
+	}
+
+	if len(o.Mitigations) != 3 {
+		t.Fatalf("expected 3 mitigations, got %d", len(o.Mitigations))
+	}
+	params := o.Mitigations[0]
+	if params.Id != "mitigation.sql-injection.parameterised-queries" || params.RiskReduction != 80 ||
+		params.Attributes["implemented"] != true || params.Attributes["owasp"] != "C3" ||
+		params.Attributes[AttrThreat] != "threat.sql-injection" {
+		t.Errorf("unexpected mitigation: %+v", params)
+	}
+
+	if len(o.Representations) != 2 || o.Representations[1].Id != "mermaid-1" || o.Representations[1].Attributes["format"] != "mermaid" {
+		t.Errorf("unexpected representations: %+v", o.Representations)
+	}
+
+	// Stable: the same model always gives the same document.
+	a, _ := json.Marshal(o)
+	b, _ := json.Marshal(Export(exportModel()))
+	if string(a) != string(b) {
+		t.Errorf("expected repeated exports to be identical")
+	}
+}
+
+func TestExportDuplicateIds(t *testing.T) {
+	o := Export(&spec.Threatmodel{
+		Name: "tm",
+		InformationAssets: []*spec.InformationAsset{
+			{Name: "a b"},
+			{Name: "A-B"},
+		},
+	})
+	if o.Assets[0].Id != "asset.a-b" || o.Assets[1].Id != "asset.a-b-2" {
+		t.Errorf("expected unique ids, got %q and %q", o.Assets[0].Id, o.Assets[1].Id)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	orig := exportModel()
+
+	data, err := json.Marshal(Export(orig))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	docs, err := Parse(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	res := Import(docs[0], testOpts)
+	if len(res.Warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", res.Warnings)
+	}
+
+	got := res.Threatmodel
+
+	// Trust zone blocks come back as trust_zone attributes on each element,
+	// which is the same diagram.
+	exp := exportModel()
+	l0 := exp.DataFlowDiagrams[0]
+	for _, tz := range l0.TrustZones {
+		for _, p := range tz.Processes {
+			p.TrustZone = tz.Name
+			l0.Processes = append(l0.Processes, p)
+		}
+		for _, ds := range tz.DataStores {
+			ds.TrustZone = tz.Name
+			l0.DataStores = append(l0.DataStores, ds)
+		}
+	}
+	l0.TrustZones = nil
+
+	if !reflect.DeepEqual(got, exp) {
+		a, _ := json.MarshalIndent(exp, "", "  ")
+		b, _ := json.MarshalIndent(got, "", "  ")
+		t.Errorf("round trip changed the model:\nexpected %s\ngot %s", a, b)
+	}
+}
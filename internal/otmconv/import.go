@@ -0,0 +1,690 @@
+// Package otmconv converts between Open Threat Model (OTM) documents and
+// threatcl threat models.
+//
+// Import maps an OTM project onto a threatmodel: assets become
+// information_asset blocks, threats become threat blocks with their
+// mitigations as controls, and trustZones, components and dataflows become a
+// data_flow_diagram_v2. Anything OTM can express that threatcl can't (or that
+// is ambiguous) is reported as a warning rather than silently dropped.
+package otmconv
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/threatcl/go-otm/pkg/otm"
+	"github.com/threatcl/spec"
+)
+
+// UnlinkedThreatName names the threat that collects mitigations which no
+// threat instance references.
+const UnlinkedThreatName = "Unlinked mitigations"
+
+// Options tune how OTM values are mapped onto the spec.
+type Options struct {
+	// Stride and ImpactTypes are the allowed stride and impacts values (see
+	// spec.ThreatmodelSpecConfig). OTM threat categories matching either
+	// list are mapped onto them, case-insensitively.
+	Stride      []string
+	ImpactTypes []string
+}
+
+// Result is one imported threat model and anything that didn't map cleanly.
+type Result struct {
+	Threatmodel *spec.Threatmodel
+	Warnings    []string
+}
+
+// unratedLikelihood stands in for a null threat likelihood, see normalise.
+const unratedLikelihood = -1
+
+// Parse decodes OTM JSON, which may be a single document or an array of them
+// (as written by "threatcl export -format=otm" for several models).
+func Parse(data []byte) ([]otm.OtmSchemaJson, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing otm: %s", err)
+	}
+
+	normalise(raw)
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing otm: %s", err)
+	}
+
+	if _, ok := raw.([]interface{}); ok {
+		docs := []otm.OtmSchemaJson{}
+		if err := json.Unmarshal(data, &docs); err != nil {
+			return nil, fmt.Errorf("error parsing otm: %s", err)
+		}
+		return docs, nil
+	}
+
+	doc := otm.OtmSchemaJson{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing otm: %s", err)
+	}
+	return []otm.OtmSchemaJson{doc}, nil
+}
+
+// normalise replaces null threat likelihoods, which OTM writers (threatcl's
+// own export included) use for unrated threats but go-otm rejects as a
+// missing required field.
+func normalise(raw interface{}) {
+	docs, ok := raw.([]interface{})
+	if !ok {
+		docs = []interface{}{raw}
+	}
+
+	for _, d := range docs {
+		doc, _ := d.(map[string]interface{})
+		threats, _ := doc["threats"].([]interface{})
+		for _, t := range threats {
+			threat, _ := t.(map[string]interface{})
+			risk, _ := threat["risk"].(map[string]interface{})
+			if l, ok := risk["likelihood"]; ok && l == nil {
+				risk["likelihood"] = unratedLikelihood
+			}
+		}
+	}
+}
+
+// Import converts an OTM document into a threat model.
+func Import(o otm.OtmSchemaJson, opts Options) *Result {
+	i := &importer{
+		o:      o,
+		opts:   opts,
+		tm:     &spec.Threatmodel{Name: o.Project.Name},
+		assets: map[string]string{},
+		zones:  map[string]string{},
+		elems:  map[string]string{},
+	}
+
+	i.project()
+	i.representations()
+	i.importAssets()
+	i.importThreats()
+	i.importDiagram()
+
+	return &Result{Threatmodel: i.tm, Warnings: i.warnings}
+}
+
+type importer struct {
+	o        otm.OtmSchemaJson
+	opts     Options
+	tm       *spec.Threatmodel
+	warnings []string
+
+	// ids of assets, trust zones and DFD elements mapped to their
+	// threatcl names
+	assets map[string]string
+	zones  map[string]string
+	elems  map[string]string
+}
+
+func (i *importer) warn(format string, args ...interface{}) {
+	i.warnings = append(i.warnings, fmt.Sprintf(format, args...))
+}
+
+func (i *importer) project() {
+	p := i.o.Project
+	i.tm.Description = deref(p.Description)
+
+	switch {
+	case deref(p.Owner) != "":
+		i.tm.Author = *p.Owner
+	case deref(p.OwnerContact) != "":
+		i.tm.Author = *p.OwnerContact
+	default:
+		i.warn("project %q has no owner, author is empty", p.Name)
+	}
+
+	for _, k := range sortedKeys(p.Attributes) {
+		v := p.Attributes[k]
+		switch k {
+		case "initiative_size":
+			i.attrs().InitiativeSize = stringValue(v)
+		case "internet_facing":
+			i.attrs().InternetFacing = boolValue(v)
+		case "new_initiative":
+			i.attrs().NewInitiative = boolValue(v)
+		case "link":
+			i.tm.Link = stringValue(v)
+		case "repository":
+			i.tm.Repository = listValue(v)
+		case "created_at":
+			i.tm.CreatedAt = intValue(v)
+		case "updated_at":
+			i.tm.UpdatedAt = intValue(v)
+		case "usecases":
+			for _, d := range listValue(v) {
+				i.tm.UseCases = append(i.tm.UseCases, &spec.UseCase{Description: d})
+			}
+		case "exclusions":
+			for _, d := range listValue(v) {
+				i.tm.Exclusions = append(i.tm.Exclusions, &spec.Exclusion{Description: d})
+			}
+		case "third_party_dependencies":
+			i.thirdPartyDependencies(v)
+		default:
+			i.tm.AdditionalAttributes = append(i.tm.AdditionalAttributes, &spec.AdditionalAttribute{
+				Name:  k,
+				Value: stringValue(v),
+			})
+		}
+	}
+
+	if len(p.Tags) > 0 {
+		i.tm.AdditionalAttributes = append(i.tm.AdditionalAttributes, &spec.AdditionalAttribute{
+			Name:  "tags",
+			Value: strings.Join(p.Tags, ", "),
+		})
+	}
+}
+
+// thirdPartyDependencies restores the third_party_dependency blocks the export
+// carries in the project attributes.
+func (i *importer) thirdPartyDependencies(v interface{}) {
+	list, _ := v.([]interface{})
+	for _, e := range list {
+		m, ok := e.(map[string]interface{})
+		if !ok {
+			i.warn("third_party_dependencies: expected objects, skipped %v", e)
+			continue
+		}
+		i.tm.ThirdPartyDependencies = append(i.tm.ThirdPartyDependencies, &spec.ThirdPartyDependency{
+			Name:             stringValue(m["name"]),
+			Description:      stringValue(m["description"]),
+			Saas:             boolValue(m["saas"]),
+			PayingCustomer:   boolValue(m["paying_customer"]),
+			OpenSource:       boolValue(m["open_source"]),
+			Infrastructure:   boolValue(m["infrastructure"]),
+			UptimeDependency: spec.UptimeDependencyClassification(stringValue(m["uptime_dependency"])),
+			UptimeNotes:      stringValue(m["uptime_notes"]),
+		})
+	}
+}
+
+func (i *importer) attrs() *spec.Attribute {
+	if i.tm.Attributes == nil {
+		i.tm.Attributes = &spec.Attribute{}
+	}
+	return i.tm.Attributes
+}
+
+// representations restores mermaid blocks and the diagram_link.
+func (i *importer) representations() {
+	for _, r := range i.o.Representations {
+		if stringValue(r.Attributes["format"]) == "mermaid" {
+			i.tm.MermaidDiagrams = append(i.tm.MermaidDiagrams, &spec.MermaidDiagram{
+				Name:        r.Name,
+				Description: deref(r.Description),
+				Content:     stringValue(r.Attributes["content"]),
+			})
+			continue
+		}
+
+		link := ""
+		if r.Repository != nil {
+			link = deref(r.Repository.Url)
+		}
+		for _, k := range []string{"url", "link", "diagram_link"} {
+			if link == "" {
+				link = stringValue(r.Attributes[k])
+			}
+		}
+
+		switch {
+		case link != "" && i.tm.DiagramLink == "":
+			i.tm.DiagramLink = link
+		case link != "":
+			i.warn("representation %q: only one diagram_link is supported, %s skipped", r.Id, link)
+		}
+	}
+}
+
+func (i *importer) importAssets() {
+	for _, a := range i.o.Assets {
+		ia := &spec.InformationAsset{
+			Name:        a.Name,
+			Description: deref(a.Description),
+		}
+		for _, k := range []string{"information_classification", "classification"} {
+			if ia.InformationClassification == "" {
+				ia.InformationClassification = stringValue(a.Attributes[k])
+			}
+		}
+		ia.Source = stringValue(a.Attributes["source"])
+
+		i.assets[a.Id] = a.Name
+		i.tm.InformationAssets = append(i.tm.InformationAssets, ia)
+	}
+}
+
+// importThreats maps threats, attaching each mitigation referenced by a threat
+// instance (on a component or dataflow) to that threat as a control.
+func (i *importer) importThreats() {
+	mitigations := map[string]otm.OtmSchemaJsonMitigationsElem{}
+	for _, m := range i.o.Mitigations {
+		mitigations[m.Id] = m
+	}
+
+	// threat id -> mitigation ids (in order) and their instance states
+	linked := map[string][]string{}
+	states := map[string]map[string]string{}
+	used := map[string]bool{}
+
+	addInstances := func(instances []otm.Threat) {
+		for _, inst := range instances {
+			if states[inst.Threat] == nil {
+				states[inst.Threat] = map[string]string{}
+			}
+			for _, m := range inst.Mitigations {
+				id := deref(m.Mitigation)
+				if id == "" {
+					continue
+				}
+				if _, seen := states[inst.Threat][id]; !seen {
+					linked[inst.Threat] = append(linked[inst.Threat], id)
+					states[inst.Threat][id] = ""
+				}
+				if deref(m.State) != "" && states[inst.Threat][id] != "implemented" {
+					states[inst.Threat][id] = *m.State
+				}
+				used[id] = true
+			}
+		}
+	}
+	for _, c := range i.o.Components {
+		addInstances(c.Threats)
+	}
+	for _, d := range i.o.Dataflows {
+		addInstances(d.Threats)
+	}
+
+	// Mitigations written by Export name their threat, which links them even
+	// without a threat instance.
+	proposed := map[string][]*spec.ProposedControl{}
+	for _, m := range i.o.Mitigations {
+		tid := stringValue(m.Attributes[AttrThreat])
+		if tid == "" {
+			continue
+		}
+		if boolValue(m.Attributes[AttrProposed]) {
+			proposed[tid] = append(proposed[tid], &spec.ProposedControl{
+				Description: deref(m.Description),
+				Implemented: boolValue(m.Attributes["implemented"]),
+			})
+			used[m.Id] = true
+			continue
+		}
+		addInstances([]otm.Threat{{Threat: tid, Mitigations: []otm.ThreatMitigationsElem{{Mitigation: &m.Id}}}})
+	}
+
+	for _, t := range i.o.Threats {
+		threat := &spec.Threat{
+			Name:        t.Name,
+			Description: deref(t.Description),
+		}
+
+		for _, cat := range t.Categories {
+			c := deref(cat)
+			if s, ok := matchFold(i.opts.Stride, c); ok {
+				threat.Stride = append(threat.Stride, s)
+			} else if it, ok := matchFold(i.opts.ImpactTypes, c); ok {
+				threat.ImpactType = append(threat.ImpactType, it)
+			} else if c != "" {
+				i.warn("threat %q: category %q is neither a STRIDE element nor an impact type, skipped", t.Name, c)
+			}
+		}
+
+		threat.InformationAssetRefs = listValue(t.Attributes["information_asset_refs"])
+		threat.Control = stringValue(t.Attributes["control"])
+		threat.ProposedControls = proposed[t.Id]
+
+		threat.Risk = i.risk(t)
+
+		for _, id := range linked[t.Id] {
+			m, ok := mitigations[id]
+			if !ok {
+				i.warn("threat %q references unknown mitigation %q, skipped", t.Name, id)
+				continue
+			}
+			threat.Controls = append(threat.Controls, i.control(m, states[t.Id][id]))
+		}
+
+		i.tm.Threats = append(i.tm.Threats, threat)
+	}
+
+	// Threat instances referencing threats that don't exist lose their
+	// mitigations along with them.
+	known := map[string]bool{}
+	for _, t := range i.o.Threats {
+		known[t.Id] = true
+	}
+	for _, id := range sortedKeys(linked) {
+		if !known[id] {
+			i.warn("threat instance references unknown threat %q, skipped", id)
+		}
+	}
+
+	var unlinked []*spec.Control
+	for _, m := range i.o.Mitigations {
+		if !used[m.Id] {
+			unlinked = append(unlinked, i.control(m, ""))
+		}
+	}
+	if len(unlinked) > 0 {
+		i.warn("%d mitigation(s) aren't linked to any threat, added to threat %q", len(unlinked), UnlinkedThreatName)
+		i.tm.Threats = append(i.tm.Threats, &spec.Threat{
+			Name:        UnlinkedThreatName,
+			Description: "Mitigations imported from OTM that no threat referenced",
+			Controls:    unlinked,
+		})
+	}
+}
+
+func (i *importer) control(m otm.OtmSchemaJsonMitigationsElem, state string) *spec.Control {
+	c := &spec.Control{
+		Name:          m.Name,
+		Description:   deref(m.Description),
+		RiskReduction: int(m.RiskReduction),
+		Implemented:   state == "implemented",
+	}
+
+	for _, k := range sortedKeys(m.Attributes) {
+		v := m.Attributes[k]
+		switch k {
+		case "implemented":
+			c.Implemented = c.Implemented || boolValue(v)
+		case "implementation_notes":
+			c.ImplementationNotes = stringValue(v)
+		case AttrThreat, AttrProposed:
+		default:
+			c.Attributes = append(c.Attributes, &spec.ControlAttribute{Name: k, Value: stringValue(v)})
+		}
+	}
+	return c
+}
+
+// risk maps OTM's 0-100 impact and likelihood onto the five level scale. A
+// threat without a likelihood (or with a zero impact) isn't rated.
+func (i *importer) risk(t otm.OtmSchemaJsonThreatsElem) *spec.Risk {
+	if t.Risk.Likelihood == nil || *t.Risk.Likelihood < 0 || t.Risk.Impact <= 0 {
+		return nil
+	}
+
+	r := &spec.Risk{
+		Likelihood: level(*t.Risk.Likelihood),
+		Impact:     level(t.Risk.Impact),
+	}
+
+	comments := []string{}
+	if c := deref(t.Risk.LikelihoodComment); c != "" {
+		comments = append(comments, c)
+	}
+	if c := deref(t.Risk.ImpactComment); c != "" {
+		comments = append(comments, c)
+	}
+	r.Rationale = strings.Join(comments, "\n")
+	if rationale := stringValue(t.Attributes["risk_rationale"]); rationale != "" {
+		r.Rationale = rationale
+	}
+
+	return r
+}
+
+// level buckets a 0-100 value into very_low ... very_high.
+func level(v float64) string {
+	switch {
+	case v < 20:
+		return "very_low"
+	case v < 40:
+		return "low"
+	case v < 60:
+		return "medium"
+	case v < 80:
+		return "high"
+	}
+	return "very_high"
+}
+
+// importDiagram builds data_flow_diagram_v2 blocks from trustZones,
+// components and dataflows. Components and dataflows are grouped by their
+// data_flow_diagram attribute (as written by Export); without one they all go
+// into a single diagram named after the project.
+func (i *importer) importDiagram() {
+	if len(i.o.Components) == 0 && len(i.o.Dataflows) == 0 {
+		return
+	}
+
+	for _, z := range i.o.TrustZones {
+		if boolValue(z.Attributes[AttrImplicit]) {
+			i.zones[z.Id] = ""
+			continue
+		}
+		i.zones[z.Id] = z.Name
+	}
+
+	dfds := map[string]*spec.DataFlowDiagram{}
+	diagram := func(attrs map[string]interface{}) *spec.DataFlowDiagram {
+		name := stringValue(attrs[AttrDiagram])
+		if name == "" {
+			name = i.tm.Name
+		}
+		if d, ok := dfds[name]; ok {
+			return d
+		}
+		d := &spec.DataFlowDiagram{Name: name}
+		dfds[name] = d
+		i.tm.DataFlowDiagrams = append(i.tm.DataFlowDiagrams, d)
+		return d
+	}
+
+	// component id -> its diagram, element names are unique per diagram
+	elemDfd := map[string]*spec.DataFlowDiagram{}
+	taken := map[*spec.DataFlowDiagram]map[string]bool{}
+
+	for _, c := range i.o.Components {
+		dfd := diagram(c.Attributes)
+		if taken[dfd] == nil {
+			taken[dfd] = map[string]bool{}
+		}
+
+		name := c.Name
+		if taken[dfd][name] {
+			name = fmt.Sprintf("%s (%s)", c.Name, c.Id)
+			i.warn("component %q: name is already used, imported as %q", c.Id, name)
+		}
+		taken[dfd][name] = true
+		i.elems[c.Id] = name
+		elemDfd[c.Id] = dfd
+
+		zone := i.componentZone(c, 0)
+
+		switch elementKind(c.Type) {
+		case kindDataStore:
+			ds := &spec.DfdData{Name: name, TrustZone: zone}
+			if c.Assets != nil {
+				ds.IaLink = i.assetLink(name, c.Assets.Stored)
+			}
+			dfd.DataStores = append(dfd.DataStores, ds)
+		case kindExternal:
+			dfd.ExternalElements = append(dfd.ExternalElements, &spec.DfdExternal{Name: name, TrustZone: zone})
+		default:
+			dfd.Processes = append(dfd.Processes, &spec.DfdProcess{Name: name, TrustZone: zone})
+		}
+	}
+
+	for _, d := range i.o.Dataflows {
+		from, okFrom := i.elems[d.Source]
+		to, okTo := i.elems[d.Destination]
+		if !okFrom || !okTo {
+			i.warn("dataflow %q: source and destination must both be components, skipped", d.Name)
+			continue
+		}
+		dfd := elemDfd[d.Source]
+		if elemDfd[d.Destination] != dfd {
+			i.warn("dataflow %q: source and destination are in different diagrams, skipped", d.Name)
+			continue
+		}
+
+		protocol := stringValue(d.Attributes["protocol"])
+		dfd.Flows = append(dfd.Flows, &spec.DfdFlow{Name: d.Name, From: from, To: to, Protocol: protocol})
+		if d.Bidirectional != nil && *d.Bidirectional {
+			dfd.Flows = append(dfd.Flows, &spec.DfdFlow{Name: d.Name, From: to, To: from, Protocol: protocol})
+		}
+	}
+}
+
+// componentZone finds the trust zone holding c, following parent components.
+// Nested components are flattened; the DFD has no component hierarchy.
+func (i *importer) componentZone(c otm.OtmSchemaJsonComponentsElem, depth int) string {
+	if tz := deref(c.Parent.TrustZone); tz != "" {
+		name, ok := i.zones[tz]
+		if !ok {
+			i.warn("component %q: unknown trust zone %q", c.Name, tz)
+		}
+		return name
+	}
+
+	parent := deref(c.Parent.Component)
+	if parent == "" || depth > len(i.o.Components) {
+		return ""
+	}
+	if depth == 0 {
+		i.warn("component %q: nested under component %q, flattened", c.Name, parent)
+	}
+	for _, p := range i.o.Components {
+		if p.Id == parent {
+			return i.componentZone(p, depth+1)
+		}
+	}
+	return ""
+}
+
+// assetLink returns the information asset a data store holds. A data store
+// can only link one.
+func (i *importer) assetLink(store string, stored []*string) string {
+	names := []string{}
+	for _, id := range stored {
+		if name, ok := i.assets[deref(id)]; ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 1 {
+		i.warn("data store %q: stores %d assets, only %q is linked", store, len(names), names[0])
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+type elemKind int
+
+const (
+	kindProcess elemKind = iota
+	kindDataStore
+	kindExternal
+)
+
+// elementKind guesses a DFD element kind from an OTM component type, which is
+// free text ("web-service", "postgresql", "generic-client", ...).
+func elementKind(componentType string) elemKind {
+	words := strings.FieldsFunc(strings.ToLower(componentType), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+
+	kind := kindProcess
+	for _, w := range words {
+		switch {
+		case w == "store" || w == "datastore" || w == "database" || w == "storage" ||
+			w == "bucket" || w == "queue" || w == "cache" || w == "s3" || w == "redis" ||
+			strings.HasSuffix(w, "db") || strings.HasSuffix(w, "sql"):
+			return kindDataStore
+		case w == "external" || w == "client" || w == "browser" || w == "user" ||
+			w == "actor" || w == "mobile" || w == "party":
+			kind = kindExternal
+		}
+	}
+	return kind
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func stringValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []interface{}:
+		return strings.Join(listValue(val), ", ")
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func intValue(v interface{}) int64 {
+	switch val := v.(type) {
+	case float64:
+		return int64(val)
+	case string:
+		n, _ := strconv.ParseInt(val, 10, 64)
+		return n
+	}
+	return 0
+}
+
+func boolValue(v interface{}) bool {
+	switch val := v.(type) {
+	case bool:
+		return val
+	case string:
+		return strings.EqualFold(val, "true")
+	}
+	return false
+}
+
+func listValue(v interface{}) []string {
+	switch val := v.(type) {
+	case []interface{}:
+		out := []string{}
+		for _, e := range val {
+			out = append(out, stringValue(e))
+		}
+		return out
+	case string:
+		if val == "" {
+			return nil
+		}
+		return []string{val}
+	}
+	return nil
+}
+
+func matchFold(list []string, s string) (string, bool) {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
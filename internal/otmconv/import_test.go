@@ -0,0 +1,229 @@
+package otmconv
+
+import (
+	"strings"
+	"testing"
+)
+
+var testOpts = Options{
+	Stride:      []string{"Spoofing", "Tampering", "Repudiation", "Info Disclosure", "Denial Of Service", "Elevation Of Privilege"},
+	ImpactTypes: []string{"Confidentiality", "Integrity", "Availability"},
+}
+
+const fullOtm = `{
+  "otmVersion": "0.2.0",
+  "project": {
+    "id": "shop",
+    "name": "Shop",
+    "description": "An online shop",
+    "owner": "@alice",
+    "tags": ["retail"],
+    "attributes": {
+      "initiative_size": "Small",
+      "internet_facing": true,
+      "new_initiative": "false",
+      "network_segment": "dmz"
+    }
+  },
+  "representations": [
+    {"id": "diagram-1", "name": "Shop", "type": "diagram", "attributes": {"url": "https://example.com/shop.png"}},
+    {"id": "mermaid-1", "name": "Checkout", "type": "diagram", "description": "The checkout flow",
+     "attributes": {"format": "mermaid", "content": "sequenceDiagram\n  A->>B: pay"}}
+  ],
+  "trustZones": [
+    {"id": "internet", "name": "Internet", "risk": {"trustRating": 10}},
+    {"id": "dc", "name": "Data Centre", "risk": {"trustRating": 90}}
+  ],
+  "assets": [
+    {"id": "cards", "name": "Card data", "description": "PANs", "risk": {"confidentiality": 100, "integrity": 50, "availability": 10},
+     "attributes": {"information_classification": "Restricted"}}
+  ],
+  "components": [
+    {"id": "browser", "name": "Browser", "type": "generic-client", "parent": {"trustZone": "internet"}},
+    {"id": "web", "name": "Web", "type": "web-service", "parent": {"trustZone": "dc"},
+     "threats": [{"threat": "sqli", "state": "exposed", "mitigations": [{"mitigation": "params", "state": "implemented"}]}]},
+    {"id": "api", "name": "Web", "type": "api", "parent": {"component": "web"}},
+    {"id": "db", "name": "Orders DB", "type": "postgresql", "parent": {"trustZone": "dc"},
+     "assets": {"stored": ["cards"]},
+     "threats": [{"threat": "sqli", "state": "exposed", "mitigations": [{"mitigation": "waf", "state": "required"}]}]}
+  ],
+  "dataflows": [
+    {"id": "f1", "name": "browse", "source": "browser", "destination": "web", "bidirectional": true, "attributes": {"protocol": "HTTPS"}},
+    {"id": "f2", "name": "query", "source": "web", "destination": "db"},
+    {"id": "f3", "name": "odd", "source": "internet", "destination": "web"}
+  ],
+  "threats": [
+    {"id": "sqli", "name": "SQL injection", "description": "Attacker injects SQL",
+     "categories": ["Tampering", "confidentiality", "OWASP A03"],
+     "risk": {"impact": 90, "likelihood": 45, "likelihoodComment": "Public endpoint"}},
+    {"id": "dos", "name": "Flooding", "risk": {"impact": 0, "likelihood": null}}
+  ],
+  "mitigations": [
+    {"id": "params", "name": "Parameterised queries", "description": "Use bind params", "riskReduction": 80,
+     "attributes": {"implementation_notes": "All queries via the ORM", "owasp": "C3"}},
+    {"id": "waf", "name": "WAF", "riskReduction": 30},
+    {"id": "guards", "name": "Guards", "description": "Security guards", "riskReduction": 10, "attributes": {"implemented": true}}
+  ]
+}`
+
+func TestImport(t *testing.T) {
+	docs, err := Parse([]byte(fullOtm))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("expected 1 document, got %d", len(docs))
+	}
+
+	res := Import(docs[0], testOpts)
+	tm := res.Threatmodel
+
+	if tm.Name != "Shop" || tm.Author != "@alice" || tm.Description != "An online shop" {
+		t.Errorf("unexpected project mapping: %+v", tm)
+	}
+	if tm.Attributes == nil || tm.Attributes.InitiativeSize != "Small" || !tm.Attributes.InternetFacing || tm.Attributes.NewInitiative {
+		t.Errorf("unexpected attributes: %+v", tm.Attributes)
+	}
+	if len(tm.AdditionalAttributes) != 2 || tm.AdditionalAttributes[0].Name != "network_segment" || tm.AdditionalAttributes[1].Value != "retail" {
+		t.Errorf("unexpected additional attributes: %+v", tm.AdditionalAttributes)
+	}
+	if tm.DiagramLink != "https://example.com/shop.png" {
+		t.Errorf("unexpected diagram_link: %q", tm.DiagramLink)
+	}
+	if len(tm.MermaidDiagrams) != 1 || tm.MermaidDiagrams[0].Description != "The checkout flow" ||
+		!strings.Contains(tm.MermaidDiagrams[0].Content, "A->>B") {
+		t.Errorf("unexpected mermaid diagrams: %+v", tm.MermaidDiagrams)
+	}
+
+	if len(tm.InformationAssets) != 1 || tm.InformationAssets[0].InformationClassification != "Restricted" {
+		t.Errorf("unexpected information assets: %+v", tm.InformationAssets)
+	}
+
+	if len(tm.Threats) != 3 {
+		t.Fatalf("expected 3 threats (2 + unlinked), got %d", len(tm.Threats))
+	}
+
+	sqli := tm.Threats[0]
+	if len(sqli.Stride) != 1 || sqli.Stride[0] != "Tampering" {
+		t.Errorf("unexpected stride: %v", sqli.Stride)
+	}
+	if len(sqli.ImpactType) != 1 || sqli.ImpactType[0] != "Confidentiality" {
+		t.Errorf("unexpected impacts: %v", sqli.ImpactType)
+	}
+	if sqli.Risk == nil || sqli.Risk.Likelihood != "medium" || sqli.Risk.Impact != "very_high" || sqli.Risk.Rationale != "Public endpoint" {
+		t.Errorf("unexpected risk: %+v", sqli.Risk)
+	}
+	if len(sqli.Controls) != 2 {
+		t.Fatalf("expected 2 controls, got %d", len(sqli.Controls))
+	}
+	params := sqli.Controls[0]
+	if !params.Implemented || params.RiskReduction != 80 || params.ImplementationNotes != "All queries via the ORM" {
+		t.Errorf("unexpected control: %+v", params)
+	}
+	if len(params.Attributes) != 1 || params.Attributes[0].Name != "owasp" {
+		t.Errorf("unexpected control attributes: %+v", params.Attributes)
+	}
+	if sqli.Controls[1].Implemented {
+		t.Errorf("expected the required WAF control to be unimplemented")
+	}
+
+	if tm.Threats[1].Risk != nil {
+		t.Errorf("expected an unrated threat to have no risk")
+	}
+
+	unlinked := tm.Threats[2]
+	if unlinked.Name != UnlinkedThreatName || len(unlinked.Controls) != 1 || !unlinked.Controls[0].Implemented {
+		t.Errorf("unexpected unlinked threat: %+v", unlinked)
+	}
+
+	if len(tm.DataFlowDiagrams) != 1 {
+		t.Fatalf("expected 1 data flow diagram, got %d", len(tm.DataFlowDiagrams))
+	}
+	dfd := tm.DataFlowDiagrams[0]
+	if len(dfd.ExternalElements) != 1 || dfd.ExternalElements[0].TrustZone != "Internet" {
+		t.Errorf("unexpected external elements: %+v", dfd.ExternalElements)
+	}
+	if len(dfd.Processes) != 2 || dfd.Processes[1].Name != "Web (api)" || dfd.Processes[1].TrustZone != "Data Centre" {
+		t.Errorf("unexpected processes: %+v", dfd.Processes)
+	}
+	if len(dfd.DataStores) != 1 || dfd.DataStores[0].IaLink != "Card data" {
+		t.Errorf("unexpected data stores: %+v", dfd.DataStores)
+	}
+	if len(dfd.Flows) != 3 || dfd.Flows[0].Protocol != "HTTPS" || dfd.Flows[1].From != "Web" || dfd.Flows[1].To != "Browser" {
+		t.Errorf("unexpected flows: %+v", dfd.Flows)
+	}
+
+	warnings := strings.Join(res.Warnings, "\n")
+	for _, exp := range []string{
+		`category "OWASP A03"`,
+		`imported as "Web (api)"`,
+		`nested under component "web"`,
+		`dataflow "odd"`,
+		`added to threat "Unlinked mitigations"`,
+	} {
+		if !strings.Contains(warnings, exp) {
+			t.Errorf("expected warnings to contain %q:\n%s", exp, warnings)
+		}
+	}
+}
+
+func TestParseArray(t *testing.T) {
+	docs, err := Parse([]byte(`[
+  {"otmVersion": "0.2.0", "project": {"id": "a", "name": "A"}},
+  {"otmVersion": "0.2.0", "project": {"id": "b", "name": "B"}}
+]`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(docs) != 2 || docs[1].Project.Name != "B" {
+		t.Errorf("unexpected documents: %+v", docs)
+	}
+
+	res := Import(docs[0], testOpts)
+	if len(res.Threatmodel.DataFlowDiagrams) != 0 || len(res.Threatmodel.Threats) != 0 {
+		t.Errorf("expected an empty model, got %+v", res.Threatmodel)
+	}
+	if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "no owner") {
+		t.Errorf("unexpected warnings: %v", res.Warnings)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		exp  string
+	}{
+		{"not_json", "nope", "error parsing otm"},
+		{"no_version", `{"project": {"id": "a", "name": "A"}}`, "otmVersion"},
+		{"no_project", `[{"otmVersion": "0.2.0"}]`, "project"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.in))
+			if err == nil || !strings.Contains(err.Error(), tc.exp) {
+				t.Errorf("expected an error containing %q, got %v", tc.exp, err)
+			}
+		})
+	}
+}
+
+func TestElementKind(t *testing.T) {
+	cases := map[string]elemKind{
+		"data-store":       kindDataStore,
+		"postgresql":       kindDataStore,
+		"aws-dynamodb":     kindDataStore,
+		"s3":               kindDataStore,
+		"generic-client":   kindExternal,
+		"external-element": kindExternal,
+		"web-service":      kindProcess,
+		"feedback-service": kindProcess,
+		"process":          kindProcess,
+	}
+	for in, exp := range cases {
+		if got := elementKind(in); got != exp {
+			t.Errorf("elementKind(%q) = %d, expected %d", in, got, exp)
+		}
+	}
+}
@@ -0,0 +1,519 @@
+// Package redact applies a redaction profile to parsed threat models before
+// they're exported for vendors, auditors or anyone else outside the team.
+//
+// A profile is an HCL file:
+//
+//	mask = "[REDACTED]"
+//
+//	field {
+//	  match  = "^implementation_notes$"
+//	  action = "drop"
+//	}
+//
+//	field {
+//	  match  = "(?i)hostname"
+//	  action = "mask"
+//	}
+//
+//	text {
+//	  match = "[a-z0-9.-]+\\.corp\\.example\\.com"
+//	}
+//
+//	threats {
+//	  max_severity = "medium"
+//	}
+//
+//	information_assets {
+//	  classifications = ["Restricted"]
+//	}
+//
+// field rules match free-text field names (and additional_attribute / control
+// attribute names) by regex, and either drop the value or replace it with the
+// mask. text rules replace matching substrings within any free-text value.
+// Both also apply to the names of threat models, threats, controls,
+// information assets and third party dependencies, and to the names and flow
+// protocols of data flow diagrams, whose field names are name and protocol.
+// Names are masked rather than dropped, and renamed wherever they're referred
+// to, such as information_asset_refs and data stores' information_asset.
+// threats drops threats whose inherent severity is above max_severity, and
+// information_assets drops assets with the listed classifications along with
+// every reference to them. Apply modifies models in place and returns a
+// Report of everything it removed.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmfields"
+)
+
+// Actions a field rule can take.
+const (
+	ActionDrop = "drop"
+	ActionMask = "mask"
+)
+
+// DefaultMask replaces masked values when the profile doesn't set one.
+const DefaultMask = "[REDACTED]"
+
+// Profile is a parsed redaction profile.
+type Profile struct {
+	Mask                 string
+	Fields               []*FieldRule
+	Text                 []*regexp.Regexp
+	MaxThreatSeverity    string
+	AssetClassifications []string
+}
+
+// FieldRule drops or masks every field whose name matches Match.
+type FieldRule struct {
+	Match  *regexp.Regexp
+	Action string
+}
+
+// Removal records one thing a profile removed or masked.
+type Removal struct {
+	Threatmodel string
+	Path        string
+	Action      string
+	Reason      string
+}
+
+// Report lists every removal made by Apply.
+type Report struct {
+	Removals []Removal
+}
+
+type profileHCL struct {
+	Mask    *string     `hcl:"mask,optional"`
+	Fields  []*fieldHCL `hcl:"field,block"`
+	Text    []*textHCL  `hcl:"text,block"`
+	Threats *threatsHCL `hcl:"threats,block"`
+	Assets  *assetsHCL  `hcl:"information_assets,block"`
+}
+
+type fieldHCL struct {
+	Match  string `hcl:"match"`
+	Action string `hcl:"action"`
+}
+
+type textHCL struct {
+	Match string `hcl:"match"`
+}
+
+type threatsHCL struct {
+	MaxSeverity string `hcl:"max_severity"`
+}
+
+type assetsHCL struct {
+	Classifications []string `hcl:"classifications"`
+}
+
+// ParseFile reads and validates a redaction profile.
+func ParseFile(path string) (*Profile, error) {
+	parser := hclparse.NewParser()
+	f, diags := parser.ParseHCLFile(path)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	raw := &profileHCL{}
+	if diags := gohcl.DecodeBody(f.Body, nil, raw); diags.HasErrors() {
+		return nil, diags
+	}
+
+	p := &Profile{Mask: DefaultMask}
+	if raw.Mask != nil {
+		p.Mask = *raw.Mask
+	}
+
+	for _, fr := range raw.Fields {
+		re, err := regexp.Compile(fr.Match)
+		if err != nil {
+			return nil, fmt.Errorf("field match %q: %s", fr.Match, err)
+		}
+		if fr.Action != ActionDrop && fr.Action != ActionMask {
+			return nil, fmt.Errorf("field match %q: action must be %q or %q, not %q", fr.Match, ActionDrop, ActionMask, fr.Action)
+		}
+		p.Fields = append(p.Fields, &FieldRule{Match: re, Action: fr.Action})
+	}
+
+	for _, tr := range raw.Text {
+		re, err := regexp.Compile(tr.Match)
+		if err != nil {
+			return nil, fmt.Errorf("text match %q: %s", tr.Match, err)
+		}
+		p.Text = append(p.Text, re)
+	}
+
+	if raw.Threats != nil {
+		if severityRank(raw.Threats.MaxSeverity) < 0 {
+			return nil, fmt.Errorf("threats max_severity must be one of %s, not %q",
+				strings.Join(spec.SeverityLevels, ", "), raw.Threats.MaxSeverity)
+		}
+		p.MaxThreatSeverity = raw.Threats.MaxSeverity
+	}
+
+	if raw.Assets != nil {
+		p.AssetClassifications = raw.Assets.Classifications
+	}
+
+	return p, nil
+}
+
+// Apply redacts every model in place and reports what it removed.
+func (p *Profile) Apply(tms []*spec.Threatmodel) *Report {
+	r := &Report{}
+	models := newRenames()
+	for _, tm := range tms {
+		p.applyOne(tm, models, r)
+	}
+	return r
+}
+
+// applyOne redacts tm, recording its redacted name in models so models
+// masked alike are kept apart.
+func (p *Profile) applyOne(tm *spec.Threatmodel, models *renames, r *Report) {
+	name := tm.Name
+	add := func(path, action, reason string) {
+		r.Removals = append(r.Removals, Removal{Threatmodel: name, Path: path, Action: action, Reason: reason})
+	}
+
+	// Blocks first, so field rules don't report on content that's dropped
+	// anyway.
+	if p.MaxThreatSeverity != "" {
+		max := severityRank(p.MaxThreatSeverity)
+		kept := tm.Threats[:0]
+		for _, t := range tm.Threats {
+			if t.Risk != nil {
+				if sev := t.Risk.Severity(); severityRank(sev) > max {
+					add(fmt.Sprintf("threat %q", t.Name), ActionDrop,
+						fmt.Sprintf("severity %s is above %s", sev, p.MaxThreatSeverity))
+					continue
+				}
+			}
+			kept = append(kept, t)
+		}
+		tm.Threats = kept
+	}
+
+	if len(p.AssetClassifications) > 0 {
+		dropped := map[string]bool{}
+		kept := tm.InformationAssets[:0]
+		for _, ia := range tm.InformationAssets {
+			if containsFold(p.AssetClassifications, ia.InformationClassification) {
+				dropped[ia.Name] = true
+				add(fmt.Sprintf("information_asset %q", ia.Name), ActionDrop,
+					fmt.Sprintf("classified %s", ia.InformationClassification))
+				continue
+			}
+			kept = append(kept, ia)
+		}
+		tm.InformationAssets = kept
+
+		if len(dropped) > 0 {
+			dropAssetRefs(tm, dropped)
+		}
+	}
+
+	tmfields.Walk(tm, func(f *tmfields.Field) bool {
+		for _, fr := range p.Fields {
+			if !fr.Match.MatchString(f.Name) {
+				continue
+			}
+			add(f.Path, fr.Action, fmt.Sprintf("field matches %s", fr.Match))
+			if fr.Action == ActionDrop {
+				return true
+			}
+			*f.Value = p.Mask
+			return false
+		}
+
+		for _, re := range p.Text {
+			if re.MatchString(*f.Value) {
+				*f.Value = re.ReplaceAllLiteralString(*f.Value, p.Mask)
+				add(f.Path, ActionMask, fmt.Sprintf("text matches %s", re))
+			}
+		}
+		return false
+	})
+
+	p.redactNames(tm, models, add)
+	p.redactDFDs(tm, add)
+}
+
+// redactNames applies the field and text rules to the names of tm and its
+// threats, controls, information assets and third party dependencies, which
+// tmfields.Walk leaves alone as identifiers. Like the names in data flow
+// diagrams, they're masked, never dropped, and numbered apart where needed;
+// references to renamed information assets follow them.
+func (p *Profile) redactNames(tm *spec.Threatmodel, models *renames, add func(path, action, reason string)) {
+	if len(p.Fields) == 0 && len(p.Text) == 0 {
+		return
+	}
+
+	models.add(tm.Name, p.redactName("threatmodel", tm.Name, add))
+	tm.Name = models.get(tm.Name)
+
+	assets := newRenames()
+	for _, ia := range tm.InformationAssets {
+		assets.add(ia.Name, p.redactName(fmt.Sprintf("information_asset %q", ia.Name), ia.Name, add))
+		ia.Name = assets.get(ia.Name)
+	}
+
+	deps := newRenames()
+	for _, tpd := range tm.ThirdPartyDependencies {
+		deps.add(tpd.Name, p.redactName(fmt.Sprintf("third_party_dependency %q", tpd.Name), tpd.Name, add))
+		tpd.Name = deps.get(tpd.Name)
+	}
+
+	threats := newRenames()
+	for _, t := range tm.Threats {
+		path := fmt.Sprintf("threat %q", t.Name)
+		threats.add(t.Name, p.redactName(path, t.Name, add))
+		t.Name = threats.get(t.Name)
+
+		// a control imported under the same name as one of the threat's
+		// own is the same control, so they're renamed alike, and one that
+		// appears in both lists is only renamed once
+		controls := newRenames()
+		seen := map[*spec.Control]bool{}
+		for _, cs := range [][]*spec.Control{t.Controls, t.ExpandedControls} {
+			for _, c := range cs {
+				if seen[c] {
+					continue
+				}
+				seen[c] = true
+				if _, ok := controls.to[c.Name]; !ok {
+					controls.add(c.Name, p.redactName(fmt.Sprintf("%s > control %q", path, c.Name), c.Name, add))
+				}
+				c.Name = controls.get(c.Name)
+			}
+		}
+
+		for i, ref := range t.InformationAssetRefs {
+			t.InformationAssetRefs[i] = assets.get(ref)
+		}
+	}
+
+	relink := func(stores []*spec.DfdData) {
+		for _, ds := range stores {
+			ds.IaLink = assets.get(ds.IaLink)
+		}
+	}
+	for _, d := range tm.DataFlowDiagrams {
+		relink(d.DataStores)
+		for _, z := range d.TrustZones {
+			relink(z.DataStores)
+		}
+	}
+}
+
+// redactDFDs applies the field and text rules to the names and protocols of
+// data flow diagrams, which tmfields.Walk leaves alone as identifiers. Their
+// field names are name and protocol. Names are masked, never dropped, and
+// renamed consistently so flows and trust zones still refer to them; names
+// masked alike are numbered to keep them apart.
+func (p *Profile) redactDFDs(tm *spec.Threatmodel, add func(path, action, reason string)) {
+	if len(p.Fields) == 0 && len(p.Text) == 0 {
+		return
+	}
+
+	for _, d := range tm.DataFlowDiagrams {
+		path := fmt.Sprintf("data_flow_diagram %q", d.Name)
+		p.redactValue(path, "name", &d.Name, false, add)
+
+		zones := newRenames()
+		for _, z := range d.TrustZones {
+			zones.add(z.Name, p.redactName(fmt.Sprintf("%s > trust_zone %q", path, z.Name), z.Name, add))
+		}
+		for _, z := range d.TrustZones {
+			z.Name = zones.get(z.Name)
+		}
+
+		// elements maps every element name onto its redacted name
+		elements := newRenames()
+		element := func(kind string, name, zone *string) {
+			elements.add(*name, p.redactName(fmt.Sprintf("%s > %s %q", path, kind, *name), *name, add))
+			*zone = zones.get(*zone)
+		}
+		each := func(ps []*spec.DfdProcess, ds []*spec.DfdData, ees []*spec.DfdExternal) {
+			for _, pr := range ps {
+				element("process", &pr.Name, &pr.TrustZone)
+			}
+			for _, s := range ds {
+				element("data_store", &s.Name, &s.TrustZone)
+			}
+			for _, ee := range ees {
+				element("external_element", &ee.Name, &ee.TrustZone)
+			}
+		}
+		each(d.Processes, d.DataStores, d.ExternalElements)
+		for _, z := range d.TrustZones {
+			each(z.Processes, z.DataStores, z.ExternalElements)
+		}
+		rename := func(ps []*spec.DfdProcess, ds []*spec.DfdData, ees []*spec.DfdExternal) {
+			for _, pr := range ps {
+				pr.Name = elements.get(pr.Name)
+			}
+			for _, s := range ds {
+				s.Name = elements.get(s.Name)
+			}
+			for _, ee := range ees {
+				ee.Name = elements.get(ee.Name)
+			}
+		}
+		rename(d.Processes, d.DataStores, d.ExternalElements)
+		for _, z := range d.TrustZones {
+			rename(z.Processes, z.DataStores, z.ExternalElements)
+		}
+
+		for _, f := range d.Flows {
+			fp := fmt.Sprintf("%s > flow %q", path, f.Name)
+			p.redactValue(fp, "name", &f.Name, false, add)
+			p.redactValue(fp, "protocol", &f.Protocol, true, add)
+			f.From, f.To = elements.get(f.From), elements.get(f.To)
+		}
+	}
+}
+
+// redactName returns the redacted value of the identifier name.
+func (p *Profile) redactName(path, name string, add func(path, action, reason string)) string {
+	p.redactValue(path, "name", &name, false, add)
+	return name
+}
+
+// redactValue applies the rules to the DFD value v of field, as the walk
+// over free-text fields does. Unless droppable, a drop rule masks v.
+func (p *Profile) redactValue(path, field string, v *string, droppable bool, add func(path, action, reason string)) {
+	if *v == "" {
+		return
+	}
+	path += " > " + field
+
+	for _, fr := range p.Fields {
+		if !fr.Match.MatchString(field) {
+			continue
+		}
+		if fr.Action == ActionDrop && droppable {
+			*v = ""
+			add(path, ActionDrop, fmt.Sprintf("field matches %s", fr.Match))
+			return
+		}
+		*v = p.Mask
+		add(path, ActionMask, fmt.Sprintf("field matches %s", fr.Match))
+		return
+	}
+
+	for _, re := range p.Text {
+		if re.MatchString(*v) {
+			*v = re.ReplaceAllLiteralString(*v, p.Mask)
+			add(path, ActionMask, fmt.Sprintf("text matches %s", re))
+		}
+	}
+}
+
+// renames maps the names of a diagram's elements or trust zones onto their
+// redacted names, keeping them unique.
+type renames struct {
+	to    map[string]string
+	taken map[string]bool
+}
+
+func newRenames() *renames {
+	return &renames{to: map[string]string{}, taken: map[string]bool{}}
+}
+
+// add records that name is redacted to redacted, numbering redacted if
+// another name already has it.
+func (r *renames) add(name, redacted string) {
+	if _, ok := r.to[name]; ok {
+		return
+	}
+	unique := redacted
+	for i := 2; r.taken[unique]; i++ {
+		unique = fmt.Sprintf("%s %d", redacted, i)
+	}
+	r.to[name] = unique
+	r.taken[unique] = true
+}
+
+// get returns name's redacted name, or name if it isn't known.
+func (r *renames) get(name string) string {
+	if to, ok := r.to[name]; ok {
+		return to
+	}
+	return name
+}
+
+// dropAssetRefs removes references to dropped information assets, so their
+// names don't leak through threats or data flow diagrams.
+func dropAssetRefs(tm *spec.Threatmodel, dropped map[string]bool) {
+	for _, t := range tm.Threats {
+		refs := t.InformationAssetRefs[:0]
+		for _, ref := range t.InformationAssetRefs {
+			if !dropped[ref] {
+				refs = append(refs, ref)
+			}
+		}
+		t.InformationAssetRefs = refs
+	}
+
+	clearLinks := func(stores []*spec.DfdData) {
+		for _, ds := range stores {
+			if dropped[ds.IaLink] {
+				ds.IaLink = ""
+			}
+		}
+	}
+	for _, d := range tm.DataFlowDiagrams {
+		clearLinks(d.DataStores)
+		for _, z := range d.TrustZones {
+			clearLinks(z.DataStores)
+		}
+	}
+}
+
+// String formats the report one removal per line.
+func (r *Report) String() string {
+	if len(r.Removals) == 0 {
+		return "Redaction removed nothing\n"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Redaction removed or masked %d item(s):\n", len(r.Removals))
+	for _, rm := range r.Removals {
+		fmt.Fprintf(&b, "  threatmodel %q: %s %s (%s)\n", rm.Threatmodel, rm.Path, pastTense(rm.Action), rm.Reason)
+	}
+	return b.String()
+}
+
+func pastTense(action string) string {
+	if action == ActionMask {
+		return "masked"
+	}
+	return "dropped"
+}
+
+func severityRank(s string) int {
+	for i, l := range spec.SeverityLevels {
+		if strings.EqualFold(l, s) {
+			return i
+		}
+	}
+	return -1
+}
+
+func containsFold(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,319 @@
+package redact
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+func writeProfile(tb testing.TB, src string) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "redact.hcl")
+	if err := os.WriteFile(path, []byte(src), 0600); err != nil {
+		tb.Fatalf("Error writing profile: %s", err)
+	}
+	return path
+}
+
+func testModel() *spec.Threatmodel {
+	return &spec.Threatmodel{
+		Name:        "tm",
+		Description: "Runs on db01.corp.example.com",
+		AdditionalAttributes: []*spec.AdditionalAttribute{
+			{Name: "internal_hostname", Value: "db01.corp.example.com"},
+			{Name: "team", Value: "payments"},
+		},
+		InformationAssets: []*spec.InformationAsset{
+			{Name: "card data", InformationClassification: "Restricted"},
+			{Name: "logs", InformationClassification: "Internal"},
+		},
+		Threats: []*spec.Threat{
+			{
+				Name:                 "leak",
+				Description:          "Card data leaks",
+				InformationAssetRefs: []string{"card data", "logs"},
+				Controls: []*spec.Control{
+					{
+						Name:                "encryption",
+						Description:         "Encrypt at rest",
+						ImplementationNotes: "Not done yet, see ticket 123",
+					},
+				},
+			},
+		},
+		DataFlowDiagrams: []*spec.DataFlowDiagram{
+			{
+				Name:       "dfd",
+				DataStores: []*spec.DfdData{{Name: "db", IaLink: "card data"}},
+			},
+		},
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	p, err := ParseFile(writeProfile(t, `
+mask = "***"
+
+field {
+  match  = "^implementation_notes$"
+  action = "drop"
+}
+
+text {
+  match = "[a-z0-9.-]+\\.corp\\.example\\.com"
+}
+
+threats {
+  max_severity = "medium"
+}
+
+information_assets {
+  classifications = ["Restricted"]
+}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if p.Mask != "***" || len(p.Fields) != 1 || len(p.Text) != 1 {
+		t.Errorf("unexpected profile: %+v", p)
+	}
+	if p.MaxThreatSeverity != "medium" || len(p.AssetClassifications) != 1 {
+		t.Errorf("unexpected block rules: %+v", p)
+	}
+}
+
+func TestParseFileInvalid(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		exp  string
+	}{
+		{"bad_action", "field {\n match = \"x\"\n action = \"hide\"\n}", `action must be "drop" or "mask"`},
+		{"bad_regex", "field {\n match = \"(\"\n action = \"drop\"\n}", "field match"},
+		{"bad_severity", "threats {\n max_severity = \"urgent\"\n}", "max_severity must be one of"},
+		{"unknown_attr", `redact = true`, "Unsupported argument"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseFile(writeProfile(t, tc.src))
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if !strings.Contains(err.Error(), tc.exp) {
+				t.Errorf("expected %q to contain %q", err, tc.exp)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	p, err := ParseFile(writeProfile(t, `
+field {
+  match  = "^implementation_notes$"
+  action = "drop"
+}
+
+field {
+  match  = "(?i)hostname"
+  action = "drop"
+}
+
+text {
+  match = "[a-z0-9.-]+\\.corp\\.example\\.com"
+}
+
+information_assets {
+  classifications = ["restricted"]
+}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tm := testModel()
+	report := p.Apply([]*spec.Threatmodel{tm})
+
+	if tm.Threats[0].Controls[0].ImplementationNotes != "" {
+		t.Errorf("expected implementation_notes to be dropped")
+	}
+	if len(tm.AdditionalAttributes) != 1 || tm.AdditionalAttributes[0].Name != "team" {
+		t.Errorf("expected the hostname attribute to be dropped, got %+v", tm.AdditionalAttributes)
+	}
+	if tm.Description != "Runs on [REDACTED]" {
+		t.Errorf("expected the hostname to be masked, got %q", tm.Description)
+	}
+	if len(tm.InformationAssets) != 1 || tm.InformationAssets[0].Name != "logs" {
+		t.Errorf("expected the Restricted asset to be dropped, got %+v", tm.InformationAssets)
+	}
+	if refs := tm.Threats[0].InformationAssetRefs; len(refs) != 1 || refs[0] != "logs" {
+		t.Errorf("expected references to the dropped asset to go, got %v", refs)
+	}
+	if tm.DataFlowDiagrams[0].DataStores[0].IaLink != "" {
+		t.Errorf("expected the data store's asset link to be cleared")
+	}
+
+	if len(report.Removals) != 4 {
+		t.Errorf("expected 4 removals, got %d:\n%s", len(report.Removals), report)
+	}
+	out := report.String()
+	for _, exp := range []string{
+		`information_asset "card data" dropped (classified Restricted)`,
+		`threat "leak" > control "encryption" > implementation_notes dropped`,
+		`additional_attribute "internal_hostname" dropped`,
+		`description masked`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("expected report to contain %q:\n%s", exp, out)
+		}
+	}
+}
+
+func TestApplyThreatSeverity(t *testing.T) {
+	p := &Profile{Mask: DefaultMask, MaxThreatSeverity: spec.SeverityInfo}
+
+	tm := &spec.Threatmodel{
+		Name: "tm",
+		Threats: []*spec.Threat{
+			{Name: "serious", Risk: &spec.Risk{Likelihood: "very_high", Impact: "very_high"}},
+			{Name: "unrated"},
+		},
+	}
+
+	report := p.Apply([]*spec.Threatmodel{tm})
+
+	if len(tm.Threats) != 1 || tm.Threats[0].Name != "unrated" {
+		t.Errorf("expected only the unrated threat to remain, got %d threats", len(tm.Threats))
+	}
+	if len(report.Removals) != 1 || !strings.Contains(report.String(), `threat "serious" dropped`) {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
+
+func TestApplyDFDs(t *testing.T) {
+	p, err := ParseFile(writeProfile(t, `
+field {
+  match  = "^protocol$"
+  action = "drop"
+}
+
+text {
+  match = "[a-z0-9.-]+\\.corp\\.example\\.com"
+}
+
+information_assets {
+  classifications = ["Restricted"]
+}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tm := testModel()
+	tm.DataFlowDiagrams[0].TrustZones = []*spec.DfdTrustZone{
+		{
+			Name:       "dc1.corp.example.com",
+			Processes:  []*spec.DfdProcess{{Name: "api.corp.example.com"}},
+			DataStores: []*spec.DfdData{{Name: "db01.corp.example.com", IaLink: "card data"}},
+		},
+	}
+	tm.DataFlowDiagrams[0].Flows = []*spec.DfdFlow{
+		{Name: "query db01.corp.example.com", From: "api.corp.example.com", To: "db01.corp.example.com", Protocol: "postgres"},
+		{Name: "read", From: "db", To: "api.corp.example.com"},
+	}
+	report := p.Apply([]*spec.Threatmodel{tm})
+
+	d := tm.DataFlowDiagrams[0]
+	zone := d.TrustZones[0]
+	if zone.DataStores[0].IaLink != "" {
+		t.Errorf("expected the zoned data store's asset link to be cleared")
+	}
+	if zone.Name != "[REDACTED]" || zone.Processes[0].Name != "[REDACTED]" || zone.DataStores[0].Name != "[REDACTED] 2" {
+		t.Errorf("expected the zone to be masked and its elements masked apart, got %q, %q and %q",
+			zone.Name, zone.Processes[0].Name, zone.DataStores[0].Name)
+	}
+	if f := d.Flows[0]; f.Name != "query [REDACTED]" || f.From != "[REDACTED]" || f.To != "[REDACTED] 2" || f.Protocol != "" {
+		t.Errorf("expected the flow to follow the renamed elements, got %+v", f)
+	}
+	if f := d.Flows[1]; f.From != "db" || f.To != "[REDACTED]" {
+		t.Errorf("expected the flow to follow the renamed process, got %+v", f)
+	}
+
+	out := report.String()
+	for _, exp := range []string{
+		`data_flow_diagram "dfd" > trust_zone "dc1.corp.example.com" > name masked`,
+		`data_flow_diagram "dfd" > data_store "db01.corp.example.com" > name masked`,
+		`data_flow_diagram "dfd" > flow "query db01.corp.example.com" > protocol dropped (field matches ^protocol$)`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("expected report to contain %q:\n%s", exp, out)
+		}
+	}
+}
+
+func TestApplyNames(t *testing.T) {
+	p, err := ParseFile(writeProfile(t, `
+text {
+  match = "[a-z0-9.-]+\\.corp\\.example\\.com"
+}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tm := testModel()
+	tm.Name = "tm for db01.corp.example.com"
+	tm.InformationAssets[0].Name = "db01.corp.example.com card data"
+	tm.InformationAssets[1].Name = "db02.corp.example.com card data"
+	tm.ThirdPartyDependencies = []*spec.ThirdPartyDependency{{Name: "vault.corp.example.com"}}
+	tm.Threats[0].Name = "leak from db01.corp.example.com"
+	tm.Threats[0].InformationAssetRefs = []string{"db01.corp.example.com card data", "db02.corp.example.com card data"}
+	tm.Threats[0].Controls[0].Name = "encrypt db01.corp.example.com"
+	tm.DataFlowDiagrams[0].DataStores[0].IaLink = "db02.corp.example.com card data"
+
+	other := testModel()
+	other.Name = "tm for db02.corp.example.com"
+	report := p.Apply([]*spec.Threatmodel{tm, other})
+
+	if tm.Name != "tm for [REDACTED]" || other.Name != "tm for [REDACTED] 2" {
+		t.Errorf("expected the models to be masked apart, got %q and %q", tm.Name, other.Name)
+	}
+	if a, b := tm.InformationAssets[0].Name, tm.InformationAssets[1].Name; a != "[REDACTED] card data" || b != "[REDACTED] card data 2" {
+		t.Errorf("expected the assets to be masked apart, got %q and %q", a, b)
+	}
+	if n := tm.ThirdPartyDependencies[0].Name; n != "[REDACTED]" {
+		t.Errorf("expected the dependency to be masked, got %q", n)
+	}
+	th := tm.Threats[0]
+	if th.Name != "leak from [REDACTED]" || th.Controls[0].Name != "encrypt [REDACTED]" {
+		t.Errorf("expected the threat and control to be masked, got %q and %q", th.Name, th.Controls[0].Name)
+	}
+	if refs := strings.Join(th.InformationAssetRefs, ", "); refs != "[REDACTED] card data, [REDACTED] card data 2" {
+		t.Errorf("expected the asset refs to follow the renamed assets, got %s", refs)
+	}
+	if l := tm.DataFlowDiagrams[0].DataStores[0].IaLink; l != "[REDACTED] card data 2" {
+		t.Errorf("expected the data store to follow the renamed asset, got %q", l)
+	}
+
+	out := report.String()
+	for _, exp := range []string{
+		`threatmodel "tm for db02.corp.example.com": threatmodel > name masked`,
+		`information_asset "db01.corp.example.com card data" > name masked`,
+		`threat "leak from db01.corp.example.com" > control "encrypt db01.corp.example.com" > name masked`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("expected report to contain %q:\n%s", exp, out)
+		}
+	}
+}
+
+func TestReportEmpty(t *testing.T) {
+	r := (&Profile{Mask: DefaultMask}).Apply([]*spec.Threatmodel{testModel()})
+	if r.String() != "Redaction removed nothing\n" {
+		t.Errorf("unexpected report: %s", r)
+	}
+}
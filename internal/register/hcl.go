@@ -0,0 +1,204 @@
+package register
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/threatcl/spec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Apply writes the changes in res into HCL source, leaving the rest of the
+// file (comments, layout) untouched. Added models, threats and controls are
+// written in full from res.Threatmodels. Changes to blocks the file doesn't
+// declare itself (threats from another file, controls expanded from a
+// library reference) can't be written and are returned as warnings.
+func Apply(src []byte, filename string, res *Result) ([]byte, []string, error) {
+	f, diags := hclwrite.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, nil, diags
+	}
+
+	warnings := []string{}
+	added := map[string]bool{}
+
+	for _, c := range res.Changes {
+		tm, threat, control := res.find(c)
+
+		switch c.Kind {
+		case ModelAdded:
+			if len(f.Body().Blocks()) > 0 || len(f.Body().Attributes()) > 0 {
+				f.Body().AppendNewline()
+			}
+			f.Body().AppendBlock(modelBlock(tm))
+			added[c.Model] = true
+			continue
+		}
+		if added[c.Model] || added[c.Model+"\x00"+c.Threat] {
+			continue
+		}
+
+		tmBlock := findLabelledBlock(f.Body(), "threatmodel", c.Model)
+		if tmBlock == nil {
+			warnings = append(warnings, fmt.Sprintf("%s: threatmodel isn't declared in %s, not written", c, filename))
+			continue
+		}
+
+		if c.Kind == ThreatAdded {
+			tmBlock.Body().AppendNewline()
+			tmBlock.Body().AppendBlock(threatBlock(threat))
+			added[c.Model+"\x00"+c.Threat] = true
+			continue
+		}
+
+		threatBlk := findLabelledBlock(tmBlock.Body(), "threat", c.Threat)
+		if threatBlk == nil {
+			warnings = append(warnings, fmt.Sprintf("%s: threat isn't declared in %s, not written", c, filename))
+			continue
+		}
+
+		if c.Kind == ControlAdded {
+			threatBlk.Body().AppendNewline()
+			threatBlk.Body().AppendBlock(controlBlock(control))
+			continue
+		}
+
+		body := threatBlk.Body()
+		if c.Control != "" {
+			controlBlk := findLabelledBlock(body, "control", c.Control)
+			if controlBlk == nil {
+				warnings = append(warnings, fmt.Sprintf("%s: control isn't declared in %s, not written", c, filename))
+				continue
+			}
+			body = controlBlk.Body()
+		}
+
+		switch c.Field {
+		case "description":
+			body.SetAttributeValue("description", cty.StringVal(c.New))
+		case "stride":
+			setList(body, "stride", threat.Stride)
+		case "impacts":
+			setList(body, "impacts", threat.ImpactType)
+		case "information_asset_refs":
+			setList(body, "information_asset_refs", threat.InformationAssetRefs)
+		case "likelihood", "impact":
+			risk := findBlock(body, "risk")
+			if risk == nil {
+				body.AppendNewline()
+				risk = body.AppendNewBlock("risk", nil)
+			}
+			risk.Body().SetAttributeValue(c.Field, cty.StringVal(c.New))
+		case "risk":
+			if risk := findBlock(body, "risk"); risk != nil {
+				body.RemoveBlock(risk)
+			}
+		case "implemented":
+			body.SetAttributeValue("implemented", cty.BoolVal(control.Implemented))
+		case "risk_reduction":
+			body.SetAttributeValue("risk_reduction", cty.NumberIntVal(int64(control.RiskReduction)))
+		}
+	}
+
+	return f.Bytes(), warnings, nil
+}
+
+// find returns the merged model, threat and control a change refers to.
+func (r *Result) find(c Change) (*spec.Threatmodel, *spec.Threat, *spec.Control) {
+	for _, tm := range r.Threatmodels {
+		if tm.Name != c.Model {
+			continue
+		}
+		for _, t := range tm.Threats {
+			if t.Name != c.Threat {
+				continue
+			}
+			for _, ctrl := range append(append([]*spec.Control{}, t.Controls...), t.ExpandedControls...) {
+				if ctrl.Name == c.Control {
+					return tm, t, ctrl
+				}
+			}
+			return tm, t, nil
+		}
+		return tm, nil, nil
+	}
+	return nil, nil, nil
+}
+
+func modelBlock(tm *spec.Threatmodel) *hclwrite.Block {
+	b := hclwrite.NewBlock("threatmodel", []string{tm.Name})
+	b.Body().SetAttributeValue("author", cty.StringVal(tm.Author))
+	for _, t := range tm.Threats {
+		b.Body().AppendNewline()
+		b.Body().AppendBlock(threatBlock(t))
+	}
+	return b
+}
+
+func threatBlock(t *spec.Threat) *hclwrite.Block {
+	b := hclwrite.NewBlock("threat", []string{t.Name})
+	body := b.Body()
+	body.SetAttributeValue("description", cty.StringVal(t.Description))
+	setList(body, "impacts", t.ImpactType)
+	setList(body, "stride", t.Stride)
+	setList(body, "information_asset_refs", t.InformationAssetRefs)
+
+	if t.Risk != nil {
+		body.AppendNewline()
+		risk := body.AppendNewBlock("risk", nil)
+		risk.Body().SetAttributeValue("likelihood", cty.StringVal(t.Risk.Likelihood))
+		risk.Body().SetAttributeValue("impact", cty.StringVal(t.Risk.Impact))
+	}
+
+	for _, c := range t.Controls {
+		body.AppendNewline()
+		body.AppendBlock(controlBlock(c))
+	}
+	return b
+}
+
+func controlBlock(c *spec.Control) *hclwrite.Block {
+	b := hclwrite.NewBlock("control", []string{c.Name})
+	b.Body().SetAttributeValue("implemented", cty.BoolVal(c.Implemented))
+	b.Body().SetAttributeValue("description", cty.StringVal(c.Description))
+	if c.RiskReduction != 0 {
+		b.Body().SetAttributeValue("risk_reduction", cty.NumberIntVal(int64(c.RiskReduction)))
+	}
+	return b
+}
+
+// setList sets a list of strings attribute, or removes it when empty.
+func setList(body *hclwrite.Body, name string, values []string) {
+	if len(values) == 0 {
+		body.RemoveAttribute(name)
+		return
+	}
+	vals := []cty.Value{}
+	for _, v := range values {
+		vals = append(vals, cty.StringVal(v))
+	}
+	body.SetAttributeValue(name, cty.ListVal(vals))
+}
+
+func findLabelledBlock(body *hclwrite.Body, blockType, label string) *hclwrite.Block {
+	for _, b := range body.Blocks() {
+		if b.Type() != blockType {
+			continue
+		}
+		labels := b.Labels()
+		if len(labels) > 0 && labels[0] == label {
+			return b
+		}
+	}
+	return nil
+}
+
+func findBlock(body *hclwrite.Body, blockType string) *hclwrite.Block {
+	for _, b := range body.Blocks() {
+		if b.Type() == blockType {
+			return b
+		}
+	}
+	return nil
+}
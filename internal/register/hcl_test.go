@@ -0,0 +1,107 @@
+package register
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+const applySrc = `spec_version = "0.1.0"
+
+// The shop
+threatmodel "Shop" {
+  author = "@alice"
+
+  threat "SQL injection" {
+    description = "Attacker injects SQL"
+    stride      = ["Tampering"] // keep me
+
+    risk {
+      likelihood = "medium"
+      impact     = "very_high"
+    }
+
+    control "WAF" {
+      implemented = false
+      description = "Filter requests"
+    }
+  }
+
+  threat "Flooding" {
+    description = "Too many requests"
+  }
+}
+`
+
+func TestApply(t *testing.T) {
+	in := `model,threat,stride,likelihood,impact,control,implemented,risk_reduction
+Shop,SQL injection,Tampering,high,very_high,WAF,true,30
+Shop,Flooding,Denial Of Service,low,low,,,
+Shop,Sniffing,Info Disclosure,,,TLS,true,50
+Warehouse,Theft,,,,,,
+Elsewhere,Thing,,,,,,
+`
+	rows, err := Read(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tm := &spec.Threatmodel{
+		Name: "Shop",
+		Threats: []*spec.Threat{
+			{
+				Name:        "SQL injection",
+				Description: "Attacker injects SQL",
+				Stride:      []string{"Tampering"},
+				Risk:        &spec.Risk{Likelihood: "medium", Impact: "very_high"},
+				Controls:    []*spec.Control{{Name: "WAF", Description: "Filter requests"}},
+			},
+			{Name: "Flooding", Description: "Too many requests"},
+		},
+	}
+	res := Merge([]*spec.Threatmodel{tm}, rows, testOpts)
+
+	// A change to a model the file doesn't declare can't be written.
+	res.Changes = append(res.Changes, Change{Kind: FieldChanged, Model: "Nowhere", Threat: "T", Field: "stride"})
+
+	out, warnings, err := Apply([]byte(applySrc), "shop.hcl", res)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := string(out)
+
+	for _, exp := range []string{
+		"// The shop\n",
+		`stride      = ["Tampering"] // keep me`,
+		`likelihood = "high"`,
+		"implemented    = true\n      description    = \"Filter requests\"\n      risk_reduction = 30",
+		"threat \"Flooding\" {\n    description = \"Too many requests\"\n    stride      = [\"Denial Of Service\"]\n\n    risk {\n      likelihood = \"low\"\n      impact     = \"low\"\n    }\n  }",
+		"  threat \"Sniffing\" {\n    description = \"\"\n    stride      = [\"Info Disclosure\"]\n\n    control \"TLS\" {\n      implemented    = true\n      description    = \"\"\n      risk_reduction = 50\n    }\n  }\n}",
+		"\nthreatmodel \"Warehouse\" {\n  author = \"\"\n\n  threat \"Theft\" {\n    description = \"\"\n  }\n}\n",
+	} {
+		if !strings.Contains(got, exp) {
+			t.Errorf("expected output to contain %q:\n%s", exp, got)
+		}
+	}
+
+	if len(warnings) != 1 || !strings.Contains(warnings[0], `threatmodel "Nowhere"`) {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+}
+
+func TestApplyRemovesRisk(t *testing.T) {
+	rows, _ := Read(strings.NewReader("model,threat,likelihood,impact\nShop,SQL injection,,\n"))
+	tm := &spec.Threatmodel{Name: "Shop", Threats: []*spec.Threat{
+		{Name: "SQL injection", Risk: &spec.Risk{Likelihood: "medium", Impact: "very_high"}},
+	}}
+	res := Merge([]*spec.Threatmodel{tm}, rows, testOpts)
+
+	out, _, err := Apply([]byte(applySrc), "shop.hcl", res)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Contains(string(out), "risk {") {
+		t.Errorf("expected the risk block to be removed:\n%s", out)
+	}
+}
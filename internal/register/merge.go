@@ -0,0 +1,321 @@
+package register
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/threatcl/spec"
+)
+
+// riskLevels are the allowed likelihood and impact values.
+var riskLevels = []string{"very_low", "low", "medium", "high", "very_high"}
+
+// Options tune Merge.
+type Options struct {
+	// Stride and ImpactTypes are the allowed stride and impacts values (see
+	// spec.ThreatmodelSpecConfig).
+	Stride      []string
+	ImpactTypes []string
+}
+
+// Kind is what a Change did.
+type Kind int
+
+const (
+	ModelAdded Kind = iota
+	ThreatAdded
+	ControlAdded
+	FieldChanged
+)
+
+// Change is one edit Merge made. Field is the HCL attribute changed ("risk"
+// when a threat's risk block was removed).
+type Change struct {
+	Kind    Kind
+	Model   string
+	Threat  string
+	Control string
+	Field   string
+	Old     string
+	New     string
+}
+
+func (c Change) String() string {
+	path := fmt.Sprintf("threatmodel %q", c.Model)
+	if c.Threat != "" {
+		path += fmt.Sprintf(": threat %q", c.Threat)
+	}
+	if c.Control != "" {
+		path += fmt.Sprintf(" > control %q", c.Control)
+	}
+	if c.Kind != FieldChanged {
+		return path + " added"
+	}
+	return fmt.Sprintf("%s > %s: %s -> %s", path, c.Field, show(c.Old), show(c.New))
+}
+
+func show(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return strconv.Quote(s)
+}
+
+// Result is the merged threat models, what changed and anything that
+// couldn't be merged.
+type Result struct {
+	Threatmodels []*spec.Threatmodel
+	Changes      []Change
+	Warnings     []string
+}
+
+// Merge applies register rows to tms, which are modified in place. Models,
+// threats and controls the register names but tms lack are added; nothing is
+// ever removed, so a filtered register only touches the rows it has. A
+// threat's columns are taken from its first row.
+func Merge(tms []*spec.Threatmodel, rows []Row, opts Options) *Result {
+	m := &merger{
+		opts:  opts,
+		res:   &Result{Threatmodels: tms},
+		fresh: map[interface{}]bool{},
+		first: map[*spec.Threat]Row{},
+	}
+	for _, r := range rows {
+		m.row(r)
+	}
+	return m.res
+}
+
+type merger struct {
+	opts Options
+	res  *Result
+
+	// fresh holds the models, threats and controls added by this merge,
+	// whose fields aren't reported as changes, and first the row each
+	// threat's columns came from
+	fresh map[interface{}]bool
+	first map[*spec.Threat]Row
+}
+
+func (m *merger) warn(format string, args ...interface{}) {
+	m.res.Warnings = append(m.res.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (m *merger) change(fresh bool, c Change) {
+	if !fresh {
+		m.res.Changes = append(m.res.Changes, c)
+	}
+}
+
+func (m *merger) row(r Row) {
+	modelName, _ := r.Get(ColModel)
+	threatName, _ := r.Get(ColThreat)
+	if modelName == "" || threatName == "" {
+		m.warn("line %d: a row needs a model and a threat, skipped", r.Line)
+		return
+	}
+
+	var tm *spec.Threatmodel
+	for _, t := range m.res.Threatmodels {
+		if t.Name == modelName {
+			tm = t
+		}
+	}
+	if tm == nil {
+		tm = &spec.Threatmodel{Name: modelName}
+		m.res.Threatmodels = append(m.res.Threatmodels, tm)
+		m.res.Changes = append(m.res.Changes, Change{Kind: ModelAdded, Model: modelName})
+		m.fresh[tm] = true
+		m.warn("threatmodel %q is new, its author is empty", modelName)
+	}
+
+	var threat *spec.Threat
+	for _, t := range tm.Threats {
+		if t.Name == threatName {
+			threat = t
+		}
+	}
+	if threat == nil {
+		threat = &spec.Threat{Name: threatName}
+		tm.Threats = append(tm.Threats, threat)
+		m.change(m.fresh[tm], Change{Kind: ThreatAdded, Model: modelName, Threat: threatName})
+		m.fresh[threat] = true
+	}
+
+	if first, ok := m.first[threat]; ok {
+		for _, col := range []string{ColThreatDescription, ColStride, ColImpacts, ColAssetRefs, ColLikelihood, ColImpact} {
+			a, _ := first.Get(col)
+			b, _ := r.Get(col)
+			if a != b {
+				m.warn("line %d: threat %q: %s differs from line %d, using line %d", r.Line, threatName, col, first.Line, first.Line)
+			}
+		}
+	} else {
+		m.first[threat] = r
+		m.threat(tm, threat, r)
+	}
+
+	if name, _ := r.Get(ColControl); name != "" {
+		m.control(tm, threat, name, r)
+	}
+}
+
+func (m *merger) threat(tm *spec.Threatmodel, t *spec.Threat, r Row) {
+	fresh := m.fresh[t]
+	changed := func(field, old, new string) {
+		m.change(fresh, Change{Kind: FieldChanged, Model: tm.Name, Threat: t.Name, Field: field, Old: old, New: new})
+	}
+
+	if v, ok := r.Get(ColThreatDescription); ok && v != "" && v != strings.TrimSpace(t.Description) {
+		changed("description", strings.TrimSpace(t.Description), v)
+		t.Description = v
+	}
+
+	if v, ok := r.Get(ColStride); ok {
+		stride := m.allowed(r, t.Name, "stride", splitList(v), m.opts.Stride)
+		if joinList(stride) != joinList(t.Stride) {
+			changed("stride", joinList(t.Stride), joinList(stride))
+			t.Stride = stride
+		}
+	}
+
+	if v, ok := r.Get(ColImpacts); ok {
+		impacts := m.allowed(r, t.Name, "impacts", splitList(v), m.opts.ImpactTypes)
+		if joinList(impacts) != joinList(t.ImpactType) {
+			changed("impacts", joinList(t.ImpactType), joinList(impacts))
+			t.ImpactType = impacts
+		}
+	}
+
+	if v, ok := r.Get(ColAssetRefs); ok {
+		assets := []string{}
+		for _, ia := range tm.InformationAssets {
+			assets = append(assets, ia.Name)
+		}
+		refs := m.allowed(r, t.Name, "information asset", splitList(v), assets)
+		if joinList(refs) != joinList(t.InformationAssetRefs) {
+			changed("information_asset_refs", joinList(t.InformationAssetRefs), joinList(refs))
+			t.InformationAssetRefs = refs
+		}
+	}
+
+	l, lok := r.Get(ColLikelihood)
+	i, iok := r.Get(ColImpact)
+	if !lok || !iok {
+		return
+	}
+	l, i = strings.ToLower(l), strings.ToLower(i)
+	switch {
+	case l == "" && i == "":
+		if t.Risk != nil {
+			changed("risk", fmt.Sprintf("likelihood %s, impact %s", t.Risk.Likelihood, t.Risk.Impact), "")
+			t.Risk = nil
+		}
+	case l == "" || i == "":
+		m.warn("line %d: threat %q: a risk needs both a likelihood and an impact, skipped", r.Line, t.Name)
+	case !contains(riskLevels, l) || !contains(riskLevels, i):
+		m.warn("line %d: threat %q: likelihood and impact must be one of %s, skipped", r.Line, t.Name, strings.Join(riskLevels, ", "))
+	default:
+		if t.Risk == nil {
+			t.Risk = &spec.Risk{}
+		}
+		if t.Risk.Likelihood != l {
+			changed("likelihood", t.Risk.Likelihood, l)
+			t.Risk.Likelihood = l
+		}
+		if t.Risk.Impact != i {
+			changed("impact", t.Risk.Impact, i)
+			t.Risk.Impact = i
+		}
+	}
+}
+
+func (m *merger) control(tm *spec.Threatmodel, t *spec.Threat, name string, r Row) {
+	var c *spec.Control
+	for _, ctrl := range append(append([]*spec.Control{}, t.Controls...), t.ExpandedControls...) {
+		if ctrl.Name == name {
+			c = ctrl
+			break
+		}
+	}
+	if c == nil {
+		c = &spec.Control{Name: name}
+		t.Controls = append(t.Controls, c)
+		m.change(m.fresh[t], Change{Kind: ControlAdded, Model: tm.Name, Threat: t.Name, Control: name})
+		m.fresh[c] = true
+	}
+
+	fresh := m.fresh[c]
+	changed := func(field, old, new string) {
+		m.change(fresh, Change{Kind: FieldChanged, Model: tm.Name, Threat: t.Name, Control: name, Field: field, Old: old, New: new})
+	}
+
+	if v, ok := r.Get(ColControlDescription); ok && v != "" && v != strings.TrimSpace(c.Description) {
+		changed("description", strings.TrimSpace(c.Description), v)
+		c.Description = v
+	}
+
+	// Blank cells leave a control as it is, so a partly filled register
+	// doesn't reset what it leaves out.
+	if v, ok := r.Get(ColImplemented); ok && v != "" {
+		implemented, valid := parseBool(v)
+		switch {
+		case !valid:
+			m.warn("line %d: control %q: implemented %q isn't true or false, skipped", r.Line, name, v)
+		case implemented != c.Implemented:
+			changed("implemented", strconv.FormatBool(c.Implemented), strconv.FormatBool(implemented))
+			c.Implemented = implemented
+		}
+	}
+
+	if v, ok := r.Get(ColRiskReduction); ok && v != "" {
+		rr, err := strconv.Atoi(strings.TrimSuffix(v, "%"))
+		switch {
+		case err != nil || rr < 0 || rr > 100:
+			m.warn("line %d: control %q: risk_reduction %q isn't a number from 0 to 100, skipped", r.Line, name, v)
+		case rr != c.RiskReduction:
+			changed("risk_reduction", strconv.Itoa(c.RiskReduction), strconv.Itoa(rr))
+			c.RiskReduction = rr
+		}
+	}
+}
+
+// allowed returns the values found in allowed (matched case-insensitively,
+// using allowed's spelling), warning about the rest.
+func (m *merger) allowed(r Row, threat, what string, values, allowed []string) []string {
+	out := []string{}
+	for _, v := range values {
+		found := false
+		for _, a := range allowed {
+			if strings.EqualFold(a, v) {
+				out = append(out, a)
+				found = true
+				break
+			}
+		}
+		if !found {
+			m.warn("line %d: threat %q: unknown %s %q, skipped", r.Line, threat, what, v)
+		}
+	}
+	return out
+}
+
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "y", "x", "1":
+		return true, true
+	case "false", "no", "n", "0":
+		return false, true
+	}
+	return false, false
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
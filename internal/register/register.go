@@ -0,0 +1,206 @@
+// Package register reads and writes threat registers: CSV spreadsheets with
+// one row per threat/control pair, as kept by risk committees.
+//
+// Write exports threat models into a register. Read parses a (possibly
+// edited) register back, Merge applies its rows to threat models, building
+// models and threats that don't exist yet, and reports every change, and
+// Apply writes those changes into HCL source without disturbing the rest of
+// the file.
+package register
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// Register columns, in the order Write emits them. severity and
+// residual_score are computed from the model and ignored by Read.
+const (
+	ColModel              = "model"
+	ColThreat             = "threat"
+	ColStride             = "stride"
+	ColImpacts            = "impacts"
+	ColLikelihood         = "likelihood"
+	ColImpact             = "impact"
+	ColSeverity           = "severity"
+	ColResidualScore      = "residual_score"
+	ColControl            = "control"
+	ColImplemented        = "implemented"
+	ColRiskReduction      = "risk_reduction"
+	ColAssetRefs          = "asset_refs"
+	ColThreatDescription  = "threat_description"
+	ColControlDescription = "control_description"
+)
+
+// Columns is the register header.
+var Columns = []string{
+	ColModel,
+	ColThreat,
+	ColStride,
+	ColImpacts,
+	ColLikelihood,
+	ColImpact,
+	ColSeverity,
+	ColResidualScore,
+	ColControl,
+	ColImplemented,
+	ColRiskReduction,
+	ColAssetRefs,
+	ColThreatDescription,
+	ColControlDescription,
+}
+
+// Write writes a register for tms: one row per threat/control pair, and one
+// row with empty control columns for each threat without controls.
+func Write(w io.Writer, tms []spec.Threatmodel) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Columns); err != nil {
+		return err
+	}
+
+	for _, tm := range tms {
+		for _, t := range tm.Threats {
+			threatCols := map[string]string{
+				ColModel:             tm.Name,
+				ColThreat:            t.Name,
+				ColStride:            joinList(t.Stride),
+				ColImpacts:           joinList(t.ImpactType),
+				ColAssetRefs:         joinList(t.InformationAssetRefs),
+				ColThreatDescription: strings.TrimSpace(t.Description),
+			}
+			if t.Risk != nil {
+				threatCols[ColLikelihood] = t.Risk.Likelihood
+				threatCols[ColImpact] = t.Risk.Impact
+				threatCols[ColSeverity] = t.Risk.Severity()
+				threatCols[ColResidualScore] = strconv.FormatFloat(t.ResidualScore(), 'f', -1, 64)
+			}
+
+			controls := tmutil.AllControls(t)
+			if len(controls) == 0 {
+				if err := cw.Write(record(threatCols)); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, c := range controls {
+				cols := map[string]string{
+					ColControl:            c.Name,
+					ColImplemented:        strconv.FormatBool(c.Implemented),
+					ColRiskReduction:      strconv.Itoa(c.RiskReduction),
+					ColControlDescription: strings.TrimSpace(c.Description),
+				}
+				for k, v := range threatCols {
+					cols[k] = v
+				}
+				if err := cw.Write(record(cols)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func record(cols map[string]string) []string {
+	out := make([]string, len(Columns))
+	for i, c := range Columns {
+		out[i] = cols[c]
+	}
+	return out
+}
+
+// Row is one register row. Only the columns present in the register's
+// header are set, so a register without (say) a stride column leaves the
+// stride of its threats alone.
+type Row struct {
+	// Line is the row's line number in the register, for messages.
+	Line int
+
+	cols map[string]string
+}
+
+// Get returns a column's value and whether the register has that column.
+func (r Row) Get(col string) (string, bool) {
+	v, ok := r.cols[col]
+	return strings.TrimSpace(v), ok
+}
+
+// Read parses a register. Columns are matched by header name, in any order
+// and case; unknown columns are ignored. The model and threat columns are
+// required.
+func Read(r io.Reader) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("error parsing csv: the register is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing csv: %s", err)
+	}
+
+	index := map[string]int{}
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		for _, c := range Columns {
+			if h == c {
+				index[c] = i
+			}
+		}
+	}
+	for _, c := range []string{ColModel, ColThreat} {
+		if _, ok := index[c]; !ok {
+			return nil, fmt.Errorf("error parsing csv: missing the %q column", c)
+		}
+	}
+
+	rows := []Row{}
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing csv: %s", err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		row := Row{Line: line, cols: map[string]string{}}
+		empty := true
+		for c, i := range index {
+			if i < len(rec) {
+				row.cols[c] = rec[i]
+				empty = empty && strings.TrimSpace(rec[i]) == ""
+			} else {
+				row.cols[c] = ""
+			}
+		}
+		if !empty {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func joinList(l []string) string {
+	return strings.Join(l, ", ")
+}
+
+func splitList(s string) []string {
+	out := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,181 @@
+package register
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+var testOpts = Options{
+	Stride:      []string{"Spoofing", "Tampering", "Repudiation", "Info Disclosure", "Denial Of Service", "Elevation Of Privilege"},
+	ImpactTypes: []string{"Confidentiality", "Integrity", "Availability"},
+}
+
+func registerModel() *spec.Threatmodel {
+	return &spec.Threatmodel{
+		Name:              "Shop",
+		Author:            "@alice",
+		InformationAssets: []*spec.InformationAsset{{Name: "Card data"}},
+		Threats: []*spec.Threat{
+			{
+				Name:                 "SQL injection",
+				Description:          "Attacker injects SQL",
+				Stride:               []string{"Tampering"},
+				ImpactType:           []string{"Confidentiality", "Integrity"},
+				InformationAssetRefs: []string{"Card data"},
+				Risk:                 &spec.Risk{Likelihood: "medium", Impact: "very_high"},
+				Controls: []*spec.Control{
+					{Name: "Parameterised queries", Description: "Use bind params", Implemented: true, RiskReduction: 80},
+					{Name: "WAF", Description: "Filter requests, mostly", RiskReduction: 30},
+				},
+			},
+			{
+				Name:        "Flooding",
+				Description: "Too many requests",
+			},
+		},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, []spec.Threatmodel{*registerModel()}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected a header and 3 rows, got:\n%s", buf.String())
+	}
+	if lines[0] != strings.Join(Columns, ",") {
+		t.Errorf("unexpected header: %s", lines[0])
+	}
+	for i, exp := range []string{
+		`Shop,SQL injection,Tampering,"Confidentiality, Integrity",medium,very_high,`,
+		`WAF,false,30,Card data,Attacker injects SQL,"Filter requests, mostly"`,
+		`Shop,Flooding,,,,,,,,,,,Too many requests,`,
+	} {
+		if !strings.Contains(lines[i+1], exp) {
+			t.Errorf("expected row %d to contain %q:\n%s", i+1, exp, lines[i+1])
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	rows, err := Read(strings.NewReader("\ufeffThreat, Model ,extra\nT,M,x\n,,\nU,M,y\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(rows) != 2 || rows[1].Line != 4 {
+		t.Fatalf("expected 2 rows, blank rows skipped: %+v", rows)
+	}
+	if v, ok := rows[0].Get(ColThreat); !ok || v != "T" {
+		t.Errorf("expected the threat column by name, got %q", v)
+	}
+	if _, ok := rows[0].Get(ColStride); ok {
+		t.Errorf("expected no stride column")
+	}
+
+	for _, in := range []string{"", "model\nM\n", "model,threat\n\"unterminated\n"} {
+		if _, err := Read(strings.NewReader(in)); err == nil || !strings.HasPrefix(err.Error(), "error parsing csv") {
+			t.Errorf("expected a parse error for %q, got %v", in, err)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	in := `model,threat,stride,impacts,likelihood,impact,severity,residual_score,control,implemented,risk_reduction,asset_refs,threat_description,control_description
+Shop,SQL injection,Tampering,"Confidentiality, Integrity",high,very_high,critical,9,Parameterised queries,true,80,Card data,Attacker injects SQL,Use bind params
+Shop,SQL injection,Tampering,"Confidentiality, Integrity",high,very_high,critical,9,WAF,yes,30,Card data,Attacker injects SQL,
+Shop,SQL injection,Tampering,"Confidentiality, Integrity",high,very_high,critical,9,Rate limit,no,,Card data,Attacker injects SQL,Throttle clients
+Shop,Flooding,denial of service,,,,,,,,,,,
+Shop,Sniffing,Info Disclosure,Confidentiality,low,high,,,TLS,maybe,200,Nope,Traffic read,
+Warehouse,Theft,,,,,,,Locks,true,50,,Stock stolen,Lock the doors
+`
+	rows, err := Read(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tm := registerModel()
+	res := Merge([]*spec.Threatmodel{tm}, rows, testOpts)
+
+	changes := []string{}
+	for _, c := range res.Changes {
+		changes = append(changes, c.String())
+	}
+	exp := []string{
+		`threatmodel "Shop": threat "SQL injection" > likelihood: "medium" -> "high"`,
+		`threatmodel "Shop": threat "SQL injection" > control "WAF" > implemented: "false" -> "true"`,
+		`threatmodel "Shop": threat "SQL injection" > control "Rate limit" added`,
+		`threatmodel "Shop": threat "Flooding" > stride: (none) -> "Denial Of Service"`,
+		`threatmodel "Shop": threat "Sniffing" added`,
+		`threatmodel "Warehouse" added`,
+	}
+	if strings.Join(changes, "\n") != strings.Join(exp, "\n") {
+		t.Errorf("unexpected changes:\n%s\nexpected:\n%s", strings.Join(changes, "\n"), strings.Join(exp, "\n"))
+	}
+
+	if len(res.Threatmodels) != 2 || len(tm.Threats) != 3 || len(tm.Threats[0].Controls) != 3 {
+		t.Fatalf("unexpected merge: %+v", res.Threatmodels)
+	}
+	if tm.Threats[0].Controls[1].Description != "Filter requests, mostly" {
+		t.Errorf("expected an empty description to leave the control's alone")
+	}
+	sniff := tm.Threats[2]
+	if sniff.Risk == nil || sniff.Risk.Likelihood != "low" || len(sniff.InformationAssetRefs) != 0 || sniff.Controls[0].Implemented {
+		t.Errorf("unexpected new threat: %+v", sniff)
+	}
+
+	warnings := strings.Join(res.Warnings, "\n")
+	for _, exp := range []string{
+		`line 6: threat "Sniffing": unknown information asset "Nope"`,
+		`line 6: control "TLS": implemented "maybe"`,
+		`line 6: control "TLS": risk_reduction "200"`,
+		`threatmodel "Warehouse" is new`,
+	} {
+		if !strings.Contains(warnings, exp) {
+			t.Errorf("expected warnings to contain %q:\n%s", exp, warnings)
+		}
+	}
+}
+
+func TestMergeRemovesRisk(t *testing.T) {
+	rows, _ := Read(strings.NewReader("model,threat,likelihood,impact\nShop,SQL injection,,\nShop,Flooding,low,\n"))
+	tm := registerModel()
+	res := Merge([]*spec.Threatmodel{tm}, rows, testOpts)
+
+	if tm.Threats[0].Risk != nil || len(res.Changes) != 1 || res.Changes[0].Field != "risk" {
+		t.Errorf("expected the risk to be removed: %+v", res.Changes)
+	}
+	if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "needs both a likelihood and an impact") {
+		t.Errorf("unexpected warnings: %v", res.Warnings)
+	}
+}
+
+func TestMergeBlankControlCells(t *testing.T) {
+	rows, _ := Read(strings.NewReader("model,threat,control,implemented,risk_reduction,control_description\nShop,SQL injection,Parameterised queries,,,\nShop,SQL injection,WAF,, ,\n"))
+	tm := registerModel()
+	res := Merge([]*spec.Threatmodel{tm}, rows, testOpts)
+
+	if len(res.Changes) != 0 || len(res.Warnings) != 0 {
+		t.Errorf("expected blank cells to change nothing: %+v %v", res.Changes, res.Warnings)
+	}
+	if c := tm.Threats[0].Controls[0]; !c.Implemented || c.RiskReduction != 80 {
+		t.Errorf("expected the control to be left alone: %+v", c)
+	}
+	if c := tm.Threats[0].Controls[1]; c.Implemented || c.RiskReduction != 30 {
+		t.Errorf("expected the control to be left alone: %+v", c)
+	}
+}
+
+func TestMergeConflictingRows(t *testing.T) {
+	rows, _ := Read(strings.NewReader("model,threat,stride,control\nShop,Flooding,Spoofing,A\nShop,Flooding,Tampering,B\n"))
+	res := Merge([]*spec.Threatmodel{registerModel()}, rows, testOpts)
+
+	if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "line 3: threat \"Flooding\": stride differs from line 2") {
+		t.Errorf("unexpected warnings: %v", res.Warnings)
+	}
+}
@@ -0,0 +1,308 @@
+// Package signing produces and checks detached signatures over threat models.
+//
+// Signatures cover a canonical form of the parsed spec.Threatmodel rather than
+// the bytes of its source file, so reformatting a file, reordering its blocks'
+// attributes or switching between heredoc and quoted strings with the same
+// value doesn't invalidate a signature, while any change to the model's
+// content does. Keys are local ed25519 (recommended) or RSA SSH keys; trusted
+// public keys are read from an authorized_keys style file.
+//
+// Signatures live in a JSON sidecar next to the model's file (see
+// SidecarPath), one entry per threat model and signing key.
+package signing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/threatcl/spec"
+	"golang.org/x/crypto/ssh"
+)
+
+// Namespace is prefixed to the canonical form before signing, so a threatcl
+// signature can't be replayed as a signature over anything else.
+const Namespace = "threatcl-threatmodel-v1"
+
+// sidecarSuffix is appended to a threat model file's path to name its
+// signature file.
+const sidecarSuffix = ".sig"
+
+var (
+	// ErrUnsigned is returned when a threat model has no signature at all.
+	ErrUnsigned = errors.New("threatmodel isn't signed")
+
+	// ErrUntrusted is returned when a threat model is only signed by keys
+	// that aren't in the trusted set.
+	ErrUntrusted = errors.New("threatmodel isn't signed by a trusted key")
+
+	// ErrWeakSignature is returned when a threat model's only trusted
+	// signatures use an algorithm that isn't accepted, such as SHA-1
+	// "ssh-rsa".
+	ErrWeakSignature = errors.New("threatmodel is only signed with an algorithm that isn't accepted")
+
+	// ErrModified is returned when a trusted signature exists but the
+	// threat model has changed since it was made.
+	ErrModified = errors.New("threatmodel has changed since it was signed")
+)
+
+// signatureFormats are the signature algorithms accepted for each key type.
+// Plain "ssh-rsa" signatures use SHA-1, so RSA keys must sign with SHA-2.
+var signatureFormats = map[string][]string{
+	ssh.KeyAlgoED25519: {ssh.KeyAlgoED25519},
+	ssh.KeyAlgoRSA:     {ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512},
+}
+
+// Signature is one detached signature over one threat model.
+type Signature struct {
+	Threatmodel string    `json:"threatmodel"`
+	Signer      string    `json:"signer,omitempty"`
+	SignedAt    time.Time `json:"signed_at"`
+	PublicKey   string    `json:"public_key"`
+	Fingerprint string    `json:"fingerprint"`
+	Digest      string    `json:"digest"`
+	Format      string    `json:"format"`
+	Signature   string    `json:"signature"`
+}
+
+// File is the on-disk format of a signature sidecar.
+type File struct {
+	Signatures []Signature `json:"signatures"`
+}
+
+// SidecarPath returns the path of the signature file for a threat model file.
+func SidecarPath(file string) string {
+	return file + sidecarSuffix
+}
+
+// Canonical returns the canonical form of tm: its JSON encoding with object
+// keys sorted and no insignificant whitespace.
+func Canonical(tm *spec.Threatmodel) ([]byte, error) {
+	b, err := json.Marshal(tm)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round-tripping through interface{} sorts the keys of every object, so
+	// the result doesn't depend on struct field order in the spec module.
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+// Digest returns the hex sha256 of tm's canonical form.
+func Digest(tm *spec.Threatmodel) (string, error) {
+	b, err := Canonical(tm)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// LoadSigner reads an SSH private key. passphrase is only consulted for
+// encrypted keys; if it's nil and the key is encrypted, the returned error
+// wraps *ssh.PassphraseMissingError.
+func LoadSigner(path string, passphrase []byte) (ssh.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(b)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) && passphrase != nil {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(b, passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading private key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// LoadTrustedKeys reads public keys, one per line, in authorized_keys format.
+// Blank lines and # comments are ignored. A single .pub file works too.
+func LoadTrustedKeys(path string) ([]ssh.PublicKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []ssh.PublicKey{}
+	for len(bytes.TrimSpace(b)) > 0 {
+		pub, _, _, rest, err := ssh.ParseAuthorizedKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("error reading public keys from %s: %w", path, err)
+		}
+		keys = append(keys, pub)
+		b = rest
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public keys found in %s", path)
+	}
+	return keys, nil
+}
+
+// Sign signs tm's canonical form with signer.
+func Sign(signer ssh.Signer, tm *spec.Threatmodel, signerName string, now time.Time) (Signature, error) {
+	canonical, err := Canonical(tm)
+	if err != nil {
+		return Signature{}, err
+	}
+
+	msg := signedMessage(canonical)
+
+	if _, ok := signatureFormats[signer.PublicKey().Type()]; !ok {
+		return Signature{}, fmt.Errorf("%s keys aren't supported, use an ed25519 or RSA key", signer.PublicKey().Type())
+	}
+
+	var sig *ssh.Signature
+	// Plain "ssh-rsa" signatures use SHA-1; ask RSA keys for SHA-512.
+	if as, ok := signer.(ssh.AlgorithmSigner); ok && signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		sig, err = as.SignWithAlgorithm(rand.Reader, msg, ssh.KeyAlgoRSASHA512)
+	} else {
+		sig, err = signer.Sign(rand.Reader, msg)
+	}
+	if err != nil {
+		return Signature{}, err
+	}
+
+	sum := sha256.Sum256(canonical)
+	pub := signer.PublicKey()
+
+	return Signature{
+		Threatmodel: tm.Name,
+		Signer:      signerName,
+		SignedAt:    now.UTC(),
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))),
+		Fingerprint: ssh.FingerprintSHA256(pub),
+		Digest:      hex.EncodeToString(sum[:]),
+		Format:      sig.Format,
+		Signature:   base64.StdEncoding.EncodeToString(sig.Blob),
+	}, nil
+}
+
+// Verify checks that tm carries a valid signature from one of the trusted
+// keys and returns it. Only ed25519 signatures, and SHA-2 signatures from RSA
+// keys, are accepted. ErrUnsigned, ErrUntrusted, ErrWeakSignature and
+// ErrModified describe why verification failed.
+func (f *File) Verify(tm *spec.Threatmodel, trusted []ssh.PublicKey) (*Signature, error) {
+	canonical, err := Canonical(tm)
+	if err != nil {
+		return nil, err
+	}
+	msg := signedMessage(canonical)
+
+	found := false
+	trustedFound := false
+	accepted := false
+	for i := range f.Signatures {
+		s := &f.Signatures[i]
+		if s.Threatmodel != tm.Name {
+			continue
+		}
+		found = true
+
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(s.PublicKey))
+		if err != nil || !isTrusted(pub, trusted) {
+			continue
+		}
+		trustedFound = true
+		if !acceptedFormat(pub, s.Format) {
+			continue
+		}
+		accepted = true
+
+		blob, err := base64.StdEncoding.DecodeString(s.Signature)
+		if err != nil {
+			continue
+		}
+		if pub.Verify(msg, &ssh.Signature{Format: s.Format, Blob: blob}) == nil {
+			return s, nil
+		}
+	}
+
+	switch {
+	case !found:
+		return nil, ErrUnsigned
+	case !trustedFound:
+		return nil, ErrUntrusted
+	case !accepted:
+		return nil, ErrWeakSignature
+	}
+	return nil, ErrModified
+}
+
+// acceptedFormat reports whether a signature in format, by pub, is accepted.
+func acceptedFormat(pub ssh.PublicKey, format string) bool {
+	for _, f := range signatureFormats[pub.Type()] {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Add records sig, replacing any earlier signature of the same threat model
+// by the same key.
+func (f *File) Add(sig Signature) {
+	for i, s := range f.Signatures {
+		if s.Threatmodel == sig.Threatmodel && s.Fingerprint == sig.Fingerprint {
+			f.Signatures[i] = sig
+			return
+		}
+	}
+	f.Signatures = append(f.Signatures, sig)
+}
+
+// ReadFile loads a signature sidecar. A missing file holds no signatures.
+func ReadFile(path string) (*File, error) {
+	f := &File{}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, f); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// WriteFile saves the signature sidecar to path.
+func (f *File) WriteFile(path string) error {
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+func signedMessage(canonical []byte) []byte {
+	return append([]byte(Namespace+"\n"), canonical...)
+}
+
+func isTrusted(pub ssh.PublicKey, trusted []ssh.PublicKey) bool {
+	for _, t := range trusted {
+		if bytes.Equal(pub.Marshal(), t.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package signing
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/threatcl/spec"
+	"golang.org/x/crypto/ssh"
+)
+
+func testSigner(tb testing.TB) ssh.Signer {
+	tb.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		tb.Fatalf("Error generating key: %s", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		tb.Fatalf("Error creating signer: %s", err)
+	}
+	return signer
+}
+
+func testModel() *spec.Threatmodel {
+	return &spec.Threatmodel{
+		Name:   "signed",
+		Author: "@xntrik",
+		Threats: []*spec.Threat{
+			{Name: "data leak", Description: "Data could leak"},
+		},
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	signer := testSigner(t)
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sig, err := Sign(signer, testModel(), "alice", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if sig.Fingerprint != ssh.FingerprintSHA256(signer.PublicKey()) {
+		t.Errorf("unexpected fingerprint %s", sig.Fingerprint)
+	}
+
+	f := &File{}
+	f.Add(sig)
+	trusted := []ssh.PublicKey{signer.PublicKey()}
+
+	t.Run("valid", func(t *testing.T) {
+		got, err := f.Verify(testModel(), trusted)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got.Signer != "alice" {
+			t.Errorf("expected signer alice, got %s", got.Signer)
+		}
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		tm := testModel()
+		tm.Threats[0].Description = "Data could leak badly"
+		if _, err := f.Verify(tm, trusted); !errors.Is(err, ErrModified) {
+			t.Errorf("expected ErrModified, got %v", err)
+		}
+	})
+
+	t.Run("untrusted", func(t *testing.T) {
+		other := []ssh.PublicKey{testSigner(t).PublicKey()}
+		if _, err := f.Verify(testModel(), other); !errors.Is(err, ErrUntrusted) {
+			t.Errorf("expected ErrUntrusted, got %v", err)
+		}
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		tm := testModel()
+		tm.Name = "other"
+		if _, err := f.Verify(tm, trusted); !errors.Is(err, ErrUnsigned) {
+			t.Errorf("expected ErrUnsigned, got %v", err)
+		}
+	})
+}
+
+func TestSignVerifyRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("Error creating signer: %s", err)
+	}
+	trusted := []ssh.PublicKey{signer.PublicKey()}
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sig, err := Sign(signer, testModel(), "alice", now)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if sig.Format != ssh.KeyAlgoRSASHA512 {
+		t.Errorf("expected an rsa-sha2-512 signature, got %s", sig.Format)
+	}
+	f := &File{}
+	f.Add(sig)
+	if _, err := f.Verify(testModel(), trusted); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// a valid SHA-1 signature by the same trusted key isn't accepted
+	canonical, err := Canonical(testModel())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	sha1Sig, err := signer.(ssh.AlgorithmSigner).SignWithAlgorithm(rand.Reader, signedMessage(canonical), ssh.KeyAlgoRSA)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	sig.Format = sha1Sig.Format
+	sig.Signature = base64.StdEncoding.EncodeToString(sha1Sig.Blob)
+	f = &File{}
+	f.Add(sig)
+	if _, err := f.Verify(testModel(), trusted); !errors.Is(err, ErrWeakSignature) {
+		t.Errorf("expected ErrWeakSignature, got %v", err)
+	}
+}
+
+func TestSignUnsupportedKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("Error creating signer: %s", err)
+	}
+	if _, err := Sign(signer, testModel(), "alice", time.Now()); err == nil {
+		t.Error("expected an ecdsa key to be refused")
+	}
+}
+
+func TestAddReplacesSameKey(t *testing.T) {
+	signer := testSigner(t)
+	f := &File{}
+
+	for i := 0; i < 2; i++ {
+		sig, err := Sign(signer, testModel(), "alice", time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		f.Add(sig)
+	}
+
+	sig, _ := Sign(testSigner(t), testModel(), "bob", time.Now())
+	f.Add(sig)
+
+	if len(f.Signatures) != 2 {
+		t.Errorf("expected 2 signatures, got %d", len(f.Signatures))
+	}
+}
+
+func TestCanonicalIsStable(t *testing.T) {
+	a, err := Canonical(testModel())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b, _ := Canonical(testModel())
+	if string(a) != string(b) {
+		t.Errorf("expected identical canonical forms")
+	}
+
+	da, _ := Digest(testModel())
+	tm := testModel()
+	tm.Author = "@someone"
+	db, _ := Digest(tm)
+	if da == db {
+		t.Errorf("expected a content change to change the digest")
+	}
+}
+
+func TestLoadKeysAndFile(t *testing.T) {
+	d := t.TempDir()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(priv, "test", []byte("hunter2"))
+	if err != nil {
+		t.Fatalf("Error marshalling key: %s", err)
+	}
+	keyFile := filepath.Join(d, "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Error writing key: %s", err)
+	}
+
+	if _, err := LoadSigner(keyFile, nil); err == nil {
+		t.Errorf("expected an encrypted key to need a passphrase")
+	} else {
+		var missing *ssh.PassphraseMissingError
+		if !errors.As(err, &missing) {
+			t.Errorf("expected a PassphraseMissingError, got %v", err)
+		}
+	}
+
+	signer, err := LoadSigner(keyFile, []byte("hunter2"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	sshPub, _ := ssh.NewPublicKey(pub)
+	keysFile := filepath.Join(d, "allowed_signers")
+	keys := "# release approvers\n" + string(ssh.MarshalAuthorizedKey(sshPub)) + "\n" + string(ssh.MarshalAuthorizedKey(testSigner(t).PublicKey()))
+	if err := os.WriteFile(keysFile, []byte(keys), 0600); err != nil {
+		t.Fatalf("Error writing keys: %s", err)
+	}
+
+	trusted, err := LoadTrustedKeys(keysFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(trusted) != 2 {
+		t.Fatalf("expected 2 trusted keys, got %d", len(trusted))
+	}
+
+	sig, err := Sign(signer, testModel(), "", time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	sigFile := SidecarPath(filepath.Join(d, "tm.hcl"))
+	f, err := ReadFile(sigFile)
+	if err != nil || len(f.Signatures) != 0 {
+		t.Fatalf("expected an empty file for a missing sidecar, got %v (%v)", f, err)
+	}
+	f.Add(sig)
+	if err := f.WriteFile(sigFile); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	f, err = ReadFile(sigFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := f.Verify(testModel(), trusted); err != nil {
+		t.Errorf("expected the round-tripped signature to verify: %s", err)
+	}
+}
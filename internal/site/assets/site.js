@@ -0,0 +1,79 @@
+// threatcl site: search and severity filters. Everything runs from local
+// files, so the site works without a server.
+(function () {
+  "use strict";
+
+  var index = window.threatclSearchIndex || [];
+  var input = document.getElementById("search");
+  var results = document.getElementById("search-results");
+
+  function search(query) {
+    var terms = query.toLowerCase().split(/\s+/).filter(Boolean);
+    while (results.firstChild) {
+      results.removeChild(results.firstChild);
+    }
+    if (terms.length === 0) {
+      results.hidden = true;
+      return;
+    }
+
+    var found = 0;
+    for (var i = 0; i < index.length && found < 20; i++) {
+      var e = index[i];
+      var text = (e.title + " " + e.kind + " " + e.text).toLowerCase();
+      if (!terms.every(function (t) { return text.indexOf(t) >= 0; })) {
+        continue;
+      }
+      var li = document.createElement("li");
+      var a = document.createElement("a");
+      a.href = e.url;
+      a.textContent = e.title;
+      var kind = document.createElement("span");
+      kind.className = "kind";
+      kind.textContent = e.kind;
+      li.appendChild(a);
+      li.appendChild(kind);
+      results.appendChild(li);
+      found++;
+    }
+    if (found === 0) {
+      var none = document.createElement("li");
+      none.textContent = "No results";
+      results.appendChild(none);
+    }
+    results.hidden = false;
+  }
+
+  if (input) {
+    input.addEventListener("input", function () { search(input.value); });
+    input.addEventListener("keydown", function (ev) {
+      if (ev.key === "Escape") {
+        input.value = "";
+        search("");
+      }
+    });
+  }
+
+  // The filters are hidden until this script runs, so they aren't shown
+  // when they can't work.
+  var filters = document.querySelectorAll(".severity-filter");
+  function applyFilters() {
+    var shown = {};
+    filters.forEach(function (f) {
+      f.querySelectorAll("input").forEach(function (box) {
+        shown[box.value] = box.checked;
+      });
+    });
+    document.querySelectorAll("[data-severity]").forEach(function (el) {
+      el.hidden = !shown[el.getAttribute("data-severity")];
+    });
+  }
+  filters.forEach(function (f) {
+    f.hidden = false;
+    f.addEventListener("change", applyFilters);
+  });
+
+  if (window.mermaid) {
+    window.mermaid.initialize({ startOnLoad: true, securityLevel: "strict" });
+  }
+})();
@@ -0,0 +1,647 @@
+// Package site builds a static HTML documentation site for a fleet of threat
+// models.
+//
+// The site is a flat directory of pages: a fleet index, a page per threat
+// model, and indexes of information assets, controls and third party
+// dependencies that link to where each one is used. Search runs in the
+// browser from a script holding the search index, and severity filters are
+// plain script over data attributes, so the site works when opened straight
+// from a file share.
+package site
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmutil"
+	"github.com/yuin/goldmark"
+)
+
+//go:embed templates/*.html assets/*
+var content embed.FS
+
+var pages = template.Must(template.New("site").ParseFS(content, "templates/*.html"))
+
+// Options tune Build.
+type Options struct {
+	// DfdSvg renders a data flow diagram as SVG, which is inlined into the
+	// threat model's page. Diagrams are left out when it's nil. link maps
+	// the name of a threat, information asset or data flow diagram, in a
+	// section named "threat", "information_asset" or "data_flow_diagram",
+	// onto its anchor in the page, so an interactive diagram can link to it.
+	DfdSvg func(tm *spec.Threatmodel, d *spec.DataFlowDiagram, link func(section, name string) string) ([]byte, error)
+
+	// MermaidJS is a copy of mermaid.min.js. When set, it's added to the
+	// site and mermaid blocks are rendered; otherwise their source is shown.
+	MermaidJS []byte
+
+	// Generated is shown in every page's footer when set.
+	Generated string
+}
+
+// File is one file of the site, relative to its root.
+type File struct {
+	Name string
+	Data []byte
+}
+
+const (
+	// unrated is the severity of threats without a risk.
+	unrated = "unrated"
+
+	mermaidFile = "mermaid.min.js"
+)
+
+// Build returns the files of a site for tms.
+func Build(tms []*spec.Threatmodel, opts Options) ([]File, error) {
+	b := &builder{
+		opts:      opts,
+		pageNames: map[string]bool{},
+		assets:    map[string]*assetGroup{},
+		controls:  map[string]*controlGroup{},
+		deps:      map[string]*depGroup{},
+
+		groupAnchors: map[string]bool{},
+	}
+
+	models := []*modelPage{}
+	for _, tm := range tms {
+		m, err := b.model(tm)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, m)
+	}
+
+	files := []File{}
+	render := func(name, tpl string, data interface{}) error {
+		var buf bytes.Buffer
+		if err := pages.ExecuteTemplate(&buf, tpl, data); err != nil {
+			return fmt.Errorf("error rendering %s: %s", name, err)
+		}
+		files = append(files, File{Name: name, Data: buf.Bytes()})
+		return nil
+	}
+
+	idx := indexPage{page: b.page("Threat models", "index"), Models: models}
+	for _, m := range models {
+		for _, t := range m.Threats {
+			idx.Threats = append(idx.Threats, fleetThreat{Model: m, Threat: t})
+		}
+	}
+	if err := render("index.html", "index", idx); err != nil {
+		return nil, err
+	}
+	for _, m := range models {
+		m.page = b.page(m.Name, "")
+		if err := render(m.File, "model", m); err != nil {
+			return nil, err
+		}
+	}
+
+	assets := groupPage{page: b.page("Information assets", "assets"), Groups: sortedValues(b.assets)}
+	if err := render("assets.html", "assets", assets); err != nil {
+		return nil, err
+	}
+
+	controls := groupPage{page: b.page("Controls", "controls"), Groups: sortedValues(b.controls)}
+	if err := render("controls.html", "controls", controls); err != nil {
+		return nil, err
+	}
+
+	deps := groupPage{page: b.page("Third party dependencies", "dependencies"), Groups: sortedValues(b.deps)}
+	if err := render("dependencies.html", "dependencies", deps); err != nil {
+		return nil, err
+	}
+
+	search, err := json.Marshal(b.search)
+	if err != nil {
+		return nil, fmt.Errorf("error writing search index: %s", err)
+	}
+	files = append(files, File{Name: "search-index.js", Data: []byte("window.threatclSearchIndex = " + string(search) + ";\n")})
+
+	for _, name := range []string{"site.css", "site.js"} {
+		data, err := content.ReadFile("assets/" + name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Name: name, Data: data})
+	}
+	if opts.MermaidJS != nil {
+		files = append(files, File{Name: mermaidFile, Data: opts.MermaidJS})
+	}
+
+	return files, nil
+}
+
+type builder struct {
+	opts Options
+
+	// pageNames are the model page file names taken so far
+	pageNames map[string]bool
+
+	// the cross-model indexes, keyed by lower case name
+	assets   map[string]*assetGroup
+	controls map[string]*controlGroup
+	deps     map[string]*depGroup
+
+	// groupAnchors are the index anchors taken so far, as two names can
+	// slug the same ("TLS 1.2" and "TLS-1-2")
+	groupAnchors map[string]bool
+
+	search []searchEntry
+}
+
+// page is what every page's layout needs.
+type page struct {
+	Title      string
+	Nav        string
+	Generated  string
+	Mermaid    bool
+	Severities []string
+}
+
+func (b *builder) page(title, nav string) page {
+	return page{Title: title, Nav: nav, Generated: b.opts.Generated, Mermaid: b.opts.MermaidJS != nil, Severities: severities()}
+}
+
+// searchEntry is one search result. Text is matched but not shown.
+type searchEntry struct {
+	Title string `json:"title"`
+	Kind  string `json:"kind"`
+	URL   string `json:"url"`
+	Text  string `json:"text"`
+}
+
+func (b *builder) index(kind, title, url string, text ...string) {
+	b.search = append(b.search, searchEntry{Title: title, Kind: kind, URL: url, Text: strings.Join(text, " ")})
+}
+
+type modelPage struct {
+	page
+
+	Name        string
+	File        string
+	Author      string
+	Description template.HTML
+	Details     [][2]string
+	Stats       stats
+
+	Dfds         []dfd
+	Mermaids     []mermaid
+	Assets       []*asset
+	Threats      []*threat
+	Dependencies []*dependency
+	UseCases     []string
+	Exclusions   []string
+}
+
+type dfd struct {
+	Name   string
+	Anchor string
+	Svg    template.HTML
+}
+
+type mermaid struct {
+	Name        string
+	Description string
+	Source      string
+}
+
+type asset struct {
+	Name           string
+	Anchor         string
+	Classification string
+	Source         string
+	Description    string
+	Threats        []link
+	Index          string
+}
+
+type threat struct {
+	Name          string
+	Anchor        string
+	Severity      string
+	Description   template.HTML
+	Stride        string
+	Impacts       string
+	Assets        []link
+	Likelihood    string
+	Impact        string
+	ResidualScore string
+	Controls      []*control
+}
+
+type control struct {
+	Name          string
+	Implemented   bool
+	RiskReduction int
+	Description   string
+	Index         string
+}
+
+type dependency struct {
+	Name        string
+	Description string
+	Uptime      string
+	UptimeNotes string
+	Kinds       string
+	Index       string
+}
+
+type link struct {
+	Text string
+	URL  string
+}
+
+// stats are a threat model's summary figures.
+type stats struct {
+	Threats     int
+	Severities  []severityCount
+	Controls    int
+	Implemented int
+	// Uncovered is the number of threats without an implemented control
+	Uncovered int
+}
+
+type severityCount struct {
+	Severity string
+	Count    int
+}
+
+func (s stats) Coverage() string {
+	if s.Controls == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%d%%", s.Implemented*100/s.Controls)
+}
+
+func (b *builder) model(tm *spec.Threatmodel) (*modelPage, error) {
+	m := &modelPage{
+		Name:        tm.Name,
+		File:        b.pageName(tm.Name),
+		Author:      tm.Author,
+		Description: markdown(tm.Description),
+	}
+	b.index("threat model", tm.Name, m.File, tm.Author, tm.Description)
+
+	if tm.Link != "" {
+		m.Details = append(m.Details, [2]string{"Link", tm.Link})
+	}
+	if tm.Attributes != nil {
+		m.Details = append(m.Details,
+			[2]string{"New initiative", yesNo(tm.Attributes.NewInitiative)},
+			[2]string{"Internet facing", yesNo(tm.Attributes.InternetFacing)},
+			[2]string{"Initiative size", tm.Attributes.InitiativeSize},
+		)
+	}
+	for _, a := range tm.AdditionalAttributes {
+		m.Details = append(m.Details, [2]string{a.Name, a.Value})
+	}
+	for _, u := range tm.UseCases {
+		m.UseCases = append(m.UseCases, strings.TrimSpace(u.Description))
+	}
+	for _, e := range tm.Exclusions {
+		m.Exclusions = append(m.Exclusions, strings.TrimSpace(e.Description))
+	}
+
+	for _, md := range tm.MermaidDiagrams {
+		m.Mermaids = append(m.Mermaids, mermaid{Name: md.Name, Description: strings.TrimSpace(md.Description), Source: strings.TrimSpace(md.Content)})
+	}
+
+	anchors := map[string]bool{}
+	assetLinks := map[string]*asset{}
+	for _, ia := range tm.InformationAssets {
+		a := &asset{
+			Name:           ia.Name,
+			Anchor:         anchor(anchors, "asset", ia.Name),
+			Classification: ia.InformationClassification,
+			Source:         ia.Source,
+			Description:    strings.TrimSpace(ia.Description),
+			Index:          "assets.html#" + b.assetGroup(ia.Name).Anchor,
+		}
+		m.Assets = append(m.Assets, a)
+		assetLinks[ia.Name] = a
+		b.index("information asset", ia.Name, m.File+"#"+a.Anchor, tm.Name, ia.InformationClassification, ia.Description)
+
+		g := b.assetGroup(ia.Name)
+		g.Uses = append(g.Uses, assetUse{Model: link{tm.Name, m.File}, Asset: a})
+	}
+
+	sevs := map[string]int{}
+	threatAnchors := map[string]string{}
+	m.Stats.Threats = len(tm.Threats)
+	for _, t := range tm.Threats {
+		th := &threat{
+			Name:        t.Name,
+			Anchor:      anchor(anchors, "threat", t.Name),
+			Severity:    severity(t),
+			Description: markdown(t.Description),
+			Stride:      strings.Join(t.Stride, ", "),
+			Impacts:     strings.Join(t.ImpactType, ", "),
+		}
+		if t.Risk != nil {
+			th.Likelihood, th.Impact = t.Risk.Likelihood, t.Risk.Impact
+			th.ResidualScore = strconv.FormatFloat(t.ResidualScore(), 'f', -1, 64)
+		}
+		sevs[th.Severity]++
+		if _, ok := threatAnchors[t.Name]; !ok {
+			threatAnchors[t.Name] = th.Anchor
+		}
+		threatLink := link{fmt.Sprintf("%s: %s", tm.Name, t.Name), m.File + "#" + th.Anchor}
+		b.index("threat", t.Name, threatLink.URL, tm.Name, th.Severity, t.Description, th.Stride, th.Impacts)
+
+		for _, ref := range t.InformationAssetRefs {
+			if a, ok := assetLinks[ref]; ok {
+				th.Assets = append(th.Assets, link{ref, "#" + a.Anchor})
+				a.Threats = append(a.Threats, link{t.Name, "#" + th.Anchor})
+			} else {
+				th.Assets = append(th.Assets, link{ref, ""})
+			}
+		}
+
+		covered := false
+		for _, c := range tmutil.AllControls(t) {
+			g := b.controlGroup(c.Name)
+			ctrl := &control{
+				Name:          c.Name,
+				Implemented:   c.Implemented,
+				RiskReduction: c.RiskReduction,
+				Description:   strings.TrimSpace(c.Description),
+				Index:         "controls.html#" + g.Anchor,
+			}
+			th.Controls = append(th.Controls, ctrl)
+			g.Uses = append(g.Uses, controlUse{Threat: threatLink, Control: ctrl})
+			if len(g.Uses) == 1 {
+				b.index("control", c.Name, ctrl.Index, c.Description)
+			}
+
+			m.Stats.Controls++
+			if c.Implemented {
+				m.Stats.Implemented++
+				covered = true
+			}
+		}
+		for _, pc := range t.ProposedControls {
+			th.Controls = append(th.Controls, &control{Name: "Proposed", Implemented: pc.Implemented, Description: strings.TrimSpace(pc.Description)})
+			covered = covered || pc.Implemented
+		}
+		if !covered {
+			m.Stats.Uncovered++
+		}
+		m.Threats = append(m.Threats, th)
+	}
+	for _, s := range severities() {
+		m.Stats.Severities = append(m.Stats.Severities, severityCount{s, sevs[s]})
+	}
+
+	if b.opts.DfdSvg != nil {
+		dfdAnchors := map[string]string{}
+		for _, d := range tm.DataFlowDiagrams {
+			a := anchor(anchors, "dfd", d.Name)
+			if _, ok := dfdAnchors[d.Name]; !ok {
+				dfdAnchors[d.Name] = a
+			}
+			m.Dfds = append(m.Dfds, dfd{Name: d.Name, Anchor: a})
+		}
+		link := func(section, name string) string {
+			switch {
+			case section == "threat" && threatAnchors[name] != "":
+				return "#" + threatAnchors[name]
+			case section == "information_asset" && assetLinks[name] != nil:
+				return "#" + assetLinks[name].Anchor
+			case section == "data_flow_diagram" && dfdAnchors[name] != "":
+				return "#" + dfdAnchors[name]
+			}
+			return ""
+		}
+		for i, d := range tm.DataFlowDiagrams {
+			svg, err := b.opts.DfdSvg(tm, d, link)
+			if err != nil {
+				return nil, fmt.Errorf("error rendering data flow diagram %q: %s", d.Name, err)
+			}
+			m.Dfds[i].Svg = inlineSvg(svg)
+		}
+	}
+
+	for _, d := range tm.ThirdPartyDependencies {
+		g := b.depGroup(d.Name)
+		kinds := []string{}
+		for _, k := range []struct {
+			set  bool
+			name string
+		}{{d.Saas, "SaaS"}, {d.PayingCustomer, "paying customer"}, {d.OpenSource, "open source"}, {d.Infrastructure, "infrastructure"}} {
+			if k.set {
+				kinds = append(kinds, k.name)
+			}
+		}
+		dep := &dependency{
+			Name:        d.Name,
+			Description: strings.TrimSpace(d.Description),
+			Uptime:      string(d.UptimeDependency),
+			UptimeNotes: strings.TrimSpace(d.UptimeNotes),
+			Kinds:       strings.Join(kinds, ", "),
+			Index:       "dependencies.html#" + g.Anchor,
+		}
+		m.Dependencies = append(m.Dependencies, dep)
+		g.Uses = append(g.Uses, depUse{Model: link{tm.Name, m.File}, Dependency: dep})
+		if len(g.Uses) == 1 {
+			b.index("third party dependency", d.Name, dep.Index, d.Description)
+		}
+	}
+
+	return m, nil
+}
+
+// pageName is a unique page file name for a threat model.
+func (b *builder) pageName(name string) string {
+	base := "tm-" + slug(name)
+	file := base + ".html"
+	for n := 2; b.pageNames[file]; n++ {
+		file = fmt.Sprintf("%s-%d.html", base, n)
+	}
+	b.pageNames[file] = true
+	return file
+}
+
+type groupPage struct {
+	page
+	Groups interface{}
+}
+
+type assetGroup struct {
+	Name   string
+	Anchor string
+	Uses   []assetUse
+}
+
+type assetUse struct {
+	Model link
+	Asset *asset
+}
+
+type controlGroup struct {
+	Name   string
+	Anchor string
+	Uses   []controlUse
+}
+
+type controlUse struct {
+	Threat  link
+	Control *control
+}
+
+// Implemented is the number of threats where the control is implemented.
+func (g *controlGroup) Implemented() int {
+	n := 0
+	for _, u := range g.Uses {
+		if u.Control.Implemented {
+			n++
+		}
+	}
+	return n
+}
+
+type depGroup struct {
+	Name   string
+	Anchor string
+	Uses   []depUse
+}
+
+type depUse struct {
+	Model      link
+	Dependency *dependency
+}
+
+// The group lookups below match names case-insensitively, so the same asset
+// or control spelt differently across models is listed once.
+
+func (b *builder) assetGroup(name string) *assetGroup {
+	k := strings.ToLower(strings.TrimSpace(name))
+	if g, ok := b.assets[k]; ok {
+		return g
+	}
+	g := &assetGroup{Name: name, Anchor: anchor(b.groupAnchors, "asset", k)}
+	b.assets[k] = g
+	return g
+}
+
+func (b *builder) controlGroup(name string) *controlGroup {
+	k := strings.ToLower(strings.TrimSpace(name))
+	if g, ok := b.controls[k]; ok {
+		return g
+	}
+	g := &controlGroup{Name: name, Anchor: anchor(b.groupAnchors, "control", k)}
+	b.controls[k] = g
+	return g
+}
+
+func (b *builder) depGroup(name string) *depGroup {
+	k := strings.ToLower(strings.TrimSpace(name))
+	if g, ok := b.deps[k]; ok {
+		return g
+	}
+	g := &depGroup{Name: name, Anchor: anchor(b.groupAnchors, "dep", k)}
+	b.deps[k] = g
+	return g
+}
+
+type indexPage struct {
+	page
+	Models  []*modelPage
+	Threats []fleetThreat
+}
+
+type fleetThreat struct {
+	Model  *modelPage
+	Threat *threat
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slug(s string) string {
+	s = strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if s == "" {
+		return "item"
+	}
+	return s
+}
+
+// anchor returns a page-unique anchor for name.
+func anchor(taken map[string]bool, prefix, name string) string {
+	base := prefix + "-" + slug(name)
+	a := base
+	for n := 2; taken[a]; n++ {
+		a = fmt.Sprintf("%s-%d", base, n)
+	}
+	taken[a] = true
+	return a
+}
+
+// markdown renders free text, such as a description, as sanitised HTML.
+// Threat model fields may come from untrusted files, so raw HTML isn't
+// passed through.
+func markdown(s string) template.HTML {
+	var buf bytes.Buffer
+	if err := goldmark.New().Convert([]byte(strings.TrimSpace(s)), &buf); err != nil {
+		return template.HTML(template.HTMLEscapeString(s))
+	}
+	return template.HTML(bluemonday.UGCPolicy().SanitizeBytes(buf.Bytes()))
+}
+
+// inlineSvg strips the XML declaration and doctype Graphviz writes before
+// the svg element, so the diagram can be inlined. Graphviz escapes the
+// labels it draws.
+func inlineSvg(svg []byte) template.HTML {
+	s := string(svg)
+	if i := strings.Index(s, "<svg"); i >= 0 {
+		s = s[i:]
+	}
+	return template.HTML(s)
+}
+
+// severities are the severity filter's values, most severe first.
+func severities() []string {
+	out := []string{}
+	for i := len(spec.SeverityLevels) - 1; i >= 0; i-- {
+		out = append(out, spec.SeverityLevels[i])
+	}
+	return append(out, unrated)
+}
+
+func severity(t *spec.Threat) string {
+	if t.Risk == nil || t.Risk.Severity() == "" {
+		return unrated
+	}
+	return t.Risk.Severity()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// sortedValues returns m's values ordered by key.
+func sortedValues[V any](m map[string]V) []V {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]V, 0, len(m))
+	for _, k := range keys {
+		values = append(values, m[k])
+	}
+	return values
+}
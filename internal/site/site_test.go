@@ -0,0 +1,234 @@
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+func siteModels() []*spec.Threatmodel {
+	return []*spec.Threatmodel{
+		{
+			Name:        "Shop",
+			Author:      "@alice",
+			Description: "An online *shop*.<script>alert(1)</script>",
+			InformationAssets: []*spec.InformationAsset{
+				{Name: "Card data", InformationClassification: "Confidential"},
+			},
+			Threats: []*spec.Threat{
+				{
+					Name:                 "SQL injection",
+					Stride:               []string{"Tampering"},
+					InformationAssetRefs: []string{"Card data"},
+					Risk:                 &spec.Risk{Likelihood: "medium", Impact: "high"},
+					Controls: []*spec.Control{
+						{Name: "Parameterised queries", Implemented: true, RiskReduction: 60},
+						{Name: "WAF"},
+					},
+				},
+				{Name: "Sniffing"},
+			},
+			ThirdPartyDependencies: []*spec.ThirdPartyDependency{
+				{Name: "Stripe", Saas: true, UptimeDependency: spec.HardUptime},
+			},
+			DataFlowDiagrams: []*spec.DataFlowDiagram{{Name: "Level 0"}, {Name: "Level 1: Web"}},
+			MermaidDiagrams:  []*spec.MermaidDiagram{{Name: "Flow", Content: "graph LR\n  a-->b"}},
+		},
+		{
+			Name:   "Warehouse",
+			Author: "@bob",
+			InformationAssets: []*spec.InformationAsset{
+				{Name: "card data", InformationClassification: "Restricted"},
+			},
+			Threats: []*spec.Threat{
+				{Name: "Theft", Controls: []*spec.Control{{Name: "WAF", Implemented: true}}},
+			},
+		},
+	}
+}
+
+func build(t *testing.T, opts Options) map[string]string {
+	t.Helper()
+	files, err := Build(siteModels(), opts)
+	if err != nil {
+		t.Fatalf("error building site: %s", err)
+	}
+	out := map[string]string{}
+	for _, f := range files {
+		out[f.Name] = string(f.Data)
+	}
+	return out
+}
+
+func TestBuild(t *testing.T) {
+	files := build(t, Options{
+		Generated: "2026-01-01",
+		DfdSvg: func(tm *spec.Threatmodel, d *spec.DataFlowDiagram, link func(section, name string) string) ([]byte, error) {
+			return []byte(`<?xml version="1.0"?>` + "\n" + `<!DOCTYPE svg>` + "\n" + fmt.Sprintf(`<svg id="dfd"><title>%s %s</title><a href="%s"></a><a href="%s"></a><a href="%s"></a><a href="%s"></a></svg>`, tm.Name, d.Name, link("threat", "SQL injection"), link("information_asset", "Card data"), link("threat", "Nope"), link("data_flow_diagram", "Level 1: Web"))), nil
+		},
+	})
+
+	for _, name := range []string{"index.html", "tm-shop.html", "tm-warehouse.html", "assets.html", "controls.html", "dependencies.html", "search-index.js", "site.css", "site.js"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("missing %s", name)
+		}
+	}
+	if _, ok := files[mermaidFile]; ok {
+		t.Errorf("%s shouldn't be written without MermaidJS", mermaidFile)
+	}
+
+	tests := []struct {
+		file string
+		exp  []string
+		not  []string
+	}{
+		{
+			"index.html",
+			[]string{
+				`<a href="tm-shop.html">Shop</a>`,
+				`<tr data-severity="unrated">`,
+				`<a href="tm-shop.html#threat-sql-injection">SQL injection</a>`,
+				`<fieldset class="severity-filter" hidden>`,
+				"Generated by threatcl on 2026-01-01",
+				`<td>50%</td>`,
+			},
+			nil,
+		},
+		{
+			"tm-shop.html",
+			[]string{
+				`<figure class="dfd" id="dfd-level-0">`,
+				`<svg id="dfd"><title>Shop Level 0</title><a href="#threat-sql-injection"></a><a href="#asset-card-data"></a><a href=""></a><a href="#dfd-level-1-web"></a></svg>`,
+				`<figure class="dfd" id="dfd-level-1-web">`,
+				`<section class="threat" id="threat-sql-injection" data-severity="unrated">`,
+				`<em>shop</em>`,
+				`<a href="#asset-card-data">Card data</a>`,
+				`<a href="controls.html#control-waf">WAF</a>`,
+				`<a href="assets.html#asset-card-data">Card data</a>`,
+				`<a href="dependencies.html#dep-stripe">Stripe</a>`,
+				"<pre><code>graph LR\n  a--&gt;b</code></pre>",
+				"60%",
+			},
+			[]string{"<script>alert", "<?xml", "DOCTYPE svg", `<pre class="mermaid">`},
+		},
+		{
+			"assets.html",
+			[]string{
+				// the same asset, spelt differently, is grouped across models
+				`<section id="asset-card-data">`,
+				`<a href="tm-shop.html#asset-card-data">Shop</a>`,
+				`<a href="tm-warehouse.html#asset-card-data">Warehouse</a>`,
+				`<a href="tm-shop.html#threat-sql-injection">SQL injection</a>`,
+			},
+			nil,
+		},
+		{
+			"controls.html",
+			[]string{
+				"Implemented for 1 of 2 threats.",
+				`<a href="tm-warehouse.html#threat-theft">Warehouse: Theft</a>`,
+			},
+			nil,
+		},
+	}
+	for _, tc := range tests {
+		for _, exp := range tc.exp {
+			if !strings.Contains(files[tc.file], exp) {
+				t.Errorf("%s: expected %q", tc.file, exp)
+			}
+		}
+		for _, not := range tc.not {
+			if strings.Contains(files[tc.file], not) {
+				t.Errorf("%s: didn't expect %q", tc.file, not)
+			}
+		}
+	}
+}
+
+func TestBuildSearchIndex(t *testing.T) {
+	files := build(t, Options{})
+
+	js := files["search-index.js"]
+	prefix := "window.threatclSearchIndex = "
+	if !strings.HasPrefix(js, prefix) {
+		t.Fatalf("unexpected search index: %s", js)
+	}
+	entries := []searchEntry{}
+	if err := json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimPrefix(js, prefix), ";\n")), &entries); err != nil {
+		t.Fatalf("error parsing search index: %s", err)
+	}
+
+	found := map[string]string{}
+	for _, e := range entries {
+		found[e.Kind+"/"+e.Title] = e.URL
+	}
+	for key, url := range map[string]string{
+		"threat model/Shop":             "tm-shop.html",
+		"threat/SQL injection":          "tm-shop.html#threat-sql-injection",
+		"information asset/card data":   "tm-warehouse.html#asset-card-data",
+		"control/WAF":                   "controls.html#control-waf",
+		"third party dependency/Stripe": "dependencies.html#dep-stripe",
+		"control/Parameterised queries": "controls.html#control-parameterised-queries",
+		"information asset/Card data":   "tm-shop.html#asset-card-data",
+		"threat/Theft":                  "tm-warehouse.html#threat-theft",
+		"threat model/Warehouse":        "tm-warehouse.html",
+		"threat/Sniffing":               "tm-shop.html#threat-sniffing",
+	} {
+		if found[key] != url {
+			t.Errorf("search index: %s: expected %q, got %q", key, url, found[key])
+		}
+	}
+	// controls are indexed once, however many threats use them
+	if len(entries) != 10 {
+		t.Errorf("expected 10 search entries, got %d", len(entries))
+	}
+}
+
+func TestBuildMermaidJS(t *testing.T) {
+	files := build(t, Options{MermaidJS: []byte("// mermaid")})
+
+	if files[mermaidFile] != "// mermaid" {
+		t.Errorf("%s wasn't written", mermaidFile)
+	}
+	if !strings.Contains(files["tm-shop.html"], `<pre class="mermaid">graph LR`) {
+		t.Errorf("mermaid block isn't marked for rendering")
+	}
+	if !strings.Contains(files["index.html"], `<script src="mermaid.min.js"></script>`) {
+		t.Errorf("mermaid.min.js isn't loaded")
+	}
+}
+
+func TestBuildDuplicateNames(t *testing.T) {
+	tms := []*spec.Threatmodel{
+		{Name: "Shop", Threats: []*spec.Threat{{Name: "A b"}, {Name: "A-b"}}},
+		{Name: "shop"},
+	}
+	files, err := Build(tms, Options{})
+	if err != nil {
+		t.Fatalf("error building site: %s", err)
+	}
+	names := map[string]string{}
+	for _, f := range files {
+		names[f.Name] = string(f.Data)
+	}
+	if _, ok := names["tm-shop-2.html"]; !ok {
+		t.Errorf("expected the second model's page to be tm-shop-2.html")
+	}
+	if !strings.Contains(names["tm-shop.html"], `id="threat-a-b-2"`) {
+		t.Errorf("expected a unique anchor for the second threat")
+	}
+}
+
+func TestBuildDfdError(t *testing.T) {
+	_, err := Build(siteModels(), Options{
+		DfdSvg: func(*spec.Threatmodel, *spec.DataFlowDiagram, func(string, string) string) ([]byte, error) {
+			return nil, fmt.Errorf("graphviz failed")
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), `error rendering data flow diagram "Level 0": graphviz failed`) {
+		t.Errorf("expected a render error, got %v", err)
+	}
+}
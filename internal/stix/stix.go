@@ -0,0 +1,292 @@
+// Package stix exports threat models as STIX 2.1 bundles for threat
+// intelligence platforms.
+//
+// Threats become attack-patterns, controls become courses of action that
+// mitigate them, information assets become infrastructure and third party
+// dependencies become identities, all collected in a report per threat
+// model. ATT&CK techniques and mitigations a control names are external
+// references of its course of action. Object ids are derived from names, so
+// importing a re-export updates the existing objects rather than
+// duplicating them.
+package stix
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/attack"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// SpecVersion is the STIX version written by NewBundle.
+const SpecVersion = "2.1"
+
+// timestamp is the STIX timestamp format, in UTC with millisecond precision.
+const timestamp = "2006-01-02T15:04:05.000Z"
+
+// capecLink is a link to an attack pattern on capec.mitre.org.
+var capecLink = regexp.MustCompile(`capec\.mitre\.org/data/definitions/(\d+)\.html`)
+
+// Bundle is a STIX bundle.
+type Bundle struct {
+	Type    string   `json:"type"`
+	ID      string   `json:"id"`
+	Objects []Object `json:"objects,omitempty"`
+}
+
+// Object is a STIX domain or relationship object. Only the properties of
+// its type are set.
+type Object struct {
+	Type               string              `json:"type"`
+	SpecVersion        string              `json:"spec_version"`
+	ID                 string              `json:"id"`
+	Created            string              `json:"created"`
+	Modified           string              `json:"modified"`
+	Name               string              `json:"name,omitempty"`
+	Description        string              `json:"description,omitempty"`
+	IdentityClass      string              `json:"identity_class,omitempty"`
+	ReportTypes        []string            `json:"report_types,omitempty"`
+	Published          string              `json:"published,omitempty"`
+	ObjectRefs         []string            `json:"object_refs,omitempty"`
+	RelationshipType   string              `json:"relationship_type,omitempty"`
+	SourceRef          string              `json:"source_ref,omitempty"`
+	TargetRef          string              `json:"target_ref,omitempty"`
+	ExternalReferences []ExternalReference `json:"external_references,omitempty"`
+
+	// threatcl properties STIX has no equivalent for
+	Stride                    []string `json:"x_threatcl_stride,omitempty"`
+	Implemented               *bool    `json:"x_threatcl_implemented,omitempty"`
+	RiskReduction             int      `json:"x_threatcl_risk_reduction,omitempty"`
+	InformationClassification string   `json:"x_threatcl_information_classification,omitempty"`
+	UptimeDependency          string   `json:"x_threatcl_uptime_dependency,omitempty"`
+}
+
+type ExternalReference struct {
+	SourceName string `json:"source_name"`
+	ExternalID string `json:"external_id,omitempty"`
+	URL        string `json:"url,omitempty"`
+}
+
+// epoch is the created time of objects from threat models without a
+// created_at, so that it doesn't change between exports.
+var epoch = time.Unix(0, 0)
+
+// Options configures NewBundle.
+type Options struct {
+	// Timestamp is written as every object's modified time. Created times
+	// come from the threat model's created_at.
+	Timestamp time.Time
+}
+
+type exporter struct {
+	ts       time.Time
+	created  string
+	modified string
+	objects  []Object
+	ids      map[string]bool
+}
+
+// NewBundle builds a bundle of tms, with a report per threat model.
+func NewBundle(tms []spec.Threatmodel, opts Options) Bundle {
+	e := &exporter{
+		ts:  opts.Timestamp,
+		ids: map[string]bool{},
+	}
+
+	seen := map[string]bool{}
+	names := []string{}
+	for _, tm := range tms {
+		key := tm.Name
+		for n := 2; seen[key]; n++ {
+			key = fmt.Sprintf("%s\x00%d", tm.Name, n)
+		}
+		seen[key] = true
+		names = append(names, key)
+
+		e.report(key, tm)
+	}
+
+	return Bundle{
+		Type:    "bundle",
+		ID:      "bundle--" + tmutil.UUID(append([]string{"bundle"}, names...)...),
+		Objects: e.objects,
+	}
+}
+
+// add appends o unless an object with its id is already in the bundle, and
+// returns its id.
+func (e *exporter) add(o Object) string {
+	o.SpecVersion = SpecVersion
+	o.Created = e.created
+	o.Modified = e.modified
+	if !e.ids[o.ID] {
+		e.ids[o.ID] = true
+		e.objects = append(e.objects, o)
+	}
+	return o.ID
+}
+
+func (e *exporter) relationship(kind, source, target string) string {
+	return e.add(Object{
+		Type:             "relationship",
+		ID:               "relationship--" + tmutil.UUID(kind, source, target),
+		RelationshipType: kind,
+		SourceRef:        source,
+		TargetRef:        target,
+	})
+}
+
+func (e *exporter) report(key string, tm spec.Threatmodel) {
+	created := epoch
+	if tm.CreatedAt != 0 {
+		created = time.Unix(tm.CreatedAt, 0)
+	}
+	modified := e.ts
+	if modified.Before(created) {
+		modified = created
+	}
+	e.created = created.UTC().Format(timestamp)
+	e.modified = modified.UTC().Format(timestamp)
+
+	refs := []string{}
+	ref := func(id string) string {
+		refs = append(refs, id)
+		return id
+	}
+
+	assets := map[string]string{}
+	for _, ia := range tm.InformationAssets {
+		assets[ia.Name] = ref(e.add(Object{
+			Type:                      "infrastructure",
+			ID:                        "infrastructure--" + tmutil.UUID("information asset", key, ia.Name),
+			Name:                      ia.Name,
+			Description:               ia.Description,
+			InformationClassification: ia.InformationClassification,
+		}))
+	}
+
+	for _, d := range tm.ThirdPartyDependencies {
+		ref(e.add(Object{
+			Type:             "identity",
+			ID:               "identity--" + tmutil.UUID("third party dependency", key, d.Name),
+			Name:             d.Name,
+			Description:      d.Description,
+			IdentityClass:    "organization",
+			UptimeDependency: string(d.UptimeDependency),
+		}))
+	}
+
+	for _, t := range tm.Threats {
+		threat := ref(e.add(Object{
+			Type:               "attack-pattern",
+			ID:                 "attack-pattern--" + tmutil.UUID("threat", key, t.Name),
+			Name:               t.Name,
+			Description:        t.Description,
+			ExternalReferences: threatReferences(t.Description),
+			Stride:             t.Stride,
+		}))
+
+		for _, name := range t.InformationAssetRefs {
+			if asset, ok := assets[name]; ok {
+				ref(e.relationship("related-to", threat, asset))
+			}
+		}
+
+		for _, c := range tmutil.AllControls(t) {
+			techniques, mitigations, _ := attack.NewMapping().IDs(c)
+
+			extRefs := []ExternalReference{}
+			for _, id := range techniques {
+				extRefs = append(extRefs, techniqueReference(id))
+			}
+			for _, id := range mitigations {
+				extRefs = append(extRefs, ExternalReference{
+					SourceName: "mitre-attack",
+					ExternalID: id,
+					URL:        fmt.Sprintf("https://attack.mitre.org/mitigations/%s/", id),
+				})
+			}
+			implemented := c.Implemented
+			coa := ref(e.add(Object{
+				Type:               "course-of-action",
+				ID:                 "course-of-action--" + tmutil.UUID("control", key, t.Name, c.Name),
+				Name:               c.Name,
+				Description:        c.Description,
+				ExternalReferences: extRefs,
+				Implemented:        &implemented,
+				RiskReduction:      c.RiskReduction,
+			}))
+
+			ref(e.relationship("mitigates", coa, threat))
+		}
+	}
+
+	// a report has to refer to something
+	if len(refs) == 0 {
+		return
+	}
+	sort.Strings(refs)
+	e.add(Object{
+		Type:        "report",
+		ID:          "report--" + tmutil.UUID("threat model", key),
+		Name:        tm.Name,
+		Description: tm.Description,
+		ReportTypes: []string{"threat-report"},
+		Published:   e.modified,
+		ObjectRefs:  dedupe(refs),
+	})
+}
+
+// threatReferences are the ATT&CK techniques and CAPEC attack patterns
+// linked from a threat's description.
+func threatReferences(desc string) []ExternalReference {
+	out := []ExternalReference{}
+	techniques, _ := attack.Links(desc)
+	for _, id := range dedupe(techniques) {
+		out = append(out, techniqueReference(id))
+	}
+	for _, l := range capecLink.FindAllStringSubmatch(desc, -1) {
+		ref := ExternalReference{
+			SourceName: "capec",
+			ExternalID: "CAPEC-" + l[1],
+			URL:        fmt.Sprintf("https://capec.mitre.org/data/definitions/%s.html", l[1]),
+		}
+		if !containsRef(out, ref) {
+			out = append(out, ref)
+		}
+	}
+	return out
+}
+
+func techniqueReference(id string) ExternalReference {
+	return ExternalReference{
+		SourceName: "mitre-attack",
+		ExternalID: id,
+		URL:        fmt.Sprintf("https://attack.mitre.org/techniques/%s/", strings.Replace(id, ".", "/", 1)),
+	}
+}
+
+func containsRef(refs []ExternalReference, ref ExternalReference) bool {
+	for _, r := range refs {
+		if r == ref {
+			return true
+		}
+	}
+	return false
+}
+
+func dedupe(in []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, s := range in {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,167 @@
+package stix
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/threatcl/spec"
+)
+
+func stixModels() []spec.Threatmodel {
+	return []spec.Threatmodel{
+		{
+			Name:      "Shop",
+			CreatedAt: 1767225600,
+			InformationAssets: []*spec.InformationAsset{
+				{Name: "Card data", InformationClassification: "Confidential"},
+			},
+			ThirdPartyDependencies: []*spec.ThirdPartyDependency{
+				{Name: "Stripe", Saas: true, UptimeDependency: spec.HardUptime},
+			},
+			Threats: []*spec.Threat{
+				{
+					Name:                 "Credential stuffing",
+					Description:          "See https://attack.mitre.org/techniques/T1110/004/ and https://capec.mitre.org/data/definitions/600.html",
+					Stride:               []string{"Spoofing"},
+					InformationAssetRefs: []string{"Card data"},
+					Controls: []*spec.Control{
+						{
+							Name:          "Lockout",
+							Implemented:   true,
+							RiskReduction: 50,
+							Description:   "[M1036](https://attack.mitre.org/mitigations/M1036/)",
+							Attributes:    []*spec.ControlAttribute{{Name: "attack_technique", Value: "T1110"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name: "Warehouse",
+			Threats: []*spec.Threat{
+				{
+					Name: "Brute force",
+					Controls: []*spec.Control{
+						{Name: "MFA", Attributes: []*spec.ControlAttribute{{Name: "attack_technique", Value: "T1110"}}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestNewBundle(t *testing.T) {
+	b := NewBundle(stixModels(), Options{Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 6000000, time.UTC)})
+
+	if b.Type != "bundle" || !strings.HasPrefix(b.ID, "bundle--") {
+		t.Errorf("unexpected bundle: %s %s", b.Type, b.ID)
+	}
+
+	byName := map[string]Object{}
+	byID := map[string]Object{}
+	count := map[string]int{}
+	for _, o := range b.Objects {
+		if o.SpecVersion != SpecVersion || o.Modified != "2026-01-02T03:04:05.006Z" {
+			t.Errorf("unexpected common properties: %+v", o)
+		}
+		if !strings.HasPrefix(o.ID, o.Type+"--") {
+			t.Errorf("id %s doesn't match type %s", o.ID, o.Type)
+		}
+		byID[o.ID] = o
+		count[o.Type]++
+		if o.Name != "" {
+			byName[o.Type+"/"+o.Name] = o
+		}
+	}
+
+	exp := map[string]int{"attack-pattern": 2, "course-of-action": 2, "infrastructure": 1, "identity": 1, "report": 2, "relationship": 3}
+	for typ, n := range exp {
+		if count[typ] != n {
+			t.Errorf("expected %d %s objects, got %d", n, typ, count[typ])
+		}
+	}
+
+	threat := byName["attack-pattern/Credential stuffing"]
+	if len(threat.ExternalReferences) != 2 ||
+		threat.ExternalReferences[0] != (ExternalReference{SourceName: "mitre-attack", ExternalID: "T1110.004", URL: "https://attack.mitre.org/techniques/T1110/004/"}) ||
+		threat.ExternalReferences[1].ExternalID != "CAPEC-600" {
+		t.Errorf("unexpected threat references: %+v", threat.ExternalReferences)
+	}
+	if len(threat.Stride) != 1 {
+		t.Errorf("expected stride to be kept: %+v", threat)
+	}
+
+	coa := byName["course-of-action/Lockout"]
+	if coa.Implemented == nil || !*coa.Implemented || coa.RiskReduction != 50 || len(coa.ExternalReferences) != 2 ||
+		coa.ExternalReferences[0] != (ExternalReference{SourceName: "mitre-attack", ExternalID: "T1110", URL: "https://attack.mitre.org/techniques/T1110/"}) ||
+		coa.ExternalReferences[1].ExternalID != "M1036" {
+		t.Errorf("unexpected course of action: %+v", coa)
+	}
+	if coa.Created != "2026-01-01T00:00:00.000Z" {
+		t.Errorf("expected the model's created_at, got %s", coa.Created)
+	}
+	if mfa := byName["course-of-action/MFA"]; mfa.Created != "1970-01-01T00:00:00.000Z" {
+		t.Errorf("expected the epoch without a created_at, got %s", mfa.Created)
+	}
+	if byName["infrastructure/Card data"].InformationClassification != "Confidential" {
+		t.Errorf("expected the asset classification to be kept")
+	}
+	if byName["identity/Stripe"].IdentityClass != "organization" {
+		t.Errorf("expected dependencies to be organisations")
+	}
+
+	mitigates := map[string]bool{}
+	for _, o := range b.Objects {
+		if o.Type != "relationship" {
+			continue
+		}
+		if _, ok := byID[o.SourceRef]; !ok {
+			t.Errorf("relationship %s has a dangling source", o.ID)
+		}
+		if _, ok := byID[o.TargetRef]; !ok {
+			t.Errorf("relationship %s has a dangling target", o.ID)
+		}
+		mitigates[byID[o.SourceRef].Name+" "+o.RelationshipType+" "+byID[o.TargetRef].Name] = true
+	}
+	for _, r := range []string{
+		"Lockout mitigates Credential stuffing",
+		"MFA mitigates Brute force",
+		"Credential stuffing related-to Card data",
+	} {
+		if !mitigates[r] {
+			t.Errorf("expected relationship %q", r)
+		}
+	}
+
+	report := byName["report/Warehouse"]
+	if len(report.ObjectRefs) != 3 || report.Published != report.Modified {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	for _, ref := range report.ObjectRefs {
+		if _, ok := byID[ref]; !ok {
+			t.Errorf("report refers to missing object %s", ref)
+		}
+	}
+}
+
+func TestNewBundleIDs(t *testing.T) {
+	a, _ := json.Marshal(NewBundle(stixModels(), Options{}))
+	b, _ := json.Marshal(NewBundle(stixModels(), Options{}))
+	if string(a) != string(b) {
+		t.Errorf("expected re-exports to be identical")
+	}
+
+	uuid := regexp.MustCompile(`^[a-z-]+--[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	for _, o := range NewBundle(stixModels(), Options{}).Objects {
+		if !uuid.MatchString(o.ID) {
+			t.Errorf("invalid id %s", o.ID)
+		}
+	}
+
+	if got := NewBundle([]spec.Threatmodel{{Name: "Empty"}}, Options{}).Objects; len(got) != 0 {
+		t.Errorf("expected no report for an empty threat model, got %+v", got)
+	}
+}
@@ -0,0 +1,357 @@
+package threatdragon
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// proposedName is the control name proposed_control blocks are written
+// under in a mitigation task list.
+const proposedName = "Proposed"
+
+// Layout of exported diagrams: one column of cells per trust zone (and one
+// for elements without a zone), each zone drawn as a box around its column.
+const (
+	columnWidth = 260.0
+	rowHeight   = 140.0
+	top         = 80.0
+)
+
+var severities = map[string]string{
+	spec.SeverityCritical: "Critical",
+	spec.SeverityHigh:     "High",
+	spec.SeverityMedium:   "Medium",
+	spec.SeverityLow:      "Low",
+	spec.SeverityInfo:     "Low",
+}
+
+// Export converts a threat model into a Threat Dragon v2 model. Threats are
+// attached to the data stores holding an information asset they reference,
+// otherwise to the first element of the first diagram. Cell and threat ids
+// are derived from names, so exporting the same model twice gives the same
+// ids.
+func Export(tm *spec.Threatmodel) Model {
+	e := &exporter{tm: tm, ids: map[string]bool{}, iaLinks: map[string]string{}}
+
+	m := Model{
+		Version: Version,
+		Summary: Summary{Title: tm.Name, Owner: tm.Author, Description: tm.Description},
+		Detail:  Detail{Contributors: []Contributor{}, Diagrams: []Diagram{}},
+	}
+	for _, aa := range tm.AdditionalAttributes {
+		switch aa.Name {
+		case "reviewer":
+			m.Detail.Reviewer = aa.Value
+		case "contributors":
+			for _, c := range strings.Split(aa.Value, ",") {
+				if c = strings.TrimSpace(c); c != "" {
+					m.Detail.Contributors = append(m.Detail.Contributors, Contributor{Name: c})
+				}
+			}
+		}
+	}
+
+	for n, d := range tm.DataFlowDiagrams {
+		m.Detail.Diagrams = append(m.Detail.Diagrams, e.diagram(n, d))
+	}
+	e.attachThreats(&m)
+
+	m.Detail.DiagramTop = len(m.Detail.Diagrams)
+	m.Detail.ThreatTop = len(tm.Threats)
+	return m
+}
+
+type exporter struct {
+	tm  *spec.Threatmodel
+	ids map[string]bool
+
+	// iaLinks is the information asset held by each data store cell
+	iaLinks map[string]string
+}
+
+// id returns a stable uuid for a cell or threat.
+func (e *exporter) id(parts ...string) string {
+	key := strings.Join(append([]string{e.tm.Name}, parts...), "\x00")
+	id := tmutil.UUID(key)
+	for n := 2; e.ids[id]; n++ {
+		id = tmutil.UUID(fmt.Sprintf("%s\x00%d", key, n))
+	}
+	e.ids[id] = true
+	return id
+}
+
+type element struct {
+	name, shape, zone, iaLink string
+}
+
+func (e *exporter) diagram(n int, d *spec.DataFlowDiagram) Diagram {
+	out := Diagram{
+		Id:          n,
+		Title:       d.Name,
+		DiagramType: "STRIDE",
+		Placeholder: "New STRIDE diagram description",
+		Thumbnail:   "./public/content/images/thumbnail.stride.jpg",
+		Version:     Version,
+		Cells:       []Cell{},
+	}
+
+	// Elements are placed in columns by trust zone, unzoned first, but
+	// written in diagram order so an import lists them in the same order.
+	els := []element{}
+	collect := func(zone string, ps []*spec.DfdProcess, ds []*spec.DfdData, ees []*spec.DfdExternal) {
+		for _, p := range ps {
+			els = append(els, element{p.Name, ShapeProcess, tmutil.FirstNonEmpty(p.TrustZone, zone), ""})
+		}
+		for _, s := range ds {
+			els = append(els, element{s.Name, ShapeStore, tmutil.FirstNonEmpty(s.TrustZone, zone), s.IaLink})
+		}
+		for _, ee := range ees {
+			els = append(els, element{ee.Name, ShapeActor, tmutil.FirstNonEmpty(ee.TrustZone, zone), ""})
+		}
+	}
+	collect("", d.Processes, d.DataStores, d.ExternalElements)
+	zones := []string{}
+	for _, tz := range d.TrustZones {
+		zones = append(zones, tz.Name)
+		collect(tz.Name, tz.Processes, tz.DataStores, tz.ExternalElements)
+	}
+	for _, el := range els {
+		if el.zone != "" && !contains(zones, el.zone) {
+			zones = append(zones, el.zone)
+		}
+	}
+
+	rows := map[string]int{}
+	for _, el := range els {
+		rows[el.zone]++
+	}
+	column := map[string]int{}
+	if rows[""] > 0 {
+		column[""] = 0
+	}
+	for _, zone := range zones {
+		column[zone] = len(column)
+	}
+
+	z := 1
+	for _, zone := range zones {
+		height := float64(rows[zone])
+		if height == 0 {
+			height = 1
+		}
+		out.Cells = append(out.Cells, Cell{
+			Id:       e.id(d.Name, "boundary", zone),
+			Shape:    ShapeBoundaryBox,
+			ZIndex:   z,
+			Position: &Position{X: float64(column[zone])*columnWidth + 20, Y: top - 40},
+			Size:     &Size{Width: columnWidth - 40, Height: height*rowHeight + 40},
+			Attrs:    label(zone),
+			Data:     CellData{Type: "tm.BoundaryBox", Name: zone, IsTrustBoundary: true},
+		})
+		z++
+	}
+
+	cellIds := map[string]string{}
+	row := map[string]int{}
+	for _, el := range els {
+		size := &Size{Width: 160, Height: 80}
+		if el.shape == ShapeProcess {
+			size = &Size{Width: 100, Height: 100}
+		}
+		x := float64(column[el.zone])*columnWidth + (columnWidth-size.Width)/2
+		y := top + float64(row[el.zone])*rowHeight
+		row[el.zone]++
+
+		id := e.id(d.Name, "element", el.name)
+		cellIds[el.name] = id
+		if el.iaLink != "" {
+			e.iaLinks[id] = el.iaLink
+		}
+
+		out.Cells = append(out.Cells, Cell{
+			Id:       id,
+			Shape:    el.shape,
+			ZIndex:   z,
+			Position: &Position{X: x, Y: y},
+			Size:     size,
+			Attrs:    label(el.name),
+			Data:     CellData{Type: cellType(el.shape), Name: el.name},
+		})
+		z++
+	}
+
+	for _, f := range d.Flows {
+		out.Cells = append(out.Cells, Cell{
+			Id:        e.id(d.Name, "flow", f.Name, f.From, f.To),
+			Shape:     ShapeFlow,
+			ZIndex:    z,
+			Connector: "smooth",
+			Labels:    []interface{}{f.Name},
+			Source:    &Terminal{Cell: cellIds[f.From]},
+			Target:    &Terminal{Cell: cellIds[f.To]},
+			Data:      CellData{Type: "tm.Flow", Name: f.Name, Protocol: f.Protocol},
+		})
+		z++
+	}
+
+	return out
+}
+
+// attachThreats puts each threat on the data stores holding an asset it
+// references, or else on the first element of the first diagram. With no
+// elements to attach to, a ThreatsDiagram holding one process is added.
+func (e *exporter) attachThreats(m *Model) {
+	if len(e.tm.Threats) == 0 {
+		return
+	}
+
+	var fallback *Cell
+	for d := range m.Detail.Diagrams {
+		for c := range m.Detail.Diagrams[d].Cells {
+			cell := &m.Detail.Diagrams[d].Cells[c]
+			if cell.Shape != ShapeFlow && cell.Shape != ShapeBoundaryBox {
+				fallback = cell
+				break
+			}
+		}
+		if fallback != nil {
+			break
+		}
+	}
+	if fallback == nil {
+		m.Detail.Diagrams = append(m.Detail.Diagrams, Diagram{
+			Id:          len(m.Detail.Diagrams),
+			Title:       ThreatsDiagram,
+			DiagramType: "STRIDE",
+			Placeholder: "Threats from a threatcl model with no data flow diagram",
+			Thumbnail:   "./public/content/images/thumbnail.stride.jpg",
+			Version:     Version,
+			Cells: []Cell{{
+				Id:       e.id(ThreatsDiagram, "element", e.tm.Name),
+				Shape:    ShapeProcess,
+				ZIndex:   1,
+				Position: &Position{X: 80, Y: top},
+				Size:     &Size{Width: 100, Height: 100},
+				Attrs:    label(e.tm.Name),
+				Data:     CellData{Type: cellType(ShapeProcess), Name: e.tm.Name},
+			}},
+		})
+		d := len(m.Detail.Diagrams) - 1
+		fallback = &m.Detail.Diagrams[d].Cells[0]
+	}
+
+	for n, t := range e.tm.Threats {
+		td := e.threat(n+1, t)
+
+		attached := false
+		for d := range m.Detail.Diagrams {
+			for c := range m.Detail.Diagrams[d].Cells {
+				cell := &m.Detail.Diagrams[d].Cells[c]
+				if ia, ok := e.iaLinks[cell.Id]; ok && contains(t.InformationAssetRefs, ia) {
+					addThreat(cell, td)
+					attached = true
+				}
+			}
+		}
+		if !attached {
+			addThreat(fallback, td)
+		}
+	}
+}
+
+func addThreat(c *Cell, t Threat) {
+	c.Data.Threats = append(c.Data.Threats, t)
+	if t.Status == StatusOpen {
+		c.Data.HasOpenThreats = true
+	}
+}
+
+func (e *exporter) threat(number int, t *spec.Threat) Threat {
+	out := Threat{
+		Id:          e.id("threat", t.Name),
+		Title:       t.Name,
+		Status:      StatusOpen,
+		Description: t.Description,
+		ModelType:   "STRIDE",
+		Number:      number,
+	}
+	if len(t.Stride) > 0 {
+		out.Type = strideType(t.Stride[0])
+	}
+	if t.Risk != nil && t.Risk.Likelihood != "" && t.Risk.Impact != "" {
+		out.Score = fmt.Sprintf("likelihood: %s, impact: %s", t.Risk.Likelihood, t.Risk.Impact)
+		out.Severity = severities[t.Risk.Severity()]
+	}
+
+	controls := tmutil.AllControls(t)
+	tasks := []string{}
+	implemented := 0
+	for _, c := range controls {
+		tasks = append(tasks, task(c.Implemented, c.Name, c.Description))
+		if c.Implemented {
+			implemented++
+		}
+	}
+	for _, pc := range t.ProposedControls {
+		tasks = append(tasks, task(pc.Implemented, proposedName, pc.Description))
+	}
+	out.Mitigation = strings.Join(tasks, "\n")
+
+	if len(controls) > 0 && implemented == len(controls) {
+		out.Status = StatusMitigated
+	}
+	return out
+}
+
+// task writes a control as a markdown task list item. Descriptions are
+// folded onto one line.
+func task(implemented bool, name, description string) string {
+	box := " "
+	if implemented {
+		box = "x"
+	}
+	line := fmt.Sprintf("- [%s] %s", box, name)
+	if d := strings.Join(strings.Fields(description), " "); d != "" {
+		line += ": " + d
+	}
+	return line
+}
+
+// strideType maps a threatcl STRIDE value onto Threat Dragon's spelling.
+func strideType(s string) string {
+	if strings.EqualFold(s, "Info Disclosure") {
+		return "Information disclosure"
+	}
+	for _, t := range strideTypes {
+		if strings.EqualFold(t, s) {
+			return t
+		}
+	}
+	return s
+}
+
+func cellType(shape string) string {
+	switch shape {
+	case ShapeActor:
+		return "tm.Actor"
+	case ShapeStore:
+		return "tm.Store"
+	}
+	return "tm.Process"
+}
+
+func label(text string) map[string]interface{} {
+	return map[string]interface{}{"text": map[string]interface{}{"text": text}}
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
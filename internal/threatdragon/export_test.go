@@ -0,0 +1,176 @@
+package threatdragon
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+func exportModel() *spec.Threatmodel {
+	return &spec.Threatmodel{
+		Name:        "Shop",
+		Description: "An online shop",
+		Author:      "@alice",
+		AdditionalAttributes: []*spec.AdditionalAttribute{
+			{Name: "reviewer", Value: "Dave"},
+		},
+		Threats: []*spec.Threat{
+			{
+				Name:                 "SQL injection",
+				Description:          "Attacker injects SQL",
+				Stride:               []string{"Tampering"},
+				InformationAssetRefs: []string{"Card data"},
+				Risk:                 &spec.Risk{Likelihood: "medium", Impact: "very_high"},
+				Controls: []*spec.Control{
+					{Name: "Parameterised queries", Description: "Use bind params", Implemented: true},
+					{Name: "WAF", Description: "Filter requests"},
+				},
+			},
+			{
+				Name:             "Sniffing",
+				Description:      "Traffic read in transit",
+				Stride:           []string{"Info Disclosure"},
+				ProposedControls: []*spec.ProposedControl{{Description: "TLS everywhere"}},
+			},
+			{
+				Name:     "Flooding",
+				Controls: []*spec.Control{{Name: "Rate limit", Implemented: true}},
+			},
+		},
+		DataFlowDiagrams: []*spec.DataFlowDiagram{
+			{
+				Name:             "Level 0",
+				ExternalElements: []*spec.DfdExternal{{Name: "Browser"}},
+				Processes:        []*spec.DfdProcess{{Name: "Worker", TrustZone: "Data Centre"}},
+				TrustZones: []*spec.DfdTrustZone{
+					{
+						Name:       "Data Centre",
+						Processes:  []*spec.DfdProcess{{Name: "Web"}},
+						DataStores: []*spec.DfdData{{Name: "Orders DB", IaLink: "Card data"}},
+					},
+				},
+				Flows: []*spec.DfdFlow{
+					{Name: "browse", From: "Browser", To: "Web", Protocol: "HTTPS"},
+					{Name: "query", From: "Web", To: "Orders DB", Protocol: "TLS"},
+				},
+			},
+		},
+	}
+}
+
+func TestExport(t *testing.T) {
+	m := Export(exportModel())
+
+	if m.Version != Version || m.Summary.Title != "Shop" || m.Summary.Owner != "@alice" || m.Detail.Reviewer != "Dave" {
+		t.Errorf("unexpected summary: %+v %+v", m.Summary, m.Detail)
+	}
+	if len(m.Detail.Diagrams) != 1 || m.Detail.ThreatTop != 3 {
+		t.Fatalf("unexpected detail: %+v", m.Detail)
+	}
+
+	cells := m.Detail.Diagrams[0].Cells
+	shapes := []string{}
+	for _, c := range cells {
+		shapes = append(shapes, c.Shape+"/"+c.Data.Name)
+	}
+	exp := []string{
+		"trust-boundary-box/Data Centre",
+		"process/Worker",
+		"actor/Browser",
+		"process/Web",
+		"store/Orders DB",
+		"flow/browse",
+		"flow/query",
+	}
+	if !reflect.DeepEqual(shapes, exp) {
+		t.Fatalf("unexpected cells:\n%v\n%v", shapes, exp)
+	}
+
+	// Zoned elements sit inside their boundary box, the rest outside.
+	for _, c := range cells[1:5] {
+		zone := boxFor(c, cells[:1])
+		if (c.Data.Name == "Browser") != (zone == "") {
+			t.Errorf("%s placed in zone %q", c.Data.Name, zone)
+		}
+	}
+
+	if cells[5].Source.Cell != cells[2].Id || cells[5].Target.Cell != cells[3].Id || cells[5].Data.Protocol != "HTTPS" {
+		t.Errorf("unexpected flow: %+v", cells[5])
+	}
+
+	// The threat on the card data goes on the store holding it, the rest on
+	// the first element.
+	store, worker := cells[4].Data, cells[1].Data
+	if len(store.Threats) != 1 || store.Threats[0].Title != "SQL injection" || !store.HasOpenThreats {
+		t.Fatalf("unexpected store threats: %+v", store.Threats)
+	}
+	if len(worker.Threats) != 2 || worker.Threats[0].Type != "Information disclosure" || worker.Threats[1].Status != StatusMitigated {
+		t.Errorf("unexpected fallback threats: %+v", worker.Threats)
+	}
+
+	sqli := store.Threats[0]
+	if sqli.Status != StatusOpen || sqli.Score != "likelihood: medium, impact: very_high" ||
+		sqli.Mitigation != "- [x] Parameterised queries: Use bind params\n- [ ] WAF: Filter requests" {
+		t.Errorf("unexpected threat: %+v", sqli)
+	}
+
+	// Stable: the same model always gives the same document.
+	a, _ := json.Marshal(m)
+	b, _ := json.Marshal(Export(exportModel()))
+	if string(a) != string(b) {
+		t.Errorf("expected repeated exports to be identical")
+	}
+}
+
+func TestExportNoDiagrams(t *testing.T) {
+	m := Export(&spec.Threatmodel{Name: "tm", Threats: []*spec.Threat{{Name: "T"}}})
+	if len(m.Detail.Diagrams) != 1 || m.Detail.Diagrams[0].Title != ThreatsDiagram {
+		t.Fatalf("expected a diagram to hold the threats: %+v", m.Detail.Diagrams)
+	}
+
+	res := Import(m, testOpts)
+	if len(res.Threatmodel.DataFlowDiagrams) != 0 || len(res.Threatmodel.Threats) != 1 {
+		t.Errorf("expected the threats diagram to only carry threats: %+v", res.Threatmodel)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	data, err := json.Marshal(Export(exportModel()))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	models, err := Parse(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	res := Import(models[0], testOpts)
+	if len(res.Warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", res.Warnings)
+	}
+
+	// Threat Dragon has no information assets, and trust zone blocks come
+	// back as trust_zone attributes on each element.
+	exp := exportModel()
+	exp.Threats[0].InformationAssetRefs = nil
+	l0 := exp.DataFlowDiagrams[0]
+	for _, tz := range l0.TrustZones {
+		for _, p := range tz.Processes {
+			p.TrustZone = tz.Name
+			l0.Processes = append(l0.Processes, p)
+		}
+		for _, ds := range tz.DataStores {
+			ds.TrustZone = tz.Name
+			ds.IaLink = ""
+			l0.DataStores = append(l0.DataStores, ds)
+		}
+	}
+	l0.TrustZones = nil
+
+	if !reflect.DeepEqual(res.Threatmodel, exp) {
+		a, _ := json.MarshalIndent(exp, "", "  ")
+		b, _ := json.MarshalIndent(res.Threatmodel, "", "  ")
+		t.Errorf("round trip changed the model:\nexpected %s\ngot %s", a, b)
+	}
+}
@@ -0,0 +1,369 @@
+package threatdragon
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/threatcl/spec"
+)
+
+// Options tune the import.
+type Options struct {
+	// Stride and ImpactTypes are the allowed stride and impacts values (see
+	// spec.ThreatmodelSpecConfig). Threat types are matched against them.
+	Stride      []string
+	ImpactTypes []string
+}
+
+// Result is an imported threat model and anything that didn't map cleanly.
+type Result struct {
+	Threatmodel *spec.Threatmodel
+	Warnings    []string
+}
+
+var (
+	// taskLine is one control in a mitigation written by Export.
+	taskLine = regexp.MustCompile(`^- \[([ xX])\] (.+?)(?:: (.*))?$`)
+
+	// scoreRisk is a threatcl risk written into a threat's score by Export.
+	scoreRisk = regexp.MustCompile(`^likelihood: *([a-z_]+), *impact: *([a-z_]+)$`)
+)
+
+// Import converts a Threat Dragon model into a threat model.
+func Import(m Model, opts Options) *Result {
+	i := &importer{
+		m:       m,
+		opts:    opts,
+		tm:      &spec.Threatmodel{},
+		threats: map[string]*spec.Threat{},
+		numbers: map[*spec.Threat]int{},
+		names:   map[string]bool{},
+	}
+
+	i.summary()
+	for _, d := range m.Detail.Diagrams {
+		i.diagram(d)
+	}
+
+	// Threats are collected cell by cell; Threat Dragon numbers them in the
+	// order they were added.
+	sort.SliceStable(i.tm.Threats, func(a, b int) bool {
+		return i.numbers[i.tm.Threats[a]] < i.numbers[i.tm.Threats[b]]
+	})
+
+	return &Result{Threatmodel: i.tm, Warnings: i.warnings}
+}
+
+type importer struct {
+	m        Model
+	opts     Options
+	tm       *spec.Threatmodel
+	warnings []string
+
+	// threats by Threat Dragon id, as the same threat can sit on several
+	// cells, their Threat Dragon numbers, and the threat names taken so far
+	threats map[string]*spec.Threat
+	numbers map[*spec.Threat]int
+	names   map[string]bool
+}
+
+func (i *importer) warn(format string, args ...interface{}) {
+	i.warnings = append(i.warnings, fmt.Sprintf(format, args...))
+}
+
+func (i *importer) summary() {
+	s, d := i.m.Summary, i.m.Detail
+
+	i.tm.Name = s.Title
+	i.tm.Author = s.Owner
+	i.tm.Description = s.Description
+	if i.tm.Author == "" {
+		i.warn("model %q has no owner, author is empty", s.Title)
+	}
+
+	if d.Reviewer != "" {
+		i.tm.AdditionalAttributes = append(i.tm.AdditionalAttributes, &spec.AdditionalAttribute{Name: "reviewer", Value: d.Reviewer})
+	}
+	contributors := []string{}
+	for _, c := range d.Contributors {
+		if c.Name != "" {
+			contributors = append(contributors, c.Name)
+		}
+	}
+	if len(contributors) > 0 {
+		i.tm.AdditionalAttributes = append(i.tm.AdditionalAttributes, &spec.AdditionalAttribute{Name: "contributors", Value: strings.Join(contributors, ", ")})
+	}
+}
+
+// diagram converts one diagram into a data_flow_diagram_v2, collecting the
+// threats on its cells as it goes.
+func (i *importer) diagram(d Diagram) {
+	if d.Title == ThreatsDiagram {
+		for _, c := range d.Cells {
+			i.cellThreats(c)
+		}
+		return
+	}
+
+	dfd := &spec.DataFlowDiagram{Name: d.Title}
+	if dfd.Name == "" {
+		dfd.Name = fmt.Sprintf("Diagram %d", d.Id)
+	}
+
+	boxes := []Cell{}
+	for _, c := range d.Cells {
+		if c.Shape == ShapeBoundaryBox {
+			boxes = append(boxes, c)
+		}
+	}
+
+	// cell id -> element name
+	elems := map[string]string{}
+	taken := map[string]bool{}
+
+	for _, c := range d.Cells {
+		var kind string
+		switch c.Shape {
+		case ShapeActor:
+			kind = "external_element"
+		case ShapeProcess:
+			kind = "process"
+		case ShapeStore:
+			kind = "data_store"
+		case ShapeFlow, ShapeBoundaryBox:
+			continue
+		case ShapeBoundary:
+			i.warn("diagram %q: trust boundary line %q can't be expressed as a trust zone, skipped", dfd.Name, c.Data.Name)
+			continue
+		default:
+			i.warn("diagram %q: unsupported cell %q (%s), skipped", dfd.Name, c.Data.Name, c.Shape)
+			continue
+		}
+
+		name := strings.TrimSpace(c.Data.Name)
+		if name == "" {
+			name = c.Shape
+		}
+		base := name
+		for n := 2; taken[name]; n++ {
+			name = fmt.Sprintf("%s (%d)", base, n)
+		}
+		if name != base {
+			i.warn("diagram %q: %s name %q is already used, imported as %q", dfd.Name, kind, base, name)
+		}
+		taken[name] = true
+		elems[c.Id] = name
+
+		i.unsupported(dfd.Name, kind, name, c.Data)
+		zone := boxFor(c, boxes)
+
+		switch kind {
+		case "external_element":
+			dfd.ExternalElements = append(dfd.ExternalElements, &spec.DfdExternal{Name: name, TrustZone: zone})
+		case "process":
+			dfd.Processes = append(dfd.Processes, &spec.DfdProcess{Name: name, TrustZone: zone})
+		case "data_store":
+			dfd.DataStores = append(dfd.DataStores, &spec.DfdData{Name: name, TrustZone: zone})
+		}
+		i.cellThreats(c)
+	}
+
+	for _, c := range d.Cells {
+		if c.Shape != ShapeFlow {
+			continue
+		}
+
+		var from, to string
+		if c.Source != nil {
+			from = elems[c.Source.Cell]
+		}
+		if c.Target != nil {
+			to = elems[c.Target.Cell]
+		}
+		if from == "" || to == "" {
+			i.warn("diagram %q: flow %q isn't connected at both ends, skipped", dfd.Name, c.Data.Name)
+			continue
+		}
+
+		i.unsupported(dfd.Name, "flow", c.Data.Name, c.Data)
+		dfd.Flows = append(dfd.Flows, &spec.DfdFlow{Name: c.Data.Name, From: from, To: to, Protocol: c.Data.Protocol})
+		if c.Data.IsBidirectional {
+			dfd.Flows = append(dfd.Flows, &spec.DfdFlow{Name: c.Data.Name, From: to, To: from, Protocol: c.Data.Protocol})
+		}
+		i.cellThreats(c)
+	}
+
+	i.tm.DataFlowDiagrams = append(i.tm.DataFlowDiagrams, dfd)
+}
+
+// unsupported warns about cell properties that were set but have nowhere to
+// go in a data_flow_diagram_v2.
+func (i *importer) unsupported(diagram, kind, name string, d CellData) {
+	set := []string{}
+	if d.Description != "" {
+		set = append(set, "description")
+	}
+	if d.OutOfScope {
+		set = append(set, "outOfScope")
+	}
+	for _, k := range sortedKeys(d.Other) {
+		switch v := d.Other[k].(type) {
+		case bool:
+			if v {
+				set = append(set, k)
+			}
+		case string:
+			if v != "" {
+				set = append(set, fmt.Sprintf("%s=%s", k, v))
+			}
+		}
+	}
+	if len(set) > 0 {
+		i.warn("diagram %q: %s %q: unsupported properties not imported: %s", diagram, kind, name, strings.Join(set, ", "))
+	}
+}
+
+// cellThreats adds the threats on c that haven't been seen on another cell.
+func (i *importer) cellThreats(c Cell) {
+	for _, t := range c.Data.Threats {
+		if t.Id != "" {
+			if _, ok := i.threats[t.Id]; ok {
+				continue
+			}
+		}
+
+		name := strings.TrimSpace(t.Title)
+		if name == "" {
+			name = "Untitled threat"
+		}
+		base := name
+		for n := 2; i.names[name]; n++ {
+			name = fmt.Sprintf("%s (%d)", base, n)
+		}
+		if name != base {
+			i.warn("threat name %q is already used, imported as %q", base, name)
+		}
+		i.names[name] = true
+
+		threat := &spec.Threat{Name: name, Description: t.Description}
+		if t.Type != "" {
+			if s, ok := i.stride(t.Type); ok {
+				threat.Stride = []string{s}
+			} else if it, ok := matchFold(i.opts.ImpactTypes, t.Type); ok {
+				threat.ImpactType = []string{it}
+			} else {
+				i.warn("threat %q: type %q is neither a STRIDE element nor an impact type, skipped", name, t.Type)
+			}
+		}
+
+		if m := scoreRisk.FindStringSubmatch(strings.TrimSpace(t.Score)); m != nil {
+			threat.Risk = &spec.Risk{Likelihood: m[1], Impact: m[2]}
+		} else if t.Score != "" {
+			i.warn("threat %q: score %q isn't a threatcl risk, skipped", name, t.Score)
+		}
+
+		if t.Status == StatusNotApplicable {
+			i.warn("threat %q is marked not applicable, threatcl has no such state", name)
+		}
+		i.mitigation(threat, t)
+
+		if t.Id != "" {
+			i.threats[t.Id] = threat
+		}
+		i.numbers[threat] = t.Number
+		i.tm.Threats = append(i.tm.Threats, threat)
+	}
+}
+
+// mitigation turns a threat's mitigation text into controls: one per line
+// when it's a task list written by Export, otherwise a single control that's
+// implemented when the threat is mitigated.
+func (i *importer) mitigation(threat *spec.Threat, t Threat) {
+	text := strings.TrimSpace(t.Mitigation)
+	if text == "" {
+		if t.Status == StatusMitigated {
+			i.warn("threat %q is mitigated but has no mitigation, no control imported", threat.Name)
+		}
+		return
+	}
+
+	lines := strings.Split(text, "\n")
+	tasks := [][]string{}
+	for _, l := range lines {
+		if m := taskLine.FindStringSubmatch(strings.TrimSpace(l)); m != nil {
+			tasks = append(tasks, m)
+		}
+	}
+
+	if len(tasks) != len(lines) {
+		threat.Controls = append(threat.Controls, &spec.Control{
+			Name:        "Mitigation",
+			Implemented: t.Status == StatusMitigated,
+			Description: text,
+		})
+		return
+	}
+
+	for _, m := range tasks {
+		implemented := m[1] != " "
+		if m[2] == proposedName {
+			threat.ProposedControls = append(threat.ProposedControls, &spec.ProposedControl{Implemented: implemented, Description: m[3]})
+			continue
+		}
+		threat.Controls = append(threat.Controls, &spec.Control{Name: m[2], Implemented: implemented, Description: m[3]})
+	}
+}
+
+// stride maps a threat type onto the configured STRIDE values. Threat Dragon
+// spells out "Information disclosure".
+func (i *importer) stride(t string) (string, bool) {
+	if s, ok := matchFold(i.opts.Stride, t); ok {
+		return s, true
+	}
+	if strings.EqualFold(t, "Information disclosure") {
+		return matchFold(i.opts.Stride, "Info Disclosure")
+	}
+	return "", false
+}
+
+// boxFor returns the smallest trust boundary box containing the centre of c.
+func boxFor(c Cell, boxes []Cell) string {
+	if c.Position == nil || c.Size == nil {
+		return ""
+	}
+	cx, cy := c.Position.X+c.Size.Width/2, c.Position.Y+c.Size.Height/2
+
+	zone, area := "", 0.0
+	for _, b := range boxes {
+		if b.Position == nil || b.Size == nil {
+			continue
+		}
+		if cx < b.Position.X || cx > b.Position.X+b.Size.Width || cy < b.Position.Y || cy > b.Position.Y+b.Size.Height {
+			continue
+		}
+		if a := b.Size.Width * b.Size.Height; zone == "" || a < area {
+			zone, area = b.Data.Name, a
+		}
+	}
+	return zone
+}
+
+func matchFold(list []string, s string) (string, bool) {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,161 @@
+package threatdragon
+
+import (
+	"strings"
+	"testing"
+)
+
+var testOpts = Options{
+	Stride:      []string{"Spoofing", "Tampering", "Repudiation", "Info Disclosure", "Denial Of Service", "Elevation Of Privilege"},
+	ImpactTypes: []string{"Confidentiality", "Integrity", "Availability"},
+}
+
+// A model as saved by Threat Dragon 2: an actor outside a trust boundary box,
+// a process and store inside it, and threats on the cells.
+const testModel = `{
+  "version": "2.2.0",
+  "summary": {"title": "Shop", "owner": "@alice", "description": "An online shop", "id": 0},
+  "detail": {
+    "contributors": [{"name": "Bob"}, {"name": "Carol"}],
+    "reviewer": "Dave",
+    "diagramTop": 1,
+    "threatTop": 3,
+    "diagrams": [{
+      "id": 0,
+      "title": "Level 0",
+      "diagramType": "STRIDE",
+      "version": "2.2.0",
+      "cells": [
+        {"id": "tb", "shape": "trust-boundary-box", "position": {"x": 250, "y": 20}, "size": {"width": 500, "height": 300},
+         "data": {"type": "tm.BoundaryBox", "name": "Data Centre", "isTrustBoundary": true}},
+        {"id": "tl", "shape": "trust-boundary-curve", "source": {"x": 200, "y": 0}, "target": {"x": 200, "y": 400},
+         "data": {"type": "tm.Boundary", "name": "Internet", "isTrustBoundary": true}},
+        {"id": "a", "shape": "actor", "position": {"x": 20, "y": 100}, "size": {"width": 160, "height": 80},
+         "data": {"type": "tm.Actor", "name": "Customer", "description": "", "outOfScope": false, "providesAuthentication": true}},
+        {"id": "p", "shape": "process", "position": {"x": 300, "y": 100}, "size": {"width": 100, "height": 100},
+         "data": {"type": "tm.Process", "name": "Web", "description": "", "privilegeLevel": "", "isWebApplication": false,
+           "threats": [
+             {"id": "t2", "number": 2, "title": "Spoofed session", "status": "Open", "severity": "High", "type": "Spoofing",
+              "description": "Session tokens stolen", "mitigation": "Short lived tokens"},
+             {"id": "t3", "number": 3, "title": "Outage", "status": "NotApplicable", "type": "Linkability", "score": "9.1"}
+           ]}},
+        {"id": "s", "shape": "store", "position": {"x": 500, "y": 100}, "size": {"width": 160, "height": 80},
+         "data": {"type": "tm.Store", "name": "Orders DB", "isEncrypted": true,
+           "threats": [
+             {"id": "t1", "number": 1, "title": "SQL injection", "status": "Mitigated", "type": "Tampering",
+              "description": "Attacker injects SQL", "score": "likelihood: medium, impact: very_high",
+              "mitigation": "- [x] Parameterised queries: Use bind params\n- [ ] WAF\n- [ ] Proposed: Rate limit"}
+           ]}},
+        {"id": "f1", "shape": "flow", "source": {"cell": "a"}, "target": {"cell": "p"},
+         "data": {"type": "tm.Flow", "name": "browse", "protocol": "HTTPS", "isBidirectional": true, "isEncrypted": true}},
+        {"id": "f2", "shape": "flow", "source": {"cell": "p"}, "target": {"cell": "s"},
+         "data": {"type": "tm.Flow", "name": "query", "protocol": "TLS"}},
+        {"id": "f3", "shape": "flow", "source": {"cell": "p"}, "target": {"x": 10, "y": 10},
+         "data": {"type": "tm.Flow", "name": "dangling"}}
+      ]
+    }]
+  }
+}`
+
+func TestImport(t *testing.T) {
+	models, err := Parse([]byte(testModel))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	res := Import(models[0], testOpts)
+	tm := res.Threatmodel
+
+	if tm.Name != "Shop" || tm.Author != "@alice" || tm.Description != "An online shop" {
+		t.Errorf("unexpected threatmodel: %q %q %q", tm.Name, tm.Author, tm.Description)
+	}
+	if len(tm.AdditionalAttributes) != 2 || tm.AdditionalAttributes[0].Value != "Dave" || tm.AdditionalAttributes[1].Value != "Bob, Carol" {
+		t.Errorf("unexpected additional attributes: %+v", tm.AdditionalAttributes)
+	}
+
+	if len(tm.DataFlowDiagrams) != 1 {
+		t.Fatalf("expected 1 diagram, got %d", len(tm.DataFlowDiagrams))
+	}
+	dfd := tm.DataFlowDiagrams[0]
+	if len(dfd.ExternalElements) != 1 || dfd.ExternalElements[0].TrustZone != "" {
+		t.Errorf("unexpected external elements: %+v", dfd.ExternalElements)
+	}
+	if len(dfd.Processes) != 1 || dfd.Processes[0].TrustZone != "Data Centre" {
+		t.Errorf("unexpected processes: %+v", dfd.Processes)
+	}
+	if len(dfd.DataStores) != 1 || dfd.DataStores[0].Name != "Orders DB" || dfd.DataStores[0].TrustZone != "Data Centre" {
+		t.Errorf("unexpected data stores: %+v", dfd.DataStores)
+	}
+	if len(dfd.Flows) != 3 || dfd.Flows[1].From != "Web" || dfd.Flows[1].To != "Customer" || dfd.Flows[2].Protocol != "TLS" {
+		t.Errorf("expected the bidirectional flow in both directions: %+v", dfd.Flows)
+	}
+
+	names := []string{}
+	for _, th := range tm.Threats {
+		names = append(names, th.Name)
+	}
+	if strings.Join(names, ",") != "SQL injection,Spoofed session,Outage" {
+		t.Fatalf("expected threats in number order, got %v", names)
+	}
+
+	sqli := tm.Threats[0]
+	if len(sqli.Stride) != 1 || sqli.Stride[0] != "Tampering" || sqli.Risk == nil || sqli.Risk.Impact != "very_high" {
+		t.Errorf("unexpected threat: %+v", sqli)
+	}
+	if len(sqli.Controls) != 2 || !sqli.Controls[0].Implemented || sqli.Controls[0].Description != "Use bind params" ||
+		sqli.Controls[1].Name != "WAF" || sqli.Controls[1].Implemented {
+		t.Errorf("unexpected controls: %+v %+v", sqli.Controls[0], sqli.Controls[1])
+	}
+	if len(sqli.ProposedControls) != 1 || sqli.ProposedControls[0].Description != "Rate limit" {
+		t.Errorf("unexpected proposed controls: %+v", sqli.ProposedControls)
+	}
+
+	spoof := tm.Threats[1]
+	if len(spoof.Controls) != 1 || spoof.Controls[0].Name != "Mitigation" || spoof.Controls[0].Implemented ||
+		spoof.Controls[0].Description != "Short lived tokens" {
+		t.Errorf("expected free text mitigation as one control: %+v", spoof.Controls)
+	}
+
+	warnings := strings.Join(res.Warnings, "\n")
+	for _, exp := range []string{
+		`trust boundary line "Internet"`,
+		`external_element "Customer": unsupported properties not imported: providesAuthentication`,
+		`data_store "Orders DB": unsupported properties not imported: isEncrypted`,
+		`flow "dangling" isn't connected`,
+		`threat "Outage" is marked not applicable`,
+		`type "Linkability" is neither`,
+		`score "9.1" isn't a threatcl risk`,
+	} {
+		if !strings.Contains(warnings, exp) {
+			t.Errorf("expected warnings to contain %q:\n%s", exp, warnings)
+		}
+	}
+	if strings.Contains(warnings, "privilegeLevel") || strings.Contains(warnings, "isWebApplication") {
+		t.Errorf("unexpected warning for an unset property:\n%s", warnings)
+	}
+}
+
+func TestImportSharedThreat(t *testing.T) {
+	models, err := Parse([]byte(`{"summary": {"title": "tm", "owner": "me"}, "detail": {"diagrams": [{"title": "d", "cells": [
+  {"id": "a", "shape": "store", "data": {"name": "A", "threats": [{"id": "t", "title": "T"}]}},
+  {"id": "b", "shape": "store", "data": {"name": "B", "threats": [{"id": "t", "title": "T"}, {"id": "u", "title": "T"}]}}
+]}]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	res := Import(models[0], testOpts)
+	if len(res.Threatmodel.Threats) != 2 || res.Threatmodel.Threats[1].Name != "T (2)" {
+		t.Errorf("expected a threat on two cells to be imported once: %+v", res.Threatmodel.Threats)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for name, in := range map[string]string{
+		"json":  `{`,
+		"empty": `{}`,
+		"v1":    `{"summary": {"title": "old"}, "detail": {"diagrams": [{"diagramJson": {"cells": []}}]}}`,
+	} {
+		if _, err := Parse([]byte(in)); err == nil || !strings.HasPrefix(err.Error(), "error parsing threat dragon") {
+			t.Errorf("%s: expected a parse error, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Package threatdragon converts between OWASP Threat Dragon v2 models and
+// threatcl threat models.
+//
+// Each Threat Dragon diagram maps onto a data_flow_diagram_v2: actor, process
+// and store cells become external elements, processes and data stores,
+// trust boundary boxes become trust zones and flow cells become flows.
+// Threats live on cells in Threat Dragon and at the top of a threatcl model;
+// a threat's mitigation text carries its controls as a markdown task list
+// ("- [x] Name: description"), so controls and their implemented state
+// survive a round trip.
+package threatdragon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Version is the Threat Dragon model version Export writes.
+const Version = "2.2.0"
+
+// Cell shapes.
+const (
+	ShapeActor       = "actor"
+	ShapeProcess     = "process"
+	ShapeStore       = "store"
+	ShapeFlow        = "flow"
+	ShapeBoundaryBox = "trust-boundary-box"
+	ShapeBoundary    = "trust-boundary-curve"
+	ShapeText        = "td-text-block"
+)
+
+// Threat statuses.
+const (
+	StatusOpen          = "Open"
+	StatusMitigated     = "Mitigated"
+	StatusNotApplicable = "NotApplicable"
+)
+
+// ThreatsDiagram is the title of the diagram Export creates to hold threats
+// when the model has no diagram elements to attach them to. Import doesn't
+// turn it back into a data_flow_diagram_v2.
+const ThreatsDiagram = "threatcl threats"
+
+// strideTypes are Threat Dragon's STRIDE threat types.
+var strideTypes = []string{
+	"Spoofing",
+	"Tampering",
+	"Repudiation",
+	"Information disclosure",
+	"Denial of service",
+	"Elevation of privilege",
+}
+
+// Model is a Threat Dragon v2 threat model.
+type Model struct {
+	Version string  `json:"version"`
+	Summary Summary `json:"summary"`
+	Detail  Detail  `json:"detail"`
+}
+
+type Summary struct {
+	Title       string `json:"title"`
+	Owner       string `json:"owner"`
+	Description string `json:"description"`
+	Id          int    `json:"id"`
+}
+
+type Detail struct {
+	Contributors []Contributor `json:"contributors"`
+	Diagrams     []Diagram     `json:"diagrams"`
+	DiagramTop   int           `json:"diagramTop"`
+	Reviewer     string        `json:"reviewer"`
+	ThreatTop    int           `json:"threatTop"`
+}
+
+type Contributor struct {
+	Name string `json:"name"`
+}
+
+type Diagram struct {
+	Id          int    `json:"id"`
+	Title       string `json:"title"`
+	DiagramType string `json:"diagramType"`
+	Placeholder string `json:"placeholder"`
+	Thumbnail   string `json:"thumbnail"`
+	Version     string `json:"version"`
+	Cells       []Cell `json:"cells"`
+
+	// DiagramJson is only set by Threat Dragon v1 models.
+	DiagramJson json.RawMessage `json:"diagramJson,omitempty"`
+}
+
+type Cell struct {
+	Id        string                 `json:"id"`
+	Shape     string                 `json:"shape"`
+	ZIndex    int                    `json:"zIndex"`
+	Position  *Position              `json:"position,omitempty"`
+	Size      *Size                  `json:"size,omitempty"`
+	Attrs     map[string]interface{} `json:"attrs,omitempty"`
+	Connector string                 `json:"connector,omitempty"`
+	Labels    []interface{}          `json:"labels,omitempty"`
+	Source    *Terminal              `json:"source,omitempty"`
+	Target    *Terminal              `json:"target,omitempty"`
+	Data      CellData               `json:"data"`
+}
+
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type Size struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Terminal is a flow or boundary end: a cell, or a free point.
+type Terminal struct {
+	Cell string   `json:"cell,omitempty"`
+	X    *float64 `json:"x,omitempty"`
+	Y    *float64 `json:"y,omitempty"`
+}
+
+// CellData is the Threat Dragon properties of a cell. Properties threatcl has
+// no use for (isEncrypted, privilegeLevel, ...) are kept in Other.
+type CellData struct {
+	Type             string   `json:"type"`
+	Name             string   `json:"name"`
+	Description      string   `json:"description"`
+	OutOfScope       bool     `json:"outOfScope"`
+	ReasonOutOfScope string   `json:"reasonOutOfScope"`
+	HasOpenThreats   bool     `json:"hasOpenThreats"`
+	IsTrustBoundary  bool     `json:"isTrustBoundary,omitempty"`
+	Protocol         string   `json:"protocol,omitempty"`
+	IsBidirectional  bool     `json:"isBidirectional,omitempty"`
+	Threats          []Threat `json:"threats,omitempty"`
+
+	Other map[string]interface{} `json:"-"`
+}
+
+type Threat struct {
+	Id          string `json:"id"`
+	Title       string `json:"title"`
+	Status      string `json:"status"`
+	Severity    string `json:"severity"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Mitigation  string `json:"mitigation"`
+	ModelType   string `json:"modelType"`
+	New         bool   `json:"new"`
+	Number      int    `json:"number"`
+	Score       string `json:"score"`
+}
+
+// knownData is every CellData property with a field of its own.
+var knownData = map[string]bool{
+	"type": true, "name": true, "description": true, "outOfScope": true,
+	"reasonOutOfScope": true, "hasOpenThreats": true, "isTrustBoundary": true,
+	"protocol": true, "isBidirectional": true, "threats": true,
+}
+
+func (d *CellData) UnmarshalJSON(b []byte) error {
+	type plain CellData
+	if err := json.Unmarshal(b, (*plain)(d)); err != nil {
+		return err
+	}
+
+	all := map[string]interface{}{}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return err
+	}
+	for k, v := range all {
+		if !knownData[k] {
+			if d.Other == nil {
+				d.Other = map[string]interface{}{}
+			}
+			d.Other[k] = v
+		}
+	}
+	return nil
+}
+
+func (d CellData) MarshalJSON() ([]byte, error) {
+	type plain CellData
+	known, err := json.Marshal(plain(d))
+	if err != nil || len(d.Other) == 0 {
+		return known, err
+	}
+
+	all := map[string]interface{}{}
+	if err := json.Unmarshal(known, &all); err != nil {
+		return nil, err
+	}
+	for k, v := range d.Other {
+		all[k] = v
+	}
+	return json.Marshal(all)
+}
+
+// Parse reads a Threat Dragon v2 model, or an array of them as written by
+// Export for several threat models.
+func Parse(data []byte) ([]Model, error) {
+	data = bytes.TrimSpace(data)
+
+	models := []Model{}
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &models); err != nil {
+			return nil, fmt.Errorf("error parsing threat dragon: %s", err)
+		}
+	} else {
+		m := Model{}
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("error parsing threat dragon: %s", err)
+		}
+		models = append(models, m)
+	}
+
+	for _, m := range models {
+		if m.Summary.Title == "" && m.Detail.Diagrams == nil {
+			return nil, fmt.Errorf("error parsing threat dragon: not a Threat Dragon model")
+		}
+		for _, d := range m.Detail.Diagrams {
+			if len(d.DiagramJson) > 0 {
+				return nil, fmt.Errorf("error parsing threat dragon: %q is a Threat Dragon v1 model, open and save it in Threat Dragon 2 first", m.Summary.Title)
+			}
+		}
+	}
+	return models, nil
+}
@@ -0,0 +1,496 @@
+// Package tm7 imports Microsoft Threat Modeling Tool (.tm7) files.
+//
+// A .tm7 file is the tool's data contract serialised as XML. Each drawing
+// surface (diagram page) becomes a data_flow_diagram_v2: process, external
+// interactor and data store stencils become processes, external elements and
+// data stores, border trust boundaries become trust zones (an element belongs
+// to the smallest boundary its centre falls within) and connectors become
+// flows. The generated threat list becomes threat blocks, each threat's state
+// and justification mapped onto its description and controls.
+//
+// Stencil properties threatcl has no equivalent for, line trust boundaries
+// and similar are reported as warnings.
+package tm7
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/threatcl/spec"
+	"github.com/threatcl/threatcl/internal/tmutil"
+)
+
+// Generic stencil types.
+const (
+	typeProcess        = "GE.P"
+	typeExternal       = "GE.EI"
+	typeDataStore      = "GE.DS"
+	typeFlow           = "GE.DF"
+	typeBorderBoundary = "GE.TB.B"
+	typeLineBoundary   = "GE.TB.L"
+)
+
+// Threat states.
+const (
+	StateMitigated          = "Mitigated"
+	StateNotApplicable      = "NotApplicable"
+	StateNotStarted         = "NotStarted"
+	StateNeedsInvestigation = "NeedsInvestigation"
+)
+
+// Options tune the import.
+type Options struct {
+	// Name is used when the file doesn't set a threat model name.
+	Name string
+
+	// Stride is the allowed stride values (see spec.ThreatmodelSpecConfig).
+	// TMT threat categories are matched against it.
+	Stride []string
+}
+
+// Result is the imported threat model and anything that didn't map cleanly.
+type Result struct {
+	Threatmodel *spec.Threatmodel
+	Warnings    []string
+}
+
+type threatModel struct {
+	Surfaces []surface       `xml:"DrawingSurfaceList>DrawingSurfaceModel"`
+	Meta     metaInformation `xml:"MetaInformation"`
+	Threats  []threatEntry   `xml:"ThreatInstances>KeyValueOfstringThreatpc_P0_PhOB"`
+}
+
+type metaInformation struct {
+	Name                 string `xml:"ThreatModelName"`
+	Owner                string `xml:"Owner"`
+	Reviewer             string `xml:"Reviewer"`
+	Contributors         string `xml:"Contributors"`
+	Description          string `xml:"HighLevelSystemDescription"`
+	Assumptions          string `xml:"Assumptions"`
+	ExternalDependencies string `xml:"ExternalDependencies"`
+}
+
+type surface struct {
+	Guid    string      `xml:"Guid"`
+	Header  string      `xml:"Header"`
+	Borders []stencilKV `xml:"Borders>KeyValueOfguidanyType"`
+	Lines   []stencilKV `xml:"Lines>KeyValueOfguidanyType"`
+}
+
+type stencilKV struct {
+	Key   string  `xml:"Key"`
+	Value stencil `xml:"Value"`
+}
+
+type stencil struct {
+	GenericTypeId string     `xml:"GenericTypeId"`
+	TypeId        string     `xml:"TypeId"`
+	Guid          string     `xml:"Guid"`
+	Properties    []property `xml:"Properties>anyType"`
+
+	Left   float64 `xml:"Left"`
+	Top    float64 `xml:"Top"`
+	Width  float64 `xml:"Width"`
+	Height float64 `xml:"Height"`
+
+	SourceGuid string `xml:"SourceGuid"`
+	TargetGuid string `xml:"TargetGuid"`
+}
+
+type property struct {
+	Type          string        `xml:"type,attr"`
+	DisplayName   string        `xml:"DisplayName"`
+	SelectedIndex int           `xml:"SelectedIndex"`
+	Value         propertyValue `xml:"Value"`
+}
+
+type propertyValue struct {
+	Text    string   `xml:",chardata"`
+	Strings []string `xml:"string"`
+}
+
+type threatEntry struct {
+	Key   string         `xml:"Key"`
+	Value threatInstance `xml:"Value"`
+}
+
+type threatInstance struct {
+	Id                 string      `xml:"Id"`
+	DrawingSurfaceGuid string      `xml:"DrawingSurfaceGuid"`
+	FlowGuid           string      `xml:"FlowGuid"`
+	SourceGuid         string      `xml:"SourceGuid"`
+	TargetGuid         string      `xml:"TargetGuid"`
+	State              string      `xml:"State"`
+	StateInformation   string      `xml:"StateInformation"`
+	Priority           string      `xml:"Priority"`
+	Properties         []kvStrings `xml:"Properties>KeyValueOfstringstring"`
+}
+
+type kvStrings struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// Import parses a .tm7 file and converts it into a threat model.
+func Import(data []byte, opts Options) (*Result, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	raw := &threatModel{}
+	if err := xml.Unmarshal(data, raw); err != nil {
+		return nil, fmt.Errorf("error parsing tm7: %s", err)
+	}
+	if len(raw.Surfaces) == 0 && len(raw.Threats) == 0 {
+		return nil, fmt.Errorf("error parsing tm7: no diagrams or threats found")
+	}
+
+	i := &importer{
+		raw:   raw,
+		opts:  opts,
+		tm:    &spec.Threatmodel{},
+		names: map[string]string{},
+		flows: map[string]*spec.DfdFlow{},
+
+		surfaces: map[string]string{},
+	}
+	i.meta()
+	for _, s := range raw.Surfaces {
+		i.diagram(s)
+	}
+	i.threats()
+
+	return &Result{Threatmodel: i.tm, Warnings: i.warnings}, nil
+}
+
+type importer struct {
+	raw      *threatModel
+	opts     Options
+	tm       *spec.Threatmodel
+	warnings []string
+
+	// stencil guid -> element name, connector guid -> flow, and surface
+	// guid -> diagram name, for describing threats
+	names    map[string]string
+	flows    map[string]*spec.DfdFlow
+	surfaces map[string]string
+}
+
+func (i *importer) warn(format string, args ...interface{}) {
+	i.warnings = append(i.warnings, fmt.Sprintf(format, args...))
+}
+
+func (i *importer) meta() {
+	m := i.raw.Meta
+	i.tm.Name = strings.TrimSpace(m.Name)
+	if i.tm.Name == "" {
+		i.tm.Name = i.opts.Name
+	}
+	i.tm.Author = strings.TrimSpace(m.Owner)
+	if i.tm.Author == "" {
+		i.warn("the model has no owner, author is empty")
+	}
+	i.tm.Description = strings.TrimSpace(m.Description)
+
+	for _, aa := range []struct{ name, value string }{
+		{"reviewer", m.Reviewer},
+		{"contributors", m.Contributors},
+		{"assumptions", m.Assumptions},
+		{"external_dependencies", m.ExternalDependencies},
+	} {
+		if v := strings.TrimSpace(aa.value); v != "" {
+			i.tm.AdditionalAttributes = append(i.tm.AdditionalAttributes, &spec.AdditionalAttribute{Name: aa.name, Value: v})
+		}
+	}
+}
+
+// diagram converts one drawing surface into a data_flow_diagram_v2.
+func (i *importer) diagram(s surface) {
+	name := strings.TrimSpace(s.Header)
+	if name == "" {
+		name = fmt.Sprintf("Diagram %d", len(i.tm.DataFlowDiagrams)+1)
+	}
+	i.surfaces[s.Guid] = name
+
+	dfd := &spec.DataFlowDiagram{Name: name}
+	taken := map[string]bool{}
+
+	unique := func(n, kind string) string {
+		out := n
+		for c := 2; taken[out]; c++ {
+			out = fmt.Sprintf("%s (%d)", n, c)
+		}
+		if out != n {
+			i.warn("diagram %q: %s name %q is already used, imported as %q", name, kind, n, out)
+		}
+		taken[out] = true
+		return out
+	}
+
+	boundaries := []stencil{}
+	for _, b := range s.Borders {
+		if b.Value.GenericTypeId == typeBorderBoundary {
+			boundaries = append(boundaries, b.Value)
+		}
+	}
+
+	for _, b := range s.Borders {
+		st := b.Value
+		kind := ""
+		switch st.GenericTypeId {
+		case typeProcess:
+			kind = "process"
+		case typeExternal:
+			kind = "external_element"
+		case typeDataStore:
+			kind = "data_store"
+		case typeBorderBoundary:
+			continue
+		default:
+			i.warn("diagram %q: unsupported stencil %q (%s), skipped", name, st.displayName(), st.GenericTypeId)
+			continue
+		}
+
+		el := unique(st.name(), kind)
+		i.names[st.Guid] = el
+		zone := zoneFor(st, boundaries)
+		i.unsupportedProperties(name, kind, el, st)
+
+		switch kind {
+		case "process":
+			dfd.Processes = append(dfd.Processes, &spec.DfdProcess{Name: el, TrustZone: zone})
+		case "external_element":
+			dfd.ExternalElements = append(dfd.ExternalElements, &spec.DfdExternal{Name: el, TrustZone: zone})
+		case "data_store":
+			dfd.DataStores = append(dfd.DataStores, &spec.DfdData{Name: el, TrustZone: zone})
+		}
+	}
+
+	for _, l := range s.Lines {
+		st := l.Value
+		switch st.GenericTypeId {
+		case typeFlow:
+		case typeLineBoundary:
+			i.warn("diagram %q: line trust boundary %q can't be expressed as a trust zone, skipped", name, st.name())
+			continue
+		default:
+			i.warn("diagram %q: unsupported connector %q (%s), skipped", name, st.displayName(), st.GenericTypeId)
+			continue
+		}
+
+		from, okFrom := i.names[st.SourceGuid]
+		to, okTo := i.names[st.TargetGuid]
+		if !okFrom || !okTo {
+			i.warn("diagram %q: flow %q isn't connected at both ends, skipped", name, st.name())
+			continue
+		}
+
+		f := &spec.DfdFlow{Name: st.name(), From: from, To: to, Protocol: st.protocol()}
+		i.flows[st.Guid] = f
+		i.unsupportedProperties(name, "flow", f.Name, st)
+		dfd.Flows = append(dfd.Flows, f)
+	}
+
+	i.tm.DataFlowDiagrams = append(i.tm.DataFlowDiagrams, dfd)
+}
+
+// unsupportedProperties warns about stencil properties that were set but have
+// nowhere to go in a data_flow_diagram_v2.
+func (i *importer) unsupportedProperties(diagram, kind, name string, st stencil) {
+	set := []string{}
+	for _, p := range st.Properties {
+		if p.DisplayName == "Name" || strings.HasSuffix(p.Type, "HeaderDisplayAttribute") {
+			continue
+		}
+		if v := p.value(); v != "" {
+			set = append(set, fmt.Sprintf("%s=%s", p.DisplayName, v))
+		}
+	}
+	if len(set) > 0 {
+		i.warn("diagram %q: %s %q: unsupported stencil properties not imported: %s", diagram, kind, name, strings.Join(set, ", "))
+	}
+}
+
+// threats converts the threat list, ordered by threat id.
+func (i *importer) threats() {
+	entries := append([]threatEntry{}, i.raw.Threats...)
+	sort.SliceStable(entries, func(a, b int) bool {
+		ia, _ := strconv.Atoi(entries[a].Value.Id)
+		ib, _ := strconv.Atoi(entries[b].Value.Id)
+		return ia < ib
+	})
+
+	taken := map[string]bool{}
+	for _, e := range entries {
+		ti := e.Value
+		props := map[string]string{}
+		for _, p := range ti.Properties {
+			props[p.Key] = strings.TrimSpace(p.Value)
+		}
+
+		name := props["Title"]
+		if name == "" {
+			name = fmt.Sprintf("Threat %s", ti.Id)
+		}
+		if taken[name] {
+			name = fmt.Sprintf("%s (#%s)", name, ti.Id)
+		}
+		taken[name] = true
+
+		t := &spec.Threat{Name: name}
+
+		desc := []string{}
+		if d := tmutil.FirstNonEmpty(props["UserThreatDescription"], props["UserThreatShortDescription"]); d != "" {
+			desc = append(desc, d)
+		}
+		if where := i.interaction(ti); where != "" {
+			desc = append(desc, where)
+		}
+
+		state := tmutil.FirstNonEmpty(ti.State, StateNotStarted)
+		status := fmt.Sprintf("Threat Modeling Tool state: %s", state)
+		if ti.Priority != "" {
+			status += fmt.Sprintf(", priority: %s", ti.Priority)
+		}
+		justification := tmutil.FirstNonEmpty(strings.TrimSpace(ti.StateInformation), props["StateInformation"])
+		if justification != "" && state != StateMitigated {
+			status += fmt.Sprintf("\nJustification: %s", justification)
+		}
+		desc = append(desc, status)
+		t.Description = strings.Join(desc, "\n\n")
+
+		if cat := props["UserThreatCategory"]; cat != "" {
+			if s, ok := i.stride(cat); ok {
+				t.Stride = []string{s}
+			} else {
+				i.warn("threat %q: category %q isn't a STRIDE element, skipped", name, cat)
+			}
+		}
+
+		mitigations := props["PossibleMitigations"]
+		switch state {
+		case StateMitigated:
+			t.Controls = append(t.Controls, &spec.Control{
+				Name:                "Mitigation",
+				Implemented:         true,
+				Description:         tmutil.FirstNonEmpty(mitigations, "Marked as mitigated in the Threat Modeling Tool"),
+				ImplementationNotes: justification,
+			})
+		case StateNotApplicable:
+		default:
+			if mitigations != "" {
+				t.Controls = append(t.Controls, &spec.Control{
+					Name:        "Possible mitigations",
+					Description: mitigations,
+				})
+			}
+		}
+
+		i.tm.Threats = append(i.tm.Threats, t)
+	}
+}
+
+// interaction describes where a threat applies.
+func (i *importer) interaction(ti threatInstance) string {
+	diagram := i.surfaces[ti.DrawingSurfaceGuid]
+	if f, ok := i.flows[ti.FlowGuid]; ok {
+		return fmt.Sprintf("Applies to flow %q (%s to %s) in diagram %q", f.Name, f.From, f.To, diagram)
+	}
+	from, to := i.names[ti.SourceGuid], i.names[ti.TargetGuid]
+	switch {
+	case from != "" && to != "":
+		return fmt.Sprintf("Applies to the interaction between %q and %q in diagram %q", from, to, diagram)
+	case to != "":
+		return fmt.Sprintf("Applies to %q in diagram %q", to, diagram)
+	}
+	return ""
+}
+
+// stride maps a TMT category onto the configured STRIDE values. TMT spells
+// out "Information Disclosure".
+func (i *importer) stride(cat string) (string, bool) {
+	for _, s := range i.opts.Stride {
+		if strings.EqualFold(s, cat) {
+			return s, true
+		}
+	}
+	if strings.EqualFold(cat, "Information Disclosure") {
+		for _, s := range i.opts.Stride {
+			if strings.EqualFold(s, "Info Disclosure") {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
+
+// zoneFor returns the smallest border boundary containing the centre of st.
+func zoneFor(st stencil, boundaries []stencil) string {
+	cx, cy := st.Left+st.Width/2, st.Top+st.Height/2
+
+	zone, area := "", 0.0
+	for _, b := range boundaries {
+		if cx < b.Left || cx > b.Left+b.Width || cy < b.Top || cy > b.Top+b.Height {
+			continue
+		}
+		if a := b.Width * b.Height; zone == "" || a < area {
+			zone, area = b.name(), a
+		}
+	}
+	return zone
+}
+
+// name is the stencil's Name property, falling back to its type.
+func (s stencil) name() string {
+	for _, p := range s.Properties {
+		if p.DisplayName == "Name" {
+			if v := strings.TrimSpace(p.Value.Text); v != "" {
+				return v
+			}
+		}
+	}
+	return s.displayName()
+}
+
+// displayName is the stencil type's display name (the header property).
+func (s stencil) displayName() string {
+	for _, p := range s.Properties {
+		if strings.HasSuffix(p.Type, "HeaderDisplayAttribute") && p.DisplayName != "" {
+			return p.DisplayName
+		}
+	}
+	return s.GenericTypeId
+}
+
+// protocol is taken from specialised connector types (SE.DF.TMCore.HTTPS
+// and so on); the generic data flow has none.
+func (s stencil) protocol() string {
+	if !strings.HasPrefix(s.TypeId, "SE.DF.") {
+		return ""
+	}
+	return s.displayName()
+}
+
+// value returns a property's value when it's been set: non-empty strings,
+// true booleans and list selections other than the "Not Selected" default.
+func (p property) value() string {
+	switch {
+	case strings.HasSuffix(p.Type, "BooleanDisplayAttribute"):
+		if strings.TrimSpace(p.Value.Text) == "true" {
+			return "true"
+		}
+		return ""
+	case strings.HasSuffix(p.Type, "ListDisplayAttribute"):
+		if p.SelectedIndex < 0 || p.SelectedIndex >= len(p.Value.Strings) {
+			return ""
+		}
+		v := strings.TrimSpace(p.Value.Strings[p.SelectedIndex])
+		if v == "Not Selected" {
+			return ""
+		}
+		return v
+	}
+	return strings.TrimSpace(p.Value.Text)
+}
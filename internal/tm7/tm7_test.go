@@ -0,0 +1,299 @@
+package tm7
+
+import (
+	"strings"
+	"testing"
+)
+
+var testOpts = Options{
+	Name:   "fallback",
+	Stride: []string{"Spoofing", "Tampering", "Repudiation", "Info Disclosure", "Denial Of Service", "Elevation Of Privilege"},
+}
+
+// A trimmed down .tm7 as written by the Threat Modeling Tool 2016: two pages,
+// a border boundary, a line boundary and three generated threats.
+const testTm7 = "\xef\xbb\xbf" + `<ThreatModel xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.Model" xmlns:i="http://www.w3.org/2001/XMLSchema-instance">
+  <DrawingSurfaceList>
+    <DrawingSurfaceModel z:Id="i1" xmlns:z="http://schemas.microsoft.com/2003/10/Serialization/">
+      <GenericTypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">DRAWINGSURFACE</GenericTypeId>
+      <Guid xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">s1</Guid>
+      <Borders xmlns:a="http://schemas.microsoft.com/2003/10/Serialization/Arrays">
+        <a:KeyValueOfguidanyType>
+          <a:Key>b1</a:Key>
+          <a:Value z:Id="i2" i:type="BorderBoundary">
+            <GenericTypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">GE.TB.B</GenericTypeId>
+            <Guid xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">b1</Guid>
+            <Properties xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">
+              <a:anyType i:type="b:HeaderDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>Generic Trust Border Boundary</b:DisplayName><b:Name/><b:Value i:nil="true"/></a:anyType>
+              <a:anyType i:type="b:StringDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>Name</b:DisplayName><b:Name/><b:Value i:type="c:string" xmlns:c="http://www.w3.org/2001/XMLSchema">Data Centre</b:Value></a:anyType>
+            </Properties>
+            <TypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">GE.TB.B</TypeId>
+            <Height>400</Height><Left>300</Left><Top>0</Top><Width>600</Width>
+          </a:Value>
+        </a:KeyValueOfguidanyType>
+        <a:KeyValueOfguidanyType>
+          <a:Key>e1</a:Key>
+          <a:Value z:Id="i3" i:type="StencilRectangle">
+            <GenericTypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">GE.EI</GenericTypeId>
+            <Guid xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">e1</Guid>
+            <Properties xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">
+              <a:anyType i:type="b:HeaderDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>Browser</b:DisplayName><b:Name/><b:Value i:nil="true"/></a:anyType>
+              <a:anyType i:type="b:StringDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>Name</b:DisplayName><b:Name/><b:Value i:type="c:string" xmlns:c="http://www.w3.org/2001/XMLSchema">Customer</b:Value></a:anyType>
+              <a:anyType i:type="b:BooleanDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>Out Of Scope</b:DisplayName><b:Name>71f3d9aa-b8ef-4e54-8126-607a1d903103</b:Name><b:Value i:type="c:boolean" xmlns:c="http://www.w3.org/2001/XMLSchema">false</b:Value></a:anyType>
+              <a:anyType i:type="b:ListDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>Authenticates Itself</b:DisplayName><b:Name>authenticatesItself</b:Name><b:Value i:type="a:ArrayOfstring"><a:string>Not Selected</a:string><a:string>No</a:string><a:string>Yes</a:string></b:Value><b:SelectedIndex>2</b:SelectedIndex></a:anyType>
+            </Properties>
+            <TypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">SE.EI.TMCore.Browser</TypeId>
+            <Height>100</Height><Left>0</Left><Top>100</Top><Width>100</Width>
+          </a:Value>
+        </a:KeyValueOfguidanyType>
+        <a:KeyValueOfguidanyType>
+          <a:Key>p1</a:Key>
+          <a:Value z:Id="i4" i:type="StencilEllipse">
+            <GenericTypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">GE.P</GenericTypeId>
+            <Guid xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">p1</Guid>
+            <Properties xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">
+              <a:anyType i:type="b:HeaderDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>Web Application</b:DisplayName><b:Name/><b:Value i:nil="true"/></a:anyType>
+              <a:anyType i:type="b:StringDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>Name</b:DisplayName><b:Name/><b:Value i:type="c:string" xmlns:c="http://www.w3.org/2001/XMLSchema">Web</b:Value></a:anyType>
+              <a:anyType i:type="b:ListDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>Code Type</b:DisplayName><b:Name>codeType</b:Name><b:Value i:type="a:ArrayOfstring"><a:string>Not Selected</a:string><a:string>Managed</a:string></b:Value><b:SelectedIndex>0</b:SelectedIndex></a:anyType>
+            </Properties>
+            <TypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">SE.P.TMCore.WebApp</TypeId>
+            <Height>100</Height><Left>400</Left><Top>100</Top><Width>100</Width>
+          </a:Value>
+        </a:KeyValueOfguidanyType>
+        <a:KeyValueOfguidanyType>
+          <a:Key>d1</a:Key>
+          <a:Value z:Id="i5" i:type="StencilParallelLines">
+            <GenericTypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">GE.DS</GenericTypeId>
+            <Guid xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">d1</Guid>
+            <Properties xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">
+              <a:anyType i:type="b:HeaderDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>SQL Database</b:DisplayName><b:Name/><b:Value i:nil="true"/></a:anyType>
+              <a:anyType i:type="b:StringDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>Name</b:DisplayName><b:Name/><b:Value i:type="c:string" xmlns:c="http://www.w3.org/2001/XMLSchema">Orders DB</b:Value></a:anyType>
+            </Properties>
+            <TypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">SE.DS.TMCore.SQL</TypeId>
+            <Height>100</Height><Left>700</Left><Top>100</Top><Width>100</Width>
+          </a:Value>
+        </a:KeyValueOfguidanyType>
+      </Borders>
+      <Header>Level 0</Header>
+      <Lines xmlns:a="http://schemas.microsoft.com/2003/10/Serialization/Arrays">
+        <a:KeyValueOfguidanyType>
+          <a:Key>f1</a:Key>
+          <a:Value z:Id="i6" i:type="Connector">
+            <GenericTypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">GE.DF</GenericTypeId>
+            <Guid xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">f1</Guid>
+            <Properties xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">
+              <a:anyType i:type="b:HeaderDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>HTTPS</b:DisplayName><b:Name/><b:Value i:nil="true"/></a:anyType>
+              <a:anyType i:type="b:StringDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>Name</b:DisplayName><b:Name/><b:Value i:type="c:string" xmlns:c="http://www.w3.org/2001/XMLSchema">browse</b:Value></a:anyType>
+            </Properties>
+            <TypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">SE.DF.TMCore.HTTPS</TypeId>
+            <SourceGuid>e1</SourceGuid><TargetGuid>p1</TargetGuid>
+          </a:Value>
+        </a:KeyValueOfguidanyType>
+        <a:KeyValueOfguidanyType>
+          <a:Key>f2</a:Key>
+          <a:Value z:Id="i7" i:type="Connector">
+            <GenericTypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">GE.DF</GenericTypeId>
+            <Guid xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">f2</Guid>
+            <Properties xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">
+              <a:anyType i:type="b:HeaderDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>Generic Data Flow</b:DisplayName><b:Name/><b:Value i:nil="true"/></a:anyType>
+              <a:anyType i:type="b:StringDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>Name</b:DisplayName><b:Name/><b:Value i:type="c:string" xmlns:c="http://www.w3.org/2001/XMLSchema">query</b:Value></a:anyType>
+            </Properties>
+            <TypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">GE.DF</TypeId>
+            <SourceGuid>p1</SourceGuid><TargetGuid>d1</TargetGuid>
+          </a:Value>
+        </a:KeyValueOfguidanyType>
+        <a:KeyValueOfguidanyType>
+          <a:Key>l1</a:Key>
+          <a:Value z:Id="i8" i:type="LineBoundary">
+            <GenericTypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">GE.TB.L</GenericTypeId>
+            <Guid xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">l1</Guid>
+            <Properties xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">
+              <a:anyType i:type="b:StringDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>Name</b:DisplayName><b:Name/><b:Value i:type="c:string" xmlns:c="http://www.w3.org/2001/XMLSchema">Internet Boundary</b:Value></a:anyType>
+            </Properties>
+            <TypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">GE.TB.L</TypeId>
+          </a:Value>
+        </a:KeyValueOfguidanyType>
+      </Lines>
+    </DrawingSurfaceModel>
+    <DrawingSurfaceModel z:Id="i9" xmlns:z="http://schemas.microsoft.com/2003/10/Serialization/">
+      <Guid xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">s2</Guid>
+      <Borders xmlns:a="http://schemas.microsoft.com/2003/10/Serialization/Arrays">
+        <a:KeyValueOfguidanyType>
+          <a:Key>p2</a:Key>
+          <a:Value z:Id="i10" i:type="StencilEllipse">
+            <GenericTypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">GE.P</GenericTypeId>
+            <Guid xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">p2</Guid>
+            <Properties xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">
+              <a:anyType i:type="b:StringDisplayAttribute" xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase.Attributes"><b:DisplayName>Name</b:DisplayName><b:Name/><b:Value i:type="c:string" xmlns:c="http://www.w3.org/2001/XMLSchema">Worker</b:Value></a:anyType>
+            </Properties>
+            <TypeId xmlns="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">GE.P</TypeId>
+            <Height>100</Height><Left>0</Left><Top>0</Top><Width>100</Width>
+          </a:Value>
+        </a:KeyValueOfguidanyType>
+      </Borders>
+      <Header>Jobs</Header>
+      <Lines xmlns:a="http://schemas.microsoft.com/2003/10/Serialization/Arrays"/>
+    </DrawingSurfaceModel>
+  </DrawingSurfaceList>
+  <MetaInformation>
+    <Assumptions>Customers use modern browsers</Assumptions>
+    <Contributors/>
+    <ExternalDependencies/>
+    <HighLevelSystemDescription>An online shop</HighLevelSystemDescription>
+    <Owner>@alice</Owner>
+    <Reviewer>@bob</Reviewer>
+    <ThreatModelName>Shop</ThreatModelName>
+  </MetaInformation>
+  <Notes/>
+  <ThreatInstances xmlns:a="http://schemas.microsoft.com/2003/10/Serialization/Arrays">
+    <a:KeyValueOfstringThreatpc_P0_PhOB>
+      <a:Key>T2</a:Key>
+      <a:Value xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">
+        <b:DrawingSurfaceGuid>s1</b:DrawingSurfaceGuid>
+        <b:FlowGuid>f2</b:FlowGuid>
+        <b:Id>2</b:Id>
+        <b:Priority>High</b:Priority>
+        <b:Properties>
+          <a:KeyValueOfstringstring><a:Key>Title</a:Key><a:Value>SQL injection</a:Value></a:KeyValueOfstringstring>
+          <a:KeyValueOfstringstring><a:Key>UserThreatCategory</a:Key><a:Value>Tampering</a:Value></a:KeyValueOfstringstring>
+          <a:KeyValueOfstringstring><a:Key>UserThreatDescription</a:Key><a:Value>Web could inject SQL into Orders DB</a:Value></a:KeyValueOfstringstring>
+          <a:KeyValueOfstringstring><a:Key>PossibleMitigations</a:Key><a:Value>Use parameterised queries</a:Value></a:KeyValueOfstringstring>
+        </b:Properties>
+        <b:SourceGuid>p1</b:SourceGuid>
+        <b:State>Mitigated</b:State>
+        <b:StateInformation>All queries go through the ORM</b:StateInformation>
+        <b:TargetGuid>d1</b:TargetGuid>
+      </a:Value>
+    </a:KeyValueOfstringThreatpc_P0_PhOB>
+    <a:KeyValueOfstringThreatpc_P0_PhOB>
+      <a:Key>T1</a:Key>
+      <a:Value xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">
+        <b:DrawingSurfaceGuid>s1</b:DrawingSurfaceGuid>
+        <b:FlowGuid>f1</b:FlowGuid>
+        <b:Id>1</b:Id>
+        <b:Priority>Medium</b:Priority>
+        <b:Properties>
+          <a:KeyValueOfstringstring><a:Key>Title</a:Key><a:Value>Data flow sniffing</a:Value></a:KeyValueOfstringstring>
+          <a:KeyValueOfstringstring><a:Key>UserThreatCategory</a:Key><a:Value>Information Disclosure</a:Value></a:KeyValueOfstringstring>
+          <a:KeyValueOfstringstring><a:Key>UserThreatDescription</a:Key><a:Value>Data flowing across browse may be sniffed</a:Value></a:KeyValueOfstringstring>
+        </b:Properties>
+        <b:SourceGuid>e1</b:SourceGuid>
+        <b:State>NotApplicable</b:State>
+        <b:StateInformation>TLS everywhere</b:StateInformation>
+        <b:TargetGuid>p1</b:TargetGuid>
+      </a:Value>
+    </a:KeyValueOfstringThreatpc_P0_PhOB>
+    <a:KeyValueOfstringThreatpc_P0_PhOB>
+      <a:Key>T3</a:Key>
+      <a:Value xmlns:b="http://schemas.datacontract.org/2004/07/ThreatModeling.KnowledgeBase">
+        <b:DrawingSurfaceGuid>s1</b:DrawingSurfaceGuid>
+        <b:FlowGuid>f1</b:FlowGuid>
+        <b:Id>3</b:Id>
+        <b:Properties>
+          <a:KeyValueOfstringstring><a:Key>Title</a:Key><a:Value>SQL injection</a:Value></a:KeyValueOfstringstring>
+          <a:KeyValueOfstringstring><a:Key>UserThreatCategory</a:Key><a:Value>Made Up</a:Value></a:KeyValueOfstringstring>
+          <a:KeyValueOfstringstring><a:Key>PossibleMitigations</a:Key><a:Value>Validate input</a:Value></a:KeyValueOfstringstring>
+        </b:Properties>
+        <b:State>NeedsInvestigation</b:State>
+      </a:Value>
+    </a:KeyValueOfstringThreatpc_P0_PhOB>
+  </ThreatInstances>
+</ThreatModel>`
+
+func TestImport(t *testing.T) {
+	res, err := Import([]byte(testTm7), testOpts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tm := res.Threatmodel
+
+	if tm.Name != "Shop" || tm.Author != "@alice" || tm.Description != "An online shop" {
+		t.Errorf("unexpected threatmodel: %q %q %q", tm.Name, tm.Author, tm.Description)
+	}
+	if len(tm.AdditionalAttributes) != 2 || tm.AdditionalAttributes[0].Name != "reviewer" || tm.AdditionalAttributes[1].Name != "assumptions" {
+		t.Errorf("unexpected additional attributes: %+v", tm.AdditionalAttributes)
+	}
+
+	if len(tm.DataFlowDiagrams) != 2 {
+		t.Fatalf("expected a diagram per page, got %d", len(tm.DataFlowDiagrams))
+	}
+	l0 := tm.DataFlowDiagrams[0]
+	if l0.Name != "Level 0" || tm.DataFlowDiagrams[1].Name != "Jobs" {
+		t.Errorf("unexpected diagram names: %q, %q", l0.Name, tm.DataFlowDiagrams[1].Name)
+	}
+	if len(l0.ExternalElements) != 1 || l0.ExternalElements[0].Name != "Customer" || l0.ExternalElements[0].TrustZone != "" {
+		t.Errorf("unexpected external elements: %+v", l0.ExternalElements)
+	}
+	if len(l0.Processes) != 1 || l0.Processes[0].Name != "Web" || l0.Processes[0].TrustZone != "Data Centre" {
+		t.Errorf("unexpected processes: %+v", l0.Processes)
+	}
+	if len(l0.DataStores) != 1 || l0.DataStores[0].Name != "Orders DB" || l0.DataStores[0].TrustZone != "Data Centre" {
+		t.Errorf("unexpected data stores: %+v", l0.DataStores)
+	}
+	if len(l0.Flows) != 2 ||
+		l0.Flows[0].From != "Customer" || l0.Flows[0].To != "Web" || l0.Flows[0].Protocol != "HTTPS" ||
+		l0.Flows[1].Name != "query" || l0.Flows[1].Protocol != "" {
+		t.Errorf("unexpected flows: %+v %+v", l0.Flows[0], l0.Flows[1])
+	}
+
+	if len(tm.Threats) != 3 {
+		t.Fatalf("expected 3 threats, got %d", len(tm.Threats))
+	}
+
+	sniff := tm.Threats[0]
+	if sniff.Name != "Data flow sniffing" || len(sniff.Stride) != 1 || sniff.Stride[0] != "Info Disclosure" || len(sniff.Controls) != 0 {
+		t.Errorf("unexpected threat: %+v", sniff)
+	}
+	for _, exp := range []string{`flow "browse" (Customer to Web)`, "state: NotApplicable, priority: Medium", "Justification: TLS everywhere"} {
+		if !strings.Contains(sniff.Description, exp) {
+			t.Errorf("expected %q to contain %q", sniff.Description, exp)
+		}
+	}
+
+	sqli := tm.Threats[1]
+	if len(sqli.Controls) != 1 || !sqli.Controls[0].Implemented ||
+		sqli.Controls[0].Description != "Use parameterised queries" || sqli.Controls[0].ImplementationNotes != "All queries go through the ORM" {
+		t.Errorf("unexpected mitigated threat: %+v", sqli.Controls)
+	}
+
+	dup := tm.Threats[2]
+	if dup.Name != "SQL injection (#3)" || len(dup.Controls) != 1 || dup.Controls[0].Implemented || len(dup.Stride) != 0 {
+		t.Errorf("unexpected open threat: %+v", dup)
+	}
+
+	warnings := strings.Join(res.Warnings, "\n")
+	for _, exp := range []string{
+		`external_element "Customer": unsupported stencil properties not imported: Authenticates Itself=Yes`,
+		`line trust boundary "Internet Boundary"`,
+		`category "Made Up" isn't a STRIDE element`,
+	} {
+		if !strings.Contains(warnings, exp) {
+			t.Errorf("expected warnings to contain %q:\n%s", exp, warnings)
+		}
+	}
+	// Unset properties aren't worth a warning.
+	if strings.Contains(warnings, "Code Type") || strings.Contains(warnings, "Out Of Scope") {
+		t.Errorf("unexpected warning for an unset property:\n%s", warnings)
+	}
+}
+
+func TestImportFallbackName(t *testing.T) {
+	res, err := Import([]byte(`<ThreatModel><DrawingSurfaceList><DrawingSurfaceModel><Header>D</Header></DrawingSurfaceModel></DrawingSurfaceList></ThreatModel>`), testOpts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.Threatmodel.Name != "fallback" {
+		t.Errorf("expected the fallback name, got %q", res.Threatmodel.Name)
+	}
+	if len(res.Warnings) != 1 || !strings.Contains(res.Warnings[0], "no owner") {
+		t.Errorf("unexpected warnings: %v", res.Warnings)
+	}
+}
+
+func TestImportInvalid(t *testing.T) {
+	for _, in := range []string{"not xml", "<ThreatModel></ThreatModel>"} {
+		if _, err := Import([]byte(in), testOpts); err == nil || !strings.HasPrefix(err.Error(), "error parsing tm7") {
+			t.Errorf("expected a parse error for %q, got %v", in, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Package tmfields walks the free-text fields of a parsed threat model.
+//
+// Each visited field carries its HCL attribute name (description,
+// implementation_notes, ...) and a human readable path to the block that holds
+// it, so policies such as redaction profiles and secret scanning can match on
+// field names and report where a value lives. Names and references (threat
+// names, information_asset_refs, ...) are identifiers rather than free text
+// and aren't visited; changing them would break the model.
+//
+// Free-form attributes (a threat model's additional_attribute blocks and a
+// control's attribute blocks) are visited with the attribute's own name as
+// the field name, pointing at its value.
+package tmfields
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/threatcl/spec"
+)
+
+// Field is one free-text value within a threat model.
+type Field struct {
+	// Name is the HCL attribute name, or the attribute's own name for
+	// additional_attribute and control attribute blocks.
+	Name string
+
+	// Path locates the field, e.g. `threat "Theft" > control "Guards" >
+	// implementation_notes`.
+	Path string
+
+	// Value points at the field's value and may be modified in place.
+	Value *string
+
+	// Attribute is true for additional_attribute and control attribute
+	// blocks.
+	Attribute bool
+}
+
+// Visitor is called for every field. Returning true removes the field: the
+// whole block for attributes and repository entries, or the value is cleared
+// for plain fields.
+type Visitor func(f *Field) (remove bool)
+
+// Walk visits every free-text field in tm.
+func Walk(tm *spec.Threatmodel, fn Visitor) {
+	w := &walker{fn: fn}
+
+	w.str(nil, "description", &tm.Description)
+	w.str(nil, "link", &tm.Link)
+	w.str(nil, "diagram_link", &tm.DiagramLink)
+	w.str(nil, "author", &tm.Author)
+
+	repos := tm.Repository[:0]
+	for i := range tm.Repository {
+		if !w.visit(nil, "repository", &tm.Repository[i], false) {
+			repos = append(repos, tm.Repository[i])
+		}
+	}
+	tm.Repository = repos
+
+	aas := tm.AdditionalAttributes[:0]
+	for _, aa := range tm.AdditionalAttributes {
+		if aa == nil || !w.visit([]string{block("additional_attribute", aa.Name)}, aa.Name, &aa.Value, true) {
+			aas = append(aas, aa)
+		}
+	}
+	tm.AdditionalAttributes = aas
+
+	for _, ia := range tm.InformationAssets {
+		p := []string{block("information_asset", ia.Name)}
+		w.str(p, "description", &ia.Description)
+		w.str(p, "information_classification", &ia.InformationClassification)
+		w.str(p, "source", &ia.Source)
+	}
+
+	for i, uc := range tm.UseCases {
+		w.str([]string{fmt.Sprintf("usecase %d", i+1)}, "description", &uc.Description)
+	}
+
+	for i, ex := range tm.Exclusions {
+		w.str([]string{fmt.Sprintf("exclusion %d", i+1)}, "description", &ex.Description)
+	}
+
+	for _, tpd := range tm.ThirdPartyDependencies {
+		p := []string{block("third_party_dependency", tpd.Name)}
+		w.str(p, "description", &tpd.Description)
+		w.str(p, "uptime_notes", &tpd.UptimeNotes)
+	}
+
+	for _, t := range tm.Threats {
+		p := []string{block("threat", t.Name)}
+		w.str(p, "description", &t.Description)
+		w.str(p, "control", &t.Control)
+
+		if t.Risk != nil {
+			w.str(append(p, "risk"), "rationale", &t.Risk.Rationale)
+		}
+
+		for i, pc := range t.ProposedControls {
+			w.str(append(p, fmt.Sprintf("proposed_control %d", i+1)), "description", &pc.Description)
+		}
+
+		for _, c := range t.Controls {
+			w.control(p, c)
+		}
+		for _, c := range t.ExpandedControls {
+			w.control(p, c)
+		}
+	}
+
+	for _, m := range tm.MermaidDiagrams {
+		p := []string{block("mermaid", m.Name)}
+		w.str(p, "description", &m.Description)
+		w.str(p, "content", &m.Content)
+	}
+}
+
+type walker struct {
+	fn Visitor
+}
+
+func (w *walker) control(parent []string, c *spec.Control) {
+	p := append(append([]string{}, parent...), block("control", c.Name))
+	w.str(p, "description", &c.Description)
+	w.str(p, "implementation_notes", &c.ImplementationNotes)
+
+	attrs := c.Attributes[:0]
+	for _, a := range c.Attributes {
+		if a == nil || !w.visit(append(p, block("attribute", a.Name)), a.Name, &a.Value, true) {
+			attrs = append(attrs, a)
+		}
+	}
+	c.Attributes = attrs
+}
+
+// str visits a plain field, clearing it when the visitor removes it. Empty
+// fields aren't visited.
+func (w *walker) str(parent []string, name string, v *string) {
+	if *v == "" {
+		return
+	}
+	if w.visit(parent, name, v, false) {
+		*v = ""
+	}
+}
+
+func (w *walker) visit(parent []string, name string, v *string, attribute bool) bool {
+	path := name
+	if attribute {
+		path = strings.Join(parent, " > ")
+	} else if len(parent) > 0 {
+		path = strings.Join(parent, " > ") + " > " + name
+	}
+	return w.fn(&Field{Name: name, Path: path, Value: v, Attribute: attribute})
+}
+
+func block(kind, name string) string {
+	return fmt.Sprintf("%s %q", kind, name)
+}
@@ -0,0 +1,62 @@
+package tmfields
+
+import (
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+func TestWalk(t *testing.T) {
+	tm := &spec.Threatmodel{
+		Name:        "tm",
+		Description: "model",
+		Repository:  []string{"https://a", "https://b"},
+		Threats: []*spec.Threat{
+			{
+				Name:        "theft",
+				Description: "stolen",
+				Risk:        &spec.Risk{Rationale: "easy"},
+				Controls: []*spec.Control{
+					{
+						Name:                "guards",
+						ImplementationNotes: "night shift",
+						Attributes: []*spec.ControlAttribute{
+							{Name: "owner", Value: "security"},
+							{Name: "ticket", Value: "SEC-1"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	paths := map[string]string{}
+	Walk(tm, func(f *Field) bool {
+		paths[f.Path] = *f.Value
+		return f.Name == "ticket" || *f.Value == "https://a" || f.Name == "implementation_notes"
+	})
+
+	for path, exp := range map[string]string{
+		"description":                                              "model",
+		`threat "theft" > description`:                             "stolen",
+		`threat "theft" > risk > rationale`:                        "easy",
+		`threat "theft" > control "guards" > implementation_notes`: "night shift",
+		`threat "theft" > control "guards" > attribute "owner"`:    "security",
+		"repository": "https://b",
+	} {
+		if paths[path] != exp {
+			t.Errorf("expected %s to be %q, got %q", path, exp, paths[path])
+		}
+	}
+
+	if len(tm.Repository) != 1 || tm.Repository[0] != "https://b" {
+		t.Errorf("expected the removed repository to go, got %v", tm.Repository)
+	}
+	c := tm.Threats[0].Controls[0]
+	if len(c.Attributes) != 1 || c.Attributes[0].Name != "owner" {
+		t.Errorf("expected the removed attribute to go, got %+v", c.Attributes)
+	}
+	if c.ImplementationNotes != "" {
+		t.Errorf("expected the removed field to be cleared")
+	}
+}
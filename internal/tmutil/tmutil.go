@@ -0,0 +1,48 @@
+// Package tmutil holds small helpers shared by the converters, exporters
+// and checks: which controls a threat has, the first value that's set,
+// and stable ids for the objects the exporters write.
+package tmutil
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strings"
+
+	"github.com/threatcl/spec"
+)
+
+// AllControls is the threat's controls followed by any expanded controls
+// not already among them.
+func AllControls(t *spec.Threat) []*spec.Control {
+	out := append([]*spec.Control{}, t.Controls...)
+	seen := map[string]bool{}
+	for _, c := range t.Controls {
+		seen[c.Name] = true
+	}
+	for _, c := range t.ExpandedControls {
+		if !seen[c.Name] {
+			seen[c.Name] = true
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// FirstNonEmpty returns the first of s that isn't empty, or "".
+func FirstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// UUID formats a name-based (version 5 style) uuid for parts, so the same
+// parts always get the same id.
+func UUID(parts ...string) string {
+	h := sha1.Sum([]byte(strings.Join(parts, "\x00")))
+	h[6] = (h[6] & 0x0f) | 0x50
+	h[8] = (h[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
@@ -0,0 +1,42 @@
+package tmutil
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/threatcl/spec"
+)
+
+func TestAllControls(t *testing.T) {
+	th := &spec.Threat{
+		Controls:         []*spec.Control{{Name: "A"}, {Name: "B"}},
+		ExpandedControls: []*spec.Control{{Name: "B"}, {Name: "C"}},
+	}
+
+	got := []string{}
+	for _, c := range AllControls(th) {
+		got = append(got, c.Name)
+	}
+	if len(got) != 3 || got[0] != "A" || got[1] != "B" || got[2] != "C" {
+		t.Errorf("expected A, B and C, got %v", got)
+	}
+}
+
+func TestFirstNonEmpty(t *testing.T) {
+	if got := FirstNonEmpty("", "b", "c"); got != "b" {
+		t.Errorf("expected b, got %q", got)
+	}
+	if got := FirstNonEmpty("", ""); got != "" {
+		t.Errorf("expected nothing, got %q", got)
+	}
+}
+
+func TestUUID(t *testing.T) {
+	id := UUID("threat", "Theft")
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("expected a version 5 uuid, got %s", id)
+	}
+	if id != UUID("threat", "Theft") || id == UUID("threat", "Thef", "t") {
+		t.Errorf("expected uuids to be stable and to keep parts apart")
+	}
+}